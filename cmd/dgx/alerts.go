@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/weatherman/dgx-manager/internal/alert"
+	"github.com/weatherman/dgx-manager/internal/deploy"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// alerts command
+var alertsCmd = &cobra.Command{
+	Use:   "alerts",
+	Short: "List alert rules and test their notification hooks",
+	Long: `Alert rules watch the DGX for trouble such as a GPU running hot, memory or
+disk filling up, or an autostart deployment going down. 'dgx daemon' checks
+them every alerts.interval (default 30s) and notifies every hook when a rule
+fires, once its metric has stayed above the threshold for the rule's duration,
+and again when it resolves.
+
+Rules and hooks live in the config file:
+
+  alerts:
+    interval: 30s
+    rules:
+      - name: gpu-hot
+        metric: gpu_temp        # gpu_temp (°C), gpu_util, memory, disk (%), deployment
+        above: 85
+        for: 5m
+      - name: data-full
+        metric: disk
+        path: /data
+        above: 90
+      - name: chat-down
+        metric: deployment
+        deployment: chat        # an autostart name; empty watches every unit
+        for: 2m
+    hooks:
+      - url: https://hooks.slack.com/services/...
+      - command: notify-send "$DGX_ALERT_TEXT"
+
+Webhooks receive the event as a JSON POST; its "text" field is what Slack and
+compatible services display. Commands run locally through sh with the event
+in DGX_ALERT_RULE, DGX_ALERT_METRIC, DGX_ALERT_STATUS, DGX_ALERT_HOST,
+DGX_ALERT_VALUE, and DGX_ALERT_TEXT, and as JSON on stdin. Without rules, the
+defaults shown by 'dgx alerts list' apply.`,
+}
+
+var alertsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Show the alert rules with the DGX's current readings",
+	Long: `Show the alert rules and hooks, reading the DGX once to show where each
+metric stands against its threshold. Whether a rule has held long enough to
+fire is tracked by 'dgx daemon' (GET /v1/alerts).`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := cfgManager.Get()
+		if err := alert.Validate(cfg.Alerts); err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Config, err))
+		}
+		rules := alert.Rules(cfg.Alerts)
+
+		sample, err := readAlertSample(cfg, rules)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "RULE\tMETRIC\tFIRES WHEN\tNOW\tSTATE")
+		for _, r := range rules {
+			now, state := "-", "-"
+			if reading, ok := alert.Measure(r, sample); err == nil && ok {
+				now, state = reading.Detail, "ok"
+				if reading.Over {
+					state = "over"
+				}
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Name, r.Metric, alert.Threshold(r), now, state)
+		}
+		w.Flush()
+
+		if cfg.Alerts == nil || len(cfg.Alerts.Rules) == 0 {
+			fmt.Println("\nThese are the default rules; set alerts.rules in the config to replace them.")
+		}
+		hooks := alert.Hooks(cfg.Alerts)
+		if len(hooks) == 0 {
+			fmt.Println("\nNo hooks configured: 'dgx daemon' only logs alerts. Add alerts.hooks to be notified.")
+			return
+		}
+		fmt.Println("\nHooks:")
+		for _, h := range hooks {
+			fmt.Printf("  %s\n", alert.Describe(h))
+		}
+	},
+}
+
+var alertsTestCmd = &cobra.Command{
+	Use:   "test [rule]",
+	Short: "Send a test notification to every hook",
+	Long: `Send a test event for a rule (the first one by default) to every configured
+hook and report which hooks failed. The event's status is "test", so hook
+scripts can tell it from a real alert.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := cfgManager.Get()
+		if err := alert.Validate(cfg.Alerts); err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Config, err))
+		}
+		hooks := alert.Hooks(cfg.Alerts)
+		if len(hooks) == 0 {
+			exitWithError(exitcode.Wrap(exitcode.Config, fmt.Errorf("no alert hooks configured (set alerts.hooks in %s)", cfgManager.GetConfigPath())))
+		}
+
+		rules := alert.Rules(cfg.Alerts)
+		rule := rules[0]
+		if len(args) == 1 {
+			found := false
+			for _, r := range rules {
+				if r.Name == args[0] {
+					rule, found = r, true
+				}
+			}
+			if !found {
+				exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("no alert rule named %q (see 'dgx alerts list')", args[0])))
+			}
+		}
+
+		event := alert.NewEvent(cfg.Host, rule, alert.StatusTest, alert.Reading{}, time.Now())
+		failed := 0
+		for i, err := range alert.Notify(context.Background(), hooks, event) {
+			if err != nil {
+				failed++
+				fmt.Printf("FAIL  %s: %v\n", alert.Describe(hooks[i]), err)
+			} else {
+				fmt.Printf("OK    %s\n", alert.Describe(hooks[i]))
+			}
+		}
+		if failed > 0 {
+			exitWithError(fmt.Errorf("%d of %d hooks failed", failed, len(hooks)))
+		}
+	},
+}
+
+// readAlertSample reads the DGX once for the rules, as the daemon does on each check
+func readAlertSample(cfg *types.Config, rules []types.AlertRule) (alert.Sample, error) {
+	client, err := ssh.NewClient(cfg)
+	if err != nil {
+		return alert.Sample{}, err
+	}
+	defer client.Close()
+
+	sample, err := alert.Collect(context.Background(), client, alert.DiskPaths(rules))
+	if err != nil || !alert.WatchesDeployments(rules) {
+		return sample, err
+	}
+	if entries, err := deploy.NewManager(client).List(); err == nil {
+		sample.Deployments = alert.DeploymentStates(entries)
+	}
+	return sample, nil
+}
+
+func init() {
+	alertsCmd.AddCommand(alertsListCmd)
+	alertsCmd.AddCommand(alertsTestCmd)
+	rootCmd.AddCommand(alertsCmd)
+}
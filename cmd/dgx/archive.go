@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/transfer"
+)
+
+// archive command
+var archiveCmd = &cobra.Command{
+	Use:   "archive",
+	Short: "Tar directories on the DGX and stream archives to or from it",
+	Long: `Pack or unpack directories on the DGX with tar, streaming the archive over a
+single SSH channel. Moving one stream is far faster than copying thousands of
+small files one by one.
+
+Archive names ending in .tar.zst/.tzst or .tar.gz/.tgz are compressed on the DGX
+with zstd or gzip; a dgx: prefix keeps the archive on the DGX itself, and - means
+stdout or stdin.
+
+--compress sends an uncompressed archive zstd-compressed over the wire and
+restores it on the other side, which speeds up fp16 checkpoints and other
+compressible data on slow links. It needs zstd on both ends; without it the
+stream is sent as is.`,
+}
+
+var archiveCreateCmd = &cobra.Command{
+	Use:   "create <remote-dir> [archive]",
+	Short: "Archive a directory on the DGX",
+	Long: `Archive the contents of a directory on the DGX into a local file (default
+./<dir>.tar, or .tar.zst with --zstd), into a file on the DGX (dgx:path), or to
+stdout (-).
+
+Examples:
+  dgx archive create ~/outputs/run1 --zstd
+  dgx archive create ~/outputs/run1 dgx:~/backups/run1.tar.zst
+  dgx archive create ~/datasets/alpaca - | tar -tvf -
+  dgx archive create ~/checkpoints/step-9000 --compress`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		useZstd, _ := cmd.Flags().GetBool("zstd")
+		compress, _ := cmd.Flags().GetBool("compress")
+		bwlimit, _ := cmd.Flags().GetString("bwlimit")
+
+		dir := strings.TrimSuffix(args[0], "/")
+		dest := ""
+		if len(args) == 2 {
+			dest = args[1]
+		}
+		compression := transfer.DetectCompression(dest)
+		if useZstd {
+			compression = transfer.CompressZstd
+		}
+		if dest == "" {
+			dest = path.Base(dir) + compression.Extension()
+		}
+
+		client, err := ssh.NewClient(cfgManager.Get())
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+
+		if remote, ok := strings.CutPrefix(dest, "dgx:"); ok {
+			fmt.Printf("Archiving %s to %s on the DGX...\n", dir, remote)
+			if err := client.RunInteractive(transfer.TarCommand(dir, compression, remote)); err != nil {
+				exitWithError(err)
+			}
+			fmt.Printf("Created %s\n", remote)
+			return
+		}
+
+		var out io.Writer = os.Stdout
+		if dest != "-" {
+			f, err := os.Create(dest)
+			if err != nil {
+				exitWithError(exitcode.Wrap(exitcode.Usage, err))
+			}
+			defer f.Close()
+			out = f
+			fmt.Fprintf(os.Stderr, "Archiving %s to %s...\n", dir, dest)
+		}
+		counter := &countingWriter{w: out}
+		out = counter
+		// The archive is compressed on the DGX and restored here, so the file is unchanged
+		stream, inTransit := compression, ""
+		var decompressor io.WriteCloser
+		if compress && compression == transfer.CompressNone {
+			if err := transfer.ProbeZstd(client.Execute); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: sending the archive uncompressed: %v\n", err)
+			} else if decompressor, err = transfer.Decompressor(out); err != nil {
+				exitWithError(err)
+			} else {
+				out, stream, inTransit = decompressor, transfer.CompressZstd, ", zstd in transit"
+			}
+		}
+		if bwlimit != "" {
+			rate, err := transfer.ParseRate(bwlimit)
+			if err != nil {
+				exitWithError(exitcode.Wrap(exitcode.Usage, err))
+			}
+			out = transfer.NewWriter(out, transfer.NewLimiter(rate))
+		}
+
+		start := time.Now()
+		err = client.Stream(transfer.TarCommand(dir, stream, ""), nil, out, os.Stderr)
+		if decompressor != nil {
+			if closeErr := decompressor.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if err != nil {
+			if dest != "-" {
+				os.Remove(dest)
+			}
+			exitWithError(err)
+		}
+		if dest != "-" {
+			fmt.Fprintf(os.Stderr, "Wrote %s (%s%s)\n", dest, transferSummary(counter.n, time.Since(start)), inTransit)
+		}
+	},
+}
+
+var archiveExtractCmd = &cobra.Command{
+	Use:   "extract <archive> <remote-dir>",
+	Short: "Extract an archive into a directory on the DGX",
+	Long: `Extract a local archive (or - for stdin, or dgx:path for one already on the DGX)
+into a directory on the DGX, which is created if needed. The archive is
+decompressed on the DGX, so a .tar.zst is sent compressed; --compress does the
+same for an uncompressed archive when zstd is installed on both ends.
+
+Examples:
+  dgx archive extract ./checkpoints.tar.zst ~/outputs/run1
+  dgx archive extract dgx:~/backups/run1.tar.zst ~/restore/run1
+  tar -C ./data -cf - . | dgx archive extract - ~/datasets/mydata`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		useZstd, _ := cmd.Flags().GetBool("zstd")
+		compress, _ := cmd.Flags().GetBool("compress")
+		bwlimit, _ := cmd.Flags().GetString("bwlimit")
+
+		archive, dir := args[0], args[1]
+		compression := transfer.DetectCompression(archive)
+		if useZstd {
+			compression = transfer.CompressZstd
+		}
+
+		client, err := ssh.NewClient(cfgManager.Get())
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+
+		if remote, ok := strings.CutPrefix(archive, "dgx:"); ok {
+			fmt.Printf("Extracting %s into %s on the DGX...\n", remote, dir)
+			if err := client.RunInteractive(transfer.UntarCommand(dir, compression, remote)); err != nil {
+				exitWithError(err)
+			}
+			fmt.Printf("Extracted into %s\n", dir)
+			return
+		}
+
+		var in io.Reader = os.Stdin
+		if archive != "-" {
+			f, err := os.Open(archive)
+			if err != nil {
+				exitWithError(exitcode.Wrap(exitcode.Usage, err))
+			}
+			defer f.Close()
+			in = f
+		}
+		counter := &countingReader{r: in}
+		in = counter
+		stream, inTransit := compression, ""
+		var compressor io.ReadCloser
+		if compress && compression == transfer.CompressNone {
+			if err := transfer.ProbeZstd(client.Execute); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: sending the archive uncompressed: %v\n", err)
+			} else if compressor, err = transfer.Compressor(in); err != nil {
+				exitWithError(err)
+			} else {
+				in, stream, inTransit = compressor, transfer.CompressZstd, ", zstd in transit"
+			}
+		}
+		if bwlimit != "" {
+			rate, err := transfer.ParseRate(bwlimit)
+			if err != nil {
+				exitWithError(exitcode.Wrap(exitcode.Usage, err))
+			}
+			in = transfer.NewReader(in, transfer.NewLimiter(rate))
+		}
+
+		fmt.Printf("Extracting %s into %s...\n", archive, dir)
+		start := time.Now()
+		err = client.Stream(transfer.UntarCommand(dir, stream, ""), in, os.Stdout, os.Stderr)
+		if compressor != nil {
+			if closeErr := compressor.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Extracted into %s (%s%s)\n", dir, transferSummary(counter.n, time.Since(start)), inTransit)
+	},
+}
+
+func transferSummary(n int64, elapsed time.Duration) string {
+	mib := float64(n) / (1 << 20)
+	if elapsed < time.Millisecond {
+		return fmt.Sprintf("%.1f MiB", mib)
+	}
+	return fmt.Sprintf("%.1f MiB in %s, %.1f MiB/s", mib, elapsed.Round(100*time.Millisecond), mib/elapsed.Seconds())
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func init() {
+	for _, c := range []*cobra.Command{archiveCreateCmd, archiveExtractCmd} {
+		c.Flags().Bool("zstd", false, "Use zstd regardless of the archive name")
+		c.Flags().Bool("compress", false, "Compress an uncompressed archive with zstd in transit")
+		c.Flags().String("bwlimit", "", "Limit the stream to a rate, e.g. 10M (bytes per second)")
+	}
+	archiveCmd.AddCommand(archiveCreateCmd)
+	archiveCmd.AddCommand(archiveExtractCmd)
+	rootCmd.AddCommand(archiveCmd)
+}
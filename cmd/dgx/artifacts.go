@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/artifacts"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+// artifacts command
+var artifactsCmd = &cobra.Command{
+	Use:   "artifacts",
+	Short: "Download training outputs from the DGX",
+	Long: `Find and download the checkpoints and logs written by training jobs.
+
+A job is either the name of a playbook that writes outputs (see 'dgx artifacts
+jobs') or a directory on the DGX, such as a Trainer output_dir. Checkpoints are
+the checkpoint-<step> directories Hugging Face Trainer based tools write; the
+best one is read from best_model_checkpoint in trainer_state.json.`,
+}
+
+var artifactsJobsCmd = &cobra.Command{
+	Use:   "jobs",
+	Short: "List the playbooks whose outputs are known",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, job := range artifacts.Jobs {
+			fmt.Printf("%-10s %-20s %s\n", job.Name, job.Dir, job.Description)
+		}
+	},
+}
+
+var artifactsListCmd = &cobra.Command{
+	Use:   "list <job>",
+	Short: "Show the checkpoints a job has written",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		job, listing := loadArtifacts(args[0])
+		fmt.Printf("%s (%s, %s)\n", job.Name, job.Dir, artifacts.FormatBytes(listing.SizeKB*1024))
+		if len(listing.Checkpoints) == 0 {
+			fmt.Println("No checkpoint directories.")
+			return
+		}
+		fmt.Println()
+		listing.Print(os.Stdout)
+	},
+}
+
+var artifactsPullCmd = &cobra.Command{
+	Use:   "pull <job> [local-dir]",
+	Short: "Download a job's outputs, optionally with only one checkpoint",
+	Long: `Download a job's output directory into local-dir (default ./<job>).
+
+With --latest or --best only that checkpoint directory is downloaded, along with
+everything outside checkpoint directories (logs, configs, the final model).
+Interrupted downloads resume: finished files are skipped and partial files are
+kept and completed on the next run.
+
+Examples:
+  dgx artifacts pull nvfp4
+  dgx artifacts pull ~/LLaMA-Factory/saves/qwen-lora --best ./qwen-lora
+  dgx artifacts pull ~/outputs/run1 --latest`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		latest, _ := cmd.Flags().GetBool("latest")
+		best, _ := cmd.Flags().GetBool("best")
+		if latest && best {
+			fmt.Fprintln(os.Stderr, "Error: --latest and --best are mutually exclusive")
+			exit(exitcode.Usage)
+		}
+
+		job, listing := loadArtifacts(args[0])
+		local := job.Name
+		if len(args) == 2 {
+			local = args[1]
+		}
+
+		var filters []string
+		switch {
+		case latest:
+			c, ok := listing.Latest()
+			if !ok {
+				exitWithError(exitcode.Wrap(exitcode.Remote, fmt.Errorf("%s has no checkpoint directories", job.Dir)))
+			}
+			fmt.Printf("Latest checkpoint: %s (%s)\n", c.Name, artifacts.FormatBytes(c.SizeKB*1024))
+			filters = artifacts.KeepOnlyFilters(c.Name)
+		case best:
+			c, ok := listing.BestCheckpoint()
+			if !ok {
+				if listing.Best != "" {
+					exitWithError(exitcode.Wrap(exitcode.Remote, fmt.Errorf("best checkpoint %s is no longer in %s", listing.Best, job.Dir)))
+				}
+				exitWithError(exitcode.Wrap(exitcode.Remote, fmt.Errorf("no best_model_checkpoint recorded in %s (train with load_best_model_at_end or pass --latest)", job.Dir)))
+			}
+			fmt.Printf("Best checkpoint: %s (%s)\n", c.Name, artifacts.FormatBytes(c.SizeKB*1024))
+			filters = artifacts.KeepOnlyFilters(c.Name)
+		default:
+			fmt.Printf("Downloading %s (%s)\n", job.Dir, artifacts.FormatBytes(listing.SizeKB*1024))
+		}
+
+		cfg := cfgManager.Get()
+		client, err := ssh.NewClient(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		source := fmt.Sprintf("%s@%s:%s", cfg.User, cfg.Host, ensureTrailingSlash(job.Dir))
+		// --partial keeps interrupted files so the next run continues from them
+		if err := client.Rsync(source, ensureTrailingSlash(local), false, "", append([]string{"--partial"}, filters...)...); err != nil {
+			exitWithError(fmt.Errorf("download failed (run the same command again to resume): %w", err))
+		}
+		fmt.Printf("Saved to %s\n", strings.TrimSuffix(local, "/"))
+	},
+}
+
+// loadArtifacts resolves job and lists its output directory on the DGX
+func loadArtifacts(name string) (artifacts.Job, artifacts.Listing) {
+	job, err := artifacts.Resolve(name)
+	if err != nil {
+		exitWithError(exitcode.Wrap(exitcode.Usage, err))
+	}
+	client, err := ssh.NewClient(cfgManager.Get())
+	if err != nil {
+		exitWithError(err)
+	}
+	defer client.Close()
+
+	output, err := client.Execute(artifacts.ListCommand(job.Dir))
+	if err != nil {
+		exitWithError(err)
+	}
+	return job, artifacts.ParseListing(output)
+}
+
+func init() {
+	artifactsPullCmd.Flags().Bool("latest", false, "Download only the newest checkpoint")
+	artifactsPullCmd.Flags().Bool("best", false, "Download only the checkpoint recorded as best")
+	artifactsCmd.AddCommand(artifactsJobsCmd)
+	artifactsCmd.AddCommand(artifactsListCmd)
+	artifactsCmd.AddCommand(artifactsPullCmd)
+	rootCmd.AddCommand(artifactsCmd)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/artifacts"
+	"github.com/weatherman/dgx-manager/internal/buildctx"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/transfer"
+	"github.com/weatherman/dgx-manager/internal/ui"
+)
+
+// largeContext is the context size past which dgx build suggests a .dockerignore
+const largeContext = 1 << 30
+
+// build command
+var buildCmd = &cobra.Command{
+	Use:   "build [context-dir]",
+	Short: "Build a docker image on the DGX from a local directory",
+	Long: `Build a docker image on the DGX from a local build context, so aarch64 CUDA
+images are built natively instead of under emulation on an x86 laptop.
+
+The context (default .) is collected as docker does, leaving out what
+.dockerignore excludes (or <Dockerfile>.dockerignore next to the Dockerfile),
+streamed to the DGX as a gzipped tar, and built there with docker build, whose
+output streams back. Nothing is left on the DGX but the image; layer caching is
+the DGX's, so repeated builds are fast. --no-cache builds without it.
+
+--build-arg NAME without a value takes the value from the local environment.
+--push pushes each tag from the DGX after the build; log in to the registry
+there first with 'dgx registry login'.
+
+Examples:
+  dgx build -t trainer:dev .
+  dgx build -t trainer:dev -f docker/Dockerfile.train --build-arg BASE=25.09 .
+  dgx build -t ghcr.io/acme/trainer:1.2 --push .
+  dgx build --list .`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		dockerfile, _ := cmd.Flags().GetString("file")
+		tags, _ := cmd.Flags().GetStringArray("tag")
+		buildArgs, _ := cmd.Flags().GetStringArray("build-arg")
+		target, _ := cmd.Flags().GetString("target")
+		platform, _ := cmd.Flags().GetString("platform")
+		progress, _ := cmd.Flags().GetString("progress")
+		noCache, _ := cmd.Flags().GetBool("no-cache")
+		pull, _ := cmd.Flags().GetBool("pull")
+		push, _ := cmd.Flags().GetBool("push")
+		list, _ := cmd.Flags().GetBool("list")
+		bwlimit, _ := cmd.Flags().GetString("bwlimit")
+
+		if push && len(tags) == 0 {
+			exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("--push needs a --tag")))
+		}
+		if progress != "" && !slices.Contains([]string{"auto", "plain", "tty", "quiet"}, progress) {
+			exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("invalid --progress %q (use auto, plain, tty, or quiet)", progress)))
+		}
+		for i, a := range buildArgs {
+			if !strings.Contains(a, "=") {
+				value, ok := os.LookupEnv(a)
+				if !ok {
+					exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("--build-arg %s has no value and $%s is not set", a, a)))
+				}
+				buildArgs[i] = a + "=" + value
+			}
+		}
+
+		buildContext, err := buildctx.Collect(dir, dockerfile)
+		if err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Usage, err))
+		}
+		if list {
+			for _, p := range buildContext.Paths() {
+				fmt.Println(p)
+			}
+			return
+		}
+		if buildContext.Size > largeContext {
+			fmt.Fprintf(os.Stderr, "Warning: the build context is %s; list large files the image doesn't need in .dockerignore\n", artifacts.FormatBytes(buildContext.Size))
+		}
+
+		client, err := ssh.NewClient(cfgManager.Get())
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+
+		fmt.Fprintf(os.Stderr, "Sending build context to the DGX (%d files, %s)\n", buildContext.Files, artifacts.FormatBytes(buildContext.Size))
+		pr, pw := io.Pipe()
+		sent := make(chan int64, 1)
+		go func() {
+			n, err := buildContext.WriteTo(pw)
+			pw.CloseWithError(err)
+			sent <- n
+		}()
+		var in io.Reader = pr
+		if bwlimit != "" {
+			rate, err := transfer.ParseRate(bwlimit)
+			if err != nil {
+				exitWithError(exitcode.Wrap(exitcode.Usage, err))
+			}
+			in = transfer.NewReader(in, transfer.NewLimiter(rate))
+		}
+
+		if progress == "" && ui.Plain() {
+			progress = "plain"
+		}
+		options := buildctx.Options{
+			Tags:      tags,
+			BuildArgs: buildArgs,
+			Target:    target,
+			Platform:  platform,
+			Progress:  progress,
+			NoCache:   noCache,
+			Pull:      pull,
+			Push:      push,
+		}
+		script := "command -v docker >/dev/null || { echo 'docker is not installed on the DGX' >&2; exit 127; }; " +
+			buildctx.Command(buildContext.Dockerfile, options)
+		start := time.Now()
+		err = client.Stream(script, in, os.Stdout, os.Stderr)
+		pr.Close()
+		n := <-sent
+		if err != nil {
+			exitWithError(err)
+		}
+
+		built := "the image"
+		if len(tags) > 0 {
+			built = strings.Join(tags, ", ")
+		}
+		pushed := ""
+		if push {
+			pushed = " and pushed it"
+		}
+		fmt.Fprintf(os.Stderr, "Built %s on the DGX%s (context %s compressed, %s total)\n",
+			built, pushed, artifacts.FormatBytes(n), time.Since(start).Round(time.Second))
+	},
+}
+
+func init() {
+	// --tag shadows the inventory --tag here, as docker users expect; --group still fans out
+	buildCmd.Flags().StringArrayP("tag", "t", nil, "Name and optionally tag the image (name:tag); repeatable")
+	buildCmd.Flags().StringP("file", "f", "", "Dockerfile (default <context-dir>/Dockerfile)")
+	buildCmd.Flags().StringArray("build-arg", nil, "Set a build argument (NAME=value, or NAME to pass the local value); repeatable")
+	buildCmd.Flags().String("target", "", "Build this stage of a multi-stage Dockerfile")
+	buildCmd.Flags().String("platform", "", "Target platform (default the DGX's, linux/arm64)")
+	buildCmd.Flags().String("progress", "", "Build output: auto, plain, tty, or quiet")
+	buildCmd.Flags().Bool("pull", false, "Always pull newer versions of the base images")
+	buildCmd.Flags().Bool("push", false, "Push the tags from the DGX after the build")
+	buildCmd.Flags().Bool("list", false, "List the files the context would send, and exit")
+	buildCmd.Flags().String("bwlimit", "", "Limit the upload to a rate, e.g. 10M (bytes per second)")
+
+	rootCmd.AddCommand(buildCmd)
+}
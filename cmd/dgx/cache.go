@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/deploy"
+	"github.com/weatherman/dgx-manager/internal/dmr"
+	"github.com/weatherman/dgx-manager/internal/gpu"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/state"
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// modelsCacheTTL is how long the Model Runner model list is reused
+const modelsCacheTTL = 2 * time.Minute
+
+// modelsCompletionTTL is how old a cached model list may be for shell completion, which
+// prefers a stale list to none
+const modelsCompletionTTL = 24 * time.Hour
+
+// containersCacheTTL is how long the running container list is reused
+const containersCacheTTL = 30 * time.Second
+
+// probeCache returns the cache for slow read-only probes, bypassed by --no-cache. It is
+// nil (never caching) when the state directory is unavailable.
+func probeCache(cmd *cobra.Command) *state.Cache {
+	store, err := state.DefaultStore()
+	if err != nil {
+		return nil
+	}
+	cache := state.NewCache(store)
+	if noCache, _ := cmd.Flags().GetBool("no-cache"); noCache {
+		cache.SetBypass(true)
+	}
+	return cache
+}
+
+func gpuCacheKey(cfg *types.Config) string         { return state.Key("gpu", cfg.Host) }
+func modelsCacheKey(cfg *types.Config) string      { return state.Key("models", cfg.Host) }
+func containersCacheKey(cfg *types.Config) string  { return state.Key("containers", cfg.Host) }
+func deploymentsCacheKey(cfg *types.Config) string { return state.Key("deployments", cfg.Host) }
+
+// cachedGPUs returns the GPU inventory and the age of the cached copy used, if any
+func cachedGPUs(cache *state.Cache, cfg *types.Config, client *ssh.Client) ([]gpu.Device, time.Duration, error) {
+	var devices []gpu.Device
+	age, err := cache.Fetch(gpuCacheKey(cfg), gpu.InventoryCacheTTL, &devices, func() error {
+		var err error
+		devices, err = gpu.NewMonitor(client).Inventory()
+		return err
+	})
+	return devices, age, err
+}
+
+// cachedModels returns the models in the Docker Model Runner store and the age of the
+// cached copy used, if any
+func cachedModels(cache *state.Cache, cfg *types.Config, client *ssh.Client) ([]string, time.Duration, error) {
+	var names []string
+	age, err := cache.Fetch(modelsCacheKey(cfg), modelsCacheTTL, &names, func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		models, err := dmr.NewClient(client.Dial, "tcp", dmr.DefaultAddr).Models(ctx)
+		if err != nil {
+			return err
+		}
+		names = names[:0]
+		for _, m := range models {
+			names = append(names, m.Tags...)
+		}
+		return nil
+	})
+	return names, age, err
+}
+
+// cachedContainers returns the names of the running docker containers
+func cachedContainers(cache *state.Cache, cfg *types.Config, client *ssh.Client) ([]string, error) {
+	var names []string
+	_, err := cache.Fetch(containersCacheKey(cfg), containersCacheTTL, &names, func() error {
+		output, err := client.Execute("docker ps --format '{{.Names}}'")
+		if err != nil {
+			return fmt.Errorf("failed to list containers: %w", err)
+		}
+		names = strings.Fields(output)
+		return nil
+	})
+	return names, err
+}
+
+// cachedDeployments returns the autostart deployments on the DGX. 'dgx deploy autostart
+// list' always reads them fresh; the copy it keeps feeds the command palette.
+func cachedDeployments(cache *state.Cache, cfg *types.Config, client *ssh.Client) ([]deploy.Autostart, error) {
+	var entries []deploy.Autostart
+	_, err := cache.Fetch(deploymentsCacheKey(cfg), containersCacheTTL, &entries, func() error {
+		var err error
+		entries, err = deploy.NewManager(client).List()
+		return err
+	})
+	return entries, err
+}
+
+// cacheNote marks output that came from the cache
+func cacheNote(age time.Duration) string {
+	if age == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (cached %v ago)", age.Round(time.Second))
+}
+
+// completeModels completes model references from the cached Model Runner model list. It
+// never contacts the DGX, since completion must not stall on the network or stop at a
+// password or host key prompt; 'dgx status' refreshes the list. 'dgx __names' prints the
+// same names for shell widgets and plugins.
+func completeModels(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return completionNames(cmd, toComplete, "models"), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeContainers completes the first argument with container names from the list
+// cached by 'dgx shell'; like completeModels it never contacts the DGX
+var completeContainers = completeFirst("containers")
+
+// completeDeployments completes the first argument with the autostart deployments cached
+// by 'dgx deploy autostart list'
+var completeDeployments = completeFirst("deployments")
+
+func filterPrefix(values []string, prefix string) []string {
+	var matches []string
+	for _, v := range values {
+		if strings.HasPrefix(v, prefix) {
+			matches = append(matches, v)
+		}
+	}
+	return matches
+}
+
+func init() {
+	rootCmd.PersistentFlags().Bool("no-cache", false, "Re-run slow remote probes instead of using cached results")
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/weatherman/dgx-manager/internal/chat"
+	"github.com/weatherman/dgx-manager/internal/config"
+	"github.com/weatherman/dgx-manager/internal/dmr"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/fleet"
+	"github.com/weatherman/dgx-manager/internal/health"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// canaryMinRatio is the share of its pre-update generation rate the canary must keep
+const canaryMinRatio = 0.7
+
+// canaryBenchmarkTimeout bounds the benchmark, including loading the model
+const canaryBenchmarkTimeout = 5 * time.Minute
+
+// canaryPrompt asks for a reply long enough to time the generation rate
+const canaryPrompt = "Count from 1 to 60, separated by spaces. Reply with the numbers only."
+
+// runWithCanary runs the canary's job on its own, then checks the host's health and, with a
+// model, its generation rate. The other jobs run only when everything passed. It returns
+// the exit code.
+func runWithCanary(cmd *cobra.Command, canary string, targets []fleetTarget, jobs []fleet.Job, rows []fleet.ReportRow, export string) int {
+	index := -1
+	for i, t := range targets {
+		if t.Name == canary {
+			index = i
+		}
+	}
+	if index < 0 {
+		exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("--canary %s is not one of the targets", canary)))
+	}
+	cfg := targets[index].Config
+	timeout, _ := cmd.Flags().GetDuration("canary-timeout")
+	model, _ := cmd.Flags().GetString("canary-model")
+	if model == "" {
+		if preset, err := config.ResolvePreset(cfgManager.Get(), ""); err == nil {
+			model = preset.Model
+		}
+	}
+
+	var baseline float64
+	if model != "" {
+		fmt.Printf("Benchmarking %s on %s before the update...\n", model, canary)
+		if rate, err := benchmarkModel(cfg, model); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: no baseline, so any working rate passes: %v\n", err)
+		} else {
+			baseline = rate
+			fmt.Printf("Baseline: %.1f tokens/s\n", rate)
+		}
+	}
+
+	fmt.Printf("Canary: running on %s first\n", canary)
+	first := runFleet(cmd, jobs[index:index+1])
+	code := reportFleet(first)
+	if code == exitcode.OK && !checkCanary(cfg, model, baseline, timeout) {
+		code = 1
+	}
+
+	restJobs := append(append([]fleet.Job{}, jobs[:index]...), jobs[index+1:]...)
+	if code != exitcode.OK {
+		fmt.Fprintf(os.Stderr, "Error: canary %s failed; the other %d hosts were left alone\n", canary, len(restJobs))
+		if export != "" {
+			exportFleet(export, rows[index:index+1], first)
+		}
+		return code
+	}
+
+	fmt.Printf("\nCanary %s passed; continuing with %d more hosts\n", canary, len(restJobs))
+	results := runFleet(cmd, restJobs)
+	code = reportFleet(results)
+	if export != "" {
+		// The jobs filled rows in target order; the results start with the canary
+		ordered := append([]fleet.ReportRow{rows[index]}, rows[:index]...)
+		ordered = append(ordered, rows[index+1:]...)
+		exportFleet(export, ordered, append(first, results...))
+	}
+	return code
+}
+
+// checkCanary waits for the canary to be up (the job may have rebooted it), then runs the
+// post-update health probes and the benchmark. It reports whether all of them passed.
+func checkCanary(cfg *types.Config, model string, baseline float64, timeout time.Duration) bool {
+	fmt.Printf("\nWaiting for %s to be up...\n", cfg.Host)
+	var client *ssh.Client
+	up := pollUntil(cfg.Host, "ssh", time.Now().Add(timeout), 5*time.Second, func() bool {
+		c, err := ssh.NewClient(cfg)
+		if err != nil {
+			return false
+		}
+		// "stopping" right after a reboot was started, "starting" while booting
+		output, err := c.Execute("systemctl is-system-running || true")
+		if state := strings.TrimSpace(output); err != nil || (state != "running" && state != "degraded") {
+			c.Close()
+			return false
+		}
+		client = c
+		return true
+	})
+	if !up {
+		fmt.Fprintf(os.Stderr, "Error: %s did not come up within %v\n", cfg.Host, timeout)
+		return false
+	}
+	defer client.Close()
+
+	fmt.Println("Post-update health:")
+	checks := health.Run(client, health.PostBootProbes)
+	fmt.Print(health.FormatChecks(checks))
+	if !health.AllOK(checks) {
+		return false
+	}
+
+	if model == "" {
+		fmt.Println("Benchmark skipped: no model (pass --canary-model or set a default preset)")
+		return true
+	}
+	rate, err := benchmarkModel(cfg, model)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: benchmark failed: %v\n", err)
+		return false
+	}
+	fmt.Printf("Benchmark: %.1f tokens/s with %s\n", rate, model)
+	if baseline > 0 && rate < baseline*canaryMinRatio {
+		fmt.Fprintf(os.Stderr, "Error: %.1f tokens/s is more than %.0f%% below the baseline of %.1f\n", rate, 100*(1-canaryMinRatio), baseline)
+		return false
+	}
+	return true
+}
+
+// benchmarkModel streams a short completion from model in the DGX's Docker Model Runner and
+// returns the generation rate after the first token. Each streamed chunk counts as one
+// token, which holds for llama.cpp.
+func benchmarkModel(cfg *types.Config, model string) (float64, error) {
+	client, err := ssh.NewClient(cfg)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+
+	httpClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return client.Dial("tcp", dmr.DefaultAddr)
+		},
+	}}
+	c := chat.NewClient(httpClient, "http://dmr/engines", "")
+	zero := 0.0
+	c.SetTemperature(&zero)
+
+	ctx, cancel := context.WithTimeout(context.Background(), canaryBenchmarkTimeout)
+	defer cancel()
+	start := time.Now()
+	var first time.Duration
+	chunks := 0
+	_, err = c.Complete(ctx, model, []chat.Message{{Role: "user", Content: canaryPrompt}}, func(string) {
+		if chunks == 0 {
+			first = time.Since(start)
+		}
+		chunks++
+	})
+	if err != nil {
+		return 0, err
+	}
+	generating := time.Since(start) - first
+	if chunks < 10 || generating <= 0 {
+		return 0, fmt.Errorf("the reply was too short to time (%d tokens)", chunks)
+	}
+	return float64(chunks-1) / generating.Seconds(), nil
+}
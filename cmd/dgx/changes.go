@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/weatherman/dgx-manager/internal/changes"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/state"
+	"github.com/weatherman/dgx-manager/internal/ui"
+)
+
+// changes command
+var changesCmd = &cobra.Command{
+	Use:   "changes [N]",
+	Short: "Show what update playbooks changed on the DGX",
+	Long: `Update playbooks (dmr update, nvidia update, webui update) read the DGX's
+package and component versions before and after they run and record the
+difference locally. Without arguments, list the recorded runs for the DGX,
+newest last; pass a run's number or --last to see every version it changed.
+
+Components are the kernel, the loaded NVIDIA driver, Docker, and the image of
+each running container. The loaded driver only changes at the reboot after a
+driver update; the package versions show the update itself. The last 50
+runs per host are kept.
+
+Examples:
+  dgx changes
+  dgx changes --last
+  dgx changes 3 --json`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		last, _ := cmd.Flags().GetBool("last")
+		asJSON, _ := cmd.Flags().GetBool("json")
+		if last && len(args) > 0 {
+			exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("--last cannot be combined with a run number")))
+		}
+
+		host := cfgManager.Get().Host
+		store, err := state.DefaultStore()
+		if err != nil {
+			exitWithError(err)
+		}
+		records, err := changes.Load(store, host)
+		if err != nil {
+			exitWithError(err)
+		}
+
+		if !last && len(args) == 0 {
+			if asJSON {
+				if records == nil {
+					records = []changes.Record{}
+				}
+				printJSON(records)
+				return
+			}
+			if len(records) == 0 {
+				fmt.Printf("No update recorded for %s\n", host)
+				return
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "#\tTIME\tPLAYBOOK\tSTATUS\tCHANGES")
+			for i, r := range records {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\n", i+1, r.Started.Local().Format("Jan 02 15:04"), r.Playbook, recordStatus(r), len(r.Changes))
+			}
+			w.Flush()
+			return
+		}
+
+		n := len(records)
+		if len(args) == 1 {
+			if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+				exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("invalid run number %q", args[0])))
+			}
+		}
+		if n == 0 || n > len(records) {
+			exitWithError(fmt.Errorf("no recorded update #%d for %s (see 'dgx changes')", n, host))
+		}
+		r := records[n-1]
+		if asJSON {
+			printJSON(r)
+			return
+		}
+
+		fmt.Printf("%s on %s, %s (%s), %s\n", r.Playbook, r.Host, r.Started.Local().Format("Jan 02 15:04"),
+			r.Finished.Sub(r.Started).Round(time.Second), recordStatus(r))
+		if r.Error != "" {
+			fmt.Printf("Error: %s\n", r.Error)
+		}
+		if len(r.Changes) == 0 {
+			fmt.Println("No versions changed")
+			return
+		}
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KIND\tNAME\tBEFORE\tAFTER")
+		for _, c := range r.Changes {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.Kind, c.Name, ui.OrDash(c.Before), ui.OrDash(c.After))
+		}
+		w.Flush()
+	},
+}
+
+// recordStatus is "ok" or "failed", as in dgx history
+func recordStatus(r changes.Record) string {
+	if r.Error != "" {
+		return "failed"
+	}
+	return "ok"
+}
+
+// printJSON writes v as indented JSON to stdout
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		exitWithError(err)
+	}
+}
+
+func init() {
+	changesCmd.Flags().Bool("last", false, "Show the versions the most recent update changed")
+	changesCmd.Flags().Bool("json", false, "Print the records as JSON")
+	rootCmd.AddCommand(changesCmd)
+}
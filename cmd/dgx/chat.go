@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/chat"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/serve"
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// chat command
+var chatCmd = &cobra.Command{
+	Use:   "chat [model] [prompt]",
+	Short: "Chat with a model on the DGX, streaming tokens as they are generated",
+	Long: `Open an interactive chat with a model served on the DGX. Replies are streamed
+token by token over SSH using the same serve.routes table as 'dgx serve'
+(Docker Model Runner when no routes are configured).
+
+Without a model, the preset's model is used (see 'dgx preset'); with none
+configured, pick one from a fuzzy-searchable list of recently used models,
+the Model Runner store, and serve routes. Pass a prompt to get a single reply
+and exit. In interactive mode, Ctrl+C
+stops the current reply; type /reset to clear history or /exit to quit.
+
+For vision models, --image attaches local PNG, JPEG, GIF, or WebP files (up to
+20 MiB each) to the prompt, or to the first message in interactive mode. There,
+/image <path> attaches an image to the next message.
+
+Examples:
+  dgx chat ai/smollm2:360M-Q4_K_M
+  dgx chat ai/smollm2:360M-Q4_K_M "Explain quantum computing"
+  dgx chat ai/gemma3 "What is in this picture?" --image photo.jpg
+  dgx chat meta-llama/Llama-3.1-8B-Instruct --url http://127.0.0.1:8080
+  dgx chat --preset code`,
+	Args: cobra.RangeArgs(0, 2),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return completeModels(cmd, args, toComplete)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		preset := chatPreset(cmd)
+		if len(args) == 0 && preset.Model != "" {
+			args = []string{preset.Model}
+		}
+		if len(args) == 0 {
+			model, err := pickModel(cmd, nil)
+			if err != nil {
+				exitWithError(exitcode.Wrap(exitcode.Usage, errors.New("a model is required: dgx chat <model> [prompt]")))
+			}
+			args = []string{model}
+		}
+		model := args[0]
+		system := preset.System
+		url, _ := cmd.Flags().GetString("url")
+		apiKey, _ := cmd.Flags().GetString("api-key")
+		if apiKey == "" {
+			apiKey = os.Getenv("DGX_API_KEY")
+		}
+		imagePaths, _ := cmd.Flags().GetStringArray("image")
+		// Images are checked before connecting, so a bad path fails fast
+		pending, err := chat.LoadImages(imagePaths)
+		if err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Usage, err))
+		}
+
+		clients, cleanup, err := chatClients(cfgManager.Get(), model, serve.TypeChat, url, apiKey)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer cleanup()
+		for _, c := range clients {
+			c.SetTemperature(preset.Temperature)
+		}
+		rememberModel(model)
+
+		var history []chat.Message
+		if system != "" {
+			history = append(history, chat.Message{Role: "system", Content: system})
+		}
+
+		if len(args) == 2 {
+			history = append(history, chat.Message{Role: "user", Content: args[1], Images: pending})
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+			if _, err := streamReply(ctx, clients, model, history); err != nil {
+				exitWithError(err)
+			}
+			return
+		}
+
+		fmt.Printf("Chatting with %s (/reset to clear history, /exit to quit)\n", model)
+		scanner := bufio.NewScanner(os.Stdin)
+		scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+		for {
+			fmt.Print("\n> ")
+			if !scanner.Scan() {
+				fmt.Println()
+				return
+			}
+			line := strings.TrimSpace(scanner.Text())
+			if path, ok := strings.CutPrefix(line, "/image "); ok {
+				image, err := chat.LoadImage(strings.TrimSpace(path))
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					continue
+				}
+				pending = append(pending, image)
+				fmt.Printf("Image attached to the next message (%d pending)\n", len(pending))
+				continue
+			}
+			switch line {
+			case "":
+				continue
+			case "/exit", "/quit":
+				return
+			case "/reset":
+				history = history[:0]
+				pending = nil
+				if system != "" {
+					history = append(history, chat.Message{Role: "system", Content: system})
+				}
+				fmt.Println("History cleared")
+				continue
+			}
+
+			history = append(history, chat.Message{Role: "user", Content: line, Images: pending})
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			reply, err := streamReply(ctx, clients, model, history)
+			interrupted := ctx.Err() != nil
+			stop()
+
+			switch {
+			case interrupted:
+				fmt.Println("\n[interrupted]")
+				history = history[:len(history)-1]
+			case err != nil:
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				history = history[:len(history)-1]
+			default:
+				history = append(history, chat.Message{Role: "assistant", Content: reply})
+				pending = nil
+			}
+		}
+	},
+}
+
+// chatClients returns clients for the model's backends for requests of type kind, in
+// failover order. With an explicit URL (such as a running 'dgx serve') the request goes
+// there directly.
+func chatClients(cfg *types.Config, model, kind, url, apiKey string) ([]*chat.Client, func(), error) {
+	if url != "" {
+		return []*chat.Client{chat.NewClient(http.DefaultClient, url, apiKey)}, func() {}, nil
+	}
+
+	var routes []types.Route
+	if cfg.Serve != nil {
+		routes = cfg.Serve.Routes
+	}
+	backends := serve.NewRouter(routes).CandidatesFor(model, kind)
+	if len(backends) == 0 {
+		return nil, nil, fmt.Errorf("no serve route matches model %q", model)
+	}
+
+	dialers, closeAll, err := backendDialers(cfg, backends)
+	if err != nil {
+		return nil, nil, err
+	}
+	httpClient := &http.Client{Transport: serve.NewTransport(dialers)}
+
+	clients := make([]*chat.Client, 0, len(backends))
+	for _, b := range backends {
+		clients = append(clients, chat.NewClient(httpClient, serve.BackendURL(b, ""), ""))
+	}
+	return clients, closeAll, nil
+}
+
+// streamReply prints the reply as it streams in. A backend is skipped only if it fails before
+// producing any output, so a partially printed reply is never repeated.
+func streamReply(ctx context.Context, clients []*chat.Client, model string, history []chat.Message) (string, error) {
+	var lastErr error
+	for _, client := range clients {
+		started := false
+		reply, err := client.Complete(ctx, model, history, func(delta string) {
+			started = true
+			fmt.Print(delta)
+		})
+		if started {
+			fmt.Println()
+		}
+		if err == nil || started || errors.Is(err, context.Canceled) {
+			return reply, err
+		}
+		lastErr = err
+	}
+	return "", lastErr
+}
+
+func init() {
+	chatCmd.Flags().String("system", "", "System prompt (default from the preset)")
+	chatCmd.Flags().Float64("temperature", 0, "Sampling temperature (default from the preset, else the server's)")
+	chatCmd.Flags().String("preset", "", "Preset to take the model, system prompt, and temperature from (default: the active one)")
+	chatCmd.Flags().String("url", "", "OpenAI-compatible base URL to use instead of SSH (e.g. a running dgx serve)")
+	chatCmd.Flags().String("api-key", "", "Bearer token for --url (default $DGX_API_KEY)")
+	chatCmd.Flags().StringArray("image", nil, "Attach an image file for vision models (repeatable)")
+	rootCmd.AddCommand(chatCmd)
+}
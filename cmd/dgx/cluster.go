@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/cluster"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/gpu"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// cluster command
+var clusterCmd = &cobra.Command{
+	Use:   "cluster",
+	Short: "Inspect and benchmark dual-Spark setups",
+}
+
+var clusterLinkCmd = &cobra.Command{
+	Use:   "link",
+	Short: "ConnectX interconnect helpers",
+}
+
+var clusterLinkStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show ConnectX link state, speed, and firmware on each node",
+	Long: `Inspect the ConnectX (mlx5) interfaces on the configured DGX and, with --peer,
+on the second Spark. Links that are down or running below 200Gb/s are flagged.
+With --perftest, run ib_write_bw between the nodes to measure RDMA bandwidth.
+
+The peer is reached with the same user, port, and SSH key as the configured DGX
+unless given as user@host.
+
+Examples:
+  dgx cluster link status
+  dgx cluster link status --peer spark-2.local --perftest`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := cfgManager.Get()
+		peerSpec, _ := cmd.Flags().GetString("peer")
+		perftest, _ := cmd.Flags().GetBool("perftest")
+
+		primary, err := ssh.NewClient(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer primary.Close()
+
+		primaryInspector := cluster.NewInspector(primary)
+		primaryLinks, err := primaryInspector.Links()
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Print(cluster.FormatLinks(cfg.Host, primaryLinks))
+
+		if peerSpec == "" {
+			if perftest {
+				fmt.Fprintln(os.Stderr, "Error: --perftest requires --peer")
+				exit(exitcode.Usage)
+			}
+			return
+		}
+
+		peerCfg := peerConfig(cfg, peerSpec)
+		peer, err := ssh.NewClient(peerCfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer peer.Close()
+
+		peerInspector := cluster.NewInspector(peer)
+		peerLinks, err := peerInspector.Links()
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Println()
+		fmt.Print(cluster.FormatLinks(peerCfg.Host, peerLinks))
+
+		if !perftest {
+			return
+		}
+
+		local, remote := firstUpLink(primaryLinks), firstUpLink(peerLinks)
+		if local == nil || remote == nil || remote.RDMADevice == "" || remote.IPv4Address == "" {
+			fmt.Fprintln(os.Stderr, "Error: need an up ConnectX link with an RDMA device and IPv4 address on both nodes")
+			exit(1)
+		}
+		peerAddr := strings.SplitN(remote.IPv4Address, "/", 2)[0]
+
+		fmt.Printf("\nRunning ib_write_bw %s (%s) -> %s (%s)...\n", cfg.Host, local.RDMADevice, peerCfg.Host, peerAddr)
+		bw, err := primaryInspector.RDMAWriteBandwidth(peerInspector, local.RDMADevice, remote.RDMADevice, peerAddr)
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("RDMA write bandwidth: %.1f Gb/s\n", bw)
+	},
+}
+
+var clusterBenchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Interconnect benchmarks",
+}
+
+var clusterBenchNCCLCmd = &cobra.Command{
+	Use:   "nccl",
+	Short: "Run nccl-tests all_reduce_perf across one or two nodes",
+	Long: `Build and run NVIDIA nccl-tests (all_reduce_perf) inside containers on the
+configured DGX and, with --peer, across both Sparks using MPI over the ConnectX
+link. Bus bandwidth is compared against the expected GB10/ConnectX-7 numbers.
+
+The peer must accept SSH from the first node with the keys in its ~/.ssh.
+
+Examples:
+  dgx cluster bench nccl
+  dgx cluster bench nccl --peer 192.168.100.11 --iface enp1s0f0np0
+  dgx cluster bench nccl --gpu-status   # live GPU footer on the first node`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := cfgManager.Get()
+		peerSpec, _ := cmd.Flags().GetString("peer")
+		image, _ := cmd.Flags().GetString("image")
+		maxBytes, _ := cmd.Flags().GetString("max-bytes")
+		iface, _ := cmd.Flags().GetString("iface")
+		verbose, _ := cmd.Flags().GetBool("verbose")
+		gpuStatus, _ := cmd.Flags().GetBool("gpu-status")
+
+		primary, err := ssh.NewClient(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer primary.Close()
+
+		nodes := []*ssh.Client{primary}
+		hosts := []string{"localhost"}
+		if peerSpec != "" {
+			peerCfg := peerConfig(cfg, peerSpec)
+			peer, err := ssh.NewClient(peerCfg)
+			if err != nil {
+				exitWithError(err)
+			}
+			defer peer.Close()
+			nodes = append(nodes, peer)
+			hosts = append(hosts, peerCfg.Host)
+
+			if iface == "" {
+				links, err := cluster.NewInspector(primary).Links()
+				if err == nil {
+					if up := firstUpLink(links); up != nil {
+						iface = up.Interface
+					}
+				}
+			}
+		}
+
+		bench := cluster.NewNCCLBench(nodes, hosts)
+		bench.Image = image
+		bench.MaxBytes = maxBytes
+		bench.Interface = iface
+
+		var status *gpu.StatusLine
+		if gpuStatus {
+			status = startGPUStatus(cfg)
+		}
+		result, output, err := bench.Run()
+		status.Stop()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			if output != "" {
+				fmt.Fprintln(os.Stderr, strings.TrimSpace(output))
+			}
+			exit(exitcode.Of(err))
+		}
+		if verbose {
+			fmt.Println(output)
+		}
+
+		fmt.Println()
+		fmt.Printf("%-14s %-12s %-12s %s\n", "SIZE", "ALGBW GB/s", "BUSBW GB/s", "WRONG")
+		for _, row := range result.Rows {
+			fmt.Printf("%-14d %-12.2f %-12.2f %d\n", row.SizeBytes, row.AlgBW, row.BusBW, row.Wrong)
+		}
+		fmt.Println()
+		fmt.Printf("Nodes:          %d\n", result.Nodes)
+		fmt.Printf("Peak bus BW:    %.2f GB/s\n", result.PeakBusBW)
+		fmt.Printf("Avg bus BW:     %.2f GB/s\n", result.AvgBusBW)
+		if result.Nodes > 1 {
+			fmt.Printf("Expected:       >= %.1f GB/s (200Gb/s ConnectX-7)\n", cluster.ExpectedBusBandwidthGBps)
+		}
+
+		verdict := result.Verdict()
+		fmt.Printf("Verdict:        %s\n", strings.ToUpper(verdict))
+		if verdict == "fail" {
+			exit(1)
+		}
+	},
+}
+
+// peerConfig derives the connection config for a second Spark from the primary config.
+// The spec may be "host" or "user@host".
+func peerConfig(base *types.Config, spec string) *types.Config {
+	peer := *base
+	peer.Tunnels = nil
+	peer.Serve = nil
+	if user, host, ok := strings.Cut(spec, "@"); ok {
+		peer.User = user
+		spec = host
+	}
+	peer.Host = spec
+	return &peer
+}
+
+func firstUpLink(links []cluster.Link) *cluster.Link {
+	for i := range links {
+		if links[i].State == "up" {
+			return &links[i]
+		}
+	}
+	return nil
+}
+
+func init() {
+	clusterLinkStatusCmd.Flags().String("peer", "", "Second Spark to inspect (host or user@host)")
+	clusterLinkStatusCmd.Flags().Bool("perftest", false, "Measure RDMA bandwidth with ib_write_bw (requires --peer)")
+	clusterLinkCmd.AddCommand(clusterLinkStatusCmd)
+	clusterCmd.AddCommand(clusterLinkCmd)
+
+	clusterBenchNCCLCmd.Flags().String("peer", "", "Second Spark to include (host or user@host, as reachable from the first node)")
+	clusterBenchNCCLCmd.Flags().String("image", cluster.DefaultNCCLImage, "Container image used to build and run nccl-tests")
+	clusterBenchNCCLCmd.Flags().String("max-bytes", "8G", "Largest message size passed to all_reduce_perf -e")
+	clusterBenchNCCLCmd.Flags().String("iface", "", "Network interface for NCCL traffic (default: first up ConnectX link)")
+	clusterBenchNCCLCmd.Flags().BoolP("verbose", "v", false, "Print raw all_reduce_perf output")
+	clusterBenchNCCLCmd.Flags().Bool("gpu-status", false, "Show a live GPU utilization footer while the benchmark runs")
+	clusterBenchCmd.AddCommand(clusterBenchNCCLCmd)
+	clusterCmd.AddCommand(clusterBenchCmd)
+	rootCmd.AddCommand(clusterCmd)
+}
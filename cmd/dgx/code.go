@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/config"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+// vscodeMinDiskKB is the free space the VS Code server and its extensions need in ~
+const vscodeMinDiskKB = 1 << 20
+
+// vscodePrereqScript prints key=value facts about what the VS Code server needs
+const vscodePrereqScript = `echo "arch=$(uname -m)"
+echo "glibc=$(getconf GNU_LIBC_VERSION 2>/dev/null | awk '{print $2}')"
+echo "home=$HOME"
+for t in tar curl wget; do command -v $t >/dev/null 2>&1 && echo "$t=yes" || echo "$t=no"; done
+echo "disk_kb=$(df -Pk "$HOME" 2>/dev/null | awk 'NR==2 {print $4}')"
+echo "forwarding=$(grep -hiE '^[[:space:]]*AllowTcpForwarding' /etc/ssh/sshd_config /etc/ssh/sshd_config.d/*.conf 2>/dev/null | awk '{print tolower($2)}' | head -n1)"`
+
+// code command
+var codeCmd = &cobra.Command{
+	Use:   "code [remote-path]",
+	Short: "Open a folder on the DGX in VS Code (Remote-SSH)",
+	Long: `Check that the DGX can run the VS Code server, write a Host entry for the active
+profile to ~/.ssh/config, and launch VS Code with the Remote-SSH extension.
+
+The entry is named dgx-<profile> (dgx-spark for the top-level connection) unless
+--alias is given, and is rewritten in place on later runs so it follows profile
+changes. Missing tar or curl are installed on the DGX after confirmation.
+
+The remote path defaults to your home directory; relative paths are taken from it.
+
+Examples:
+  dgx code
+  dgx code projects/llm-finetune
+  dgx --profile lab code /workspace --alias lab-spark`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		alias, _ := cmd.Flags().GetString("alias")
+		binary, _ := cmd.Flags().GetString("code-bin")
+		skipChecks, _ := cmd.Flags().GetBool("skip-checks")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		cfg := cfgManager.Get()
+		// The editor's terminal and tasks are a full shell on the DGX
+		requireShell(cfg, "dgx code")
+		if alias == "" {
+			alias = "dgx-spark"
+			if cfg.ActiveProfile != "" {
+				alias = "dgx-" + cfg.ActiveProfile
+			}
+		}
+
+		codePath, err := findVSCode(binary)
+		if err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Config, err))
+		}
+
+		client, err := ssh.NewClient(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+
+		output, err := client.Execute(vscodePrereqScript)
+		if err != nil {
+			exitWithError(err)
+		}
+		facts := parseKeyValues(output)
+
+		if !skipChecks {
+			missing, problems := vscodePrereqProblems(facts)
+			for _, p := range problems {
+				fmt.Fprintf(os.Stderr, "Warning: %s\n", p)
+			}
+			if len(missing) > 0 {
+				install := "sudo apt-get install -y " + strings.Join(missing, " ")
+				fmt.Printf("The VS Code server needs %s on the DGX.\n", strings.Join(missing, " and "))
+				if !yes && !confirmAction(fmt.Sprintf("Run '%s'?", install)) {
+					fmt.Println("Cancelled.")
+					exit(exitcode.Aborted)
+				}
+				if err := client.RunInteractive(install); err != nil {
+					exitWithError(err)
+				}
+			}
+		}
+
+		sshConfigPath, err := config.DefaultSSHConfigPath()
+		if err != nil {
+			exitWithError(err)
+		}
+		entry := config.SSHHostEntry{
+			Alias:    alias,
+			HostName: cfg.Host,
+			User:     cfg.User,
+			Port:     cfg.Port,
+			Options:  ssh.ConfigOptions(cfg.SSH),
+		}
+		if len(ssh.IdentityArgs(cfg)) > 0 {
+			entry.IdentityFile = cfg.IdentityFile
+			entry.CertificateFile = cfg.CertificateFile
+		}
+		changed, err := config.UpsertSSHHost(sshConfigPath, entry)
+		if err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Config, err))
+		}
+		if changed {
+			fmt.Printf("Updated Host %s in %s\n", alias, sshConfigPath)
+		}
+
+		remotePath := facts["home"]
+		if len(args) == 1 {
+			remotePath = args[0]
+			if !path.IsAbs(remotePath) {
+				remotePath = path.Join(facts["home"], strings.TrimPrefix(remotePath, "~/"))
+			}
+		}
+		if remotePath == "" {
+			remotePath = "/"
+		}
+		if _, err := client.Execute("test -d " + ssh.ShellQuote(remotePath)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s is not a directory on the DGX\n", remotePath)
+			exit(exitcode.Usage)
+		}
+
+		fmt.Printf("Opening %s:%s in VS Code...\n", alias, remotePath)
+		launch := exec.Command(codePath, "--remote", "ssh-remote+"+alias, remotePath)
+		launch.Stdout = os.Stdout
+		launch.Stderr = os.Stderr
+		if err := launch.Run(); err != nil {
+			exitWithError(fmt.Errorf("failed to launch VS Code: %w", err))
+		}
+	},
+}
+
+// findVSCode locates the code CLI, looking in the standard macOS app bundle when it is not
+// on PATH
+func findVSCode(binary string) (string, error) {
+	if p, err := exec.LookPath(binary); err == nil {
+		return p, nil
+	}
+	if runtime.GOOS == "darwin" && binary == "code" {
+		bundled := "/Applications/Visual Studio Code.app/Contents/Resources/app/bin/code"
+		if _, err := os.Stat(bundled); err == nil {
+			return bundled, nil
+		}
+	}
+	return "", fmt.Errorf("%s not found on PATH; in VS Code run 'Shell Command: Install code command in PATH' or pass --code-bin", binary)
+}
+
+// vscodePrereqProblems returns the packages to install and any problems that need manual
+// attention
+func vscodePrereqProblems(facts map[string]string) ([]string, []string) {
+	var missing, problems []string
+	if facts["tar"] != "yes" {
+		missing = append(missing, "tar")
+	}
+	if facts["curl"] != "yes" && facts["wget"] != "yes" {
+		missing = append(missing, "curl")
+	}
+
+	switch facts["arch"] {
+	case "aarch64", "arm64", "x86_64", "armv7l":
+	default:
+		problems = append(problems, fmt.Sprintf("architecture %q is not supported by the VS Code server", facts["arch"]))
+	}
+	if v := facts["glibc"]; v != "" && versionLess(v, "2.28") {
+		problems = append(problems, fmt.Sprintf("glibc %s is older than the 2.28 the VS Code server requires", v))
+	}
+	if kb, err := strconv.ParseInt(facts["disk_kb"], 10, 64); err == nil && kb < vscodeMinDiskKB {
+		problems = append(problems, fmt.Sprintf("only %d MiB free in %s; the VS Code server needs about 1 GiB", kb>>10, facts["home"]))
+	}
+	if facts["forwarding"] == "no" {
+		problems = append(problems, "sshd has AllowTcpForwarding no, which Remote-SSH needs to reach the server")
+	}
+	return missing, problems
+}
+
+// versionLess compares dotted numeric versions
+func versionLess(a, b string) bool {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			return x < y
+		}
+	}
+	return false
+}
+
+func parseKeyValues(output string) map[string]string {
+	values := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+func init() {
+	codeCmd.Flags().String("alias", "", "Host alias to write to ~/.ssh/config (default dgx-<profile>)")
+	codeCmd.Flags().String("code-bin", "code", "VS Code CLI to launch (e.g. code-insiders, cursor)")
+	codeCmd.Flags().Bool("skip-checks", false, "Skip the VS Code server prerequisite checks")
+	codeCmd.Flags().BoolP("yes", "y", false, "Install missing prerequisites without confirmation")
+	rootCmd.AddCommand(codeCmd)
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/config"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/ui"
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate all profiles and print the effective configuration",
+	Long: `Print the connection settings this invocation would use, with where each value
+came from, then check the top-level connection and every profile: the host
+resolves, the port is in range, and the identity file exists with 0600
+permissions.
+
+Precedence, highest first: flags, DGX_* environment variables, the selected
+profile, the config file, NVIDIA Sync detection.
+
+Environment variables:
+  DGX_PROFILE, DGX_HOST, DGX_PORT, DGX_USER, DGX_IDENTITY_FILE,
+  DGX_PLAYBOOK_RETRIES, DGX_CONFIG (path of the config file)
+
+Examples:
+  dgx config validate
+  dgx --profile ci config validate
+  DGX_HOST=10.0.0.5 dgx config validate`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := cfgManager.Apply(connectionOverrides(cmd)); err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Config, err))
+		}
+
+		fmt.Println("Effective configuration:")
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, s := range cfgManager.Settings() {
+			value := s.Value
+			if value == "" {
+				value = "(unset)"
+			}
+			fmt.Fprintf(w, "  %s\t%s\t(%s)\n", s.Name, value, s.Source)
+		}
+		w.Flush()
+		fmt.Printf("  config path: %s\n", cfgManager.GetConfigPath())
+
+		fmt.Println("\nProfiles:")
+		failed := 0
+		for _, check := range cfgManager.ValidateProfiles(context.Background()) {
+			if len(check.Problems) == 0 {
+				fmt.Printf("  [OK  ] %-12s %s@%s:%d\n", check.Name, check.Config.User, check.Config.Host, check.Config.Port)
+				continue
+			}
+			failed++
+			fmt.Printf("  [FAIL] %-12s %s\n", check.Name, check.Problems[0])
+			for _, problem := range check.Problems[1:] {
+				fmt.Printf("         %-12s %s\n", "", problem)
+			}
+		}
+		if failed > 0 {
+			fmt.Fprintf(os.Stderr, "\n%d profile(s) failed validation\n", failed)
+			exit(exitcode.Config)
+		}
+	},
+}
+
+var configProfileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage named DGX connection profiles",
+	Long: `Profiles are named connections stored in the config file. Select one for a
+single command with --profile, or make it the default with 'dgx config profile use'.
+Fields a profile leaves empty fall back to the top-level config.
+
+Examples:
+  dgx config profile add ci --host 10.0.0.5 --user ci --identity-file ~/.ssh/ci_ed25519
+  dgx config profile use ci
+  dgx --profile ci status`,
+}
+
+var configProfileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List configured profiles",
+	Run: func(cmd *cobra.Command, args []string) {
+		file := cfgManager.File()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ACTIVE\tNAME\tHOST\tPORT\tUSER\tIDENTITY FILE\tSOURCE")
+		active := ""
+		if file.ActiveProfile == "" {
+			active = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t%s\n", active, config.DefaultProfileName, file.Host, file.Port, file.User, file.IdentityFile, "config")
+		stale := 0
+		for _, name := range cfgManager.ProfileNames() {
+			p := file.Profiles[name]
+			active = ""
+			if name == file.ActiveProfile {
+				active = "*"
+			}
+			port := "-"
+			if p.Port > 0 {
+				port = fmt.Sprintf("%d", p.Port)
+			}
+			source := "config"
+			if p.Source != "" {
+				source = p.Source
+			}
+			if p.Stale {
+				source += " (stale)"
+				stale++
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", active, name, ui.OrDash(p.Host), port, ui.OrDash(p.User), ui.OrDash(p.IdentityFile), source)
+		}
+		w.Flush()
+		if stale > 0 {
+			fmt.Printf("\n%d profile(s) are no longer in the NVIDIA Sync config; remove them with 'dgx config profile remove <name>'\n", stale)
+		}
+	},
+}
+
+var configProfileSyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Import NVIDIA Sync hosts as profiles",
+	Long: `Import every Host block from the NVIDIA Sync ssh_config as a profile named after
+its alias. Changed blocks update their profile; blocks that disappeared are
+flagged as stale instead of being deleted.
+
+With --watch, the Sync config is checked on every dgx invocation and re-imported
+when it changes, so new Sparks show up in 'dgx config profile list' on their own.
+
+Examples:
+  dgx config profile sync
+  dgx config profile sync --watch
+  dgx config profile sync --no-watch`,
+	Run: func(cmd *cobra.Command, args []string) {
+		watch, _ := cmd.Flags().GetBool("watch")
+		noWatch, _ := cmd.Flags().GetBool("no-watch")
+		if watch && noWatch {
+			fmt.Fprintln(os.Stderr, "Error: --watch and --no-watch are mutually exclusive")
+			exit(exitcode.Usage)
+		}
+
+		changed, err := cfgManager.SyncNVSync()
+		if err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Config, err))
+		}
+		if changed {
+			fmt.Println("Profiles updated from NVIDIA Sync")
+		} else {
+			fmt.Println("Profiles already match NVIDIA Sync")
+		}
+
+		if watch || noWatch {
+			if err := cfgManager.SetNVSyncWatch(watch); err != nil {
+				exitWithError(exitcode.Wrap(exitcode.Config, err))
+			}
+			if watch {
+				fmt.Println("Watching NVIDIA Sync config for changes")
+			} else {
+				fmt.Println("Stopped watching NVIDIA Sync config")
+			}
+		}
+	},
+}
+
+var configProfileAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Add or update a profile from --host, --ssh-port, --user and --identity-file",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		if name == config.DefaultProfileName {
+			fmt.Fprintf(os.Stderr, "Error: %q is reserved for the top-level config\n", name)
+			exit(exitcode.Usage)
+		}
+		o := connectionOverrides(cmd)
+		profile := types.Profile{Host: o.Host, Port: o.Port, User: o.User, IdentityFile: o.IdentityFile}
+		if o.Host == "" && o.Port == 0 && o.User == "" && o.IdentityFile == "" {
+			fmt.Fprintln(os.Stderr, "Error: set at least one of --host, --ssh-port, --user or --identity-file")
+			exit(exitcode.Usage)
+		}
+
+		err := cfgManager.Update(func(cfg *types.Config) {
+			if cfg.Profiles == nil {
+				cfg.Profiles = make(map[string]types.Profile)
+			}
+			cfg.Profiles[name] = profile
+		})
+		if err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Config, err))
+		}
+		fmt.Printf("Profile %s saved\n", name)
+		fmt.Printf("Use it with: dgx --profile %s status\n", name)
+	},
+}
+
+var configProfileUseCmd = &cobra.Command{
+	Use:               "use <name>",
+	Short:             "Make a profile the default (use \"default\" for the top-level config)",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeFirst("profiles"),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		if name == config.DefaultProfileName {
+			name = ""
+		} else if _, ok := cfgManager.File().Profiles[name]; !ok {
+			fmt.Fprintf(os.Stderr, "Error: unknown profile %q\n", name)
+			exit(exitcode.Config)
+		}
+
+		if err := cfgManager.Update(func(cfg *types.Config) { cfg.ActiveProfile = name }); err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Config, err))
+		}
+		fmt.Printf("Active profile: %s\n", args[0])
+	},
+}
+
+var configProfileRemoveCmd = &cobra.Command{
+	Use:               "remove <name>",
+	Short:             "Remove a profile",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeFirst("profiles"),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		if _, ok := cfgManager.File().Profiles[name]; !ok {
+			fmt.Fprintf(os.Stderr, "Error: unknown profile %q\n", name)
+			exit(exitcode.Config)
+		}
+
+		err := cfgManager.Update(func(cfg *types.Config) {
+			delete(cfg.Profiles, name)
+			if cfg.ActiveProfile == name {
+				cfg.ActiveProfile = ""
+			}
+		})
+		if err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Config, err))
+		}
+		fmt.Printf("Profile %s removed\n", name)
+	},
+}
+
+// connectionOverrides reads the global connection flags; DGX_* variables are applied by the config manager
+func connectionOverrides(cmd *cobra.Command) config.Overrides {
+	var o config.Overrides
+	o.Profile, _ = cmd.Flags().GetString("profile")
+	o.Host, _ = cmd.Flags().GetString("host")
+	o.Port, _ = cmd.Flags().GetInt("ssh-port")
+	o.User, _ = cmd.Flags().GetString("user")
+	o.IdentityFile, _ = cmd.Flags().GetString("identity-file")
+	o.ReadOnly, _ = cmd.Flags().GetBool("readonly")
+	return o
+}
+
+func init() {
+	rootCmd.PersistentFlags().String("profile", "", "Connection profile to use, or $DGX_PROFILE (see 'dgx config profile')")
+	rootCmd.PersistentFlags().String("host", "", "Override the DGX host for this command, or $DGX_HOST")
+	rootCmd.PersistentFlags().Int("ssh-port", 0, "Override the DGX SSH port for this command, or $DGX_PORT")
+	rootCmd.PersistentFlags().String("user", "", "Override the DGX user for this command, or $DGX_USER")
+	rootCmd.PersistentFlags().String("identity-file", "", "Override the SSH key for this command, or $DGX_IDENTITY_FILE")
+	rootCmd.PersistentFlags().Bool("readonly", false, "Refuse playbook commands that change the DGX (see 'readonly' in the config)")
+	rootCmd.PersistentFlags().Bool("plain", false, "Print plain lines without progress bars, live tables, colors, or cursor movement, or $DGX_PLAIN (see 'plain' in the config)")
+
+	configProfileSyncCmd.Flags().Bool("watch", false, "Re-import automatically whenever the NVIDIA Sync config changes")
+	configProfileSyncCmd.Flags().Bool("no-watch", false, "Stop re-importing automatically")
+
+	configProfileCmd.AddCommand(configProfileListCmd, configProfileAddCmd, configProfileUseCmd, configProfileRemoveCmd, configProfileSyncCmd)
+	configCmd.AddCommand(configValidateCmd, configProfileCmd)
+}
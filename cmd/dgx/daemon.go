@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/alert"
+	"github.com/weatherman/dgx-manager/internal/daemon"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+)
+
+// daemon command
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run a local REST API for GUIs and editor extensions",
+	Long: `Run a long-lived process that keeps one SSH connection to the DGX open and
+exposes status, tunnels, models, and autostart deployments as a JSON API on
+localhost, so GUIs and IDE extensions can build on the same connection and
+config as the CLI.
+
+Every request must send "Authorization: Bearer <token>". The token is created
+on first start in ~/.config/dgx/daemon.token (mode 0600); print it with
+'dgx daemon token'. The daemon only listens on loopback addresses.
+
+Endpoints:
+  GET    /v1/status                   connection state and latency
+  GET    /v1/alerts                   alert rules and which are firing
+  GET    /v1/tunnels                  SSH tunnels to the DGX
+  POST   /v1/tunnels                  {"local_port": 8888, "remote_port": 8888}
+  DELETE /v1/tunnels/{pid}
+  GET    /v1/models                   Docker Model Runner models
+  POST   /v1/models/pull              {"model": "ai/smollm2"}
+  GET    /v1/deploy/autostart
+  POST   /v1/deploy/autostart         {"name": "chat", "model": "ai/smollm2", "now": true}
+  DELETE /v1/deploy/autostart/{name}
+
+The daemon also evaluates the alert rules in the config (see 'dgx alerts')
+every alerts.interval and notifies their hooks when a rule fires or resolves.
+
+Autostart changes run sudo non-interactively, so they need passwordless sudo
+on the DGX. With readonly set, only GET requests are accepted.
+
+Examples:
+  dgx daemon
+  dgx daemon --listen 127.0.0.1:9000
+  curl -H "Authorization: Bearer $(dgx daemon token)" http://127.0.0.1:7717/v1/status`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := cfgManager.Get()
+		listen, _ := cmd.Flags().GetString("listen")
+		if err := daemon.CheckLoopback(listen); err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("%w; the daemon can run commands on the DGX", err)))
+		}
+
+		if err := alert.Validate(cfg.Alerts); err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Config, err))
+		}
+
+		token, err := daemon.LoadOrCreateToken(daemonTokenPath(cmd))
+		if err != nil {
+			exitWithError(err)
+		}
+
+		fmt.Printf("Connecting to %s@%s...\n", cfg.User, cfg.Host)
+		backend, err := daemon.NewRemote(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer backend.Close()
+
+		logger := log.New(os.Stdout, "", log.LstdFlags)
+		server := daemon.NewServer(backend, token, logger)
+		server.SetReadOnly(cfg.ReadOnly)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		rules := alert.Rules(cfg.Alerts)
+		alerts := alert.NewEvaluator(cfg.Host, rules)
+		server.SetAlerts(alerts)
+		sampling := pollScheduler().Register(cfg.Host, "alerts", alert.Interval(cfg.Alerts))
+		defer sampling.Close()
+		go alerts.Watch(ctx, sampling, func(ctx context.Context) (alert.Sample, error) {
+			return backend.AlertSample(ctx, rules)
+		}, alert.Hooks(cfg.Alerts), logger)
+
+		fmt.Printf("Serving dgx API on http://%s/v1\n", listen)
+		fmt.Printf("Token: %s\n", daemonTokenPath(cmd))
+		if cfg.ReadOnly {
+			fmt.Println("Read-only: only GET requests are accepted")
+		}
+		fmt.Printf("Alerts: %d rules checked every %v, %d hooks\n", len(rules), alert.Interval(cfg.Alerts), len(alert.Hooks(cfg.Alerts)))
+		fmt.Println("\nPress Ctrl+C to stop")
+
+		if err := server.ListenAndServe(ctx, listen); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+var daemonTokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Print the daemon's API token, creating it if needed",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		token, err := daemon.LoadOrCreateToken(daemonTokenPath(cmd))
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Println(token)
+	},
+}
+
+// daemonTokenPath returns --token-file, or daemon.token next to the config file
+func daemonTokenPath(cmd *cobra.Command) string {
+	if path, _ := cmd.Flags().GetString("token-file"); path != "" {
+		if expanded, err := expandPath(path); err == nil {
+			return expanded
+		}
+		return path
+	}
+	return daemon.TokenPath(cfgManager.GetConfigPath())
+}
+
+func init() {
+	daemonCmd.Flags().String("listen", daemon.DefaultListen, "Loopback address to serve the API on")
+	daemonCmd.PersistentFlags().String("token-file", "", "Token file (default: daemon.token next to the config file)")
+
+	daemonCmd.AddCommand(daemonTokenCmd)
+	rootCmd.AddCommand(daemonCmd)
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/dataset"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+// data command
+var dataCmd = &cobra.Command{
+	Use:   "data",
+	Short: "Manage datasets on the DGX",
+	Long: `Keep datasets in ~/datasets on the DGX, one directory per dataset, each with a
+manifest recording its size, checksum, and where it came from.
+
+The checksum covers every file in the dataset, so the same data pushed twice (or
+downloaded from a mirror) is recognised and not stored again.`,
+}
+
+var dataPushCmd = &cobra.Command{
+	Use:   "push <path|url|hf:owner/name>",
+	Short: "Upload a local dataset or download one on the DGX",
+	Long: `Add a dataset to ~/datasets on the DGX.
+
+Local files and directories are checksummed, uploaded with rsync, and verified on
+arrival. http(s) URLs and Hugging Face datasets (hf:owner/name) are downloaded
+directly on the DGX, so the data never passes through your workstation; Hugging
+Face downloads use the token stored with 'dgx env hf-token'.
+
+A dataset whose checksum matches one already on the DGX is not stored twice.
+
+Examples:
+  dgx data push ./alpaca-cleaned
+  dgx data push https://example.com/corpus.jsonl.gz --sha256 9f86d0...
+  dgx data push hf:HuggingFaceH4/ultrachat_200k --name ultrachat`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name, _ := cmd.Flags().GetString("name")
+		expected, _ := cmd.Flags().GetString("sha256")
+		force, _ := cmd.Flags().GetBool("force")
+
+		source := dataset.ParseSource(args[0])
+		if name == "" {
+			name = source.DefaultName()
+		}
+		if err := dataset.ValidateName(name); err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("%w; pass --name", err)))
+		}
+		if expected != "" && source.Kind != dataset.SourceURL {
+			fmt.Fprintln(os.Stderr, "Error: --sha256 checks a single downloaded file and only applies to URLs")
+			exit(exitcode.Usage)
+		}
+
+		var local dataset.Manifest
+		if source.Kind == dataset.SourceLocal {
+			fmt.Printf("Checksumming %s...\n", source.Ref)
+			var err error
+			if local, err = dataset.Digest(source.Ref); err != nil {
+				exitWithError(exitcode.Wrap(exitcode.Usage, err))
+			}
+		}
+
+		cfg := cfgManager.Get()
+		client, err := ssh.NewClient(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+
+		output, err := client.Execute(dataset.ListCommand)
+		if err != nil {
+			exitWithError(err)
+		}
+		manifests, unmanaged := dataset.ParseList(output)
+		exists := false
+		for _, m := range manifests {
+			exists = exists || m.Name == name
+		}
+		for _, u := range unmanaged {
+			exists = exists || u == name
+		}
+		if !force {
+			if source.Kind == dataset.SourceLocal {
+				if dup, ok := dataset.FindDuplicate(manifests, local); ok {
+					fmt.Printf("Already on the DGX as %s (%s); nothing to upload.\n", dup.Name, dataset.Dir(dup.Name))
+					return
+				}
+			}
+			if exists {
+				exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("dataset %s already exists with different contents; pass --force to replace it or --name to pick another name", name)))
+			}
+		}
+
+		dir := dataset.Dir(name)
+		var manifest dataset.Manifest
+		if source.Kind == dataset.SourceLocal {
+			src := source.Ref
+			prepare := "mkdir -p " + ssh.QuoteRemotePath(dir)
+			if info, err := os.Stat(src); err == nil && info.IsDir() {
+				src = ensureTrailingSlash(src)
+			} else if exists {
+				// rsync --delete only prunes directory transfers
+				prepare = fmt.Sprintf("rm -rf %[1]s && mkdir -p %[1]s", ssh.QuoteRemotePath(dir))
+			}
+			if _, err := client.Execute(prepare); err != nil {
+				exitWithError(err)
+			}
+			dest := fmt.Sprintf("%s@%s:%s", cfg.User, cfg.Host, ensureTrailingSlash(dir))
+			if err := client.Rsync(src, dest, true, "", "--partial", "--exclude=/"+dataset.ManifestFile); err != nil {
+				exitWithError(fmt.Errorf("upload failed (run the same command again to resume): %w", err))
+			}
+			if manifest, err = remoteDigest(client, dir); err != nil {
+				exitWithError(err)
+			}
+			if manifest.SHA256 != local.SHA256 {
+				exitWithError(exitcode.Wrap(exitcode.Remote, fmt.Errorf("checksum mismatch after upload: local %s, DGX %s", local.SHA256, manifest.SHA256)))
+			}
+		} else {
+			// Download to a staging directory so a failed or duplicate download never
+			// replaces an existing dataset
+			staging := dataset.Root + "/.incoming-" + name
+			download, err := dataset.DownloadCommand(source, staging)
+			if err != nil {
+				exitWithError(exitcode.Wrap(exitcode.Usage, err))
+			}
+			fmt.Printf("Downloading %s on the DGX...\n", source)
+			if err := client.RunInteractive(download); err != nil {
+				exitWithError(fmt.Errorf("download failed (run the same command again to resume): %w", err))
+			}
+			if manifest, err = remoteDigest(client, staging); err != nil {
+				exitWithError(err)
+			}
+			if expected != "" {
+				fileSum, err := client.Execute(fmt.Sprintf("cd %s && find . -type f -exec sha256sum {} + | cut -d' ' -f1", ssh.QuoteRemotePath(staging)))
+				if err != nil {
+					exitWithError(err)
+				}
+				if !strings.EqualFold(strings.TrimSpace(fileSum), expected) {
+					client.Execute("rm -rf " + ssh.QuoteRemotePath(staging))
+					exitWithError(exitcode.Wrap(exitcode.Remote, fmt.Errorf("sha256 mismatch: got %s, want %s", strings.TrimSpace(fileSum), expected)))
+				}
+			}
+			if dup, ok := dataset.FindDuplicate(manifests, manifest); ok && !force {
+				client.Execute("rm -rf " + ssh.QuoteRemotePath(staging))
+				fmt.Printf("Identical to %s (%s); discarded the download.\n", dup.Name, dataset.Dir(dup.Name))
+				return
+			}
+			move := fmt.Sprintf("rm -rf %[1]s && mv %[2]s %[1]s", ssh.QuoteRemotePath(dir), ssh.QuoteRemotePath(staging))
+			if _, err := client.Execute(move); err != nil {
+				exitWithError(err)
+			}
+		}
+
+		manifest.Name = name
+		manifest.Source = source.String()
+		manifest.Created = time.Now().UTC()
+		data, err := json.Marshal(manifest)
+		if err != nil {
+			exitWithError(err)
+		}
+		if err := client.Stream("cat > "+ssh.QuoteRemotePath(dir+"/"+dataset.ManifestFile), bytes.NewReader(append(data, '\n')), nil, nil); err != nil {
+			exitWithError(fmt.Errorf("failed to write manifest: %w", err))
+		}
+		fmt.Printf("Stored %s in %s (%d files, sha256 %s)\n", name, dir, manifest.Files, manifest.SHA256[:12])
+	},
+}
+
+var dataListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List datasets on the DGX",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		client, err := ssh.NewClient(cfgManager.Get())
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+
+		output, err := client.Execute(dataset.ListCommand)
+		if err != nil {
+			exitWithError(err)
+		}
+		manifests, unmanaged := dataset.ParseList(output)
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if manifests == nil {
+				manifests = []dataset.Manifest{}
+			}
+			if err := enc.Encode(manifests); err != nil {
+				exitWithError(err)
+			}
+			return
+		}
+		if len(manifests) == 0 && len(unmanaged) == 0 {
+			fmt.Println("No datasets yet. Add one with: dgx data push <path|url|hf:owner/name>")
+			return
+		}
+		if len(manifests) > 0 {
+			dataset.Print(os.Stdout, manifests)
+		}
+		if len(unmanaged) > 0 {
+			fmt.Printf("\nWithout a manifest: %s\n", strings.Join(unmanaged, ", "))
+		}
+	},
+}
+
+var dataRmCmd = &cobra.Command{
+	Use:   "rm <name>",
+	Short: "Delete a dataset from the DGX",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		yes, _ := cmd.Flags().GetBool("yes")
+		name := args[0]
+		if err := dataset.ValidateName(name); err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Usage, err))
+		}
+
+		client, err := ssh.NewClient(cfgManager.Get())
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+
+		dir := ssh.QuoteRemotePath(dataset.Dir(name))
+		size, err := client.Execute(fmt.Sprintf("[ -d %[1]s ] && du -sh %[1]s | cut -f1", dir))
+		if err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("no dataset named %s on the DGX", name)))
+		}
+		if !confirmCommand(cmd, fmt.Sprintf("Delete %s (%s)?", dataset.Dir(name), strings.TrimSpace(size)), yes) {
+			fmt.Println("Cancelled.")
+			exit(exitcode.Aborted)
+		}
+		if _, err := client.Execute("rm -rf " + dir); err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Deleted %s\n", name)
+	},
+}
+
+// remoteDigest checksums a dataset directory on the DGX
+func remoteDigest(client *ssh.Client, dir string) (dataset.Manifest, error) {
+	fmt.Println("Computing checksum on the DGX...")
+	output, err := client.Execute(dataset.DigestCommand(dir))
+	if err != nil {
+		return dataset.Manifest{}, err
+	}
+	return dataset.ParseDigest(output)
+}
+
+func init() {
+	dataPushCmd.Flags().String("name", "", "Dataset name (default: derived from the source)")
+	dataPushCmd.Flags().String("sha256", "", "Expected SHA-256 of a downloaded file")
+	dataPushCmd.Flags().Bool("force", false, "Store even when a dataset with the same name or contents exists")
+	dataListCmd.Flags().Bool("json", false, "Print manifests as JSON")
+	dataRmCmd.Flags().BoolP("yes", "y", false, "Delete without confirmation")
+	dataCmd.AddCommand(dataPushCmd)
+	dataCmd.AddCommand(dataListCmd)
+	dataCmd.AddCommand(dataRmCmd)
+	rootCmd.AddCommand(dataCmd)
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/deploy"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/serve"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+// deploy command
+var deployCmd = &cobra.Command{
+	Use:   "deploy",
+	Short: "Manage long-running model deployments on the DGX",
+}
+
+var deployAutostartCmd = &cobra.Command{
+	Use:   "autostart <name>",
+	Short: "Load a model at boot with a systemd unit on the DGX",
+	Long: `Install a systemd unit on the DGX that brings a model up at boot, so the
+endpoint is available without anyone running the CLI.
+
+Engines:
+  dmr     Wait for Docker Model Runner and load the model
+  ollama  Wait for the Ollama service and keep the model resident
+  vllm    Run a vLLM server container (uses HF_TOKEN from 'dgx env set')
+
+Without --model, pick one from a fuzzy-searchable list of recently used models,
+the Model Runner store, and serve routes.
+
+Use --type embedding or --type rerank for pooling models: vLLM serves them with
+the matching task, and Docker Model Runner and Ollama load them with an
+embedding or rerank request instead of a chat.
+
+With --warm, the unit sends a small chat request once the model is up, so the
+first user does not wait for the weights to load.
+
+Installing the unit uses sudo on the DGX, so you may be prompted for a password.
+
+Examples:
+  dgx deploy autostart smollm --engine dmr --model ai/smollm2:360M-Q4_K_M
+  dgx deploy autostart llama --engine vllm --model meta-llama/Llama-3.1-8B-Instruct --now --warm
+  dgx deploy autostart list
+  dgx deploy autostart disable llama`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		engine, _ := cmd.Flags().GetString("engine")
+		model, _ := cmd.Flags().GetString("model")
+		port, _ := cmd.Flags().GetInt("port")
+		image, _ := cmd.Flags().GetString("image")
+		now, _ := cmd.Flags().GetBool("now")
+		warm, _ := cmd.Flags().GetBool("warm")
+		modelType, _ := cmd.Flags().GetString("type")
+
+		if model == "" {
+			var err error
+			if model, err = pickModel(cmd, nil); err != nil {
+				fmt.Fprintln(os.Stderr, "Error: --model is required")
+				exit(exitcode.Usage)
+			}
+		}
+
+		cfg := cfgManager.Get()
+		entry := deploy.Autostart{
+			Name:   args[0],
+			Engine: engine,
+			Model:  model,
+			Port:   port,
+			Image:  image,
+			User:   cfg.User,
+			Type:   modelType,
+			Warm:   warm,
+		}
+		if err := entry.Validate(); err != nil {
+			exitWithError(err)
+		}
+
+		client, err := ssh.NewClient(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+		if entry.Engine == "vllm" {
+			entry.GPUMemory = reservedShare(client, cfg.Host, entry.Name)
+		}
+
+		fmt.Printf("Installing %s on %s...\n", deploy.UnitName(entry.Name), cfg.Host)
+		if err := deploy.NewManager(client).Enable(entry, now); err != nil {
+			exitWithError(err)
+		}
+		probeCache(cmd).Invalidate(deploymentsCacheKey(cfg))
+
+		fmt.Printf("\n%s (%s) will load at boot\n", entry.Name, entry.Model)
+		if !now {
+			fmt.Printf("Start it now with: dgx exec \"sudo systemctl start %s\"\n", deploy.UnitName(entry.Name))
+		}
+		fmt.Printf("Logs: dgx exec \"journalctl -u %s -n 50\"\n", deploy.UnitName(entry.Name))
+	},
+}
+
+var deployAutostartListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List autostart units on the DGX",
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := ssh.NewClient(cfgManager.Get())
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+
+		cache := probeCache(cmd)
+		cache.SetBypass(true)
+		entries, err := cachedDeployments(cache, cfgManager.Get(), client)
+		if err != nil {
+			exitWithError(err)
+		}
+		if len(entries) == 0 {
+			fmt.Println("No autostart units installed")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tENGINE\tMODEL\tTYPE\tPORT\tENABLED\tACTIVE\tWARMUP")
+		for _, e := range entries {
+			port := "-"
+			if e.Port > 0 {
+				port = fmt.Sprintf("%d", e.Port)
+			}
+			modelType := e.Type
+			if modelType == "" {
+				modelType = serve.TypeChat
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.Name, e.Engine, e.Model, modelType, port, e.Enabled, e.Active, warmupState(e))
+		}
+		w.Flush()
+	},
+}
+
+var deployAutostartDisableCmd = &cobra.Command{
+	Use:               "disable <name>",
+	Short:             "Stop and remove an autostart unit",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeDeployments,
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := ssh.NewClient(cfgManager.Get())
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+
+		if err := deploy.NewManager(client).Disable(args[0]); err != nil {
+			exitWithError(err)
+		}
+		probeCache(cmd).Invalidate(deploymentsCacheKey(cfgManager.Get()))
+		fmt.Printf("Autostart %s disabled and removed\n", args[0])
+	},
+}
+
+var deployWarmCmd = &cobra.Command{
+	Use:   "warm <name>",
+	Short: "Send a warmup request to a deployment so its model is loaded",
+	Long: `Send a small chat request to an autostart deployment, so the multi-gigabyte
+weight load happens now instead of on the first user's request. The command waits
+for the engine to answer first, which covers a vLLM server that is still starting.
+
+With --schedule, a systemd timer on the DGX repeats the warmup at that interval,
+which keeps engines that unload idle models (Docker Model Runner, Ollama) ready.
+Scheduling uses sudo on the DGX, so you may be prompted for a password.
+
+Examples:
+  dgx deploy warm llama
+  dgx deploy warm smollm --prompt "Write a haiku about GPUs"
+  dgx deploy warm smollm --schedule 30m
+  dgx deploy warm smollm --unschedule`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeDeployments,
+	Run: func(cmd *cobra.Command, args []string) {
+		prompt, _ := cmd.Flags().GetString("prompt")
+		wait, _ := cmd.Flags().GetDuration("wait")
+		schedule, _ := cmd.Flags().GetDuration("schedule")
+		unschedule, _ := cmd.Flags().GetBool("unschedule")
+		if schedule > 0 && unschedule {
+			exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("--schedule and --unschedule cannot be combined")))
+		}
+
+		cfg := cfgManager.Get()
+		client, err := ssh.NewClient(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+		manager := deploy.NewManager(client)
+
+		if unschedule {
+			if err := manager.Unschedule(args[0]); err != nil {
+				exitWithError(err)
+			}
+			fmt.Printf("Scheduled warmup for %s removed\n", args[0])
+			return
+		}
+
+		entry, err := manager.Lookup(args[0])
+		if err != nil {
+			exitWithError(err)
+		}
+
+		fmt.Printf("Warming %s (%s)...\n", entry.Name, entry.Model)
+		result, err := manager.Warm(entry, prompt, wait)
+		if err != nil {
+			exitWithError(err)
+		}
+		if result.Ready > 0 {
+			fmt.Printf("Engine answered after %v\n", result.Ready)
+		}
+		fmt.Printf("Warmup request completed in %v\n", result.Duration.Round(10*time.Millisecond))
+
+		if schedule > 0 {
+			fmt.Printf("Installing %s.timer on %s...\n", deploy.WarmUnitName(entry.Name), cfg.Host)
+			if err := manager.Schedule(entry, schedule); err != nil {
+				exitWithError(err)
+			}
+			fmt.Printf("\n%s will be warmed every %v\n", entry.Name, schedule)
+		}
+	},
+}
+
+var deployEnvCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Manage environment variables passed to a deployment's container",
+	Long: `Store environment variables for a vLLM autostart deployment on the DGX, in
+~/.config/dgx/deploy/<name>.env (readable only by you). The unit passes the file
+to the container every time it starts, so changes apply at the next restart;
+--restart restarts it right away, which uses sudo.
+
+Variables whose names look like credentials (TOKEN, SECRET, PASSWORD, API_KEY,
+...) are masked when listed, as are those set with --secret. Give a name
+without a value to be prompted for it, which keeps the value out of your shell
+history.
+
+Examples:
+  dgx deploy env set llama VLLM_LOGGING_LEVEL=DEBUG --restart
+  dgx deploy env set llama OPENAI_API_KEY
+  dgx deploy env list llama
+  dgx deploy env unset llama VLLM_LOGGING_LEVEL`,
+}
+
+var deployEnvListCmd = &cobra.Command{
+	Use:               "list <name>",
+	Short:             "List a deployment's environment variables, masking secrets",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeDeployments,
+	Run: func(cmd *cobra.Command, args []string) {
+		show, _ := cmd.Flags().GetBool("show-secrets")
+		manager, client := deployManager()
+		defer client.Close()
+
+		vars, err := manager.Env(args[0])
+		if err != nil {
+			exitWithError(err)
+		}
+		if len(vars) == 0 {
+			fmt.Printf("No environment variables set for %s\n", args[0])
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tVALUE")
+		for _, v := range vars {
+			value := v.Masked()
+			if show {
+				value = v.Value
+			}
+			fmt.Fprintf(w, "%s\t%s\n", v.Name, value)
+		}
+		w.Flush()
+	},
+}
+
+var deployEnvSetCmd = &cobra.Command{
+	Use:               "set <name> NAME[=value]...",
+	Short:             "Set environment variables for a deployment",
+	Args:              cobra.MinimumNArgs(2),
+	ValidArgsFunction: completeDeployments,
+	Run: func(cmd *cobra.Command, args []string) {
+		secret, _ := cmd.Flags().GetBool("secret")
+		restart, _ := cmd.Flags().GetBool("restart")
+
+		var updates []deploy.EnvVar
+		for _, arg := range args[1:] {
+			if !strings.Contains(arg, "=") {
+				if err := deploy.ValidateEnvName(arg); err != nil {
+					exitWithError(exitcode.Wrap(exitcode.Usage, err))
+				}
+				value, err := promptForSecret(arg)
+				if err != nil {
+					exitWithError(err)
+				}
+				arg += "=" + value
+			}
+			v, err := deploy.ParseEnvAssignment(arg)
+			if err != nil {
+				exitWithError(exitcode.Wrap(exitcode.Usage, err))
+			}
+			v.Secret = v.Secret || secret
+			updates = append(updates, v)
+		}
+
+		manager, client := deployManager()
+		defer client.Close()
+		entry := envDeployment(manager, args[0])
+		vars, err := manager.Env(entry.Name)
+		if err != nil {
+			exitWithError(err)
+		}
+		if err := manager.SaveEnv(entry.Name, deploy.SetEnv(vars, updates)); err != nil {
+			exitWithError(err)
+		}
+		for _, v := range updates {
+			fmt.Printf("Set %s=%s\n", v.Name, v.Masked())
+		}
+		applyDeployEnv(manager, entry, restart)
+	},
+}
+
+var deployEnvUnsetCmd = &cobra.Command{
+	Use:               "unset <name> NAME...",
+	Short:             "Remove environment variables from a deployment",
+	Args:              cobra.MinimumNArgs(2),
+	ValidArgsFunction: completeDeployments,
+	Run: func(cmd *cobra.Command, args []string) {
+		restart, _ := cmd.Flags().GetBool("restart")
+		manager, client := deployManager()
+		defer client.Close()
+
+		entry := envDeployment(manager, args[0])
+		vars, err := manager.Env(entry.Name)
+		if err != nil {
+			exitWithError(err)
+		}
+		vars, missing := deploy.UnsetEnv(vars, args[1:])
+		if len(missing) == len(args[1:]) {
+			exitWithError(fmt.Errorf("%s is not set for %s", strings.Join(missing, ", "), entry.Name))
+		}
+		if len(missing) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: %s was not set\n", strings.Join(missing, ", "))
+		}
+		if err := manager.SaveEnv(entry.Name, vars); err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Removed %d variables from %s\n", len(args[1:])-len(missing), entry.Name)
+		applyDeployEnv(manager, entry, restart)
+	},
+}
+
+// deployManager connects to the configured DGX
+func deployManager() (*deploy.Manager, *ssh.Client) {
+	client, err := ssh.NewClient(cfgManager.Get())
+	if err != nil {
+		exitWithError(err)
+	}
+	return deploy.NewManager(client), client
+}
+
+// envDeployment looks up the deployment whose environment is being changed; only vLLM
+// deployments run a container of their own
+func envDeployment(manager *deploy.Manager, name string) deploy.Autostart {
+	entry, err := manager.Lookup(name)
+	if err != nil {
+		exitWithError(err)
+	}
+	if entry.Engine != "vllm" {
+		exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("%s uses %s, which loads the model into a shared engine; environment variables apply to vllm deployments", name, entry.Engine)))
+	}
+	return entry
+}
+
+// applyDeployEnv restarts the deployment when asked, first reinstalling a unit from before
+// env files existed so it reads the file
+func applyDeployEnv(manager *deploy.Manager, entry deploy.Autostart, restart bool) {
+	if !entry.EnvFile {
+		fmt.Printf("Updating %s to pass its environment file to the container...\n", deploy.UnitName(entry.Name))
+		if err := manager.Enable(entry, false); err != nil {
+			exitWithError(err)
+		}
+	}
+	if !restart {
+		fmt.Printf("Applies at the next start of %s (or pass --restart)\n", entry.Name)
+		return
+	}
+	fmt.Printf("Restarting %s...\n", deploy.UnitName(entry.Name))
+	if err := manager.Restart(entry.Name); err != nil {
+		exitWithError(err)
+	}
+}
+
+// warmupState describes when a deployment is warmed, for the list
+func warmupState(e deploy.Autostart) string {
+	var when []string
+	if e.Warm {
+		when = append(when, "start")
+	}
+	if e.Timer == "active" {
+		when = append(when, "timer")
+	}
+	if len(when) == 0 {
+		return "-"
+	}
+	return strings.Join(when, ",")
+}
+
+func init() {
+	deployAutostartCmd.Flags().String("engine", "dmr", "Runtime to load the model with ("+strings.Join(deploy.Engines, ", ")+")")
+	deployAutostartCmd.Flags().String("model", "", "Model to load at boot (picked from a list when omitted)")
+	deployAutostartCmd.RegisterFlagCompletionFunc("model", completeModels)
+	deployAutostartCmd.Flags().Int("port", 0, "Host port for the vLLM server (default 8000)")
+	deployAutostartCmd.Flags().String("image", "", "vLLM container image (default "+deploy.DefaultVLLMImage+")")
+	deployAutostartCmd.Flags().Bool("now", false, "Also start the unit immediately")
+	deployAutostartCmd.Flags().String("type", serve.TypeChat, "Model type ("+strings.Join(serve.ModelTypes, ", ")+")")
+	deployAutostartCmd.Flags().Bool("warm", false, "Send a warmup request once the model is up")
+
+	deployWarmCmd.Flags().String("prompt", "", "Prompt for the warmup request (default \""+deploy.DefaultWarmPrompt+"\")")
+	deployWarmCmd.Flags().Duration("wait", 2*time.Minute, "How long to wait for the engine to answer")
+	deployWarmCmd.Flags().Duration("schedule", 0, "Also repeat the warmup on the DGX at this interval (e.g. 30m)")
+	deployWarmCmd.Flags().Bool("unschedule", false, "Remove the scheduled warmup")
+
+	deployEnvListCmd.Flags().Bool("show-secrets", false, "Print secret values instead of masking them")
+	deployEnvSetCmd.Flags().Bool("secret", false, "Mask the values when listed, whatever their names")
+	deployEnvSetCmd.Flags().Bool("restart", false, "Restart the deployment so the change applies now")
+	deployEnvUnsetCmd.Flags().Bool("restart", false, "Restart the deployment so the change applies now")
+
+	deployAutostartCmd.AddCommand(deployAutostartListCmd, deployAutostartDisableCmd)
+	deployEnvCmd.AddCommand(deployEnvListCmd, deployEnvSetCmd, deployEnvUnsetCmd)
+	deployCmd.AddCommand(deployAutostartCmd, deployWarmCmd, deployEnvCmd)
+	rootCmd.AddCommand(deployCmd)
+}
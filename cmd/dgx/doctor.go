@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/health"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+// doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose common DGX setup problems",
+	Long: `Run all diagnostics against the DGX in parallel and classify each result as
+ok, info, warn, or critical. Problems with a known remedy show the command that
+fixes them; --fix applies those remedies (after confirmation unless --yes is
+given) and re-runs the affected checks.
+
+The exit status is non-zero only when a critical problem remains. To chase one
+symptom, such as a model running out of memory, use 'dgx fix <symptom>'.
+
+Examples:
+  dgx doctor
+  dgx doctor --fix
+  dgx doctor --timeout 30s`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fix, _ := cmd.Flags().GetBool("fix")
+		yes, _ := cmd.Flags().GetBool("yes")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+
+		cfg := cfgManager.Get()
+		client, err := ssh.NewClient(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+
+		fmt.Printf("Diagnosing %s@%s...\n\n", cfg.User, cfg.Host)
+		if err := client.Connect(); err != nil {
+			fmt.Print(health.FormatResults([]health.Result{{Name: "SSH connection", Severity: health.SeverityCritical, Detail: err.Error()}}))
+			exit(exitcode.Of(err))
+		}
+
+		diagnostics := make([]health.Diagnostic, len(health.Diagnostics))
+		copy(diagnostics, health.Diagnostics)
+		for i := range diagnostics {
+			diagnostics[i].Timeout = timeout
+		}
+
+		results := health.Diagnose(context.Background(), client, diagnostics)
+		fmt.Print(health.FormatResults(results))
+
+		if fix {
+			var fixed []health.Diagnostic
+			for i, r := range results {
+				if r.Fix == nil {
+					continue
+				}
+				if !yes && !confirmAction(fmt.Sprintf("\n%s: %s?", r.Name, r.Fix.Description)) {
+					continue
+				}
+				if err := applyRemedy(client, r.Fix); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: fix for %s failed: %v\n", r.Name, err)
+				}
+				fixed = append(fixed, diagnostics[i])
+			}
+
+			if len(fixed) > 0 {
+				fmt.Println("\nRe-checking:")
+				rechecked := health.Diagnose(context.Background(), client, fixed)
+				fmt.Print(health.FormatResults(rechecked))
+				byName := make(map[string]health.Result, len(rechecked))
+				for _, r := range rechecked {
+					byName[r.Name] = r
+				}
+				for i, r := range results {
+					if updated, ok := byName[r.Name]; ok {
+						results[i] = updated
+					}
+				}
+			}
+		} else if hasFixes(results) {
+			fmt.Println("\nRun 'dgx doctor --fix' to apply the suggested fixes.")
+		}
+
+		if health.Worst(results) == health.SeverityCritical {
+			exit(1)
+		}
+	},
+}
+
+func hasFixes(results []health.Result) bool {
+	for _, r := range results {
+		if r.Fix != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	doctorCmd.Flags().Bool("fix", false, "Apply known remedies for the problems found")
+	doctorCmd.Flags().BoolP("yes", "y", false, "Apply fixes without confirmation")
+	doctorCmd.Flags().Duration("timeout", health.DefaultTimeout, "Timeout for each check")
+	rootCmd.AddCommand(doctorCmd)
+}
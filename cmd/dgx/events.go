@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/dmr"
+	"github.com/weatherman/dgx-manager/internal/events"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+// events command
+var eventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Show or follow container, systemd unit, and model lifecycle events",
+	Long: `Merge the DGX's lifecycle events into one timestamped feed:
+
+  docker   container create, start, die (with the exit code), oom, stop, destroy,
+           restart, and health status changes
+  systemd  units started, stopped, restarted, or failed (reading the system
+           journal needs root or the adm or systemd-journal group)
+  dmr      Docker Model Runner models loaded and unloaded, seen while following
+
+Without --follow the events of the last --since (1h by default) are printed in
+time order. With --follow new events are printed as they happen, after those of
+--since when it is given. --json prints one JSON object per line, for scripts
+that react to model loads or container crashes.
+
+Examples:
+  dgx events
+  dgx events --since 24h --source systemd
+  dgx events -f
+  dgx events -f --json | jq -c 'select(.action == "die")'`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		follow, _ := cmd.Flags().GetBool("follow")
+		since, _ := cmd.Flags().GetString("since")
+		sources, _ := cmd.Flags().GetStringSlice("source")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		for _, s := range sources {
+			if !slices.Contains(events.Sources, s) {
+				exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("unknown --source %q (want docker, systemd, or dmr)", s)))
+			}
+		}
+		if since == "" && !follow {
+			since = "1h"
+		}
+		if since != "" {
+			if d, err := time.ParseDuration(since); err != nil || d <= 0 {
+				exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("invalid --since %q (want a duration such as 10m or 24h)", since)))
+			}
+		}
+
+		client, err := ssh.NewClient(cfgManager.Get())
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+		if err := client.Connect(); err != nil {
+			exitWithError(err)
+		}
+
+		runner := dmr.NewClient(client.Dial, "tcp", dmr.DefaultAddr)
+		opts := events.Options{
+			Sources: sources,
+			Since:   since,
+			Follow:  follow,
+			Running: func(ctx context.Context) ([]dmr.Runner, error) {
+				ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+				defer cancel()
+				return runner.Running(ctx)
+			},
+			Poll: pollScheduler(),
+			Host: cfgManager.Get().Host,
+			Warn: func(source string, err error) {
+				fmt.Fprintf(os.Stderr, "Warning: %s events: %v\n", source, err)
+			},
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		count := 0
+		events.Collect(context.Background(), client, opts, func(e events.Event) {
+			count++
+			if asJSON {
+				enc.Encode(e)
+				return
+			}
+			fmt.Println(e)
+		})
+		if count == 0 && !asJSON {
+			fmt.Printf("No events in the last %s\n", since)
+		}
+	},
+}
+
+func init() {
+	eventsCmd.Flags().BoolP("follow", "f", false, "Keep printing new events until interrupted")
+	eventsCmd.Flags().String("since", "", "Show events from this long ago, e.g. 10m or 24h (default 1h without --follow)")
+	eventsCmd.Flags().StringSlice("source", events.Sources, "Event sources to include: docker, systemd, dmr")
+	eventsCmd.Flags().Bool("json", false, "Print one JSON object per event")
+	rootCmd.AddCommand(eventsCmd)
+}
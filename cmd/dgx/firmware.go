@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/firmware"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+// firmware command
+var firmwareCmd = &cobra.Command{
+	Use:     "firmware",
+	Aliases: []string{"fw"},
+	Short:   "Inspect DGX firmware and driver versions",
+}
+
+var firmwareStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Report firmware/SBIOS/driver versions and flag available updates",
+	Long: `Query SBIOS, VBIOS, driver, and DGX OS versions on the DGX and compare them
+against the CLI's known-latest release metadata. Devices managed by fwupd are
+also checked for pending firmware updates.
+
+Examples:
+  dgx firmware status
+  dgx firmware status --metadata ./spark-releases.yaml
+
+Installed versions are cached for 15 minutes; pass --no-cache to query again.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := ssh.NewClient(cfgManager.Get())
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+
+		checker := firmware.NewChecker(client)
+		checker.UseCache(probeCache(cmd), cfgManager.Get().Host)
+		if path, _ := cmd.Flags().GetString("metadata"); path != "" {
+			if err := checker.LoadMetadata(path); err != nil {
+				exitWithError(err)
+			}
+		}
+
+		components, err := checker.Status()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+
+		fmt.Print(firmware.FormatStatus(components))
+
+		updates := 0
+		for _, c := range components {
+			if !c.UpdateAvailable {
+				continue
+			}
+			if updates == 0 {
+				fmt.Println()
+				fmt.Println("Updates available:")
+			}
+			updates++
+			fmt.Printf("  %s %s -> %s\n", c.Name, c.Installed, c.Latest)
+			if c.UpdateHint != "" {
+				fmt.Printf("    Apply with: %s\n", c.UpdateHint)
+			}
+		}
+		if updates == 0 {
+			fmt.Println()
+			fmt.Println("All tracked components are up to date.")
+		}
+	},
+}
+
+func init() {
+	firmwareStatusCmd.Flags().String("metadata", "", "YAML file with known-latest release metadata (overrides built-in)")
+	firmwareCmd.AddCommand(firmwareStatusCmd)
+	rootCmd.AddCommand(firmwareCmd)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/health"
+	"github.com/weatherman/dgx-manager/internal/picker"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"golang.org/x/term"
+)
+
+// fix command
+var fixCmd = &cobra.Command{
+	Use:   "fix [symptom]",
+	Short: "Troubleshoot a symptom step by step and offer fixes",
+	Long: `Walk the troubleshooting tree for a symptom: run its diagnostics in order,
+explain how each problem found causes the symptom, and offer the fix where one
+is known, applied with a single key (y to fix, n or Enter to skip, q to stop).
+Fixes are remote commands or playbooks ('dgx run'), and each fixed check is run
+again. A problem the later checks depend on stops the walk until it is fixed.
+
+Symptoms:
+  oom                model fails to load or is killed for lack of memory
+  docker-permission  docker fails with permission denied on the socket
+  cuda-container     CUDA or the GPU is not visible inside a container
+  slow-tokens        generation is slower than it should be
+
+Without a symptom, one is picked interactively, or listed when there is no
+terminal. For a check of everything at once, use 'dgx doctor'.
+
+Examples:
+  dgx fix oom
+  dgx fix cuda-container
+  dgx fix slow-tokens --yes`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		var symptom string
+		if len(args) == 1 {
+			symptom = args[0]
+		} else {
+			symptom = pickSymptom()
+		}
+		flow, err := health.FindFlow(symptom)
+		if err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Usage, err))
+		}
+
+		cfg := cfgManager.Get()
+		client, err := ssh.NewClient(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+		if err := client.Connect(); err != nil {
+			exitWithError(err)
+		}
+
+		fmt.Printf("Troubleshooting %s on %s: %s\n\n", flow.Symptom, cfg.Host, flow.Summary)
+		remaining, problems := walkFlow(client, flow, yes)
+
+		switch {
+		case problems == 0:
+			fmt.Printf("\nNo known cause of %s found. Run 'dgx doctor' for a full check.\n", flow.Symptom)
+		case remaining == 0:
+			fmt.Println("\nAll problems found were fixed. Try again what showed the symptom.")
+		default:
+			fmt.Printf("\n%d of %d problems remain.\n", remaining, problems)
+			exit(1)
+		}
+	},
+}
+
+// walkFlow runs the flow's steps, offering each fix found, and returns how many problems
+// remain and how many were found
+func walkFlow(client *ssh.Client, flow *health.Flow, yes bool) (remaining, problems int) {
+	for i, step := range flow.Steps {
+		r := health.Diagnose(context.Background(), client, []health.Diagnostic{step.Diagnostic})[0]
+		fmt.Print(formatFlowResult(r))
+		if r.Severity < health.SeverityWarn {
+			continue
+		}
+		problems++
+		if step.Why != "" {
+			fmt.Printf("         %s\n", step.Why)
+		}
+
+		if r.Fix != nil {
+			key := byte('y')
+			if !yes {
+				key = readKey(fmt.Sprintf("         Fix: %s (%s)? [y/N/q] ", r.Fix.Description, r.Fix.CommandLine()))
+			}
+			if key == 'q' {
+				return remaining + 1, problems
+			}
+			if key == 'y' {
+				if err := applyRemedy(client, r.Fix); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: fix for %s failed: %v\n", r.Name, err)
+				}
+				r = health.Diagnose(context.Background(), client, []health.Diagnostic{step.Diagnostic})[0]
+				fmt.Print(formatFlowResult(r))
+			}
+		}
+
+		if r.Severity >= health.SeverityWarn {
+			remaining++
+			if step.Blocking && i < len(flow.Steps)-1 {
+				fmt.Println("\nFix this first: the remaining checks depend on it.")
+				return remaining, problems
+			}
+		}
+	}
+	return remaining, problems
+}
+
+// formatFlowResult renders one result without the fix hint, which walkFlow offers instead
+func formatFlowResult(r health.Result) string {
+	r.Fix = nil
+	return health.FormatResults([]health.Result{r})
+}
+
+// applyRemedy runs a diagnostic's fix: a playbook, as 'dgx run' would, or a remote command
+func applyRemedy(client *ssh.Client, fix *health.Remedy) error {
+	fmt.Printf("Running: %s\n", fix.CommandLine())
+	if len(fix.Playbook) > 0 {
+		return newPlaybookManager(client).Execute(fix.Playbook[0], fix.Playbook[1:])
+	}
+	return client.RunInteractive(fix.Command)
+}
+
+// readKey shows prompt and returns the key pressed, lowercased, without waiting for Enter.
+// Without a terminal it reads a line instead.
+func readKey(prompt string) byte {
+	fmt.Print(prompt)
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		var response string
+		fmt.Scanln(&response)
+		if response == "" {
+			return 'n'
+		}
+		return strings.ToLower(response)[0]
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return 'n'
+	}
+	buf := make([]byte, 1)
+	_, err = os.Stdin.Read(buf)
+	term.Restore(fd, state)
+	fmt.Println()
+	switch {
+	case err != nil, buf[0] == 3: // Ctrl+C
+		return 'q'
+	case buf[0] >= 'A' && buf[0] <= 'Z':
+		return buf[0] + 'a' - 'A'
+	}
+	return buf[0]
+}
+
+// pickSymptom asks for a symptom with the interactive picker, or lists them and exits
+// when there is no terminal
+func pickSymptom() string {
+	items := make([]picker.Item, len(health.Flows))
+	for i, f := range health.Flows {
+		items[i] = picker.Item{Value: f.Symptom, Note: f.Summary}
+	}
+	symptom, err := picker.Pick("Symptom: ", items)
+	switch {
+	case errors.Is(err, picker.ErrNoTerminal):
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SYMPTOM\tDESCRIPTION")
+		for _, f := range health.Flows {
+			fmt.Fprintf(w, "%s\t%s\n", f.Symptom, f.Summary)
+		}
+		w.Flush()
+		fmt.Println("\nRun 'dgx fix <symptom>' to troubleshoot one.")
+		exit(0)
+	case errors.Is(err, picker.ErrCanceled):
+		exit(exitcode.Aborted)
+	case err != nil:
+		exitWithError(err)
+	}
+	return symptom
+}
+
+func init() {
+	fixCmd.Flags().BoolP("yes", "y", false, "Apply every fix found without asking")
+	rootCmd.AddCommand(fixCmd)
+}
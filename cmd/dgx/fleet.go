@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/weatherman/dgx-manager/internal/artifacts"
+	"github.com/weatherman/dgx-manager/internal/config"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/fleet"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/ui"
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// fleetTarget is one DGX a fleet command acts on
+type fleetTarget struct {
+	Name    string
+	Profile string // set when the target was named by profile
+	Config  *types.Config
+}
+
+// fleet command
+var fleetCmd = &cobra.Command{
+	Use:   "fleet",
+	Short: "Run commands and playbooks across several DGX hosts",
+	Long: `Fan work out to several DGX hosts with a bounded worker pool.
+
+Targets given with --hosts are profile names, inventory host names, or host /
+user@host (which inherit the port, user, and key of the current connection).
+--group and --tag pick hosts from the inventory (~/.config/dgx/hosts.yaml)
+instead; see 'dgx fleet hosts'. --parallel caps how many
+jobs run at once. Mutating jobs for the same host always run one after another,
+while read-only ones may overlap. Progress is shown as a queued/running/done
+table, and each job's output is printed once everything has finished.`,
+}
+
+var fleetExecCmd = &cobra.Command{
+	Use:   "exec <command>",
+	Short: "Run a shell command on every target",
+	Long: `Run a shell command on every target. Commands count as mutating unless
+--read-only is given.
+
+Examples:
+  dgx fleet exec --hosts lab1,lab2,lab3 'df -h /'
+  dgx fleet exec --hosts lab1,lab2 --read-only --parallel 8 nvidia-smi -L`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		readOnly, _ := cmd.Flags().GetBool("read-only")
+		command := strings.Join(args, " ")
+
+		export := exportPath(cmd)
+		targets := fleetTargets(cmd)
+		jobs := make([]fleet.Job, len(targets))
+		rows := make([]fleet.ReportRow, len(targets))
+		for i, t := range targets {
+			cfg := t.Config
+			jobs[i] = fleet.Job{Host: t.Name, Label: command, Mutating: !readOnly, Run: func(ctx context.Context) (string, error) {
+				remote, err := execCommand(cfg, args)
+				if err != nil {
+					return "", err
+				}
+				client, err := ssh.NewClient(cfg)
+				if err != nil {
+					return "", err
+				}
+				defer client.Close()
+				return client.ExecuteContext(ctx, remote)
+			}}
+			if export != "" {
+				jobs[i] = withReport(jobs[i], cfg, &rows[i])
+			}
+		}
+		results := runFleet(cmd, jobs)
+		code := reportFleet(results)
+		if export != "" {
+			exportFleet(export, rows, results)
+		}
+		exit(code)
+	},
+}
+
+var fleetRunCmd = &cobra.Command{
+	Use:   "run <playbook> [args...]",
+	Short: "Run a playbook on every target",
+	Long: `Run 'dgx run <playbook> [args...]' against every target, each in its own dgx
+process so their output stays separate. Playbooks are treated as mutating.
+Prompts read end-of-file, which accepts [Y/n] defaults and declines [y/N] ones.
+
+With --canary, the playbook runs on that host alone first. Once the host is up
+again (the playbook may reboot it), the post-update health checks run, and a
+short benchmark streams a reply from --canary-model (default: the preset's
+model) in Docker Model Runner. The benchmark must answer and keep at least 70%
+of the tokens/s measured before the update. The other hosts are only updated
+when all of this passes. Meant for updates such as 'dmr update' and
+'nvidia update --reboot --yes'.
+
+Examples:
+  dgx fleet run --hosts lab1,lab2,lab3 devsetup
+  dgx fleet run --hosts lab1,lab2 --parallel 2 ollama pull qwen2.5:7b
+  dgx fleet run --group lab --canary lab1 dmr update
+  dgx fleet run --group lab --canary lab1 --canary-model ai/smollm2 nvidia update --reboot --yes`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		exe, err := os.Executable()
+		if err != nil {
+			exitWithError(fmt.Errorf("failed to locate the dgx executable: %w", err))
+		}
+
+		export := exportPath(cmd)
+		targets := fleetTargets(cmd)
+		jobs := make([]fleet.Job, len(targets))
+		rows := make([]fleet.ReportRow, len(targets))
+		readOnly, _ := cmd.Flags().GetBool("readonly")
+		for i, t := range targets {
+			childArgs := connectionArgs(t.Config)
+			if t.Profile != "" {
+				childArgs = []string{"--profile", t.Profile}
+			}
+			if readOnly && !slices.Contains(childArgs, "--readonly") {
+				childArgs = append(childArgs, "--readonly")
+			}
+			childArgs = append(append(childArgs, "run"), args...)
+			jobs[i] = fleet.Job{Host: t.Name, Label: strings.Join(args, " "), Mutating: true, Run: func(ctx context.Context) (string, error) {
+				return runChild(ctx, exe, childArgs)
+			}}
+			if export != "" {
+				jobs[i] = withReport(jobs[i], t.Config, &rows[i])
+			}
+		}
+		if canary, _ := cmd.Flags().GetString("canary"); canary != "" {
+			exit(runWithCanary(cmd, canary, targets, jobs, rows, export))
+		}
+		results := runFleet(cmd, jobs)
+		code := reportFleet(results)
+		if export != "" {
+			exportFleet(export, rows, results)
+		}
+		exit(code)
+	},
+}
+
+var fleetReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Collect driver, DMR, disk, and GPU facts from every target",
+	Long: `Probe every target for its GPU, NVIDIA driver, Docker Model Runner version,
+DGX OS build, free root disk space, and GPU temperature, and print one row per
+host. --export writes the rows to a .csv or .json file instead, for asset
+spreadsheets. Hosts that cannot be reached get a failed row with the error.
+
+--export also works on any --group/--tag fan-out and on fleet exec/run, where
+the row's status is the outcome of the command that ran.
+
+Examples:
+  dgx fleet report --group all
+  dgx fleet report --hosts lab1,lab2 --export sparks.csv
+  dgx --group lab doctor --export doctor.json`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		export := exportPath(cmd)
+		targets := fleetTargets(cmd)
+		jobs := make([]fleet.Job, len(targets))
+		rows := make([]fleet.ReportRow, len(targets))
+		for i, t := range targets {
+			jobs[i] = withReport(fleet.Job{Host: t.Name, Label: "report", Run: func(ctx context.Context) (string, error) {
+				return "", nil
+			}}, t.Config, &rows[i])
+		}
+		results := runFleet(cmd, jobs)
+		if export != "" {
+			exportFleet(export, rows, results)
+		} else {
+			fillReport(rows, results)
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "HOST\tSTATUS\tGPU\tDRIVER\tDMR\tDGX OS\tDISK FREE\tGPU TEMP")
+			for _, r := range rows {
+				disk, temp := "-", "-"
+				if r.DiskFreeKB >= 0 {
+					disk = artifacts.FormatBytes(r.DiskFreeKB * 1024)
+				}
+				if r.GPUTempC >= 0 {
+					temp = fmt.Sprintf("%d°C", r.GPUTempC)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.Host, r.Status, ui.OrDash(r.GPU), ui.OrDash(r.Driver),
+					ui.OrDash(r.DMRVersion), ui.OrDash(r.DGXOS), disk, temp)
+			}
+			w.Flush()
+		}
+		for _, r := range results {
+			if r.Err != nil {
+				exit(exitcode.Of(r.Err))
+			}
+		}
+	},
+}
+
+var fleetHostsCmd = &cobra.Command{
+	Use:   "hosts",
+	Short: "List inventory hosts, optionally filtered by --group and --tag",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		groups, _ := cmd.Flags().GetStringSlice("group")
+		tags, _ := cmd.Flags().GetStringSlice("tag")
+		inv := loadInventory(cmd)
+		hosts, err := inv.Select(groups, tags)
+		if err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Usage, err))
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tADDRESS\tGROUPS\tTAGS")
+		for _, h := range hosts {
+			cfg := inv.Config(cfgManager.Get(), h)
+			fmt.Fprintf(w, "%s\t%s@%s:%d\t%s\t%s\n", h.Name, cfg.User, cfg.Host, cfg.Port,
+				ui.OrDash(strings.Join(h.Groups, ",")), ui.OrDash(strings.Join(h.Tags, ",")))
+		}
+		w.Flush()
+	},
+}
+
+// fleetTargets resolves --hosts, or the inventory hosts chosen by --group and --tag, into
+// connection configs
+func fleetTargets(cmd *cobra.Command) []fleetTarget {
+	specs, _ := cmd.Flags().GetStringSlice("hosts")
+	if len(specs) > 0 && groupSelected(cmd) {
+		fmt.Fprintln(os.Stderr, "Error: use either --hosts or --group/--tag")
+		exit(exitcode.Usage)
+	}
+	if groupSelected(cmd) {
+		return inventoryTargets(cmd)
+	}
+	if len(specs) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: no targets; pass --hosts with profile or inventory names or hosts, or --group")
+		exit(exitcode.Usage)
+	}
+	return resolveTargets(cmd, specs)
+}
+
+// resolveTargets turns profile names, inventory names, and host or user@host specs into
+// connection configs
+func resolveTargets(cmd *cobra.Command, specs []string) []fleetTarget {
+	// Inventory names are accepted too when there is an inventory
+	var inv *config.Inventory
+	if _, err := os.Stat(inventoryPath(cmd)); err == nil {
+		inv = loadInventory(cmd)
+	}
+	profiles := map[string]bool{}
+	for _, name := range cfgManager.ProfileNames() {
+		profiles[name] = true
+	}
+	targets := make([]fleetTarget, 0, len(specs))
+	for _, spec := range specs {
+		if profiles[spec] {
+			cfg, err := cfgManager.ProfileConfig(spec)
+			if err != nil {
+				exitWithError(exitcode.Wrap(exitcode.Config, err))
+			}
+			targets = append(targets, fleetTarget{Name: spec, Profile: spec, Config: cfg})
+			continue
+		}
+		if inv != nil {
+			if h, ok := inv.Lookup(spec); ok {
+				targets = append(targets, fleetTarget{Name: spec, Config: inv.Config(cfgManager.Get(), h)})
+				continue
+			}
+		}
+		targets = append(targets, fleetTarget{Name: spec, Config: peerConfig(cfgManager.Get(), spec)})
+	}
+	return targets
+}
+
+// runFleet runs jobs with the command's --parallel limit, showing progress on stderr
+func runFleet(cmd *cobra.Command, jobs []fleet.Job) []fleet.Result {
+	parallel, _ := cmd.Flags().GetInt("parallel")
+	pool := &fleet.Pool{
+		Parallel: parallel,
+		Progress: os.Stderr,
+		Live:     ui.Live(os.Stderr),
+	}
+	return pool.Run(context.Background(), jobs)
+}
+
+// reportFleet prints each job's output and returns the exit code of the first failure
+func reportFleet(results []fleet.Result) int {
+	code := exitcode.OK
+	failed := 0
+	for _, r := range results {
+		fmt.Printf("\n==> %s: %s (%s) <==\n", r.Job.Host, r.Job.Label, r.State)
+		if output := strings.TrimRight(r.Output, "\n"); output != "" {
+			fmt.Println(output)
+		}
+		if r.Err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", r.Job.Host, r.Err)
+			failed++
+			if code == exitcode.OK {
+				code = exitcode.Of(r.Err)
+			}
+		}
+	}
+	fmt.Printf("\n%d of %d jobs succeeded\n", len(results)-failed, len(results))
+	return code
+}
+
+// exportPath returns --export, exiting before any work starts when its format is unknown
+func exportPath(cmd *cobra.Command) string {
+	path, _ := cmd.Flags().GetString("export")
+	if path != "" {
+		if _, err := fleet.ExportFormat(path); err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Usage, err))
+		}
+	}
+	return path
+}
+
+// withReport wraps job so that the host's report row is probed once the job has run,
+// whether or not it succeeded
+func withReport(job fleet.Job, cfg *types.Config, row *fleet.ReportRow) fleet.Job {
+	run := job.Run
+	row.Host = job.Host
+	row.Address = fmt.Sprintf("%s@%s:%d", cfg.User, cfg.Host, cfg.Port)
+	job.Run = func(ctx context.Context) (string, error) {
+		output, err := run(ctx)
+		fleet.ParseReport(row, "")
+		client, probeErr := ssh.NewClient(cfg)
+		if probeErr == nil {
+			var facts string
+			facts, probeErr = client.ExecuteContext(ctx, fleet.ReportCommand)
+			client.Close()
+			fleet.ParseReport(row, facts)
+		}
+		if probeErr != nil {
+			row.Error = probeErr.Error()
+			if err == nil {
+				err = probeErr
+			}
+		}
+		return output, err
+	}
+	return job
+}
+
+// fillReport copies each job's outcome into its report row
+func fillReport(rows []fleet.ReportRow, results []fleet.Result) {
+	for i, r := range results {
+		rows[i].Status = r.State
+		if r.Err != nil && rows[i].Error == "" {
+			rows[i].Error = r.Err.Error()
+		}
+	}
+}
+
+// exportFleet writes the report rows to path
+func exportFleet(path string, rows []fleet.ReportRow, results []fleet.Result) {
+	fillReport(rows, results)
+	if err := fleet.ExportReport(path, rows); err != nil {
+		exitWithError(err)
+	}
+	fmt.Fprintf(os.Stderr, "Report for %d hosts written to %s\n", len(rows), path)
+}
+
+func init() {
+	fleetCmd.PersistentFlags().StringSlice("hosts", nil, "Targets: profile names or host / user@host (comma-separated or repeated)")
+	fleetExecCmd.Flags().Bool("read-only", false, "The command changes nothing, so it may overlap other jobs on a host")
+	fleetRunCmd.Flags().String("canary", "", "Run on this target first and continue only if its health checks and benchmark pass")
+	fleetRunCmd.Flags().String("canary-model", "", "Model to benchmark on the canary in Docker Model Runner (default: the preset's model)")
+	fleetRunCmd.Flags().Duration("canary-timeout", 10*time.Minute, "How long to wait for the canary to come back up after the playbook")
+	// Everything after the playbook name belongs to it
+	fleetRunCmd.Flags().SetInterspersed(false)
+	fleetExecCmd.Flags().SetInterspersed(false)
+	fleetCmd.AddCommand(fleetHostsCmd)
+	fleetCmd.AddCommand(fleetReportCmd)
+	fleetCmd.AddCommand(fleetExecCmd)
+	fleetCmd.AddCommand(fleetRunCmd)
+	rootCmd.AddCommand(fleetCmd)
+}
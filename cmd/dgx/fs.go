@@ -0,0 +1,681 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/weatherman/dgx-manager/internal/artifacts"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/sftp"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/textdiff"
+)
+
+// fsPollInterval is how often tail -f checks the file for new data, unless other loops are
+// polling the DGX for the same
+const fsPollInterval = time.Second
+
+// fs command
+var fsCmd = &cobra.Command{
+	Use:   "fs",
+	Short: "Inspect files on the DGX over SFTP, or edit one locally",
+	Long: `List, read, and measure files on the DGX without opening a shell. These
+commands use the SSH server's SFTP subsystem, so nothing runs on the DGX but
+sftp-server, and they only read. 'dgx fs edit' opens a file in your local
+editor and writes it back.
+
+Paths are relative to the login directory unless absolute. Arguments may be
+glob patterns (*, ?, [...]), matched on the DGX; quote them so the local shell
+leaves them alone.
+
+Examples:
+  dgx fs ls
+  dgx fs ls 'runs/*/checkpoints'
+  dgx fs cat '/etc/os-release'
+  dgx fs tail -f runs/latest/train.log
+  dgx fs stat '~/.cache/huggingface'
+  dgx fs du --depth 1 .cache
+  dgx fs edit --sudo /etc/docker/daemon.json`,
+}
+
+var fsLsCmd = &cobra.Command{
+	Use:   "ls [path|glob...]",
+	Short: "List directories with sizes",
+	Run: func(cmd *cobra.Command, args []string) {
+		all, _ := cmd.Flags().GetBool("all")
+		if len(args) == 0 {
+			args = []string{"."}
+		}
+		c := openSFTP()
+		defer c.Close()
+
+		paths, failed := expandRemote(c, args)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		var files []*sftp.FileInfo
+		var dirs []string
+		for _, p := range paths {
+			fi, err := c.Stat(p)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				failed = true
+				continue
+			}
+			if fi.IsDir() {
+				dirs = append(dirs, p)
+			} else {
+				files = append(files, fi.WithName(p))
+			}
+		}
+		printListing(w, files)
+
+		for i, dir := range dirs {
+			entries, err := c.ReadDir(dir)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				failed = true
+				continue
+			}
+			if len(paths) > 1 {
+				if i > 0 || len(files) > 0 {
+					fmt.Fprintln(w)
+				}
+				fmt.Fprintf(w, "%s:\n", dir)
+			}
+			var shown []*sftp.FileInfo
+			for _, e := range entries {
+				if all || !strings.HasPrefix(e.Name(), ".") {
+					shown = append(shown, e)
+				}
+			}
+			sort.Slice(shown, func(i, j int) bool { return shown[i].Name() < shown[j].Name() })
+			printListing(w, shown)
+		}
+		w.Flush()
+		if failed {
+			exit(1)
+		}
+	},
+}
+
+var fsCatCmd = &cobra.Command{
+	Use:   "cat <path|glob>...",
+	Short: "Print files",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := openSFTP()
+		defer c.Close()
+
+		paths, failed := expandRemote(c, args)
+		for _, p := range paths {
+			if err := catRemote(c, p); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				failed = true
+			}
+		}
+		if failed {
+			exit(1)
+		}
+	},
+}
+
+var fsTailCmd = &cobra.Command{
+	Use:   "tail <path|glob>",
+	Short: "Print the end of a file, optionally following it",
+	Long: `Print the last lines of a file. With -f, keep printing what is appended
+until interrupted; a file that shrinks (truncated or rotated in place) is
+followed from its start.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		lines, _ := cmd.Flags().GetInt("lines")
+		follow, _ := cmd.Flags().GetBool("follow")
+		if lines < 0 {
+			exitWithError(exitcode.Wrap(exitcode.Usage, errors.New("--lines must not be negative")))
+		}
+		c := openSFTP()
+		defer c.Close()
+
+		paths, failed := expandRemote(c, args)
+		if failed {
+			exit(1)
+		}
+		if len(paths) > 1 {
+			exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("%s matches %d files; tail takes one", args[0], len(paths))))
+		}
+		if err := tailRemote(c, paths[0], lines, follow); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+var fsStatCmd = &cobra.Command{
+	Use:   "stat <path|glob>...",
+	Short: "Show file type, size, mode, owner, and times",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := openSFTP()
+		defer c.Close()
+
+		paths, failed := expandRemote(c, args)
+		for i, p := range paths {
+			fi, err := c.Lstat(p)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				failed = true
+				continue
+			}
+			if i > 0 {
+				fmt.Println()
+			}
+			uid, gid := fi.Owner()
+			fmt.Printf("File:     %s\n", p)
+			fmt.Printf("Type:     %s\n", fileType(fi.Mode()))
+			fmt.Printf("Size:     %d (%s)\n", fi.Size(), artifacts.FormatBytes(fi.Size()))
+			fmt.Printf("Mode:     %s (%04o)\n", fi.Mode(), fi.Mode().Perm())
+			fmt.Printf("Owner:    uid %d, gid %d\n", uid, gid)
+			fmt.Printf("Modified: %s\n", fi.ModTime().Local().Format(time.RFC3339))
+			fmt.Printf("Accessed: %s\n", fi.AccessTime().Local().Format(time.RFC3339))
+		}
+		if failed {
+			exit(1)
+		}
+	},
+}
+
+var fsDuCmd = &cobra.Command{
+	Use:   "du [path|glob...]",
+	Short: "Show the disk usage of directories",
+	Long: `Add up the sizes of the files under each path. Symbolic links are not
+followed. --depth also shows the totals of subdirectories down to that many
+levels. Large trees take one round trip per directory, so 'dgx exec du' is
+faster for a whole disk.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		depth, _ := cmd.Flags().GetInt("depth")
+		if len(args) == 0 {
+			args = []string{"."}
+		}
+		c := openSFTP()
+		defer c.Close()
+
+		paths, failed := expandRemote(c, args)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SIZE\tFILES\tPATH")
+		var total, totalFiles int64
+		for _, p := range paths {
+			size, files, err := diskUsage(c, w, p, 0, depth)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				failed = true
+			}
+			total += size
+			totalFiles += files
+		}
+		if len(paths) > 1 {
+			fmt.Fprintf(w, "%s\t%d\ttotal\n", artifacts.FormatBytes(total), totalFiles)
+		}
+		w.Flush()
+		if failed {
+			exit(1)
+		}
+	},
+}
+
+// sftpConn is the sftp subsystem of an SSH connection; closing it closes the connection
+type sftpConn struct {
+	io.ReadWriteCloser
+	client *ssh.Client
+}
+
+func (c *sftpConn) Close() error {
+	c.ReadWriteCloser.Close()
+	return c.client.Close()
+}
+
+// openSFTP starts an SFTP session with the configured DGX
+func openSFTP() *sftp.Client {
+	cfg := cfgManager.Get()
+	requireFileAccess(cfg, "dgx fs")
+	client, err := ssh.NewClient(cfg)
+	if err != nil {
+		exitWithError(err)
+	}
+	conn, err := client.Subsystem("sftp")
+	if err != nil {
+		client.Close()
+		exitWithError(fmt.Errorf("%w (is the sftp Subsystem enabled in the DGX's sshd_config?)", err))
+	}
+	c, err := sftp.NewClient(&sftpConn{ReadWriteCloser: conn, client: client})
+	if err != nil {
+		client.Close()
+		exitWithError(err)
+	}
+	return c
+}
+
+// expandRemote expands the glob patterns in args on the DGX. It reports each one that
+// matches nothing and whether any did.
+func expandRemote(c *sftp.Client, args []string) ([]string, bool) {
+	var paths []string
+	failed := false
+	for _, arg := range args {
+		arg = remoteHome(arg)
+		matches, err := c.Glob(arg)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				err = fmt.Errorf("%s: no such file or directory", arg)
+			}
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			failed = true
+			continue
+		}
+		paths = append(paths, matches...)
+	}
+	return paths, failed
+}
+
+// remoteHome turns a leading ~ into a path relative to the login directory, where SFTP
+// resolves relative paths
+func remoteHome(p string) string {
+	switch {
+	case p == "~":
+		return "."
+	case strings.HasPrefix(p, "~/"):
+		return strings.TrimPrefix(p, "~/")
+	}
+	return p
+}
+
+func printListing(w io.Writer, entries []*sftp.FileInfo) {
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() {
+			name += "/"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.Mode(), artifacts.FormatBytes(e.Size()), e.ModTime().Local().Format("Jan 02 15:04"), name)
+	}
+}
+
+func catRemote(c *sftp.Client, p string) error {
+	fi, err := c.Stat(p)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return fmt.Errorf("%s is a directory", p)
+	}
+	f, err := c.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(os.Stdout, f)
+	return err
+}
+
+// tailRemote prints the last lines of p and, with follow, what is appended to it
+func tailRemote(c *sftp.Client, p string, lines int, follow bool) error {
+	fi, err := c.Stat(p)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return fmt.Errorf("%s is a directory", p)
+	}
+	f, err := c.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	size := fi.Size()
+	start, err := tailOffset(f, size, lines)
+	if err != nil {
+		return err
+	}
+	offset, err := copyRange(f, start, size)
+	if err != nil || !follow {
+		return err
+	}
+
+	loop := pollScheduler().Register(cfgManager.Get().Host, "tail", fsPollInterval)
+	defer loop.Close()
+	for {
+		time.Sleep(loop.Next())
+		fi, err := c.Stat(p)
+		if err != nil {
+			return err
+		}
+		switch {
+		case fi.Size() < offset:
+			fmt.Fprintf(os.Stderr, "%s: file truncated\n", p)
+			// A rotated file has a new handle to read from
+			f.Close()
+			if f, err = c.Open(p); err != nil {
+				return err
+			}
+			offset = 0
+		case fi.Size() == offset:
+			continue
+		}
+		if offset, err = copyRange(f, offset, fi.Size()); err != nil {
+			return err
+		}
+	}
+}
+
+// tailOffset finds where the last n lines of a file of the given size start, reading it
+// backwards in blocks. A final newline does not count as the start of a line.
+func tailOffset(f *sftp.File, size int64, n int) (int64, error) {
+	if n == 0 {
+		return size, nil
+	}
+	const block = 32 << 10
+	newlines := 0
+	end := size
+	buf := make([]byte, block)
+	for end > 0 {
+		start := end - block
+		if start < 0 {
+			start = 0
+		}
+		chunk := buf[:end-start]
+		if _, err := f.ReadAt(chunk, start); err != nil && err != io.EOF {
+			return 0, err
+		}
+		for i := len(chunk) - 1; i >= 0; i-- {
+			if chunk[i] != '\n' || start+int64(i) == size-1 {
+				continue
+			}
+			newlines++
+			if newlines == n {
+				return start + int64(i) + 1, nil
+			}
+		}
+		end = start
+	}
+	return 0, nil
+}
+
+// copyRange writes the bytes of f from start to end to stdout and returns where it stopped
+func copyRange(f *sftp.File, start, end int64) (int64, error) {
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return start, err
+	}
+	n, err := io.Copy(os.Stdout, io.LimitReader(f, end-start))
+	return start + n, err
+}
+
+// diskUsage adds up the sizes under p and prints the totals of p and, down to maxDepth,
+// its subdirectories, after their contents as du does
+func diskUsage(c *sftp.Client, w io.Writer, p string, depth, maxDepth int) (int64, int64, error) {
+	fi, err := c.Lstat(p)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !fi.IsDir() {
+		if depth == 0 {
+			fmt.Fprintf(w, "%s\t1\t%s\n", artifacts.FormatBytes(fi.Size()), p)
+		}
+		return fi.Size(), 1, nil
+	}
+
+	entries, err := c.ReadDir(p)
+	if err != nil {
+		return 0, 0, err
+	}
+	var size, files int64
+	var firstErr error
+	for _, e := range entries {
+		child := path.Join(p, e.Name())
+		if !e.IsDir() {
+			size += e.Size()
+			files++
+			continue
+		}
+		s, n, err := diskUsage(c, w, child, depth+1, maxDepth)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		size += s
+		files += n
+	}
+	if depth <= maxDepth {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", artifacts.FormatBytes(size), files, p)
+	}
+	return size, files, firstErr
+}
+
+// fileType names the type of a file as stat(1) does
+func fileType(m fs.FileMode) string {
+	switch {
+	case m.IsDir():
+		return "directory"
+	case m&fs.ModeSymlink != 0:
+		return "symbolic link"
+	case m&fs.ModeNamedPipe != 0:
+		return "fifo"
+	case m&fs.ModeSocket != 0:
+		return "socket"
+	case m&fs.ModeCharDevice != 0:
+		return "character device"
+	case m&fs.ModeDevice != 0:
+		return "block device"
+	default:
+		return "regular file"
+	}
+}
+
+var fsEditCmd = &cobra.Command{
+	Use:   "edit <path>",
+	Short: "Edit a file on the DGX in your local editor",
+	Long: `Download a file from the DGX, open it in $VISUAL or $EDITOR (vi when
+neither is set), show a diff of your changes, and write them back once you
+confirm. The file is replaced atomically through a temporary file next to it,
+keeping its mode and owner, so a crash never leaves it half written. A path
+that does not exist yet is created.
+
+If the file changed on the DGX while you were editing, the upload stops;
+--force overwrites it anyway. --sudo reads and writes as root (passwordless
+sudo is required), for files such as /etc/docker/daemon.json. Declining the
+upload keeps your edited copy and prints where it is.
+
+Examples:
+  dgx fs edit '~/compose/webui.yaml'
+  dgx fs edit --sudo /etc/docker/daemon.json`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		useSudo, _ := cmd.Flags().GetBool("sudo")
+		force, _ := cmd.Flags().GetBool("force")
+		yes, _ := cmd.Flags().GetBool("yes")
+		cfg := cfgManager.Get()
+		if cfg.ReadOnly {
+			exitWithError(exitcode.Wrap(exitcode.Usage, errors.New("'dgx fs edit' changes files, but this connection is readonly")))
+		}
+		requireFileAccess(cfg, "dgx fs edit")
+		remote := args[0]
+		if sftp.HasMeta(remote) {
+			exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("edit takes a single path, not a pattern: %s", remote)))
+		}
+
+		client, err := ssh.NewClient(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+
+		original, exists, err := readRemoteFile(client, remote, useSudo)
+		if err != nil {
+			exitWithError(err)
+		}
+		if !exists {
+			fmt.Printf("%s does not exist yet; it is created when you save\n", remote)
+		}
+
+		dir, err := os.MkdirTemp("", "dgx-edit-")
+		if err != nil {
+			exitWithError(err)
+		}
+		// The local copy keeps the remote name so editors pick the right syntax
+		local := filepath.Join(dir, path.Base(remote))
+		if err := os.WriteFile(local, original, 0600); err != nil {
+			os.RemoveAll(dir)
+			exitWithError(err)
+		}
+		if err := runEditor(local); err != nil {
+			os.RemoveAll(dir)
+			exitWithError(err)
+		}
+		edited, err := os.ReadFile(local)
+		if err != nil {
+			os.RemoveAll(dir)
+			exitWithError(err)
+		}
+		if bytes.Equal(edited, original) {
+			os.RemoveAll(dir)
+			fmt.Println("No changes")
+			return
+		}
+
+		fmt.Print(textdiff.Unified(remote, remote+" (edited)", string(original), string(edited)))
+		if !confirmCommand(cmd, fmt.Sprintf("Write the changes to %s on %s?", remote, cfg.Host), yes) {
+			fmt.Printf("Cancelled. Your edited copy is in %s\n", local)
+			exit(exitcode.Aborted)
+		}
+
+		if !force {
+			current, stillExists, err := readRemoteFile(client, remote, useSudo)
+			if err != nil {
+				fmt.Printf("Your edited copy is in %s\n", local)
+				exitWithError(err)
+			}
+			if stillExists != exists || !bytes.Equal(current, original) {
+				fmt.Printf("Your edited copy is in %s\n", local)
+				exitWithError(fmt.Errorf("%s changed on the DGX while you were editing; edit it again or pass --force to overwrite", remote))
+			}
+		}
+		if err := writeRemoteFile(client, remote, edited, useSudo); err != nil {
+			fmt.Printf("Your edited copy is in %s\n", local)
+			exitWithError(err)
+		}
+		os.RemoveAll(dir)
+		fmt.Printf("Saved %s\n", remote)
+	},
+}
+
+// Exit codes of the read script for a missing path and a directory
+const (
+	remoteMissing = 66
+	remoteIsDir   = 65
+)
+
+// readRemoteFile returns the contents of the file at p on the DGX and whether it exists
+func readRemoteFile(client *ssh.Client, p string, useSudo bool) ([]byte, bool, error) {
+	q := ssh.QuoteRemotePath(p)
+	cat := "cat"
+	if useSudo {
+		cat = "sudo -n cat"
+	}
+	script := fmt.Sprintf("[ -d %[1]s ] && exit %[2]d; [ -e %[1]s ] || [ -L %[1]s ] || exit %[3]d; %[4]s -- %[1]s", q, remoteIsDir, remoteMissing, cat)
+	var stdout, stderr bytes.Buffer
+	err := client.Stream(script, nil, &stdout, &stderr)
+	if status, ok := ssh.RemoteExitStatus(err); ok {
+		switch status {
+		case remoteMissing:
+			return nil, false, nil
+		case remoteIsDir:
+			return nil, false, exitcode.Wrap(exitcode.Usage, fmt.Errorf("%s is a directory", p))
+		}
+	}
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if !useSudo && strings.Contains(msg, "Permission denied") {
+			msg += " (use --sudo to read it as root)"
+		}
+		if msg == "" {
+			return nil, false, fmt.Errorf("failed to read %s: %w", p, err)
+		}
+		return nil, false, fmt.Errorf("failed to read %s: %s", p, msg)
+	}
+	return stdout.Bytes(), true, nil
+}
+
+// writeRemoteFile replaces the file at p with data through a temporary file in the same
+// directory, copying the old file's mode and owner. A symbolic link is followed, so the
+// file it points to is replaced rather than the link.
+func writeRemoteFile(client *ssh.Client, p string, data []byte, useSudo bool) error {
+	sudo := ""
+	if useSudo {
+		sudo = "sudo -n "
+	}
+	script := fmt.Sprintf(`set -e
+f=$(readlink -f -- %[1]s)
+tmp=$(%[2]smktemp "$(dirname -- "$f")/.$(basename -- "$f").dgx-edit.XXXXXX")
+trap '%[2]srm -f "$tmp"' EXIT
+%[2]stee "$tmp" >/dev/null
+if [ -e "$f" ]; then
+  %[2]schmod --reference="$f" "$tmp"
+  %[2]schown --reference="$f" "$tmp" 2>/dev/null || true
+else
+  %[2]schmod 0644 "$tmp"
+fi
+%[2]smv -f "$tmp" "$f"`, ssh.QuoteRemotePath(p), sudo)
+	var stderr bytes.Buffer
+	if err := client.Stream(script, bytes.NewReader(data), nil, &stderr); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			if !useSudo && strings.Contains(msg, "Permission denied") {
+				msg += " (use --sudo to write it as root)"
+			}
+			return fmt.Errorf("failed to write %s: %s", p, msg)
+		}
+		return fmt.Errorf("failed to write %s: %w", p, err)
+	}
+	return nil
+}
+
+// runEditor opens file in $VISUAL or $EDITOR, which may include arguments such as
+// "code --wait"
+func runEditor(file string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	c := exec.Command("sh", "-c", editor+` "$1"`, "sh", file)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("editor %s failed: %w", editor, err)
+	}
+	return nil
+}
+
+func init() {
+	fsLsCmd.Flags().BoolP("all", "a", false, "Include entries starting with a dot")
+	fsTailCmd.Flags().IntP("lines", "n", 10, "Number of lines to print")
+	fsTailCmd.Flags().BoolP("follow", "f", false, "Keep printing data appended to the file")
+	fsDuCmd.Flags().IntP("depth", "d", 0, "Also show subdirectory totals down to this depth")
+	fsEditCmd.Flags().Bool("sudo", false, "Read and write the file as root")
+	fsEditCmd.Flags().Bool("force", false, "Overwrite the file even if it changed on the DGX meanwhile")
+	fsEditCmd.Flags().Bool("yes", false, "Write the changes without asking")
+
+	fsCmd.AddCommand(fsLsCmd)
+	fsCmd.AddCommand(fsCatCmd)
+	fsCmd.AddCommand(fsTailCmd)
+	fsCmd.AddCommand(fsStatCmd)
+	fsCmd.AddCommand(fsDuCmd)
+	fsCmd.AddCommand(fsEditCmd)
+	rootCmd.AddCommand(fsCmd)
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/gitsync"
+	"github.com/weatherman/dgx-manager/internal/redact"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/tracking"
+)
+
+// pushRunCommand returns the remote command line for the arguments after --. A single
+// argument is taken as a shell snippet so pipes and && work unquoted.
+func pushRunCommand(command []string) string {
+	switch len(command) {
+	case 0:
+		return ""
+	case 1:
+		return command[0]
+	}
+	quoted := make([]string, len(command))
+	for i, arg := range command {
+		quoted[i] = ssh.ShellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// git command
+var gitCmd = &cobra.Command{
+	Use:   "git",
+	Short: "Push local git checkouts to the DGX",
+}
+
+var gitPushRunCmd = &cobra.Command{
+	Use:   "push-run [local-dir] [-- command...]",
+	Short: "Push the local checkout to the DGX and optionally run a command there",
+	Long: `Push the working tree of a local git checkout to a repository on the DGX, check it
+out there, and run a command in it.
+
+Uncommitted changes and untracked files are included unless .gitignore excludes
+them; the local index and branches are not touched. Only changed objects go over
+the wire, so repeated runs are fast. On the DGX the snapshot is checked out
+detached, files deleted locally are removed, and untracked outputs (checkpoints,
+logs) are left in place. Local edits made on the DGX are overwritten.
+
+The repository defaults to ~/src/<checkout name> and is created on first use.
+With --bare the DGX gets a bare repository and nothing is checked out.
+
+--track wandb|mlflow exports the tracker's credentials from ~/.config/dgx/env.sh
+(see 'dgx env wandb' and 'dgx env mlflow') into the command's environment and
+prints the run URLs the job logs when it finishes. It works for anything the
+command starts, torchrun included.
+
+Examples:
+  dgx git push-run
+  dgx git push-run -- python train.py --epochs 1
+  dgx git push-run --track wandb -- torchrun --nproc-per-node 1 train.py
+  dgx git push-run ../trainer --dest /workspace/trainer -- ./run.sh
+  dgx git push-run --committed --bare --dest ~/repos/trainer.git`,
+	Run: func(cmd *cobra.Command, args []string) {
+		dest, _ := cmd.Flags().GetString("dest")
+		bare, _ := cmd.Flags().GetBool("bare")
+		committed, _ := cmd.Flags().GetBool("committed")
+		track, _ := cmd.Flags().GetString("track")
+
+		var command []string
+		if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+			args, command = args[:dash], args[dash:]
+		}
+		if len(args) > 1 {
+			fmt.Fprintln(os.Stderr, "Error: expected at most one local directory; put the command after --")
+			exit(exitcode.Usage)
+		}
+		if bare && len(command) > 0 {
+			fmt.Fprintln(os.Stderr, "Error: --bare does not check anything out, so no command can run")
+			exit(exitcode.Usage)
+		}
+		var tracker tracking.Backend
+		if track != "" {
+			if len(command) == 0 {
+				fmt.Fprintln(os.Stderr, "Error: --track needs a command to run after --")
+				exit(exitcode.Usage)
+			}
+			var err error
+			if tracker, err = tracking.Lookup(track); err != nil {
+				exitWithError(exitcode.Wrap(exitcode.Usage, err))
+			}
+		}
+
+		// The push writes the checkout wherever --dest points
+		requireFileAccess(cfgManager.Get(), "dgx git push-run")
+		line := pushRunCommand(command)
+
+		localDir := "."
+		if len(args) == 1 {
+			localDir = args[0]
+		}
+		repo, err := gitsync.Open(localDir)
+		if err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Usage, err))
+		}
+		if dest == "" {
+			dest = "src/" + repo.Name()
+		}
+		dest = strings.TrimPrefix(dest, "~/")
+
+		var commit string
+		if committed {
+			commit, err = repo.Head()
+		} else {
+			commit, err = repo.Snapshot()
+		}
+		if err != nil {
+			exitWithError(err)
+		}
+
+		cfg := cfgManager.Get()
+		client, err := ssh.NewClient(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+
+		if track != "" {
+			// Fail on missing credentials before pushing anything
+			if _, err := client.Execute(tracker.Prelude()); err != nil {
+				exitWithError(exitcode.Wrap(exitcode.Config, err))
+			}
+		}
+
+		remoteDir := ssh.QuoteRemotePath(dest)
+		initRepo := "git init -q"
+		if bare {
+			initRepo = "git init -q --bare"
+		}
+		prepare := fmt.Sprintf("command -v git >/dev/null || { echo 'git is not installed on the DGX' >&2; exit 1; }; mkdir -p %[1]s && cd %[1]s && { git rev-parse --git-dir >/dev/null 2>&1 || %[2]s; }", remoteDir, initRepo)
+		if _, err := client.Execute(prepare); err != nil {
+			exitWithError(fmt.Errorf("failed to prepare %s on the DGX: %w", dest, err))
+		}
+
+		fmt.Printf("Pushing %s (%s) to %s:%s...\n", repo.Name(), commit[:12], cfg.Host, dest)
+		url := gitsync.RemoteURL(cfg.User, cfg.Host, cfg.Port, dest)
+		if err := repo.Push(url, commit, ssh.SSHCommand(cfg), ssh.AgentEnv(cfg)); err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Connection, err))
+		}
+		if bare {
+			fmt.Printf("Pushed to %s in %s\n", gitsync.SnapshotRef, dest)
+			return
+		}
+
+		checkout := fmt.Sprintf("cd %s && git -c advice.detachedHead=false checkout -q -f --detach %s", remoteDir, commit)
+		if _, err := client.Execute(checkout); err != nil {
+			exitWithError(fmt.Errorf("failed to check out %s on the DGX: %w", commit[:12], err))
+		}
+		fmt.Printf("Checked out in %s\n", dest)
+
+		if len(command) == 0 {
+			return
+		}
+		fmt.Printf("Running: %s\n", strings.Join(command, " "))
+		if track == "" {
+			if err := client.RunInteractive(fmt.Sprintf("cd %s && %s", remoteDir, line)); err != nil {
+				exitWithError(err)
+			}
+			return
+		}
+
+		// The prelude exports the tracker's credentials; keep them off the screen
+		redactRemoteSecrets(client)
+		stdout := redact.NewWriter(os.Stdout, redact.Default())
+		stderr := redact.NewWriter(os.Stderr, redact.Default())
+		watcher := &tracking.Watcher{}
+		err = client.Stream(fmt.Sprintf("bash -lc %s", ssh.ShellQuote(fmt.Sprintf("%s; cd %s && %s", tracker.Prelude(), remoteDir, line))),
+			os.Stdin, io.MultiWriter(stdout, watcher), io.MultiWriter(stderr, watcher))
+		stdout.Flush()
+		stderr.Flush()
+		if urls := watcher.URLs(); len(urls) > 0 {
+			fmt.Printf("\n%s runs:\n", tracker.Name)
+			for _, u := range urls {
+				fmt.Printf("  %s\n", u)
+			}
+		} else {
+			fmt.Printf("\nNo %s run URL appeared in the output.\n", tracker.Name)
+		}
+		if err != nil {
+			if status, ok := ssh.RemoteExitStatus(err); ok {
+				exit(status)
+			}
+			exitWithError(err)
+		}
+	},
+}
+
+func init() {
+	gitPushRunCmd.Flags().String("dest", "", "Repository path on the DGX (default ~/src/<checkout name>)")
+	gitPushRunCmd.Flags().Bool("bare", false, "Push to a bare repository without checking out")
+	gitPushRunCmd.Flags().Bool("committed", false, "Push HEAD only, leaving out uncommitted changes")
+	gitPushRunCmd.Flags().String("track", "", "Report the command to an experiment tracker: wandb or mlflow")
+	gitCmd.AddCommand(gitPushRunCmd)
+	rootCmd.AddCommand(gitCmd)
+}
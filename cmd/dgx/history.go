@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/config"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/history"
+	"github.com/weatherman/dgx-manager/internal/redact"
+	"github.com/weatherman/dgx-manager/internal/ui"
+)
+
+// invocation is this process's history entry; nil when it is not recorded
+var invocation *history.Invocation
+
+// beginHistory starts recording this invocation. Completion requests, hidden helpers such
+// as __rsh, dgx history itself (a re-run records its own entry), and fan-out children are
+// not recorded. Arguments that look like secrets are masked and the entry marked, since
+// they cannot be re-run as given.
+func beginHistory() {
+	if os.Getenv(history.EnvDisable) != "" || len(os.Args) < 2 {
+		return
+	}
+	if first := os.Args[1]; strings.HasPrefix(first, "__") || first == "history" || first == "completion" {
+		return
+	}
+	path, err := history.DefaultPath()
+	if err != nil {
+		return
+	}
+	args, redacted := redactArgs(os.Args[1:])
+	invocation = history.NewStore(path).Begin(args, redacted)
+}
+
+// redactArgs masks secret arguments, including the value after a flag such as --api-key
+func redactArgs(args []string) ([]string, bool) {
+	masked := make([]string, len(args))
+	redacted := false
+	for i, arg := range args {
+		masked[i] = redact.Default().String(arg)
+		if prev := i - 1; prev >= 0 && strings.HasPrefix(args[prev], "--") && !strings.Contains(args[prev], "=") {
+			if pair := args[prev] + "=" + arg; redact.Default().String(pair) != pair {
+				masked[i] = redact.Mask
+			}
+		}
+		if masked[i] != arg {
+			redacted = true
+		}
+	}
+	return masked, redacted
+}
+
+// startHistory writes the entry once the command and its target host are resolved
+func startHistory(cmd *cobra.Command) {
+	cfg := cfgManager.Get()
+	invocation.Start(cmd.CommandPath(), cfg.Host, cfg.ActiveProfile)
+}
+
+// exit records the outcome in the history and exits with code
+func exit(code int) {
+	invocation.Finish(code)
+	os.Exit(code)
+}
+
+// history command
+var historyCmd = &cobra.Command{
+	Use:   "history [!N]",
+	Short: "List recent dgx commands and re-run one",
+	Long: `List the dgx commands run on this machine, newest last, with the DGX each one
+targeted and how it ended. The last 1000 are kept in ~/.config/dgx/history.jsonl.
+
+Re-run an entry with 'dgx history !N' (quote it, or most shells expand the !) or
+'dgx history rerun N'. The command runs with the same arguments, from the same
+directory, against the same profile and host, even if the active profile has
+changed since. !! re-runs the last command and !-N the Nth from the end; --failed
+picks the last one that failed, e.g. a playbook that stopped on a flaky step.
+
+Commands whose arguments contained secrets are recorded with them masked and
+cannot be re-run.
+
+Examples:
+  dgx history
+  dgx history --failed
+  dgx history '!42'
+  dgx history rerun --failed`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 1 {
+			if !strings.HasPrefix(args[0], "!") {
+				exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("unknown argument %q; use !N to re-run entry N", args[0])))
+			}
+			rerunEntry(loadHistory(), args[0])
+			return
+		}
+
+		limit, _ := cmd.Flags().GetInt("limit")
+		failed, _ := cmd.Flags().GetBool("failed")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		var entries []history.Entry
+		for _, e := range loadHistory() {
+			if failed && !e.Failed() {
+				continue
+			}
+			entries = append(entries, e)
+		}
+		if limit > 0 && len(entries) > limit {
+			entries = entries[len(entries)-limit:]
+		}
+
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if entries == nil {
+				entries = []history.Entry{}
+			}
+			if err := enc.Encode(entries); err != nil {
+				exitWithError(err)
+			}
+			return
+		}
+		if len(entries) == 0 {
+			fmt.Println("No commands recorded")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "#\tTIME\tHOST\tSTATUS\tDURATION\tCOMMAND")
+		for _, e := range entries {
+			duration := "-"
+			if e.Done {
+				duration = e.Duration.Round(100 * time.Millisecond).String()
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", e.N, e.Time.Local().Format("Jan 02 15:04"), ui.OrDash(e.Host), e.Status(), duration, history.CommandLine(e.Args))
+		}
+		w.Flush()
+	},
+}
+
+var historyRerunCmd = &cobra.Command{
+	Use:   "rerun [N|!N|!!|!-N]",
+	Short: "Re-run a command from the history with identical arguments",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		entries := loadHistory()
+		if failed, _ := cmd.Flags().GetBool("failed"); failed {
+			if len(args) > 0 {
+				exitWithError(exitcode.Wrap(exitcode.Usage, errors.New("--failed cannot be combined with an entry number")))
+			}
+			e, ok := history.LastFailed(entries)
+			if !ok {
+				exitWithError(errors.New("no failed command in the history"))
+			}
+			rerun(e)
+			return
+		}
+		if len(args) == 0 {
+			exitWithError(exitcode.Wrap(exitcode.Usage, errors.New("an entry number or --failed is required")))
+		}
+		ref := args[0]
+		if !strings.HasPrefix(ref, "!") {
+			ref = "!" + ref
+		}
+		rerunEntry(entries, ref)
+	},
+}
+
+func loadHistory() []history.Entry {
+	path, err := history.DefaultPath()
+	if err != nil {
+		exitWithError(err)
+	}
+	entries, err := history.NewStore(path).Load()
+	if err != nil {
+		exitWithError(err)
+	}
+	return entries
+}
+
+// rerunEntry re-runs the entry named by a !N, !!, or !-N reference
+func rerunEntry(entries []history.Entry, ref string) {
+	if len(entries) == 0 {
+		exitWithError(errors.New("no commands recorded"))
+	}
+	var e history.Entry
+	var ok bool
+	switch spec := strings.TrimPrefix(ref, "!"); {
+	case spec == "!":
+		e, ok = entries[len(entries)-1], true
+	case strings.HasPrefix(spec, "-"):
+		back, err := strconv.Atoi(spec[1:])
+		if err != nil || back < 1 {
+			exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("invalid history reference %q", ref)))
+		}
+		if back <= len(entries) {
+			e, ok = entries[len(entries)-back], true
+		}
+	default:
+		n, err := strconv.Atoi(spec)
+		if err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("invalid history reference %q", ref)))
+		}
+		e, ok = history.Find(entries, n)
+	}
+	if !ok {
+		exitWithError(fmt.Errorf("no history entry %s", ref))
+	}
+	rerun(e)
+}
+
+// rerun runs e's arguments again in a dgx child process from e's directory, pinned to the
+// profile and host it targeted, and exits with the child's status
+func rerun(e history.Entry) {
+	if e.Redacted {
+		exitWithError(fmt.Errorf("entry %d had secrets in its arguments, which were not recorded; run it again by hand", e.N))
+	}
+	fmt.Fprintf(os.Stderr, "Re-running #%d: %s\n", e.N, history.CommandLine(e.Args))
+	child := dgxCommand(e.Args)
+	// Flags in the arguments still take precedence over these
+	child.Env = os.Environ()
+	if e.Profile != "" {
+		child.Env = append(child.Env, config.EnvProfile+"="+e.Profile)
+	}
+	if e.Host != "" {
+		child.Env = append(child.Env, config.EnvHost+"="+e.Host)
+	}
+	if info, err := os.Stat(e.Dir); err == nil && info.IsDir() {
+		child.Dir = e.Dir
+	} else if e.Dir != "" {
+		fmt.Fprintf(os.Stderr, "Warning: %s no longer exists; running from the current directory\n", e.Dir)
+	}
+
+	runForeground(child)
+}
+
+// dgxCommand returns a dgx child process for args, attached to this terminal
+func dgxCommand(args []string) *exec.Cmd {
+	exe, err := os.Executable()
+	if err != nil {
+		exitWithError(fmt.Errorf("failed to locate the dgx executable: %w", err))
+	}
+	child := exec.Command(exe, args...)
+	child.Stdin, child.Stdout, child.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return child
+}
+
+// runForeground runs a dgx child process, exiting with its status when it fails
+func runForeground(child *exec.Cmd) {
+	if err := child.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			exit(exitErr.ExitCode())
+		}
+		exitWithError(err)
+	}
+}
+
+func init() {
+	historyCmd.Flags().IntP("limit", "n", 20, "Show at most this many entries (0 for all)")
+	historyCmd.Flags().Bool("failed", false, "Show only commands that exited non-zero")
+	historyCmd.Flags().Bool("json", false, "Print entries as JSON")
+	historyRerunCmd.Flags().Bool("failed", false, "Re-run the last command that failed")
+
+	historyCmd.AddCommand(historyRerunCmd)
+	rootCmd.AddCommand(historyCmd)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/artifacts"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/transfer"
+	"github.com/weatherman/dgx-manager/internal/ui"
+	"golang.org/x/term"
+)
+
+// images command
+var imagesCmd = &cobra.Command{
+	Use:   "images",
+	Short: "Move docker images between Sparks",
+}
+
+var imagesCopyCmd = &cobra.Command{
+	Use:   "copy <image>... --to <target>",
+	Short: "Copy docker images from one Spark to another over SSH",
+	Long: `Copy docker images from the DGX (or --from) to another Spark (--to) without a
+registry: docker save on one side is streamed over SSH into docker load on the
+other, zstd-compressed in transit when both have zstd. Targets are profile names,
+inventory names, or host / user@host, connected to as for dgx fleet.
+
+By default the stream is relayed through this machine, which shows its progress.
+--direct has the source Spark send to the other one itself, which is much faster
+over the ConnectX link between a pair of Sparks: the destination must then be
+reachable from the source with the keys in its ~/.ssh, so give --to the
+destination's address on that link.
+
+Images the destination already has (the same image ID) are skipped unless
+--force.
+
+Examples:
+  dgx images copy trainer:dev --to spark-2
+  dgx images copy vllm/vllm-openai:latest nvcr.io/nvidia/pytorch:25.09-py3 --to spark-2
+  dgx images copy trainer:dev --from spark-1 --to spark-2
+  dgx images copy trainer:dev --to 192.168.100.11 --direct --no-compress`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		toSpec, _ := cmd.Flags().GetString("to")
+		fromSpec, _ := cmd.Flags().GetString("from")
+		direct, _ := cmd.Flags().GetBool("direct")
+		noCompress, _ := cmd.Flags().GetBool("no-compress")
+		force, _ := cmd.Flags().GetBool("force")
+
+		if toSpec == "" {
+			exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("--to is required")))
+		}
+		from := fleetTarget{Name: cfgManager.Get().Host, Config: cfgManager.Get()}
+		if fromSpec != "" {
+			from = resolveTargets(cmd, []string{fromSpec})[0]
+		}
+		to := resolveTargets(cmd, []string{toSpec})[0]
+		if from.Config.Host == to.Config.Host && from.Config.Port == to.Config.Port {
+			exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("the source and destination are both %s", to.Config.Host)))
+		}
+
+		src, err := ssh.NewClient(from.Config)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer src.Close()
+		dst, err := ssh.NewClient(to.Config)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer dst.Close()
+
+		images := inspectImages(src, from.Name, args)
+		for _, img := range images {
+			if img.ID == "" {
+				exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("%s has no image %s", from.Name, img.Ref)))
+			}
+		}
+		if !force {
+			existing := inspectImages(dst, to.Name, args)
+			var missing []transfer.Image
+			for i, img := range images {
+				if existing[i].ID == img.ID {
+					fmt.Printf("%s is already on %s\n", img.Ref, to.Name)
+					continue
+				}
+				missing = append(missing, img)
+			}
+			images = missing
+		}
+		if len(images) == 0 {
+			return
+		}
+		refs := make([]string, len(images))
+		var size int64
+		for i, img := range images {
+			refs[i] = img.Ref
+			size += img.Size
+		}
+
+		compression, inTransit := transfer.CompressNone, ""
+		if !noCompress {
+			_, srcErr := src.Execute("command -v zstd")
+			_, dstErr := dst.Execute("command -v zstd")
+			switch {
+			case srcErr != nil:
+				fmt.Fprintf(os.Stderr, "Warning: sending uncompressed: zstd is not installed on %s (sudo apt-get install zstd)\n", from.Name)
+			case dstErr != nil:
+				fmt.Fprintf(os.Stderr, "Warning: sending uncompressed: zstd is not installed on %s (sudo apt-get install zstd)\n", to.Name)
+			default:
+				compression, inTransit = transfer.CompressZstd, ", zstd in transit"
+			}
+		}
+
+		fmt.Fprintf(os.Stderr, "Copying %s (%s) from %s to %s%s\n", strings.Join(refs, ", "), artifacts.FormatBytes(size), from.Name, to.Name, inTransit)
+		start := time.Now()
+		if direct {
+			dest := fmt.Sprintf("%s@%s", to.Config.User, to.Config.Host)
+			script := fmt.Sprintf("%s | ssh -o BatchMode=yes -p %d %s %s",
+				transfer.SaveCommand(refs, compression), to.Config.Port, ssh.ShellQuote(dest), ssh.ShellQuote(transfer.LoadCommand(compression)))
+			if err := src.Stream("bash -o pipefail -c "+ssh.ShellQuote(script), nil, os.Stdout, os.Stderr); err != nil {
+				exitWithError(err)
+			}
+			fmt.Fprintf(os.Stderr, "Copied to %s in %s\n", to.Name, time.Since(start).Round(time.Second))
+			return
+		}
+
+		// Only an uncompressed stream can be measured against the image size
+		total := size
+		if compression != transfer.CompressNone {
+			total = 0
+		}
+		// Plain mode reports progress as lines, even into a log file
+		var out io.Writer
+		if term.IsTerminal(int(os.Stderr.Fd())) || ui.Plain() {
+			out = os.Stderr
+		}
+		meter := transfer.NewMeter(out, total, ui.Live(os.Stderr))
+		pr, pw := io.Pipe()
+		saved := make(chan error, 1)
+		go func() {
+			err := src.Stream(transfer.SaveCommand(refs, compression), nil, pw, os.Stderr)
+			pw.CloseWithError(err)
+			saved <- err
+		}()
+		err = dst.Stream(transfer.LoadCommand(compression), meter.Reader(pr), os.Stdout, os.Stderr)
+		pr.CloseWithError(io.ErrClosedPipe)
+		meter.Stop()
+		if saveErr := <-saved; saveErr != nil {
+			exitWithError(fmt.Errorf("docker save on %s failed: %w", from.Name, saveErr))
+		}
+		if err != nil {
+			exitWithError(fmt.Errorf("docker load on %s failed: %w", to.Name, err))
+		}
+		fmt.Fprintf(os.Stderr, "Copied to %s (%s sent%s)\n", to.Name, transferSummary(meter.Bytes(), time.Since(start)), inTransit)
+	},
+}
+
+// inspectImages looks refs up on a Spark
+func inspectImages(client *ssh.Client, name string, refs []string) []transfer.Image {
+	output, err := client.Execute(transfer.InspectImagesCommand(refs))
+	if err != nil {
+		exitWithError(fmt.Errorf("failed to list images on %s: %w", name, err))
+	}
+	images, err := transfer.ParseImages(refs, output)
+	if err != nil {
+		exitWithError(err)
+	}
+	return images
+}
+
+func init() {
+	imagesCopyCmd.Flags().String("to", "", "Destination Spark: profile, inventory name, or host / user@host")
+	imagesCopyCmd.Flags().String("from", "", "Source Spark (default the configured DGX)")
+	imagesCopyCmd.Flags().Bool("direct", false, "Send from the source Spark straight to the destination instead of through this machine")
+	imagesCopyCmd.Flags().Bool("no-compress", false, "Send uncompressed, for links faster than zstd")
+	imagesCopyCmd.Flags().Bool("force", false, "Copy images the destination already has")
+
+	imagesCmd.AddCommand(imagesCopyCmd)
+	rootCmd.AddCommand(imagesCmd)
+}
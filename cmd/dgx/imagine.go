@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/sdgen"
+	"github.com/weatherman/dgx-manager/internal/serve"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/state"
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// imagine command
+var imagineCmd = &cobra.Command{
+	Use:   "imagine <prompt>",
+	Short: "Generate an image on the DGX",
+	Long: `Generate an image from a text prompt with the server deployed by
+'dgx run sdgen deploy' (ComfyUI or sd-webui) and save it locally as PNG.
+
+The server is reached over SSH, so no tunnel is needed; --url talks to one
+directly instead, such as the tunnel opened by deploy. Without --seed a random
+seed is used and printed, so a result can be reproduced.
+
+Examples:
+  dgx imagine "a lighthouse at dusk, oil painting" -o out.png
+  dgx imagine "isometric pixel-art city" --steps 40 --seed 42 --width 1280 --height 768
+  dgx imagine "a red fox in snow" --negative "blurry, text" --url http://localhost:8188`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+		url, _ := cmd.Flags().GetString("url")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		req := sdgen.Request{Prompt: strings.Join(args, " ")}
+		req.Negative, _ = cmd.Flags().GetString("negative")
+		req.Width, _ = cmd.Flags().GetInt("width")
+		req.Height, _ = cmd.Flags().GetInt("height")
+		req.Steps, _ = cmd.Flags().GetInt("steps")
+		req.CFG, _ = cmd.Flags().GetFloat64("cfg")
+		req.Seed, _ = cmd.Flags().GetInt64("seed")
+		req.Checkpoint, _ = cmd.Flags().GetString("checkpoint")
+		if req.Width%8 != 0 || req.Height%8 != 0 || req.Width < 0 || req.Height < 0 {
+			exitWithError(exitcode.Wrap(exitcode.Usage, errors.New("--width and --height must be multiples of 8")))
+		}
+		if req.Seed < 0 {
+			req.Seed = rand.Int63n(1 << 32)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		client, cleanup, err := imageClient(ctx, cfgManager.Get(), url)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer cleanup()
+
+		fmt.Fprintf(os.Stderr, "Generating (seed %d)...\n", req.Seed)
+		start := time.Now()
+		image, err := client.Generate(ctx, req)
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("no image after %v (raise --timeout?)", timeout)
+		}
+		if err != nil {
+			exitWithError(err)
+		}
+		if output == "-" {
+			os.Stdout.Write(image)
+			return
+		}
+		if err := os.WriteFile(output, image, 0o644); err != nil {
+			exitWithError(fmt.Errorf("failed to save the image: %w", err))
+		}
+		fmt.Fprintf(os.Stderr, "Saved %s in %v\n", output, time.Since(start).Round(100*time.Millisecond))
+	},
+}
+
+// imageClient connects to the image server at url, or over SSH to the one deployed on
+// the DGX
+func imageClient(ctx context.Context, cfg *types.Config, url string) (*sdgen.Client, func(), error) {
+	if url != "" {
+		ui, err := sdgen.Detect(ctx, http.DefaultClient, url)
+		if err != nil {
+			return nil, nil, err
+		}
+		return sdgen.NewClient(http.DefaultClient, url, ui), func() {}, nil
+	}
+
+	var deployment sdgen.Deployment
+	store, err := state.DefaultStore()
+	if err != nil {
+		return nil, nil, err
+	}
+	found, err := store.Load(sdgen.StateKey(cfg.Host), &deployment)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !found {
+		return nil, nil, exitcode.Wrap(exitcode.Usage, fmt.Errorf("no image server deployed on %s; run 'dgx run sdgen deploy' first, or pass --url", cfg.Host))
+	}
+
+	client, err := ssh.NewClient(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	httpClient := &http.Client{Transport: serve.NewTransport(map[string]serve.DialFunc{"": client.Dial})}
+	base := serve.BackendURL(types.Backend{Port: deployment.Port}, "")
+	return sdgen.NewClient(httpClient, base, deployment.UI), func() { client.Close() }, nil
+}
+
+func init() {
+	imagineCmd.Flags().StringP("output", "o", "image.png", "File to save the PNG to (- for stdout)")
+	imagineCmd.Flags().String("negative", "", "Negative prompt: what the image should not contain")
+	imagineCmd.Flags().Int("width", sdgen.DefaultSize, "Image width in pixels (a multiple of 8)")
+	imagineCmd.Flags().Int("height", sdgen.DefaultSize, "Image height in pixels (a multiple of 8)")
+	imagineCmd.Flags().Int("steps", sdgen.DefaultSteps, "Sampling steps")
+	imagineCmd.Flags().Float64("cfg", sdgen.DefaultCFG, "Classifier-free guidance scale")
+	imagineCmd.Flags().Int64("seed", -1, "Seed for reproducible images (default random)")
+	imagineCmd.Flags().String("checkpoint", "", "Checkpoint file to use (default: the server's first or current one)")
+	imagineCmd.Flags().String("url", "", "Image server base URL to use instead of SSH (e.g. http://localhost:8188)")
+	imagineCmd.Flags().Duration("timeout", 10*time.Minute, "Give up after this long")
+	rootCmd.AddCommand(imagineCmd)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/weatherman/dgx-manager/internal/config"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/fleet"
+	"github.com/weatherman/dgx-manager/internal/history"
+)
+
+// fanOutFlags select inventory hosts; they are consumed by the parent process and never
+// forwarded to the per-host children
+var fanOutFlags = []string{"group", "tag", "inventory", "parallel", "export"}
+
+// groupSelected reports whether --group or --tag asks for inventory hosts
+func groupSelected(cmd *cobra.Command) bool {
+	groups, _ := cmd.Flags().GetStringSlice("group")
+	tags, _ := cmd.Flags().GetStringSlice("tag")
+	return len(groups) > 0 || len(tags) > 0
+}
+
+// inventoryPath returns --inventory or the default hosts.yaml
+func inventoryPath(cmd *cobra.Command) string {
+	path, _ := cmd.Flags().GetString("inventory")
+	if path == "" {
+		var err error
+		if path, err = config.DefaultInventoryPath(); err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Config, err))
+		}
+	}
+	return path
+}
+
+// loadInventory reads the inventory file
+func loadInventory(cmd *cobra.Command) *config.Inventory {
+	inv, err := config.LoadInventory(inventoryPath(cmd))
+	if err != nil {
+		exitWithError(exitcode.Wrap(exitcode.Config, err))
+	}
+	return inv
+}
+
+// inventoryTargets returns the inventory hosts chosen by --group and --tag
+func inventoryTargets(cmd *cobra.Command) []fleetTarget {
+	groups, _ := cmd.Flags().GetStringSlice("group")
+	tags, _ := cmd.Flags().GetStringSlice("tag")
+	inv := loadInventory(cmd)
+	hosts, err := inv.Select(groups, tags)
+	if err != nil {
+		exitWithError(exitcode.Wrap(exitcode.Usage, err))
+	}
+	if len(hosts) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: no inventory hosts match the --group/--tag selection")
+		exit(exitcode.Usage)
+	}
+	targets := make([]fleetTarget, len(hosts))
+	for i, h := range hosts {
+		targets[i] = fleetTarget{Name: h.Name, Config: inv.Config(cfgManager.Get(), h)}
+	}
+	return targets
+}
+
+// fanOutToGroup re-runs the current command once per selected inventory host, each in its
+// own dgx process pointed at that host, through the fleet worker pool. It does not return.
+func fanOutToGroup(cmd *cobra.Command) {
+	for _, name := range []string{"host", "profile"} {
+		if value, _ := cmd.Flags().GetString(name); value != "" {
+			fmt.Fprintf(os.Stderr, "Error: --%s cannot be combined with --group or --tag\n", name)
+			exit(exitcode.Usage)
+		}
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		exitWithError(fmt.Errorf("failed to locate the dgx executable: %w", err))
+	}
+
+	export := exportPath(cmd)
+	rest := stripFlags(os.Args[1:], fanOutFlags)
+	label := strings.TrimPrefix(cmd.CommandPath(), "dgx ")
+	targets := inventoryTargets(cmd)
+	jobs := make([]fleet.Job, len(targets))
+	rows := make([]fleet.ReportRow, len(targets))
+	for i, t := range targets {
+		childArgs := append(connectionArgs(t.Config), rest...)
+		jobs[i] = fleet.Job{Host: t.Name, Label: label, Mutating: true, Run: func(ctx context.Context) (string, error) {
+			return runChild(ctx, exe, childArgs)
+		}}
+		if export != "" {
+			jobs[i] = withReport(jobs[i], t.Config, &rows[i])
+		}
+	}
+	results := runFleet(cmd, jobs)
+	code := reportFleet(results)
+	if export != "" {
+		exportFleet(export, rows, results)
+	}
+	exit(code)
+}
+
+// runChild runs a dgx child process and returns its combined output, tagging failures with
+// the child's exit code
+func runChild(ctx context.Context, exe string, args []string) (string, error) {
+	child := exec.CommandContext(ctx, exe, args...)
+	// The parent's history entry covers the whole fan-out
+	child.Env = append(os.Environ(), history.EnvDisable+"=1")
+	output, err := child.CombinedOutput()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		err = exitcode.Wrap(exitErr.ExitCode(), fmt.Errorf("exit status %d", exitErr.ExitCode()))
+	}
+	return string(output), err
+}
+
+// stripFlags removes --name value and --name=value for each of names, stopping at "--"
+func stripFlags(args []string, names []string) []string {
+	kept := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return append(kept, args[i:]...)
+		}
+		matched := false
+		for _, name := range names {
+			if arg == "--"+name {
+				i++ // skip the value
+				matched = true
+				break
+			}
+			if strings.HasPrefix(arg, "--"+name+"=") {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			kept = append(kept, arg)
+		}
+	}
+	return kept
+}
+
+// takeRootFlags parses root persistent flags at the front of args for commands that
+// disable flag parsing, such as 'dgx run', and returns the remaining args
+func takeRootFlags(cmd *cobra.Command, args []string) ([]string, error) {
+	for len(args) > 0 && strings.HasPrefix(args[0], "--") && args[0] != "--" {
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(args[0], "--"), "=")
+		flag := rootCmd.PersistentFlags().Lookup(name)
+		if flag == nil {
+			break
+		}
+		args = args[1:]
+		if !hasValue {
+			if flag.Value.Type() == "bool" {
+				value = "true"
+			} else {
+				if len(args) == 0 {
+					return nil, fmt.Errorf("flag needs an argument: --%s", name)
+				}
+				value, args = args[0], args[1:]
+			}
+		}
+		if err := cmd.Flags().Set(name, value); err != nil {
+			return nil, fmt.Errorf("invalid argument %q for --%s: %w", value, name, err)
+		}
+	}
+	return args, nil
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringSlice("group", nil, "Run on every inventory host in these groups ('all' for every host; see hosts.yaml)")
+	rootCmd.PersistentFlags().StringSlice("tag", nil, "Run on inventory hosts carrying all of these tags")
+	rootCmd.PersistentFlags().String("inventory", "", "Inventory file (default ~/.config/dgx/hosts.yaml, or $DGX_INVENTORY)")
+	rootCmd.PersistentFlags().Int("parallel", fleet.DefaultParallel, "Maximum hosts worked on at once when fanning out")
+	rootCmd.PersistentFlags().String("export", "", "With --group/--tag or dgx fleet, write a per-host report to this .csv or .json file")
+}
@@ -0,0 +1,484 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/artifacts"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/job"
+	"github.com/weatherman/dgx-manager/internal/redact"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/state"
+)
+
+// job command
+var jobCmd = &cobra.Command{
+	Use:   "job",
+	Short: "Run long commands in the background on the DGX",
+	Long: `Run commands detached on the DGX as transient systemd user units, so they keep
+running after dgx exits and the laptop disconnects. Each job's output is kept
+in ~/.local/share/dgx/jobs/<id>/output.log on the DGX.
+
+Jobs survive the end of the SSH session only when lingering is enabled for the
+user; 'dgx job submit' enables it when the DGX allows (loginctl enable-linger)
+and warns when it could not.
+
+Examples:
+  dgx job submit -- python train.py --epochs 3
+  dgx job submit --script prep.sh --dir ~/data --memory 32G --timeout 6h
+  dgx job submit --cpus 8 --nice 15 --ionice idle --gpu none -- python tokenize.py
+  dgx job submit --gpu exclusive -- python finetune.py
+  dgx job list
+  dgx job logs train -f
+  dgx job show train
+  dgx job wait train && echo finished`,
+}
+
+var jobSubmitCmd = &cobra.Command{
+	Use:   "submit [-- <command>...]",
+	Short: "Start a command or script as a background job",
+	Long: `Start a command, or a local script with --script, as a background job and
+print its ID. The command runs with bash in the home directory (or --dir), with
+the environment from 'dgx env', and its output goes to the job's log.
+
+Limits and priorities keep a background job from starving interactive work
+such as a model server:
+
+  --memory        hard memory cap (systemd MemoryMax=, such as 32G or 50%); the
+                  kernel kills the job's largest process when it goes over
+  --memory-high   soft memory limit: above it the job is throttled and its memory
+                  reclaimed instead of killed
+  --cpus          CPU time cap in CPUs (systemd CPUQuota=), such as 4 or 2.5
+  --nice          CPU priority, 0 to 19 (the lowest)
+  --ionice        I/O priority: idle (only when the disk is otherwise unused),
+                  or best-effort with a level from 0 to 7 (best-effort:7)
+  --timeout       stop the job after this long
+
+--gpu none hides the GPU from CUDA programs in the job. --gpu exclusive waits in
+the queue and then until no other process is using the GPU before starting.
+
+--queue puts the job in the DGX's FIFO queue: queued jobs run one at a time in
+the order they were submitted, while jobs submitted without --queue start right
+away. The CPU and memory limits need the cpu and memory cgroup controllers
+delegated to user units, as recent systemd releases do by default.
+
+--notify posts the outcome to a webhook from the DGX when the job ends, as JSON
+with a Slack-compatible text field ("[spark] dgx job train-3f9a failed with exit
+code 1") and the job, host, state, and exit_code. See 'dgx job show' for the
+exit code, duration, and peak resource use of a finished job.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		script, _ := cmd.Flags().GetString("script")
+		name, _ := cmd.Flags().GetString("name")
+		dir, _ := cmd.Flags().GetString("dir")
+		memory, _ := cmd.Flags().GetString("memory")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		memoryHigh, _ := cmd.Flags().GetString("memory-high")
+		cpus, _ := cmd.Flags().GetFloat64("cpus")
+		nice, _ := cmd.Flags().GetInt("nice")
+		ionice, _ := cmd.Flags().GetString("ionice")
+		gpuMode, _ := cmd.Flags().GetString("gpu")
+		queue, _ := cmd.Flags().GetBool("queue")
+		notify, _ := cmd.Flags().GetString("notify")
+
+		cfg := cfgManager.Get()
+		spec := job.Spec{
+			Name: name, Dir: dir, Memory: memory, Timeout: timeout,
+			MemoryHigh: memoryHigh, CPUs: cpus, Nice: nice, IONice: ionice, GPU: gpuMode, Queue: queue,
+			Notify: notify,
+		}
+		switch {
+		case script != "" && len(args) > 0:
+			exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("give a command or --script, not both")))
+		case script != "":
+			requireShell(cfg, "dgx job submit --script")
+			content, err := os.ReadFile(script)
+			if err != nil {
+				exitWithError(exitcode.Wrap(exitcode.Usage, err))
+			}
+			spec.Command, spec.Script = string(content), script
+		case len(args) > 0:
+			command, err := execCommand(cfg, args)
+			if err != nil {
+				exitWithError(err)
+			}
+			spec.Command = command
+		default:
+			exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("give the command to run after --, or --script")))
+		}
+		if err := spec.Validate(); err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Usage, err))
+		}
+
+		client, err := ssh.NewClient(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+
+		result, err := job.NewManager(client).Submit(spec)
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Submitted job %s (%s)\n", result.ID, job.UnitName(result.ID))
+		if spec.Queue {
+			fmt.Println("It waits in the queue until the jobs submitted before it have ended")
+		}
+		if !result.Linger {
+			fmt.Fprintf(os.Stderr, "Warning: lingering could not be enabled for %s, so the job stops when the last session on the DGX ends; run 'sudo loginctl enable-linger %s' there\n", cfg.User, cfg.User)
+		}
+		fmt.Printf("Follow its output with: dgx job logs %s -f\n", result.ID)
+	},
+}
+
+var jobListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List background jobs",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		client, manager := jobManager()
+		defer client.Close()
+		jobs, err := manager.List()
+		if err != nil {
+			exitWithError(err)
+		}
+		if asJSON {
+			if jobs == nil {
+				jobs = []job.Job{}
+			}
+			printJSON(jobs)
+			return
+		}
+		if len(jobs) == 0 {
+			fmt.Println("No jobs. Start one with: dgx job submit -- <command>")
+			return
+		}
+
+		now := time.Now()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tSTATE\tEXIT\tDURATION\tSUBMITTED\tCOMMAND")
+		for _, j := range jobs {
+			exit := "-"
+			if j.ExitCode != nil {
+				exit = fmt.Sprint(*j.ExitCode)
+			}
+			duration := "-"
+			if d := j.Duration(now); d > 0 {
+				duration = d.Round(time.Second).String()
+			}
+			command := j.Command
+			if len(command) > 50 {
+				command = command[:47] + "..."
+			}
+			state := j.State
+			if j.Position > 0 {
+				state += fmt.Sprintf(" #%d", j.Position)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", j.ID, state, exit, duration, j.Submitted.Local().Format("Jan 02 15:04"), command)
+		}
+		w.Flush()
+	},
+}
+
+var jobLogsCmd = &cobra.Command{
+	Use:   "logs <job>",
+	Short: "Show a job's output",
+	Long: `Show the last lines of a job's output. With --follow, keep printing new output
+until the job ends. A job is named by its ID or the start of one.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		tail, _ := cmd.Flags().GetInt("tail")
+		follow, _ := cmd.Flags().GetBool("follow")
+
+		client, manager := jobManager()
+		defer client.Close()
+		j := findJob(manager, args[0])
+		redactRemoteSecrets(client)
+		stdout := redact.NewWriter(os.Stdout, redact.Default())
+		stderr := redact.NewWriter(os.Stderr, redact.Default())
+		err := client.Stream(job.LogsCommand(j.ID, tail, follow && !j.Done()), nil, stdout, stderr)
+		stdout.Flush()
+		stderr.Flush()
+		if err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+var jobCancelCmd = &cobra.Command{
+	Use:   "cancel <job>",
+	Short: "Stop a running job",
+	Long: `Stop a running job and everything it started. A job is named by its ID or the
+start of one.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		client, manager := jobManager()
+		defer client.Close()
+		j := findJob(manager, args[0])
+		if j.Done() {
+			exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("job %s is not running (%s)", j.ID, j.State)))
+		}
+		if !confirmCommand(cmd, fmt.Sprintf("Cancel job %s (%s)?", j.ID, j.Command), yes) {
+			fmt.Println("Cancelled.")
+			exit(exitcode.Aborted)
+		}
+		if err := manager.Cancel(j.ID); err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Canceled job %s\n", j.ID)
+	},
+}
+
+var jobWaitCmd = &cobra.Command{
+	Use:   "wait <job>",
+	Short: "Wait for a job to end",
+	Long: `Wait until a job ends and print how it ended. dgx exits 0 when the job
+succeeded and 4 when it failed, was canceled, or was killed, so scripts can
+chain on it. With --timeout, give up after that long and exit 1.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+
+		client, manager := jobManager()
+		defer client.Close()
+		j := findJob(manager, args[0])
+
+		ctx := context.Background()
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		if !j.Done() {
+			var err error
+			if j, err = manager.Wait(ctx, j.ID); err != nil {
+				if ctx.Err() != nil {
+					exitWithError(fmt.Errorf("job %s still running after %s", args[0], timeout))
+				}
+				exitWithError(err)
+			}
+		}
+
+		recordJob(manager, j)
+		summary := fmt.Sprintf("Job %s %s", j.ID, j.State)
+		if j.ExitCode != nil && *j.ExitCode != 0 {
+			summary += fmt.Sprintf(" with exit code %d", *j.ExitCode)
+		}
+		if d := j.Duration(time.Now()); d > 0 {
+			summary += fmt.Sprintf(" after %s", d.Round(time.Second))
+		}
+		fmt.Println(summary)
+		if j.State != job.StateSucceeded {
+			exit(exitcode.Remote)
+		}
+	},
+}
+
+var jobShowCmd = &cobra.Command{
+	Use:   "show <job>",
+	Short: "Show a job's outcome, resource use, and last output",
+	Long: `Show a job's settings and state and, once it has ended, its exit code,
+duration, peak CPU, memory, and GPU use, and the last lines of its output.
+
+The result of a job that ended is recorded in the local state directory the
+first time 'dgx job show' or 'dgx job wait' sees it, so it can still be shown
+after the job's directory on the DGX is removed. A job is named by its ID or
+the start of one.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		cfg := cfgManager.Get()
+		store, err := state.DefaultStore()
+		if err != nil {
+			exitWithError(err)
+		}
+		client, manager := jobManager()
+		defer client.Close()
+		jobs, err := manager.List()
+		if err != nil {
+			exitWithError(err)
+		}
+
+		var result job.Result
+		if j, err := job.Find(jobs, args[0]); err == nil {
+			if j.Done() {
+				result = recordJob(manager, j)
+			} else if result.Tail, err = manager.Tail(j.ID, job.TailLines); err != nil {
+				exitWithError(err)
+			}
+			result.Job = j
+		} else {
+			// The job may be gone from the DGX but recorded here
+			results, _ := job.LoadResults(store, cfg.Host)
+			if result, err = job.FindResult(results, args[0]); err != nil {
+				exitWithError(exitcode.Wrap(exitcode.Usage, err))
+			}
+		}
+
+		if asJSON {
+			printJSON(result)
+			return
+		}
+		printJobResult(result, time.Now())
+	},
+}
+
+// recordJob saves the result of a job that ended to the local state, once, and returns it.
+// A job whose output can no longer be read is recorded without it.
+func recordJob(manager *job.Manager, j job.Job) job.Result {
+	host := cfgManager.Get().Host
+	store, err := state.DefaultStore()
+	if err != nil {
+		return job.Result{Job: j}
+	}
+	results, _ := job.LoadResults(store, host)
+	for _, r := range results {
+		if r.ID == j.ID && r.State == j.State {
+			return r
+		}
+	}
+	result, err := manager.Record(j, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		result = job.Result{Job: j, Recorded: time.Now()}
+	}
+	if err := job.SaveResult(store, host, result); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record the result of %s: %v\n", j.ID, err)
+	}
+	return result
+}
+
+// printJobResult prints a job's details, usage, and output tail for 'dgx job show'
+func printJobResult(r job.Result, now time.Time) {
+	stamp := func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.Local().Format("2006-01-02 15:04:05")
+	}
+	state := r.State
+	switch {
+	case r.ExitCode != nil && *r.ExitCode != 0:
+		state += fmt.Sprintf(" (exit code %d)", *r.ExitCode)
+	case r.Position > 0:
+		state += fmt.Sprintf(" (position %d)", r.Position)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Job:\t%s (%s)\n", r.ID, job.UnitName(r.ID))
+	fmt.Fprintf(w, "Command:\t%s\n", r.Command)
+	if r.Dir != "" {
+		fmt.Fprintf(w, "Directory:\t%s\n", r.Dir)
+	}
+	fmt.Fprintf(w, "State:\t%s\n", state)
+	fmt.Fprintf(w, "Submitted:\t%s\n", stamp(r.Submitted))
+	fmt.Fprintf(w, "Started:\t%s\n", stamp(r.Started))
+	fmt.Fprintf(w, "Finished:\t%s\n", stamp(r.Finished))
+	if d := r.Duration(now); d > 0 {
+		fmt.Fprintf(w, "Duration:\t%s\n", d.Round(time.Second))
+	}
+
+	var limits []string
+	if r.Memory != "" {
+		limits = append(limits, "memory="+r.Memory)
+	}
+	if r.Timeout > 0 {
+		limits = append(limits, "timeout="+r.Timeout.String())
+	}
+	if l := r.Limits.String(); l != "" {
+		limits = append(limits, l)
+	}
+	if len(limits) > 0 {
+		fmt.Fprintf(w, "Limits:\t%s\n", strings.Join(limits, " "))
+	}
+
+	u := r.Usage
+	if u.CPUTime > 0 || u.PeakCPU > 0 {
+		fmt.Fprintf(w, "Peak CPU:\t%d%% of one CPU (CPU time %s)\n", u.PeakCPU, u.CPUTime.Round(time.Second))
+	}
+	if u.PeakMemory > 0 {
+		fmt.Fprintf(w, "Peak memory:\t%s\n", artifacts.FormatBytes(u.PeakMemory))
+	}
+	if u.PeakGPU > 0 || u.PeakGPUMemory > 0 {
+		gpuMemory := "-"
+		if u.PeakGPUMemory > 0 {
+			gpuMemory = artifacts.FormatBytes(u.PeakGPUMemory)
+		}
+		fmt.Fprintf(w, "Peak GPU:\t%d%% utilization, %s GPU memory\n", u.PeakGPU, gpuMemory)
+	}
+	if r.Notify {
+		fmt.Fprintf(w, "Notify:\twebhook when the job ends\n")
+	}
+	if !r.Recorded.IsZero() {
+		fmt.Fprintf(w, "Recorded:\t%s\n", stamp(r.Recorded))
+	}
+	w.Flush()
+
+	if len(r.Tail) > 0 {
+		fmt.Printf("\nLast %d lines of output:\n", len(r.Tail))
+		for _, line := range r.Tail {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+}
+
+// jobManager connects to the configured DGX. The caller closes the returned client.
+func jobManager() (*ssh.Client, *job.Manager) {
+	client, err := ssh.NewClient(cfgManager.Get())
+	if err != nil {
+		exitWithError(err)
+	}
+	return client, job.NewManager(client)
+}
+
+// findJob resolves ref to a job, exiting when it matches none or several
+func findJob(manager *job.Manager, ref string) job.Job {
+	jobs, err := manager.List()
+	if err != nil {
+		exitWithError(err)
+	}
+	j, err := job.Find(jobs, ref)
+	if err != nil {
+		exitWithError(exitcode.Wrap(exitcode.Usage, err))
+	}
+	return j
+}
+
+func init() {
+	jobSubmitCmd.Flags().String("script", "", "Local script to run instead of a command")
+	jobSubmitCmd.Flags().String("name", "", "Name the job ID starts with (default: the program run)")
+	jobSubmitCmd.Flags().String("dir", "", "Working directory on the DGX (default: home)")
+	jobSubmitCmd.Flags().String("memory", "", "Memory limit, e.g. 32G or 50%")
+	jobSubmitCmd.Flags().Duration("timeout", 0, "Stop the job after this long, e.g. 6h")
+	jobSubmitCmd.Flags().String("memory-high", "", "Soft memory limit above which the job is throttled, e.g. 24G")
+	jobSubmitCmd.Flags().Float64("cpus", 0, "Cap the job's CPU time at this many CPUs, e.g. 4")
+	jobSubmitCmd.Flags().Int("nice", 0, "CPU priority from 0 to 19 (lowest)")
+	jobSubmitCmd.Flags().String("ionice", "", "I/O priority: idle or best-effort[:0-7]")
+	jobSubmitCmd.Flags().String("gpu", job.GPUShared, "GPU access: shared, exclusive (wait for a free GPU), or none")
+	jobSubmitCmd.Flags().String("notify", "", "Webhook URL the DGX posts the job's outcome to when it ends")
+	jobSubmitCmd.Flags().Bool("queue", false, "Wait in the DGX's job queue and run after the jobs queued before it")
+	jobListCmd.Flags().Bool("json", false, "Print jobs as JSON")
+	jobLogsCmd.Flags().Int("tail", 100, "Number of lines to show")
+	jobLogsCmd.Flags().BoolP("follow", "f", false, "Keep printing output until the job ends")
+	jobCancelCmd.Flags().BoolP("yes", "y", false, "Cancel without confirmation")
+	jobWaitCmd.Flags().Duration("timeout", 0, "Give up after this long")
+	jobShowCmd.Flags().Bool("json", false, "Print the job as JSON")
+
+	jobCmd.AddCommand(jobSubmitCmd)
+	jobCmd.AddCommand(jobListCmd)
+	jobCmd.AddCommand(jobLogsCmd)
+	jobCmd.AddCommand(jobCancelCmd)
+	jobCmd.AddCommand(jobWaitCmd)
+	jobCmd.AddCommand(jobShowCmd)
+	rootCmd.AddCommand(jobCmd)
+}
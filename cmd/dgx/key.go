@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/ui"
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// key command
+var keyCmd = &cobra.Command{
+	Use:   "key",
+	Short: "Manage the SSH key and certificate used for the DGX",
+}
+
+var keySetupCmd = &cobra.Command{
+	Use:   "setup",
+	Short: "Generate a dedicated ed25519 key, install it on the DGX, and switch to it",
+	Long: `Generate an ed25519 key pair for this DGX, install the public key in
+~/.ssh/authorized_keys on the DGX, point the config (or the selected profile) at
+the new key, and check that key-only login works.
+
+Installing the key logs in with the current key if it works and otherwise asks
+for the account password, so this also bootstraps a fresh device. An existing
+key at the target path is reused unless --force is given.
+
+Examples:
+  dgx key setup
+  dgx --profile ci key setup --passphrase
+  dgx --host 192.168.1.50 --user alice key setup`,
+	Run: func(cmd *cobra.Command, args []string) {
+		keyFile, _ := cmd.Flags().GetString("key-file")
+		force, _ := cmd.Flags().GetBool("force")
+		withPassphrase, _ := cmd.Flags().GetBool("passphrase")
+
+		cfg := cfgManager.Get()
+		name := cfg.ActiveProfile
+		if name == "" {
+			name = "spark"
+		}
+		if keyFile == "" {
+			home, _ := os.UserHomeDir()
+			keyFile = filepath.Join(home, ".ssh", fmt.Sprintf("dgx_%s_ed25519", name))
+		}
+
+		var pubKey string
+		_, statErr := os.Stat(keyFile)
+		if statErr == nil && !force {
+			var err error
+			if pubKey, err = ssh.PublicKeyFile(keyFile); err != nil {
+				exitWithError(err)
+			}
+			fmt.Printf("Using existing key %s\n", keyFile)
+		} else {
+			var passphrase []byte
+			if withPassphrase {
+				var err error
+				if passphrase, err = readNewPassphrase(); err != nil {
+					exitWithError(err)
+				}
+			}
+			var err error
+			pubKey, err = ssh.GenerateKey(keyFile, fmt.Sprintf("dgx-%s", name), passphrase)
+			if err != nil {
+				exitWithError(err)
+			}
+			fmt.Printf("Generated %s\n", keyFile)
+		}
+
+		fmt.Printf("Installing public key for %s@%s...\n", cfg.User, cfg.Host)
+		client, err := ssh.NewClient(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		err = client.InstallAuthorizedKey(pubKey)
+		client.Close()
+		if err != nil {
+			exitWithError(err)
+		}
+
+		err = cfgManager.Update(func(file *types.Config) {
+			if cfg.ActiveProfile == "" {
+				file.IdentityFile = keyFile
+				return
+			}
+			profile := file.Profiles[cfg.ActiveProfile]
+			profile.IdentityFile = keyFile
+			file.Profiles[cfg.ActiveProfile] = profile
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to save config: %v\n", err)
+			exit(exitcode.Config)
+		}
+		if cfg.ActiveProfile == "" {
+			fmt.Printf("Config now uses %s\n", keyFile)
+		} else {
+			fmt.Printf("Profile %s now uses %s\n", cfg.ActiveProfile, keyFile)
+		}
+
+		verifyCfg := *cfg
+		verifyCfg.IdentityFile = keyFile
+		verify, err := ssh.NewClient(&verifyCfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer verify.Close()
+		if _, err := verify.Execute("true"); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: key-only login failed: %v\n", err)
+			exit(exitcode.Connection)
+		}
+		fmt.Println("Key-only login verified")
+	},
+}
+
+var keyCertCmd = &cobra.Command{
+	Use:   "cert [certificate]",
+	Short: "Show or set the SSH certificate used with the key",
+	Long: `Show the OpenSSH user certificate dgx presents with the key: who signed it,
+which users it is valid for, and when it expires. The exit status is non-zero
+when it cannot be used.
+
+With a path, the certificate is checked against the key and saved as
+certificate_file in the config (or the selected profile). Without one
+configured, dgx uses <identity_file>-cert.pub when it exists, as ssh does, so
+a CA client that refreshes that file needs no setup.
+
+Examples:
+  dgx key cert
+  dgx key cert ~/.ssh/id_ed25519-cert.pub
+  dgx --profile lab key cert ~/.step/ssh/alice-cert.pub`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := cfgManager.Get()
+		path := ssh.CertificatePath(cfg)
+		if len(args) == 1 {
+			path = args[0]
+		}
+		if path == "" {
+			fmt.Println("No SSH certificate configured; logging in with the key alone.")
+			if cfg.IdentityFile != "" {
+				fmt.Printf("Set one with 'dgx key cert <path>', or place it at %s-cert.pub\n", cfg.IdentityFile)
+			}
+			return
+		}
+
+		cert, err := ssh.LoadCertificate(path)
+		if err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Config, err))
+		}
+		info := ssh.DescribeCertificate(cert)
+		principals := "any user"
+		if len(info.Principals) > 0 {
+			principals = strings.Join(info.Principals, ", ")
+		}
+		validity := "forever"
+		if !info.Expires.IsZero() {
+			validity = "until " + info.Expires.Local().Format("2006-01-02 15:04 MST")
+			if remaining := time.Until(info.Expires); remaining > 0 {
+				validity += fmt.Sprintf(" (%v left)", remaining.Round(time.Minute))
+			}
+		}
+		if !info.ValidAfter.IsZero() {
+			validity = "from " + info.ValidAfter.Local().Format("2006-01-02 15:04 MST") + " " + validity
+		}
+		fmt.Printf("Certificate: %s\n", path)
+		fmt.Printf("Key ID:      %s (serial %d)\n", info.KeyID, info.Serial)
+		fmt.Printf("Principals:  %s\n", principals)
+		fmt.Printf("Signed by:   %s\n", info.CA)
+		fmt.Printf("Valid:       %s\n", validity)
+
+		problem := ssh.CheckCertificate(cert, cfg.User, time.Now())
+		if problem == nil && cfg.IdentityFile != "" {
+			problem = ssh.CheckCertificateKey(cert, cfg.IdentityFile)
+		}
+		if problem != nil {
+			exitWithError(exitcode.Wrap(exitcode.Config, problem))
+		}
+		fmt.Printf("Status:      usable for %s with %s\n", cfg.User, ui.OrDash(cfg.IdentityFile))
+		if len(args) == 0 {
+			return
+		}
+
+		err = cfgManager.Update(func(file *types.Config) {
+			if cfg.ActiveProfile == "" {
+				file.CertificateFile = path
+				return
+			}
+			profile := file.Profiles[cfg.ActiveProfile]
+			profile.CertificateFile = path
+			file.Profiles[cfg.ActiveProfile] = profile
+		})
+		if err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Config, err))
+		}
+		if cfg.ActiveProfile == "" {
+			fmt.Printf("Config now uses %s\n", path)
+		} else {
+			fmt.Printf("Profile %s now uses %s\n", cfg.ActiveProfile, path)
+		}
+	},
+}
+
+// readNewPassphrase prompts twice for a passphrase for a new key
+func readNewPassphrase() ([]byte, error) {
+	first, err := ssh.ReadSecret("Passphrase for the new key: ")
+	if err != nil {
+		return nil, err
+	}
+	second, err := ssh.ReadSecret("Repeat passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(first, second) {
+		return nil, fmt.Errorf("passphrases do not match")
+	}
+	return first, nil
+}
+
+func init() {
+	keySetupCmd.Flags().String("key-file", "", "Private key path (default ~/.ssh/dgx_<profile>_ed25519)")
+	keySetupCmd.Flags().Bool("force", false, "Generate a new key even if the key file exists")
+	keySetupCmd.Flags().Bool("passphrase", false, "Protect the new key with a passphrase")
+
+	keyCmd.AddCommand(keySetupCmd, keyCertCmd)
+	rootCmd.AddCommand(keyCmd)
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/weatherman/dgx-manager/internal/config"
+	"github.com/weatherman/dgx-manager/internal/locate"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/state"
+	"github.com/weatherman/dgx-manager/internal/ui"
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// sightingRefresh is how often an unchanged sighting is rewritten to update its time
+const sightingRefresh = time.Hour
+
+// hostTracker remembers where the DGX answered and finds it again after a DHCP change.
+// The configured connection is keyed by its profile so the key survives the move; other
+// connections (inventory hosts, cluster peers) are keyed by host.
+type hostTracker struct {
+	store *state.Store
+	cfg   *types.Config // the configured connection, as returned by cfgManager.Get
+	name  string
+}
+
+// installHostTracker makes SSH connections follow the configured DGX when it moves
+func installHostTracker() {
+	store, err := state.DefaultStore()
+	if err != nil {
+		return
+	}
+	ssh.SetTracker(&hostTracker{store: store, cfg: cfgManager.Get(), name: trackerKey()})
+}
+
+// settingSource returns where the effective value of the named setting came from
+func settingSource(name string) string {
+	for _, s := range cfgManager.Settings() {
+		if s.Name == name {
+			return s.Source
+		}
+	}
+	return ""
+}
+
+func (t *hostTracker) key(cfg *types.Config) string {
+	if cfg == t.cfg {
+		return t.name
+	}
+	return cfg.Host
+}
+
+// Connected records the address, MAC, and hostname the DGX answered with
+func (t *hostTracker) Connected(cfg *types.Config, client *ssh.Client) {
+	key := t.key(cfg)
+	last := locate.Load(t.store, key)
+	if last == nil {
+		last = &locate.Sighting{}
+	}
+	seen := locate.Sighting{Address: client.RemoteIP(), MAC: last.MAC, Hostname: last.Hostname, SeenAt: time.Now()}
+	if seen.Address == "" {
+		return
+	}
+	if mac := locate.NeighborMAC(seen.Address); mac != "" {
+		seen.MAC = mac
+	}
+	if seen.Hostname == "" || seen.Address != last.Address {
+		if out, err := client.Execute("hostname -s"); err == nil {
+			seen.Hostname = strings.TrimSpace(out)
+		}
+	}
+	if seen.Address == last.Address && seen.MAC == last.MAC && seen.Hostname == last.Hostname &&
+		time.Since(last.SeenAt) < sightingRefresh {
+		return
+	}
+	locate.Save(t.store, key, seen)
+}
+
+// Relocate looks for the DGX by mDNS, its last known address, and its MAC
+func (t *hostTracker) Relocate(cfg *types.Config) (string, string, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	target := locate.Target{Host: cfg.Host, Port: cfg.Port, MAC: cfg.MAC, MDNS: cfg.MDNS}
+	res, err := locate.New().Find(ctx, target, locate.Load(t.store, t.key(cfg)))
+	if err != nil {
+		if !errors.Is(err, locate.ErrNoHints) {
+			fmt.Fprintf(os.Stderr, "Note: %s does not answer and the %v\n", cfg.Host, err)
+		}
+		return "", "", false
+	}
+	return res.Address, res.Method, true
+}
+
+// Moved writes the new address back to the profile or config file it came from
+func (t *hostTracker) Moved(cfg *types.Config, from string) {
+	if cfg != t.cfg {
+		return
+	}
+	file := cfgManager.File()
+	source := settingSource("host")
+	switch {
+	case strings.HasPrefix(source, config.SourceProfile):
+		p := file.Profiles[cfg.ActiveProfile]
+		p.Host = cfg.Host
+		file.Profiles[cfg.ActiveProfile] = p
+	case source == config.SourceConfig:
+		file.Host = cfg.Host
+	default:
+		fmt.Fprintf(os.Stderr, "Note: host %s comes from %s; update it to %s there\n", from, source, cfg.Host)
+		return
+	}
+	if err := cfgManager.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save the new address: %v\n", err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Updated %s: host %s -> %s\n", source, from, cfg.Host)
+	// Save re-resolves the effective config; keep recognizing this connection under its key
+	t.cfg = cfgManager.Get()
+}
+
+// locate command
+var locateCmd = &cobra.Command{
+	Use:   "locate",
+	Short: "Find the DGX after its IP address changed and update the profile",
+	Long: `Connect to the DGX, and when its address no longer answers, look for it on the
+local network:
+
+  1. mDNS: the mdns setting, the hostname remembered from earlier
+     connections, or the host itself when it is a .local name
+  2. the address it last answered on
+  3. its MAC address (the mac setting or the one remembered from earlier
+     connections) in the ARP table, sweeping the local /24 to fill it
+
+A new address is only used when the DGX there presents the host key that
+known_hosts has for the old one. The profile (or the top-level host) is then
+updated. Every command does this automatically when the connection fails;
+'dgx locate' runs it on demand and shows what dgx remembers.
+
+Example config for a Spark on a DHCP network:
+  profiles:
+    lab:
+      host: 192.168.1.23
+      mac: a0:b1:c2:d3:e4:f5
+      mdns: spark-1a2b.local`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := cfgManager.Get()
+		client, err := ssh.NewClient(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		if err := client.Connect(); err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+
+		store, err := state.DefaultStore()
+		if err != nil {
+			exitWithError(err)
+		}
+		cfg = cfgManager.Get()
+		seen := locate.Load(store, trackerKey())
+		if seen == nil {
+			seen = &locate.Sighting{}
+		}
+		fmt.Printf("Host:      %s (%s)\n", cfg.Host, settingSource("host"))
+		fmt.Printf("Address:   %s\n", ui.OrDash(seen.Address))
+		fmt.Printf("MAC:       %s\n", ui.OrDash(firstNonEmpty(cfg.MAC, seen.MAC)))
+		fmt.Printf("mDNS name: %s\n", ui.OrDash(locate.MDNSName(locate.Target{Host: cfg.Host, MDNS: cfg.MDNS}, seen)))
+	},
+}
+
+// trackerKey returns the sighting key of the configured connection
+func trackerKey() string {
+	cfg := cfgManager.Get()
+	switch source := settingSource("host"); {
+	case strings.HasPrefix(source, config.SourceProfile):
+		return "profile-" + cfg.ActiveProfile
+	case source == config.SourceConfig:
+		return "default"
+	}
+	return cfg.Host
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func init() {
+	rootCmd.AddCommand(locateCmd)
+}
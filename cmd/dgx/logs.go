@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/fleet"
+	"github.com/weatherman/dgx-manager/internal/redact"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/ui"
+	"github.com/weatherman/dgx-manager/internal/workload"
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// logs command
+var logsCmd = &cobra.Command{
+	Use:   "logs <workload>",
+	Short: "Show or follow a workload's logs on one or several DGX hosts",
+	Long: `Show the logs of a workload, named as in 'dgx ps logs' (container name or ID,
+model name, or PID), on the configured DGX or, with --hosts, --group, or --tag,
+on every selected host at once. The streams are merged line by line, each line
+prefixed with its host in a color of its own when the output is a terminal
+(NO_COLOR or --plain turns the colors off). Hosts where the workload is not running are
+skipped with a warning.
+
+--grep filters on the DGX, so only matching lines cross the network; it takes
+an extended regular expression as grep -E does. --tail counts lines before the
+filter. With --output-dir each host's lines are also written, unprefixed, to
+<dir>/<host>.log.
+
+Examples:
+  dgx logs dgx-qwen -f
+  dgx logs vllm --group inference -f --grep 'ERROR|WARN'
+  dgx logs trainer --hosts lab1,lab2 --tail 500 --output-dir ./logs`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		tail, _ := cmd.Flags().GetInt("tail")
+		follow, _ := cmd.Flags().GetBool("follow")
+		pattern, _ := cmd.Flags().GetString("grep")
+		ignoreCase, _ := cmd.Flags().GetBool("ignore-case")
+		outputDir, _ := cmd.Flags().GetString("output-dir")
+
+		cfg := cfgManager.Get()
+		targets := []fleetTarget{{Name: cfg.Host, Config: cfg}}
+		if specs, _ := cmd.Flags().GetStringSlice("hosts"); len(specs) > 0 || groupSelected(cmd) {
+			targets = fleetTargets(cmd)
+		}
+		if outputDir != "" {
+			if err := os.MkdirAll(outputDir, 0755); err != nil {
+				exitWithError(exitcode.Wrap(exitcode.Usage, err))
+			}
+		}
+
+		names := make([]string, len(targets))
+		for i, t := range targets {
+			names[i] = t.Name
+		}
+		color := ui.Color(os.Stdout)
+		mux := fleet.NewLogMux(os.Stdout, names, color)
+
+		jobs := make([]fleet.Job, len(targets))
+		for i, t := range targets {
+			var file io.Writer
+			if outputDir != "" {
+				f, err := os.Create(filepath.Join(outputDir, logFileName(t.Name)))
+				if err != nil {
+					exitWithError(exitcode.Wrap(exitcode.Usage, err))
+				}
+				defer f.Close()
+				file = f
+			}
+			out := mux.Writer(t.Name, file)
+			jobs[i] = fleet.Job{Host: t.Name, Label: "logs", Run: func(ctx context.Context) (string, error) {
+				defer out.Close()
+				return "", streamLogs(t.Config, args[0], tail, follow, pattern, ignoreCase, out)
+			}}
+		}
+		// Every stream runs at once, since followed logs never finish
+		results := (&fleet.Pool{Parallel: len(jobs)}).Run(context.Background(), jobs)
+
+		if len(results) == 1 && results[0].Err != nil {
+			exitWithError(results[0].Err)
+		}
+		var firstErr error
+		for _, r := range results {
+			if r.Err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", r.Job.Host, r.Err)
+				if firstErr == nil {
+					firstErr = r.Err
+				}
+			}
+		}
+		if firstErr != nil {
+			exit(exitcode.Of(firstErr))
+		}
+	},
+}
+
+// streamLogs finds the workload ref on the host and copies its logs to out
+func streamLogs(cfg *types.Config, ref string, tail int, follow bool, pattern string, ignoreCase bool, out io.Writer) error {
+	client, err := ssh.NewClient(cfg)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	workloads, err := workload.NewCollector(client, cfg.Host).List(context.Background(), true)
+	if err != nil {
+		return err
+	}
+	matches := workload.Find(workloads, ref)
+	switch len(matches) {
+	case 0:
+		return exitcode.Wrap(exitcode.Usage, fmt.Errorf("no workload matches %q (see 'dgx ps --all')", ref))
+	case 1:
+	default:
+		names := make([]string, len(matches))
+		for i, m := range matches {
+			names[i] = m.Name
+		}
+		return exitcode.Wrap(exitcode.Usage, fmt.Errorf("%q matches several workloads: %s", ref, strings.Join(names, ", ")))
+	}
+
+	command, err := matches[0].LogsCommand(tail, follow)
+	if err != nil {
+		return exitcode.Wrap(exitcode.Usage, err)
+	}
+	redactRemoteSecrets(client)
+	masked := redact.NewWriter(out, redact.Default())
+	defer masked.Flush()
+	return client.Stream(workload.FilterLogs(command, pattern, ignoreCase), nil, masked, nil)
+}
+
+// logFileName returns the --output-dir file for a host, keeping the name to one path element
+func logFileName(host string) string {
+	return strings.NewReplacer("/", "_", string(filepath.Separator), "_", ":", "_").Replace(host) + ".log"
+}
+
+func init() {
+	logsCmd.Flags().Int("tail", 100, "Number of lines to show from each host")
+	logsCmd.Flags().BoolP("follow", "f", false, "Follow the log output")
+	logsCmd.Flags().String("grep", "", "Only send lines matching this extended regular expression (filtered on the DGX)")
+	logsCmd.Flags().BoolP("ignore-case", "i", false, "Match --grep case-insensitively")
+	logsCmd.Flags().String("output-dir", "", "Also write each host's lines to <dir>/<host>.log")
+	logsCmd.Flags().StringSlice("hosts", nil, "Profile names, inventory names, or hosts to read from (or use --group/--tag)")
+	rootCmd.AddCommand(logsCmd)
+}
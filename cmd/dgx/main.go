@@ -2,21 +2,32 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/weatherman/dgx-manager/internal/config"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
 	"github.com/weatherman/dgx-manager/internal/gpu"
+	"github.com/weatherman/dgx-manager/internal/pins"
 	"github.com/weatherman/dgx-manager/internal/playbook"
+	"github.com/weatherman/dgx-manager/internal/policy"
+	"github.com/weatherman/dgx-manager/internal/redact"
+	"github.com/weatherman/dgx-manager/internal/session"
 	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/transfer"
 	"github.com/weatherman/dgx-manager/internal/tunnel"
+	"github.com/weatherman/dgx-manager/internal/ui"
 	"github.com/weatherman/dgx-manager/pkg/types"
 )
 
@@ -30,13 +41,22 @@ func main() {
 	cfgManager, err = config.NewManager()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: Failed to initialize config: %v\n", err)
-		os.Exit(1)
+		exit(exitcode.Config)
 	}
 
+	// Plugins are found by searching $PATH, which completion helpers have no use for
+	if len(os.Args) < 2 || os.Args[1] != "__names" {
+		registerPlugins()
+	}
+	// Before the history entry, so its arguments are masked with the known secrets too
+	installRedactor()
+	beginHistory()
+
+	// Commands exit from Run with their own codes, so errors here are cobra usage errors
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		exitWithError(exitcode.Wrap(exitcode.Usage, err))
 	}
+	invocation.Finish(0)
 }
 
 var rootCmd = &cobra.Command{
@@ -44,20 +64,69 @@ var rootCmd = &cobra.Command{
 	Short: "DGX Spark management CLI",
 	Long:  `A CLI tool to manage connections, tunnels, and GPU monitoring for DGX Spark.`,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
-		// Check if this command or its parent is one that doesn't require config
-		cmdPath := cmd.CommandPath()
-		noConfigRequired := strings.Contains(cmdPath, "config") ||
-			strings.Contains(cmdPath, "version") ||
-			strings.Contains(cmdPath, "help") ||
-			strings.Contains(cmdPath, "completion")
-
-		if !noConfigRequired && !cfgManager.IsConfigured() {
-			fmt.Fprintf(os.Stderr, "Error: DGX not configured. Run 'dgx config set' first.\n")
-			os.Exit(1)
+		prepareCommand(cmd)
+	},
+	// Without a command, a terminal gets the command palette and anything else the help
+	Run: func(cmd *cobra.Command, args []string) {
+		if !isPaletteTerminal() {
+			cmd.Help()
+			return
 		}
+		runPalette(cmd)
 	},
 }
 
+// prepareCommand resolves the effective configuration for cmd and, when --group or --tag
+// selects inventory hosts, runs the command on each of them instead
+func prepareCommand(cmd *cobra.Command) {
+	if isCompletionRequest(cmd) {
+		return
+	}
+	// Check if this command or its parent is one that doesn't require config
+	cmdPath := cmd.CommandPath()
+	noConfigRequired := strings.Contains(cmdPath, "config") ||
+		strings.Contains(cmdPath, "version") ||
+		strings.Contains(cmdPath, "help") ||
+		strings.Contains(cmdPath, "completion") ||
+		strings.Contains(cmdPath, "sessions") ||
+		strings.Contains(cmdPath, "history") ||
+		cmdPath == "dgx" || // the command palette
+		cmdPath == "dgx models fit" || // only Model Runner references need the DGX
+		cmdPath == "dgx new" ||
+		cmdPath == "dgx usage report" || // reads the local usage log
+		(strings.HasPrefix(cmdPath, "dgx recipes") && cmdPath != "dgx recipes run")
+
+	if err := cfgManager.Apply(connectionOverrides(cmd)); err != nil && !noConfigRequired {
+		exitWithError(exitcode.Wrap(exitcode.Config, err))
+	}
+	if plain, _ := cmd.Flags().GetBool("plain"); plain || cfgManager.File().Plain {
+		ui.SetPlain(true)
+	}
+	startHistory(cmd)
+
+	if active := cfgManager.Get().ActiveProfile; cfgManager.File().Profiles[active].Stale {
+		fmt.Fprintf(os.Stderr, "Warning: profile %s is no longer in the NVIDIA Sync config\n", active)
+	}
+
+	if !noConfigRequired && !cfgManager.IsConfigured() {
+		fmt.Fprintf(os.Stderr, "Error: DGX not configured. Run 'dgx config set' first.\n")
+		exit(exitcode.Config)
+	}
+	if !noConfigRequired {
+		installHostTracker()
+	}
+
+	// fleet commands and dgx logs take --group and --export themselves
+	isFleet := strings.HasPrefix(cmdPath, "dgx fleet") || cmdPath == "dgx logs"
+	if export, _ := cmd.Flags().GetString("export"); export != "" && !isFleet && !groupSelected(cmd) {
+		fmt.Fprintln(os.Stderr, "Error: --export needs --group/--tag or a dgx fleet command")
+		exit(exitcode.Usage)
+	}
+	if groupSelected(cmd) && !noConfigRequired && !isFleet {
+		fanOutToGroup(cmd)
+	}
+}
+
 // config command
 var configCmd = &cobra.Command{
 	Use:   "config",
@@ -68,7 +137,7 @@ var configSetCmd = &cobra.Command{
 	Use:   "set",
 	Short: "Set DGX configuration interactively",
 	Run: func(cmd *cobra.Command, args []string) {
-		cfg := cfgManager.Get()
+		cfg := cfgManager.File()
 		home, _ := os.UserHomeDir()
 
 		defaultKeys := []string{
@@ -173,34 +242,27 @@ var configSetCmd = &cobra.Command{
 					}
 				}
 			} else {
-				fmt.Println("Warning: No SSH key found in ~/.ssh/")
-				fmt.Println()
-				fmt.Println("To generate a new SSH key, run:")
-				fmt.Println("  ssh-keygen -t ed25519 -C \"your-email@example.com\"")
+				fmt.Println("No SSH key found in ~/.ssh/")
+				fmt.Println("Without a key, dgx logs in with your DGX password.")
+				fmt.Println("To create and install a dedicated key afterwards, run:")
+				fmt.Println("  dgx key setup")
 				fmt.Println()
-				fmt.Println("Then copy it to your DGX:")
-				fmt.Printf("  ssh-copy-id %s@%s\n", cfg.User, cfg.Host)
-				fmt.Println()
-				fmt.Print("Enter SSH key path (or press Enter to use default): ")
+				fmt.Print("Enter SSH key path (or press Enter to use password authentication): ")
 				var keyPath string
 				fmt.Scanln(&keyPath)
-				if keyPath != "" {
-					cfg.IdentityFile = keyPath
-				} else {
-					cfg.IdentityFile = filepath.Join(home, ".ssh", "id_ed25519")
-				}
+				cfg.IdentityFile = keyPath
 			}
 		}
 
 		// Validate minimum config
 		if cfg.Host == "" || cfg.User == "" {
 			fmt.Fprintf(os.Stderr, "\nError: Hostname and Username are required\n")
-			os.Exit(1)
+			exit(exitcode.Config)
 		}
 
 		if err := cfgManager.Set(cfg); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: Failed to save config: %v\n", err)
-			os.Exit(1)
+			exit(exitcode.Config)
 		}
 
 		fmt.Println()
@@ -208,6 +270,9 @@ var configSetCmd = &cobra.Command{
 		fmt.Printf("Config file: %s\n", cfgManager.GetConfigPath())
 		fmt.Println()
 		fmt.Println("Next steps:")
+		if cfg.IdentityFile == "" {
+			fmt.Println("  dgx key setup # Install a key for passwordless login")
+		}
 		fmt.Println("  dgx status    # Test connection")
 		fmt.Println("  dgx connect   # SSH to DGX")
 		fmt.Println("  dgx gpu       # Check GPU status")
@@ -234,20 +299,55 @@ var connectCmd = &cobra.Command{
 	Short:   "Open an interactive SSH shell to DGX",
 	Aliases: []string{"ssh"},
 	Run: func(cmd *cobra.Command, args []string) {
+		requireShell(cfgManager.Get(), "dgx connect")
 		client, err := ssh.NewClient(cfgManager.Get())
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			exitWithError(err)
 		}
 
 		fmt.Printf("Connecting to %s@%s...\n", cfgManager.Get().User, cfgManager.Get().Host)
+		if record, _ := cmd.Flags().GetBool("record"); record {
+			recordShell(client)
+			return
+		}
 		if err := client.InteractiveShell(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			exitWithError(err)
 		}
 	},
 }
 
+// recordShell runs the interactive shell over the Go SSH client so its output can be
+// captured, and saves it as a session recording
+func recordShell(client *ssh.Client) {
+	defer client.Close()
+	cfg := cfgManager.Get()
+	width, height := terminalSize(int(os.Stdin.Fd()))
+	rec, id, err := session.Create(sessionsDir(), "connect", session.Header{
+		Width:  width,
+		Height: height,
+		Title:  fmt.Sprintf("%s@%s", cfg.User, cfg.Host),
+		Env:    map[string]string{"TERM": os.Getenv("TERM")},
+	})
+	if err != nil {
+		exitWithError(err)
+	}
+
+	initial := true
+	err = client.Shell(rec, func(w, h int) {
+		// The header already holds the starting size
+		if initial {
+			initial = false
+			return
+		}
+		rec.Resize(w, h)
+	})
+	rec.Close()
+	fmt.Printf("Session recorded as %s (replay with 'dgx sessions play %s')\n", id, id)
+	if err != nil {
+		exitWithError(err)
+	}
+}
+
 // status command
 var statusCmd = &cobra.Command{
 	Use:   "status",
@@ -256,15 +356,14 @@ var statusCmd = &cobra.Command{
 		cfg := cfgManager.Get()
 		client, err := ssh.NewClient(cfg)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			exitWithError(err)
 		}
 
 		fmt.Printf("Checking connection to %s@%s:%d...\n", cfg.User, cfg.Host, cfg.Port)
 		latency, err := client.CheckConnection()
 		if err != nil {
 			fmt.Printf("Connection failed: %v\n", err)
-			os.Exit(1)
+			exit(exitcode.Connection)
 		}
 
 		fmt.Printf("Connected (latency: %v)\n", latency)
@@ -273,9 +372,52 @@ var statusCmd = &cobra.Command{
 		tm := tunnel.NewManager(cfg)
 		tunnels, _ := tm.List()
 		fmt.Printf("Active tunnels: %d\n", len(tunnels))
+
+		cache := probeCache(cmd)
+		if devices, age, err := cachedGPUs(cache, cfg, client); err != nil {
+			fmt.Printf("GPU: unavailable (%v)\n", err)
+		} else {
+			for _, d := range devices {
+				fmt.Printf("GPU %d: %s, driver %s%s\n", d.Index, d.Name, d.Driver, cacheNote(age))
+			}
+		}
+		if models, age, err := cachedModels(cache, cfg, client); err != nil {
+			fmt.Println("Models: Docker Model Runner not reachable")
+		} else {
+			fmt.Printf("Models: %d in Docker Model Runner%s\n", len(models), cacheNote(age))
+		}
+		printPinDrift(cfg, client)
 	},
 }
 
+// printPinDrift compares the pinned component versions with the installed ones
+func printPinDrift(cfg *types.Config, client *ssh.Client) {
+	if cfg.Pins == nil {
+		return
+	}
+	statuses, err := pins.Check(context.Background(), client, cfg.Pins)
+	if err != nil {
+		fmt.Printf("Pins: unavailable (%v)\n", err)
+		return
+	}
+	drifted := 0
+	for _, s := range statuses {
+		state := "ok"
+		if s.Drift {
+			state = "DRIFT"
+			drifted++
+		}
+		installed := s.Installed
+		if installed == "" {
+			installed = "not installed"
+		}
+		fmt.Printf("Pin %s: %s, installed %s [%s]\n", s.Component, s.Pinned, installed, state)
+	}
+	if drifted > 0 {
+		fmt.Println("Reinstall the pinned versions with 'dgx run dmr setup', 'dgx run dmr update', or 'dgx run nvidia update'")
+	}
+}
+
 // tunnel command
 var tunnelCmd = &cobra.Command{
 	Use:     "tunnel",
@@ -292,19 +434,19 @@ var tunnelCreateCmd = &cobra.Command{
 		parts := strings.Split(args[0], ":")
 		if len(parts) != 2 {
 			fmt.Fprintf(os.Stderr, "Error: Invalid format. Use <local-port>:<remote-port>\n")
-			os.Exit(1)
+			exit(exitcode.Usage)
 		}
 
 		localPort, err := strconv.Atoi(parts[0])
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: Invalid local port: %s\n", parts[0])
-			os.Exit(1)
+			exit(exitcode.Usage)
 		}
 
 		remotePort, err := strconv.Atoi(parts[1])
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: Invalid remote port: %s\n", parts[1])
-			os.Exit(1)
+			exit(exitcode.Usage)
 		}
 
 		description := ""
@@ -317,7 +459,7 @@ var tunnelCreateCmd = &cobra.Command{
 		// Check if port is already in use
 		if tm.IsPortInUse(localPort) {
 			fmt.Fprintf(os.Stderr, "Error: Local port %d is already in use\n", localPort)
-			os.Exit(1)
+			exit(1)
 		}
 
 		t := types.Tunnel{
@@ -329,8 +471,7 @@ var tunnelCreateCmd = &cobra.Command{
 		}
 
 		if err := tm.Create(t); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			exitWithError(err)
 		}
 
 		// Save to config
@@ -346,8 +487,7 @@ var tunnelListCmd = &cobra.Command{
 		tm := tunnel.NewManager(cfgManager.Get())
 		tunnels, err := tm.List()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			exitWithError(err)
 		}
 
 		if len(tunnels) == 0 {
@@ -373,13 +513,12 @@ var tunnelKillCmd = &cobra.Command{
 		pid, err := strconv.Atoi(args[0])
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: Invalid PID: %s\n", args[0])
-			os.Exit(1)
+			exit(exitcode.Usage)
 		}
 
 		tm := tunnel.NewManager(cfgManager.Get())
 		if err := tm.Kill(pid); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			exitWithError(err)
 		}
 	},
 }
@@ -390,8 +529,7 @@ var tunnelKillAllCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		tm := tunnel.NewManager(cfgManager.Get())
 		if err := tm.KillAll(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			exitWithError(err)
 		}
 		fmt.Println("All tunnels terminated")
 	},
@@ -404,8 +542,7 @@ var gpuCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		client, err := ssh.NewClient(cfgManager.Get())
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			exitWithError(err)
 		}
 		defer client.Close()
 
@@ -417,15 +554,13 @@ var gpuCmd = &cobra.Command{
 		if raw {
 			output, err := monitor.GetStatusText()
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-				os.Exit(1)
+				exitWithError(err)
 			}
 			fmt.Println(output)
 		} else {
 			gpus, err := monitor.GetStatus()
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-				os.Exit(1)
+				exitWithError(err)
 			}
 
 			fmt.Println(gpu.FormatGPUStatus(gpus))
@@ -433,6 +568,109 @@ var gpuCmd = &cobra.Command{
 	},
 }
 
+var gpuKillCmd = &cobra.Command{
+	Use:   "kill [pid]",
+	Short: "Kill a GPU process or stop the container that owns it",
+	Long: `Kill a process holding the GPU, e.g. a stuck inference server.
+
+Without a PID, GPU processes are listed and you are prompted to pick one.
+Processes running inside a docker container are stopped via 'docker stop'
+unless --process is given.
+
+Examples:
+  dgx gpu kill
+  dgx gpu kill 12345 --force`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := ssh.NewClient(cfgManager.Get())
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+
+		monitor := gpu.NewMonitor(client)
+		processes, err := monitor.ListProcesses()
+		if err != nil {
+			exitWithError(err)
+		}
+		if len(processes) == 0 {
+			fmt.Println("No GPU processes running")
+			return
+		}
+
+		var target *types.GPUProcess
+		if len(args) == 1 {
+			pid, err := strconv.Atoi(args[0])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: Invalid PID: %s\n", args[0])
+				exit(exitcode.Usage)
+			}
+			for i := range processes {
+				if processes[i].PID == pid {
+					target = &processes[i]
+					break
+				}
+			}
+			if target == nil {
+				fmt.Fprintf(os.Stderr, "Error: PID %d is not using the GPU\n", pid)
+				exit(1)
+			}
+		} else {
+			fmt.Println("GPU Processes:")
+			for i, proc := range processes {
+				fmt.Printf("  [%d] PID %-8d %-40s %s\n", i+1, proc.PID, proc.Name, proc.MemoryUsage)
+			}
+			fmt.Printf("Select process [1-%d]: ", len(processes))
+			var choice string
+			fmt.Scanln(&choice)
+			idx, err := strconv.Atoi(choice)
+			if err != nil || idx < 1 || idx > len(processes) {
+				fmt.Println("No process selected.")
+				return
+			}
+			target = &processes[idx-1]
+		}
+
+		force, _ := cmd.Flags().GetBool("force")
+		yes, _ := cmd.Flags().GetBool("yes")
+		processOnly, _ := cmd.Flags().GetBool("process")
+
+		containerID, containerName := "", ""
+		if !processOnly {
+			containerID, containerName, err = monitor.ProcessContainer(target.PID)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}
+
+		if containerID != "" {
+			label := containerName
+			if label == "" {
+				label = containerID[:12]
+			}
+			fmt.Printf("PID %d (%s) belongs to container %s.\n", target.PID, target.Name, label)
+			if !confirmCommand(cmd, fmt.Sprintf("Stop container %s?", label), yes) {
+				fmt.Println("Cancelled.")
+				exit(exitcode.Aborted)
+			}
+			if err := monitor.StopContainer(containerID); err != nil {
+				exitWithError(err)
+			}
+			fmt.Printf("Container %s stopped\n", label)
+			return
+		}
+
+		if !confirmCommand(cmd, fmt.Sprintf("Kill PID %d (%s)?", target.PID, target.Name), yes) {
+			fmt.Println("Cancelled.")
+			exit(exitcode.Aborted)
+		}
+		if err := monitor.KillProcess(target.PID, force); err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("PID %d terminated\n", target.PID)
+	},
+}
+
 // sync command
 var syncCmd = &cobra.Command{
 	Use:   "sync <source> <destination>",
@@ -440,29 +678,60 @@ var syncCmd = &cobra.Command{
 	Long: `Sync files using rsync.
 Examples:
   dgx sync ./code dgx:~/projects/  # Upload to DGX
-  dgx sync dgx:~/results ./        # Download from DGX`,
+  dgx sync dgx:~/results ./        # Download from DGX
+  dgx sync --bwlimit 5M ./data dgx:~/data  # Cap the transfer at 5 MiB/s
+  dgx sync --compress dgx:~/checkpoints ./  # zstd on the wire
+
+--bwlimit takes a rate in bytes per second with an optional K, M, or G suffix
+(binary units). The limit is enforced by dgx itself, which carries the rsync
+stream over its own SSH connection, so it needs key or agent authentication: a
+password prompt cannot share stdin with rsync.
+
+rsync always compresses in transit; --compress asks for zstd instead of zlib,
+which is much faster on large checkpoints. Both rsyncs must be 3.2 or later
+built with zstd, and the transfer falls back to zlib when either is not.`,
 	Args: cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
-		client, err := ssh.NewClient(cfgManager.Get())
+		cfg := cfgManager.Get()
+		requireFileAccess(cfg, "dgx sync")
+		client, err := ssh.NewClient(cfg)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			exitWithError(err)
 		}
 
 		source := args[0]
 		dest := args[1]
-		cfg := cfgManager.Get()
 
 		// Replace "dgx:" with actual SSH path
 		source = strings.ReplaceAll(source, "dgx:", fmt.Sprintf("%s@%s:", cfg.User, cfg.Host))
 		dest = strings.ReplaceAll(dest, "dgx:", fmt.Sprintf("%s@%s:", cfg.User, cfg.Host))
 
 		deleteFlag, _ := cmd.Flags().GetBool("delete")
+		bwlimit, _ := cmd.Flags().GetString("bwlimit")
+
+		rsh := ""
+		if bwlimit != "" {
+			rate, err := transfer.ParseRate(bwlimit)
+			if err != nil {
+				exitWithError(exitcode.Wrap(exitcode.Usage, err))
+			}
+			if rsh, err = throttledRsh(cfg, rate); err != nil {
+				exitWithError(err)
+			}
+		}
+
+		var extra []string
+		if compress, _ := cmd.Flags().GetBool("compress"); compress {
+			if err := transfer.ProbeRsyncZstd(client.Execute); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: using rsync's default compression: %v\n", err)
+			} else {
+				extra = transfer.RsyncZstdArgs
+			}
+		}
 
 		fmt.Printf("Syncing %s -> %s\n", args[0], args[1])
-		if err := client.Rsync(source, dest, deleteFlag); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+		if err := client.Rsync(source, dest, deleteFlag, rsh, extra...); err != nil {
+			exitWithError(err)
 		}
 
 		fmt.Println("Sync complete")
@@ -473,7 +742,9 @@ Examples:
 var setupKeyCmd = &cobra.Command{
 	Use:   "setup-key",
 	Short: "Setup SSH key authentication with DGX",
-	Long:  `Helps you copy your SSH public key to the DGX for passwordless authentication.`,
+	Long: `Helps you copy your SSH public key to the DGX for passwordless authentication.
+
+See also 'dgx key setup', which generates a dedicated key and installs it without ssh-copy-id.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		cfg := cfgManager.Get()
 
@@ -483,7 +754,7 @@ var setupKeyCmd = &cobra.Command{
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: Cannot read public key at %s\n", pubKeyPath)
 			fmt.Fprintf(os.Stderr, "Make sure your SSH key pair exists.\n")
-			os.Exit(1)
+			exit(1)
 		}
 
 		fmt.Println("SSH Key Setup for DGX")
@@ -526,7 +797,7 @@ var setupKeyCmd = &cobra.Command{
 			if err := cmd.Run(); err != nil {
 				fmt.Fprintf(os.Stderr, "\nWarning: Automatic setup failed.\n")
 				fmt.Fprintf(os.Stderr, "Please use the manual method shown above.\n")
-				os.Exit(1)
+				exit(1)
 			}
 
 			fmt.Println()
@@ -592,19 +863,45 @@ var runCmd = &cobra.Command{
 	Long: `Execute playbooks for various AI/ML workloads on your DGX Spark.
 
 Available playbooks:
-  ollama  - Local model runner (install, pull, serve, run)
-  vllm    - Optimized LLM inference (pull, serve, status)
-  nvfp4   - 4-bit quantization (setup, quantize)
-  dmr     - Docker Model Runner (setup, install, pull, run, status, logs)
+  ollama   - Local model runner (install, pull, serve, run)
+  vllm     - Optimized LLM inference (pull, serve, status)
+  nvfp4    - 4-bit quantization (setup, quantize)
+  dmr      - Docker Model Runner (setup, install, pull, run, ps, unload, status, logs, rollback)
+  pyenv    - Python environments with CUDA PyTorch (create, list, remove, activate)
+  devsetup - Developer tools and dotfiles from the config's devsetup list (install, status)
+  time     - Clock skew check and NTP setup with chrony or systemd-timesyncd (status, sync)
+  memory   - Swap file, zram, and memory pressure (status, configure)
+  tune     - Kernel sysctl, hugepage, and IOMMU tuning (diff, apply, rollback)
+  sdgen    - Image generation server with ComfyUI or sd-webui (deploy, status, stop)
+  webui    - Open WebUI connected to the DGX's model servers (install, status, update, uninstall)
+  monitoring - Grafana and Prometheus with a DGX Spark dashboard (grafana, status, uninstall)
+  nvidia   - NVIDIA driver version check and package updates (status, update)
+  whisper  - Audio transcription with faster-whisper (<audio-file>, setup, cache)
+
+Commands that take a model (dmr pull/run/unload, ollama pull/run, vllm serve,
+nvfp4 quantize) open a fuzzy-searchable model picker when it is left out.
 
 Examples:
   dgx run ollama install
   dgx run ollama pull qwen2.5:32b
   dgx run vllm serve meta-llama/Llama-2-7b-hf
   dgx run nvfp4 quantize meta-llama/Llama-2-7b-hf
-  dgx run dmr status`,
+  dgx run dmr status
+  dgx run dmr run   # pick the model from a list
+  dgx run pyenv create train --cuda 12.x --python 3.11
+  dgx run --record ollama install   # keep a replayable log (see 'dgx sessions')
+  dgx run --gpu-status nvfp4 quantize meta-llama/Llama-2-7b-hf   # live GPU footer`,
 	DisableFlagParsing: true,
 	Run: func(cmd *cobra.Command, args []string) {
+		// Flag parsing is disabled, so root flags such as --host arrive as arguments
+		args, err := takeRootFlags(cmd, args)
+		if err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Usage, err))
+		}
+		prepareCommand(cmd)
+
+		args, record := takeFlag(args, "--record")
+		args, gpuStatus := takeFlag(args, "--gpu-status")
 		if len(args) == 0 || isHelpArg(args[0]) {
 			cmd.Help()
 			return
@@ -612,26 +909,118 @@ Examples:
 
 		client, err := ssh.NewClient(cfgManager.Get())
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			exitWithError(err)
 		}
 		defer client.Close()
+		redactRemoteSecrets(client)
 
-		manager := playbook.NewManager(client)
+		manager := newPlaybookManager(client)
 		playbookName := args[0]
 		playbookArgs := args[1:]
 		if len(playbookArgs) > 0 && isHelpArg(playbookArgs[0]) {
 			playbook.PrintHelp(playbookName)
 			return
 		}
+		playbookArgs = pickPlaybookModel(cmd, client, playbookName, playbookArgs)
+
+		stopRecording := func() {}
+		if record {
+			stop, err := startRecording("run-"+playbookName, "", sessionCommand(append([]string{"run"}, args...)...))
+			if err != nil {
+				exitWithError(err)
+			}
+			stopRecording = func() {
+				id := stop()
+				fmt.Printf("Session recorded as %s\n", id)
+			}
+		}
 
-		if err := manager.Execute(playbookName, playbookArgs); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+		var status *gpu.StatusLine
+		if gpuStatus {
+			status = startGPUStatus(cfgManager.Get())
 		}
+		err = manager.Execute(playbookName, playbookArgs)
+		status.Stop()
+		stopRecording()
+		if playbookName == "dmr" {
+			// pull, uninstall, and friends change the model list shown by status and completion
+			probeCache(cmd).Invalidate(modelsCacheKey(cfgManager.Get()))
+		}
+		if err != nil {
+			exitWithError(err)
+		}
+		rememberModel(playbookModel(playbookName, playbookArgs))
 	},
 }
 
+// newPlaybookManager returns a playbook manager set up from the active configuration
+func newPlaybookManager(client *ssh.Client) *playbook.Manager {
+	cfg := cfgManager.Get()
+	manager := playbook.NewManager(client)
+	if retries := cfg.PlaybookRetries; retries != nil {
+		manager.SetRetries(*retries)
+	}
+	manager.SetDevSetup(cfg.DevSetup)
+	if err := pins.Validate(cfg.Pins); err != nil {
+		exitWithError(exitcode.Wrap(exitcode.Config, err))
+	}
+	manager.SetPins(cfg.Pins)
+	manager.SetReadOnly(cfg.ReadOnly)
+	manager.SetPolicy(policy.New(cfg.Confirm, cfg.Host))
+	manager.SetTunnels(tunnel.NewManager(cfg))
+	return manager
+}
+
+// startGPUStatus shows a live GPU footer sampled over a second SSH connection, so the
+// job's own sessions are unaffected. It returns nil, after a warning, when sampling can't
+// start.
+func startGPUStatus(cfg *types.Config) *gpu.StatusLine {
+	if ui.Plain() {
+		fmt.Fprintln(os.Stderr, "Warning: GPU status line disabled in plain mode; run 'dgx gpu' for the same figures")
+		return nil
+	}
+	client, err := ssh.NewClient(cfg)
+	if err == nil {
+		err = client.Connect()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: GPU status line disabled: %v\n", err)
+		return nil
+	}
+	loop := pollScheduler().Register(cfg.Host, "gpu", time.Second)
+	status := gpu.StartStatusLine(client, loop)
+	if status == nil {
+		loop.Close()
+		client.Close()
+	}
+	return status
+}
+
+// exitWithError reports err and exits with the code for its failure category. An
+// exitcode.ErrAborted, however wrapped, is not printed, since the prompt that was declined
+// already said so.
+func exitWithError(err error) {
+	if !errors.Is(err, exitcode.ErrAborted) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+	exit(exitcode.Of(err))
+}
+
+// confirmAction asks a yes/no question that defaults to "no".
+func confirmAction(prompt string) bool {
+	fmt.Printf("%s [y/N]: ", prompt)
+	var response string
+	fmt.Scanln(&response)
+	return strings.EqualFold(response, "y") || strings.EqualFold(response, "yes")
+}
+
+// confirmCommand asks before cmd goes ahead. Commands classified as destructive follow
+// the connection's confirm policy, which may require typing the hostname even with --yes.
+func confirmCommand(cmd *cobra.Command, prompt string, yes bool) bool {
+	cfg := cfgManager.Get()
+	return policy.New(cfg.Confirm, cfg.Host).Allow(policy.Classify(cmd.CommandPath()), prompt, yes)
+}
+
 func isHelpArg(arg string) bool {
 	return arg == "-h" || arg == "--help" || strings.EqualFold(arg, "help")
 }
@@ -651,18 +1040,31 @@ func promptForSecret(label string) (string, error) {
 }
 
 func setRemoteEnvVar(varName, value string) error {
+	return setRemoteEnvVars(map[string]string{varName: value})
+}
+
+// setRemoteEnvVars stores several variables in the env store in one update
+func setRemoteEnvVars(vars map[string]string) error {
 	client, err := ssh.NewClient(cfgManager.Get())
 	if err != nil {
 		return err
 	}
 	defer client.Close()
 
-	encoded := base64.StdEncoding.EncodeToString([]byte(value))
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	encoded, err := json.Marshal(vars)
+	if err != nil {
+		return err
+	}
 	script := fmt.Sprintf(`
-import base64, os, pathlib, shlex
+import base64, json, os, pathlib, shlex
 
-name = "%s"
-value = base64.b64decode(os.environ["ENV_VALUE"]).decode()
+names = %s
+values = json.loads(base64.b64decode(os.environ["ENV_VALUES"]))
 
 config_dir = pathlib.Path.home() / ".config" / "dgx"
 env_file = config_dir / "env.sh"
@@ -671,9 +1073,10 @@ config_dir.mkdir(parents=True, exist_ok=True)
 lines = []
 if env_file.exists():
     for line in env_file.read_text().splitlines():
-        if not line.startswith(f"export {name}="):
+        if not any(line.startswith(f"export {name}=") for name in names):
             lines.append(line)
-lines.append(f"export {name}={shlex.quote(value)}")
+for name in names:
+    lines.append(f"export {name}={shlex.quote(values[name])}")
 env_file.write_text("\n".join(lines) + "\n")
 
 bashrc = pathlib.Path.home() / ".bashrc"
@@ -688,10 +1091,10 @@ if source_line not in content:
             fh.write("\n")
         fh.write(source_line + "\n")
 
-print(f"Stored {name} in {env_file} and ensured {bashrc} sources it.")
-`, varName)
+print(f"Stored {', '.join(names)} in {env_file} and ensured {bashrc} sources it.")
+`, pythonList(names))
 
-	command := fmt.Sprintf("ENV_VALUE=%s python3 - <<'PY'\n%s\nPY", ssh.ShellQuote(encoded), script)
+	command := fmt.Sprintf("ENV_VALUES=%s python3 - <<'PY'\n%s\nPY", ssh.ShellQuote(base64.StdEncoding.EncodeToString(encoded)), script)
 	output, err := client.Execute(command)
 	if err != nil {
 		return fmt.Errorf("remote update failed: %w", err)
@@ -702,6 +1105,15 @@ print(f"Stored {name} in {env_file} and ensured {bashrc} sources it.")
 	return nil
 }
 
+// pythonList renders names, which are variable names, as a Python list literal
+func pythonList(names []string) string {
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = fmt.Sprintf("%q", n)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
 func expandPath(p string) (string, error) {
 	if p == "" {
 		return "", fmt.Errorf("path cannot be empty")
@@ -731,7 +1143,7 @@ func ensureRemoteDirectory(path string) error {
 
 func syncDirectoryToRemote(localPath, remotePath string, deleteExtraneous bool) error {
 	cfg := cfgManager.Get()
-	sshCmd := fmt.Sprintf("ssh -i %q -p %d", cfg.IdentityFile, cfg.Port)
+	sshCmd := ssh.SSHCommand(cfg)
 	local := ensureTrailingSlash(localPath)
 	remote := fmt.Sprintf("%s@%s:%s", cfg.User, cfg.Host, ensureTrailingSlash(remotePath))
 	args := []string{"-az", "-e", sshCmd}
@@ -755,7 +1167,7 @@ func ensureTrailingSlash(path string) string {
 func ensureMutagen() {
 	if _, err := exec.LookPath("mutagen"); err != nil {
 		fmt.Fprintln(os.Stderr, "Error: mutagen CLI not found. Install from https://mutagen.io/ before using this command.")
-		os.Exit(1)
+		exit(1)
 	}
 }
 
@@ -765,7 +1177,7 @@ func runMutagen(args ...string) {
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
 		fmt.Fprintf(os.Stderr, "mutagen command failed: %v\n", err)
-		os.Exit(1)
+		exit(1)
 	}
 }
 
@@ -787,7 +1199,8 @@ var envCmd = &cobra.Command{
 
 Examples:
   dgx env hf-token
-  dgx env wandb --value your_api_key`,
+  dgx env wandb --value your_api_key
+  dgx env mlflow --value http://mlflow.lab:5000`,
 }
 
 var envHFTokenCmd = &cobra.Command{
@@ -799,13 +1212,11 @@ var envHFTokenCmd = &cobra.Command{
 			var err error
 			value, err = promptForSecret("Hugging Face token")
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-				os.Exit(1)
+				exitWithError(err)
 			}
 		}
 		if err := setRemoteEnvVar("HF_TOKEN", value); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			exitWithError(err)
 		}
 	},
 }
@@ -830,13 +1241,11 @@ var codexSetAPIKeyCmd = &cobra.Command{
 			var err error
 			value, err = promptForSecret("Codex API key")
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-				os.Exit(1)
+				exitWithError(err)
 			}
 		}
 		if err := setRemoteEnvVar("CODEX_API_KEY", value); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			exitWithError(err)
 		}
 	},
 }
@@ -848,22 +1257,18 @@ var codexImportConfigCmd = &cobra.Command{
 		pathFlag, _ := cmd.Flags().GetString("path")
 		localPath, err := expandPath(pathFlag)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			exitWithError(err)
 		}
 		if _, err := os.Stat(localPath); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			exitWithError(err)
 		}
 
 		if err := ensureRemoteDirectory("~/.codex"); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			exitWithError(err)
 		}
 
 		if err := syncDirectoryToRemote(localPath, "~/.codex", true); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			exitWithError(err)
 		}
 
 		fmt.Println("Copied local Codex configuration to DGX (~/.codex).")
@@ -897,7 +1302,7 @@ var mutagenCreateCmd = &cobra.Command{
 		local := args[0]
 		remote := resolveRemotePath(args[1], cfg)
 
-		sshCmd := fmt.Sprintf("ssh -i %q -p %d", cfg.IdentityFile, cfg.Port)
+		sshCmd := ssh.SSHCommand(cfg)
 		mutagenArgs := []string{"sync", "create", "--name", name, "--ssh-command", sshCmd}
 
 		if mode, _ := cmd.Flags().GetString("mode"); mode != "" {
@@ -980,13 +1385,35 @@ var envWandbCmd = &cobra.Command{
 			var err error
 			value, err = promptForSecret("Weights & Biases API key")
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-				os.Exit(1)
+				exitWithError(err)
 			}
 		}
 		if err := setRemoteEnvVar("WANDB_API_KEY", value); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			exitWithError(err)
+		}
+	},
+}
+
+var envMLflowCmd = &cobra.Command{
+	Use:   "mlflow",
+	Short: "Set MLFLOW_TRACKING_URI on the DGX",
+	Run: func(cmd *cobra.Command, args []string) {
+		value, _ := cmd.Flags().GetString("value")
+		token, _ := cmd.Flags().GetString("token")
+		if value == "" {
+			var err error
+			value, err = promptForSecret("MLflow tracking URI")
+			if err != nil {
+				exitWithError(err)
+			}
+		}
+		if err := setRemoteEnvVar("MLFLOW_TRACKING_URI", value); err != nil {
+			exitWithError(err)
+		}
+		if token != "" {
+			if err := setRemoteEnvVar("MLFLOW_TRACKING_TOKEN", token); err != nil {
+				exitWithError(err)
+			}
 		}
 	},
 }
@@ -995,27 +1422,59 @@ var envWandbCmd = &cobra.Command{
 var execCmd = &cobra.Command{
 	Use:   "exec <command>",
 	Short: "Execute a command on the DGX",
-	Long:  `Run an arbitrary shell command on your DGX Spark.`,
-	Args:  cobra.MinimumNArgs(1),
+	Long: `Run an arbitrary shell command on your DGX Spark.
+
+When the connection has an exec_allow list in the config, only commands matching
+one of its patterns run, and they run without a shell: pipes, redirects, and
+variables reach the command as plain arguments.`,
+	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		command, err := execCommand(cfgManager.Get(), args)
+		if err != nil {
+			exitWithError(err)
+		}
 		client, err := ssh.NewClient(cfgManager.Get())
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			exitWithError(err)
 		}
 		defer client.Close()
+		redactRemoteSecrets(client)
 
-		command := strings.Join(args, " ")
 		output, err := client.Execute(command)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			exitWithError(err)
 		}
 
-		fmt.Print(output)
+		fmt.Print(redact.Default().String(output))
 	},
 }
 
+// execCommand returns the remote command for the arguments of 'dgx exec', checked against
+// the connection's exec_allow list (see policy.ExecCommand)
+func execCommand(cfg *types.Config, args []string) (string, error) {
+	command, err := policy.ExecCommand(cfg.ExecAllow, args)
+	if err != nil {
+		return "", exitcode.Wrap(exitcode.Usage, err)
+	}
+	return command, nil
+}
+
+// requireShell stops commands that open an unrestricted shell when the connection
+// limits 'dgx exec' to an allowlist
+func requireShell(cfg *types.Config, command string) {
+	if err := policy.RequireShell(cfg.ExecAllow, command); err != nil {
+		exitWithError(exitcode.Wrap(exitcode.Usage, err))
+	}
+}
+
+// requireFileAccess stops commands that can write any file on the DGX when the
+// connection limits 'dgx exec' to an allowlist
+func requireFileAccess(cfg *types.Config, command string) {
+	if err := policy.RequireFileAccess(cfg.ExecAllow, command); err != nil {
+		exitWithError(exitcode.Wrap(exitcode.Usage, err))
+	}
+}
+
 // version command
 var versionCmd = &cobra.Command{
 	Use:   "version",
@@ -1041,15 +1500,27 @@ func init() {
 
 	// gpu flags
 	gpuCmd.Flags().BoolP("raw", "r", false, "Show raw nvidia-smi output")
+	gpuKillCmd.Flags().BoolP("force", "f", false, "Send SIGKILL instead of SIGTERM")
+	gpuKillCmd.Flags().BoolP("yes", "y", false, "Skip confirmation")
+	gpuKillCmd.Flags().Bool("process", false, "Kill the process directly even if it runs in a container")
+	gpuCmd.AddCommand(gpuKillCmd)
 
 	// sync flags
 	syncCmd.Flags().BoolP("delete", "d", false, "Delete extraneous files from destination")
+	syncCmd.Flags().String("bwlimit", "", "Limit bandwidth in each direction, e.g. 500K or 10M (bytes per second)")
+	syncCmd.Flags().Bool("compress", false, "Compress with zstd in transit when both rsyncs support it")
+
+	// connect flags
+	connectCmd.Flags().Bool("record", false, "Record the session (see 'dgx sessions')")
 
 	// env subcommands
 	envHFTokenCmd.Flags().String("value", "", "Token to set (omit to be prompted)")
 	envWandbCmd.Flags().String("value", "", "API key to set (omit to be prompted)")
+	envMLflowCmd.Flags().String("value", "", "Tracking server URI to set (omit to be prompted)")
+	envMLflowCmd.Flags().String("token", "", "Optional bearer token for the tracking server")
 	envCmd.AddCommand(envHFTokenCmd)
 	envCmd.AddCommand(envWandbCmd)
+	envCmd.AddCommand(envMLflowCmd)
 
 	// codex subcommands
 	codexSetAPIKeyCmd.Flags().String("value", "", "API key to set (omit to be prompted)")
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/artifacts"
+	"github.com/weatherman/dgx-manager/internal/dmr"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/fit"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+// models command
+var modelsCmd = &cobra.Command{
+	Use:   "models",
+	Short: "Plan which models the DGX can run",
+}
+
+var modelsFitCmd = &cobra.Command{
+	Use:   "fit <model>",
+	Short: "Estimate a model's memory needs and whether it fits on one Spark or a pair",
+	Long: `Estimate the memory a model needs at common quantizations and context lengths,
+and report whether each configuration fits on a single DGX Spark or needs a
+two-node pair (tensor parallel over the ConnectX link, see 'dgx cluster').
+
+The parameter count and architecture come from:
+  org/repo       the Hugging Face Hub (HF_TOKEN is sent for gated repositories)
+  ai/name:tag    Docker Model Runner metadata on the DGX, when the model is pulled
+  anything else  the parameter count in the name, such as 70B or 360M
+
+The estimate counts the weights, an fp16 KV cache for the full context, and
+runtime overhead. Without the model's architecture the KV cache is
+approximated. Treat results close to the limit as a maybe: engines such as
+vLLM also reserve memory up front (see 'dgx gpu reserve').
+
+Examples:
+  dgx models fit meta-llama/Llama-3.1-70B-Instruct
+  dgx models fit ai/smollm2:360M-Q4_K_M
+  dgx models fit Qwen/Qwen2.5-32B-Instruct --context 8K,64K
+  dgx models fit my-finetune --params 13B --json`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeFirst("models"),
+	Run: func(cmd *cobra.Command, args []string) {
+		contextFlags, _ := cmd.Flags().GetStringSlice("context")
+		paramsFlag, _ := cmd.Flags().GetString("params")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		var contexts []int
+		for _, c := range contextFlags {
+			n, err := fit.ParseContext(c)
+			if err != nil {
+				exitWithError(exitcode.Wrap(exitcode.Usage, err))
+			}
+			contexts = append(contexts, n)
+		}
+
+		model, err := fitModel(args[0])
+		if paramsFlag != "" {
+			params, ok := fit.ParseParameters(paramsFlag)
+			if !ok {
+				exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("invalid --params %q (want e.g. 70B or 360M)", paramsFlag)))
+			}
+			if err != nil {
+				model = fit.Model{Ref: args[0], Source: fit.SourceName}
+			}
+			model.Parameters, err = params, nil
+		}
+		if err != nil {
+			exitWithError(err)
+		}
+
+		contexts = model.Contexts(contexts)
+		var estimates []fit.Estimate
+		for _, q := range fit.Quantizations {
+			for _, c := range contexts {
+				estimates = append(estimates, model.Estimate(q, c))
+			}
+		}
+
+		if asJSON {
+			printJSON(struct {
+				Model      fit.Model      `json:"model"`
+				NodeMemory int64          `json:"node_memory_bytes"`
+				Estimates  []fit.Estimate `json:"estimates"`
+			}{model, fit.NodeMemory, estimates})
+			return
+		}
+
+		_, exact := model.KVBytesPerToken()
+		fmt.Printf("%s: %s parameters", model.Ref, fit.FormatParameters(model.Parameters))
+		if exact {
+			fmt.Printf(", %d layers, %d KV heads", model.Layers, model.KVHeads)
+		}
+		if model.Quantization != "" {
+			fmt.Printf(", published as %s", model.Quantization)
+		}
+		fmt.Printf(" (from %s)\n\n", model.Source)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		header := []string{"QUANT", "WEIGHTS"}
+		for _, c := range contexts {
+			header = append(header, fit.FormatContext(c)+" CONTEXT")
+		}
+		fmt.Fprintln(w, strings.Join(header, "\t"))
+		for i, q := range fit.Quantizations {
+			row := estimates[i*len(contexts) : (i+1)*len(contexts)]
+			name := q.Name
+			if strings.EqualFold(q.Name, model.Quantization) {
+				name += "*"
+			}
+			cells := []string{name, artifacts.FormatBytes(row[0].Weights)}
+			for _, e := range row {
+				cells = append(cells, fmt.Sprintf("%s (%s)", artifacts.FormatBytes(e.Total), e.Placement))
+			}
+			fmt.Fprintln(w, strings.Join(cells, "\t"))
+		}
+		w.Flush()
+
+		fmt.Printf("\nOne Spark has about %s for a model; a pair splits the weights and cache across both.\n", artifacts.FormatBytes(fit.NodeMemory))
+		if model.Quantization != "" {
+			fmt.Println("* the format the model is published in")
+		}
+		if !exact {
+			fmt.Println("The KV cache is approximated: the model's architecture is unknown.")
+		}
+	},
+}
+
+// fitModel looks up the parameter count and architecture of a model reference
+func fitModel(ref string) (fit.Model, error) {
+	kind, name := fit.ParseRef(ref)
+	switch kind {
+	case fit.SourceHF:
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		return fit.FromHF(ctx, http.DefaultClient, fit.HFBaseURL, name, os.Getenv("HF_TOKEN"))
+	case fit.SourceDMR:
+		info, err := inspectDMRModel(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: no Model Runner metadata for %s (%v); using the name\n", name, err)
+			return fit.FromName(name)
+		}
+		return fit.FromDMR(name, info)
+	}
+	return fit.FromName(name)
+}
+
+// inspectDMRModel reads a pulled model's metadata from Docker Model Runner on the DGX
+func inspectDMRModel(ref string) (*dmr.Model, error) {
+	if !cfgManager.IsConfigured() {
+		return nil, fmt.Errorf("no DGX configured")
+	}
+	client, err := ssh.NewClient(cfgManager.Get())
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return dmr.NewClient(client.Dial, "tcp", dmr.DefaultAddr).Inspect(ctx, ref)
+}
+
+func init() {
+	modelsFitCmd.Flags().StringSlice("context", nil, "Context lengths to estimate, e.g. 8K,128K (default 4K, 32K, 128K up to the model's maximum)")
+	modelsFitCmd.Flags().String("params", "", "Parameter count, e.g. 70B, when it cannot be looked up")
+	modelsFitCmd.Flags().Bool("json", false, "Print the estimates as JSON")
+
+	modelsCmd.AddCommand(modelsFitCmd)
+	rootCmd.AddCommand(modelsCmd)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/config"
+	"github.com/weatherman/dgx-manager/internal/deploy"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/state"
+)
+
+// nameKinds are the argument values tab-completion offers, each read from what earlier
+// commands cached or from the local config. None of them contacts the DGX.
+var nameKinds = map[string]func(cmd *cobra.Command) []string{
+	// the Model Runner list refreshed by dgx status and the model picker, and models used recently
+	"models": func(cmd *cobra.Command) []string {
+		var names, recent []string
+		probeCache(cmd).Get(modelsCacheKey(cfgManager.Get()), modelsCompletionTTL, &names)
+		if store, err := state.DefaultStore(); err == nil {
+			store.Load(recentModelsKey, &recent)
+		}
+		return append(names, recent...)
+	},
+	// refreshed by dgx shell
+	"containers": func(cmd *cobra.Command) []string {
+		var names []string
+		probeCache(cmd).Get(containersCacheKey(cfgManager.Get()), modelsCompletionTTL, &names)
+		return names
+	},
+	// refreshed by dgx deploy autostart list
+	"deployments": func(cmd *cobra.Command) []string {
+		var entries []deploy.Autostart
+		probeCache(cmd).Get(deploymentsCacheKey(cfgManager.Get()), modelsCompletionTTL, &entries)
+		names := make([]string, len(entries))
+		for i, e := range entries {
+			names[i] = e.Name
+		}
+		return names
+	},
+	"profiles": func(cmd *cobra.Command) []string {
+		return append([]string{config.DefaultProfileName}, cfgManager.ProfileNames()...)
+	},
+}
+
+// names command: what shell completion offers for arguments, printed one per line. It only
+// reads local state, so shell widgets and plugins can call it on every keystroke.
+var namesCmd = &cobra.Command{
+	Use:    "__names <models|containers|deployments|profiles>... [--prefix text]",
+	Hidden: true,
+	Args:   cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		prefix, _ := cmd.Flags().GetString("prefix")
+		for _, kind := range args {
+			if nameKinds[kind] == nil {
+				exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("unknown kind %q (use %s)", kind, strings.Join(nameKindList(), ", "))))
+			}
+		}
+		for _, name := range completionNames(cmd, prefix, args...) {
+			fmt.Fprintln(os.Stdout, name)
+		}
+	},
+}
+
+func nameKindList() []string {
+	kinds := make([]string, 0, len(nameKinds))
+	for kind := range nameKinds {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// isCompletionRequest reports whether cmd only answers shell completion, which must not
+// stop at an unconfigured DGX
+func isCompletionRequest(cmd *cobra.Command) bool {
+	return cmd == namesCmd || cmd.Name() == cobra.ShellCompRequestCmd || cmd.Name() == cobra.ShellCompNoDescRequestCmd
+}
+
+// completionNames returns the names of kinds starting with prefix, sorted and without
+// duplicates. The connection flags on the line being completed pick the DGX whose caches
+// are read, as they would for the command itself.
+func completionNames(cmd *cobra.Command, prefix string, kinds ...string) []string {
+	cfgManager.Apply(connectionOverrides(cmd))
+	seen := map[string]bool{}
+	var names []string
+	for _, kind := range kinds {
+		for _, name := range filterPrefix(nameKinds[kind](cmd), prefix) {
+			if name != "" && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// completeFirst returns a completion function that completes the first argument with
+// names of kinds and nothing after it
+func completeFirst(kinds ...string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return completionNames(cmd, toComplete, kinds...), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+func init() {
+	namesCmd.Flags().String("prefix", "", "Only print names starting with this")
+	rootCmd.AddCommand(namesCmd)
+
+	rootCmd.RegisterFlagCompletionFunc("profile", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return completionNames(cmd, toComplete, "profiles"), cobra.ShellCompDirectiveNoFileComp
+	})
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/config"
+	"github.com/weatherman/dgx-manager/internal/deploy"
+	"github.com/weatherman/dgx-manager/internal/state"
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// completionCommand returns a command with the connection flags the completion functions
+// read, as the line being completed would set them
+func completionCommand(t *testing.T, host string) *cobra.Command {
+	t.Helper()
+	cmd := &cobra.Command{Use: "test"}
+	cmd.Flags().String("profile", "", "")
+	cmd.Flags().String("host", "", "")
+	cmd.Flags().Int("ssh-port", 0, "")
+	cmd.Flags().String("user", "", "")
+	cmd.Flags().String("identity-file", "", "")
+	cmd.Flags().Bool("readonly", false, "")
+	cmd.Flags().Bool("no-cache", false, "")
+	if host != "" {
+		cmd.Flags().Set("host", host)
+	}
+	return cmd
+}
+
+func TestCompletionNames(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	for _, env := range []string{config.EnvConfig, config.EnvProfile, config.EnvHost, config.EnvPort, config.EnvUser, config.EnvIdentityFile} {
+		t.Setenv(env, "")
+	}
+	var err error
+	cfgManager, err = config.NewManager()
+	if err != nil {
+		t.Fatalf("config.NewManager: %v", err)
+	}
+	cfg := cfgManager.File()
+	cfg.Host = "spark.local"
+	cfg.Profiles = map[string]types.Profile{"lab": {Host: "lab.local"}}
+
+	store, err := state.DefaultStore()
+	if err != nil {
+		t.Fatalf("state.DefaultStore: %v", err)
+	}
+	cache := state.NewCache(store)
+	put := func(key string, v interface{}) {
+		t.Helper()
+		if _, err := cache.Fetch(key, time.Hour, v, func() error { return nil }); err != nil {
+			t.Fatalf("cache %s: %v", key, err)
+		}
+	}
+	models := []string{"ai/qwen3", "ai/gemma3"}
+	put(modelsCacheKey(cfg), &models)
+	store.Save(recentModelsKey, []string{"ai/qwen3", "hf.co/org/model"})
+	containers := []string{"vllm-server", "open-webui"}
+	put(containersCacheKey(cfg), &containers)
+	deployments := []deploy.Autostart{{Name: "qwen"}, {Name: "gemma"}}
+	put(deploymentsCacheKey(cfg), &deployments)
+	other := []string{"elsewhere"}
+	put(containersCacheKey(&types.Config{Host: "other.local"}), &other)
+
+	cmd := completionCommand(t, "")
+	if got, want := completionNames(cmd, "", "models"), []string{"ai/gemma3", "ai/qwen3", "hf.co/org/model"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("models = %v, want %v", got, want)
+	}
+	names, directive := completeModels(cmd, nil, "ai/q")
+	if !reflect.DeepEqual(names, []string{"ai/qwen3"}) || directive != cobra.ShellCompDirectiveNoFileComp {
+		t.Fatalf("completeModels = %v, %v", names, directive)
+	}
+	if names, _ := completeContainers(cmd, nil, "v"); !reflect.DeepEqual(names, []string{"vllm-server"}) {
+		t.Fatalf("completeContainers = %v", names)
+	}
+	if names, _ := completeContainers(cmd, []string{"vllm-server"}, ""); names != nil {
+		t.Fatalf("only the first argument is a container, got %v", names)
+	}
+	if names, _ := completeDeployments(cmd, nil, ""); !reflect.DeepEqual(names, []string{"gemma", "qwen"}) {
+		t.Fatalf("completeDeployments = %v", names)
+	}
+	if got := completionNames(cmd, "", "profiles"); !reflect.DeepEqual(got, []string{config.DefaultProfileName, "lab"}) {
+		t.Fatalf("profiles = %v", got)
+	}
+	if got := completionNames(cmd, "", "containers", "deployments"); len(got) != 4 {
+		t.Fatalf("expected the kinds merged, got %v", got)
+	}
+
+	// --host on the line reads that DGX's caches
+	if names, _ := completeContainers(completionCommand(t, "other.local"), nil, ""); !reflect.DeepEqual(names, []string{"elsewhere"}) {
+		t.Fatalf("completeContainers --host other.local = %v", names)
+	}
+	if names, _ := completeDeployments(completionCommand(t, "other.local"), nil, ""); names != nil {
+		t.Fatalf("nothing is cached for other.local, got %v", names)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/netperf"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+// net command
+var netCmd = &cobra.Command{
+	Use:   "net",
+	Short: "Network diagnostics between this workstation and the DGX",
+}
+
+var netTestCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Measure latency and throughput to the DGX",
+	Long: `Measure command latency and raw throughput over the SSH channel in both
+directions. With --iperf, also run iperf3 (installed on the DGX if missing)
+to measure the network path without SSH encryption overhead.
+
+Examples:
+  dgx net test
+  dgx net test --size 512 --iperf`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := cfgManager.Get()
+		client, err := ssh.NewClient(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+
+		sizeMB, _ := cmd.Flags().GetInt("size")
+		samples, _ := cmd.Flags().GetInt("samples")
+		useIperf, _ := cmd.Flags().GetBool("iperf")
+		duration, _ := cmd.Flags().GetDuration("duration")
+		size := int64(sizeMB) * 1024 * 1024
+
+		tester := netperf.NewTester(client, cfg.Host)
+
+		fmt.Printf("Testing network to %s...\n\n", cfg.Host)
+
+		latency, err := tester.Latency(samples)
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Latency (%d command round-trips): min %v / avg %v / max %v\n",
+			latency.Samples, latency.Min.Round(time.Microsecond*100), latency.Avg.Round(time.Microsecond*100), latency.Max.Round(time.Microsecond*100))
+
+		fmt.Printf("\nSSH channel throughput (%d MiB):\n", sizeMB)
+		for _, run := range []func(int64) (netperf.Result, error){tester.Upload, tester.Download} {
+			result, err := run(size)
+			if err != nil {
+				exitWithError(err)
+			}
+			printNetResult(result)
+		}
+
+		if !useIperf {
+			fmt.Println("\nTip: add --iperf to measure the raw network path with iperf3.")
+			return
+		}
+
+		if err := tester.EnsureIperf3(); err != nil {
+			exitWithError(err)
+		}
+		fmt.Println("\niperf3 throughput:")
+		for _, reverse := range []bool{false, true} {
+			result, err := tester.Iperf3(duration, reverse)
+			if err != nil {
+				exitWithError(err)
+			}
+			printNetResult(result)
+		}
+	},
+}
+
+func printNetResult(r netperf.Result) {
+	fmt.Printf("  %-18s %8.1f MiB/s  (%7.1f Mbit/s) in %v\n", r.Direction, r.MBps(), r.Mbps(), r.Duration.Round(time.Millisecond))
+}
+
+func init() {
+	netTestCmd.Flags().Int("size", 256, "Payload size in MiB for SSH channel tests")
+	netTestCmd.Flags().Int("samples", 10, "Number of latency samples")
+	netTestCmd.Flags().Bool("iperf", false, "Also run iperf3 in both directions")
+	netTestCmd.Flags().Duration("duration", 10*time.Second, "Duration of each iperf3 run")
+	netCmd.AddCommand(netTestCmd)
+	rootCmd.AddCommand(netCmd)
+}
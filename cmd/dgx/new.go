@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/scaffold"
+)
+
+// new command
+var newCmd = &cobra.Command{
+	Use:   "new [template] [name]",
+	Short: "Create a local project set up for running on the DGX",
+	Long: `Create a new project from a template in ./<name>. The pytorch-project template
+has a Dockerfile on the NGC PyTorch image (arm64, CUDA), a compose file that
+gives the container the GPU and the Hugging Face cache, and a Makefile whose
+targets push the project to the DGX and run it there:
+
+  make run     build the image on the DGX and run train.py, streaming output
+  make job     the same as a background job ('dgx job'), then 'make logs'
+  make pull    copy outputs/ back
+
+Without arguments, lists the templates. Nothing on the DGX is touched until the
+first make target runs.
+
+Examples:
+  dgx new
+  dgx new pytorch-project mnist
+  dgx new pytorch-project llm-eval --image nvcr.io/nvidia/pytorch:25.10-py3`,
+	Args: cobra.MaximumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "TEMPLATE\tDESCRIPTION")
+			for _, t := range scaffold.Templates {
+				fmt.Fprintf(w, "%s\t%s\n", t.Name, t.Description)
+			}
+			w.Flush()
+			return
+		}
+		if len(args) == 1 {
+			exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("a project name is required: dgx new %s <name>", args[0])))
+		}
+		t, err := scaffold.Find(args[0])
+		if err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Usage, err))
+		}
+		name := args[1]
+		if err := scaffold.ValidateName(name); err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Usage, err))
+		}
+		image, _ := cmd.Flags().GetString("image")
+		force, _ := cmd.Flags().GetBool("force")
+		noGit, _ := cmd.Flags().GetBool("no-git")
+
+		dir, err := filepath.Abs(name)
+		if err != nil {
+			exitWithError(err)
+		}
+		files, err := scaffold.Create(t, dir, scaffold.Params{Name: name, Image: image}, force)
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Created %s from %s:\n", name, t.Name)
+		for _, f := range files {
+			fmt.Printf("  %s\n", f)
+		}
+
+		if !noGit {
+			if _, err := os.Stat(filepath.Join(dir, ".git")); os.IsNotExist(err) {
+				if _, err := exec.LookPath("git"); err == nil {
+					git := exec.Command("git", "init", "-q", dir)
+					if output, err := git.CombinedOutput(); err != nil {
+						fmt.Fprintf(os.Stderr, "Warning: git init failed: %v %s\n", err, output)
+					}
+				}
+			}
+		}
+
+		fmt.Printf("\nNext:\n  cd %s\n  make run\n", name)
+	},
+}
+
+func init() {
+	newCmd.Flags().String("image", scaffold.DefaultImage, "Base image for the Dockerfile")
+	newCmd.Flags().Bool("force", false, "Overwrite files that already exist")
+	newCmd.Flags().Bool("no-git", false, "Don't initialize a git repository")
+
+	rootCmd.AddCommand(newCmd)
+}
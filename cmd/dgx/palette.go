@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/config"
+	"github.com/weatherman/dgx-manager/internal/deploy"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/history"
+	"github.com/weatherman/dgx-manager/internal/picker"
+	"github.com/weatherman/dgx-manager/internal/ui"
+	"golang.org/x/term"
+)
+
+// paletteRecent is how many recent commands the palette offers
+const paletteRecent = 10
+
+// paletteEntry is a palette choice and the dgx arguments it runs. Usage, when set, names
+// the arguments still to be asked for.
+type paletteEntry struct {
+	item  picker.Item
+	args  []string
+	usage string
+}
+
+// isPaletteTerminal reports whether 'dgx' without arguments should open the palette, which
+// plain mode replaces with the help
+func isPaletteTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && ui.Live(os.Stderr)
+}
+
+// runPalette lets the user pick a recent command, deployment, profile, or any command by
+// fuzzy search, then runs it as a dgx child process. Text that matches nothing is run as
+// the command line typed.
+func runPalette(cmd *cobra.Command) {
+	entries := paletteEntries(cmd)
+	items := make([]picker.Item, len(entries))
+	byValue := make(map[string]paletteEntry, len(entries))
+	for i, e := range entries {
+		items[i] = e.item
+		byValue[e.item.Value] = e
+	}
+
+	value, err := picker.Pick("dgx ", items)
+	if errors.Is(err, picker.ErrCanceled) {
+		exit(exitcode.Aborted)
+	}
+	if err != nil {
+		exitWithError(err)
+	}
+
+	entry, ok := byValue[value]
+	if !ok {
+		args, err := history.SplitCommandLine(value)
+		if err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Usage, err))
+		}
+		entry = paletteEntry{args: args}
+	}
+	args := entry.args
+	if entry.usage != "" {
+		rest, err := askArguments(value, entry.usage)
+		if err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Usage, err))
+		}
+		args = append(args, rest...)
+	}
+	runForeground(dgxCommand(args))
+}
+
+// paletteEntries lists recent commands first, then deployments and profiles, then every
+// command. Nothing here contacts the DGX: deployments come from the list cached by 'dgx
+// deploy autostart list'.
+func paletteEntries(cmd *cobra.Command) []paletteEntry {
+	var entries []paletteEntry
+	seen := map[string]bool{}
+	add := func(e paletteEntry) {
+		if !seen[e.item.Value] {
+			seen[e.item.Value] = true
+			entries = append(entries, e)
+		}
+	}
+
+	if path, err := history.DefaultPath(); err == nil {
+		recent, _ := history.NewStore(path).Load()
+		for i, n := len(recent)-1, 0; i >= 0 && n < paletteRecent; i-- {
+			e := recent[i]
+			value := strings.TrimPrefix(history.CommandLine(e.Args), "dgx ")
+			if e.Redacted || len(e.Args) == 0 || seen[value] {
+				continue
+			}
+			add(paletteEntry{item: picker.Item{Value: value, Note: "recent, " + e.Status()}, args: e.Args})
+			n++
+		}
+	}
+
+	if cfgManager.IsConfigured() {
+		var deployments []deploy.Autostart
+		probeCache(cmd).Get(deploymentsCacheKey(cfgManager.Get()), modelsCompletionTTL, &deployments)
+		for _, d := range deployments {
+			note := fmt.Sprintf("deployment, %s %s", d.Engine, d.Model)
+			add(paletteEntry{item: picker.Item{Value: "deploy warm " + d.Name, Note: note}, args: []string{"deploy", "warm", d.Name}})
+		}
+	}
+
+	active := cfgManager.File().ActiveProfile
+	for _, name := range append([]string{config.DefaultProfileName}, cfgManager.ProfileNames()...) {
+		note := "profile"
+		if name == active || (active == "" && name == config.DefaultProfileName) {
+			note = "profile, active"
+		}
+		add(paletteEntry{item: picker.Item{Value: "config profile use " + name, Note: note}, args: []string{"config", "profile", "use", name}})
+	}
+
+	var walk func(c *cobra.Command)
+	walk = func(c *cobra.Command) {
+		for _, sub := range c.Commands() {
+			if sub.Hidden || !sub.IsAvailableCommand() || sub.Name() == "help" || sub.Name() == "completion" {
+				continue
+			}
+			if sub.Runnable() {
+				path := strings.TrimPrefix(sub.CommandPath(), "dgx ")
+				_, usage, _ := strings.Cut(sub.Use, " ")
+				add(paletteEntry{item: picker.Item{Value: path, Note: sub.Short}, args: strings.Fields(path), usage: usage})
+			}
+			walk(sub)
+		}
+	}
+	walk(cmd.Root())
+	return entries
+}
+
+// askArguments asks for the arguments of the chosen command on one line
+func askArguments(command, usage string) ([]string, error) {
+	fmt.Fprintf(os.Stderr, "Usage: dgx %s %s\n", command, usage)
+	fmt.Fprintf(os.Stderr, "dgx %s ", command)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	return history.SplitCommandLine(line)
+}
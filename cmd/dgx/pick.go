@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/config"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/picker"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/state"
+)
+
+// recentModelsKey is the state document listing the models used most recently, newest first
+const recentModelsKey = "recent_models"
+
+// maxRecentModels bounds the recent model list
+const maxRecentModels = 20
+
+// playbookModelCommands are the playbook subcommands whose first argument is a model
+var playbookModelCommands = map[string][]string{
+	"dmr":    {"pull", "run", "unload"},
+	"ollama": {"pull", "run"},
+	"vllm":   {"serve"},
+	"nvfp4":  {"quantize"},
+}
+
+// rememberModel puts model at the front of the recent model list. It is best effort.
+func rememberModel(model string) {
+	store, err := state.DefaultStore()
+	if err != nil || model == "" {
+		return
+	}
+	var recent []string
+	store.Load(recentModelsKey, &recent)
+	list := []string{model}
+	for _, m := range recent {
+		if m != model && len(list) < maxRecentModels {
+			list = append(list, m)
+		}
+	}
+	store.Save(recentModelsKey, list)
+}
+
+// modelChoices lists the models a picker offers: recently used ones first, then the Docker
+// Model Runner store, then the models named by serve routes. Without a client the store
+// comes from the cache, so no connection is opened just to ask.
+func modelChoices(cmd *cobra.Command, client *ssh.Client) []picker.Item {
+	var items []picker.Item
+	seen := map[string]bool{}
+	add := func(note string, models ...string) {
+		for _, m := range models {
+			if m != "" && !seen[m] {
+				seen[m] = true
+				items = append(items, picker.Item{Value: m, Note: note})
+			}
+		}
+	}
+
+	if store, err := state.DefaultStore(); err == nil {
+		var recent []string
+		store.Load(recentModelsKey, &recent)
+		add("recent", recent...)
+	}
+
+	cfg := cfgManager.Get()
+	var local []string
+	if client != nil {
+		local, _, _ = cachedModels(probeCache(cmd), cfg, client)
+	} else {
+		probeCache(cmd).Get(modelsCacheKey(cfg), modelsCompletionTTL, &local)
+	}
+	add("Model Runner", local...)
+
+	if cfg.Serve != nil {
+		for _, r := range cfg.Serve.Routes {
+			if !strings.Contains(r.Model, "*") {
+				add("serve route", r.Model)
+			}
+		}
+	}
+	return items
+}
+
+// pickModel asks for a model with the interactive picker. It returns picker.ErrNoTerminal
+// when there is no terminal to ask on, so callers can report the missing argument instead;
+// a canceled picker exits.
+func pickModel(cmd *cobra.Command, client *ssh.Client) (string, error) {
+	model, err := picker.Pick("Model: ", modelChoices(cmd, client))
+	if errors.Is(err, picker.ErrCanceled) {
+		exitWithError(exitcode.ErrAborted)
+	}
+	return model, err
+}
+
+// pickPlaybookModel fills in the model of a playbook subcommand that takes one when it was
+// left out, from the preset or, when a terminal is available, the picker; otherwise args
+// are returned unchanged and the playbook reports the missing model
+func pickPlaybookModel(cmd *cobra.Command, client *ssh.Client, name string, args []string) []string {
+	if len(args) == 0 || !slices.Contains(playbookModelCommands[name], args[0]) || slices.Contains(args, "--all") {
+		return args
+	}
+	if len(args) > 1 && !strings.HasPrefix(args[1], "-") {
+		return args
+	}
+	if preset, err := config.ResolvePreset(cfgManager.Get(), ""); err == nil && preset.Model != "" {
+		fmt.Fprintf(os.Stderr, "Using the preset model %s\n", preset.Model)
+		return append([]string{args[0], preset.Model}, args[1:]...)
+	}
+	model, err := pickModel(cmd, client)
+	if err != nil {
+		return args
+	}
+	return append([]string{args[0], model}, args[1:]...)
+}
+
+// playbookModel returns the model a playbook subcommand was run with, if it takes one
+func playbookModel(name string, args []string) string {
+	if len(args) > 1 && slices.Contains(playbookModelCommands[name], args[0]) && !strings.HasPrefix(args[1], "-") {
+		return args[1]
+	}
+	return ""
+}
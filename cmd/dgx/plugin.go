@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/pkg/plugin"
+)
+
+// pluginAnnotation marks the commands registered for plugins
+const pluginAnnotation = "dgx-plugin"
+
+// pluginInfo is a dgx-<name> executable found on PATH
+type pluginInfo struct {
+	Name     string
+	Path     string
+	Shadowed bool // a built-in command has the same name
+}
+
+// plugin command
+var pluginCmd = &cobra.Command{
+	Use:   "plugin",
+	Short: "List external dgx-<name> plugins",
+	Long: `Any executable named dgx-<name> on PATH becomes 'dgx <name>', git-style. dgx
+resolves the connection first (--profile, --host, ... and root flags like --group
+work as usual), then runs the plugin with it in the environment:
+
+  DGX_PLUGIN         the plugin name
+  DGX_BIN            the dgx executable, for calling back into dgx
+  DGX_HOST, DGX_PORT, DGX_USER, DGX_IDENTITY_FILE, DGX_PROFILE
+  DGX_CONFIG         the config file in use
+  DGX_READONLY       "1" when the connection is read-only
+
+Go plugins can read these with the github.com/weatherman/dgx-manager/pkg/plugin
+package. Built-in commands win over plugins of the same name.`,
+}
+
+var pluginListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the plugins found on PATH",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		plugins := discoverPlugins()
+		if len(plugins) == 0 {
+			fmt.Println("No plugins found (executables named dgx-<name> on PATH)")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tPATH\tNOTE")
+		for _, p := range plugins {
+			note := "-"
+			if p.Shadowed {
+				note = "shadowed by the built-in command"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", p.Name, p.Path, note)
+		}
+		w.Flush()
+	},
+}
+
+// discoverPlugins finds dgx-<name> executables on PATH; earlier directories win
+func discoverPlugins() []pluginInfo {
+	seen := map[string]bool{}
+	var plugins []pluginInfo
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name, ok := strings.CutPrefix(e.Name(), plugin.Prefix)
+			if !ok || e.IsDir() {
+				continue
+			}
+			if runtime.GOOS == "windows" {
+				if name, ok = strings.CutSuffix(name, ".exe"); !ok {
+					continue
+				}
+			}
+			if name == "" || seen[name] {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil || (runtime.GOOS != "windows" && info.Mode().Perm()&0111 == 0) {
+				continue
+			}
+			seen[name] = true
+			plugins = append(plugins, pluginInfo{Name: name, Path: filepath.Join(dir, e.Name()), Shadowed: builtinCommand(name)})
+		}
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins
+}
+
+// builtinCommand reports whether name is a top-level dgx command or alias
+func builtinCommand(name string) bool {
+	if name == "help" || name == "completion" {
+		return true
+	}
+	for _, c := range rootCmd.Commands() {
+		if _, isPlugin := c.Annotations[pluginAnnotation]; isPlugin {
+			continue
+		}
+		if c.Name() == name || c.HasAlias(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// registerPlugins adds a command for every plugin that no built-in command shadows
+func registerPlugins() {
+	for _, p := range discoverPlugins() {
+		if p.Shadowed {
+			continue
+		}
+		path := p.Path
+		rootCmd.AddCommand(&cobra.Command{
+			Use:                p.Name + " [args...]",
+			Short:              "Plugin (" + path + ")",
+			Annotations:        map[string]string{pluginAnnotation: path},
+			DisableFlagParsing: true,
+			Run: func(cmd *cobra.Command, args []string) {
+				args, err := takeRootFlags(cmd, args)
+				if err != nil {
+					exitWithError(exitcode.Wrap(exitcode.Usage, err))
+				}
+				prepareCommand(cmd)
+				exit(runPlugin(cmd.Name(), path, args))
+			},
+		})
+	}
+}
+
+// runPlugin runs a plugin with the effective connection in its environment and returns
+// its exit code
+func runPlugin(name, path string, args []string) int {
+	cfg := cfgManager.Get()
+	conn := plugin.Connection{
+		Name:            name,
+		Host:            cfg.Host,
+		Port:            cfg.Port,
+		User:            cfg.User,
+		IdentityFile:    cfg.IdentityFile,
+		CertificateFile: cfg.CertificateFile,
+		Profile:         cfg.ActiveProfile,
+		ReadOnly:        cfg.ReadOnly,
+	}
+	env := append(ssh.AgentEnv(cfg), conn.Env()...)
+	if exe, err := os.Executable(); err == nil {
+		env = append(env, plugin.EnvBin+"="+exe)
+	}
+	env = append(env, plugin.EnvConfig+"="+cfgManager.GetConfigPath())
+
+	child := exec.Command(path, args...)
+	child.Env = env
+	child.Stdin, child.Stdout, child.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+	// The plugin shares the terminal, so it handles Ctrl-C; dgx just waits for it to exit
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	defer signal.Stop(signals)
+
+	err := child.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to run plugin %s: %v\n", name, err)
+		return exitcode.General
+	}
+	return exitcode.OK
+}
+
+func init() {
+	pluginCmd.AddCommand(pluginListCmd)
+	rootCmd.AddCommand(pluginCmd)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/poll"
+	"github.com/weatherman/dgx-manager/internal/state"
+)
+
+// pollScheduler paces this process's polling loops together with those of other dgx
+// processes, through leases in the state directory, at the minimums of the poll config
+var pollScheduler = sync.OnceValue(func() *poll.Scheduler {
+	dir := ""
+	if store, err := state.DefaultStore(); err == nil {
+		dir = filepath.Join(store.Dir(), "poll")
+	}
+	return poll.New(dir, poll.Minimums(cfgManager.Get().Poll))
+})
+
+// pollUntil calls cond until it returns true or the deadline passes, waiting interval
+// between calls, or longer while other loops are checking the same DGX
+func pollUntil(host, kind string, deadline time.Time, interval time.Duration, cond func() bool) bool {
+	loop := pollScheduler().Register(host, kind, interval)
+	defer loop.Close()
+	for {
+		if cond() {
+			return true
+		}
+		wait := loop.Next()
+		if time.Now().Add(wait).After(deadline) {
+			return false
+		}
+		time.Sleep(wait)
+	}
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/locate"
+	"github.com/weatherman/dgx-manager/internal/power"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/state"
+	"github.com/weatherman/dgx-manager/internal/ui"
+	"github.com/weatherman/dgx-manager/internal/usage"
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// power command
+var powerCmd = &cobra.Command{
+	Use:   "power",
+	Short: "Track the DGX's power draw and what it costs",
+}
+
+var powerTrackCmd = &cobra.Command{
+	Use:   "track",
+	Short: "Log GPU and platform power on the DGX",
+	Long: `Install a systemd unit on the DGX that logs GPU power, and platform power when
+the system has a power meter, every --interval to ~/.local/share/dgx/power.
+The log runs without the CLI connected and keeps --retention days of samples.
+Running it again changes the interval or retention.
+
+Installing the unit uses sudo on the DGX, so you may be prompted for a password.
+
+Examples:
+  dgx power track
+  dgx power track --interval 30s --retention 365
+  dgx power track disable`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		interval, _ := cmd.Flags().GetDuration("interval")
+		retention, _ := cmd.Flags().GetInt("retention")
+
+		cfg := cfgManager.Get()
+		client, err := ssh.NewClient(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+
+		fmt.Printf("Installing %s on %s...\n", power.LoggerUnit, cfg.Host)
+		if err := power.NewManager(client).Enable(cfg.User, interval, retention); err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("\nLogging power every %v. See the estimate with: dgx power report\n", interval)
+	},
+}
+
+var powerTrackDisableCmd = &cobra.Command{
+	Use:   "disable",
+	Short: "Stop logging power and remove the unit (samples are kept)",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := ssh.NewClient(cfgManager.Get())
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+
+		if err := power.NewManager(client).Disable(); err != nil {
+			exitWithError(err)
+		}
+		fmt.Println("Power logging stopped")
+	},
+}
+
+var powerReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Estimate energy use and cost from the power log",
+	Long: `Estimate the energy the DGX drew over --since from the samples logged by
+'dgx power track', per day and in total, and what it cost at your electricity
+rate. The estimate uses the platform power meter when the system has one, and
+GPU power alone otherwise, which leaves out the CPU, memory, storage, and fans.
+Time the logger was not running (the DGX was off, or tracking was disabled) is
+not counted; the report shows how much of the window was covered.
+
+Set the rate once in the config:
+
+  power:
+    rate: 0.15        # per kWh
+    currency: USD
+
+Examples:
+  dgx power report
+  dgx power report --since 30d --rate 0.32 --currency EUR
+  dgx power report --since 7d --json`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		sinceFlag, _ := cmd.Flags().GetString("since")
+		asJSON, _ := cmd.Flags().GetBool("json")
+		window, err := usage.ParseSince(sinceFlag)
+		if err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Usage, err))
+		}
+
+		cfg := cfgManager.Get()
+		rate, currency := 0.0, "USD"
+		if cfg.Power != nil {
+			rate = cfg.Power.Rate
+			if cfg.Power.Currency != "" {
+				currency = cfg.Power.Currency
+			}
+		}
+		if cmd.Flags().Changed("rate") {
+			rate, _ = cmd.Flags().GetFloat64("rate")
+		}
+		if cmd.Flags().Changed("currency") {
+			currency, _ = cmd.Flags().GetString("currency")
+		}
+
+		client, err := ssh.NewClient(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+
+		to := time.Now()
+		from := to.Add(-window)
+		manager := power.NewManager(client)
+		samples, err := manager.Samples(from.Unix())
+		if err != nil {
+			exitWithError(err)
+		}
+		status := manager.Status()
+		if len(samples) == 0 {
+			if status != "active" {
+				exitWithError(fmt.Errorf("no power samples on %s; start logging with: dgx power track", cfg.Host))
+			}
+			exitWithError(fmt.Errorf("no power samples in the last %s yet", sinceFlag))
+		}
+		if status != "active" {
+			fmt.Fprintf(os.Stderr, "Warning: the power logger is %s; start it again with: dgx power track\n", status)
+		}
+
+		report := power.Summarize(samples, from, to)
+		report.Rate, report.Currency = rate, currency
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(report); err != nil {
+				exitWithError(err)
+			}
+			return
+		}
+		printPowerReport(report, sinceFlag)
+	},
+}
+
+var powerIdleCmd = &cobra.Command{
+	Use:   "idle",
+	Short: "Suspend or power-limit the DGX when its GPU sits idle",
+	Long: `Install a policy on the DGX that acts once the GPU has been idle (utilization at
+or below --threshold) for --after, to cut idle power draw on a Spark that is only
+used now and then:
+
+  suspend     suspend to RAM; wake it with 'dgx power on', or let 'dgx serve'
+              wake it on request (serve.wake: true). Needs Wake-on-LAN, which
+              the policy turns on for the default network interface.
+  powerlimit  drop the GPU power limit to --limit watts and restore it as soon
+              as the GPU is busy again or 'dgx power on' asks for it
+
+Logged-in users keep the DGX awake, so it does not suspend under an open shell;
+SSH tunnels and the serve proxy do not count as logins.
+
+Installing the policy uses sudo on the DGX, so you may be prompted for a password.
+
+Examples:
+  dgx power idle enable --after 45m
+  dgx power idle enable --action powerlimit --limit 60
+  dgx power idle status
+  dgx power idle disable`,
+}
+
+var powerIdleEnableCmd = &cobra.Command{
+	Use:   "enable",
+	Short: "Install or change the idle policy",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		policy := power.IdlePolicy{}
+		policy.Action, _ = cmd.Flags().GetString("action")
+		policy.After, _ = cmd.Flags().GetDuration("after")
+		policy.Threshold, _ = cmd.Flags().GetInt("threshold")
+		policy.Limit, _ = cmd.Flags().GetInt("limit")
+		if err := policy.Validate(); err != nil {
+			exitWithError(err)
+		}
+
+		cfg := cfgManager.Get()
+		client, err := ssh.NewClient(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+
+		manager := power.NewManager(client)
+		if policy.Action == power.ActionSuspend {
+			// Remember the address Wake-on-LAN packets must go to while the DGX still answers
+			mac, err := manager.InterfaceMAC()
+			if err != nil {
+				exitWithError(err)
+			}
+			rememberWakeMAC(cfg, mac)
+		}
+
+		fmt.Printf("Installing %s on %s...\n", power.IdleUnit, cfg.Host)
+		if err := manager.EnableIdle(policy); err != nil {
+			exitWithError(err)
+		}
+		switch policy.Action {
+		case power.ActionSuspend:
+			fmt.Printf("\n%s will suspend after %v of GPU idleness. Wake it with: dgx power on\n", cfg.Host, policy.After)
+		case power.ActionPowerLimit:
+			fmt.Printf("\nThe GPU power limit drops to %d W after %v of idleness\n", policy.Limit, policy.After)
+		}
+	},
+}
+
+var powerIdleDisableCmd = &cobra.Command{
+	Use:   "disable",
+	Short: "Remove the idle policy",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := ssh.NewClient(cfgManager.Get())
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+
+		if err := power.NewManager(client).DisableIdle(); err != nil {
+			exitWithError(err)
+		}
+		fmt.Println("Idle policy removed")
+	},
+}
+
+var powerIdleStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the idle policy and how long the GPU has been idle",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := ssh.NewClient(cfgManager.Get())
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+
+		status, err := power.NewManager(client).IdleStatus()
+		if err != nil {
+			exitWithError(err)
+		}
+		if status.Unit != "active" {
+			fmt.Printf("No idle policy running (%s)\n", ui.OrDash(status.Unit))
+			return
+		}
+		fmt.Printf("Policy:  %s\n", status.Policy)
+		since := "-"
+		if !status.Since.IsZero() {
+			since = time.Since(status.Since).Round(time.Second).String()
+		}
+		switch status.State {
+		case "active":
+			fmt.Printf("State:   active (%s)\n", status.Reason)
+		case "idle":
+			fmt.Printf("State:   idle for %s\n", since)
+		case "lowpower":
+			fmt.Printf("State:   power limited, idle for %s\n", since)
+		default:
+			fmt.Printf("State:   %s\n", ui.OrDash(status.State))
+		}
+	},
+}
+
+var powerOnCmd = &cobra.Command{
+	Use:   "on",
+	Short: "Wake a suspended DGX and restore its power limit",
+	Long: `Wake the DGX: send a Wake-on-LAN packet when it does not answer and wait for
+SSH to come back, then tell the idle policy it is wanted, which restores a
+lowered GPU power limit and restarts the idle timer.
+
+The packet goes to the MAC address recorded by 'dgx power idle enable' or seen on
+earlier connections, or the mac setting in the config. It is broadcast on the
+local network, so the DGX must be on the same LAN.
+
+Examples:
+  dgx power on
+  dgx power on --timeout 5m`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		cfg := cfgManager.Get()
+
+		woken, err := wakeHost(cfg, trackerKey(), timeout)
+		if err != nil {
+			exitWithError(err)
+		}
+		client, err := ssh.NewClient(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+		if err := power.NewManager(client).Wake(); err != nil {
+			exitWithError(err)
+		}
+		if woken {
+			fmt.Printf("%s is awake\n", cfg.Host)
+		} else {
+			fmt.Printf("%s was already awake; idle timer restarted\n", cfg.Host)
+		}
+	},
+}
+
+// wakeHost sends Wake-on-LAN to the DGX at cfg when it does not answer on its SSH port and
+// waits up to timeout for it to. It reports whether the DGX had to be woken. key names
+// the DGX's sighting in the state store, as in hostTracker.
+func wakeHost(cfg *types.Config, key string, timeout time.Duration) (bool, error) {
+	port := cfg.Port
+	if port == 0 {
+		port = 22
+	}
+	if locate.Reachable(cfg.Host, port, 3*time.Second) {
+		return false, nil
+	}
+
+	mac, last := cfg.MAC, ""
+	if store, err := state.DefaultStore(); err == nil {
+		if seen := locate.Load(store, key); seen != nil {
+			if mac == "" {
+				mac = seen.MAC
+			}
+			last = seen.Address
+		}
+	}
+	if mac == "" {
+		return false, fmt.Errorf("%s does not answer and its MAC address is unknown; set mac in the config", cfg.Host)
+	}
+
+	fmt.Fprintf(os.Stderr, "Waking %s (%s)...\n", cfg.Host, mac)
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		// Resend now and then in case a packet was lost
+		if err := power.SendWake(mac, last); err != nil {
+			return false, err
+		}
+		for range 5 {
+			if locate.Reachable(cfg.Host, port, 2*time.Second) {
+				return true, nil
+			}
+			time.Sleep(time.Second)
+		}
+	}
+	return false, fmt.Errorf("%s did not wake within %v; check that Wake-on-LAN is enabled in its firmware", cfg.Host, timeout)
+}
+
+// rememberWakeMAC stores mac in the DGX's sighting so dgx power on can find it once it is
+// asleep and no longer in the ARP table
+func rememberWakeMAC(cfg *types.Config, mac string) {
+	if cfg.MAC != "" || mac == "" {
+		return
+	}
+	store, err := state.DefaultStore()
+	if err != nil {
+		return
+	}
+	seen := locate.Load(store, trackerKey())
+	if seen == nil {
+		seen = &locate.Sighting{Address: cfg.Host, SeenAt: time.Now()}
+	}
+	if !strings.EqualFold(seen.MAC, mac) {
+		seen.MAC = mac
+		locate.Save(store, trackerKey(), *seen)
+	}
+}
+
+func printPowerReport(r *power.Report, since string) {
+	total := r.Total
+	source := "platform power meter"
+	if total.PlatformkWh == 0 {
+		source = "GPU power only"
+	}
+	coverage := 100 * total.Covered.Seconds() / r.To.Sub(r.From).Seconds()
+	fmt.Printf("Power over the last %s (%s, %.0f%% of the window logged)\n\n", since, source, min(coverage, 100))
+
+	if len(r.Days) > 1 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "DAY\tKWH\tAVG W\tPEAK GPU W\tCOST")
+		for _, d := range r.Days {
+			fmt.Fprintf(w, "%s\t%.2f\t%.0f\t%.0f\t%s\n", d.Date, d.KWh(), d.AvgWatts(), d.PeakGPU, formatCost(r, r.Cost(d.Energy)))
+		}
+		w.Flush()
+		fmt.Println()
+	}
+
+	fmt.Printf("Energy:        %.2f kWh\n", total.KWh())
+	fmt.Printf("Average draw:  %.0f W\n", total.AvgWatts())
+	fmt.Printf("GPU:           %.0f W average, %.0f W peak\n", total.AvgGPU, total.PeakGPU)
+	if total.PlatformkWh > 0 {
+		fmt.Printf("Platform:      %.0f W average, %.0f W peak\n", total.AvgPlatform, total.PeakPlatform)
+	}
+	if r.Rate > 0 {
+		fmt.Printf("Cost:          %s at %.4g %s/kWh\n", formatCost(r, r.Cost(total)), r.Rate, r.Currency)
+		fmt.Printf("Monthly:       %s if run like this continuously (%.0f kWh)\n", formatCost(r, total.MonthlyKWh()*r.Rate), total.MonthlyKWh())
+	} else {
+		fmt.Printf("Monthly:       %.0f kWh if run like this continuously\n", total.MonthlyKWh())
+		fmt.Println("\nSet power.rate in the config (or pass --rate) to estimate the cost.")
+	}
+}
+
+func formatCost(r *power.Report, cost float64) string {
+	if r.Rate <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f %s", cost, r.Currency)
+}
+
+func init() {
+	powerTrackCmd.Flags().Duration("interval", power.DefaultInterval, "How often to sample")
+	powerTrackCmd.Flags().Int("retention", power.DefaultRetention, "Days of samples to keep on the DGX")
+	powerReportCmd.Flags().String("since", "24h", "Lookback window (e.g. 24h, 7d, 2w)")
+	powerReportCmd.Flags().Float64("rate", 0, "Electricity price per kWh (default power.rate from the config)")
+	powerReportCmd.Flags().String("currency", "", "Currency of the rate (default power.currency, or USD)")
+	powerReportCmd.Flags().Bool("json", false, "Print the report as JSON")
+
+	powerIdleEnableCmd.Flags().String("action", power.ActionSuspend, "What to do when idle ("+strings.Join(power.IdleActions, ", ")+")")
+	powerIdleEnableCmd.Flags().Duration("after", power.DefaultIdleAfter, "How long the GPU must be idle")
+	powerIdleEnableCmd.Flags().Int("threshold", power.DefaultIdleThreshold, "GPU utilization (%) at or below which the GPU is idle")
+	powerIdleEnableCmd.Flags().Int("limit", 0, "Power limit in watts for --action powerlimit")
+	powerOnCmd.Flags().Duration("timeout", 3*time.Minute, "How long to wait for the DGX to wake")
+
+	powerTrackCmd.AddCommand(powerTrackDisableCmd)
+	powerIdleCmd.AddCommand(powerIdleEnableCmd, powerIdleDisableCmd, powerIdleStatusCmd)
+	powerCmd.AddCommand(powerTrackCmd, powerReportCmd, powerIdleCmd, powerOnCmd)
+	rootCmd.AddCommand(powerCmd)
+}
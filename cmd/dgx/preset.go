@@ -0,0 +1,208 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/config"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/ui"
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// preset command
+var presetCmd = &cobra.Command{
+	Use:   "preset",
+	Short: "Manage default models and prompt presets",
+	Long: `A preset is a model, system prompt, and temperature stored under a name in the
+config file. The "default" preset is the config's defaults block; the preset
+picked with 'dgx preset use' is layered over it, and --preset selects another
+for a single chat or test. Explicit arguments and flags always win.
+
+'dgx chat' and 'dgx test chat' use the model when none is given, and the
+system prompt and temperature unless --system or --temperature are passed.
+Playbook commands that take a model (such as 'dgx run dmr run') use the model
+instead of asking for one.
+
+Examples:
+  dgx preset add default --model ai/llama3.3:70B-Q4_K_M --temperature 0.7
+  dgx preset add code --model ai/qwen2.5-coder --system "You are a terse senior Go reviewer." --temperature 0.2
+  dgx preset use code
+  dgx chat                       # qwen2.5-coder with the reviewer prompt
+  dgx chat --preset default`,
+}
+
+var presetListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List presets",
+	Run: func(cmd *cobra.Command, args []string) {
+		file := cfgManager.File()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ACTIVE\tNAME\tMODEL\tTEMPERATURE\tSYSTEM PROMPT")
+		row := func(name string, p types.Preset, active bool) {
+			marker := ""
+			if active {
+				marker = "*"
+			}
+			temperature := "-"
+			if p.Temperature != nil {
+				temperature = fmt.Sprint(*p.Temperature)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", marker, name, ui.OrDash(p.Model), temperature, ui.OrDash(ui.Truncate(ui.OneLine(p.System), 50)))
+		}
+		var defaults types.Preset
+		if file.Defaults != nil {
+			defaults = *file.Defaults
+		}
+		row(config.DefaultPresetName, defaults, file.ActivePreset == "")
+		for _, name := range config.PresetNames(file) {
+			row(name, file.Presets[name], name == file.ActivePreset)
+		}
+		w.Flush()
+	},
+}
+
+var presetAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Add a preset, or update the fields given of an existing one (\"default\" for the defaults)",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		if name != config.DefaultPresetName && !config.ValidPresetName(name) {
+			exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("invalid preset name %q (letters, digits, '.', '_', and '-')", name)))
+		}
+		if !cmd.Flags().Changed("model") && !cmd.Flags().Changed("system") && !cmd.Flags().Changed("system-file") && !cmd.Flags().Changed("temperature") {
+			exitWithError(exitcode.Wrap(exitcode.Usage, errors.New("set at least one of --model, --system, --system-file, or --temperature")))
+		}
+
+		var preset types.Preset
+		file := cfgManager.File()
+		if name == config.DefaultPresetName && file.Defaults != nil {
+			preset = *file.Defaults
+		} else if existing, ok := file.Presets[name]; ok {
+			preset = existing
+		}
+		if cmd.Flags().Changed("model") {
+			preset.Model, _ = cmd.Flags().GetString("model")
+		}
+		if cmd.Flags().Changed("system") {
+			preset.System, _ = cmd.Flags().GetString("system")
+		}
+		if path, _ := cmd.Flags().GetString("system-file"); path != "" {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				exitWithError(exitcode.Wrap(exitcode.Usage, err))
+			}
+			preset.System = strings.TrimSpace(string(data))
+		}
+		if cmd.Flags().Changed("temperature") {
+			t, _ := cmd.Flags().GetFloat64("temperature")
+			if t < 0 {
+				// A negative value clears it, leaving the temperature to the server
+				preset.Temperature = nil
+			} else if t > 2 {
+				exitWithError(exitcode.Wrap(exitcode.Usage, errors.New("--temperature must be between 0 and 2")))
+			} else {
+				preset.Temperature = &t
+			}
+		}
+
+		err := cfgManager.Update(func(cfg *types.Config) {
+			if name == config.DefaultPresetName {
+				cfg.Defaults = &preset
+				return
+			}
+			if cfg.Presets == nil {
+				cfg.Presets = make(map[string]types.Preset)
+			}
+			cfg.Presets[name] = preset
+		})
+		if err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Config, err))
+		}
+		fmt.Printf("Preset %s saved\n", name)
+		if name != config.DefaultPresetName && cfgManager.File().ActivePreset != name {
+			fmt.Printf("Make it the default with: dgx preset use %s\n", name)
+		}
+	},
+}
+
+var presetUseCmd = &cobra.Command{
+	Use:               "use <name>",
+	Short:             "Make a preset the default (use \"default\" for just the defaults)",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completePresets,
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		if name == config.DefaultPresetName {
+			name = ""
+		} else if _, ok := cfgManager.File().Presets[name]; !ok {
+			exitWithError(exitcode.Wrap(exitcode.Config, fmt.Errorf("unknown preset %q", name)))
+		}
+		if err := cfgManager.Update(func(cfg *types.Config) { cfg.ActivePreset = name }); err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Config, err))
+		}
+		fmt.Printf("Active preset: %s\n", args[0])
+	},
+}
+
+var presetRemoveCmd = &cobra.Command{
+	Use:               "remove <name>",
+	Short:             "Remove a preset",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completePresets,
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		if _, ok := cfgManager.File().Presets[name]; !ok {
+			exitWithError(exitcode.Wrap(exitcode.Config, fmt.Errorf("unknown preset %q", name)))
+		}
+		err := cfgManager.Update(func(cfg *types.Config) {
+			delete(cfg.Presets, name)
+			if cfg.ActivePreset == name {
+				cfg.ActivePreset = ""
+			}
+		})
+		if err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Config, err))
+		}
+		fmt.Printf("Preset %s removed\n", name)
+	},
+}
+
+func completePresets(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return config.PresetNames(cfgManager.File()), cobra.ShellCompDirectiveNoFileComp
+}
+
+// chatPreset returns the preset selected by --preset or the config, with --system and
+// --temperature applied when they were passed
+func chatPreset(cmd *cobra.Command) types.Preset {
+	name, _ := cmd.Flags().GetString("preset")
+	preset, err := config.ResolvePreset(cfgManager.Get(), name)
+	if err != nil {
+		exitWithError(exitcode.Wrap(exitcode.Usage, err))
+	}
+	if cmd.Flags().Lookup("system") != nil && cmd.Flags().Changed("system") {
+		preset.System, _ = cmd.Flags().GetString("system")
+	}
+	if cmd.Flags().Changed("temperature") {
+		t, _ := cmd.Flags().GetFloat64("temperature")
+		preset.Temperature = &t
+	}
+	return preset
+}
+
+func init() {
+	presetAddCmd.Flags().String("model", "", "Model reference")
+	presetAddCmd.Flags().String("system", "", "System prompt")
+	presetAddCmd.Flags().String("system-file", "", "Read the system prompt from a file")
+	presetAddCmd.Flags().Float64("temperature", 0, "Sampling temperature, 0-2 (negative to unset)")
+	presetCmd.AddCommand(presetListCmd, presetAddCmd, presetUseCmd, presetRemoveCmd)
+	rootCmd.AddCommand(presetCmd)
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/fleet"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/workload"
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// ps command
+var psCmd = &cobra.Command{
+	Use:   "ps",
+	Short: "List GPU workloads: containers, Model Runner models, and processes",
+	Long: `List everything holding the GPU on the DGX, whether it was started by dgx or
+not: GPU containers (with the processes inside them folded in), models loaded
+in Docker Model Runner, and bare GPU processes. ORIGIN shows what started each
+one (dgx run, dgx autostart, systemd, manual, ...).
+
+With --peer, the listed hosts are queried as well, in parallel. A peer is
+reached with the same user, port, and SSH key as the configured DGX unless
+given as user@host.
+
+Examples:
+  dgx ps
+  dgx ps --all --peer spark-2.local
+  dgx ps logs dgx-qwen -f
+  dgx ps stop 48213`,
+	Run: func(cmd *cobra.Command, args []string) {
+		peers, _ := cmd.Flags().GetStringSlice("peer")
+		all, _ := cmd.Flags().GetBool("all")
+		asJSON, _ := cmd.Flags().GetBool("json")
+		parallel, _ := cmd.Flags().GetInt("parallel")
+
+		cfg := cfgManager.Get()
+		configs := []*types.Config{cfg}
+		for _, peer := range peers {
+			configs = append(configs, peerConfig(cfg, peer))
+		}
+
+		results := make([][]workload.Workload, len(configs))
+		jobs := make([]fleet.Job, len(configs))
+		for i, c := range configs {
+			jobs[i] = fleet.Job{Host: c.Host, Label: "ps", Run: func(ctx context.Context) (string, error) {
+				var err error
+				results[i], err = listWorkloads(c, all)
+				return "", err
+			}}
+		}
+		done := (&fleet.Pool{Parallel: parallel}).Run(context.Background(), jobs)
+		errs := make([]error, len(done))
+		for i, r := range done {
+			errs[i] = r.Err
+		}
+
+		var workloads []workload.Workload
+		var firstErr error
+		for i, err := range errs {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", configs[i].Host, err)
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			workloads = append(workloads, results[i]...)
+		}
+		workload.Sort(workloads)
+
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if workloads == nil {
+				workloads = []workload.Workload{}
+			}
+			enc.Encode(workloads)
+		} else if len(workloads) == 0 && firstErr == nil {
+			fmt.Println("No GPU workloads running")
+		} else if len(workloads) > 0 {
+			fmt.Print(workload.Format(workloads, len(configs) > 1))
+		}
+
+		if firstErr != nil {
+			exit(exitcode.Of(firstErr))
+		}
+	},
+}
+
+var psLogsCmd = &cobra.Command{
+	Use:   "logs <workload>",
+	Short: "Show a workload's logs",
+	Long: `Show the logs of a workload from 'dgx ps', named by container name or ID,
+model name, or PID. Containers use docker logs, models the Model Runner log,
+and processes run by a systemd unit the unit's journal.
+
+Use --host to act on a machine other than the configured DGX.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		tail, _ := cmd.Flags().GetInt("tail")
+		follow, _ := cmd.Flags().GetBool("follow")
+
+		client, target := findWorkload(args[0])
+		defer client.Close()
+
+		command, err := target.LogsCommand(tail, follow)
+		if err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Usage, err))
+		}
+		// RunInteractive masks the output
+		redactRemoteSecrets(client)
+		if err := client.RunInteractive(command); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+var psStopCmd = &cobra.Command{
+	Use:   "stop <workload>",
+	Short: "Stop a workload",
+	Long: `Stop a workload from 'dgx ps', named by container name or ID, model name,
+or PID. Workloads owned by a systemd unit (such as dgx autostart entries) are
+stopped through the unit so it does not restart them; containers are stopped
+with docker stop, models are unloaded, and processes are sent SIGTERM (SIGKILL
+with --force).
+
+Use --host to act on a machine other than the configured DGX.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		force, _ := cmd.Flags().GetBool("force")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		client, target := findWorkload(args[0])
+		defer client.Close()
+
+		command := target.StopCommand(force)
+		if !confirmCommand(cmd, fmt.Sprintf("Stop %s %s (%s)?", target.Kind, target.Name, command), yes) {
+			fmt.Println("Cancelled.")
+			exit(exitcode.Aborted)
+		}
+		if err := client.RunInteractive(command); err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Stopped %s %s\n", target.Kind, target.Name)
+	},
+}
+
+// listWorkloads connects to one host and lists its workloads
+func listWorkloads(cfg *types.Config, all bool) ([]workload.Workload, error) {
+	client, err := ssh.NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	return workload.NewCollector(client, cfg.Host).List(context.Background(), all)
+}
+
+// findWorkload resolves ref against the workloads on the configured DGX, exiting when it
+// matches none or several. The caller closes the returned client.
+func findWorkload(ref string) (*ssh.Client, workload.Workload) {
+	cfg := cfgManager.Get()
+	client, err := ssh.NewClient(cfg)
+	if err != nil {
+		exitWithError(err)
+	}
+
+	workloads, err := workload.NewCollector(client, cfg.Host).List(context.Background(), true)
+	if err != nil {
+		client.Close()
+		exitWithError(err)
+	}
+	matches := workload.Find(workloads, ref)
+	switch len(matches) {
+	case 1:
+		return client, matches[0]
+	case 0:
+		client.Close()
+		fmt.Fprintf(os.Stderr, "Error: no workload matches %q (see 'dgx ps --all')\n", ref)
+		exit(exitcode.Usage)
+	default:
+		client.Close()
+		names := make([]string, len(matches))
+		for i, m := range matches {
+			names[i] = m.Name
+		}
+		fmt.Fprintf(os.Stderr, "Error: %q matches several workloads: %s\n", ref, strings.Join(names, ", "))
+		exit(exitcode.Usage)
+	}
+	return nil, workload.Workload{}
+}
+
+func init() {
+	psCmd.Flags().StringSlice("peer", nil, "Additional hosts to query (host or user@host; repeatable)")
+	psCmd.Flags().BoolP("all", "a", false, "Include containers without GPU access")
+	psCmd.Flags().Bool("json", false, "Print workloads as JSON")
+	psLogsCmd.Flags().Int("tail", 100, "Number of lines to show")
+	psLogsCmd.Flags().BoolP("follow", "f", false, "Follow the log output")
+	psStopCmd.Flags().Bool("force", false, "Send SIGKILL instead of SIGTERM to bare processes")
+	psStopCmd.Flags().BoolP("yes", "y", false, "Stop without confirmation")
+
+	psCmd.AddCommand(psLogsCmd)
+	psCmd.AddCommand(psStopCmd)
+	rootCmd.AddCommand(psCmd)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/firmware"
+	"github.com/weatherman/dgx-manager/internal/health"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+// reboot command
+var rebootCmd = &cobra.Command{
+	Use:   "reboot",
+	Short: "Reboot the DGX",
+	Long: `Reboot the DGX over SSH. With --wait, block until SSH is reachable again,
+then run a post-boot health summary (driver, docker, Docker Model Runner) and
+report the total downtime.
+
+Examples:
+  dgx reboot
+  dgx reboot --wait --timeout 15m`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := cfgManager.Get()
+		wait, _ := cmd.Flags().GetBool("wait")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		if !confirmCommand(cmd, fmt.Sprintf("Reboot %s now?", cfg.Host), yes) {
+			fmt.Println("Reboot cancelled.")
+			exit(exitcode.Aborted)
+		}
+
+		client, err := ssh.NewClient(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+
+		fmt.Printf("Rebooting %s...\n", cfg.Host)
+		fmt.Println("(You may be prompted for your DGX sudo password)")
+		start := time.Now()
+		// The reboot tears the session down, so a dropped connection means it started; any
+		// other failure, such as a wrong sudo password, means it did not
+		if err := client.RunInteractive("sudo systemctl reboot"); err != nil && !ssh.Disconnected(err) {
+			exitWithError(fmt.Errorf("reboot failed: %w", err))
+		}
+		// Driver and firmware updates take effect on reboot
+		cache := probeCache(cmd)
+		cache.Invalidate(firmware.CacheKey(cfg.Host))
+		cache.Invalidate(gpuCacheKey(cfg))
+		client.Close()
+
+		if !wait {
+			fmt.Println("Reboot issued. Run 'dgx reboot --wait' next time to block until the DGX is back.")
+			return
+		}
+
+		fmt.Println("Waiting for the DGX to go down...")
+		if !pollUntil(cfg.Host, "ssh", start.Add(2*time.Minute), 2*time.Second, func() bool {
+			return !client.IsReachable(2 * time.Second)
+		}) {
+			fmt.Fprintln(os.Stderr, "Warning: SSH never became unreachable; the reboot may not have started.")
+		}
+
+		fmt.Println("Waiting for SSH to come back...")
+		if !pollUntil(cfg.Host, "ssh", start.Add(timeout), 5*time.Second, func() bool {
+			return client.IsReachable(3 * time.Second)
+		}) {
+			fmt.Fprintf(os.Stderr, "Error: DGX did not come back within %v\n", timeout)
+			exit(exitcode.Connection)
+		}
+
+		// sshd may accept TCP before authentication is ready; retry the handshake briefly.
+		booted, err := ssh.NewClient(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer booted.Close()
+		if !pollUntil(cfg.Host, "ssh", time.Now().Add(time.Minute), 3*time.Second, func() bool {
+			return booted.Connect() == nil
+		}) {
+			fmt.Fprintln(os.Stderr, "Error: SSH port is open but login keeps failing")
+			exit(exitcode.Connection)
+		}
+
+		downtime := time.Since(start).Round(time.Second)
+		fmt.Printf("DGX is back (downtime: %v)\n\n", downtime)
+
+		fmt.Println("Post-boot health:")
+		checks := health.Run(booted, health.PostBootProbes)
+		fmt.Print(health.FormatChecks(checks))
+		if !health.AllOK(checks) {
+			exit(1)
+		}
+	},
+}
+
+func init() {
+	rebootCmd.Flags().BoolP("wait", "w", false, "Wait for the DGX to come back and run a health summary")
+	rebootCmd.Flags().Duration("timeout", 10*time.Minute, "Maximum time to wait for SSH to return")
+	rebootCmd.Flags().BoolP("yes", "y", false, "Skip confirmation")
+	rootCmd.AddCommand(rebootCmd)
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/recipe"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/ui"
+	"golang.org/x/term"
+)
+
+// recipes command
+var recipesCmd = &cobra.Command{
+	Use:   "recipes",
+	Short: "Run end-to-end recipes built from playbooks",
+	Long: `A recipe is an end-to-end workflow, such as serving a model or starting a
+fine-tune, made of 'dgx run' playbook commands and shell steps on the DGX, with
+parameters asked for before it starts.
+
+The built-in catalog ships with dgx; 'dgx recipes update' downloads the newest
+one into ~/.config/dgx/recipes. Recipes of your own go in other .yaml files in
+that directory, in the same format, and replace catalog recipes of the same name.
+
+Examples:
+  dgx recipes
+  dgx recipes show qwen-lora
+  dgx recipes run llama-70b-vllm
+  dgx recipes run qwen-lora --set model=Qwen/Qwen2.5-14B-Instruct --yes
+  dgx recipes update`,
+	Run: func(cmd *cobra.Command, args []string) {
+		recipesListCmd.Run(cmd, args)
+	},
+}
+
+var recipesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the available recipes",
+	Run: func(cmd *cobra.Command, args []string) {
+		recipes := loadRecipes()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tDESCRIPTION\tSOURCE")
+		for _, r := range recipes {
+			source := r.Source
+			if source != "built-in" {
+				source = filepath.Base(source)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", r.Name, r.Description, source)
+		}
+		w.Flush()
+	},
+}
+
+var recipesShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Show a recipe's parameters and steps",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		r := findRecipe(args[0])
+		fmt.Printf("%s: %s\n", r.Name, r.Description)
+		if len(r.Params) > 0 {
+			fmt.Println("\nParameters:")
+			for _, p := range r.Params {
+				fmt.Printf("  %-12s %s (default: %s)\n", p.Name, p.Prompt, ui.OrDash(p.Default))
+			}
+		}
+		fmt.Println("\nSteps:")
+		for i, s := range r.Steps {
+			fmt.Printf("  %d. %s\n", i+1, s.Name)
+			if s.Run != "" {
+				fmt.Printf("       dgx run %s\n", s.Run)
+				continue
+			}
+			for _, line := range strings.Split(strings.TrimRight(s.Exec, "\n"), "\n") {
+				fmt.Printf("       %s\n", line)
+			}
+		}
+	},
+}
+
+var recipesRunCmd = &cobra.Command{
+	Use:   "run <name>",
+	Short: "Run a recipe on the DGX",
+	Long: `Run a recipe's steps in order. Parameters not given with --set are asked for,
+with the recipe's default offered; without a terminal the defaults are used.
+Playbook steps are confirmed like 'dgx run'. When a step fails, fix the cause
+and continue with --from <step>.
+
+Examples:
+  dgx recipes run comfyui
+  dgx recipes run qwen-lora --set run=support-bot --set dataset=my-org/support-chats
+  dgx recipes run qwen-lora --from 3`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		sets, _ := cmd.Flags().GetStringArray("set")
+		yes, _ := cmd.Flags().GetBool("yes")
+		from, _ := cmd.Flags().GetInt("from")
+
+		r := findRecipe(args[0])
+		if from < 1 || from > len(r.Steps) {
+			exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("--from must be a step between 1 and %d", len(r.Steps))))
+		}
+		values, err := recipeValues(r, sets)
+		if err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Usage, err))
+		}
+
+		cfg := cfgManager.Get()
+		if r.HasExec() {
+			requireShell(cfg, "dgx recipes run "+r.Name)
+		}
+		if !confirmCommand(cmd, fmt.Sprintf("Run recipe %s (%d steps) on %s?", r.Name, len(r.Steps), cfg.Host), yes) {
+			fmt.Println("Recipe cancelled.")
+			exit(exitcode.Aborted)
+		}
+
+		client, err := ssh.NewClient(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+		redactRemoteSecrets(client)
+
+		if err := newPlaybookManager(client).RunRecipe(r, values, from); err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("\nRecipe %s finished.\n", r.Name)
+		if notes := r.ExpandNotes(values); notes != "" {
+			fmt.Print("\n" + notes)
+		}
+	},
+}
+
+var recipesUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Download the newest recipe catalog",
+	Run: func(cmd *cobra.Command, args []string) {
+		url, _ := cmd.Flags().GetString("url")
+		dir, err := recipe.DefaultDir()
+		if err != nil {
+			exitWithError(err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Usage, err))
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			exitWithError(fmt.Errorf("failed to download the catalog: %w", err))
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			exitWithError(fmt.Errorf("failed to download the catalog from %s: %s", url, resp.Status))
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+		if err != nil {
+			exitWithError(fmt.Errorf("failed to download the catalog: %w", err))
+		}
+		recipes, err := recipe.ParseCatalog(data, url)
+		if err != nil {
+			exitWithError(err)
+		}
+
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			exitWithError(err)
+		}
+		path := filepath.Join(dir, recipe.CatalogFile)
+		if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+			exitWithError(err)
+		}
+		if err := os.Rename(path+".tmp", path); err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Updated %s: %d recipes\n", path, len(recipes))
+	},
+}
+
+// loadRecipes returns the built-in, downloaded, and local recipes
+func loadRecipes() []recipe.Recipe {
+	dir, err := recipe.DefaultDir()
+	if err != nil {
+		exitWithError(err)
+	}
+	recipes, err := recipe.Load(dir)
+	if err != nil {
+		exitWithError(exitcode.Wrap(exitcode.Config, err))
+	}
+	return recipes
+}
+
+func findRecipe(name string) *recipe.Recipe {
+	r, err := recipe.Find(loadRecipes(), name)
+	if err != nil {
+		exitWithError(exitcode.Wrap(exitcode.Usage, err))
+	}
+	return r
+}
+
+// recipeValues collects the recipe's parameters from --set, then by asking on the
+// terminal, falling back to the defaults
+func recipeValues(r *recipe.Recipe, sets []string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, s := range sets {
+		name, value, ok := strings.Cut(s, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid --set %q (want name=value)", s)
+		}
+		values[name] = value
+	}
+	known := map[string]bool{}
+	for _, p := range r.Params {
+		known[p.Name] = true
+	}
+	for name := range values {
+		if !known[name] {
+			return nil, fmt.Errorf("recipe %s has no parameter %s", r.Name, name)
+		}
+	}
+
+	interactive := term.IsTerminal(int(os.Stdin.Fd()))
+	reader := bufio.NewReader(os.Stdin)
+	for _, p := range r.Params {
+		value, given := values[p.Name]
+		switch {
+		case given:
+		case interactive:
+			var err error
+			if value, err = askParam(reader, p); err != nil {
+				return nil, err
+			}
+		case p.Default == "":
+			return nil, fmt.Errorf("%s is required: pass --set %s=<value>", p.Name, p.Name)
+		default:
+			value = p.Default
+		}
+		if err := p.Check(value); err != nil {
+			return nil, err
+		}
+		values[p.Name] = value
+	}
+	return values, nil
+}
+
+// askParam asks for a parameter until a valid value is given; an empty answer takes the
+// default
+func askParam(reader *bufio.Reader, p recipe.Param) (string, error) {
+	for {
+		if p.Default != "" {
+			fmt.Printf("%s [%s]: ", p.Prompt, p.Default)
+		} else {
+			fmt.Printf("%s: ", p.Prompt)
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		value := strings.TrimSpace(line)
+		if value == "" {
+			value = p.Default
+		}
+		if value == "" {
+			continue
+		}
+		if err := p.Check(value); err != nil {
+			fmt.Println(err)
+			continue
+		}
+		return value, nil
+	}
+}
+
+func init() {
+	recipesRunCmd.Flags().StringArray("set", nil, "Set a parameter (name=value); repeatable")
+	recipesRunCmd.Flags().BoolP("yes", "y", false, "Skip the confirmation prompt")
+	recipesRunCmd.Flags().Int("from", 1, "Start at this step, after fixing a failed one")
+	recipesUpdateCmd.Flags().String("url", recipe.DefaultCatalogURL, "Catalog to download")
+
+	recipesCmd.AddCommand(recipesListCmd, recipesShowCmd, recipesRunCmd, recipesUpdateCmd)
+	rootCmd.AddCommand(recipesCmd)
+}
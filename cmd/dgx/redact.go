@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/weatherman/dgx-manager/internal/daemon"
+	"github.com/weatherman/dgx-manager/internal/redact"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/tracking"
+)
+
+// remoteSecretsLoaded records the hosts whose dgx env store was read this run
+var remoteSecretsLoaded sync.Map
+
+// installRedactor registers the secrets this machine knows of, the daemon token and the
+// proxy's API keys, with the process-wide redactor
+func installRedactor() {
+	if token, err := os.ReadFile(daemon.TokenPath(cfgManager.GetConfigPath())); err == nil {
+		redact.Add(strings.TrimSpace(string(token)))
+	}
+	if serve := cfgManager.Get().Serve; serve != nil {
+		for _, k := range serve.Keys {
+			redact.Add(k.Key)
+		}
+	}
+}
+
+// redactRemoteSecrets registers the values in the DGX's dgx env store, so output that
+// echoes HF_TOKEN or WANDB_API_KEY is masked too. The store is read once per host.
+func redactRemoteSecrets(client *ssh.Client) {
+	if _, loaded := remoteSecretsLoaded.LoadOrStore(client.Host(), true); loaded {
+		return
+	}
+	output, err := client.Execute("cat " + tracking.EnvFile + " 2>/dev/null || true")
+	if err != nil {
+		return
+	}
+	redact.Add(redact.EnvValues(output)...)
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/registry"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+// registry command
+var registryCmd = &cobra.Command{
+	Use:   "registry",
+	Short: "Manage docker registry logins on the DGX",
+	Long: `Log docker on the DGX in to container registries with credentials kept in the
+dgx env store (~/.config/dgx/env.sh on the DGX, see 'dgx env'). The password is
+passed to docker login on stdin on the DGX, so it never appears in a process list
+and is not read back to this machine.
+
+docker keeps a login in ~/.docker/config.json on the DGX until it logs out.
+--ttl logs out again after a while, and a command after -- runs logged in and
+logs out when it ends. nvcr.io logs in as $oauthtoken, so setting it asks only for
+the NGC API key; an NGC_API_KEY already in the env store is used when none was set.
+
+Examples:
+  dgx registry set ghcr.io --username octocat
+  dgx registry login ghcr.io
+  dgx registry login ghcr.io --ttl 1h
+  dgx registry login ghcr.io -- docker push ghcr.io/acme/trainer:1.2
+  dgx registry list
+  dgx registry logout ghcr.io`,
+}
+
+var registrySetCmd = &cobra.Command{
+	Use:   "set <registry>",
+	Short: "Store credentials for a registry in the env store on the DGX",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		r := parseRegistry(args[0])
+		username, _ := cmd.Flags().GetString("username")
+		passwordStdin, _ := cmd.Flags().GetBool("password-stdin")
+
+		if username == "" {
+			username = r.DefaultUser
+		}
+		if username == "" {
+			var err error
+			if username, err = promptForSecret(r.Host + " username"); err != nil {
+				exitWithError(exitcode.Wrap(exitcode.Usage, err))
+			}
+		}
+		var password string
+		if passwordStdin {
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				exitWithError(err)
+			}
+			password = strings.TrimRight(string(data), "\r\n")
+		} else {
+			secret, err := ssh.ReadSecret(fmt.Sprintf("%s password or token: ", r.Host))
+			if err != nil {
+				exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("%w (use --password-stdin)", err)))
+			}
+			password = string(secret)
+		}
+		if password == "" {
+			exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("the password cannot be empty")))
+		}
+
+		err := setRemoteEnvVars(map[string]string{
+			r.HostVar:     r.Host,
+			r.UserVar:     username,
+			r.PasswordVar: password,
+		})
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Log in with: dgx registry login %s\n", r.Host)
+	},
+}
+
+var registryLoginCmd = &cobra.Command{
+	Use:   "login <registry> [-- command...]",
+	Short: "Log docker on the DGX in to a registry with the stored credentials",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ttl, _ := cmd.Flags().GetDuration("ttl")
+
+		var command []string
+		if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+			args, command = args[:dash], args[dash:]
+		}
+		if len(args) != 1 {
+			exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("expected one registry; put the command after --")))
+		}
+		r := parseRegistry(args[0])
+		if ttl < 0 || (ttl > 0 && ttl < time.Minute) {
+			exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("--ttl must be at least 1m")))
+		}
+		if ttl > 0 && len(command) > 0 {
+			exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("a command after -- logs out when it ends, so --ttl does not apply")))
+		}
+
+		cfg := cfgManager.Get()
+		script := registry.LoginScript(r, ttl)
+		if len(command) > 0 {
+			remote, err := execCommand(cfg, command)
+			if err != nil {
+				exitWithError(err)
+			}
+			script = registry.RunScript(r, remote)
+		}
+		client, err := ssh.NewClient(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+		redactRemoteSecrets(client)
+		if err := client.Stream(script, nil, os.Stdout, os.Stderr); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+var registryLogoutCmd = &cobra.Command{
+	Use:   "logout <registry>",
+	Short: "Log docker on the DGX out of a registry",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		r := parseRegistry(args[0])
+		client, err := ssh.NewClient(cfgManager.Get())
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+		if err := client.Stream(registry.LogoutScript(r), nil, os.Stdout, os.Stderr); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+var registryListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List registries with stored credentials or a docker login on the DGX",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		client, err := ssh.NewClient(cfgManager.Get())
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+		output, err := client.Execute(registry.ListScript())
+		if err != nil {
+			exitWithError(err)
+		}
+		statuses := registry.ParseList(output)
+		if asJSON {
+			printJSON(statuses)
+			return
+		}
+		if len(statuses) == 0 {
+			fmt.Println("No registry credentials or logins on the DGX. Store some with: dgx registry set <registry>")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "REGISTRY\tCREDENTIALS\tLOGGED IN")
+		for _, s := range statuses {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", s.Host, yesNo(s.Stored), yesNo(s.LoggedIn))
+		}
+		w.Flush()
+	},
+}
+
+// parseRegistry validates a registry argument
+func parseRegistry(host string) registry.Registry {
+	r, err := registry.Parse(host)
+	if err != nil {
+		exitWithError(exitcode.Wrap(exitcode.Usage, err))
+	}
+	return r
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func init() {
+	registrySetCmd.Flags().String("username", "", "Registry user name (prompted for when omitted)")
+	registrySetCmd.Flags().Bool("password-stdin", false, "Read the password or token from stdin")
+	registryLoginCmd.Flags().Duration("ttl", 0, "Log out again after this long, e.g. 2h")
+	registryListCmd.Flags().Bool("json", false, "Output as JSON")
+
+	registryCmd.AddCommand(registrySetCmd, registryLoginCmd, registryLogoutCmd, registryListCmd)
+	rootCmd.AddCommand(registryCmd)
+}
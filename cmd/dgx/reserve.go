@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/artifacts"
+	"github.com/weatherman/dgx-manager/internal/deploy"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/gpu"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/state"
+	"github.com/weatherman/dgx-manager/internal/ui"
+)
+
+var gpuReserveCmd = &cobra.Command{
+	Use:   "reserve [<name> <size>]",
+	Short: "Reserve shares of GPU memory for concurrent workloads",
+	Long: `Record how much of the GPU's memory each workload may use, and warn when the
+reservations add up to more than the DGX has. On GB10 the GPU shares the unified
+system memory, so the total is the system memory.
+
+The size is a fraction (0.4), a percentage (40%), or a size: 48GiB or 48G in
+binary units, 50GB in decimal ones.
+
+For a vllm deployment from 'dgx deploy autostart', the reservation is applied as
+vLLM's --gpu-memory-utilization by reinstalling its unit (this uses sudo), and
+takes effect at the next start or with --restart. vllm deployments without a
+reservation are counted at vLLM's default of 90%, which leaves little room for
+anything else. Docker Model Runner, Ollama, and other workloads have no memory
+limit to set, so their reservations are recorded for planning only.
+
+Without arguments, list the reservations. Reservations are kept per host in the
+local state directory.
+
+Examples:
+  dgx gpu reserve llama 40% --restart
+  dgx gpu reserve finetune 32GiB --note "LoRA runs"
+  dgx gpu reserve
+  dgx gpu reserve llama --clear`,
+	Args: cobra.MaximumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		note, _ := cmd.Flags().GetString("note")
+		remove, _ := cmd.Flags().GetBool("clear")
+		restart, _ := cmd.Flags().GetBool("restart")
+
+		var reservation gpu.Reservation
+		switch {
+		case remove && len(args) != 1:
+			exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("--clear takes the name of a reservation")))
+		case !remove && len(args) == 1:
+			exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("give a size for %s, or --clear to remove its reservation", args[0])))
+		case len(args) == 2:
+			var err error
+			if reservation, err = gpu.ParseReservation(args[1]); err != nil {
+				exitWithError(exitcode.Wrap(exitcode.Usage, err))
+			}
+			reservation.Name, reservation.Note = args[0], note
+		}
+
+		cfg := cfgManager.Get()
+		store, err := state.DefaultStore()
+		if err != nil {
+			exitWithError(err)
+		}
+		reservations, err := gpu.LoadReservations(store, cfg.Host)
+		if err != nil {
+			exitWithError(err)
+		}
+
+		client, err := ssh.NewClient(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+		total, err := gpu.NewMonitor(client).MemoryTotal()
+		if err != nil {
+			exitWithError(err)
+		}
+		manager := deploy.NewManager(client)
+		deployments, err := manager.List()
+		if err != nil {
+			exitWithError(err)
+		}
+
+		if len(args) == 0 {
+			printReservations(reservations, deployments, total)
+			return
+		}
+
+		name := args[0]
+		var kept []gpu.Reservation
+		for _, r := range reservations {
+			if r.Name != name {
+				kept = append(kept, r)
+			}
+		}
+		if remove {
+			if len(kept) == len(reservations) {
+				exitWithError(fmt.Errorf("no reservation for %s", name))
+			}
+		} else {
+			if reservation.Share(total) > 1 {
+				exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("%s is more than the %s the DGX has", reservation, artifacts.FormatBytes(total))))
+			}
+			kept = append(kept, reservation)
+		}
+		if err := gpu.SaveReservations(store, cfg.Host, kept); err != nil {
+			exitWithError(err)
+		}
+		if remove {
+			fmt.Printf("Removed the reservation for %s\n", name)
+		} else {
+			fmt.Printf("Reserved %s (%s) for %s\n", reservation, artifacts.FormatBytes(reservation.Size(total)), name)
+		}
+
+		for _, entry := range deployments {
+			if entry.Name != name {
+				continue
+			}
+			if entry.Engine != "vllm" {
+				fmt.Printf("%s uses %s, which has no memory limit to set; the reservation is recorded for planning\n", name, entry.Engine)
+				break
+			}
+			entry.GPUMemory = 0
+			if !remove {
+				entry.GPUMemory = vllmShare(reservation, total)
+			}
+			fmt.Printf("Updating %s...\n", deploy.UnitName(entry.Name))
+			if err := manager.Enable(entry, false); err != nil {
+				exitWithError(err)
+			}
+			if !restart {
+				fmt.Printf("Applies at the next start of %s (or pass --restart)\n", entry.Name)
+				break
+			}
+			fmt.Printf("Restarting %s...\n", deploy.UnitName(entry.Name))
+			if err := manager.Restart(entry.Name); err != nil {
+				exitWithError(err)
+			}
+		}
+
+		reservations, _ = gpu.LoadReservations(store, cfg.Host)
+		warnOvercommit(reservationRows(reservations, deployments), total)
+	},
+}
+
+// reservationRow is one workload counted against the GPU's memory
+type reservationRow struct {
+	gpu.Reservation
+	Engine  string
+	Applied string
+}
+
+// reservationRows merges the recorded reservations with the autostart deployments. vllm
+// deployments without one count at vLLM's default share.
+func reservationRows(reservations []gpu.Reservation, deployments []deploy.Autostart) []reservationRow {
+	engines := map[string]deploy.Autostart{}
+	for _, d := range deployments {
+		engines[d.Name] = d
+	}
+	var rows []reservationRow
+	for _, r := range reservations {
+		row := reservationRow{Reservation: r, Engine: "-", Applied: "recorded"}
+		if d, ok := engines[r.Name]; ok {
+			row.Engine = d.Engine
+			if d.Engine == "vllm" {
+				row.Applied = "not applied"
+				if d.GPUMemory > 0 {
+					row.Applied = fmt.Sprintf("--gpu-memory-utilization %.2f", d.GPUMemory)
+				}
+			}
+		}
+		rows = append(rows, row)
+	}
+	for _, d := range deployments {
+		if _, ok := gpu.FindReservation(reservations, d.Name); ok || d.Engine != "vllm" {
+			continue
+		}
+		share, applied := gpu.VLLMDefaultFraction, "vLLM default"
+		if d.GPUMemory > 0 {
+			share, applied = d.GPUMemory, fmt.Sprintf("--gpu-memory-utilization %.2f", d.GPUMemory)
+		}
+		rows = append(rows, reservationRow{
+			Reservation: gpu.Reservation{Name: d.Name, Fraction: share},
+			Engine:      d.Engine,
+			Applied:     applied,
+		})
+	}
+	return rows
+}
+
+// printReservations lists the reservations with the total against the DGX's memory
+func printReservations(reservations []gpu.Reservation, deployments []deploy.Autostart, total int64) {
+	rows := reservationRows(reservations, deployments)
+	if len(rows) == 0 {
+		fmt.Println("No GPU memory reserved")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tENGINE\tRESERVED\tSIZE\tAPPLIED\tNOTE")
+	for _, r := range rows {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", r.Name, r.Engine, r.Reservation, artifacts.FormatBytes(r.Size(total)), r.Applied, ui.OrDash(r.Note))
+	}
+	w.Flush()
+	warnOvercommit(rows, total)
+}
+
+// warnOvercommit prints the reserved total and warns when it exceeds the DGX's memory
+func warnOvercommit(rows []reservationRow, total int64) {
+	var sum int64
+	for _, r := range rows {
+		sum += r.Size(total)
+	}
+	fmt.Printf("\nReserved %s of %s (%.0f%%)\n", artifacts.FormatBytes(sum), artifacts.FormatBytes(total), float64(sum)*100/float64(total))
+	if sum > total {
+		fmt.Fprintf(os.Stderr, "Warning: reservations exceed the memory by %s; workloads running together may fail to load or be killed\n", artifacts.FormatBytes(sum-total))
+	}
+}
+
+// vllmShare converts a reservation to --gpu-memory-utilization, rounded down to the two
+// decimals the unit records so it never exceeds the reservation
+func vllmShare(r gpu.Reservation, total int64) float64 {
+	return math.Max(math.Floor(r.Share(total)*100)/100, 0.01)
+}
+
+// reservedShare returns the vLLM share recorded for the named deployment, or zero, so
+// reinstalling a deployment keeps its reservation
+func reservedShare(client *ssh.Client, host, name string) float64 {
+	store, err := state.DefaultStore()
+	if err != nil {
+		return 0
+	}
+	reservations, err := gpu.LoadReservations(store, host)
+	if err != nil {
+		return 0
+	}
+	r, ok := gpu.FindReservation(reservations, name)
+	if !ok {
+		return 0
+	}
+	var total int64
+	if r.Bytes > 0 {
+		if total, err = gpu.NewMonitor(client).MemoryTotal(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: not applying the GPU reservation for %s: %v\n", name, err)
+			return 0
+		}
+	}
+	return vllmShare(r, total)
+}
+
+func init() {
+	gpuReserveCmd.Flags().String("note", "", "Describe the workload the reservation is for")
+	gpuReserveCmd.Flags().Bool("clear", false, "Remove the named reservation")
+	gpuReserveCmd.Flags().Bool("restart", false, "Restart a vllm deployment so the reservation applies now")
+
+	gpuCmd.AddCommand(gpuReserveCmd)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/transfer"
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// rsh command: the remote shell rsync runs for 'dgx sync --bwlimit'. rsync invokes it as
+// "<rsh> [-l user] host command...", and it runs the command over the Go SSH client with
+// both directions of the byte stream paced by a token bucket.
+var rshCmd = &cobra.Command{
+	Use:    "__rsh --bwlimit <rate> [-l user] <host> <command...>",
+	Hidden: true,
+	Args:   cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		limit, _ := cmd.Flags().GetString("bwlimit")
+		rate, err := transfer.ParseRate(limit)
+		if err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Usage, err))
+		}
+
+		// 'dgx sync' is refused under exec_allow before it ever starts rsync, so nothing
+		// legitimate reaches here on such a connection
+		cfg := cfgManager.Get()
+		requireFileAccess(cfg, "dgx __rsh")
+		client, err := ssh.NewClient(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+
+		// The host argument is the one 'dgx sync' substituted for "dgx:"; the connection
+		// settings come from the forwarded flags instead
+		command := strings.Join(args[1:], " ")
+		stdin := transfer.NewReader(os.Stdin, transfer.NewLimiter(rate))
+		stdout := transfer.NewWriter(os.Stdout, transfer.NewLimiter(rate))
+		if err := client.Stream(command, stdin, stdout, os.Stderr); err != nil {
+			if status, ok := ssh.RemoteExitStatus(err); ok {
+				client.Close()
+				exit(status)
+			}
+			exitWithError(err)
+		}
+	},
+}
+
+// throttledRsh returns the rsync -e command that routes a transfer through 'dgx __rsh',
+// forwarding the resolved connection settings so the child connects to the same DGX
+func throttledRsh(cfg *types.Config, rate int64) (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate the dgx executable: %w", err)
+	}
+	args := append([]string{exe, "__rsh", "--bwlimit", transfer.FormatRate(rate)}, connectionArgs(cfg)...)
+	for i, arg := range args {
+		args[i] = ssh.ShellQuote(arg)
+	}
+	return strings.Join(args, " "), nil
+}
+
+// connectionArgs returns the root flags that point a child dgx process at cfg's DGX
+func connectionArgs(cfg *types.Config) []string {
+	args := []string{
+		"--host", cfg.Host,
+		"--ssh-port", strconv.Itoa(cfg.Port),
+		"--user", cfg.User,
+	}
+	if cfg.IdentityFile != "" {
+		args = append(args, "--identity-file", cfg.IdentityFile)
+	}
+	if cfg.ReadOnly {
+		args = append(args, "--readonly")
+	}
+	return args
+}
+
+func init() {
+	rshCmd.Flags().String("bwlimit", "", "Bandwidth limit per direction")
+	rshCmd.Flags().StringP("login", "l", "", "Remote user (ignored; taken from the DGX configuration)")
+	// Everything from the host onwards belongs to the remote command
+	rshCmd.Flags().SetInterspersed(false)
+	rootCmd.AddCommand(rshCmd)
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/daemon"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/redact"
+	"github.com/weatherman/dgx-manager/internal/serve"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/usage"
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run a local OpenAI-compatible proxy to models on the DGX",
+	Long: `Expose an OpenAI-compatible endpoint on this machine that forwards requests
+over SSH to inference servers on the DGX (and optionally a second Spark).
+
+Requests are routed by their "model" field using the serve.routes table in
+~/.config/dgx/config.yaml. Each route lists backends in failover order;
+backends are health-checked in the background and a request that fails to
+connect is retried on the next backend. Without routes, every model is sent
+to Docker Model Runner on port 12434.
+
+Once any API key exists (dgx serve keys add), every request must send
+"Authorization: Bearer <key>" and is subject to that key's rate limit.
+Each request is logged with its key, model, backend, status, and duration,
+and token usage is recorded for 'dgx usage report'.
+
+Chat, embedding (/v1/embeddings), and rerank (/v1/rerank, /v1/score) requests
+are all proxied. A route with a type (chat, embedding, or rerank) only serves
+requests of that type, so embedding models can go to their own server; untyped
+routes serve everything.
+
+With serve.max_concurrency set, at most that many requests reach the backends
+at once, so bursts from several clients cannot run the GPU out of memory. Up to
+serve.max_queue more (default 4 per slot) wait up to serve.queue_timeout
+(default 2m) for a slot; beyond that clients get 429 with Retry-After. The queue
+depth shows in 'dgx serve status'.
+
+With serve.wake set, a request that finds the DGX suspended by 'dgx power idle'
+wakes it with Wake-on-LAN and is retried once it answers.
+
+Example config:
+  serve:
+    listen: 127.0.0.1:8080
+    max_concurrency: 4
+    routes:
+      - model: "ai/*"
+        backends:
+          - {name: dmr, port: 12434, base_path: /engines}
+      - model: "*"
+        type: embedding
+        backends:
+          - {name: vllm-embed, port: 8001}
+      - model: "*"
+        backends:
+          - {name: vllm, port: 8000}
+          - {name: vllm-spark2, host: spark2.local, port: 8000}
+
+Examples:
+  dgx serve
+  dgx serve --listen 0.0.0.0:8080
+  dgx serve status
+  dgx serve keys add alice --rate-limit 30`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := cfgManager.Get()
+		serveCfg := cfg.Serve
+		if serveCfg == nil {
+			serveCfg = &types.ServeConfig{}
+		}
+
+		listen, _ := cmd.Flags().GetString("listen")
+		if listen == "" {
+			listen = serveCfg.Listen
+		}
+		if listen == "" {
+			listen = serve.DefaultListen
+		}
+
+		if cmd.Flags().Changed("max-concurrency") {
+			// Copy so the override never reaches the config file
+			override := *serveCfg
+			override.MaxConcurrency, _ = cmd.Flags().GetInt("max-concurrency")
+			serveCfg = &override
+		}
+
+		if err := serve.ValidateRoutes(serveCfg.Routes); err != nil {
+			exitWithError(err)
+		}
+		router := serve.NewRouter(serveCfg.Routes)
+		dialers, closeAll, err := backendDialers(cfg, router.Backends())
+		if err != nil {
+			exitWithError(err)
+		}
+		defer closeAll()
+
+		logFile, _ := cmd.Flags().GetString("log-file")
+		if logFile == "" {
+			logFile = serveCfg.LogFile
+		}
+		var logOut io.Writer = os.Stdout
+		if logFile != "" {
+			path, err := expandPath(logFile)
+			if err != nil {
+				exitWithError(err)
+			}
+			f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: failed to open log file: %v\n", err)
+				exit(1)
+			}
+			defer f.Close()
+			logOut = io.MultiWriter(os.Stdout, f)
+		}
+		// Request logs can carry the proxy's keys or upstream tokens
+		logWriter := redact.NewWriter(logOut, redact.Default())
+		defer logWriter.Flush()
+		logOut = logWriter
+
+		var store *usage.Store
+		if noUsage, _ := cmd.Flags().GetBool("no-usage"); !noUsage {
+			path, err := usage.DefaultPath()
+			if err != nil {
+				exitWithError(err)
+			}
+			store = usage.NewStore(path)
+		}
+
+		server := serve.NewServer(serveCfg, dialers, log.New(logOut, "", log.LstdFlags), store)
+		if serveCfg.Wake {
+			server.SetWaker(serveWaker(cfg))
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		fmt.Printf("Serving OpenAI-compatible API on http://%s/v1\n", listen)
+		if server.AuthEnabled() {
+			fmt.Printf("API key auth enabled (%d keys)\n", len(serveCfg.Keys))
+		} else if daemon.CheckLoopback(listen) != nil {
+			fmt.Fprintln(os.Stderr, "Warning: listening beyond localhost without API keys; anyone who can reach this port can use the GPU")
+			fmt.Fprintln(os.Stderr, "         Add one with: dgx serve keys add <name>")
+		}
+		if q := server.Admission().Status(); q != nil {
+			fmt.Printf("Admission control: %d concurrent requests, %d queued\n", q.MaxConcurrency, q.MaxQueue)
+		}
+		printRoutes(router.Routes())
+		fmt.Println("\nPress Ctrl+C to stop")
+
+		if err := server.ListenAndServe(ctx, listen); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+var serveStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show backend health from a running dgx serve proxy",
+	Run: func(cmd *cobra.Command, args []string) {
+		listen, _ := cmd.Flags().GetString("listen")
+		if listen == "" {
+			if cfg := cfgManager.Get(); cfg.Serve != nil {
+				listen = cfg.Serve.Listen
+			}
+		}
+		if listen == "" {
+			listen = serve.DefaultListen
+		}
+
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s/dgx/status", listen), nil)
+		if err != nil {
+			exitWithError(err)
+		}
+		if cfg := cfgManager.Get(); cfg.Serve != nil && len(cfg.Serve.Keys) > 0 {
+			req.Header.Set("Authorization", "Bearer "+cfg.Serve.Keys[0].Key)
+		}
+
+		client := &http.Client{Timeout: 5 * time.Second}
+		resp, err := client.Do(req)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: proxy not reachable on %s (is 'dgx serve' running?): %v\n", listen, err)
+			exit(1)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			fmt.Fprintf(os.Stderr, "Error: proxy returned %s\n", resp.Status)
+			exit(1)
+		}
+
+		var report serve.StatusReport
+		if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to decode proxy status: %v\n", err)
+			exit(1)
+		}
+
+		printRoutes(report.Routes)
+		if q := report.Queue; q != nil {
+			fmt.Printf("\nQueue: %d/%d running, %d/%d waiting, %d rejected\n", q.Active, q.MaxConcurrency, q.Queued, q.MaxQueue, q.Rejected)
+		}
+		fmt.Println()
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "BACKEND\tHOST\tPORT\tHEALTH\tLAST CHECK\tERROR")
+		for _, st := range report.Backends {
+			health := "healthy"
+			if !st.Healthy {
+				health = "down"
+			}
+			lastCheck := "-"
+			if !st.LastCheck.IsZero() {
+				lastCheck = time.Since(st.LastCheck).Round(time.Second).String() + " ago"
+			}
+			host := st.Backend.Host
+			if host == "" {
+				host = cfgManager.Get().Host
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\n", st.Backend.Name, host, st.Backend.Port, health, lastCheck, st.LastError)
+		}
+		w.Flush()
+	},
+}
+
+var serveKeysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage API keys for the serve proxy",
+	Long: `Manage bearer tokens that clients must send (Authorization: Bearer <key>)
+once any key is configured. Each key can carry its own rate limit in
+requests per minute. Restart 'dgx serve' after changing keys.`,
+}
+
+var serveKeysAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Generate a new API key",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		rateLimit, _ := cmd.Flags().GetInt("rate-limit")
+
+		if cfg := cfgManager.Get(); cfg.Serve != nil {
+			for _, k := range cfg.Serve.Keys {
+				if k.Name == name {
+					fmt.Fprintf(os.Stderr, "Error: key %q already exists\n", name)
+					exit(1)
+				}
+			}
+		}
+
+		key, err := serve.GenerateKey()
+		if err != nil {
+			exitWithError(err)
+		}
+
+		err = cfgManager.Update(func(cfg *types.Config) {
+			if cfg.Serve == nil {
+				cfg.Serve = &types.ServeConfig{}
+			}
+			cfg.Serve.Keys = append(cfg.Serve.Keys, types.APIKey{Name: name, Key: key, RateLimit: rateLimit})
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to save config: %v\n", err)
+			exit(exitcode.Config)
+		}
+
+		fmt.Printf("Created key %q:\n\n  %s\n\n", name, key)
+		fmt.Println("Share it with the client; it is stored in ~/.config/dgx/config.yaml.")
+	},
+}
+
+var serveKeysListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List API keys",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := cfgManager.Get()
+		if cfg.Serve == nil || len(cfg.Serve.Keys) == 0 {
+			fmt.Println("No API keys configured (the proxy accepts unauthenticated requests)")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tKEY\tRATE LIMIT")
+		for _, k := range cfg.Serve.Keys {
+			limit := "unlimited"
+			if k.RateLimit > 0 {
+				limit = fmt.Sprintf("%d/min", k.RateLimit)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", k.Name, maskKey(k.Key), limit)
+		}
+		w.Flush()
+	},
+}
+
+var serveKeysRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Revoke an API key",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		found := false
+		err := cfgManager.Update(func(cfg *types.Config) {
+			if cfg.Serve == nil {
+				return
+			}
+			keys := make([]types.APIKey, 0, len(cfg.Serve.Keys))
+			for _, k := range cfg.Serve.Keys {
+				if k.Name == name {
+					found = true
+					continue
+				}
+				keys = append(keys, k)
+			}
+			cfg.Serve.Keys = keys
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to save config: %v\n", err)
+			exit(exitcode.Config)
+		}
+		if !found {
+			fmt.Fprintf(os.Stderr, "Error: key %q not found\n", name)
+			exit(1)
+		}
+		fmt.Printf("Removed key %q\n", name)
+	},
+}
+
+func maskKey(key string) string {
+	if len(key) <= 12 {
+		return "****"
+	}
+	return key[:8] + "…" + key[len(key)-4:]
+}
+
+// backendDialers opens one SSH client per distinct backend host. Hosts other than the
+// configured DGX reuse its user, port, and key unless given as user@host.
+func backendDialers(cfg *types.Config, backends []types.Backend) (map[string]serve.DialFunc, func(), error) {
+	dialers := make(map[string]serve.DialFunc)
+	var clients []*ssh.Client
+	closeAll := func() {
+		for _, c := range clients {
+			c.Close()
+		}
+	}
+
+	for _, b := range backends {
+		if _, ok := dialers[b.Host]; ok {
+			continue
+		}
+		nodeCfg := cfg
+		if b.Host != "" {
+			nodeCfg = peerConfig(cfg, b.Host)
+		}
+		client, err := ssh.NewClient(nodeCfg)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		clients = append(clients, client)
+		dialers[b.Host] = client.Dial
+	}
+	return dialers, closeAll, nil
+}
+
+// serveWaker wakes suspended backend hosts for the proxy, one wake at a time. Requests
+// that waited behind a wake count the host as woken, so they retry too.
+func serveWaker(cfg *types.Config) serve.WakeFunc {
+	var mu sync.Mutex
+	woken := map[string]time.Time{}
+	return func(host string) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if time.Since(woken[host]) < time.Minute {
+			return true
+		}
+		nodeCfg, key := cfg, trackerKey()
+		if host != "" {
+			nodeCfg = peerConfig(cfg, host)
+			key = nodeCfg.Host
+		}
+		ok, err := wakeHost(nodeCfg, key, 3*time.Minute)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			return false
+		}
+		if ok {
+			woken[host] = time.Now()
+		}
+		return ok
+	}
+}
+
+func printRoutes(routes []types.Route) {
+	fmt.Println("Routes:")
+	for _, route := range routes {
+		names := make([]string, 0, len(route.Backends))
+		for _, b := range route.Backends {
+			names = append(names, b.Name)
+		}
+		pattern := route.Model
+		if route.Type != "" {
+			pattern += " (" + route.Type + ")"
+		}
+		fmt.Printf("  %-24s -> %s\n", pattern, strings.Join(names, ", "))
+	}
+}
+
+func init() {
+	serveCmd.PersistentFlags().String("listen", "", "Local address for the proxy (default from config or 127.0.0.1:8080)")
+	serveCmd.Flags().String("log-file", "", "Also append access logs to this file (default from config)")
+	serveCmd.Flags().Bool("no-usage", false, "Do not record per-request usage for dgx usage report")
+	serveCmd.Flags().Int("max-concurrency", 0, "Requests forwarded at once, the rest queued (default from config; 0 = unlimited)")
+	serveKeysAddCmd.Flags().Int("rate-limit", 0, "Requests per minute allowed for this key (0 = unlimited)")
+	serveKeysCmd.AddCommand(serveKeysAddCmd, serveKeysListCmd, serveKeysRemoveCmd)
+	serveCmd.AddCommand(serveStatusCmd, serveKeysCmd)
+	rootCmd.AddCommand(serveCmd)
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/session"
+	"github.com/weatherman/dgx-manager/internal/ui"
+	"golang.org/x/term"
+)
+
+// sessions command
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "List, replay, and export recorded sessions",
+	Long: `Recordings made with "dgx connect --record" and "dgx run --record" are stored
+in ~/.config/dgx/sessions in asciicast v2 format, so they can also be played with
+asciinema or uploaded to asciinema.org.`,
+}
+
+var sessionsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List recorded sessions",
+	Run: func(cmd *cobra.Command, args []string) {
+		recs, err := session.List(sessionsDir())
+		if err != nil {
+			exitWithError(err)
+		}
+		if len(recs) == 0 {
+			fmt.Println("No recorded sessions. Use 'dgx connect --record' or 'dgx run --record ...'.")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tDATE\tDURATION\tTITLE")
+		for _, rec := range recs {
+			title := rec.Header.Title
+			if title == "" {
+				title = rec.Header.Command
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+				rec.ID,
+				time.Unix(rec.Header.Timestamp, 0).Format("2006-01-02 15:04"),
+				rec.Duration().Round(time.Second),
+				ui.OrDash(title))
+		}
+		w.Flush()
+	},
+}
+
+var sessionsPlayCmd = &cobra.Command{
+	Use:   "play <id>",
+	Short: "Replay a recorded session in the terminal",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		speed, _ := cmd.Flags().GetFloat64("speed")
+		idleLimit, _ := cmd.Flags().GetDuration("idle-limit")
+
+		rec, err := session.Load(sessionsDir(), args[0])
+		if err != nil {
+			exitWithError(err)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		if err := session.Play(ctx, os.Stdout, rec, speed, idleLimit); err != nil && err != context.Canceled {
+			exitWithError(err)
+		}
+		// Leave the terminal in a sane state if playback stopped mid-sequence
+		fmt.Print("\x1b[0m\n")
+	},
+}
+
+var sessionsExportCmd = &cobra.Command{
+	Use:   "export <id>",
+	Short: "Export a recorded session as asciicast or plain text",
+	Long: `Export a recorded session. The cast format is the raw asciicast v2 file; the
+text format is a plain transcript with colors and cursor movement removed, which is
+handy for pasting into docs or bug reports.
+
+Examples:
+  dgx sessions export 20250101-120000-connect -o setup.cast
+  dgx sessions export 20250101-120000-run-ollama --format text > install.txt`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		format, _ := cmd.Flags().GetString("format")
+		output, _ := cmd.Flags().GetString("output")
+
+		rec, err := session.Load(sessionsDir(), args[0])
+		if err != nil {
+			exitWithError(err)
+		}
+
+		var data []byte
+		switch format {
+		case "cast":
+			if data, err = os.ReadFile(rec.Path); err != nil {
+				exitWithError(err)
+			}
+		case "text":
+			data = []byte(session.Text(rec))
+		default:
+			fmt.Fprintf(os.Stderr, "Error: unknown format %q (use cast or text)\n", format)
+			exit(exitcode.Usage)
+		}
+
+		if output == "" {
+			os.Stdout.Write(data)
+			return
+		}
+		if err := os.WriteFile(output, data, 0600); err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Exported %s to %s\n", rec.ID, output)
+	},
+}
+
+func sessionsDir() string {
+	dir, err := session.DefaultPath()
+	if err != nil {
+		exitWithError(err)
+	}
+	return dir
+}
+
+// terminalSize returns the size of the terminal on fd, or 80x24 when it is not a terminal
+func terminalSize(fd int) (int, int) {
+	if w, h, err := term.GetSize(fd); err == nil {
+		return w, h
+	}
+	return 80, 24
+}
+
+// startRecording records everything the process writes to stdout and stderr until the
+// returned stop function is called. stop restores the original streams and returns the
+// session ID.
+func startRecording(kind, title, command string) (func() string, error) {
+	width, height := terminalSize(int(os.Stdout.Fd()))
+	rec, id, err := session.Create(sessionsDir(), kind, session.Header{
+		Width:   width,
+		Height:  height,
+		Command: command,
+		Title:   title,
+		Env:     map[string]string{"TERM": os.Getenv("TERM")},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	origOut, origErr := os.Stdout, os.Stderr
+	var wg sync.WaitGroup
+	tee := func(dst *os.File) (*os.File, error) {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return nil, err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			io.Copy(io.MultiWriter(dst, rec), r)
+			r.Close()
+		}()
+		return w, nil
+	}
+	outW, err := tee(origOut)
+	if err != nil {
+		rec.Close()
+		return nil, fmt.Errorf("failed to capture output: %w", err)
+	}
+	errW, err := tee(origErr)
+	if err != nil {
+		outW.Close()
+		wg.Wait()
+		rec.Close()
+		return nil, fmt.Errorf("failed to capture output: %w", err)
+	}
+	os.Stdout, os.Stderr = outW, errW
+
+	return func() string {
+		outW.Close()
+		errW.Close()
+		wg.Wait()
+		os.Stdout, os.Stderr = origOut, origErr
+		rec.Close()
+		return id
+	}, nil
+}
+
+// takeFlag removes every occurrence of flag from args and reports whether it was present
+// (for commands that bypass cobra flag parsing)
+func takeFlag(args []string, flag string) ([]string, bool) {
+	kept := make([]string, 0, len(args))
+	found := false
+	for _, arg := range args {
+		if arg == flag {
+			found = true
+			continue
+		}
+		kept = append(kept, arg)
+	}
+	return kept, found
+}
+
+// sessionCommand describes the invocation for a recording header
+func sessionCommand(args ...string) string {
+	return strings.Join(append([]string{"dgx"}, args...), " ")
+}
+
+func init() {
+	sessionsPlayCmd.Flags().Float64("speed", 1, "Playback speed multiplier")
+	sessionsPlayCmd.Flags().Duration("idle-limit", 2*time.Second, "Shorten pauses longer than this (0 keeps the original timing)")
+	sessionsExportCmd.Flags().String("format", "cast", "Export format: cast or text")
+	sessionsExportCmd.Flags().StringP("output", "o", "", "Write to a file instead of stdout")
+
+	sessionsCmd.AddCommand(sessionsListCmd)
+	sessionsCmd.AddCommand(sessionsPlayCmd)
+	sessionsCmd.AddCommand(sessionsExportCmd)
+	rootCmd.AddCommand(sessionsCmd)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/workload"
+)
+
+// shell command
+var shellCmd = &cobra.Command{
+	Use:   "shell [container] [-- command...]",
+	Short: "Open an interactive shell in a running container",
+	Long: `Run docker exec -it in a container on the DGX over an SSH pseudo-terminal,
+starting bash, or sh when the image has no bash. Without a container name the
+running containers are listed to pick from. Anything after -- is run instead
+of the shell.
+
+Container names complete on Tab from the list seen by the last 'dgx shell'.
+
+Examples:
+  dgx shell vllm-server
+  dgx shell vllm-server --exec-user root -w /workspace
+  dgx shell vllm-server -- nvidia-smi`,
+	ValidArgsFunction: completeContainers,
+	Run: func(cmd *cobra.Command, args []string) {
+		execUser, _ := cmd.Flags().GetString("exec-user")
+		workdir, _ := cmd.Flags().GetString("workdir")
+
+		cfg := cfgManager.Get()
+		requireShell(cfg, "dgx shell")
+		client, err := ssh.NewClient(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+
+		// Always read the live list; storing it keeps completion current
+		cache := probeCache(cmd)
+		cache.SetBypass(true)
+		containers, err := cachedContainers(cache, cfg, client)
+		if err != nil {
+			exitWithError(err)
+		}
+
+		var container string
+		var command []string
+		if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+			command = args[dash:]
+			args = args[:dash]
+		}
+		switch len(args) {
+		case 0:
+			if len(containers) == 0 {
+				fmt.Println("No running containers")
+				return
+			}
+			fmt.Println("Running containers:")
+			for i, name := range containers {
+				fmt.Printf("  [%d] %s\n", i+1, name)
+			}
+			fmt.Printf("Select container [1-%d]: ", len(containers))
+			var choice string
+			fmt.Scanln(&choice)
+			idx, err := strconv.Atoi(choice)
+			if err != nil || idx < 1 || idx > len(containers) {
+				fmt.Println("No container selected.")
+				exit(exitcode.Aborted)
+			}
+			container = containers[idx-1]
+		case 1:
+			// Names and ID prefixes are resolved by docker, which reports unknown ones
+			container = args[0]
+		default:
+			fmt.Fprintln(os.Stderr, "Error: expected one container (put the command after --)")
+			exit(exitcode.Usage)
+		}
+
+		if err := client.RunPTY(workload.ExecCommand(container, execUser, workdir, command)); err != nil {
+			// The exit status of the last command in the shell is not a dgx failure
+			if status, ok := ssh.RemoteExitStatus(err); ok {
+				client.Close()
+				exit(status)
+			}
+			exitWithError(err)
+		}
+	},
+}
+
+func init() {
+	shellCmd.Flags().String("exec-user", "", "User to run as inside the container (docker exec --user)")
+	shellCmd.Flags().StringP("workdir", "w", "", "Working directory inside the container")
+	rootCmd.AddCommand(shellCmd)
+}
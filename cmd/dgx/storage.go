@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/artifacts"
+	"github.com/weatherman/dgx-manager/internal/health"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/ui"
+)
+
+// smartOutput is where the SMART script leaves its JSON on the DGX
+const smartOutput = `"$HOME/.cache/dgx/smart.json"`
+
+// storage command
+var storageCmd = &cobra.Command{
+	Use:   "storage",
+	Short: "Inspect the DGX's NVMe storage",
+}
+
+var storageHealthCmd = &cobra.Command{
+	Use:   "health",
+	Short: "Report NVMe wear, temperature, and error counts from SMART",
+	Long: `Read SMART data from every NVMe drive in the DGX and report wear (the
+drive's estimate of rated endurance used), spare capacity, temperature,
+lifetime reads and writes, and error counts. smartmontools is installed when
+it is missing; reading SMART needs sudo, which may prompt for a password.
+
+Model pulls and conversions write hundreds of gigabytes at a time, so wear
+is worth watching: at --wear-warn percent used the drive is flagged, and at
+--wear-critical it should be replaced. A failed SMART check, a critical
+warning, spare capacity at its threshold, or a temperature of 80°C or more
+is critical; media errors and 70°C are warnings.
+
+The exit status is non-zero only when a drive is in critical condition.
+
+Examples:
+  dgx storage health
+  dgx storage health --wear-warn 60
+  dgx storage health --json`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		wearWarn, _ := cmd.Flags().GetInt("wear-warn")
+		wearCritical, _ := cmd.Flags().GetInt("wear-critical")
+
+		client, err := ssh.NewClient(cfgManager.Get())
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+
+		drives, err := readSmart(client)
+		if len(drives) == 0 {
+			if err == nil {
+				err = fmt.Errorf("no NVMe drives found")
+			}
+			exitWithError(err)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(drives)
+			return
+		}
+
+		worst := health.SeverityOK
+		for i, d := range drives {
+			if i > 0 {
+				fmt.Println()
+			}
+			severity, findings := d.Assess(wearWarn, wearCritical)
+			if severity > worst {
+				worst = severity
+			}
+			printDrive(d, severity, findings)
+		}
+		if worst == health.SeverityCritical {
+			exit(1)
+		}
+	},
+}
+
+// readSmart runs the SMART script, with a terminal when sudo needs a password, and parses
+// the JSON it leaves behind
+func readSmart(client *ssh.Client) ([]health.Drive, error) {
+	script := health.SmartScript(smartOutput)
+	if _, err := client.Execute("sudo -n true"); err == nil {
+		if out, err := client.Execute(script); err != nil {
+			if line := strings.TrimSpace(out); line != "" {
+				return nil, fmt.Errorf("failed to read SMART data: %s", line)
+			}
+			return nil, fmt.Errorf("failed to read SMART data: %w", err)
+		}
+	} else if err := client.RunInteractive(script); err != nil {
+		return nil, fmt.Errorf("failed to read SMART data: %w", err)
+	}
+
+	output, err := client.Execute("cat " + smartOutput + " && rm -f " + smartOutput)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SMART data: %w", err)
+	}
+	return health.ParseSmartctl(strings.NewReader(output))
+}
+
+func printDrive(d health.Drive, severity health.Severity, findings []string) {
+	fmt.Printf("%s  %s (%s, firmware %s, serial %s)\n", d.Device, ui.OrDash(d.Model), artifacts.FormatBytes(d.CapacityBytes), ui.OrDash(d.Firmware), ui.OrDash(d.Serial))
+	fmt.Printf("  Health:       %s\n", strings.ToUpper(severity.String()))
+	fmt.Printf("  Wear:         %d%% used, spare %d%% (threshold %d%%)\n", d.PercentUsed, d.AvailableSpare, d.SpareThreshold)
+	fmt.Printf("  Temperature:  %d°C\n", d.TemperatureC)
+	fmt.Printf("  Written:      %s (read %s)\n", artifacts.FormatBytes(d.BytesWritten), artifacts.FormatBytes(d.BytesRead))
+	fmt.Printf("  Errors:       %d media, %d error log entries, %d unsafe shutdowns\n", d.MediaErrors, d.ErrorLogEntries, d.UnsafeShutdowns)
+	fmt.Printf("  Power-on:     %d hours\n", d.PowerOnHours)
+	for _, f := range findings {
+		fmt.Printf("  ! %s\n", f)
+	}
+}
+
+func init() {
+	storageHealthCmd.Flags().Bool("json", false, "Print SMART data as JSON")
+	storageHealthCmd.Flags().Int("wear-warn", health.WearWarn, "Warn at this percentage of rated endurance used")
+	storageHealthCmd.Flags().Int("wear-critical", health.WearCritical, "Report critical at this percentage of rated endurance used")
+	storageCmd.AddCommand(storageHealthCmd)
+	rootCmd.AddCommand(storageCmd)
+}
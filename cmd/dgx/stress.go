@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/gpu"
+	"github.com/weatherman/dgx-manager/internal/health"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/ui"
+)
+
+var gpuStressCmd = &cobra.Command{
+	Use:   "stress",
+	Short: "Burn in the GPU to validate a unit's stability and cooling",
+	Long: `Run every GPU at full load for --duration and report the peak temperature,
+power, and SM clocks, any thermal or power throttling, new ECC errors, and Xid
+errors the kernel logged, to validate a new unit's stability and cooling.
+
+Methods:
+  matmul    bf16 matrix multiplies in the NGC PyTorch container, with results
+            checked for silent computation errors (default)
+  gpu-burn  gpu-burn built from source in a CUDA devel container, at the commit
+            given by --gpu-burn-commit or pins.gpu_burn
+
+The load runs as the '` + gpu.StressContainer + `' container and stops on its own after
+--duration. It is stopped early when any GPU reaches --max-temp, or on Ctrl-C.
+Other GPU jobs will slow down while it runs.
+
+The exit status is non-zero when the unit fails: Xid errors, new uncorrectable
+ECC errors, computation errors, or the temperature limit reached.
+
+Examples:
+  dgx gpu stress
+  dgx gpu stress --duration 30m --max-temp 85
+  dgx gpu stress --method gpu-burn --gpu-burn-commit <sha> --json > burn-in.json`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		method, _ := cmd.Flags().GetString("method")
+		image, _ := cmd.Flags().GetString("image")
+		duration, _ := cmd.Flags().GetDuration("duration")
+		maxTemp, _ := cmd.Flags().GetInt("max-temp")
+		asJSON, _ := cmd.Flags().GetBool("json")
+		yes, _ := cmd.Flags().GetBool("yes")
+		commit, _ := cmd.Flags().GetString("gpu-burn-commit")
+
+		if !slices.Contains(gpu.StressMethods, method) {
+			exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("--method must be one of %s", strings.Join(gpu.StressMethods, ", "))))
+		}
+		if duration < 10*time.Second {
+			exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("--duration must be at least 10s")))
+		}
+		if image == "" {
+			image = gpu.DefaultImage(method)
+		}
+		cfg := cfgManager.Get()
+		if commit == "" && cfg.Pins != nil {
+			commit = cfg.Pins.GPUBurn
+		}
+		// Checked before confirming, so a missing commit is not found after the prompt
+		if _, err := gpu.StressCommand(method, image, commit, duration); err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Usage, err))
+		}
+		if !confirmCommand(cmd, fmt.Sprintf("Run a %v GPU stress test on %s?", duration, cfg.Host), yes) {
+			fmt.Println("Stress test cancelled.")
+			exit(exitcode.Aborted)
+		}
+
+		client, err := ssh.NewClient(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+
+		if procs, err := gpu.NewMonitor(client).ListProcesses(); err == nil && len(procs) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: %d process(es) already use the GPU; readings will include their load\n", len(procs))
+		}
+
+		// With --json the progress goes to stderr so stdout holds only the report
+		out := os.Stdout
+		if asJSON {
+			out = os.Stderr
+		}
+		fmt.Fprintf(out, "Stressing the GPU on %s for %v with %s (limit %d°C, Ctrl-C stops)...\n", cfg.Host, duration, method, maxTemp)
+		report, err := gpu.RunStress(client, gpu.StressOptions{
+			Method:        method,
+			Image:         image,
+			GPUBurnCommit: commit,
+			Duration:      duration,
+			MaxTemp:       maxTemp,
+			Interval:      time.Second,
+			Progress:      30 * time.Second,
+		}, out)
+		if err != nil {
+			exitWithError(err)
+		}
+
+		severity, findings := report.Assess(maxTemp)
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(struct {
+				*gpu.StressReport
+				Result   string   `json:"result"`
+				Findings []string `json:"findings"`
+			}{report, severity.String(), findings})
+		} else {
+			printStressReport(report, severity, findings)
+		}
+		if severity == health.SeverityCritical {
+			exit(1)
+		}
+	},
+}
+
+func printStressReport(r *gpu.StressReport, severity health.Severity, findings []string) {
+	fmt.Printf("\nStress test: %s for %v\n", r.Method, r.Duration)
+	for _, g := range r.GPUs {
+		fmt.Printf("GPU%d\n", g.Index)
+		fmt.Printf("  Temperature:  %d°C max\n", g.MaxTemp)
+		fmt.Printf("  Power:        %.0f W max\n", max(g.MaxPower, 0))
+		if g.MinSMClock > 0 {
+			fmt.Printf("  SM clock:     %d MHz avg, %d-%d MHz under load (max %d MHz)\n", g.AvgSMClock, g.MinSMClock, g.MaxSMClock, g.RatedSMClock)
+		}
+		fmt.Printf("  Utilization:  %d%% max\n", g.MaxUtil)
+		if after, ok := r.ECCAfter[g.Index]; ok && after.Corrected >= 0 {
+			fmt.Printf("  ECC:          %d corrected, %d uncorrected (volatile)\n", after.Corrected, after.Uncorrected)
+		} else {
+			fmt.Println("  ECC:          not reported")
+		}
+		fmt.Printf("  Throttling:   %s\n", ui.OrDash(strings.Join(g.Throttle, ", ")))
+	}
+	for _, x := range r.Xid {
+		fmt.Printf("Xid: %s\n", x)
+	}
+	fmt.Printf("Result: %s\n", strings.ToUpper(severity.String()))
+	for _, f := range findings {
+		fmt.Printf("  ! %s\n", f)
+	}
+}
+
+func init() {
+	gpuStressCmd.Flags().String("method", "matmul", "Load generator ("+strings.Join(gpu.StressMethods, ", ")+")")
+	gpuStressCmd.Flags().String("image", "", "Container image for the load (default depends on --method)")
+	gpuStressCmd.Flags().Duration("duration", gpu.DefaultStressDuration, "How long to run the load")
+	gpuStressCmd.Flags().Int("max-temp", gpu.DefaultMaxTemp, "Stop early when any GPU reaches this temperature (°C)")
+	gpuStressCmd.Flags().String("gpu-burn-commit", "", "Full wilicc/gpu-burn commit hash to build (default pins.gpu_burn)")
+	gpuStressCmd.Flags().Bool("json", false, "Print the report as JSON")
+	gpuStressCmd.Flags().BoolP("yes", "y", false, "Skip the confirmation prompt")
+	gpuCmd.AddCommand(gpuStressCmd)
+}
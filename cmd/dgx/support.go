@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/artifacts"
+	"github.com/weatherman/dgx-manager/internal/history"
+	"github.com/weatherman/dgx-manager/internal/redact"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/state"
+	"github.com/weatherman/dgx-manager/internal/support"
+)
+
+// support command
+var supportCmd = &cobra.Command{
+	Use:   "support",
+	Short: "Collect diagnostics for NVIDIA or project issue reports",
+}
+
+var supportBundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Gather DGX logs and dgx state into one redacted archive",
+	Long: `Collect everything an issue report usually asks for into a single .tar.gz on
+this machine:
+
+  DGX:   system and package versions, dmesg, the journal of the docker,
+         containerd, and nvidia units, nvidia-smi -q, Docker and Docker Model
+         Runner status and logs, and nvidia-bug-report.sh output
+  local: dgx version and effective settings, the config file, dgx's state
+         (step checkpoints, snapshots, remembered addresses), and the command
+         history
+
+Every file is redacted before it is written: the values in the dgx env store,
+the daemon token, proxy API keys, and anything shaped like a token, password,
+private key, or URL credential are replaced with [REDACTED]. Review the
+archive before attaching it anyway.
+
+Reading dmesg and the journal and running nvidia-bug-report.sh need sudo,
+which may ask for a password; --no-sudo skips them and collects what the user
+can read.
+
+Examples:
+  dgx support bundle
+  dgx support bundle --since 2h -o issue-1234.tar.gz
+  dgx support bundle --no-bug-report`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+		since, _ := cmd.Flags().GetDuration("since")
+		noSudo, _ := cmd.Flags().GetBool("no-sudo")
+		noBugReport, _ := cmd.Flags().GetBool("no-bug-report")
+
+		cfg := cfgManager.Get()
+		if output == "" {
+			output = fmt.Sprintf("dgx-support-%s-%s.tar.gz", strings.ReplaceAll(cfg.Host, ":", "_"), time.Now().Format("20060102-150405"))
+		}
+
+		client, err := ssh.NewClient(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+
+		redactRemoteSecrets(client)
+		bundle, err := support.Create(output, redact.Default())
+		if err != nil {
+			exitWithError(err)
+		}
+
+		opts := support.Options{Since: since, Sudo: !noSudo, BugReport: !noBugReport}
+		if err := collectRemote(client, bundle, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		if err := addLocalFiles(bundle); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		if err := bundle.Close(); err != nil {
+			exitWithError(err)
+		}
+
+		info, err := os.Stat(output)
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("\nWrote %s (%d files, %s)\n", output, len(bundle.Files()), artifacts.FormatBytes(info.Size()))
+		fmt.Println("Secrets were redacted; review the archive before attaching it to an issue.")
+	},
+}
+
+// collectRemote runs the collect script, with a terminal when sudo needs a password, and
+// copies what it gathered into the bundle
+func collectRemote(client *ssh.Client, bundle *support.Bundle, opts support.Options) error {
+	if opts.Sudo {
+		if _, err := client.Execute("sudo -n true"); err != nil {
+			if err := client.RunInteractive(support.CollectScript(opts)); err != nil {
+				return fmt.Errorf("failed to collect DGX logs: %w", err)
+			}
+			return fetchRemote(client, bundle)
+		}
+	}
+	stdout := redact.NewWriter(os.Stdout, redact.Default())
+	err := client.Stream(support.CollectScript(opts), nil, stdout, os.Stderr)
+	stdout.Flush()
+	if err != nil {
+		return fmt.Errorf("failed to collect DGX logs: %w", err)
+	}
+	return fetchRemote(client, bundle)
+}
+
+func fetchRemote(client *ssh.Client, bundle *support.Bundle) error {
+	r, w := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := bundle.AddArchive(r, "dgx")
+		// Drain what is left so the remote tar can finish
+		io.Copy(io.Discard, r)
+		done <- err
+	}()
+	err := client.Stream(support.FetchCommand(), nil, w, os.Stderr)
+	w.CloseWithError(err)
+	if addErr := <-done; addErr != nil && err == nil {
+		err = addErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to copy DGX logs: %w", err)
+	}
+	return nil
+}
+
+// addLocalFiles adds the dgx version, effective settings, config file, command history, and
+// state documents
+func addLocalFiles(bundle *support.Bundle) error {
+	var info strings.Builder
+	fmt.Fprintf(&info, "dgx version %s (%s/%s)\n", Version, runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&info, "collected %s\n\n", time.Now().Format(time.RFC3339))
+	for _, s := range cfgManager.Settings() {
+		fmt.Fprintf(&info, "%-16s %-32s %s\n", s.Name, s.Value, s.Source)
+	}
+	if err := bundle.Add("local/dgx.txt", []byte(info.String())); err != nil {
+		return err
+	}
+
+	if data, err := os.ReadFile(cfgManager.GetConfigPath()); err == nil {
+		if err := bundle.Add("local/config.yaml", data); err != nil {
+			return err
+		}
+	}
+
+	if path, err := history.DefaultPath(); err == nil {
+		if data, err := os.ReadFile(path); err == nil {
+			if err := bundle.Add("local/"+history.DefaultFile, data); err != nil {
+				return err
+			}
+		}
+	}
+
+	store, err := state.DefaultStore()
+	if err != nil {
+		return nil
+	}
+	dir := store.Dir()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		if err := bundle.Add("local/state/"+e.Name(), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	supportBundleCmd.Flags().StringP("output", "o", "", "Archive to write (default dgx-support-<host>-<time>.tar.gz)")
+	supportBundleCmd.Flags().Duration("since", 24*time.Hour, "How far back to collect the journal")
+	supportBundleCmd.Flags().Bool("no-sudo", false, "Do not use sudo; skips nvidia-bug-report.sh and root-only logs")
+	supportBundleCmd.Flags().Bool("no-bug-report", false, "Skip nvidia-bug-report.sh, which takes a minute or two")
+	supportCmd.AddCommand(supportBundleCmd)
+	rootCmd.AddCommand(supportCmd)
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/chat"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/health"
+	"github.com/weatherman/dgx-manager/internal/serve"
+	"github.com/weatherman/dgx-manager/internal/ui"
+)
+
+// Sample texts for the embedding checks: a sentence, a paraphrase, and something unrelated
+const (
+	embedSentence   = "The cat sat on the mat by the window."
+	embedParaphrase = "A kitten was sitting on the rug near the window."
+	embedUnrelated  = "Quarterly revenue grew by twelve percent."
+)
+
+// test command
+var testCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Check that models on the DGX give sensible answers",
+}
+
+var testChatCmd = &cobra.Command{
+	Use:   "chat [model]",
+	Short: "Validate a chat or vision model end to end",
+	Long: `Send a short prompt to a chat model and check that a reply streams back,
+reporting the time to the first token and the whole reply. With --image, the
+image files are attached and the model is asked to describe them, which checks
+that a vision model accepts images (PNG, JPEG, GIF, or WebP, up to 20 MiB).
+
+Requests go to the model's backends from serve.routes, or to --url, such as a
+running 'dgx serve'. Without a model, the preset's model and temperature are
+used (see 'dgx preset').
+
+The exit status is non-zero when a check fails.
+
+Examples:
+  dgx test chat ai/smollm2:360M-Q4_K_M
+  dgx test chat ai/gemma3 --image photo.jpg
+  dgx test chat Qwen/Qwen2.5-VL-7B-Instruct --image a.png --image b.png --url http://127.0.0.1:8080`,
+	Args:              cobra.RangeArgs(0, 1),
+	ValidArgsFunction: completeTestModel,
+	Run: func(cmd *cobra.Command, args []string) {
+		preset := chatPreset(cmd)
+		model := testModel(cmd, args, preset.Model, "dgx test chat <model>")
+		imagePaths, _ := cmd.Flags().GetStringArray("image")
+		prompt, _ := cmd.Flags().GetString("prompt")
+		images, err := chat.LoadImages(imagePaths)
+		if err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Usage, err))
+		}
+		if prompt == "" {
+			prompt = "Reply with one short sentence."
+			if len(images) > 0 {
+				prompt = "Describe this image in one sentence."
+			}
+		}
+
+		clients, cleanup := testClients(cmd, model, serve.TypeChat)
+		defer cleanup()
+		for _, c := range clients {
+			c.SetTemperature(preset.Temperature)
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		fmt.Printf("Testing chat model %s...\n", model)
+		message := chat.Message{Role: "user", Content: prompt, Images: images}
+		var results []health.Result
+		var lastErr error
+		for _, client := range clients {
+			if results, lastErr = checkChat(ctx, client, model, message); lastErr == nil {
+				break
+			}
+		}
+		if lastErr != nil {
+			detail := lastErr.Error()
+			if len(images) > 0 {
+				detail += " (does the model accept images?)"
+			}
+			results = []health.Result{{Name: "Request", Severity: health.SeverityCritical, Detail: detail}}
+		}
+		finishTest(results, model)
+	},
+}
+
+var testEmbedCmd = &cobra.Command{
+	Use:   "embed [model]",
+	Short: "Validate an embedding or rerank model end to end",
+	Long: `Send sample texts to an embedding model and check the vectors: one per input,
+a consistent dimension, no NaN or all-zero vectors, identical inputs embedding
+identically, and a paraphrase scoring closer than an unrelated sentence. With
+--rerank, check that a reranking model puts the relevant document first.
+
+Requests go to the model's backends from serve.routes (routes typed embedding
+or rerank, or untyped ones), or to --url, such as a running 'dgx serve'.
+
+The exit status is non-zero when a check fails.
+
+Examples:
+  dgx test embed BAAI/bge-m3
+  dgx test embed ai/mxbai-embed-large --url http://127.0.0.1:8080
+  dgx test embed BAAI/bge-reranker-v2-m3 --rerank`,
+	Args:              cobra.RangeArgs(0, 1),
+	ValidArgsFunction: completeTestModel,
+	Run: func(cmd *cobra.Command, args []string) {
+		model := testModel(cmd, args, "", "dgx test embed <model>")
+		rerank, _ := cmd.Flags().GetBool("rerank")
+		kind := serve.TypeEmbedding
+		if rerank {
+			kind = serve.TypeRerank
+		}
+		clients, cleanup := testClients(cmd, model, kind)
+		defer cleanup()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		fmt.Printf("Testing %s model %s...\n", kind, model)
+		var results []health.Result
+		var lastErr error
+		for _, client := range clients {
+			if rerank {
+				results, lastErr = checkRerank(ctx, client, model)
+			} else {
+				results, lastErr = checkEmbed(ctx, client, model)
+			}
+			if lastErr == nil {
+				break
+			}
+		}
+		if lastErr != nil {
+			results = []health.Result{{Name: "Request", Severity: health.SeverityCritical, Detail: lastErr.Error()}}
+		}
+		finishTest(results, model)
+	},
+}
+
+func completeTestModel(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeModels(cmd, args, toComplete)
+}
+
+// testModel returns the model argument, or fallback (the preset's model), or one picked
+// from a list when both are empty
+func testModel(cmd *cobra.Command, args []string, fallback, usage string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	if fallback != "" {
+		return fallback
+	}
+	model, err := pickModel(cmd, nil)
+	if err != nil {
+		exitWithError(exitcode.Wrap(exitcode.Usage, errors.New("a model is required: "+usage)))
+	}
+	return model
+}
+
+// testClients returns clients for the model's backends, or for --url
+func testClients(cmd *cobra.Command, model, kind string) ([]*chat.Client, func()) {
+	url, _ := cmd.Flags().GetString("url")
+	apiKey, _ := cmd.Flags().GetString("api-key")
+	if apiKey == "" {
+		apiKey = os.Getenv("DGX_API_KEY")
+	}
+	clients, cleanup, err := chatClients(cfgManager.Get(), model, kind, url, apiKey)
+	if err != nil {
+		exitWithError(err)
+	}
+	return clients, cleanup
+}
+
+// finishTest prints the results and exits non-zero when a check failed
+func finishTest(results []health.Result, model string) {
+	fmt.Print(health.FormatResults(results))
+	if health.Worst(results) == health.SeverityCritical {
+		exit(1)
+	}
+	rememberModel(model)
+}
+
+// checkChat sends one message to one backend and checks that a reply streams back. An
+// error means the request failed, so the next backend may be tried.
+func checkChat(ctx context.Context, client *chat.Client, model string, message chat.Message) ([]health.Result, error) {
+	start := time.Now()
+	var first time.Duration
+	var chunks int
+	reply, err := client.Complete(ctx, model, []chat.Message{message}, func(string) {
+		if chunks == 0 {
+			first = time.Since(start)
+		}
+		chunks++
+	})
+	if err != nil {
+		return nil, err
+	}
+	elapsed := time.Since(start)
+
+	reply = strings.TrimSpace(reply)
+	if reply == "" {
+		return []health.Result{{Name: "Reply", Severity: health.SeverityCritical, Detail: "the model returned an empty reply"}}, nil
+	}
+	return []health.Result{
+		{Name: "Reply", Severity: health.SeverityOK, Detail: ui.Truncate(ui.OneLine(reply), 60)},
+		{Name: "Latency", Severity: health.SeverityOK, Detail: fmt.Sprintf("first token after %v, %d chunks in %v",
+			first.Round(time.Millisecond), chunks, elapsed.Round(time.Millisecond))},
+	}, nil
+}
+
+// checkEmbed runs the embedding checks against one backend. An error means the backend
+// could not be queried at all, so the next one may be tried.
+func checkEmbed(ctx context.Context, client *chat.Client, model string) ([]health.Result, error) {
+	start := time.Now()
+	vectors, err := client.Embed(ctx, model, []string{embedSentence, embedParaphrase, embedUnrelated, embedSentence})
+	if err != nil {
+		return nil, err
+	}
+	elapsed := time.Since(start)
+
+	dim, err := chat.CheckEmbeddings(vectors)
+	if err != nil {
+		return []health.Result{{Name: "Vectors", Severity: health.SeverityCritical, Detail: err.Error()}}, nil
+	}
+	results := []health.Result{{
+		Name:     "Vectors",
+		Severity: health.SeverityOK,
+		Detail:   fmt.Sprintf("%d embeddings, %d dimensions in %v", len(vectors), dim, elapsed.Round(time.Millisecond)),
+	}}
+
+	same := chat.Cosine(vectors[0], vectors[3])
+	consistency := health.Result{Name: "Consistency", Severity: health.SeverityOK, Detail: fmt.Sprintf("identical inputs match (cosine %.4f)", same)}
+	if same < 0.99 {
+		// Batching can perturb low-precision kernels slightly, but not this much
+		consistency.Severity = health.SeverityWarn
+		consistency.Detail = fmt.Sprintf("identical inputs differ (cosine %.4f)", same)
+	}
+	results = append(results, consistency)
+
+	near, far := chat.Cosine(vectors[0], vectors[1]), chat.Cosine(vectors[0], vectors[2])
+	similarity := health.Result{Name: "Similarity", Severity: health.SeverityOK, Detail: fmt.Sprintf("paraphrase %.3f, unrelated %.3f", near, far)}
+	if near <= far {
+		similarity.Severity = health.SeverityCritical
+		similarity.Detail += " (the paraphrase should score higher; is this an embedding model?)"
+	}
+	return append(results, similarity), nil
+}
+
+// checkRerank runs the rerank check against one backend
+func checkRerank(ctx context.Context, client *chat.Client, model string) ([]health.Result, error) {
+	documents := []string{embedUnrelated, embedSentence, "The forecast calls for rain tomorrow."}
+	start := time.Now()
+	scores, err := client.Rerank(ctx, model, "Where did the cat sit?", documents)
+	if err != nil {
+		return nil, err
+	}
+	elapsed := time.Since(start)
+
+	best := scores[0]
+	for _, s := range scores[1:] {
+		if s.Score > best.Score {
+			best = s
+		}
+	}
+	results := []health.Result{{
+		Name:     "Scores",
+		Severity: health.SeverityOK,
+		Detail:   fmt.Sprintf("%d documents scored in %v", len(scores), elapsed.Round(time.Millisecond)),
+	}}
+	ranking := health.Result{Name: "Ranking", Severity: health.SeverityOK, Detail: fmt.Sprintf("relevant document ranked first (score %.3f)", best.Score)}
+	if best.Index != 1 {
+		ranking.Severity = health.SeverityCritical
+		ranking.Detail = fmt.Sprintf("ranked %q first (score %.3f)", documents[best.Index], best.Score)
+	}
+	return append(results, ranking), nil
+}
+
+func init() {
+	testChatCmd.Flags().StringArray("image", nil, "Attach an image file for vision models (repeatable)")
+	testChatCmd.Flags().String("prompt", "", "Prompt to send (default depends on whether images are attached)")
+	testChatCmd.Flags().Float64("temperature", 0, "Sampling temperature (default from the preset, else the server's)")
+	testChatCmd.Flags().String("preset", "", "Preset to take the model and temperature from (default: the active one)")
+	testChatCmd.Flags().String("url", "", "OpenAI-compatible base URL to use instead of SSH (e.g. a running dgx serve)")
+	testChatCmd.Flags().String("api-key", "", "Bearer token for --url (default $DGX_API_KEY)")
+	testEmbedCmd.Flags().Bool("rerank", false, "Test a reranking model instead of an embedding model")
+	testEmbedCmd.Flags().String("url", "", "OpenAI-compatible base URL to use instead of SSH (e.g. a running dgx serve)")
+	testEmbedCmd.Flags().String("api-key", "", "Bearer token for --url (default $DGX_API_KEY)")
+	testCmd.AddCommand(testChatCmd, testEmbedCmd)
+	rootCmd.AddCommand(testCmd)
+}
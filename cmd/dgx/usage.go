@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/usage"
+)
+
+// usage command
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Report model usage recorded by dgx serve",
+}
+
+var usageReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Summarize requests, tokens, and wall time per model and API key",
+	Long: `Summarize usage recorded by 'dgx serve' in ~/.config/dgx/usage.jsonl:
+request counts, errors, prompt/completion tokens, and wall time grouped by
+model and API key.
+
+Examples:
+  dgx usage report
+  dgx usage report --since 30d
+  dgx usage report --since 7d --csv usage.csv`,
+	Run: func(cmd *cobra.Command, args []string) {
+		sinceFlag, _ := cmd.Flags().GetString("since")
+		csvPath, _ := cmd.Flags().GetString("csv")
+
+		window, err := usage.ParseSince(sinceFlag)
+		if err != nil {
+			exitWithError(exitcode.Wrap(exitcode.Usage, err))
+		}
+
+		path, err := usage.DefaultPath()
+		if err != nil {
+			exitWithError(err)
+		}
+		records, err := usage.NewStore(path).Load(time.Now().Add(-window))
+		if err != nil {
+			exitWithError(err)
+		}
+		summaries := usage.Summarize(records)
+
+		if csvPath != "" {
+			out := os.Stdout
+			if csvPath != "-" {
+				f, err := os.Create(csvPath)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: failed to create %s: %v\n", csvPath, err)
+					exit(1)
+				}
+				defer f.Close()
+				out = f
+			}
+			if err := usage.WriteCSV(out, summaries); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: failed to write CSV: %v\n", err)
+				exit(1)
+			}
+			if csvPath != "-" {
+				fmt.Printf("Wrote %d rows to %s\n", len(summaries), csvPath)
+			}
+			return
+		}
+
+		if len(summaries) == 0 {
+			fmt.Printf("No usage recorded in the last %s\n", sinceFlag)
+			return
+		}
+
+		fmt.Printf("Usage for the last %s (%d requests)\n\n", sinceFlag, len(records))
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "MODEL\tKEY\tREQUESTS\tERRORS\tPROMPT TOK\tCOMPLETION TOK\tWALL TIME")
+		var total usage.Summary
+		for _, s := range summaries {
+			key := s.Key
+			if key == "" {
+				key = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%s\n", s.Model, key, s.Requests, s.Errors,
+				s.PromptTokens, s.CompletionTokens, s.WallTime.Round(time.Second))
+			total.Requests += s.Requests
+			total.Errors += s.Errors
+			total.PromptTokens += s.PromptTokens
+			total.CompletionTokens += s.CompletionTokens
+			total.WallTime += s.WallTime
+		}
+		fmt.Fprintf(w, "TOTAL\t\t%d\t%d\t%d\t%d\t%s\n", total.Requests, total.Errors,
+			total.PromptTokens, total.CompletionTokens, total.WallTime.Round(time.Second))
+		w.Flush()
+	},
+}
+
+func init() {
+	usageReportCmd.Flags().String("since", "7d", "Lookback window (e.g. 24h, 7d, 2w)")
+	usageReportCmd.Flags().String("csv", "", "Write the summary as CSV to this file ('-' for stdout)")
+	usageCmd.AddCommand(usageReportCmd)
+	rootCmd.AddCommand(usageCmd)
+}
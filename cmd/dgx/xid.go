@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/gpu"
+	"github.com/weatherman/dgx-manager/internal/health"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+var gpuErrorsCmd = &cobra.Command{
+	Use:   "errors",
+	Short: "Show NVIDIA Xid errors and driver faults from the kernel log",
+	Long: `Scan the kernel log (journal, or dmesg) for NVIDIA Xid errors and other NVRM
+driver messages, grouped by kind with what each one usually means, who is
+likely at fault (the application, the driver, or the hardware), and what to do.
+
+By default the current boot is scanned; --since looks back a fixed window
+instead. With --follow, new faults are printed as the kernel logs them, to catch
+errors while a workload runs; Ctrl-C stops and prints a summary.
+
+Reading the journal needs the user in the adm or systemd-journal group;
+otherwise dmesg is read, with sudo when it runs without a password.
+
+The exit status is non-zero when a fault that needs a GPU reset, a reboot, or
+a service call was found.
+
+Examples:
+  dgx gpu errors
+  dgx gpu errors --since 48h
+  dgx gpu errors --follow`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		since, _ := cmd.Flags().GetDuration("since")
+		follow, _ := cmd.Flags().GetBool("follow")
+		asJSON, _ := cmd.Flags().GetBool("json")
+		if follow && since > 0 {
+			exitWithError(exitcode.Wrap(exitcode.Usage, fmt.Errorf("--since cannot be combined with --follow")))
+		}
+
+		cfg := cfgManager.Get()
+		client, err := ssh.NewClient(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer client.Close()
+
+		var events []gpu.FaultEvent
+		if follow {
+			events = followFaults(client, cfg.Host, asJSON)
+		} else {
+			var start int64
+			if since > 0 {
+				// The window is measured on the DGX's clock
+				now, err := client.Execute("date +%s")
+				if err != nil {
+					exitWithError(err)
+				}
+				epoch, _ := strconv.ParseInt(strings.TrimSpace(now), 10, 64)
+				start = epoch - int64(since.Seconds())
+			}
+			if events, err = gpu.ReadFaults(client, start); err != nil {
+				exitWithError(err)
+			}
+		}
+
+		groups := gpu.GroupFaults(events)
+		if asJSON {
+			if groups == nil {
+				groups = []*gpu.FaultGroup{}
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(groups); err != nil {
+				exitWithError(err)
+			}
+		} else {
+			window := "in the current boot"
+			switch {
+			case follow:
+				window = "while watching"
+			case since > 0:
+				window = fmt.Sprintf("in the last %v", since)
+			}
+			printFaultGroups(groups, window)
+		}
+		for _, g := range groups {
+			if g.Severity == health.SeverityCritical {
+				exit(1)
+			}
+		}
+	},
+}
+
+// followFaults prints NVRM messages as they are logged until Ctrl-C or the connection
+// drops, and returns them. With asJSON it prints nothing but the closing summary.
+func followFaults(client *ssh.Client, host string, asJSON bool) []gpu.FaultEvent {
+	var mu sync.Mutex
+	var events []gpu.FaultEvent
+	seen := map[string]bool{}
+
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt)
+	defer signal.Stop(interrupted)
+	stopped := false
+	go func() {
+		if _, ok := <-interrupted; ok {
+			mu.Lock()
+			stopped = true
+			mu.Unlock()
+			client.Close()
+		}
+	}()
+
+	fmt.Fprintf(os.Stderr, "Watching the kernel log on %s for GPU faults (Ctrl-C stops)...\n", host)
+	err := gpu.FollowFaults(client, func(e gpu.FaultEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+		if asJSON {
+			return
+		}
+		name, _, advice, severity := e.Explain()
+		label := name
+		if e.Xid != 0 {
+			label = fmt.Sprintf("Xid %d: %s", e.Xid, name)
+		}
+		fmt.Printf("%s  [%s] %s\n", e.Time.Local().Format("15:04:05"), strings.ToUpper(severity.String()), label)
+		fmt.Printf("    %s\n", e.Message)
+		// Explain each kind once; repeats of a fault are common
+		if advice != "" && !seen[label] {
+			fmt.Printf("    -> %s\n", advice)
+		}
+		seen[label] = true
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	if err != nil && !stopped {
+		fmt.Fprintf(os.Stderr, "Warning: stopped watching: %v\n", err)
+	}
+	return events
+}
+
+func printFaultGroups(groups []*gpu.FaultGroup, window string) {
+	if len(groups) == 0 {
+		fmt.Printf("No GPU driver errors %s.\n", window)
+		return
+	}
+	total := 0
+	for _, g := range groups {
+		total += g.Count
+	}
+	fmt.Printf("%d GPU driver message(s) %s:\n", total, window)
+	for _, g := range groups {
+		label := g.Name
+		if g.Xid != 0 {
+			label = fmt.Sprintf("Xid %d: %s", g.Xid, g.Name)
+		}
+		fmt.Printf("\n[%s] %s  x%d\n", strings.ToUpper(g.Level), label, g.Count)
+		if !g.First.IsZero() {
+			if g.Count > 1 {
+				fmt.Printf("  Seen:     %s to %s\n", formatFaultTime(g.First), formatFaultTime(g.Last))
+			} else {
+				fmt.Printf("  Seen:     %s\n", formatFaultTime(g.Last))
+			}
+		}
+		if g.Cause != "" {
+			fmt.Printf("  Cause:    %s\n", g.Cause)
+		}
+		if len(g.Devices) > 0 {
+			fmt.Printf("  Device:   %s\n", strings.Join(g.Devices, ", "))
+		}
+		if len(g.Processes) > 0 {
+			fmt.Printf("  Process:  %s\n", strings.Join(g.Processes, ", "))
+		}
+		fmt.Printf("  Message:  %s\n", g.Example)
+		if g.Advice != "" {
+			fmt.Printf("  Action:   %s\n", g.Advice)
+		}
+	}
+}
+
+func formatFaultTime(t time.Time) string {
+	return t.Local().Format("Jan 02 15:04:05")
+}
+
+func init() {
+	gpuErrorsCmd.Flags().Duration("since", 0, "Scan this far back instead of the current boot (e.g. 24h)")
+	gpuErrorsCmd.Flags().BoolP("follow", "f", false, "Print new faults as they are logged until Ctrl-C")
+	gpuErrorsCmd.Flags().Bool("json", false, "Print the grouped faults as JSON")
+	gpuCmd.AddCommand(gpuErrorsCmd)
+}
@@ -0,0 +1,358 @@
+package alert
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// Metrics
+const (
+	MetricGPUTemp    = "gpu_temp"
+	MetricGPUUtil    = "gpu_util"
+	MetricMemory     = "memory"
+	MetricDisk       = "disk"
+	MetricDeployment = "deployment"
+)
+
+// Metrics lists the metrics a rule can watch
+var Metrics = []string{MetricGPUTemp, MetricGPUUtil, MetricMemory, MetricDisk, MetricDeployment}
+
+// DefaultInterval is how often the DGX is sampled unless alerts.interval is set
+const DefaultInterval = 30 * time.Second
+
+// minInterval keeps sampling from competing with the daemon's API requests
+const minInterval = 5 * time.Second
+
+// Event statuses
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+	StatusTest     = "test"
+)
+
+// DefaultRules apply when the config has no alert rules
+var DefaultRules = []types.AlertRule{
+	{Name: "gpu-hot", Metric: MetricGPUTemp, Above: 85, For: 5 * time.Minute},
+	{Name: "memory-full", Metric: MetricMemory, Above: 95, For: 5 * time.Minute},
+	{Name: "disk-full", Metric: MetricDisk, Above: 90},
+	{Name: "deployment-down", Metric: MetricDeployment, For: 2 * time.Minute},
+}
+
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// Rules returns the configured rules, or DefaultRules when there are none
+func Rules(cfg *types.AlertsConfig) []types.AlertRule {
+	if cfg == nil || len(cfg.Rules) == 0 {
+		return DefaultRules
+	}
+	return cfg.Rules
+}
+
+// Hooks returns the configured hooks
+func Hooks(cfg *types.AlertsConfig) []types.AlertHook {
+	if cfg == nil {
+		return nil
+	}
+	return cfg.Hooks
+}
+
+// Interval returns how often the DGX is sampled
+func Interval(cfg *types.AlertsConfig) time.Duration {
+	if cfg == nil || cfg.Interval == 0 {
+		return DefaultInterval
+	}
+	return cfg.Interval
+}
+
+// Validate reports the first problem in the alerts config
+func Validate(cfg *types.AlertsConfig) error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.Interval != 0 && cfg.Interval < minInterval {
+		return fmt.Errorf("alerts.interval %v is below the minimum of %v", cfg.Interval, minInterval)
+	}
+	seen := map[string]bool{}
+	for _, r := range cfg.Rules {
+		if !namePattern.MatchString(r.Name) {
+			return fmt.Errorf("alert rule %q: use lowercase letters, digits, and dashes in names", r.Name)
+		}
+		if seen[r.Name] {
+			return fmt.Errorf("alert rule %q is defined twice", r.Name)
+		}
+		seen[r.Name] = true
+		if err := validateRule(r); err != nil {
+			return fmt.Errorf("alert rule %q: %w", r.Name, err)
+		}
+	}
+	for i, h := range cfg.Hooks {
+		if (h.URL == "") == (h.Command == "") {
+			return fmt.Errorf("alert hook %d: set exactly one of url and command", i+1)
+		}
+		if h.URL != "" && !strings.HasPrefix(h.URL, "http://") && !strings.HasPrefix(h.URL, "https://") {
+			return fmt.Errorf("alert hook %d: url %q is not an http(s) URL", i+1, h.URL)
+		}
+	}
+	return nil
+}
+
+func validateRule(r types.AlertRule) error {
+	switch r.Metric {
+	case MetricGPUTemp, MetricGPUUtil, MetricMemory, MetricDisk:
+		if r.Above < 0 {
+			return fmt.Errorf("above must not be negative")
+		}
+		if r.Metric != MetricGPUTemp && r.Above >= 100 {
+			return fmt.Errorf("above %g: %s is a percentage", r.Above, r.Metric)
+		}
+	case MetricDeployment:
+		if r.Above != 0 {
+			return fmt.Errorf("above does not apply to the deployment metric")
+		}
+	default:
+		return fmt.Errorf("unknown metric %q (use %s)", r.Metric, strings.Join(Metrics, ", "))
+	}
+	if r.For < 0 {
+		return fmt.Errorf("for must not be negative")
+	}
+	if r.Path != "" && (r.Metric != MetricDisk || !path.IsAbs(r.Path)) {
+		return fmt.Errorf("path %q: only disk rules take a path, and it must be absolute", r.Path)
+	}
+	if r.Deployment != "" && r.Metric != MetricDeployment {
+		return fmt.Errorf("deployment is only used by the deployment metric")
+	}
+	return nil
+}
+
+// DiskPath returns the filesystem a disk rule watches
+func DiskPath(r types.AlertRule) string {
+	if r.Path == "" {
+		return "/"
+	}
+	return r.Path
+}
+
+// Threshold describes when a rule fires, e.g. "> 85°C for 5m0s"
+func Threshold(r types.AlertRule) string {
+	var s string
+	switch r.Metric {
+	case MetricDeployment:
+		s = "not active"
+	case MetricGPUTemp:
+		s = fmt.Sprintf("> %g°C", r.Above)
+	default:
+		s = fmt.Sprintf("> %g%%", r.Above)
+	}
+	if r.For > 0 {
+		s += " for " + r.For.String()
+	}
+	return s
+}
+
+// label names what a rule watches in event summaries
+func label(r types.AlertRule) string {
+	switch r.Metric {
+	case MetricGPUTemp:
+		return "GPU temperature"
+	case MetricGPUUtil:
+		return "GPU utilization"
+	case MetricMemory:
+		return "memory use"
+	case MetricDisk:
+		return "disk use of " + DiskPath(r)
+	}
+	if r.Deployment != "" {
+		return "deployment " + r.Deployment
+	}
+	return "deployments"
+}
+
+// Reading is a rule's current value. Over reports whether it crosses the threshold; Detail
+// renders it for people, e.g. "87°C" or "chat, embed not active".
+type Reading struct {
+	Value  float64
+	Detail string
+	Over   bool
+}
+
+// Measure reads the rule's metric from s. ok is false when the sample has no value for it,
+// e.g. when nvidia-smi failed.
+func Measure(r types.AlertRule, s Sample) (Reading, bool) {
+	var value float64
+	switch r.Metric {
+	case MetricGPUTemp:
+		value = s.GPUTemp
+	case MetricGPUUtil:
+		value = s.GPUUtil
+	case MetricMemory:
+		value = s.Memory
+	case MetricDisk:
+		v, ok := s.Disk[DiskPath(r)]
+		if !ok {
+			return Reading{}, false
+		}
+		value = v
+	case MetricDeployment:
+		if s.Deployments == nil {
+			return Reading{}, false
+		}
+		var down []string
+		if r.Deployment != "" {
+			if !s.Deployments[r.Deployment] {
+				down = append(down, r.Deployment)
+			}
+		} else {
+			for name, active := range s.Deployments {
+				if !active {
+					down = append(down, name)
+				}
+			}
+			sort.Strings(down)
+		}
+		if len(down) == 0 {
+			return Reading{Detail: "active"}, true
+		}
+		return Reading{Value: float64(len(down)), Detail: strings.Join(down, ", ") + " not active", Over: true}, true
+	default:
+		return Reading{}, false
+	}
+	if value < 0 {
+		return Reading{}, false
+	}
+	detail := fmt.Sprintf("%.0f%%", value)
+	if r.Metric == MetricGPUTemp {
+		detail = fmt.Sprintf("%.0f°C", value)
+	}
+	return Reading{Value: value, Detail: detail, Over: value > r.Above}, true
+}
+
+// Event is sent to hooks when a rule fires or resolves. Text is what Slack-compatible
+// webhooks display.
+type Event struct {
+	Rule      string    `json:"rule"`
+	Metric    string    `json:"metric"`
+	Status    string    `json:"status"`
+	Host      string    `json:"host"`
+	Value     float64   `json:"value"`
+	Threshold string    `json:"threshold"`
+	Text      string    `json:"text"`
+	Time      time.Time `json:"time"`
+}
+
+// NewEvent builds the event for rule r with the given status and reading
+func NewEvent(host string, r types.AlertRule, status string, reading Reading, now time.Time) Event {
+	var text string
+	switch status {
+	case StatusFiring:
+		text = fmt.Sprintf("[%s] %s: %s is %s (%s)", host, r.Name, label(r), reading.Detail, Threshold(r))
+	case StatusResolved:
+		text = fmt.Sprintf("[%s] %s resolved: %s is %s", host, r.Name, label(r), reading.Detail)
+	default:
+		text = fmt.Sprintf("[%s] %s: test notification from dgx alerts (%s %s)", host, r.Name, label(r), Threshold(r))
+	}
+	return Event{
+		Rule:      r.Name,
+		Metric:    r.Metric,
+		Status:    status,
+		Host:      host,
+		Value:     reading.Value,
+		Threshold: Threshold(r),
+		Text:      text,
+		Time:      now,
+	}
+}
+
+// State is a rule's evaluation state. Since is when its reading first crossed the
+// threshold, zero while it is below.
+type State struct {
+	Rule    types.AlertRule
+	Reading Reading
+	Known   bool
+	Since   time.Time
+	Firing  bool
+}
+
+// Evaluator tracks how long each rule has been over its threshold across samples
+type Evaluator struct {
+	host string
+
+	mu     sync.Mutex
+	states []State
+}
+
+// NewEvaluator creates an evaluator for rules on host
+func NewEvaluator(host string, rules []types.AlertRule) *Evaluator {
+	e := &Evaluator{host: host, states: make([]State, len(rules))}
+	for i, r := range rules {
+		e.states[i].Rule = r
+	}
+	return e
+}
+
+// Observe evaluates every rule against s and returns the rules that started firing or
+// resolved. A rule without a reading in s keeps its state.
+func (e *Evaluator) Observe(s Sample) []Event {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var events []Event
+	for i := range e.states {
+		st := &e.states[i]
+		reading, ok := Measure(st.Rule, s)
+		if !ok {
+			continue
+		}
+		st.Reading, st.Known = reading, true
+		switch {
+		case reading.Over && st.Since.IsZero():
+			st.Since = s.Time
+		case !reading.Over:
+			st.Since = time.Time{}
+		}
+		if reading.Over && !st.Firing && s.Time.Sub(st.Since) >= st.Rule.For {
+			st.Firing = true
+			events = append(events, NewEvent(e.host, st.Rule, StatusFiring, reading, s.Time))
+		} else if !reading.Over && st.Firing {
+			st.Firing = false
+			events = append(events, NewEvent(e.host, st.Rule, StatusResolved, reading, s.Time))
+		}
+	}
+	return events
+}
+
+// States returns a copy of every rule's state
+func (e *Evaluator) States() []State {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]State(nil), e.states...)
+}
+
+// DiskPaths returns the filesystems the rules watch
+func DiskPaths(rules []types.AlertRule) []string {
+	var paths []string
+	seen := map[string]bool{}
+	for _, r := range rules {
+		if r.Metric == MetricDisk && !seen[DiskPath(r)] {
+			seen[DiskPath(r)] = true
+			paths = append(paths, DiskPath(r))
+		}
+	}
+	return paths
+}
+
+// WatchesDeployments reports whether any rule needs the autostart units' state
+func WatchesDeployments(rules []types.AlertRule) bool {
+	for _, r := range rules {
+		if r.Metric == MetricDeployment {
+			return true
+		}
+	}
+	return false
+}
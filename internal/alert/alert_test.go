@@ -0,0 +1,125 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+func TestValidate(t *testing.T) {
+	if err := Validate(&types.AlertsConfig{Rules: DefaultRules}); err != nil {
+		t.Fatalf("default rules: %v", err)
+	}
+	bad := []types.AlertsConfig{
+		{Interval: time.Second},
+		{Rules: []types.AlertRule{{Name: "Hot", Metric: MetricGPUTemp}}},
+		{Rules: []types.AlertRule{{Name: "a", Metric: "fan"}}},
+		{Rules: []types.AlertRule{{Name: "a", Metric: MetricDisk, Above: 150}}},
+		{Rules: []types.AlertRule{{Name: "a", Metric: MetricDisk, Path: "data"}}},
+		{Rules: []types.AlertRule{{Name: "a", Metric: MetricGPUTemp, Path: "/"}}},
+		{Rules: []types.AlertRule{{Name: "a", Metric: MetricMemory}, {Name: "a", Metric: MetricDisk}}},
+		{Hooks: []types.AlertHook{{URL: "https://x", Command: "true"}}},
+		{Hooks: []types.AlertHook{{URL: "ftp://x"}}},
+	}
+	for _, cfg := range bad {
+		if err := Validate(&cfg); err == nil {
+			t.Fatalf("Validate(%+v): expected an error", cfg)
+		}
+	}
+}
+
+func TestParseSample(t *testing.T) {
+	s := parseSample("gpu 71, 98\ngpu 64, [N/A]\nmemory 42.5\ndisk 91 /\ndisk 12 /my data\nnoise\n")
+	if s.GPUTemp != 71 || s.GPUUtil != 98 || s.Memory != 42.5 {
+		t.Fatalf("sample = %+v", s)
+	}
+	if s.Disk["/"] != 91 || s.Disk["/my data"] != 12 {
+		t.Fatalf("disk = %v", s.Disk)
+	}
+	if empty := parseSample(""); empty.GPUTemp != -1 || empty.Memory != -1 {
+		t.Fatalf("empty sample = %+v", empty)
+	}
+}
+
+func TestEvaluator(t *testing.T) {
+	rules := []types.AlertRule{
+		{Name: "gpu-hot", Metric: MetricGPUTemp, Above: 85, For: 5 * time.Minute},
+		{Name: "chat-down", Metric: MetricDeployment, Deployment: "chat"},
+	}
+	e := NewEvaluator("spark", rules)
+	start := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int, temp float64, deployments map[string]bool) []Event {
+		return e.Observe(Sample{Time: start.Add(time.Duration(minutes) * time.Minute), GPUTemp: temp, Deployments: deployments})
+	}
+
+	if events := at(0, 90, map[string]bool{"chat": true}); len(events) != 0 {
+		t.Fatalf("fired before the duration: %+v", events)
+	}
+	if events := at(3, 88, nil); len(events) != 0 {
+		t.Fatalf("fired before the duration: %+v", events)
+	}
+	events := at(5, 87, map[string]bool{})
+	if len(events) != 2 || events[0].Rule != "gpu-hot" || events[0].Status != StatusFiring || events[1].Rule != "chat-down" {
+		t.Fatalf("events = %+v", events)
+	}
+	if !strings.Contains(events[0].Text, "87°C") || !strings.Contains(events[1].Text, "chat not active") {
+		t.Fatalf("texts = %q, %q", events[0].Text, events[1].Text)
+	}
+	if events := at(6, 89, map[string]bool{}); len(events) != 0 {
+		t.Fatalf("fired again: %+v", events)
+	}
+	// An unknown reading keeps the firing state
+	if events := at(7, -1, map[string]bool{"chat": true}); len(events) != 1 || events[0].Rule != "chat-down" || events[0].Status != StatusResolved {
+		t.Fatalf("events = %+v", events)
+	}
+	if events := at(8, 70, nil); len(events) != 1 || events[0].Status != StatusResolved {
+		t.Fatalf("events = %+v", events)
+	}
+	// Dropping below the threshold restarts the duration
+	at(9, 90, nil)
+	at(10, 80, nil)
+	if events := at(14, 90, nil); len(events) != 0 {
+		t.Fatalf("fired before the duration: %+v", events)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	var received Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer srv.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer failing.Close()
+
+	out := filepath.Join(t.TempDir(), "hook")
+	hooks := []types.AlertHook{
+		{URL: srv.URL + "/secret"},
+		{URL: failing.URL},
+		{Command: `printf '%s %s' "$DGX_ALERT_RULE" "$DGX_ALERT_STATUS" > "` + out + `"`},
+	}
+	event := NewEvent("spark", DefaultRules[0], StatusTest, Reading{}, time.Now())
+	errs := Notify(context.Background(), hooks, event)
+	if errs[0] != nil || errs[1] == nil || errs[2] != nil {
+		t.Fatalf("errors = %v", errs)
+	}
+	if received.Rule != "gpu-hot" || received.Text == "" {
+		t.Fatalf("webhook received %+v", received)
+	}
+	if data, _ := os.ReadFile(out); string(data) != "gpu-hot test" {
+		t.Fatalf("command hook wrote %q", data)
+	}
+	if d := Describe(hooks[0]); strings.Contains(d, "secret") {
+		t.Fatalf("Describe leaks the webhook path: %s", d)
+	}
+}
@@ -0,0 +1,124 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/poll"
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// hookTimeout bounds each hook call so a hung target cannot stall the next evaluation
+const hookTimeout = 30 * time.Second
+
+// Describe names a hook for logs and `dgx alerts test`. URLs are cut to their host, since
+// webhook paths often embed a secret.
+func Describe(h types.AlertHook) string {
+	if h.URL != "" {
+		if u, err := url.Parse(h.URL); err == nil && u.Host != "" {
+			return u.Scheme + "://" + u.Host + "/..."
+		}
+		return "webhook"
+	}
+	return "command: " + h.Command
+}
+
+// Notify sends event to every hook. The returned errors line up with hooks; a nil entry
+// is a hook that succeeded.
+func Notify(ctx context.Context, hooks []types.AlertHook, event Event) []error {
+	body, err := json.Marshal(event)
+	errs := make([]error, len(hooks))
+	for i, h := range hooks {
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		hookCtx, cancel := context.WithTimeout(ctx, hookTimeout)
+		if h.URL != "" {
+			errs[i] = post(hookCtx, h.URL, body)
+		} else {
+			errs[i] = run(hookCtx, h.Command, event, body)
+		}
+		cancel()
+	}
+	return errs
+}
+
+func post(ctx context.Context, target string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// Drop the URL from the error, as Describe does
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+func run(ctx context.Context, command string, event Event, body []byte) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"DGX_ALERT_RULE="+event.Rule,
+		"DGX_ALERT_METRIC="+event.Metric,
+		"DGX_ALERT_STATUS="+event.Status,
+		"DGX_ALERT_HOST="+event.Host,
+		"DGX_ALERT_VALUE="+strconv.FormatFloat(event.Value, 'f', -1, 64),
+		"DGX_ALERT_TEXT="+event.Text,
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(output)); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// Watch samples the DGX as loop paces it until ctx is cancelled, passing each event to the
+// hooks and logging it. Failed samples are logged and the rules keep their state.
+func (e *Evaluator) Watch(ctx context.Context, loop *poll.Loop, sample func(context.Context) (Sample, error), hooks []types.AlertHook, logger *log.Logger) {
+	for {
+		s, err := sample(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			logger.Printf("alerts: %v", err)
+		} else {
+			for _, event := range e.Observe(s) {
+				logger.Printf("alert %s: %s", event.Status, event.Text)
+				for i, err := range Notify(ctx, hooks, event) {
+					if err != nil {
+						logger.Printf("alert hook %s failed: %v", Describe(hooks[i]), err)
+					}
+				}
+			}
+		}
+		if !loop.Wait(ctx) {
+			return
+		}
+	}
+}
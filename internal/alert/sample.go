@@ -0,0 +1,95 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/deploy"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+// Executor runs a remote command; *ssh.Client implements it
+type Executor interface {
+	ExecuteContext(ctx context.Context, command string) (string, error)
+}
+
+// Sample is one reading of the DGX. GPUTemp, GPUUtil, and Memory are -1 when not reported
+// (the hottest and busiest GPU count); Disk is the percentage used per watched path.
+// Deployments maps autostart units to whether they are active, and is nil when unknown.
+type Sample struct {
+	Time        time.Time
+	GPUTemp     float64
+	GPUUtil     float64
+	Memory      float64
+	Disk        map[string]float64
+	Deployments map[string]bool
+}
+
+// sampleCommand prints "gpu <temp>, <util>" per GPU, "memory <percent>", and
+// "disk <percent> <path>" for each of paths
+func sampleCommand(paths []string) string {
+	var b strings.Builder
+	b.WriteString("nvidia-smi --query-gpu=temperature.gpu,utilization.gpu --format=csv,noheader,nounits 2>/dev/null | sed 's/^/gpu /'\n")
+	b.WriteString(`awk '/^MemTotal:/ { t = $2 } /^MemAvailable:/ { a = $2 } END { if (t) printf "memory %.1f\n", (t - a) * 100 / t }' /proc/meminfo` + "\n")
+	for _, p := range paths {
+		q := ssh.ShellQuote(p)
+		fmt.Fprintf(&b, "df -P %s 2>/dev/null | awk -v p=%s 'NR == 2 { sub(\"%%\", \"\", $5); print \"disk\", $5, p }'\n", q, q)
+	}
+	b.WriteString("true")
+	return b.String()
+}
+
+// parseSample parses sampleCommand output
+func parseSample(output string) Sample {
+	s := Sample{GPUTemp: -1, GPUUtil: -1, Memory: -1, Disk: map[string]float64{}}
+	number := func(v string) (float64, bool) {
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	for _, line := range strings.Split(output, "\n") {
+		kind, rest, _ := strings.Cut(strings.TrimSpace(line), " ")
+		switch kind {
+		case "gpu":
+			temp, util, _ := strings.Cut(rest, ",")
+			if v, ok := number(temp); ok && v > s.GPUTemp {
+				s.GPUTemp = v
+			}
+			if v, ok := number(util); ok && v > s.GPUUtil {
+				s.GPUUtil = v
+			}
+		case "memory":
+			if v, ok := number(rest); ok {
+				s.Memory = v
+			}
+		case "disk":
+			percent, p, _ := strings.Cut(rest, " ")
+			if v, ok := number(percent); ok && p != "" {
+				s.Disk[p] = v
+			}
+		}
+	}
+	return s
+}
+
+// Collect samples the metrics the rules watch, except deployments (see DeploymentStates)
+func Collect(ctx context.Context, exec Executor, paths []string) (Sample, error) {
+	output, err := exec.ExecuteContext(ctx, sampleCommand(paths))
+	if err != nil {
+		return Sample{}, fmt.Errorf("failed to sample the DGX: %w", err)
+	}
+	s := parseSample(output)
+	s.Time = time.Now()
+	return s, nil
+}
+
+// DeploymentStates maps autostart entries to whether their unit is active
+func DeploymentStates(entries []deploy.Autostart) map[string]bool {
+	states := make(map[string]bool, len(entries))
+	for _, a := range entries {
+		states[a.Name] = a.Active == "active"
+	}
+	return states
+}
@@ -0,0 +1,225 @@
+package artifacts
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+// Job is a training or quantization output location on the DGX
+type Job struct {
+	Name        string
+	Dir         string
+	Description string
+}
+
+// Jobs are the output directories written by dgx playbooks
+var Jobs = []Job{
+	{Name: "nvfp4", Dir: "~/nvfp4_output", Description: "NVFP4 quantization (dgx run nvfp4 quantize)"},
+}
+
+// Resolve returns the job with the given name, or treats job as a directory on the DGX when
+// it looks like a path
+func Resolve(job string) (Job, error) {
+	for _, j := range Jobs {
+		if j.Name == job {
+			return j, nil
+		}
+	}
+	if strings.HasPrefix(job, "~") || strings.Contains(job, "/") {
+		return Job{Name: path.Base(strings.TrimSuffix(job, "/")), Dir: strings.TrimSuffix(job, "/")}, nil
+	}
+	names := make([]string, len(Jobs))
+	for i, j := range Jobs {
+		names[i] = j.Name
+	}
+	return Job{}, fmt.Errorf("unknown job %q (known: %s; or give a directory on the DGX such as ~/outputs/run1)", job, strings.Join(names, ", "))
+}
+
+// Checkpoint is a checkpoint-<step> directory, the layout Hugging Face Trainer based
+// fine-tuning (LLaMA-Factory, Unsloth, TRL) writes
+type Checkpoint struct {
+	Name     string
+	Step     int
+	Modified time.Time
+	SizeKB   int64
+}
+
+// Listing describes an output directory
+type Listing struct {
+	SizeKB      int64
+	Checkpoints []Checkpoint
+	// Best is the checkpoint named by best_model_checkpoint in trainer_state.json, if any
+	Best string
+}
+
+// ListCommand returns a script describing dir: its total size, each checkpoint directory,
+// and the best_model_checkpoint recorded by the trainer. Each line is tagged with @@.
+func ListCommand(dir string) string {
+	return fmt.Sprintf(`d=%s
+[ -d "$d" ] || { echo "$d does not exist on the DGX" >&2; exit 1; }
+echo "@@size $(du -sk "$d" 2>/dev/null | cut -f1)"
+for c in "$d"/checkpoint-*/; do
+  [ -d "$c" ] || continue
+  echo "@@ckpt $(basename "$c") $(stat -c %%Y "$c") $(du -sk "$c" 2>/dev/null | cut -f1)"
+done
+best() { grep -o '"best_model_checkpoint": *"[^"]*"' "$1" 2>/dev/null | sed 's/.*: *"//; s/"$//'; }
+[ -f "$d/trainer_state.json" ] && echo "@@best . $(best "$d/trainer_state.json")"
+for f in "$d"/checkpoint-*/trainer_state.json; do
+  [ -f "$f" ] && echo "@@best $(basename "$(dirname "$f")") $(best "$f")"
+done
+true`, ssh.QuoteRemotePath(dir))
+}
+
+// ParseListing parses ListCommand output. The best checkpoint comes from the top-level
+// trainer_state.json when training finished, otherwise from the newest checkpoint's copy.
+func ParseListing(output string) Listing {
+	var listing Listing
+	bestStep := -1
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "@@size":
+			listing.SizeKB, _ = strconv.ParseInt(fields[1], 10, 64)
+		case "@@ckpt":
+			if len(fields) < 4 {
+				continue
+			}
+			step, ok := checkpointStep(fields[1])
+			if !ok {
+				continue
+			}
+			mtime, _ := strconv.ParseInt(fields[2], 10, 64)
+			size, _ := strconv.ParseInt(fields[3], 10, 64)
+			listing.Checkpoints = append(listing.Checkpoints, Checkpoint{
+				Name: fields[1], Step: step, Modified: time.Unix(mtime, 0), SizeKB: size,
+			})
+		case "@@best":
+			if len(fields) < 3 {
+				continue
+			}
+			step := 1 << 30
+			if fields[1] != "." {
+				s, ok := checkpointStep(fields[1])
+				if !ok {
+					continue
+				}
+				step = s
+			}
+			if step > bestStep {
+				bestStep = step
+				listing.Best = path.Base(fields[2])
+			}
+		}
+	}
+	sort.Slice(listing.Checkpoints, func(i, j int) bool {
+		return listing.Checkpoints[i].Step < listing.Checkpoints[j].Step
+	})
+	return listing
+}
+
+// Latest returns the checkpoint with the highest step
+func (l Listing) Latest() (Checkpoint, bool) {
+	if len(l.Checkpoints) == 0 {
+		return Checkpoint{}, false
+	}
+	return l.Checkpoints[len(l.Checkpoints)-1], true
+}
+
+// BestCheckpoint returns the checkpoint the trainer recorded as best, when it still exists
+func (l Listing) BestCheckpoint() (Checkpoint, bool) {
+	for _, c := range l.Checkpoints {
+		if c.Name == l.Best {
+			return c, true
+		}
+	}
+	return Checkpoint{}, false
+}
+
+// Print writes the checkpoints in l as a table, marking the latest and best ones
+func (l Listing) Print(w io.Writer) {
+	latest, _ := l.Latest()
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECKPOINT\tSTEP\tSIZE\tMODIFIED\t")
+	for _, c := range l.Checkpoints {
+		var marks []string
+		if c.Name == latest.Name {
+			marks = append(marks, "latest")
+		}
+		if c.Name == l.Best {
+			marks = append(marks, "best")
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\n", c.Name, c.Step, FormatBytes(c.SizeKB*1024),
+			c.Modified.Format("2006-01-02 15:04"), strings.Join(marks, ","))
+	}
+	tw.Flush()
+}
+
+// KeepOnlyFilters returns rsync filter arguments that transfer everything except the
+// checkpoint directories other than keep
+func KeepOnlyFilters(keep string) []string {
+	return []string{"--include=/" + keep + "/", "--exclude=/checkpoint-*/"}
+}
+
+func checkpointStep(name string) (int, bool) {
+	rest, ok := strings.CutPrefix(name, "checkpoint-")
+	if !ok {
+		return 0, false
+	}
+	step, err := strconv.Atoi(rest)
+	return step, err == nil
+}
+
+var byteSizePattern = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)\s*([KMGT]?I?B|[KMGT])?$`)
+
+// ParseBytes parses sizes such as "256.35 MiB", "4.7GB", "20G", or "100", in any case.
+// Decimal units (kB, MB, GB, TB) are powers of 1000 and binary units (KiB, MiB, GiB, TiB)
+// powers of 1024; a bare letter (K, M, G, T) counts as binary, as in df and docker. A
+// number without a unit is bytes.
+func ParseBytes(s string) (int64, error) {
+	match := byteSizePattern.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(s)))
+	if match == nil {
+		return 0, fmt.Errorf("invalid size %q (want a number with a unit such as 20GB or 512MiB)", s)
+	}
+	n, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	unit := match[2]
+	if unit == "" || unit == "B" {
+		return int64(n), nil
+	}
+	base := 1000.0
+	if len(unit) == 1 || strings.Contains(unit, "I") {
+		base = 1024
+	}
+	for range strings.IndexByte("KMGT", unit[0]) + 1 {
+		n *= base
+	}
+	return int64(n), nil
+}
+
+// FormatBytes renders n bytes with binary units
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
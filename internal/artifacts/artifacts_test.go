@@ -0,0 +1,71 @@
+package artifacts
+
+import "testing"
+
+func TestParseListing(t *testing.T) {
+	output := `@@size 9000
+@@ckpt checkpoint-1000 1700000200 3000
+@@ckpt checkpoint-500 1700000100 3000
+@@ckpt checkpoint-1500 1700000300 3000
+@@best checkpoint-500 outputs/checkpoint-500
+@@best checkpoint-1500 outputs/checkpoint-1000
+@@best checkpoint-1000 outputs/checkpoint-500
+`
+	listing := ParseListing(output)
+	if listing.SizeKB != 9000 || len(listing.Checkpoints) != 3 {
+		t.Fatalf("listing = %+v", listing)
+	}
+	if latest, ok := listing.Latest(); !ok || latest.Name != "checkpoint-1500" {
+		t.Fatalf("Latest = %+v, %v", latest, ok)
+	}
+	if best, ok := listing.BestCheckpoint(); !ok || best.Name != "checkpoint-1000" {
+		t.Fatalf("BestCheckpoint = %+v, %v", best, ok)
+	}
+
+	// The top-level trainer_state.json wins once training has finished
+	listing = ParseListing(output + "@@best . /data/outputs/checkpoint-1500\n")
+	if best, ok := listing.BestCheckpoint(); !ok || best.Name != "checkpoint-1500" {
+		t.Fatalf("BestCheckpoint with final state = %+v, %v", best, ok)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	if job, err := Resolve("nvfp4"); err != nil || job.Dir != "~/nvfp4_output" {
+		t.Fatalf("Resolve(nvfp4) = %+v, %v", job, err)
+	}
+	if job, err := Resolve("~/outputs/run1/"); err != nil || job.Name != "run1" || job.Dir != "~/outputs/run1" {
+		t.Fatalf("Resolve(path) = %+v, %v", job, err)
+	}
+	if _, err := Resolve("bogus"); err == nil {
+		t.Fatalf("Resolve(bogus) should fail")
+	}
+}
+
+func TestParseBytes(t *testing.T) {
+	mib := 256.35 * (1 << 20)
+	cases := map[string]int64{
+		"20GB":       20e9,
+		"20 GiB":     20 << 30,
+		"20G":        20 << 30,
+		"20g":        20 << 30,
+		"512MiB":     512 << 20,
+		"512mib":     512 << 20,
+		"1.5 kB":     1500,
+		"1.5KB":      1500,
+		"2K":         2 << 10,
+		"1TB":        1e12,
+		"100":        100,
+		"0B":         0,
+		"256.35 MiB": int64(mib),
+	}
+	for in, want := range cases {
+		if got, err := ParseBytes(in); err != nil || got != want {
+			t.Fatalf("ParseBytes(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "big", "GB", "-4GB", "20 PB", "1,5G"} {
+		if _, err := ParseBytes(bad); err == nil {
+			t.Fatalf("ParseBytes(%q) accepted", bad)
+		}
+	}
+}
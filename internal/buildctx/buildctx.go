@@ -0,0 +1,242 @@
+// Package buildctx sends local docker build contexts to the DGX for dgx build: it collects
+// the context as docker does, honoring .dockerignore, streams it as a gzipped tar, and runs
+// docker build on the DGX reading the context from stdin.
+package buildctx
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+// outsideDockerfile is where a Dockerfile from outside the context goes in the tar
+const outsideDockerfile = ".dockerfile.dgx"
+
+// Context is a build context ready to send
+type Context struct {
+	Dir        string
+	Dockerfile string // path of the Dockerfile inside the context
+	Files      int
+	Size       int64 // bytes of file content, before compression
+
+	entries    []entry
+	dockerfile string // local path of a Dockerfile from outside the context, if any
+}
+
+type entry struct {
+	rel  string // slash-separated path in the context
+	path string // local path
+	info fs.FileInfo
+}
+
+// Collect walks dir and returns what docker would send of it for a build with dockerfile,
+// a local path that defaults to dir/Dockerfile. The Dockerfile and .dockerignore are
+// always sent, as the docker CLI does.
+func Collect(dir, dockerfile string) (*Context, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	if dockerfile == "" {
+		dockerfile = filepath.Join(dir, "Dockerfile")
+	}
+	if _, err := os.Stat(dockerfile); err != nil {
+		return nil, fmt.Errorf("no Dockerfile: %w", err)
+	}
+	ig, err := LoadIgnore(dir, dockerfile)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Context{Dir: dir}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	absDockerfile, err := filepath.Abs(dockerfile)
+	if err != nil {
+		return nil, err
+	}
+	if rel, err := filepath.Rel(absDir, absDockerfile); err == nil && !strings.HasPrefix(rel, "..") {
+		c.Dockerfile = filepath.ToSlash(rel)
+	} else {
+		c.Dockerfile, c.dockerfile = outsideDockerfile, dockerfile
+	}
+
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if ig.Excluded(rel) && rel != c.Dockerfile && rel != ".dockerignore" {
+			if d.IsDir() && ig.Prunable() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		c.add(rel, p, info)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if c.dockerfile != "" {
+		info, err := os.Stat(c.dockerfile)
+		if err != nil {
+			return nil, err
+		}
+		c.add(outsideDockerfile, c.dockerfile, info)
+	}
+	sort.Slice(c.entries, func(i, j int) bool { return c.entries[i].rel < c.entries[j].rel })
+	return c, nil
+}
+
+func (c *Context) add(rel, path string, info fs.FileInfo) {
+	c.entries = append(c.entries, entry{rel: rel, path: path, info: info})
+	if info.Mode().IsRegular() {
+		c.Files++
+		c.Size += info.Size()
+	}
+}
+
+// Paths returns the paths in the context, sorted
+func (c *Context) Paths() []string {
+	paths := make([]string, len(c.entries))
+	for i, e := range c.entries {
+		paths[i] = e.rel
+	}
+	return paths
+}
+
+// WriteTo writes the context to w as a gzipped tar, which docker build reads from stdin,
+// and returns the compressed size. Files are owned by root, as the docker CLI sends them.
+func (c *Context) WriteTo(w io.Writer) (int64, error) {
+	counter := &countingWriter{w: w}
+	zw, _ := gzip.NewWriterLevel(counter, gzip.BestSpeed)
+	tw := tar.NewWriter(zw)
+	for _, e := range c.entries {
+		if err := writeEntry(tw, e); err != nil {
+			return counter.n, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return counter.n, err
+	}
+	err := zw.Close()
+	return counter.n, err
+}
+
+func writeEntry(tw *tar.Writer, e entry) error {
+	link := ""
+	if e.info.Mode()&fs.ModeSymlink != 0 {
+		target, err := os.Readlink(e.path)
+		if err != nil {
+			return err
+		}
+		link = target
+	} else if !e.info.Mode().IsRegular() && !e.info.IsDir() {
+		return nil // sockets, devices, and pipes have no place in an image
+	}
+	hdr, err := tar.FileInfoHeader(e.info, link)
+	if err != nil {
+		return err
+	}
+	hdr.Name = e.rel
+	if e.info.IsDir() {
+		hdr.Name += "/"
+	}
+	hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+	hdr.Format = tar.FormatPAX
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("%s: %w", e.rel, err)
+	}
+	if !e.info.Mode().IsRegular() {
+		return nil
+	}
+	f, err := os.Open(e.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.CopyN(tw, f, hdr.Size); err != nil {
+		return fmt.Errorf("%s: %w", e.rel, err)
+	}
+	return nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Options are the docker build flags passed through to the DGX
+type Options struct {
+	Tags      []string
+	BuildArgs []string // NAME=value
+	Target    string
+	Platform  string
+	Progress  string
+	NoCache   bool
+	Pull      bool
+	Push      bool
+}
+
+// Command returns the remote command that builds the context read from stdin, whose
+// Dockerfile is at dockerfile inside it
+func Command(dockerfile string, o Options) string {
+	var b strings.Builder
+	b.WriteString("docker build")
+	flag := func(name, value string) {
+		if value != "" {
+			b.WriteString(" --" + name + " " + ssh.ShellQuote(value))
+		}
+	}
+	for _, t := range o.Tags {
+		flag("tag", t)
+	}
+	for _, a := range o.BuildArgs {
+		flag("build-arg", a)
+	}
+	flag("target", o.Target)
+	flag("platform", o.Platform)
+	flag("progress", o.Progress)
+	if o.NoCache {
+		b.WriteString(" --no-cache")
+	}
+	if o.Pull {
+		b.WriteString(" --pull")
+	}
+	flag("file", dockerfile)
+	b.WriteString(" -")
+	if o.Push {
+		for _, t := range o.Tags {
+			b.WriteString(" && docker push " + ssh.ShellQuote(t))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,122 @@
+package buildctx
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIgnore(t *testing.T) {
+	ig, err := ParseIgnore(strings.NewReader(`
+# build outputs
+/outputs
+**/__pycache__
+*.ckpt
+data/*
+!data/labels.csv
+.git
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for rel, want := range map[string]bool{
+		"outputs":                   true,
+		"outputs/model.pt":          true,
+		"src/outputs":               false,
+		"__pycache__/x.pyc":         true,
+		"src/pkg/__pycache__/x.pyc": true,
+		"last.ckpt":                 true,
+		"runs/last.ckpt":            false,
+		"data/train.parquet":        true,
+		"data/labels.csv":           false,
+		".git/HEAD":                 true,
+		"train.py":                  false,
+	} {
+		if got := ig.Excluded(rel); got != want {
+			t.Fatalf("Excluded(%q) = %v, want %v", rel, got, want)
+		}
+	}
+	if ig.Prunable() {
+		t.Fatalf("patterns with ! exceptions are not prunable")
+	}
+	if _, err := ParseIgnore(strings.NewReader("data/[abc")); err == nil {
+		t.Fatalf("expected an error for an unterminated class")
+	}
+}
+
+func TestCollect(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"Dockerfile":       "FROM scratch\n",
+		".dockerignore":    "outputs\nDockerfile\n",
+		"train.py":         "print('hi')\n",
+		"outputs/model.pt": "weights",
+		"src/util.py":      "",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c, err := Collect(dir, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{".dockerignore", "Dockerfile", "src", "src/util.py", "train.py"}
+	if !reflect.DeepEqual(c.Paths(), want) || c.Dockerfile != "Dockerfile" || c.Files != 4 {
+		t.Fatalf("context = %v (%s, %d files), want %v", c.Paths(), c.Dockerfile, c.Files, want)
+	}
+
+	var buf bytes.Buffer
+	if _, err := c.WriteTo(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(zr)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Uid != 0 || hdr.Uname != "" {
+			t.Fatalf("%s owned by %d %q, want root", hdr.Name, hdr.Uid, hdr.Uname)
+		}
+		names = append(names, hdr.Name)
+	}
+	if strings.Join(names, " ") != ".dockerignore Dockerfile src/ src/util.py train.py" {
+		t.Fatalf("tar holds %v", names)
+	}
+
+	outside := filepath.Join(t.TempDir(), "train.Dockerfile")
+	if err := os.WriteFile(outside, []byte("FROM scratch\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err = Collect(dir, outside)
+	if err != nil || c.Dockerfile != outsideDockerfile || !strings.Contains(strings.Join(c.Paths(), " "), outsideDockerfile) {
+		t.Fatalf("Dockerfile outside the context: %v, %v", c, err)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	got := Command("docker/Dockerfile", Options{Tags: []string{"trainer:dev"}, BuildArgs: []string{"BASE=25.09"}, NoCache: true, Push: true})
+	want := "docker build --tag 'trainer:dev' --build-arg 'BASE=25.09' --no-cache --file 'docker/Dockerfile' - && docker push 'trainer:dev'"
+	if got != want {
+		t.Fatalf("Command = %s\nwant %s", got, want)
+	}
+}
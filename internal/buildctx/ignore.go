@@ -0,0 +1,154 @@
+package buildctx
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Ignore holds the patterns of a .dockerignore file, applied with docker's rules: paths are
+// relative to the context root, * and ? stop at slashes, ** matches any number of
+// directories, a pattern that matches a directory matches everything in it, and a later
+// pattern starting with ! re-includes what an earlier one excluded.
+type Ignore struct {
+	patterns   []pattern
+	exceptions bool
+}
+
+type pattern struct {
+	re     *regexp.Regexp
+	negate bool
+}
+
+// ParseIgnore reads .dockerignore patterns from r
+func ParseIgnore(r io.Reader) (*Ignore, error) {
+	ig := &Ignore{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		negate := false
+		if strings.HasPrefix(line, "!") {
+			negate, line = true, strings.TrimSpace(line[1:])
+		}
+		line = path.Clean(strings.TrimLeft(filepath.ToSlash(line), "/"))
+		if line == "." || line == "" {
+			continue
+		}
+		re, err := compile(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid .dockerignore pattern %q: %w", line, err)
+		}
+		ig.patterns = append(ig.patterns, pattern{re: re, negate: negate})
+		ig.exceptions = ig.exceptions || negate
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ig, nil
+}
+
+// LoadIgnore reads the ignore file for a build of dir: <Dockerfile>.dockerignore next to
+// the Dockerfile when there is one, as BuildKit does, otherwise dir/.dockerignore. A
+// missing file ignores nothing.
+func LoadIgnore(dir, dockerfile string) (*Ignore, error) {
+	for _, name := range []string{dockerfile + ".dockerignore", filepath.Join(dir, ".dockerignore")} {
+		f, err := os.Open(name)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return ParseIgnore(f)
+	}
+	return &Ignore{}, nil
+}
+
+// Excluded reports whether the slash-separated path rel, relative to the context root,
+// is left out of the context
+func (ig *Ignore) Excluded(rel string) bool {
+	excluded := false
+	for _, p := range ig.patterns {
+		if p.negate == !excluded {
+			continue // would not change the outcome
+		}
+		if p.matches(rel) {
+			excluded = !p.negate
+		}
+	}
+	return excluded
+}
+
+// Prunable reports whether an excluded directory can be skipped whole, which it can
+// unless some ! pattern might re-include something inside it
+func (ig *Ignore) Prunable() bool {
+	return !ig.exceptions
+}
+
+// matches reports whether the pattern matches rel or one of its parent directories
+func (p pattern) matches(rel string) bool {
+	for {
+		if p.re.MatchString(rel) {
+			return true
+		}
+		parent := path.Dir(rel)
+		if parent == "." || parent == rel {
+			return false
+		}
+		rel = parent
+	}
+}
+
+// compile turns a cleaned pattern into an anchored regular expression
+func compile(p string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; c {
+		case '*':
+			if i+1 < len(p) && p[i+1] == '*' {
+				i++
+				if i+1 < len(p) && p[i+1] == '/' {
+					i++
+					b.WriteString("(.*/)?") // **/ also matches no directories
+				} else {
+					b.WriteString(".*")
+				}
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(p[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated [")
+			}
+			class := p[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case '\\':
+			if i+1 >= len(p) {
+				return nil, fmt.Errorf("trailing backslash")
+			}
+			i++
+			b.WriteString(regexp.QuoteMeta(string(p[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
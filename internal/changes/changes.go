@@ -0,0 +1,148 @@
+// Package changes records what an update playbook changed on a DGX: the package and
+// component versions before and after it ran, kept in the local state store.
+package changes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/state"
+)
+
+// MaxRecords is how many records are kept per host; older ones are dropped
+const MaxRecords = 50
+
+// Kinds of versioned items
+const (
+	KindComponent = "component"
+	KindPackage   = "package"
+)
+
+// Executor runs a remote command; *ssh.Client implements it
+type Executor interface {
+	ExecuteContext(ctx context.Context, command string) (string, error)
+}
+
+// Inventory is the version of every package and component on a DGX at one time
+type Inventory struct {
+	Components map[string]string
+	Packages   map[string]string
+}
+
+// inventoryScript prints one "component <name> <version>" or "package <name> <version>"
+// line per item. The driver is the loaded kernel module, which changes at the reboot after
+// an upgrade; the packages show the upgrade itself. Containers report their image and
+// image ID, so a re-pulled tag such as :latest still shows up as a change.
+const inventoryScript = `echo "component kernel $(uname -r)"
+[ -r /sys/module/nvidia/version ] && echo "component nvidia-driver $(cat /sys/module/nvidia/version)"
+command -v docker >/dev/null 2>&1 && echo "component docker $(docker version --format '{{.Server.Version}}' 2>/dev/null)"
+if command -v docker >/dev/null 2>&1; then
+  for id in $(docker ps -q 2>/dev/null); do
+    docker inspect --format 'component container:{{slice .Name 1}} {{.Config.Image}}@{{slice .Image 7 19}}' "$id" 2>/dev/null
+  done
+fi
+if command -v dpkg-query >/dev/null 2>&1; then
+  dpkg-query -W -f='package ${Package} ${Version}\n' 2>/dev/null
+elif command -v rpm >/dev/null 2>&1; then
+  rpm -qa --qf 'package %{NAME} %{VERSION}-%{RELEASE}\n' 2>/dev/null
+fi
+true`
+
+// Collect reads the DGX's inventory
+func Collect(ctx context.Context, exec Executor) (Inventory, error) {
+	output, err := exec.ExecuteContext(ctx, inventoryScript)
+	if err != nil {
+		return Inventory{}, fmt.Errorf("failed to read installed versions: %w", err)
+	}
+	return parseInventory(output), nil
+}
+
+func parseInventory(output string) Inventory {
+	inv := Inventory{Components: map[string]string{}, Packages: map[string]string{}}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		switch fields[0] {
+		case KindComponent:
+			inv.Components[fields[1]] = fields[2]
+		case KindPackage:
+			inv.Packages[fields[1]] = fields[2]
+		}
+	}
+	return inv
+}
+
+// Change is one item whose version differs; Before is "" for an added item and After is ""
+// for a removed one
+type Change struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// Diff lists the items that differ between two inventories, components first, each kind
+// sorted by name
+func Diff(before, after Inventory) []Change {
+	changes := diffKind(KindComponent, before.Components, after.Components)
+	return append(changes, diffKind(KindPackage, before.Packages, after.Packages)...)
+}
+
+func diffKind(kind string, before, after map[string]string) []Change {
+	var changes []Change
+	for name, v := range before {
+		if after[name] != v {
+			changes = append(changes, Change{Kind: kind, Name: name, Before: v, After: after[name]})
+		}
+	}
+	for name, v := range after {
+		if _, ok := before[name]; !ok {
+			changes = append(changes, Change{Kind: kind, Name: name, After: v})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
+
+// Record is one tracked playbook run
+type Record struct {
+	Host     string    `json:"host"`
+	Playbook string    `json:"playbook"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Error    string    `json:"error,omitempty"`
+	Changes  []Change  `json:"changes"`
+}
+
+// Key is the state store document holding the records for host
+func Key(host string) string {
+	return state.Key("changes", host)
+}
+
+// Load returns the records for host, oldest first
+func Load(store *state.Store, host string) ([]Record, error) {
+	var records []Record
+	if _, err := store.Load(Key(host), &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// Append adds a record for its host, dropping the oldest beyond MaxRecords
+func Append(store *state.Store, r Record) error {
+	records, err := Load(store, r.Host)
+	if err != nil {
+		// An unreadable log must not lose the new record
+		records = nil
+	}
+	records = append(records, r)
+	if len(records) > MaxRecords {
+		records = records[len(records)-MaxRecords:]
+	}
+	return store.Save(Key(r.Host), records)
+}
@@ -0,0 +1,51 @@
+package changes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/state"
+)
+
+func TestDiff(t *testing.T) {
+	before := parseInventory("component kernel 6.8.0-1010-nvidia\ncomponent docker \ncomponent container:docker-model-runner docker/model-runner:latest-cuda@0a1b2c3d4e5f\n" +
+		"package docker-model-plugin 0.1.40\npackage nvidia-driver-580-open 580.82.07-0ubuntu1\npackage old-tool 1.0\n")
+	after := parseInventory("component kernel 6.8.0-1010-nvidia\ncomponent container:docker-model-runner docker/model-runner:latest-cuda@9f8e7d6c5b4a\n" +
+		"package docker-model-plugin 0.1.44\npackage nvidia-driver-580-open 580.82.07-0ubuntu1\npackage new-tool 2.0\n")
+	if _, ok := before.Components["docker"]; ok {
+		t.Fatalf("a line without a version was parsed: %v", before.Components)
+	}
+
+	got := Diff(before, after)
+	want := []Change{
+		{Kind: KindComponent, Name: "container:docker-model-runner", Before: "docker/model-runner:latest-cuda@0a1b2c3d4e5f", After: "docker/model-runner:latest-cuda@9f8e7d6c5b4a"},
+		{Kind: KindPackage, Name: "docker-model-plugin", Before: "0.1.40", After: "0.1.44"},
+		{Kind: KindPackage, Name: "new-tool", After: "2.0"},
+		{Kind: KindPackage, Name: "old-tool", Before: "1.0"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Diff = %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("change %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestAppend(t *testing.T) {
+	store := state.NewStore(t.TempDir())
+	for i := 0; i < MaxRecords+2; i++ {
+		r := Record{Host: "spark", Playbook: "dmr update", Started: time.Unix(int64(i), 0)}
+		if err := Append(store, r); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	records, err := Load(store, "spark")
+	if err != nil || len(records) != MaxRecords || records[0].Started.Unix() != 2 {
+		t.Fatalf("records = %d, first %v, err %v", len(records), records[0].Started, err)
+	}
+	if other, _ := Load(store, "other"); len(other) != 0 {
+		t.Fatalf("records for another host: %+v", other)
+	}
+}
@@ -0,0 +1,199 @@
+package chat
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Message is one turn of an OpenAI-style chat conversation. Images are data URLs (see
+// LoadImage) sent along with the text to multimodal models.
+type Message struct {
+	Role    string   `json:"role"`
+	Content string   `json:"content"`
+	Images  []string `json:"-"`
+}
+
+// MarshalJSON encodes messages with images as OpenAI content parts, and others with plain
+// string content, which every server accepts
+func (m Message) MarshalJSON() ([]byte, error) {
+	if len(m.Images) == 0 {
+		type plain Message
+		return json.Marshal(plain(m))
+	}
+	type imageURL struct {
+		URL string `json:"url"`
+	}
+	type part struct {
+		Type     string    `json:"type"`
+		Text     string    `json:"text,omitempty"`
+		ImageURL *imageURL `json:"image_url,omitempty"`
+	}
+	parts := make([]part, 0, len(m.Images)+1)
+	if m.Content != "" {
+		parts = append(parts, part{Type: "text", Text: m.Content})
+	}
+	for _, url := range m.Images {
+		parts = append(parts, part{Type: "image_url", ImageURL: &imageURL{URL: url}})
+	}
+	return json.Marshal(struct {
+		Role    string `json:"role"`
+		Content []part `json:"content"`
+	}{m.Role, parts})
+}
+
+// Client talks to an OpenAI-compatible chat completions endpoint
+type Client struct {
+	httpClient  *http.Client
+	baseURL     string
+	apiKey      string
+	temperature *float64
+}
+
+// NewClient creates a chat client. baseURL is the part before /v1 (e.g. "http://127.0.0.1:8080");
+// apiKey is sent as a bearer token when non-empty.
+func NewClient(httpClient *http.Client, baseURL, apiKey string) *Client {
+	return &Client{
+		httpClient: httpClient,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		apiKey:     apiKey,
+	}
+}
+
+// SetTemperature sets the sampling temperature sent with completions; nil leaves it to the
+// server
+func (c *Client) SetTemperature(temperature *float64) {
+	c.temperature = temperature
+}
+
+// Complete requests a streamed completion and calls onDelta with each content fragment as it
+// arrives. It returns the full reply.
+func (c *Client) Complete(ctx context.Context, model string, messages []Message, onDelta func(string)) (string, error) {
+	request := map[string]interface{}{
+		"model":    model,
+		"messages": messages,
+		"stream":   true,
+	}
+	if c.temperature != nil {
+		request["temperature"] = *c.temperature
+	}
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v1/chat/completions", bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach model server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", fmt.Errorf("model server returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var reply strings.Builder
+	emit := func(s string) {
+		if s == "" {
+			return
+		}
+		reply.WriteString(s)
+		if onDelta != nil {
+			onDelta(s)
+		}
+	}
+
+	// Servers that ignore "stream" answer with a single JSON document
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		var full struct {
+			Choices []struct {
+				Message Message `json:"message"`
+			} `json:"choices"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&full); err != nil {
+			return "", fmt.Errorf("failed to decode response: %w", err)
+		}
+		if len(full.Choices) > 0 {
+			emit(full.Choices[0].Message.Content)
+		}
+		return reply.String(), nil
+	}
+
+	err = ReadEvents(resp.Body, func(data []byte) error {
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			return fmt.Errorf("model server error: %s", chunk.Error.Message)
+		}
+		for _, choice := range chunk.Choices {
+			emit(choice.Delta.Content)
+		}
+		return nil
+	})
+	return reply.String(), err
+}
+
+// ReadEvents parses a server-sent event stream, calling onData with the data of each event.
+// It stops at end of stream or at the OpenAI "[DONE]" sentinel.
+func ReadEvents(r io.Reader, onData func([]byte) error) error {
+	reader := bufio.NewReader(r)
+	var data []byte
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("failed to read stream: %w", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case line == "" && len(data) > 0:
+			if string(data) == "[DONE]" {
+				return nil
+			}
+			if cbErr := onData(data); cbErr != nil {
+				return cbErr
+			}
+			data = nil
+		case strings.HasPrefix(line, "data:"):
+			if len(data) > 0 {
+				data = append(data, '\n')
+			}
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")...)
+		}
+		// Comments (":"), event names, ids, and retry hints are ignored
+
+		if err == io.EOF {
+			if len(data) > 0 && string(data) != "[DONE]" {
+				return onData(data)
+			}
+			return nil
+		}
+	}
+}
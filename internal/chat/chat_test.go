@@ -0,0 +1,42 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadEvents(t *testing.T) {
+	t.Run("stops at done", func(t *testing.T) {
+		stream := ": keep-alive\n\n" +
+			"data: {\"a\":1}\n\n" +
+			"event: message\r\ndata: {\"b\":2}\r\n\r\n" +
+			"data: [DONE]\n\n" +
+			"data: {\"ignored\":true}\n\n"
+
+		var got []string
+		err := ReadEvents(strings.NewReader(stream), func(data []byte) error {
+			got = append(got, string(data))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != 2 || got[0] != `{"a":1}` || got[1] != `{"b":2}` {
+			t.Fatalf("unexpected events: %q", got)
+		}
+	})
+
+	t.Run("multi-line data and unterminated final event", func(t *testing.T) {
+		var got []string
+		err := ReadEvents(strings.NewReader("data: one\ndata: two\n\ndata: last"), func(data []byte) error {
+			got = append(got, string(data))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != 2 || got[0] != "one\ntwo" || got[1] != "last" {
+			t.Fatalf("unexpected events: %q", got)
+		}
+	})
+}
@@ -0,0 +1,136 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+)
+
+// Embed requests embeddings for inputs and returns one vector per input, in input order
+func (c *Client) Embed(ctx context.Context, model string, inputs []string) ([][]float64, error) {
+	var resp struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := c.post(ctx, "/v1/embeddings", map[string]interface{}{"model": model, "input": inputs}, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Data) != len(inputs) {
+		return nil, fmt.Errorf("model server returned %d embeddings for %d inputs", len(resp.Data), len(inputs))
+	}
+	vectors := make([][]float64, len(inputs))
+	for i, d := range resp.Data {
+		index := d.Index
+		if index < 0 || index >= len(inputs) || vectors[index] != nil {
+			// Some servers leave index out; fall back to response order
+			index = i
+		}
+		vectors[index] = d.Embedding
+	}
+	return vectors, nil
+}
+
+// RerankResult scores one document against the query
+type RerankResult struct {
+	Index int     `json:"index"`
+	Score float64 `json:"relevance_score"`
+}
+
+// Rerank scores documents against query, most relevant first
+func (c *Client) Rerank(ctx context.Context, model, query string, documents []string) ([]RerankResult, error) {
+	var resp struct {
+		Results []RerankResult `json:"results"`
+	}
+	req := map[string]interface{}{"model": model, "query": query, "documents": documents}
+	if err := c.post(ctx, "/v1/rerank", req, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Results) != len(documents) {
+		return nil, fmt.Errorf("model server returned %d scores for %d documents", len(resp.Results), len(documents))
+	}
+	for _, r := range resp.Results {
+		if r.Index < 0 || r.Index >= len(documents) {
+			return nil, fmt.Errorf("model server returned a score for unknown document %d", r.Index)
+		}
+	}
+	return resp.Results, nil
+}
+
+// post sends a JSON request and decodes the JSON response into out
+func (c *Client) post(ctx context.Context, path string, payload interface{}, out interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach model server: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("model server returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// CheckEmbeddings reports vectors that are empty, differ in dimension, contain NaN or
+// infinite values, or are all zeros, and returns the common dimension
+func CheckEmbeddings(vectors [][]float64) (int, error) {
+	if len(vectors) == 0 {
+		return 0, fmt.Errorf("no embeddings returned")
+	}
+	dim := len(vectors[0])
+	for i, v := range vectors {
+		if len(v) == 0 {
+			return 0, fmt.Errorf("embedding %d is empty", i)
+		}
+		if len(v) != dim {
+			return 0, fmt.Errorf("embedding %d has %d dimensions, expected %d", i, len(v), dim)
+		}
+		var norm float64
+		for _, x := range v {
+			if math.IsNaN(x) || math.IsInf(x, 0) {
+				return 0, fmt.Errorf("embedding %d contains %v", i, x)
+			}
+			norm += x * x
+		}
+		if norm == 0 {
+			return 0, fmt.Errorf("embedding %d is all zeros", i)
+		}
+	}
+	return dim, nil
+}
+
+// Cosine returns the cosine similarity of two vectors of the same dimension
+func Cosine(a, b []float64) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
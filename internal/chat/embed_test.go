@@ -0,0 +1,53 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEmbed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		// Out of order, as some servers reply
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": []map[string]interface{}{
+				{"index": 1, "embedding": []float64{0, 1}},
+				{"index": 0, "embedding": []float64{1, 0}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	vectors, err := NewClient(server.Client(), server.URL, "").Embed(context.Background(), "m", []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if vectors[0][0] != 1 || vectors[1][1] != 1 {
+		t.Fatalf("embeddings not in input order: %v", vectors)
+	}
+	if dim, err := CheckEmbeddings(vectors); err != nil || dim != 2 {
+		t.Fatalf("expected valid 2-dimensional embeddings, got %d, %v", dim, err)
+	}
+	if got := Cosine(vectors[0], vectors[1]); got != 0 {
+		t.Fatalf("expected orthogonal vectors, got cosine %v", got)
+	}
+}
+
+func TestCheckEmbeddings(t *testing.T) {
+	for name, vectors := range map[string][][]float64{
+		"empty":      {{}},
+		"dimensions": {{1, 0}, {1, 0, 0}},
+		"zeros":      {{0, 0}},
+		"nan":        {{1, math.NaN()}},
+	} {
+		if _, err := CheckEmbeddings(vectors); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+}
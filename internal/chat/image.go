@@ -0,0 +1,63 @@
+package chat
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MaxImageBytes bounds an attached image. Larger files are refused rather than sent, since
+// servers reject them after the whole request has gone over SSH.
+const MaxImageBytes = 20 << 20
+
+// ImageTypes are the image formats vision models commonly accept
+var ImageTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp"}
+
+// LoadImage reads an image file and returns it as a base64 data URL. The format is detected
+// from the file's contents, not its extension.
+func LoadImage(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read image: %w", err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory, not an image", path)
+	}
+	if info.Size() > MaxImageBytes {
+		return "", fmt.Errorf("%s is %.1f MiB; images are limited to %d MiB", filepath.Base(path), float64(info.Size())/(1<<20), MaxImageBytes>>20)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read image: %w", err)
+	}
+	mime := http.DetectContentType(data)
+	if !isImageType(mime) {
+		return "", fmt.Errorf("%s is not a supported image (%s); expected PNG, JPEG, GIF, or WebP", filepath.Base(path), mime)
+	}
+	return "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// LoadImages loads each path with LoadImage
+func LoadImages(paths []string) ([]string, error) {
+	images := make([]string, 0, len(paths))
+	for _, path := range paths {
+		image, err := LoadImage(path)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, image)
+	}
+	return images, nil
+}
+
+func isImageType(mime string) bool {
+	for _, t := range ImageTypes {
+		if strings.HasPrefix(mime, t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package chat
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadImage(t *testing.T) {
+	dir := t.TempDir()
+	png := filepath.Join(dir, "pixel.dat")
+	// The PNG signature is enough for content sniffing; the extension is ignored
+	if err := os.WriteFile(png, []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	url, err := LoadImage(png)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(url, "data:image/png;base64,") {
+		t.Fatalf("unexpected data URL %q", url)
+	}
+
+	text := filepath.Join(dir, "notes.png")
+	if err := os.WriteFile(text, []byte("not an image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadImage(text); err == nil {
+		t.Fatalf("expected a text file to be rejected")
+	}
+	if _, err := LoadImage(dir); err == nil {
+		t.Fatalf("expected a directory to be rejected")
+	}
+}
+
+func TestMessageJSON(t *testing.T) {
+	plain, _ := json.Marshal(Message{Role: "user", Content: "hi"})
+	if string(plain) != `{"role":"user","content":"hi"}` {
+		t.Fatalf("unexpected plain message %s", plain)
+	}
+	withImage, _ := json.Marshal(Message{Role: "user", Content: "what is this?", Images: []string{"data:image/png;base64,AA=="}})
+	want := `{"role":"user","content":[{"type":"text","text":"what is this?"},{"type":"image_url","image_url":{"url":"data:image/png;base64,AA=="}}]}`
+	if string(withImage) != want {
+		t.Fatalf("unexpected multimodal message %s", withImage)
+	}
+}
@@ -0,0 +1,162 @@
+package cluster
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/ui"
+)
+
+// ExpectedLinkSpeedMbps is the ConnectX-7 interconnect speed between paired Sparks
+const ExpectedLinkSpeedMbps = 200000
+
+// Link describes a ConnectX network interface on a node
+type Link struct {
+	Interface   string
+	State       string
+	SpeedMbps   int
+	MTU         int
+	RDMADevice  string
+	Firmware    string
+	IPv4Address string
+}
+
+// AtExpectedSpeed reports whether the link is up and running at the interconnect's rated speed
+func (l Link) AtExpectedSpeed() bool {
+	return l.State == "up" && l.SpeedMbps >= ExpectedLinkSpeedMbps
+}
+
+// linkScript prints one pipe-separated line per mlx5 interface:
+// iface|operstate|speed|mtu|rdma-device|firmware|ipv4
+const linkScript = `for dev in /sys/class/net/*; do
+  iface=$(basename "$dev")
+  driver=$(basename "$(readlink -f "$dev/device/driver" 2>/dev/null)" 2>/dev/null)
+  [ "$driver" = "mlx5_core" ] || continue
+  ib=$(ls "$dev/device/infiniband" 2>/dev/null | head -n1)
+  fw=""
+  [ -n "$ib" ] && fw=$(cat "/sys/class/infiniband/$ib/fw_ver" 2>/dev/null)
+  ip4=$(ip -4 -o addr show dev "$iface" 2>/dev/null | awk '{print $4}' | head -n1)
+  echo "$iface|$(cat "$dev/operstate" 2>/dev/null)|$(cat "$dev/speed" 2>/dev/null)|$(cat "$dev/mtu" 2>/dev/null)|$ib|$fw|$ip4"
+done`
+
+// Inspector reads interconnect state from a Spark node
+type Inspector struct {
+	sshClient *ssh.Client
+}
+
+// NewInspector creates a new interconnect inspector
+func NewInspector(sshClient *ssh.Client) *Inspector {
+	return &Inspector{
+		sshClient: sshClient,
+	}
+}
+
+// Links returns the ConnectX interfaces present on the node
+func (i *Inspector) Links() ([]Link, error) {
+	output, err := i.sshClient.Execute(linkScript)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect ConnectX interfaces: %w", err)
+	}
+	return parseLinks(output), nil
+}
+
+// RDMAWriteBandwidth runs ib_write_bw from this node against a server on the peer and
+// returns the average bandwidth in Gb/s.
+func (i *Inspector) RDMAWriteBandwidth(peer *Inspector, device, peerDevice, peerAddr string) (float64, error) {
+	for _, node := range []*Inspector{i, peer} {
+		if _, err := node.sshClient.Execute("command -v ib_write_bw"); err != nil {
+			return 0, fmt.Errorf("ib_write_bw not found; install perftest on both nodes (sudo apt-get install -y perftest)")
+		}
+	}
+
+	if _, err := peer.sshClient.Execute(perftestServerCommand(peerDevice)); err != nil {
+		return 0, fmt.Errorf("failed to start ib_write_bw server on peer: %w", err)
+	}
+
+	output, err := i.sshClient.Execute(perftestClientCommand(device, peerAddr))
+	if err != nil {
+		return 0, fmt.Errorf("ib_write_bw failed: %w\n%s", err, strings.TrimSpace(output))
+	}
+	return parsePerftestBandwidth(output)
+}
+
+// perftestServerCommand starts a one-shot ib_write_bw server on device in the background
+func perftestServerCommand(device string) string {
+	return fmt.Sprintf("nohup timeout 60 ib_write_bw -d %s --report_gbits >/tmp/dgx-ib-server.log 2>&1 &", ssh.ShellQuote(device))
+}
+
+// perftestClientCommand runs ib_write_bw on device against the server at peerAddr
+func perftestClientCommand(device, peerAddr string) string {
+	return fmt.Sprintf("sleep 1; ib_write_bw -d %s --report_gbits %s", ssh.ShellQuote(device), ssh.ShellQuote(peerAddr))
+}
+
+func parseLinks(output string) []Link {
+	var links []Link
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "|")
+		if len(fields) < 7 || fields[0] == "" {
+			continue
+		}
+		speed, _ := strconv.Atoi(fields[2])
+		mtu, _ := strconv.Atoi(fields[3])
+		links = append(links, Link{
+			Interface:   fields[0],
+			State:       fields[1],
+			SpeedMbps:   speed,
+			MTU:         mtu,
+			RDMADevice:  fields[4],
+			Firmware:    fields[5],
+			IPv4Address: fields[6],
+		})
+	}
+	return links
+}
+
+// parsePerftestBandwidth extracts "BW average[Gb/sec]" from perftest output
+func parsePerftestBandwidth(output string) (float64, error) {
+	lines := strings.Split(output, "\n")
+	for idx, line := range lines {
+		if !strings.Contains(line, "BW average") {
+			continue
+		}
+		for _, next := range lines[idx+1:] {
+			fields := strings.Fields(next)
+			if len(fields) >= 4 {
+				if bw, err := strconv.ParseFloat(fields[3], 64); err == nil {
+					return bw, nil
+				}
+			}
+		}
+	}
+	return 0, fmt.Errorf("could not find bandwidth in perftest output")
+}
+
+// FormatLinks renders links for display
+func FormatLinks(node string, links []Link) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s:\n", node))
+	if len(links) == 0 {
+		sb.WriteString("  No ConnectX (mlx5) interfaces found\n")
+		return sb.String()
+	}
+	sb.WriteString(fmt.Sprintf("  %-14s %-6s %-10s %-6s %-10s %-14s %-18s %s\n",
+		"INTERFACE", "STATE", "SPEED", "MTU", "RDMA", "FIRMWARE", "ADDRESS", "VERDICT"))
+	for _, l := range links {
+		speed := "-"
+		if l.SpeedMbps > 0 {
+			speed = fmt.Sprintf("%dGb/s", l.SpeedMbps/1000)
+		}
+		verdict := "ok"
+		switch {
+		case l.State != "up":
+			verdict = "down"
+		case !l.AtExpectedSpeed():
+			verdict = fmt.Sprintf("below %dGb/s", ExpectedLinkSpeedMbps/1000)
+		}
+		sb.WriteString(fmt.Sprintf("  %-14s %-6s %-10s %-6d %-10s %-14s %-18s %s\n",
+			l.Interface, l.State, speed, l.MTU, ui.OrDash(l.RDMADevice), ui.OrDash(l.Firmware), ui.OrDash(l.IPv4Address), verdict))
+	}
+	return sb.String()
+}
@@ -0,0 +1,45 @@
+package cluster
+
+import "testing"
+
+func TestParseLinks(t *testing.T) {
+	output := "enp1s0f0np0|up|200000|9000|rocep1s0f0|28.39.1002|192.168.100.10/24\nenp1s0f1np1|down|-1|1500|rocep1s0f1|28.39.1002|\n"
+	links := parseLinks(output)
+	if len(links) != 2 {
+		t.Fatalf("expected 2 links, got %d", len(links))
+	}
+	if !links[0].AtExpectedSpeed() {
+		t.Fatalf("expected first link at speed: %+v", links[0])
+	}
+	if links[1].AtExpectedSpeed() {
+		t.Fatalf("expected second link flagged: %+v", links[1])
+	}
+	if links[0].RDMADevice != "rocep1s0f0" || links[0].IPv4Address != "192.168.100.10/24" {
+		t.Fatalf("unexpected link %+v", links[0])
+	}
+}
+
+func TestParsePerftestBandwidth(t *testing.T) {
+	output := `
+---------------------------------------------------------------------------------------
+ #bytes     #iterations    BW peak[Gb/sec]    BW average[Gb/sec]   MsgRate[Mpps]
+ 65536      5000             185.21             184.97               0.352812
+---------------------------------------------------------------------------------------
+`
+	bw, err := parsePerftestBandwidth(output)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if bw != 184.97 {
+		t.Fatalf("unexpected bandwidth %v", bw)
+	}
+}
+
+func TestPerftestCommandsQuoteValues(t *testing.T) {
+	if got := perftestServerCommand("mlx5_0;id"); got != "nohup timeout 60 ib_write_bw -d 'mlx5_0;id' --report_gbits >/tmp/dgx-ib-server.log 2>&1 &" {
+		t.Fatalf("server: %q", got)
+	}
+	if got := perftestClientCommand("mlx5_1", "$(reboot)"); got != "sleep 1; ib_write_bw -d 'mlx5_1' --report_gbits '$(reboot)'" {
+		t.Fatalf("client: %q", got)
+	}
+}
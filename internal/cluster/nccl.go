@@ -0,0 +1,171 @@
+package cluster
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+// DefaultNCCLImage is the container used to build and run nccl-tests
+const DefaultNCCLImage = "nvcr.io/nvidia/pytorch:25.09-py3"
+
+// ExpectedBusBandwidthGBps is the all_reduce bus bandwidth a healthy pair of Sparks reaches
+// over the 200Gb/s ConnectX-7 link (line rate is 25 GB/s).
+const ExpectedBusBandwidthGBps = 22.0
+
+const ncclContainer = "dgx-nccl-bench"
+
+// NCCLRow is one message-size row of all_reduce_perf output (out-of-place columns)
+type NCCLRow struct {
+	SizeBytes int64
+	TimeUs    float64
+	AlgBW     float64
+	BusBW     float64
+	Wrong     int
+}
+
+// NCCLResult summarizes an all_reduce_perf run
+type NCCLResult struct {
+	Nodes        int
+	Rows         []NCCLRow
+	PeakBusBW    float64
+	AvgBusBW     float64
+	WrongResults int
+}
+
+// Verdict grades the result against the expected bandwidth for the node count
+func (r NCCLResult) Verdict() string {
+	if r.WrongResults > 0 || len(r.Rows) == 0 {
+		return "fail"
+	}
+	if r.Nodes < 2 {
+		// A single GB10 has one GPU, so bus bandwidth is not meaningful; a clean run is a pass.
+		return "pass"
+	}
+	if r.PeakBusBW >= ExpectedBusBandwidthGBps*0.8 {
+		return "pass"
+	}
+	return "warn"
+}
+
+// NCCLBench runs nccl-tests inside containers on one or two Spark nodes
+type NCCLBench struct {
+	Image     string
+	MaxBytes  string
+	Interface string
+	nodes     []*ssh.Client
+	hosts     []string
+}
+
+// NewNCCLBench creates a benchmark across the given nodes. hosts must be the addresses the
+// first node uses to reach each node (used for the MPI host list).
+func NewNCCLBench(nodes []*ssh.Client, hosts []string) *NCCLBench {
+	return &NCCLBench{
+		Image:    DefaultNCCLImage,
+		MaxBytes: "8G",
+		nodes:    nodes,
+		hosts:    hosts,
+	}
+}
+
+// Run starts the benchmark containers, builds nccl-tests, runs all_reduce_perf, and cleans up
+func (b *NCCLBench) Run() (NCCLResult, string, error) {
+	defer b.cleanup()
+
+	mpi := len(b.nodes) > 1
+	for i, node := range b.nodes {
+		fmt.Printf("Preparing benchmark container on %s...\n", b.hosts[i])
+		start := fmt.Sprintf(`docker rm -f %[1]s >/dev/null 2>&1; docker run -d --name %[1]s --gpus all --network host --ipc host --ulimit memlock=-1 -v "$HOME/.ssh:/root/.ssh:ro" %[2]s sleep infinity`,
+			ncclContainer, ssh.ShellQuote(b.Image))
+		if output, err := node.Execute(start); err != nil {
+			return NCCLResult{}, output, fmt.Errorf("failed to start container on %s: %w", b.hosts[i], err)
+		}
+
+		build := "git clone --depth 1 https://github.com/NVIDIA/nccl-tests /opt/nccl-tests && make -C /opt/nccl-tests -j"
+		if mpi {
+			build += " MPI=1 MPI_HOME=/usr/local/mpi"
+		}
+		if output, err := node.Execute(fmt.Sprintf("docker exec %s bash -lc %s", ncclContainer, ssh.ShellQuote(build+" >/dev/null"))); err != nil {
+			return NCCLResult{}, output, fmt.Errorf("failed to build nccl-tests on %s: %w", b.hosts[i], err)
+		}
+	}
+
+	fmt.Println("Running all_reduce_perf...")
+	output, err := b.nodes[0].Execute(fmt.Sprintf("docker exec %s bash -lc %s", ncclContainer, ssh.ShellQuote(b.benchCommand(mpi))))
+	if err != nil {
+		return NCCLResult{}, output, fmt.Errorf("all_reduce_perf failed: %w", err)
+	}
+
+	result := ParseAllReducePerf(output)
+	result.Nodes = len(b.nodes)
+	return result, output, nil
+}
+
+// benchCommand returns the all_reduce_perf command run in the first node's container,
+// under mpirun across every node when mpi is set
+func (b *NCCLBench) benchCommand(mpi bool) string {
+	bench := fmt.Sprintf("/opt/nccl-tests/build/all_reduce_perf -b 8 -e %s -f 2 -g 1", ssh.ShellQuote(b.MaxBytes))
+	if !mpi {
+		return bench
+	}
+	// mpirun reaches the peer's container by wrapping ssh with docker exec
+	rsh := fmt.Sprintf("#!/bin/sh\nh=$1; shift\nexec ssh -o StrictHostKeyChecking=accept-new \"$h\" docker exec -i %s \"$@\"\n", ncclContainer)
+	env := ""
+	if b.Interface != "" {
+		env = fmt.Sprintf("-x NCCL_SOCKET_IFNAME=%[1]s -x UCX_NET_DEVICES=%[1]s --mca btl_tcp_if_include %[1]s ", ssh.ShellQuote(b.Interface))
+	}
+	return fmt.Sprintf("printf %%s %s > /tmp/dgx-rsh && chmod +x /tmp/dgx-rsh && mpirun --allow-run-as-root -np %d -H %s --mca plm_rsh_agent /tmp/dgx-rsh %s%s",
+		ssh.ShellQuote(rsh), len(b.nodes), ssh.ShellQuote(strings.Join(b.hosts, ",")), env, bench)
+}
+
+func (b *NCCLBench) cleanup() {
+	for _, node := range b.nodes {
+		node.Execute(fmt.Sprintf("docker rm -f %s >/dev/null 2>&1", ncclContainer))
+	}
+}
+
+// ParseAllReducePerf parses nccl-tests output into rows and summary bandwidth
+func ParseAllReducePerf(output string) NCCLResult {
+	var result NCCLResult
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "# Avg bus bandwidth") {
+			if idx := strings.LastIndex(line, ":"); idx >= 0 {
+				result.AvgBusBW, _ = strconv.ParseFloat(strings.TrimSpace(line[idx+1:]), 64)
+			}
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// size count type redop root time algbw busbw #wrong (out-of-place) ...
+		fields := strings.Fields(line)
+		if len(fields) < 9 {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		timeUs, _ := strconv.ParseFloat(fields[5], 64)
+		algbw, _ := strconv.ParseFloat(fields[6], 64)
+		busbw, _ := strconv.ParseFloat(fields[7], 64)
+		wrong, _ := strconv.Atoi(fields[8])
+
+		result.Rows = append(result.Rows, NCCLRow{
+			SizeBytes: size,
+			TimeUs:    timeUs,
+			AlgBW:     algbw,
+			BusBW:     busbw,
+			Wrong:     wrong,
+		})
+		result.WrongResults += wrong
+		if busbw > result.PeakBusBW {
+			result.PeakBusBW = busbw
+		}
+	}
+	return result
+}
@@ -0,0 +1,52 @@
+package cluster
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseAllReducePerf(t *testing.T) {
+	output := `# nThread 1 nGpus 1 minBytes 8 maxBytes 8589934592 step: 2(factor) warmup iters: 5 iters: 20
+#       size         count      type   redop    root     time   algbw   busbw #wrong     time   algbw   busbw #wrong
+#        (B)    (elements)                               (us)  (GB/s)  (GB/s)            (us)  (GB/s)  (GB/s)
+           8             2     float     sum      -1    30.12    0.00    0.00      0    29.80    0.00    0.00      0
+  4294967296    1073741824     float     sum      -1   378912  11.33   22.67      0   378104  11.36   22.72      0
+# Out of bounds values : 0 OK
+# Avg bus bandwidth    : 11.3350
+`
+	result := ParseAllReducePerf(output)
+	result.Nodes = 2
+	if len(result.Rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(result.Rows))
+	}
+	if result.PeakBusBW != 22.67 {
+		t.Fatalf("unexpected peak %v", result.PeakBusBW)
+	}
+	if result.AvgBusBW != 11.335 {
+		t.Fatalf("unexpected avg %v", result.AvgBusBW)
+	}
+	if result.Verdict() != "pass" {
+		t.Fatalf("unexpected verdict %q", result.Verdict())
+	}
+}
+
+func TestBenchCommandQuotesSettings(t *testing.T) {
+	b := NewNCCLBench(nil, []string{"localhost", "spark-2.local"})
+	b.MaxBytes = "8G; reboot"
+	if got := b.benchCommand(false); got != "/opt/nccl-tests/build/all_reduce_perf -b 8 -e '8G; reboot' -f 2 -g 1" {
+		t.Fatalf("single node: %q", got)
+	}
+
+	b.MaxBytes = "8G"
+	b.Interface = "enp1s0f0np0"
+	got := b.benchCommand(true)
+	for _, want := range []string{
+		"-H 'localhost,spark-2.local'",
+		"-x NCCL_SOCKET_IFNAME='enp1s0f0np0' -x UCX_NET_DEVICES='enp1s0f0np0' --mca btl_tcp_if_include 'enp1s0f0np0' ",
+		"all_reduce_perf -b 8 -e '8G' -f 2 -g 1",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("mpi command missing %q:\n%s", want, got)
+		}
+	}
+}
@@ -18,6 +18,9 @@ const (
 type Manager struct {
 	configPath string
 	config     *types.Config
+	effective  *types.Config
+	settings   []Setting
+	overrides  *Overrides
 }
 
 // NewManager creates a new configuration manager
@@ -29,6 +32,10 @@ func NewManager() (*Manager, error) {
 
 	configDir := filepath.Join(home, DefaultConfigDir)
 	configPath := filepath.Join(configDir, DefaultConfigFile)
+	if override := os.Getenv(EnvConfig); override != "" {
+		configPath = override
+		configDir = filepath.Dir(override)
+	}
 
 	// Create config directory if it doesn't exist
 	if err := os.MkdirAll(configDir, 0700); err != nil {
@@ -51,6 +58,9 @@ func NewManager() (*Manager, error) {
 		}
 	}
 
+	// Best effort: a broken NVIDIA Sync config must not stop the CLI from starting
+	_, _ = m.RefreshNVSync()
+
 	return m, nil
 }
 
@@ -81,11 +91,15 @@ func (m *Manager) Save() error {
 		return fmt.Errorf("failed to write config: %w", err)
 	}
 
+	m.refresh()
 	return nil
 }
 
-// Get returns the current configuration
+// Get returns the effective configuration, with the selected profile and overrides applied
 func (m *Manager) Get() *types.Config {
+	if m.effective != nil {
+		return m.effective
+	}
 	return m.config
 }
 
@@ -158,5 +172,6 @@ func (m *Manager) GetConfigPath() string {
 
 // IsConfigured checks if the essential configuration is set
 func (m *Manager) IsConfigured() bool {
-	return m.config.Host != "" && m.config.User != ""
+	cfg := m.Get()
+	return cfg.Host != "" && cfg.User != ""
 }
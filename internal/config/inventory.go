@@ -0,0 +1,237 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/weatherman/dgx-manager/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DefaultInventoryFile lives next to config.yaml
+	DefaultInventoryFile = "hosts.yaml"
+	// EnvInventory overrides the inventory path
+	EnvInventory = "DGX_INVENTORY"
+	// GroupAll selects every host in the inventory
+	GroupAll = "all"
+)
+
+// InventoryHost is one DGX in the inventory. Empty connection fields fall back to the
+// inventory defaults and then to the main config.
+type InventoryHost struct {
+	Name         string   `yaml:"name"`
+	Host         string   `yaml:"host"`
+	Port         int      `yaml:"port,omitempty"`
+	User         string   `yaml:"user,omitempty"`
+	IdentityFile string   `yaml:"identity_file,omitempty"`
+	Groups       []string `yaml:"groups,omitempty"`
+	Tags         []string `yaml:"tags,omitempty"`
+	ReadOnly     bool     `yaml:"readonly,omitempty"`
+}
+
+// Inventory lists many DGX hosts for fleet commands, Ansible style
+type Inventory struct {
+	Defaults struct {
+		Port         int    `yaml:"port,omitempty"`
+		User         string `yaml:"user,omitempty"`
+		IdentityFile string `yaml:"identity_file,omitempty"`
+	} `yaml:"defaults,omitempty"`
+	Hosts []InventoryHost `yaml:"hosts"`
+	// Groups add members to groups: host names, or other groups
+	Groups map[string][]string `yaml:"groups,omitempty"`
+}
+
+// DefaultInventoryPath returns $DGX_INVENTORY or hosts.yaml in the config directory
+func DefaultInventoryPath() (string, error) {
+	if override := os.Getenv(EnvInventory); override != "" {
+		return override, nil
+	}
+	if override := os.Getenv(EnvConfig); override != "" {
+		return filepath.Join(filepath.Dir(override), DefaultInventoryFile), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, DefaultConfigDir, DefaultInventoryFile), nil
+}
+
+// LoadInventory reads and validates an inventory file
+func LoadInventory(path string) (*Inventory, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read inventory: %w", err)
+	}
+	var inv Inventory
+	if err := yaml.Unmarshal(data, &inv); err != nil {
+		return nil, fmt.Errorf("failed to parse inventory %s: %w", path, err)
+	}
+
+	seen := map[string]bool{}
+	for i, h := range inv.Hosts {
+		if h.Name == "" {
+			h.Name = h.Host
+			inv.Hosts[i].Name = h.Host
+		}
+		switch {
+		case h.Host == "":
+			return nil, fmt.Errorf("inventory %s: host %q has no host address", path, h.Name)
+		case seen[h.Name]:
+			return nil, fmt.Errorf("inventory %s: host %q is listed twice", path, h.Name)
+		case h.Name == GroupAll:
+			return nil, fmt.Errorf("inventory %s: %q is reserved", path, GroupAll)
+		}
+		seen[h.Name] = true
+	}
+	return &inv, nil
+}
+
+// Lookup returns the host with the given name
+func (inv *Inventory) Lookup(name string) (InventoryHost, bool) {
+	for _, h := range inv.Hosts {
+		if h.Name == name {
+			return h, true
+		}
+	}
+	return InventoryHost{}, false
+}
+
+// Select returns the hosts in any of groups (all hosts when groups is empty) that carry
+// every one of tags, in inventory order
+func (inv *Inventory) Select(groups, tags []string) ([]InventoryHost, error) {
+	members := map[string]bool{}
+	if len(groups) == 0 {
+		groups = []string{GroupAll}
+	}
+	for _, g := range groups {
+		names, err := inv.groupMembers(g, map[string]bool{})
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range names {
+			members[n] = true
+		}
+	}
+
+	var selected []InventoryHost
+	for _, h := range inv.Hosts {
+		if members[h.Name] && hasAll(h.Tags, tags) {
+			selected = append(selected, h)
+		}
+	}
+	return selected, nil
+}
+
+// GroupNames returns every group named by hosts or the groups section
+func (inv *Inventory) GroupNames() []string {
+	set := map[string]bool{}
+	for _, h := range inv.Hosts {
+		for _, g := range h.Groups {
+			set[g] = true
+		}
+	}
+	for g := range inv.Groups {
+		set[g] = true
+	}
+	names := make([]string, 0, len(set))
+	for g := range set {
+		names = append(names, g)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// groupMembers resolves a group to host names, following nested groups
+func (inv *Inventory) groupMembers(group string, visiting map[string]bool) ([]string, error) {
+	if group == GroupAll {
+		names := make([]string, len(inv.Hosts))
+		for i, h := range inv.Hosts {
+			names[i] = h.Name
+		}
+		return names, nil
+	}
+	if visiting[group] {
+		return nil, fmt.Errorf("inventory group %q contains itself", group)
+	}
+	visiting[group] = true
+	defer delete(visiting, group)
+
+	found := false
+	var names []string
+	for _, h := range inv.Hosts {
+		for _, g := range h.Groups {
+			if g == group {
+				names = append(names, h.Name)
+				found = true
+			}
+		}
+	}
+	if entries, ok := inv.Groups[group]; ok {
+		found = true
+		for _, entry := range entries {
+			if _, ok := inv.Lookup(entry); ok {
+				names = append(names, entry)
+				continue
+			}
+			nested, err := inv.groupMembers(entry, visiting)
+			if err != nil {
+				return nil, err
+			}
+			names = append(names, nested...)
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("unknown inventory group %q (known: %s)", group, strings.Join(inv.GroupNames(), ", "))
+	}
+	return names, nil
+}
+
+// Config returns the connection settings for h, layered over base
+func (inv *Inventory) Config(base *types.Config, h InventoryHost) *types.Config {
+	cfg := *base
+	cfg.Tunnels = nil
+	cfg.Serve = nil
+	cfg.ActiveProfile = ""
+	cfg.Host = h.Host
+	cfg.MAC, cfg.MDNS = "", ""
+	for _, port := range []int{inv.Defaults.Port, h.Port} {
+		if port != 0 {
+			cfg.Port = port
+		}
+	}
+	for _, user := range []string{inv.Defaults.User, h.User} {
+		if user != "" {
+			cfg.User = user
+		}
+	}
+	for _, key := range []string{inv.Defaults.IdentityFile, h.IdentityFile} {
+		if key != "" {
+			cfg.IdentityFile = expandHome(key)
+		}
+	}
+	cfg.ReadOnly = cfg.ReadOnly || h.ReadOnly
+	return &cfg
+}
+
+func expandHome(p string) string {
+	if rest, ok := strings.CutPrefix(p, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return p
+}
+
+func hasAll(have, want []string) bool {
+	for _, w := range want {
+		if !slices.Contains(have, w) {
+			return false
+		}
+	}
+	return true
+}
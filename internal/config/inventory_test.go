@@ -0,0 +1,74 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+const testInventory = `defaults:
+  user: student
+hosts:
+  - name: spark-01
+    host: 10.0.4.11
+    groups: [lab]
+    tags: [building-4]
+  - name: spark-02
+    host: 10.0.4.12
+    groups: [lab]
+  - name: spark-prod
+    host: 10.0.9.1
+    user: ops
+    port: 2222
+    tags: [building-4]
+groups:
+  prod: [spark-prod]
+  everything: [lab, prod]
+`
+
+func TestInventorySelect(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts.yaml")
+	if err := os.WriteFile(path, []byte(testInventory), 0600); err != nil {
+		t.Fatal(err)
+	}
+	inv, err := LoadInventory(path)
+	if err != nil {
+		t.Fatalf("LoadInventory: %v", err)
+	}
+
+	names := func(groups, tags []string) []string {
+		hosts, err := inv.Select(groups, tags)
+		if err != nil {
+			t.Fatalf("Select(%v, %v): %v", groups, tags, err)
+		}
+		var out []string
+		for _, h := range hosts {
+			out = append(out, h.Name)
+		}
+		return out
+	}
+	if got := names([]string{"lab"}, nil); len(got) != 2 || got[0] != "spark-01" {
+		t.Fatalf("lab = %v", got)
+	}
+	if got := names([]string{"everything"}, []string{"building-4"}); len(got) != 2 || got[1] != "spark-prod" {
+		t.Fatalf("everything+building-4 = %v", got)
+	}
+	if got := names(nil, nil); len(got) != 3 {
+		t.Fatalf("all = %v", got)
+	}
+	if _, err := inv.Select([]string{"nope"}, nil); err == nil {
+		t.Fatalf("unknown group should fail")
+	}
+
+	prod, _ := inv.Lookup("spark-prod")
+	cfg := inv.Config(&types.Config{Host: "main", Port: 22, User: "me"}, prod)
+	if cfg.Host != "10.0.9.1" || cfg.Port != 2222 || cfg.User != "ops" {
+		t.Fatalf("Config(spark-prod) = %+v", cfg)
+	}
+	lab, _ := inv.Lookup("spark-02")
+	if cfg := inv.Config(&types.Config{Port: 22, User: "me"}, lab); cfg.User != "student" || cfg.Port != 22 {
+		t.Fatalf("Config(spark-02) = %+v", cfg)
+	}
+}
@@ -1,18 +1,17 @@
 package config
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
 )
 
 // NVSyncProfile captures connection details exported by the NVIDIA Sync app.
 type NVSyncProfile struct {
+	Alias        string
 	Host         string
 	User         string
 	Port         int
@@ -112,9 +111,9 @@ func parseNVSyncConfig(path string) ([]*NVSyncProfile, error) {
 	}
 	defer file.Close()
 
-	profiles, err := parseNVSyncProfileReader(file)
+	profiles, err := parseNVSyncProfiles(file, filepath.Dir(path))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%s: %w", path, err)
 	}
 	for _, profile := range profiles {
 		profile.ConfigPath = path
@@ -123,71 +122,23 @@ func parseNVSyncConfig(path string) ([]*NVSyncProfile, error) {
 }
 
 func parseNVSyncProfileReader(r io.Reader) ([]*NVSyncProfile, error) {
-	scanner := bufio.NewScanner(r)
-	var (
-		current  *NVSyncProfile
-		profiles []*NVSyncProfile
-	)
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-
-		fields := strings.Fields(line)
-		if len(fields) < 2 {
-			continue
-		}
-
-		key := strings.ToLower(fields[0])
-		value := strings.TrimSpace(strings.Join(fields[1:], " "))
-		value = strings.Trim(value, "\"'")
+	return parseNVSyncProfiles(r, "")
+}
 
-		switch key {
-		case "host":
-			if current != nil {
-				if finalized := finalizeNVSyncProfile(current); finalized != nil {
-					profiles = append(profiles, finalized)
-				}
-			}
-			alias := strings.Fields(value)
-			if len(alias) == 0 || strings.Contains(alias[0], "*") {
-				current = nil
-				continue
-			}
-			current = &NVSyncProfile{Host: alias[0], Port: 22}
-		case "hostname":
-			if current != nil {
-				current.Host = value
-			}
-		case "user":
-			if current != nil {
-				current.User = value
-			}
-		case "port":
-			if current != nil {
-				if p, err := strconv.Atoi(value); err == nil {
-					current.Port = p
-				}
-			}
-		case "identityfile":
-			if current != nil {
-				current.IdentityFile = normalizeNVSyncPath(value)
-			}
-		}
+// parseNVSyncProfiles returns a profile for every concrete Host alias with a user and an
+// existing identity file. Relative Include paths are resolved against dir.
+func parseNVSyncProfiles(r io.Reader, dir string) ([]*NVSyncProfile, error) {
+	cfg, err := parseSSHConfig(r, dir)
+	if err != nil {
+		return nil, err
 	}
 
-	if current != nil {
-		if finalized := finalizeNVSyncProfile(current); finalized != nil {
+	var profiles []*NVSyncProfile
+	for _, profile := range cfg.sshProfiles() {
+		if finalized := finalizeNVSyncProfile(profile); finalized != nil {
 			profiles = append(profiles, finalized)
 		}
 	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, err
-	}
-
 	return profiles, nil
 }
 
@@ -45,4 +45,191 @@ Host 192.168.0.10
 			t.Fatalf("unexpected identity %q", profile.IdentityFile)
 		}
 	})
+
+	t.Run("wildcard hosts supply defaults", func(t *testing.T) {
+		dir := t.TempDir()
+		keyPath := writeTestKey(t, dir, "spark.key")
+
+		config := fmt.Sprintf(`
+Host spark-*
+    User alice
+Host spark-one
+    HostName 10.0.0.1
+    Port 2200
+Host *
+    Port 22
+    IdentityFile %s
+`, keyPath)
+
+		profiles := parseProfiles(t, config, "")
+		if len(profiles) != 1 {
+			t.Fatalf("expected 1 profile, got %d", len(profiles))
+		}
+		p := profiles[0]
+		if p.Alias != "spark-one" || p.Host != "10.0.0.1" || p.User != "alice" || p.Port != 2200 || p.IdentityFile != keyPath {
+			t.Fatalf("unexpected profile %+v", p)
+		}
+	})
+
+	t.Run("multiple hostnames per Host line", func(t *testing.T) {
+		dir := t.TempDir()
+		keyPath := writeTestKey(t, dir, "spark.key")
+
+		config := fmt.Sprintf(`
+Host spark-a spark-b !spark-c
+    User alice
+    IdentityFile %s
+`, keyPath)
+
+		profiles := parseProfiles(t, config, "")
+		if len(profiles) != 2 || profiles[0].Alias != "spark-a" || profiles[1].Alias != "spark-b" {
+			t.Fatalf("unexpected profiles %+v", profiles)
+		}
+		if profiles[1].Host != "spark-b" || profiles[1].User != "alice" {
+			t.Fatalf("unexpected profile %+v", profiles[1])
+		}
+	})
+
+	t.Run("match blocks", func(t *testing.T) {
+		dir := t.TempDir()
+		keyPath := writeTestKey(t, dir, "spark.key")
+
+		config := fmt.Sprintf(`
+Host spark
+    HostName 192.168.1.20
+Host other
+    HostName 192.168.2.20
+Match host 192.168.1.* !originalhost other
+    Port 2222
+Match exec "true"
+    Port 9999
+Match all
+    User alice
+    IdentityFile %s
+`, keyPath)
+
+		profiles := parseProfiles(t, config, "")
+		if len(profiles) != 2 {
+			t.Fatalf("expected 2 profiles, got %d", len(profiles))
+		}
+		if profiles[0].Port != 2222 {
+			t.Fatalf("Match host should apply to spark, got port %d", profiles[0].Port)
+		}
+		if profiles[1].Port != 22 {
+			t.Fatalf("Match blocks should not apply to other, got port %d", profiles[1].Port)
+		}
+	})
+
+	t.Run("include directives", func(t *testing.T) {
+		dir := t.TempDir()
+		keyPath := writeTestKey(t, dir, "spark.key")
+		if err := os.MkdirAll(filepath.Join(dir, "conf.d"), 0700); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		included := fmt.Sprintf("Host spark-inc\n    HostName 10.0.0.7\n    User bob\n    IdentityFile %s\n", keyPath)
+		if err := os.WriteFile(filepath.Join(dir, "conf.d", "spark.conf"), []byte(included), 0600); err != nil {
+			t.Fatalf("write include: %v", err)
+		}
+
+		// Options after the Include still belong to spark-main
+		config := fmt.Sprintf(`
+Host spark-main
+    Include conf.d/*.conf
+    User alice
+    IdentityFile %s
+`, keyPath)
+		profiles := parseProfiles(t, config, dir)
+		if len(profiles) != 2 {
+			t.Fatalf("expected 2 profiles, got %+v", profiles)
+		}
+		if profiles[0].Alias != "spark-main" || profiles[0].User != "alice" {
+			t.Fatalf("unexpected profile %+v", profiles[0])
+		}
+		if profiles[1].Alias != "spark-inc" || profiles[1].Host != "10.0.0.7" || profiles[1].User != "bob" {
+			t.Fatalf("unexpected profile %+v", profiles[1])
+		}
+	})
+
+	t.Run("tokens in IdentityFile and HostName", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTestKey(t, dir, "dgx-10.0.0.5-2201-alice")
+
+		config := fmt.Sprintf(`
+Host dgx
+    HostName %%h.local
+    Port 2201
+    User alice
+    IdentityFile %s/%%n-%%h-%%p-%%r
+`, dir)
+		cfg, err := parseSSHConfig(strings.NewReader(config), "")
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		p := cfg.sshProfiles()[0]
+		if p.Host != "dgx.local" {
+			t.Fatalf("unexpected host %q", p.Host)
+		}
+		if want := filepath.Join(dir, "dgx-dgx.local-2201-alice"); p.IdentityFile != want {
+			t.Fatalf("unexpected identity %q, want %q", p.IdentityFile, want)
+		}
+	})
+
+	t.Run("quoted values with escapes", func(t *testing.T) {
+		dir := t.TempDir()
+		keyPath := writeTestKey(t, dir, `my "spark" key`)
+
+		config := fmt.Sprintf(`
+Host=spark
+    User = alice # trailing comment
+    IdentityFile "%s"
+`, strings.ReplaceAll(keyPath, `"`, `\"`))
+
+		profiles := parseProfiles(t, config, "")
+		if len(profiles) != 1 || profiles[0].IdentityFile != keyPath || profiles[0].User != "alice" {
+			t.Fatalf("unexpected profiles %+v", profiles)
+		}
+	})
+}
+
+func TestSplitSSHArgs(t *testing.T) {
+	cases := map[string][]string{
+		`a b`:             {"a", "b"},
+		`"a b" c`:         {"a b", "c"},
+		`'it"s' x`:        {`it"s`, "x"},
+		`a\ b`:            {"a b"},
+		`"say \"hi\""`:    {`say "hi"`},
+		`C:\Users\me\key`: {`C:\Users\me\key`},
+		`a #comment`:      {"a"},
+		`a#b`:             {"a#b"},
+	}
+	for in, want := range cases {
+		got, err := splitSSHArgs(in)
+		if err != nil {
+			t.Fatalf("split %q: %v", in, err)
+		}
+		if strings.Join(got, "|") != strings.Join(want, "|") {
+			t.Fatalf("split %q = %q, want %q", in, got, want)
+		}
+	}
+	if _, err := splitSSHArgs(`"open`); err == nil {
+		t.Fatalf("expected error for unterminated quote")
+	}
+}
+
+func writeTestKey(t *testing.T, dir, name string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("test"), 0600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	return path
+}
+
+func parseProfiles(t *testing.T, config, dir string) []*NVSyncProfile {
+	t.Helper()
+	profiles, err := parseNVSyncProfiles(strings.NewReader(config), dir)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return profiles
 }
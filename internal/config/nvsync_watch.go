@@ -0,0 +1,119 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// ProfileSourceNVSync marks profiles imported from NVIDIA Sync
+const ProfileSourceNVSync = "nvsync"
+
+// SyncNVSync imports NVIDIA Sync Host blocks as profiles. New and changed blocks update their
+// profile; imported profiles whose block disappeared are marked stale rather than deleted.
+// It reports whether any profile changed.
+func (m *Manager) SyncNVSync() (bool, error) {
+	fingerprint, err := nvSyncFingerprint()
+	if err != nil {
+		return false, err
+	}
+	found, err := detectNVSyncProfiles()
+	if err != nil {
+		return false, fmt.Errorf("failed to read NVIDIA Sync config: %w", err)
+	}
+
+	if m.config.Profiles == nil {
+		m.config.Profiles = make(map[string]types.Profile)
+	}
+	changed := mergeNVSyncProfiles(m.config.Profiles, found)
+
+	if m.config.NVSync == nil {
+		m.config.NVSync = &types.NVSyncImport{}
+	}
+	m.config.NVSync.Fingerprint = fingerprint
+	m.config.NVSync.ImportedAt = time.Now()
+	return changed, m.Save()
+}
+
+// RefreshNVSync re-imports NVIDIA Sync profiles when watching is enabled and a Sync config
+// file was created, modified or removed since the last import
+func (m *Manager) RefreshNVSync() (bool, error) {
+	watch := m.config.NVSync
+	if watch == nil || !watch.Watch {
+		return false, nil
+	}
+	fingerprint, err := nvSyncFingerprint()
+	if err != nil || fingerprint == watch.Fingerprint {
+		return false, err
+	}
+	return m.SyncNVSync()
+}
+
+// SetNVSyncWatch turns automatic re-import of NVIDIA Sync profiles on or off
+func (m *Manager) SetNVSyncWatch(enabled bool) error {
+	if m.config.NVSync == nil {
+		m.config.NVSync = &types.NVSyncImport{}
+	}
+	m.config.NVSync.Watch = enabled
+	return m.Save()
+}
+
+// mergeNVSyncProfiles applies detected Sync profiles to profiles, keyed by Host alias.
+// Aliases that collide with a hand-written profile are imported as "nvsync-<alias>".
+func mergeNVSyncProfiles(profiles map[string]types.Profile, found []*NVSyncProfile) bool {
+	changed := false
+	seen := make(map[string]bool)
+	for _, p := range found {
+		name := p.Alias
+		if existing, ok := profiles[name]; ok && existing.Source != ProfileSourceNVSync {
+			name = ProfileSourceNVSync + "-" + p.Alias
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		profile := types.Profile{
+			Host:         p.Host,
+			Port:         p.Port,
+			User:         p.User,
+			IdentityFile: p.IdentityFile,
+			Source:       ProfileSourceNVSync,
+		}
+		if !reflect.DeepEqual(profiles[name], profile) {
+			profiles[name] = profile
+			changed = true
+		}
+	}
+
+	for name, profile := range profiles {
+		if profile.Source == ProfileSourceNVSync && !seen[name] && !profile.Stale {
+			profile.Stale = true
+			profiles[name] = profile
+			changed = true
+		}
+	}
+	return changed
+}
+
+// nvSyncFingerprint identifies the current state of every NVIDIA Sync config file by path,
+// size and modification time
+func nvSyncFingerprint() (string, error) {
+	paths, err := nvSyncConfigPaths()
+	if err != nil {
+		return "", err
+	}
+	var parts []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s:%d:%d", path, info.Size(), info.ModTime().UnixNano()))
+	}
+	return strings.Join(parts, ";"), nil
+}
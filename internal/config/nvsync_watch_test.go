@@ -0,0 +1,41 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+func TestMergeNVSyncProfiles(t *testing.T) {
+	profiles := map[string]types.Profile{
+		"spark-a": {Host: "10.0.0.9", User: "me"},
+		"spark-b": {Host: "10.0.0.2", User: "alice", Port: 22, IdentityFile: "/k", Source: ProfileSourceNVSync},
+		"gone":    {Host: "10.0.0.3", User: "alice", Port: 22, IdentityFile: "/k", Source: ProfileSourceNVSync},
+	}
+	found := []*NVSyncProfile{
+		{Alias: "spark-a", Host: "10.0.0.1", User: "alice", Port: 22, IdentityFile: "/k"},
+		{Alias: "spark-b", Host: "10.0.0.2", User: "alice", Port: 22, IdentityFile: "/k"},
+	}
+
+	if !mergeNVSyncProfiles(profiles, found) {
+		t.Fatalf("expected a change")
+	}
+	if profiles["spark-a"].Source != "" {
+		t.Fatalf("hand-written profile was overwritten: %+v", profiles["spark-a"])
+	}
+	if p := profiles["nvsync-spark-a"]; p.Host != "10.0.0.1" || p.Source != ProfileSourceNVSync {
+		t.Fatalf("colliding alias not imported under prefix: %+v", p)
+	}
+	if !profiles["gone"].Stale || profiles["spark-b"].Stale {
+		t.Fatalf("unexpected stale flags: %+v", profiles)
+	}
+
+	if mergeNVSyncProfiles(profiles, found) {
+		t.Fatalf("second merge should be a no-op")
+	}
+
+	found = append(found, &NVSyncProfile{Alias: "gone", Host: "10.0.0.3", User: "alice", Port: 22, IdentityFile: "/k"})
+	if !mergeNVSyncProfiles(profiles, found) || profiles["gone"].Stale {
+		t.Fatalf("returning block should clear the stale flag: %+v", profiles["gone"])
+	}
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// DefaultPresetName refers to the config's defaults block in preset commands
+const DefaultPresetName = "default"
+
+var presetNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ValidPresetName reports whether name can be used for a preset
+func ValidPresetName(name string) bool {
+	return presetNamePattern.MatchString(name) && name != DefaultPresetName
+}
+
+// PresetNames returns the configured preset names in sorted order
+func PresetNames(cfg *types.Config) []string {
+	names := make([]string, 0, len(cfg.Presets))
+	for name := range cfg.Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ResolvePreset returns the settings chat-style commands start from: the defaults block
+// overlaid with the named preset, or with the active preset when name is empty. Fields the
+// preset leaves empty keep their default.
+func ResolvePreset(cfg *types.Config, name string) (types.Preset, error) {
+	var p types.Preset
+	if cfg.Defaults != nil {
+		p = *cfg.Defaults
+	}
+	if name == "" {
+		name = cfg.ActivePreset
+	}
+	if name == "" || name == DefaultPresetName {
+		return p, nil
+	}
+	preset, ok := cfg.Presets[name]
+	if !ok {
+		return p, fmt.Errorf("unknown preset %q", name)
+	}
+	if preset.Model != "" {
+		p.Model = preset.Model
+	}
+	if preset.System != "" {
+		p.System = preset.System
+	}
+	if preset.Temperature != nil {
+		p.Temperature = preset.Temperature
+	}
+	return p, nil
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+func TestResolvePreset(t *testing.T) {
+	warm, cold := 0.9, 0.1
+	cfg := &types.Config{
+		Defaults: &types.Preset{Model: "ai/llama3.3:70B-Q4_K_M", System: "Be brief.", Temperature: &warm},
+		Presets: map[string]types.Preset{
+			"code":  {Model: "ai/qwen2.5-coder", Temperature: &cold},
+			"haiku": {System: "Answer in haiku."},
+		},
+		ActivePreset: "haiku",
+	}
+
+	p, err := ResolvePreset(cfg, "")
+	if err != nil || p.Model != "ai/llama3.3:70B-Q4_K_M" || p.System != "Answer in haiku." || *p.Temperature != warm {
+		t.Fatalf("active preset = %+v, %v", p, err)
+	}
+	p, err = ResolvePreset(cfg, "code")
+	if err != nil || p.Model != "ai/qwen2.5-coder" || p.System != "Be brief." || *p.Temperature != cold {
+		t.Fatalf("named preset = %+v, %v", p, err)
+	}
+	p, err = ResolvePreset(cfg, DefaultPresetName)
+	if err != nil || p.System != "Be brief." {
+		t.Fatalf("default preset = %+v, %v", p, err)
+	}
+	if _, err := ResolvePreset(cfg, "missing"); err == nil {
+		t.Fatalf("unknown preset: expected an error")
+	}
+	if p, err := ResolvePreset(&types.Config{}, ""); err != nil || p != (types.Preset{}) {
+		t.Fatalf("empty config = %+v, %v", p, err)
+	}
+}
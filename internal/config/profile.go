@@ -0,0 +1,300 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/weatherman/dgx-manager/internal/pins"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// Sources of effective settings, from lowest to highest precedence
+const (
+	SourceDefault = "default"
+	SourceNVSync  = "nvsync"
+	SourceConfig  = "config file"
+	SourceProfile = "profile"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// Environment variables that override the config file
+const (
+	EnvProfile         = "DGX_PROFILE"
+	EnvHost            = "DGX_HOST"
+	EnvPort            = "DGX_PORT"
+	EnvUser            = "DGX_USER"
+	EnvIdentityFile    = "DGX_IDENTITY_FILE"
+	EnvCertificateFile = "DGX_CERTIFICATE_FILE"
+	EnvPlaybookRetries = "DGX_PLAYBOOK_RETRIES"
+	EnvConfig          = "DGX_CONFIG"
+	EnvReadOnly        = "DGX_READONLY"
+)
+
+// Overrides select a profile and replace connection settings for a single invocation
+type Overrides struct {
+	Profile      string
+	Host         string
+	Port         int
+	User         string
+	IdentityFile string
+	ReadOnly     bool
+}
+
+// Setting is one resolved connection setting and where its value came from
+type Setting struct {
+	Name   string
+	Value  string
+	Source string
+}
+
+// Apply resolves the effective configuration returned by Get from the config file, DGX_*
+// environment variables and o. The config file on disk is never modified by overrides.
+func (m *Manager) Apply(o Overrides) error {
+	cfg, settings, err := resolve(m.config, o, os.Getenv, DetectNVSyncProfile)
+	if err != nil {
+		return err
+	}
+	m.overrides = &o
+	m.effective = cfg
+	m.settings = settings
+	return nil
+}
+
+// Settings returns the effective connection settings with their sources
+func (m *Manager) Settings() []Setting {
+	if m.settings == nil {
+		_, settings, _ := resolve(m.config, Overrides{}, os.Getenv, DetectNVSyncProfile)
+		return settings
+	}
+	return m.settings
+}
+
+// File returns the configuration as stored on disk, without profiles or overrides applied
+func (m *Manager) File() *types.Config {
+	return m.config
+}
+
+// ProfileNames returns the configured profile names in sorted order
+func (m *Manager) ProfileNames() []string {
+	names := make([]string, 0, len(m.config.Profiles))
+	for name := range m.config.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ProfileConfig returns the connection settings of the named profile, layered over the
+// config file as --profile would but without environment or flag overrides
+func (m *Manager) ProfileConfig(name string) (*types.Config, error) {
+	cfg, _, err := resolve(m.config, Overrides{Profile: name}, nil, DetectNVSyncProfile)
+	return cfg, err
+}
+
+// refresh re-applies the last overrides after the file config changed
+func (m *Manager) refresh() {
+	if m.overrides == nil {
+		return
+	}
+	if cfg, settings, err := resolve(m.config, *m.overrides, os.Getenv, DetectNVSyncProfile); err == nil {
+		m.effective = cfg
+		m.settings = settings
+	}
+}
+
+// resolve layers nvsync detection, the config file, the selected profile, the environment
+// and flag overrides, lowest precedence first. NVIDIA Sync only supplies the connection when
+// the config file has no host. A nil getenv ignores the environment.
+func resolve(file *types.Config, o Overrides, getenv func(string) string, detect func() (*NVSyncProfile, error)) (*types.Config, []Setting, error) {
+	if getenv == nil {
+		getenv = func(string) string { return "" }
+	}
+	cfg := *file
+	sources := map[string]string{
+		"host":          SourceConfig,
+		"port":          SourceConfig,
+		"user":          SourceConfig,
+		"identity_file": SourceConfig,
+	}
+	certificateSource := SourceConfig
+
+	if cfg.Host == "" && detect != nil {
+		if p, err := detect(); err == nil && p != nil {
+			source := SourceNVSync + " (" + p.ConfigPath + ")"
+			cfg.Host, cfg.Port, cfg.User, cfg.IdentityFile = p.Host, p.Port, p.User, p.IdentityFile
+			for name := range sources {
+				sources[name] = source
+			}
+		}
+	}
+	sources["confirm"] = SourceConfig
+	if cfg.Confirm == "" {
+		sources["confirm"] = SourceDefault
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 22
+		sources["port"] = SourceDefault
+	}
+
+	readOnlySource := SourceDefault
+	if cfg.ReadOnly {
+		readOnlySource = SourceConfig
+	}
+	execAllowSource := SourceDefault
+	if len(cfg.ExecAllow) > 0 {
+		execAllowSource = SourceConfig
+	}
+	sshSource := SourceDefault
+	if cfg.SSH != nil {
+		sshSource = SourceConfig
+	}
+	pinsSource := SourceDefault
+	if cfg.Pins != nil {
+		pinsSource = SourceConfig
+	}
+
+	name, nameSource := file.ActiveProfile, SourceConfig
+	if v := getenv(EnvProfile); v != "" {
+		name, nameSource = v, SourceEnv+" "+EnvProfile
+	}
+	if o.Profile != "" {
+		name, nameSource = o.Profile, SourceFlag+" --profile"
+	}
+	if name != "" {
+		p, ok := file.Profiles[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown profile %q", name)
+		}
+		source := SourceProfile + " " + name
+		applyString(&cfg.Host, p.Host, sources, "host", source)
+		applyPort(&cfg.Port, p.Port, sources, source)
+		applyString(&cfg.User, p.User, sources, "user", source)
+		applyString(&cfg.IdentityFile, p.IdentityFile, sources, "identity_file", source)
+		// A certificate belongs to one key, so a profile with its own key does not inherit it
+		if p.IdentityFile != "" || p.CertificateFile != "" {
+			cfg.CertificateFile, certificateSource = p.CertificateFile, source
+		}
+		applyString(&cfg.Confirm, p.Confirm, sources, "confirm", source)
+		// A profile with its own host is a different machine, so the top-level MAC and mDNS
+		// name do not carry over
+		if p.Host != "" || p.MAC != "" {
+			cfg.MAC = p.MAC
+		}
+		if p.Host != "" || p.MDNS != "" {
+			cfg.MDNS = p.MDNS
+		}
+		if p.ReadOnly && !cfg.ReadOnly {
+			cfg.ReadOnly = true
+			readOnlySource = source
+		}
+		if len(p.ExecAllow) > 0 {
+			cfg.ExecAllow = p.ExecAllow
+			execAllowSource = source
+		}
+		if p.SSH != nil {
+			cfg.SSH = ssh.MergeOptions(file.SSH, p.SSH)
+			sshSource = source
+		}
+		if p.Pins != nil {
+			cfg.Pins = pins.Merge(file.Pins, p.Pins)
+			pinsSource = source
+		}
+	}
+	cfg.ActiveProfile = name
+
+	envPort := 0
+	if v := getenv(EnvPort); v != "" {
+		p, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid %s %q: %w", EnvPort, v, err)
+		}
+		envPort = p
+	}
+	applyString(&cfg.Host, getenv(EnvHost), sources, "host", SourceEnv+" "+EnvHost)
+	applyPort(&cfg.Port, envPort, sources, SourceEnv+" "+EnvPort)
+	applyString(&cfg.User, getenv(EnvUser), sources, "user", SourceEnv+" "+EnvUser)
+	if v := getenv(EnvIdentityFile); v != "" {
+		cfg.IdentityFile, sources["identity_file"] = v, SourceEnv+" "+EnvIdentityFile
+		cfg.CertificateFile, certificateSource = "", SourceEnv+" "+EnvIdentityFile
+	}
+	if v := getenv(EnvCertificateFile); v != "" {
+		cfg.CertificateFile, certificateSource = v, SourceEnv+" "+EnvCertificateFile
+	}
+
+	retriesValue, retriesSource := "", SourceDefault
+	if cfg.PlaybookRetries != nil {
+		retriesValue, retriesSource = strconv.Itoa(*cfg.PlaybookRetries), SourceConfig
+	}
+	if v := getenv(EnvPlaybookRetries); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, nil, fmt.Errorf("invalid %s %q: want a non-negative integer", EnvPlaybookRetries, v)
+		}
+		cfg.PlaybookRetries = &n
+		retriesValue, retriesSource = v, SourceEnv+" "+EnvPlaybookRetries
+	}
+
+	applyString(&cfg.Host, o.Host, sources, "host", SourceFlag+" --host")
+	applyPort(&cfg.Port, o.Port, sources, SourceFlag+" --ssh-port")
+	applyString(&cfg.User, o.User, sources, "user", SourceFlag+" --user")
+	if o.IdentityFile != "" {
+		cfg.IdentityFile, sources["identity_file"] = o.IdentityFile, SourceFlag+" --identity-file"
+		cfg.CertificateFile, certificateSource = "", SourceFlag+" --identity-file"
+	}
+	if v := getenv(EnvReadOnly); (v == "1" || v == "true") && !cfg.ReadOnly {
+		cfg.ReadOnly = true
+		readOnlySource = SourceEnv + " " + EnvReadOnly
+	}
+	if o.ReadOnly && !cfg.ReadOnly {
+		cfg.ReadOnly = true
+		readOnlySource = SourceFlag + " --readonly"
+	}
+
+	profileValue := name
+	if name == "" {
+		profileValue, nameSource = "(none)", SourceDefault
+	}
+	settings := []Setting{
+		{Name: "profile", Value: profileValue, Source: nameSource},
+		{Name: "host", Value: cfg.Host, Source: sources["host"]},
+		{Name: "port", Value: strconv.Itoa(cfg.Port), Source: sources["port"]},
+		{Name: "user", Value: cfg.User, Source: sources["user"]},
+		{Name: "identity_file", Value: cfg.IdentityFile, Source: sources["identity_file"]},
+		{Name: "certificate_file", Value: cfg.CertificateFile, Source: certificateSource},
+		{Name: "playbook_retries", Value: retriesValue, Source: retriesSource},
+		{Name: "confirm", Value: cfg.Confirm, Source: sources["confirm"]},
+		{Name: "ssh", Value: sshSetting(cfg.SSH), Source: sshSource},
+		{Name: "pins", Value: pins.Describe(cfg.Pins), Source: pinsSource},
+		{Name: "exec_allow", Value: strings.Join(cfg.ExecAllow, "; "), Source: execAllowSource},
+		{Name: "readonly", Value: strconv.FormatBool(cfg.ReadOnly), Source: readOnlySource},
+	}
+	return &cfg, settings, nil
+}
+
+// sshSetting shows the ssh options as the ssh_config keywords they stand for
+func sshSetting(o *types.SSHOptions) string {
+	var parts []string
+	for _, opt := range ssh.ConfigOptions(o) {
+		parts = append(parts, opt[0]+"="+opt[1])
+	}
+	return strings.Join(parts, "; ")
+}
+
+func applyString(dst *string, value string, sources map[string]string, name, source string) {
+	if value != "" {
+		*dst = value
+		sources[name] = source
+	}
+}
+
+func applyPort(dst *int, value int, sources map[string]string, source string) {
+	if value != 0 {
+		*dst = value
+		sources["port"] = source
+	}
+}
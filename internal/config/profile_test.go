@@ -0,0 +1,194 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+func TestResolve(t *testing.T) {
+	file := &types.Config{
+		Host:         "spark.local",
+		Port:         22,
+		User:         "alice",
+		IdentityFile: "/keys/id",
+		Profiles: map[string]types.Profile{
+			"ci":  {Host: "10.0.0.5", Port: 2222},
+			"lab": {Host: "lab.local", ReadOnly: true, Confirm: "typed", ExecAllow: []string{"nvidia-smi ..."}},
+		},
+	}
+	noDetect := func() (*NVSyncProfile, error) { return nil, nil }
+
+	t.Run("profile overrides file and inherits the rest", func(t *testing.T) {
+		cfg, settings, err := resolve(file, Overrides{Profile: "ci"}, nil, noDetect)
+		if err != nil {
+			t.Fatalf("resolve: %v", err)
+		}
+		if cfg.Host != "10.0.0.5" || cfg.Port != 2222 || cfg.User != "alice" {
+			t.Fatalf("unexpected config %+v", cfg)
+		}
+		if settings[1].Source != "profile ci" || settings[3].Source != SourceConfig {
+			t.Fatalf("unexpected sources %+v", settings)
+		}
+	})
+
+	t.Run("flags beat the profile", func(t *testing.T) {
+		cfg, settings, err := resolve(file, Overrides{Profile: "ci", Host: "other"}, nil, noDetect)
+		if err != nil {
+			t.Fatalf("resolve: %v", err)
+		}
+		if cfg.Host != "other" || settings[1].Source != "flag --host" {
+			t.Fatalf("unexpected host %q from %q", cfg.Host, settings[1].Source)
+		}
+	})
+
+	t.Run("safety settings come from the profile and --readonly", func(t *testing.T) {
+		cfg, settings, err := resolve(file, Overrides{Profile: "lab"}, nil, noDetect)
+		if err != nil {
+			t.Fatalf("resolve: %v", err)
+		}
+		if !cfg.ReadOnly || settings[len(settings)-1].Source != "profile lab" {
+			t.Fatalf("lab profile: readonly %v from %+v", cfg.ReadOnly, settings[len(settings)-1])
+		}
+		if cfg.Confirm != "typed" {
+			t.Fatalf("lab profile: confirm %q, want typed", cfg.Confirm)
+		}
+		if len(cfg.ExecAllow) != 1 || cfg.ExecAllow[0] != "nvidia-smi ..." {
+			t.Fatalf("lab profile: exec_allow %v", cfg.ExecAllow)
+		}
+		if cfg, _, _ := resolve(file, Overrides{ReadOnly: true}, nil, noDetect); !cfg.ReadOnly {
+			t.Fatalf("--readonly was not applied")
+		}
+		if cfg, _, _ := resolve(file, Overrides{Profile: "ci"}, nil, noDetect); cfg.ReadOnly {
+			t.Fatalf("ci profile should not be read-only")
+		}
+	})
+
+	t.Run("profile ssh options layer over the file's", func(t *testing.T) {
+		off := false
+		file := &types.Config{
+			Host: "spark.local",
+			SSH:  &types.SSHOptions{Ciphers: []string{"aes128-gcm@openssh.com"}, DialTimeout: 5 * time.Second},
+			Profiles: map[string]types.Profile{
+				"vpn": {SSH: &types.SSHOptions{Compression: &off, DialTimeout: 30 * time.Second}},
+			},
+		}
+		cfg, _, err := resolve(file, Overrides{Profile: "vpn"}, nil, noDetect)
+		if err != nil {
+			t.Fatalf("resolve: %v", err)
+		}
+		if len(cfg.SSH.Ciphers) != 1 || cfg.SSH.Compression == nil || *cfg.SSH.Compression || cfg.SSH.DialTimeout != 30*time.Second {
+			t.Fatalf("unexpected ssh options %+v", cfg.SSH)
+		}
+		if file.SSH.DialTimeout != 5*time.Second || file.SSH.Compression != nil {
+			t.Fatalf("file options were modified: %+v", file.SSH)
+		}
+	})
+
+	t.Run("env sits between profile and flags", func(t *testing.T) {
+		env := map[string]string{EnvProfile: "ci", EnvUser: "runner", EnvPort: "2200"}
+		getenv := func(k string) string { return env[k] }
+
+		cfg, settings, err := resolve(file, Overrides{}, getenv, noDetect)
+		if err != nil {
+			t.Fatalf("resolve: %v", err)
+		}
+		if cfg.Host != "10.0.0.5" || cfg.User != "runner" || cfg.Port != 2200 {
+			t.Fatalf("unexpected config %+v", cfg)
+		}
+		if settings[0].Source != "env DGX_PROFILE" || settings[3].Source != "env DGX_USER" {
+			t.Fatalf("unexpected sources %+v", settings)
+		}
+
+		cfg, _, err = resolve(file, Overrides{User: "flag"}, getenv, noDetect)
+		if err != nil {
+			t.Fatalf("resolve: %v", err)
+		}
+		if cfg.User != "flag" {
+			t.Fatalf("flag should beat env, got %q", cfg.User)
+		}
+
+		env[EnvPort] = "abc"
+		if _, _, err := resolve(file, Overrides{}, getenv, noDetect); err == nil {
+			t.Fatalf("expected error for invalid port")
+		}
+	})
+
+	t.Run("unknown profile", func(t *testing.T) {
+		if _, _, err := resolve(file, Overrides{Profile: "missing"}, nil, noDetect); err == nil {
+			t.Fatalf("expected error")
+		}
+	})
+
+	t.Run("nvsync fills an empty host", func(t *testing.T) {
+		detect := func() (*NVSyncProfile, error) {
+			return &NVSyncProfile{Host: "192.168.0.10", User: "bob", Port: 22, IdentityFile: "/k", ConfigPath: "/sync"}, nil
+		}
+		cfg, settings, err := resolve(&types.Config{}, Overrides{}, nil, detect)
+		if err != nil {
+			t.Fatalf("resolve: %v", err)
+		}
+		if cfg.Host != "192.168.0.10" || cfg.User != "bob" {
+			t.Fatalf("unexpected config %+v", cfg)
+		}
+		if !strings.HasPrefix(settings[1].Source, SourceNVSync) {
+			t.Fatalf("unexpected source %q", settings[1].Source)
+		}
+	})
+}
+
+func TestValidateConnection(t *testing.T) {
+	orig := lookupHost
+	t.Cleanup(func() { lookupHost = orig })
+	lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		if host == "spark.local" {
+			return []string{"192.168.0.10"}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	dir := t.TempDir()
+	goodKey := filepath.Join(dir, "good")
+	openKey := filepath.Join(dir, "open")
+	if err := os.WriteFile(goodKey, []byte("key"), 0600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	if err := os.WriteFile(openKey, []byte("key"), 0644); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		cfg := &types.Config{Host: "spark.local", Port: 22, User: "alice", IdentityFile: goodKey}
+		if problems := ValidateConnection(context.Background(), cfg); len(problems) != 0 {
+			t.Fatalf("unexpected problems %v", problems)
+		}
+	})
+
+	t.Run("reports every problem", func(t *testing.T) {
+		cfg := &types.Config{Host: "nowhere", Port: 70000, User: "alice", IdentityFile: openKey}
+		problems := ValidateConnection(context.Background(), cfg)
+		if len(problems) != 3 {
+			t.Fatalf("expected 3 problems, got %v", problems)
+		}
+		if !strings.Contains(problems[2], "0644") {
+			t.Fatalf("expected permission problem, got %q", problems[2])
+		}
+	})
+
+	t.Run("no key falls back to a password", func(t *testing.T) {
+		cfg := &types.Config{Host: "spark.local", Port: 22, User: "alice"}
+		if problems := ValidateConnection(context.Background(), cfg); len(problems) != 0 {
+			t.Fatalf("a missing identity file should not be a problem, got %v", problems)
+		}
+		cfg.IdentityFile = filepath.Join(dir, "missing")
+		if problems := ValidateConnection(context.Background(), cfg); len(problems) != 1 {
+			t.Fatalf("a configured key that does not exist should be reported, got %v", problems)
+		}
+	})
+}
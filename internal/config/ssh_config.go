@@ -0,0 +1,376 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// sshConfigMaxDepth bounds nested Include directives, as in OpenSSH
+const sshConfigMaxDepth = 16
+
+// sshBlock is one Host or Match section with its options in file order
+type sshBlock struct {
+	hosts   []string // Host patterns
+	match   []string // Match criteria; set for Match sections
+	isMatch bool
+	options []sshOption
+}
+
+type sshOption struct {
+	key  string
+	args []string
+}
+
+// sshConfig is a parsed ssh_config file with its Includes expanded in place
+type sshConfig struct {
+	blocks []*sshBlock
+}
+
+// parseSSHConfig reads an ssh_config. Relative Include paths are resolved against dir.
+func parseSSHConfig(r io.Reader, dir string) (*sshConfig, error) {
+	global := &sshBlock{hosts: []string{"*"}}
+	c := &sshConfig{blocks: []*sshBlock{global}}
+	if _, err := c.parse(r, dir, 0, global); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// parse appends the sections in r, starting inside current, and returns the section that is
+// open at the end of the input
+func (c *sshConfig) parse(r io.Reader, dir string, depth int, current *sshBlock) (*sshBlock, error) {
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		key, args, err := splitSSHConfigLine(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+
+		switch key {
+		case "":
+			continue
+		case "host":
+			current = &sshBlock{hosts: args}
+			c.blocks = append(c.blocks, current)
+		case "match":
+			current = &sshBlock{match: args, isMatch: true}
+			c.blocks = append(c.blocks, current)
+		case "include":
+			if depth >= sshConfigMaxDepth {
+				return nil, fmt.Errorf("line %d: Include nested more than %d levels", lineNo, sshConfigMaxDepth)
+			}
+			for _, pattern := range args {
+				if current, err = c.include(pattern, dir, depth+1, current); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			current.options = append(current.options, sshOption{key: key, args: args})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return current, nil
+}
+
+// include parses every file matching pattern inside the including section. Sections opened
+// by an included file end with it, so the including section resumes afterwards.
+func (c *sshConfig) include(pattern, dir string, depth int, current *sshBlock) (*sshBlock, error) {
+	pattern = normalizeNVSyncPath(pattern)
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(dir, pattern)
+	}
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Include pattern %q: %w", pattern, err)
+	}
+
+	for _, path := range paths {
+		if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		last, err := c.parse(file, filepath.Dir(path), depth, current)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if last != current {
+			current = &sshBlock{hosts: current.hosts, match: current.match, isMatch: current.isMatch}
+			c.blocks = append(c.blocks, current)
+		}
+	}
+	return current, nil
+}
+
+// aliases returns the concrete names from Host lines, skipping wildcard and negated patterns
+func (c *sshConfig) aliases() []string {
+	var aliases []string
+	seen := make(map[string]bool)
+	for _, b := range c.blocks {
+		if b.isMatch {
+			continue
+		}
+		for _, h := range b.hosts {
+			if strings.ContainsAny(h, "*?!") || seen[h] {
+				continue
+			}
+			seen[h] = true
+			aliases = append(aliases, h)
+		}
+	}
+	return aliases
+}
+
+// resolve applies every matching section to alias in file order. As in ssh(1), the first
+// value obtained for an option wins.
+func (c *sshConfig) resolve(alias string) map[string][]string {
+	opts := make(map[string][]string)
+	for _, b := range c.blocks {
+		if !b.matches(alias, opts) {
+			continue
+		}
+		for _, o := range b.options {
+			if _, ok := opts[o.key]; !ok {
+				opts[o.key] = o.args
+			}
+		}
+	}
+	return opts
+}
+
+// matches evaluates a section for alias given the options resolved so far. Match criteria
+// that would need to run commands or inspect the network (exec, localnetwork, canonical,
+// final, tagged) never match, since detection must not have side effects.
+func (b *sshBlock) matches(alias string, opts map[string][]string) bool {
+	if !b.isMatch {
+		return matchSSHPatternList(b.hosts, alias)
+	}
+
+	for i := 0; i < len(b.match); i++ {
+		criterion := strings.ToLower(b.match[i])
+		negate := strings.HasPrefix(criterion, "!")
+		criterion = strings.TrimPrefix(criterion, "!")
+
+		var ok bool
+		switch criterion {
+		case "all":
+			ok = true
+		case "host", "originalhost", "user", "localuser":
+			if i+1 >= len(b.match) {
+				return false
+			}
+			i++
+			target := alias
+			switch criterion {
+			case "host":
+				if v := firstArg(opts["hostname"]); v != "" {
+					target = expandSSHTokens(v, map[byte]string{'h': alias, '%': "%"})
+				}
+			case "user":
+				target = firstArg(opts["user"])
+				if target == "" {
+					target = localUsername()
+				}
+			case "localuser":
+				target = localUsername()
+			}
+			ok = matchSSHPatternList(strings.Split(b.match[i], ","), target)
+		default:
+			return false
+		}
+		if ok == negate {
+			return false
+		}
+	}
+	return true
+}
+
+// sshProfiles resolves every concrete Host alias into a connection profile
+func (c *sshConfig) sshProfiles() []*NVSyncProfile {
+	home, _ := os.UserHomeDir()
+	var profiles []*NVSyncProfile
+	for _, alias := range c.aliases() {
+		opts := c.resolve(alias)
+		profile := &NVSyncProfile{Alias: alias, Host: alias, Port: 22}
+		if v := firstArg(opts["hostname"]); v != "" {
+			profile.Host = expandSSHTokens(v, map[byte]string{'h': alias, '%': "%"})
+		}
+		profile.User = firstArg(opts["user"])
+		if p, err := strconv.Atoi(firstArg(opts["port"])); err == nil {
+			profile.Port = p
+		}
+		if v := firstArg(opts["identityfile"]); v != "" {
+			profile.IdentityFile = expandSSHTokens(v, map[byte]string{
+				'd': home,
+				'h': profile.Host,
+				'n': alias,
+				'p': strconv.Itoa(profile.Port),
+				'r': profile.User,
+				'u': localUsername(),
+				'%': "%",
+			})
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles
+}
+
+// splitSSHConfigLine splits a line into a lower-cased keyword and its arguments. Keywords
+// may be separated from their arguments by whitespace or a single '='.
+func splitSSHConfigLine(line string) (string, []string, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil, nil
+	}
+
+	end := strings.IndexAny(line, " \t=")
+	if end < 0 {
+		return strings.ToLower(line), nil, nil
+	}
+	key := strings.ToLower(line[:end])
+	rest := strings.TrimLeft(line[end:], " \t")
+	if strings.HasPrefix(rest, "=") {
+		rest = strings.TrimLeft(rest[1:], " \t")
+	}
+
+	args, err := splitSSHArgs(rest)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", key, err)
+	}
+	return key, args, nil
+}
+
+// splitSSHArgs splits arguments the way OpenSSH does: single or double quotes group words,
+// a backslash escapes a quote, a backslash or (outside quotes) a space, and an unquoted '#'
+// at the start of a word begins a comment
+func splitSSHArgs(s string) ([]string, error) {
+	var (
+		args  []string
+		arg   strings.Builder
+		inArg bool
+		quote byte
+	)
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch == '\\' && i+1 < len(s) && (s[i+1] == '\\' || s[i+1] == '"' || s[i+1] == '\'' || (quote == 0 && s[i+1] == ' ')):
+			i++
+			arg.WriteByte(s[i])
+			inArg = true
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			} else {
+				arg.WriteByte(ch)
+			}
+		case ch == ' ' || ch == '\t':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		case ch == '#' && !inArg:
+			return args, nil
+		case ch == '"' || ch == '\'':
+			quote = ch
+			inArg = true
+		default:
+			arg.WriteByte(ch)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// matchSSHPatternList reports whether s matches the pattern list. A matching negated
+// pattern ("!name") rejects s even if another pattern matches.
+func matchSSHPatternList(patterns []string, s string) bool {
+	matched := false
+	for _, p := range patterns {
+		if strings.HasPrefix(p, "!") {
+			if matchSSHPattern(p[1:], s) {
+				return false
+			}
+			continue
+		}
+		if matchSSHPattern(p, s) {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// matchSSHPattern matches s against a pattern with '*' and '?' wildcards, ignoring case
+func matchSSHPattern(pattern, s string) bool {
+	pattern, s = strings.ToLower(pattern), strings.ToLower(s)
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(s); i >= 0; i-- {
+				if matchSSHPattern(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if s == "" {
+				return false
+			}
+		default:
+			if s == "" || s[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return s == ""
+}
+
+// expandSSHTokens replaces %x tokens; unknown tokens are left as written
+func expandSSHTokens(s string, tokens map[byte]string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+1 < len(s) {
+			if v, ok := tokens[s[i+1]]; ok {
+				b.WriteString(v)
+				i++
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func firstArg(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
+
+func localUsername() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SSHHostEntry is a Host block that dgx keeps in the user's ssh_config so tools that read
+// it, such as VS Code Remote-SSH, reach the DGX with the profile's settings
+type SSHHostEntry struct {
+	Alias           string
+	HostName        string
+	User            string
+	Port            int
+	IdentityFile    string
+	CertificateFile string
+	// Options are further ssh_config keywords and values, such as Ciphers
+	Options [][2]string
+}
+
+// DefaultSSHConfigPath returns ~/.ssh/config
+func DefaultSSHConfigPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".ssh", "config"), nil
+}
+
+func sshEntryMarkers(alias string) (string, string) {
+	return "# BEGIN dgx " + alias, "# END dgx " + alias
+}
+
+// render formats the entry as a marked block; the markers let later runs replace it
+func (e SSHHostEntry) render() string {
+	begin, end := sshEntryMarkers(e.Alias)
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (managed by dgx; edits are overwritten)\n", begin)
+	fmt.Fprintf(&b, "Host %s\n", e.Alias)
+	fmt.Fprintf(&b, "    HostName %s\n", e.HostName)
+	if e.User != "" {
+		fmt.Fprintf(&b, "    User %s\n", e.User)
+	}
+	if e.Port != 0 && e.Port != 22 {
+		fmt.Fprintf(&b, "    Port %d\n", e.Port)
+	}
+	if e.IdentityFile != "" {
+		fmt.Fprintf(&b, "    IdentityFile %s\n", strconv.Quote(e.IdentityFile))
+		b.WriteString("    IdentitiesOnly yes\n")
+	}
+	if e.CertificateFile != "" {
+		fmt.Fprintf(&b, "    CertificateFile %s\n", strconv.Quote(e.CertificateFile))
+	}
+	keepAlive := true
+	for _, opt := range e.Options {
+		fmt.Fprintf(&b, "    %s %s\n", opt[0], opt[1])
+		if opt[0] == "ServerAliveInterval" {
+			keepAlive = false
+		}
+	}
+	if keepAlive {
+		b.WriteString("    ServerAliveInterval 30\n")
+	}
+	b.WriteString(end + "\n")
+	return b.String()
+}
+
+// UpsertSSHHost writes entry into the ssh_config at path, replacing the block a previous
+// run wrote for the same alias or appending a new one. It refuses to shadow a Host block
+// the user wrote by hand and reports whether the file changed.
+func UpsertSSHHost(path string, entry SSHHostEntry) (bool, error) {
+	if entry.Alias == "" || strings.ContainsAny(entry.Alias, " \t*?!#") {
+		return false, fmt.Errorf("invalid ssh host alias %q", entry.Alias)
+	}
+	if entry.HostName == "" || strings.ContainsAny(entry.HostName, " \t\n") {
+		return false, fmt.Errorf("invalid host %q", entry.HostName)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	content := string(data)
+	block := entry.render()
+	begin, end := sshEntryMarkers(entry.Alias)
+
+	var updated string
+	start := strings.Index(content, begin)
+	if start >= 0 {
+		stop := strings.Index(content[start:], end)
+		if stop < 0 {
+			return false, fmt.Errorf("%s has %q without a matching %q; fix it by hand", path, begin, end)
+		}
+		stop += start + len(end)
+		if stop < len(content) && content[stop] == '\n' {
+			stop++
+		}
+		updated = content[:start] + block + content[stop:]
+	} else {
+		if sshConfigDefinesHost(content, entry.Alias) {
+			return false, fmt.Errorf("%s already has a Host %s block not written by dgx; remove it or choose another alias", path, entry.Alias)
+		}
+		updated = content
+		if updated != "" && !strings.HasSuffix(updated, "\n") {
+			updated += "\n"
+		}
+		if updated != "" {
+			updated += "\n"
+		}
+		updated += block
+	}
+
+	if updated == content {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return false, fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	mode := os.FileMode(0600)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.WriteFile(path, []byte(updated), mode); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// sshConfigDefinesHost reports whether content has a Host line naming alias literally
+func sshConfigDefinesHost(content, alias string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		key, args, err := splitSSHConfigLine(line)
+		if err != nil || key != "host" {
+			continue
+		}
+		for _, h := range args {
+			if strings.EqualFold(h, alias) {
+				return true
+			}
+		}
+	}
+	return false
+}
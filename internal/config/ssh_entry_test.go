@@ -0,0 +1,53 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUpsertSSHHost(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".ssh", "config")
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("Host work\n    HostName work.example.com"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	entry := SSHHostEntry{Alias: "dgx-spark", HostName: "10.0.0.5", User: "alice", Port: 2222, IdentityFile: "/home/alice/.ssh/id_ed25519"}
+	changed, err := UpsertSSHHost(path, entry)
+	if err != nil || !changed {
+		t.Fatalf("first upsert = %v, %v", changed, err)
+	}
+	if changed, err := UpsertSSHHost(path, entry); err != nil || changed {
+		t.Fatalf("repeat upsert = %v, %v; want no change", changed, err)
+	}
+
+	entry.HostName = "10.0.0.6"
+	if _, err := UpsertSSHHost(path, entry); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	content := string(data)
+	if strings.Count(content, "Host dgx-spark") != 1 || !strings.Contains(content, "HostName 10.0.0.6") {
+		t.Fatalf("block not replaced in place:\n%s", content)
+	}
+	if !strings.HasPrefix(content, "Host work\n    HostName work.example.com\n") {
+		t.Fatalf("existing entries were changed:\n%s", content)
+	}
+
+	profiles, err := parseSSHConfig(strings.NewReader(content), "")
+	if err != nil {
+		t.Fatalf("written config does not parse: %v", err)
+	}
+	opts := profiles.resolve("dgx-spark")
+	if firstArg(opts["port"]) != "2222" || firstArg(opts["identityfile"]) != entry.IdentityFile {
+		t.Fatalf("resolved options = %v", opts)
+	}
+
+	if _, err := UpsertSSHHost(path, SSHHostEntry{Alias: "work", HostName: "10.0.0.7"}); err == nil {
+		t.Fatal("expected a hand-written Host block to be left alone")
+	}
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/pins"
+	"github.com/weatherman/dgx-manager/internal/policy"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// DefaultProfileName labels the top-level connection in the config file
+const DefaultProfileName = "default"
+
+// lookupTimeout bounds how long host resolution may take per profile
+const lookupTimeout = 5 * time.Second
+
+// lookupHost is replaced in tests
+var lookupHost = net.DefaultResolver.LookupHost
+
+// ProfileCheck is the validation result for one profile
+type ProfileCheck struct {
+	Name     string
+	Config   *types.Config
+	Problems []string
+}
+
+// ValidateProfiles checks the top-level connection and every configured profile as stored,
+// ignoring environment and flag overrides
+func (m *Manager) ValidateProfiles(ctx context.Context) []ProfileCheck {
+	file := *m.config
+	file.ActiveProfile = ""
+
+	names := append([]string{""}, m.ProfileNames()...)
+	checks := make([]ProfileCheck, 0, len(names))
+	for _, name := range names {
+		check := ProfileCheck{Name: name}
+		if name == "" {
+			check.Name = DefaultProfileName
+		}
+		cfg, _, err := resolve(&file, Overrides{Profile: name}, nil, DetectNVSyncProfile)
+		if err != nil {
+			check.Problems = []string{err.Error()}
+		} else {
+			check.Config = cfg
+			check.Problems = ValidateConnection(ctx, cfg)
+		}
+		if m.config.Profiles[name].Stale {
+			check.Problems = append(check.Problems, "stale: its Host block is no longer in the NVIDIA Sync config")
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// ValidateConnection reports problems that would stop an SSH connection with cfg
+func ValidateConnection(ctx context.Context, cfg *types.Config) []string {
+	var problems []string
+
+	if cfg.Host == "" {
+		problems = append(problems, "host is not set")
+	} else {
+		lookupCtx, cancel := context.WithTimeout(ctx, lookupTimeout)
+		_, err := lookupHost(lookupCtx, cfg.Host)
+		cancel()
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("host %s does not resolve: %v", cfg.Host, err))
+		}
+	}
+
+	if cfg.Port < 1 || cfg.Port > 65535 {
+		problems = append(problems, fmt.Sprintf("port %d is out of range (1-65535)", cfg.Port))
+	}
+
+	if cfg.User == "" {
+		problems = append(problems, "user is not set")
+	}
+
+	if err := policy.ValidateConfirm(cfg.Confirm); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if err := policy.ValidateExecAllow(cfg.ExecAllow); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if err := ssh.ValidateOptions(cfg.SSH); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if err := pins.Validate(cfg.Pins); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	// With no identity file the connection falls back to password authentication
+	if cfg.IdentityFile != "" {
+		info, err := os.Stat(cfg.IdentityFile)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("identity file %s: %v", cfg.IdentityFile, err))
+		} else if perm := info.Mode().Perm(); runtime.GOOS != "windows" && perm&0077 != 0 {
+			problems = append(problems, fmt.Sprintf("identity file %s has permissions %04o, want 0600 (chmod 600 %s)", cfg.IdentityFile, perm, cfg.IdentityFile))
+		}
+	}
+
+	if path := ssh.CertificatePath(cfg); path != "" {
+		cert, err := ssh.LoadCertificate(path)
+		if err == nil {
+			err = ssh.CheckCertificate(cert, cfg.User, time.Now())
+		}
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,166 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/alert"
+	"github.com/weatherman/dgx-manager/internal/deploy"
+	"github.com/weatherman/dgx-manager/internal/dmr"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/tunnel"
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// Remote is the Backend for one DGX. All requests share a single SSH connection, opened
+// when the daemon starts and re-established by the SSH client when it drops.
+type Remote struct {
+	config    *types.Config
+	sshClient *ssh.Client
+	tunnels   *tunnel.Manager
+	autostart *deploy.Manager
+	dmr       *dmr.Client
+
+	// mu serializes remote commands: the SSH client reconnects without locking in Execute
+	mu sync.Mutex
+}
+
+// NewRemote connects to the DGX described by cfg
+func NewRemote(cfg *types.Config) (*Remote, error) {
+	client, err := ssh.NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Connect(); err != nil {
+		return nil, err
+	}
+
+	autostart := deploy.NewManager(client)
+	// Nobody is at a terminal to answer a sudo password prompt
+	autostart.SetBatch(true)
+
+	return &Remote{
+		config:    cfg,
+		sshClient: client,
+		tunnels:   tunnel.NewManager(cfg),
+		autostart: autostart,
+		dmr:       dmr.NewClient(client.Dial, "tcp", dmr.DefaultAddr),
+	}, nil
+}
+
+// Close closes the shared SSH connection
+func (r *Remote) Close() error {
+	return r.sshClient.Close()
+}
+
+// Status runs a no-op command to check the connection and measure its latency
+func (r *Remote) Status(ctx context.Context) Status {
+	st := Status{
+		Host:    r.config.Host,
+		Port:    r.config.Port,
+		User:    r.config.User,
+		Profile: r.config.ActiveProfile,
+	}
+
+	r.mu.Lock()
+	start := time.Now()
+	_, err := r.sshClient.ExecuteContext(ctx, "true")
+	latency := time.Since(start)
+	r.mu.Unlock()
+
+	if err != nil {
+		st.Error = err.Error()
+	} else {
+		st.Connected = true
+		st.LatencyMS = latency.Milliseconds()
+	}
+	if tunnels, err := r.Tunnels(); err == nil {
+		st.Tunnels = len(tunnels)
+	}
+	return st
+}
+
+// Tunnels lists the SSH tunnels to this DGX
+func (r *Remote) Tunnels() ([]types.Tunnel, error) {
+	return r.tunnels.List()
+}
+
+// CreateTunnel starts a background SSH tunnel and returns it with its PID
+func (r *Remote) CreateTunnel(t types.Tunnel) (types.Tunnel, error) {
+	if r.tunnels.IsPortInUse(t.LocalPort) {
+		return types.Tunnel{}, fmt.Errorf("local port %d is already in use", t.LocalPort)
+	}
+	if err := r.tunnels.Create(t); err != nil {
+		return types.Tunnel{}, err
+	}
+	t.CreatedAt = time.Now()
+	if tunnels, err := r.tunnels.List(); err == nil {
+		for _, existing := range tunnels {
+			if existing.LocalPort == t.LocalPort {
+				t.PID = existing.PID
+			}
+		}
+	}
+	return t, nil
+}
+
+// KillTunnel stops the tunnel process pid
+func (r *Remote) KillTunnel(pid int) error {
+	return r.tunnels.Kill(pid)
+}
+
+// Models lists the models in Docker Model Runner
+func (r *Remote) Models(ctx context.Context) ([]dmr.Model, error) {
+	return r.dmr.Models(ctx)
+}
+
+// Pull pulls model into Docker Model Runner and returns the command output. Other remote
+// commands wait until the pull finishes.
+func (r *Remote) Pull(ctx context.Context, model string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	output, err := r.sshClient.ExecuteContext(ctx, "docker model pull "+ssh.ShellQuote(model))
+	if err != nil {
+		return output, fmt.Errorf("failed to pull %s: %w", model, err)
+	}
+	return output, nil
+}
+
+// AlertSample reads the metrics the alert rules watch, plus the autostart units' state
+// when a rule needs it
+func (r *Remote) AlertSample(ctx context.Context, rules []types.AlertRule) (alert.Sample, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, err := alert.Collect(ctx, r.sshClient, alert.DiskPaths(rules))
+	if err != nil || !alert.WatchesDeployments(rules) {
+		return s, err
+	}
+	// A failed listing leaves the deployment rules as they were
+	if entries, err := r.autostart.List(); err == nil {
+		s.Deployments = alert.DeploymentStates(entries)
+	}
+	return s, nil
+}
+
+// Autostarts lists the installed autostart units
+func (r *Remote) Autostarts() ([]deploy.Autostart, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.autostart.List()
+}
+
+// EnableAutostart installs an autostart unit; sudo must not need a password
+func (r *Remote) EnableAutostart(a deploy.Autostart, startNow bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.autostart.Enable(a, startNow)
+}
+
+// DisableAutostart removes an autostart unit
+func (r *Remote) DisableAutostart(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.autostart.Disable(name)
+}
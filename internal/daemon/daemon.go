@@ -0,0 +1,427 @@
+package daemon
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/alert"
+	"github.com/weatherman/dgx-manager/internal/deploy"
+	"github.com/weatherman/dgx-manager/internal/dmr"
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// DefaultListen is where the daemon serves its API unless --listen is given
+const DefaultListen = "127.0.0.1:7717"
+
+// TokenFileName is the bearer token file, next to the config file
+const TokenFileName = "daemon.token"
+
+// maxRequestBody bounds JSON request bodies
+const maxRequestBody = 1 << 20
+
+// Status is the connection state reported by GET /v1/status
+type Status struct {
+	Host      string `json:"host"`
+	Port      int    `json:"port"`
+	User      string `json:"user"`
+	Profile   string `json:"profile,omitempty"`
+	Connected bool   `json:"connected"`
+	LatencyMS int64  `json:"latency_ms,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Tunnels   int    `json:"tunnels"`
+}
+
+// Backend carries out API requests against the DGX. Remote implements it over the
+// daemon's shared SSH connection.
+type Backend interface {
+	Status(ctx context.Context) Status
+	Tunnels() ([]types.Tunnel, error)
+	CreateTunnel(t types.Tunnel) (types.Tunnel, error)
+	KillTunnel(pid int) error
+	Models(ctx context.Context) ([]dmr.Model, error)
+	Pull(ctx context.Context, model string) (string, error)
+	Autostarts() ([]deploy.Autostart, error)
+	EnableAutostart(a deploy.Autostart, startNow bool) error
+	DisableAutostart(name string) error
+}
+
+// Server is the daemon's REST API. Every request must carry the bearer token.
+type Server struct {
+	backend  Backend
+	token    string
+	readOnly bool
+	alerts   *alert.Evaluator
+	logger   *log.Logger
+	handler  http.Handler
+}
+
+// NewServer creates an API server for backend that accepts token
+func NewServer(backend Backend, token string, logger *log.Logger) *Server {
+	if logger == nil {
+		logger = log.Default()
+	}
+	s := &Server{backend: backend, token: token, logger: logger}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/status", s.handleStatus)
+	mux.HandleFunc("GET /v1/alerts", s.handleAlerts)
+	mux.HandleFunc("GET /v1/tunnels", s.handleTunnels)
+	mux.HandleFunc("POST /v1/tunnels", s.handleCreateTunnel)
+	mux.HandleFunc("DELETE /v1/tunnels/{pid}", s.handleKillTunnel)
+	mux.HandleFunc("GET /v1/models", s.handleModels)
+	mux.HandleFunc("POST /v1/models/pull", s.handlePull)
+	mux.HandleFunc("GET /v1/deploy/autostart", s.handleAutostarts)
+	mux.HandleFunc("POST /v1/deploy/autostart", s.handleEnableAutostart)
+	mux.HandleFunc("DELETE /v1/deploy/autostart/{name}", s.handleDisableAutostart)
+	s.handler = s.authenticate(mux)
+	return s
+}
+
+// SetReadOnly rejects every request that would change the DGX, as the readonly config
+// option does for the CLI
+func (s *Server) SetReadOnly(readOnly bool) {
+	s.readOnly = readOnly
+}
+
+// SetAlerts reports the state of the rules tracked by e on GET /v1/alerts
+func (s *Server) SetAlerts(e *alert.Evaluator) {
+	s.alerts = e
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// ListenAndServe serves the API on addr until ctx is cancelled. Only loopback addresses
+// are accepted: the API can run commands on the DGX.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	if addr == "" {
+		addr = DefaultListen
+	}
+	if err := CheckLoopback(addr); err != nil {
+		return err
+	}
+
+	srv := &http.Server{Addr: addr, Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return fmt.Errorf("daemon failed: %w", err)
+	}
+	return nil
+}
+
+// CheckLoopback rejects listen addresses other than localhost and the loopback IPs
+func CheckLoopback(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", addr, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("listen address %q is not a loopback address", addr)
+}
+
+// authenticate rejects requests without the bearer token and logs the rest
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if s.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="dgx daemon"`)
+			writeError(w, http.StatusUnauthorized, "missing or invalid token (see 'dgx daemon token')")
+			return
+		}
+		if s.readOnly && r.Method != http.MethodGet {
+			writeError(w, http.StatusForbidden, "the daemon is read-only (readonly is set for this connection)")
+			return
+		}
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		s.logger.Printf("%s %s (%v)", r.Method, r.URL.Path, time.Since(start).Round(time.Millisecond))
+	})
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.backend.Status(r.Context()))
+}
+
+// alertJSON is the wire form of an alert rule's state
+type alertJSON struct {
+	Name      string    `json:"name"`
+	Metric    string    `json:"metric"`
+	Threshold string    `json:"threshold"`
+	Value     string    `json:"value,omitempty"`
+	Firing    bool      `json:"firing"`
+	Since     time.Time `json:"since,omitempty"`
+}
+
+func (s *Server) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	out := []alertJSON{}
+	if s.alerts != nil {
+		for _, st := range s.alerts.States() {
+			a := alertJSON{Name: st.Rule.Name, Metric: st.Rule.Metric, Threshold: alert.Threshold(st.Rule), Firing: st.Firing, Since: st.Since}
+			if st.Known {
+				a.Value = st.Reading.Detail
+			}
+			out = append(out, a)
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleTunnels(w http.ResponseWriter, r *http.Request) {
+	tunnels, err := s.backend.Tunnels()
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	if tunnels == nil {
+		tunnels = []types.Tunnel{}
+	}
+	writeJSON(w, http.StatusOK, tunnelsJSON(tunnels))
+}
+
+// tunnelRequest is the body of POST /v1/tunnels
+type tunnelRequest struct {
+	LocalPort   int    `json:"local_port"`
+	RemotePort  int    `json:"remote_port"`
+	RemoteHost  string `json:"remote_host,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+func (s *Server) handleCreateTunnel(w http.ResponseWriter, r *http.Request) {
+	var req tunnelRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if !validPort(req.LocalPort) || !validPort(req.RemotePort) {
+		writeError(w, http.StatusBadRequest, "local_port and remote_port must be between 1 and 65535")
+		return
+	}
+	if req.RemoteHost == "" {
+		req.RemoteHost = "localhost"
+	}
+	t, err := s.backend.CreateTunnel(types.Tunnel{
+		LocalPort:   req.LocalPort,
+		RemotePort:  req.RemotePort,
+		RemoteHost:  req.RemoteHost,
+		Description: req.Description,
+	})
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, tunnelsJSON([]types.Tunnel{t})[0])
+}
+
+func (s *Server) handleKillTunnel(w http.ResponseWriter, r *http.Request) {
+	pid, err := strconv.Atoi(r.PathValue("pid"))
+	if err != nil || pid <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid tunnel PID")
+		return
+	}
+	tunnels, err := s.backend.Tunnels()
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	// Only processes listed as tunnels to this DGX may be signalled
+	found := false
+	for _, t := range tunnels {
+		if t.PID == pid {
+			found = true
+		}
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no tunnel with PID %d", pid))
+		return
+	}
+	if err := s.backend.KillTunnel(pid); err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
+	models, err := s.backend.Models(r.Context())
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	if models == nil {
+		models = []dmr.Model{}
+	}
+	writeJSON(w, http.StatusOK, models)
+}
+
+func (s *Server) handlePull(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Model string `json:"model"`
+	}
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.Model == "" || strings.HasPrefix(req.Model, "-") {
+		writeError(w, http.StatusBadRequest, "model is required")
+		return
+	}
+	output, err := s.backend.Pull(r.Context(), req.Model)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"model": req.Model, "output": output})
+}
+
+// autostartJSON is the wire form of an autostart entry
+type autostartJSON struct {
+	Name    string `json:"name"`
+	Engine  string `json:"engine"`
+	Model   string `json:"model"`
+	Port    int    `json:"port,omitempty"`
+	Image   string `json:"image,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Enabled string `json:"enabled,omitempty"`
+	Active  string `json:"active,omitempty"`
+	Warm    bool   `json:"warm,omitempty"`
+	Now     bool   `json:"now,omitempty"` // request only: also start the unit
+}
+
+func (s *Server) handleAutostarts(w http.ResponseWriter, r *http.Request) {
+	entries, err := s.backend.Autostarts()
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	out := make([]autostartJSON, len(entries))
+	for i, a := range entries {
+		out[i] = autostartJSON{Name: a.Name, Engine: a.Engine, Model: a.Model, Port: a.Port, Image: a.Image, Type: a.Type, Enabled: a.Enabled, Active: a.Active, Warm: a.Warm}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleEnableAutostart(w http.ResponseWriter, r *http.Request) {
+	var req autostartJSON
+	if !readJSON(w, r, &req) {
+		return
+	}
+	a := deploy.Autostart{Name: req.Name, Engine: req.Engine, Model: req.Model, Port: req.Port, Image: req.Image, Type: req.Type, Warm: req.Warm}
+	if a.Engine == "" {
+		a.Engine = "dmr"
+	}
+	if err := a.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := s.backend.EnableAutostart(a, req.Now); err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, autostartJSON{Name: a.Name, Engine: a.Engine, Model: a.Model, Port: a.Port, Image: a.Image, Type: a.Type, Warm: a.Warm})
+}
+
+func (s *Server) handleDisableAutostart(w http.ResponseWriter, r *http.Request) {
+	if err := s.backend.DisableAutostart(r.PathValue("name")); err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// tunnelJSON is the wire form of a tunnel
+type tunnelJSON struct {
+	PID         int       `json:"pid"`
+	LocalPort   int       `json:"local_port"`
+	RemotePort  int       `json:"remote_port"`
+	RemoteHost  string    `json:"remote_host"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at,omitempty"`
+}
+
+func tunnelsJSON(tunnels []types.Tunnel) []tunnelJSON {
+	out := make([]tunnelJSON, len(tunnels))
+	for i, t := range tunnels {
+		out[i] = tunnelJSON{PID: t.PID, LocalPort: t.LocalPort, RemotePort: t.RemotePort, RemoteHost: t.RemoteHost, Description: t.Description, CreatedAt: t.CreatedAt}
+	}
+	return out
+}
+
+func validPort(p int) bool {
+	return p > 0 && p <= 65535
+}
+
+// readJSON decodes the request body into v, answering 400 on failure
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// TokenPath returns the token file next to the config file at configPath
+func TokenPath(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), TokenFileName)
+}
+
+// LoadOrCreateToken reads the bearer token at path, creating a random one (mode 0600)
+// when the file does not exist yet
+func LoadOrCreateToken(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if token := strings.TrimSpace(string(data)); token != "" {
+			return token, nil
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to read daemon token: %w", err)
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate daemon token: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("failed to write daemon token: %w", err)
+	}
+	return token, nil
+}
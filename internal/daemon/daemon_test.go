@@ -0,0 +1,182 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/weatherman/dgx-manager/internal/deploy"
+	"github.com/weatherman/dgx-manager/internal/dmr"
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+type fakeBackend struct {
+	pulled  string
+	killed  int
+	enabled deploy.Autostart
+}
+
+func (f *fakeBackend) Status(ctx context.Context) Status {
+	return Status{Host: "spark", Port: 22, User: "me", Connected: true, Tunnels: 1}
+}
+
+func (f *fakeBackend) Tunnels() ([]types.Tunnel, error) {
+	return []types.Tunnel{{PID: 42, LocalPort: 8888, RemotePort: 8888, RemoteHost: "localhost"}}, nil
+}
+
+func (f *fakeBackend) CreateTunnel(t types.Tunnel) (types.Tunnel, error) {
+	t.PID = 43
+	return t, nil
+}
+
+func (f *fakeBackend) KillTunnel(pid int) error {
+	f.killed = pid
+	return nil
+}
+
+func (f *fakeBackend) Models(ctx context.Context) ([]dmr.Model, error) {
+	return []dmr.Model{{ID: "sha256:1", Tags: []string{"ai/smollm2"}}}, nil
+}
+
+func (f *fakeBackend) Pull(ctx context.Context, model string) (string, error) {
+	f.pulled = model
+	return "done", nil
+}
+
+func (f *fakeBackend) Autostarts() ([]deploy.Autostart, error) { return nil, nil }
+
+func (f *fakeBackend) EnableAutostart(a deploy.Autostart, startNow bool) error {
+	f.enabled = a
+	return nil
+}
+
+func (f *fakeBackend) DisableAutostart(name string) error { return nil }
+
+func TestServer(t *testing.T) {
+	backend := &fakeBackend{}
+	srv := httptest.NewServer(NewServer(backend, "secret", log.New(io.Discard, "", 0)))
+	defer srv.Close()
+
+	do := func(method, path, token, body string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		return resp
+	}
+
+	t.Run("auth", func(t *testing.T) {
+		for _, token := range []string{"", "wrong"} {
+			resp := do("GET", "/v1/status", token, "")
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusUnauthorized {
+				t.Fatalf("token %q: got %d, want 401", token, resp.StatusCode)
+			}
+		}
+	})
+
+	t.Run("status", func(t *testing.T) {
+		resp := do("GET", "/v1/status", "secret", "")
+		defer resp.Body.Close()
+		var st Status
+		if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK || !st.Connected || st.Host != "spark" {
+			t.Fatalf("got %d %+v", resp.StatusCode, st)
+		}
+	})
+
+	t.Run("pull", func(t *testing.T) {
+		resp := do("POST", "/v1/models/pull", "secret", `{"model":"ai/smollm2"}`)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || backend.pulled != "ai/smollm2" {
+			t.Fatalf("got %d, pulled %q", resp.StatusCode, backend.pulled)
+		}
+		resp = do("POST", "/v1/models/pull", "secret", `{"model":"--help"}`)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("option-like model: got %d, want 400", resp.StatusCode)
+		}
+	})
+
+	t.Run("kill only known tunnels", func(t *testing.T) {
+		resp := do("DELETE", "/v1/tunnels/1", "secret", "")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound || backend.killed != 0 {
+			t.Fatalf("unknown PID: got %d, killed %d", resp.StatusCode, backend.killed)
+		}
+		resp = do("DELETE", "/v1/tunnels/42", "secret", "")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent || backend.killed != 42 {
+			t.Fatalf("got %d, killed %d", resp.StatusCode, backend.killed)
+		}
+	})
+
+	t.Run("autostart validation", func(t *testing.T) {
+		resp := do("POST", "/v1/deploy/autostart", "secret", `{"name":"Bad Name","model":"ai/smollm2"}`)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest || backend.enabled.Name != "" {
+			t.Fatalf("got %d, enabled %+v", resp.StatusCode, backend.enabled)
+		}
+	})
+}
+
+func TestReadOnly(t *testing.T) {
+	server := NewServer(&fakeBackend{}, "secret", log.New(io.Discard, "", 0))
+	server.SetReadOnly(true)
+	for method, want := range map[string]int{"GET": http.StatusOK, "DELETE": http.StatusForbidden} {
+		req := httptest.NewRequest(method, "/v1/tunnels/42", nil)
+		if method == "GET" {
+			req = httptest.NewRequest(method, "/v1/tunnels", nil)
+		}
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Fatalf("%s: got %d, want %d", method, rec.Code, want)
+		}
+	}
+}
+
+func TestLoopbackOnly(t *testing.T) {
+	for addr, ok := range map[string]bool{
+		"127.0.0.1:7717": true,
+		"localhost:7717": true,
+		"[::1]:7717":     true,
+		"0.0.0.0:7717":   false,
+		"10.0.0.5:7717":  false,
+	} {
+		if err := CheckLoopback(addr); (err == nil) != ok {
+			t.Fatalf("CheckLoopback(%q) = %v", addr, err)
+		}
+	}
+}
+
+func TestLoadOrCreateToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dgx", TokenFileName)
+	token, err := LoadOrCreateToken(path)
+	if err != nil || len(token) != 64 {
+		t.Fatalf("create: %q, %v", token, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("token file mode: %v, %v", info.Mode(), err)
+	}
+	again, err := LoadOrCreateToken(path)
+	if err != nil || again != token {
+		t.Fatalf("reload: %q, %v", again, err)
+	}
+}
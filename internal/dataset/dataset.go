@@ -0,0 +1,271 @@
+package dataset
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/artifacts"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+// Root is the datasets directory on the DGX; each dataset is a subdirectory of it
+const Root = "~/datasets"
+
+// ManifestFile sits in each dataset directory and is left out of its checksum
+const ManifestFile = ".dgx-dataset.json"
+
+var (
+	namePattern   = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	hfRepoPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*/[A-Za-z0-9._-]+$`)
+)
+
+// Manifest records where a dataset came from and what it contained when it arrived
+type Manifest struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	Size   int64  `json:"size_bytes"`
+	Files  int    `json:"files"`
+	// SHA256 is the tree checksum computed by Digest and DigestCommand
+	SHA256  string    `json:"sha256"`
+	Created time.Time `json:"created"`
+}
+
+// SourceKind is where a dataset is pushed from
+type SourceKind int
+
+const (
+	SourceLocal SourceKind = iota
+	SourceURL
+	SourceHF
+)
+
+// Source is a parsed dataset source: a local path, an http(s) URL downloaded on the DGX,
+// or hf:<repo> for a Hugging Face dataset repository
+type Source struct {
+	Kind SourceKind
+	Ref  string
+}
+
+// ParseSource classifies a dataset source argument
+func ParseSource(s string) Source {
+	switch {
+	case strings.HasPrefix(s, "hf:"):
+		return Source{Kind: SourceHF, Ref: strings.TrimPrefix(s, "hf:")}
+	case strings.HasPrefix(s, "http://"), strings.HasPrefix(s, "https://"):
+		return Source{Kind: SourceURL, Ref: s}
+	default:
+		return Source{Kind: SourceLocal, Ref: s}
+	}
+}
+
+// String returns the source as recorded in manifests
+func (s Source) String() string {
+	switch s.Kind {
+	case SourceHF:
+		return "hf:" + s.Ref
+	case SourceLocal:
+		if abs, err := filepath.Abs(s.Ref); err == nil {
+			return "local:" + abs
+		}
+		return "local:" + s.Ref
+	default:
+		return s.Ref
+	}
+}
+
+// DefaultName derives a dataset name from the source
+func (s Source) DefaultName() string {
+	switch s.Kind {
+	case SourceHF:
+		return path.Base(s.Ref)
+	case SourceURL:
+		if u, err := url.Parse(s.Ref); err == nil && path.Base(u.Path) != "/" && path.Base(u.Path) != "." {
+			return path.Base(u.Path)
+		}
+		return ""
+	default:
+		return filepath.Base(filepath.Clean(s.Ref))
+	}
+}
+
+// ValidateName rejects names that are not a single safe path component
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid dataset name %q (letters, digits, '.', '_' and '-' only)", name)
+	}
+	return nil
+}
+
+// Dir returns the DGX directory holding the named dataset
+func Dir(name string) string {
+	return Root + "/" + name
+}
+
+// Digest computes the tree checksum of a local file or directory as DigestCommand would
+// once it is on the DGX: the SHA-256 of sha256sum's output for every file, sorted by path.
+// A single file is laid out as one file in the dataset directory.
+func Digest(root string) (Manifest, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return Manifest{}, err
+	}
+	files := map[string]string{}
+	if info.IsDir() {
+		err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return err
+			}
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+			if rel != ManifestFile {
+				files["./"+filepath.ToSlash(rel)] = p
+			}
+			return nil
+		})
+		if err != nil {
+			return Manifest{}, err
+		}
+	} else {
+		files["./"+info.Name()] = root
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var m Manifest
+	tree := sha256.New()
+	for _, name := range names {
+		f, err := os.Open(files[name])
+		if err != nil {
+			return Manifest{}, err
+		}
+		h := sha256.New()
+		n, err := io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return Manifest{}, err
+		}
+		fmt.Fprintf(tree, "%s  %s\n", hex.EncodeToString(h.Sum(nil)), name)
+		m.Size += n
+		m.Files++
+	}
+	m.SHA256 = hex.EncodeToString(tree.Sum(nil))
+	return m, nil
+}
+
+// DigestCommand prints "<sha256> <size> <files>" for a dataset directory on the DGX
+func DigestCommand(dir string) string {
+	return fmt.Sprintf(`cd %s && printf '%%s %%s %%s\n' \
+  "$(find . -type f ! -path ./%[2]s -print0 | LC_ALL=C sort -z | xargs -0 -r sha256sum | sha256sum | cut -d' ' -f1)" \
+  "$(find . -type f ! -path ./%[2]s -printf '%%s\n' | awk '{s+=$1} END {print s+0}')" \
+  "$(find . -type f ! -path ./%[2]s | wc -l)"`, ssh.QuoteRemotePath(dir), ManifestFile)
+}
+
+// ParseDigest parses DigestCommand output into a manifest
+func ParseDigest(output string) (Manifest, error) {
+	var m Manifest
+	if _, err := fmt.Sscanf(strings.TrimSpace(output), "%s %d %d", &m.SHA256, &m.Size, &m.Files); err != nil {
+		return Manifest{}, fmt.Errorf("unexpected checksum output %q", strings.TrimSpace(output))
+	}
+	return m, nil
+}
+
+// DownloadCommand downloads source into dir on the DGX. Hugging Face downloads use the hf
+// CLI (or the older huggingface-cli) and pick up HF_TOKEN from 'dgx env hf-token'.
+func DownloadCommand(source Source, dir string) (string, error) {
+	quoted := ssh.QuoteRemotePath(dir)
+	switch source.Kind {
+	case SourceURL:
+		file := source.DefaultName()
+		if file == "" || strings.HasPrefix(file, ".") {
+			file = "download"
+		}
+		return fmt.Sprintf(`set -e
+mkdir -p %[1]s && cd %[1]s
+if command -v curl >/dev/null 2>&1; then curl -fL --retry 3 -C - -o %[2]s %[3]s
+elif command -v wget >/dev/null 2>&1; then wget -c -O %[2]s %[3]s
+else echo "neither curl nor wget is installed on the DGX" >&2; exit 1; fi`, quoted, ssh.ShellQuote(file), ssh.ShellQuote(source.Ref)), nil
+	case SourceHF:
+		if !hfRepoPattern.MatchString(source.Ref) {
+			return "", fmt.Errorf("invalid Hugging Face dataset %q (expected hf:<owner>/<name>)", source.Ref)
+		}
+		return fmt.Sprintf(`set -e
+[ -f ~/.config/dgx/env.sh ] && . ~/.config/dgx/env.sh
+export PATH="$HOME/.local/bin:$PATH"
+mkdir -p %[1]s
+if command -v hf >/dev/null 2>&1; then hf download --repo-type dataset %[2]s --local-dir %[1]s
+elif command -v huggingface-cli >/dev/null 2>&1; then huggingface-cli download --repo-type dataset %[2]s --local-dir %[1]s
+else echo "the Hugging Face CLI is not installed on the DGX (pip install -U huggingface_hub)" >&2; exit 1; fi
+# The download cache would otherwise be part of the checksum
+rm -rf %[1]s/.cache/huggingface`, quoted, ssh.ShellQuote(source.Ref)), nil
+	default:
+		return "", fmt.Errorf("local sources are pushed, not downloaded")
+	}
+}
+
+// ListCommand prints one manifest per line, and "@@unmanaged <name>" for directories under
+// Root without one
+const ListCommand = `for d in ~/datasets/*/; do
+  [ -d "$d" ] || continue
+  if [ -f "$d/.dgx-dataset.json" ]; then tr -d '\n' < "$d/.dgx-dataset.json"; echo
+  else echo "@@unmanaged $(basename "$d")"; fi
+done`
+
+// ParseList parses ListCommand output
+func ParseList(output string) (manifests []Manifest, unmanaged []string) {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if name, ok := strings.CutPrefix(line, "@@unmanaged "); ok {
+			unmanaged = append(unmanaged, name)
+			continue
+		}
+		var m Manifest
+		if line != "" && json.Unmarshal([]byte(line), &m) == nil {
+			manifests = append(manifests, m)
+		}
+	}
+	sort.Slice(manifests, func(i, j int) bool { return manifests[i].Name < manifests[j].Name })
+	return manifests, unmanaged
+}
+
+// FindDuplicate returns the dataset whose checksum matches m, if any
+func FindDuplicate(manifests []Manifest, m Manifest) (Manifest, bool) {
+	for _, existing := range manifests {
+		if existing.SHA256 == m.SHA256 && existing.Files == m.Files {
+			return existing, true
+		}
+	}
+	return Manifest{}, false
+}
+
+// Print writes manifests as a table
+func Print(w io.Writer, manifests []Manifest) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSIZE\tFILES\tSHA256\tADDED\tSOURCE")
+	for _, m := range manifests {
+		sum := m.SHA256
+		if len(sum) > 12 {
+			sum = sum[:12]
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\n", m.Name, artifacts.FormatBytes(m.Size), m.Files, sum, m.Created.Local().Format("2006-01-02"), m.Source)
+	}
+	tw.Flush()
+}
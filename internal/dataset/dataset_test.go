@@ -0,0 +1,85 @@
+package dataset
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestDigestMatchesRemoteCommand(t *testing.T) {
+	for _, tool := range []string{"bash", "sha256sum", "xargs"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not installed", tool)
+		}
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"train.jsonl":       "{\"text\": \"a\"}\n",
+		"val.jsonl":         "{\"text\": \"b\"}\n",
+		"images/B.png":      "png",
+		"images/a-1.png":    "png2",
+		ManifestFile:        "{}",
+		"nested/deep/x.txt": "",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	local, err := Digest(dir)
+	if err != nil {
+		t.Fatalf("Digest: %v", err)
+	}
+	out, err := exec.Command("bash", "-c", DigestCommand(dir)).Output()
+	if err != nil {
+		t.Fatalf("DigestCommand: %v", err)
+	}
+	remote, err := ParseDigest(string(out))
+	if err != nil {
+		t.Fatalf("ParseDigest: %v", err)
+	}
+	if local.SHA256 != remote.SHA256 || local.Size != remote.Size || local.Files != remote.Files || local.Files != 5 {
+		t.Fatalf("local %+v != remote %+v", local, remote)
+	}
+}
+
+func TestParseSource(t *testing.T) {
+	tests := []struct {
+		in   string
+		kind SourceKind
+		name string
+	}{
+		{"hf:HuggingFaceH4/ultrachat_200k", SourceHF, "ultrachat_200k"},
+		{"https://example.com/data/corpus.tar.gz?sig=1", SourceURL, "corpus.tar.gz"},
+		{"./data/alpaca/", SourceLocal, "alpaca"},
+	}
+	for _, tt := range tests {
+		s := ParseSource(tt.in)
+		if s.Kind != tt.kind || s.DefaultName() != tt.name {
+			t.Fatalf("ParseSource(%q) = %+v name %q", tt.in, s, s.DefaultName())
+		}
+	}
+	if err := ValidateName("../etc"); err == nil {
+		t.Fatalf("ValidateName should reject path traversal")
+	}
+}
+
+func TestParseList(t *testing.T) {
+	output := `{"name":"b","source":"hf:x/b","size_bytes":10,"files":1,"sha256":"ff"}
+@@unmanaged scratch
+{"name":"a","source":"local:/tmp/a","size_bytes":20,"files":2,"sha256":"ee"}
+`
+	manifests, unmanaged := ParseList(output)
+	if len(manifests) != 2 || manifests[0].Name != "a" || len(unmanaged) != 1 || unmanaged[0] != "scratch" {
+		t.Fatalf("ParseList = %+v, %v", manifests, unmanaged)
+	}
+	if dup, ok := FindDuplicate(manifests, Manifest{SHA256: "ff", Files: 1}); !ok || dup.Name != "b" {
+		t.Fatalf("FindDuplicate = %+v, %v", dup, ok)
+	}
+}
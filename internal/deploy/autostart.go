@@ -0,0 +1,387 @@
+package deploy
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/weatherman/dgx-manager/internal/serve"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+const (
+	unitPrefix = "dgx-autostart-"
+	unitDir    = "/etc/systemd/system"
+
+	// DefaultVLLMImage is the container used for vLLM autostart units
+	DefaultVLLMImage = "nvcr.io/nvidia/vllm:25.09-py3"
+)
+
+// Engines lists the runtimes an autostart unit can warm up
+var Engines = []string{"dmr", "vllm", "ollama"}
+
+var (
+	namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+	// Model and image references are embedded in unit files, so only plain registry/repo
+	// characters are allowed (no quotes, spaces, or systemd specifiers)
+	refPattern = regexp.MustCompile(`^[A-Za-z0-9._:/@+-]+$`)
+)
+
+// Autostart describes a model that should be loaded when the DGX boots
+type Autostart struct {
+	Name   string
+	Engine string
+	Model  string
+	Port   int    // host port for vllm
+	Image  string // container image for vllm
+	User   string // account the unit runs as (needs docker access)
+	Type   string // model type: chat (the default), embedding, or rerank
+	Warm   bool   // send a warmup request once the unit has started
+
+	// GPUMemory is the share of GPU memory vllm may use (--gpu-memory-utilization), set
+	// from 'dgx gpu reserve'; zero leaves vLLM's default
+	GPUMemory float64
+
+	// EnvFile is set by List when the unit passes the deployment's environment file to its
+	// container; units installed before 'dgx deploy env' existed do not
+	EnvFile bool
+
+	// Populated by List
+	Enabled string
+	Active  string
+	Timer   string // state of the warmup timer, if one is scheduled
+}
+
+// UnitName returns the systemd unit name for an autostart entry
+func UnitName(name string) string {
+	return unitPrefix + name + ".service"
+}
+
+// Validate checks the entry and fills in engine defaults
+func (a *Autostart) Validate() error {
+	if !namePattern.MatchString(a.Name) {
+		return fmt.Errorf("invalid name %q: use lowercase letters, digits, and dashes", a.Name)
+	}
+	if !refPattern.MatchString(a.Model) {
+		return fmt.Errorf("invalid model reference %q", a.Model)
+	}
+	switch a.Engine {
+	case "dmr", "ollama":
+	case "vllm":
+		if a.Port == 0 {
+			a.Port = 8000
+		}
+		if a.Image == "" {
+			a.Image = DefaultVLLMImage
+		}
+		if !refPattern.MatchString(a.Image) {
+			return fmt.Errorf("invalid image reference %q", a.Image)
+		}
+	default:
+		return fmt.Errorf("unknown engine %q (expected one of: %s)", a.Engine, strings.Join(Engines, ", "))
+	}
+	switch a.Type {
+	case "":
+		a.Type = serve.TypeChat
+	case serve.TypeChat, serve.TypeEmbedding:
+	case serve.TypeRerank:
+		if a.Engine == "ollama" {
+			return fmt.Errorf("ollama does not serve rerank models; use dmr or vllm")
+		}
+	default:
+		return fmt.Errorf("unknown model type %q (expected one of: %s)", a.Type, strings.Join(serve.ModelTypes, ", "))
+	}
+	if a.GPUMemory != 0 {
+		if a.Engine != "vllm" {
+			return fmt.Errorf("a GPU memory share applies only to vllm")
+		}
+		if a.GPUMemory < 0 || a.GPUMemory > 1 {
+			return fmt.Errorf("invalid GPU memory share %g: expected a fraction between 0 and 1", a.GPUMemory)
+		}
+	}
+	if a.User == "" {
+		return fmt.Errorf("unit user is required")
+	}
+	return nil
+}
+
+// loadCommand returns the unit's ExecStart for loading a non-chat model into an engine
+// that loads models on first use: one small request of the model's type
+func loadCommand(a Autostart) (string, error) {
+	path, body, err := WarmRequest(a.Type, a.Model, "hi")
+	url := a.APIBase() + "/" + path
+	if a.Engine == "ollama" {
+		// Ollama's native endpoint takes keep_alive; -1 keeps the model resident
+		url = "http://localhost:11434/api/embed"
+		body, err = json.Marshal(map[string]any{"model": a.Model, "input": "hi", "keep_alive": -1})
+	}
+	if err != nil {
+		return "", err
+	}
+	escaped := strings.ReplaceAll(string(body), `"`, `\"`)
+	return fmt.Sprintf("ExecStart=/bin/bash -c \"curl -sf -H 'Content-Type: application/json' %s -d '%s' >/dev/null\"\n", url, escaped), nil
+}
+
+// RenderUnit returns the systemd unit file for an autostart entry
+func RenderUnit(a Autostart) (string, error) {
+	if err := a.Validate(); err != nil {
+		return "", err
+	}
+
+	var unit, service strings.Builder
+	fmt.Fprintf(&unit, "[Unit]\nDescription=dgx autostart: %s (%s %s)\n", a.Name, a.Engine, a.Model)
+	unit.WriteString("Wants=network-online.target\n")
+
+	fmt.Fprintf(&service, "[Service]\nUser=%s\n", a.User)
+	switch a.Engine {
+	case "dmr":
+		unit.WriteString("After=docker.service network-online.target\nRequires=docker.service\n")
+		// The runner container is restarted by docker; wait for it, then load the model
+		service.WriteString("Type=oneshot\nRemainAfterExit=yes\nTimeoutStartSec=15min\n")
+		service.WriteString(`ExecStartPre=/bin/bash -c "until docker model status >/dev/null 2>&1; do sleep 5; done"` + "\n")
+		if a.Type == serve.TypeChat {
+			fmt.Fprintf(&service, "ExecStart=/bin/bash -c \"docker model run %s hi >/dev/null\"\n", a.Model)
+			break
+		}
+		load, err := loadCommand(a)
+		if err != nil {
+			return "", err
+		}
+		service.WriteString(load)
+	case "ollama":
+		unit.WriteString("After=ollama.service network-online.target\nWants=ollama.service\n")
+		service.WriteString("Type=oneshot\nRemainAfterExit=yes\nTimeoutStartSec=15min\n")
+		service.WriteString(`ExecStartPre=/bin/bash -c "until curl -sf http://localhost:11434/api/version >/dev/null; do sleep 2; done"` + "\n")
+		if a.Type == serve.TypeEmbedding {
+			load, err := loadCommand(a)
+			if err != nil {
+				return "", err
+			}
+			service.WriteString(load)
+			break
+		}
+		// keep_alive -1 keeps the model resident until it is explicitly unloaded
+		fmt.Fprintf(&service, "ExecStart=/bin/bash -c \"curl -sf http://localhost:11434/api/generate -d '{\\\"model\\\":\\\"%s\\\",\\\"keep_alive\\\":-1}' >/dev/null\"\n", a.Model)
+	case "vllm":
+		container := "dgx-" + a.Name
+		unit.WriteString("After=docker.service network-online.target\nRequires=docker.service\n")
+		service.WriteString("Type=simple\nRestart=on-failure\nRestartSec=10\nTimeoutStartSec=0\n")
+		fmt.Fprintf(&service, "ExecStartPre=-/bin/bash -c \"docker rm -f %s >/dev/null 2>&1\"\n", container)
+		// docker run fails on a missing --env-file, so create an empty one
+		fmt.Fprintf(&service, "ExecStartPre=/bin/bash -c \"mkdir -p %s && umask 077 && touch %s\"\n", envDir, EnvPath(a.Name))
+		// Pooling models are served with the matching task
+		serveArgs := ""
+		switch a.Type {
+		case serve.TypeEmbedding:
+			serveArgs = " --task embed"
+		case serve.TypeRerank:
+			serveArgs = " --task score"
+		}
+		if a.GPUMemory > 0 {
+			serveArgs += fmt.Sprintf(" --gpu-memory-utilization %.2f", a.GPUMemory)
+		}
+		fmt.Fprintf(&service, "ExecStart=/bin/bash -c \"source ~/.config/dgx/env.sh 2>/dev/null; exec docker run --rm --name %s --gpus all --shm-size=10g -e HF_TOKEN --env-file %s -p %d:8000 %s vllm serve %s --host 0.0.0.0 --port 8000%s\"\n",
+			container, EnvPath(a.Name), a.Port, a.Image, a.Model, serveArgs)
+		fmt.Fprintf(&service, "ExecStop=/bin/bash -c \"docker stop %s\"\n", container)
+	}
+
+	if a.Warm {
+		// The warmup waits for the engine, so for vLLM it also covers loading the weights.
+		// A failed warmup should not fail the deployment.
+		args, err := warmArgs(a, "", warmStartWait)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&service, "ExecStartPost=-/bin/sh %s %s\n", warmScriptPath, args)
+	}
+
+	var b strings.Builder
+	b.WriteString("# Managed by dgx deploy autostart; remove with: dgx deploy autostart disable " + a.Name + "\n")
+	b.WriteString(unit.String())
+	b.WriteString("\n")
+	b.WriteString(service.String())
+	b.WriteString("\n[Install]\nWantedBy=multi-user.target\n\n")
+	// systemd ignores X- sections; they let List recover the entry
+	fmt.Fprintf(&b, "[X-DGX]\nName=%s\nEngine=%s\nModel=%s\nType=%s\n", a.Name, a.Engine, a.Model, a.Type)
+	if a.Engine == "vllm" {
+		fmt.Fprintf(&b, "Port=%d\nImage=%s\n", a.Port, a.Image)
+		if a.GPUMemory > 0 {
+			fmt.Fprintf(&b, "GPUMemory=%.2f\n", a.GPUMemory)
+		}
+	}
+	if a.Warm {
+		b.WriteString("Warm=true\n")
+	}
+	return b.String(), nil
+}
+
+// parseUnit recovers an autostart entry from the [X-DGX] section of a unit file
+func parseUnit(content string) (Autostart, bool) {
+	var a Autostart
+	inSection := false
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			inSection = line == "[X-DGX]"
+			continue
+		}
+		if !inSection {
+			if user, ok := strings.CutPrefix(line, "User="); ok {
+				a.User = user
+			}
+			if strings.HasPrefix(line, "ExecStart=") && strings.Contains(line, "--env-file") {
+				a.EnvFile = true
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "Name":
+			a.Name = value
+		case "Engine":
+			a.Engine = value
+		case "Model":
+			a.Model = value
+		case "Port":
+			a.Port, _ = strconv.Atoi(value)
+		case "Image":
+			a.Image = value
+		case "Type":
+			a.Type = value
+		case "Warm":
+			a.Warm = value == "true"
+		case "GPUMemory":
+			a.GPUMemory, _ = strconv.ParseFloat(value, 64)
+		}
+	}
+	return a, a.Name != ""
+}
+
+// Manager installs and removes autostart units on the DGX
+type Manager struct {
+	sshClient *ssh.Client
+	batch     bool
+}
+
+// NewManager creates a new autostart manager
+func NewManager(sshClient *ssh.Client) *Manager {
+	return &Manager{sshClient: sshClient}
+}
+
+// SetBatch makes the manager run sudo non-interactively (sudo -n) without a terminal, for
+// callers such as dgx daemon that cannot answer a password prompt
+func (m *Manager) SetBatch(batch bool) {
+	m.batch = batch
+}
+
+// runSudo runs a script containing sudo commands, on the terminal unless in batch mode
+func (m *Manager) runSudo(script string) error {
+	if m.batch {
+		_, err := m.sshClient.Execute(strings.ReplaceAll(script, "sudo ", "sudo -n "))
+		return err
+	}
+	return m.sshClient.RunInteractive(script)
+}
+
+// Enable installs and enables the unit so it runs at every boot. With startNow the unit is
+// also started immediately. Installing requires sudo, so the user may be prompted unless
+// the manager is in batch mode.
+func (m *Manager) Enable(a Autostart, startNow bool) error {
+	content, err := RenderUnit(a)
+	if err != nil {
+		return err
+	}
+
+	unit := UnitName(a.Name)
+	staging := "$HOME/.config/dgx/units/" + unit
+	encoded := base64.StdEncoding.EncodeToString([]byte(content))
+	stage := fmt.Sprintf(`mkdir -p "$HOME/.config/dgx/units" && echo %s | base64 -d > "%s"`, encoded, staging)
+	if a.Warm {
+		stage += " && " + stageWarmScript()
+	}
+	if _, err := m.sshClient.Execute(stage); err != nil {
+		return fmt.Errorf("failed to stage unit file: %w", err)
+	}
+
+	enable := "enable"
+	if startNow {
+		enable = "enable --now"
+	}
+	install := fmt.Sprintf(`sudo install -m 0644 "%s" %s/%s && sudo systemctl daemon-reload && sudo systemctl %s %s`,
+		staging, unitDir, unit, enable, unit)
+	if a.Warm {
+		install = installWarmScript + " && " + install
+	}
+	if err := m.runSudo(install); err != nil {
+		return fmt.Errorf("failed to install %s: %w", unit, err)
+	}
+	return nil
+}
+
+// List returns installed autostart units with their enabled/active state
+func (m *Manager) List() ([]Autostart, error) {
+	script := fmt.Sprintf(`for f in %s/%s*.service; do
+  [ -e "$f" ] || continue
+  u=$(basename "$f")
+  t=%s$(basename "$u" .service | sed 's/^%s//').timer
+  echo "=== $(systemctl is-enabled "$u" 2>/dev/null) $(systemctl is-active "$u" 2>/dev/null) $(systemctl is-active "$t" 2>/dev/null)"
+  cat "$f"
+done`, unitDir, unitPrefix, warmPrefix, unitPrefix)
+	output, err := m.sshClient.Execute(script)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list autostart units: %w", err)
+	}
+	return parseList(output), nil
+}
+
+func parseList(output string) []Autostart {
+	var entries []Autostart
+	for _, block := range strings.Split(output, "=== ")[1:] {
+		header, body, _ := strings.Cut(block, "\n")
+		a, ok := parseUnit(body)
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(header)
+		if len(fields) > 0 {
+			a.Enabled = fields[0]
+		}
+		if len(fields) > 1 {
+			a.Active = fields[1]
+		}
+		if len(fields) > 2 {
+			a.Timer = fields[2]
+		}
+		entries = append(entries, a)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries
+}
+
+// Disable stops the unit and removes it from the DGX, along with any warmup timer. Any vLLM
+// container it started is stopped by the unit's ExecStop.
+func (m *Manager) Disable(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid name %q", name)
+	}
+	unit := UnitName(name)
+
+	exists, _ := m.sshClient.Execute(fmt.Sprintf("test -e %s/%s && echo yes", unitDir, unit))
+	if strings.TrimSpace(exists) != "yes" {
+		return fmt.Errorf("no autostart entry named %q", name)
+	}
+
+	remove := fmt.Sprintf(`%s; sudo systemctl disable --now %s; sudo rm -f %s/%s && sudo systemctl daemon-reload && rm -f "$HOME/.config/dgx/units/%s"`,
+		removeWarmTimer(name), unit, unitDir, unit, unit)
+	if err := m.runSudo(remove); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", unit, err)
+	}
+	return nil
+}
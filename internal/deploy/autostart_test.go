@@ -0,0 +1,126 @@
+package deploy
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderUnitRoundTrip(t *testing.T) {
+	a := Autostart{Name: "llama", Engine: "vllm", Model: "meta-llama/Llama-3.1-8B-Instruct", User: "nvidia"}
+	unit, err := RenderUnit(a)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		"User=nvidia",
+		"--name dgx-llama",
+		"-p 8000:8000 " + DefaultVLLMImage,
+		"vllm serve meta-llama/Llama-3.1-8B-Instruct",
+		"--env-file " + EnvPath("llama"),
+		"WantedBy=multi-user.target",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Fatalf("unit missing %q:\n%s", want, unit)
+		}
+	}
+
+	entries := parseList("=== enabled active\n" + unit)
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+	got := entries[0]
+	if got.Name != "llama" || got.Engine != "vllm" || got.Model != a.Model || got.Port != 8000 ||
+		!got.EnvFile || got.User != "nvidia" || got.Enabled != "enabled" || got.Active != "active" {
+		t.Fatalf("unexpected entry: %+v", got)
+	}
+}
+
+func TestValidateRejectsUnsafeModel(t *testing.T) {
+	a := Autostart{Name: "x", Engine: "dmr", Model: `ai/x"; rm -rf /`, User: "nvidia"}
+	if err := a.Validate(); err == nil {
+		t.Fatalf("expected model with quotes to be rejected")
+	}
+}
+
+func TestWarmUnits(t *testing.T) {
+	a := Autostart{Name: "smollm", Engine: "dmr", Model: "ai/smollm2:360M-Q4_K_M", User: "nvidia", Warm: true}
+	unit, err := RenderUnit(a)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(unit, "ExecStartPost=-/bin/sh "+warmScriptPath+" http://localhost:12434/engines/v1 900 ") {
+		t.Fatalf("unit missing warmup:\n%s", unit)
+	}
+	entries := parseList("=== enabled active active\n" + unit)
+	if len(entries) != 1 || !entries[0].Warm || entries[0].Timer != "active" {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+
+	_, timer, err := RenderWarmUnits(a, 30*time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(timer, "OnUnitActiveSec=1800") {
+		t.Fatalf("timer missing interval:\n%s", timer)
+	}
+}
+
+func TestParseWarmOutput(t *testing.T) {
+	got, err := parseWarmOutput("ready 40\nwarm 200 12.503\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Ready != 40*time.Second || got.Status != 200 || got.Duration != 12503*time.Millisecond {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+	if _, err := parseWarmOutput("ready 0\nwarm 404 0.010\n"); err == nil {
+		t.Fatalf("expected an error for HTTP 404")
+	}
+	if _, err := parseWarmOutput("timeout http://localhost:8000/v1 did not answer within 60s\n"); err == nil {
+		t.Fatalf("expected an error for a timeout")
+	}
+}
+
+func TestRenderUnitModelType(t *testing.T) {
+	a := Autostart{Name: "bge", Engine: "vllm", Model: "BAAI/bge-m3", Type: "embedding", User: "nvidia"}
+	unit, err := RenderUnit(a)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(unit, "vllm serve BAAI/bge-m3 --host 0.0.0.0 --port 8000 --task embed") {
+		t.Fatalf("unit missing embed task:\n%s", unit)
+	}
+
+	a = Autostart{Name: "mxbai", Engine: "dmr", Model: "ai/mxbai-embed-large", Type: "embedding", User: "nvidia"}
+	if unit, err = RenderUnit(a); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(unit, `http://localhost:12434/engines/v1/embeddings -d '{\"input\":\"hi\",\"model\":\"ai/mxbai-embed-large\"}'`) {
+		t.Fatalf("unit missing embedding load:\n%s", unit)
+	}
+
+	a = Autostart{Name: "rr", Engine: "ollama", Model: "x", Type: "rerank", User: "nvidia"}
+	if err := a.Validate(); err == nil {
+		t.Fatalf("expected ollama rerank to be rejected")
+	}
+}
+
+func TestRenderUnitGPUMemory(t *testing.T) {
+	a := Autostart{Name: "llama", Engine: "vllm", Model: "m", User: "nvidia", GPUMemory: 0.4}
+	unit, err := RenderUnit(a)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(unit, "--port 8000 --gpu-memory-utilization 0.40") {
+		t.Fatalf("unit missing --gpu-memory-utilization:\n%s", unit)
+	}
+	if got, _ := parseUnit(unit); got.GPUMemory != 0.4 {
+		t.Fatalf("parsed GPUMemory = %v", got.GPUMemory)
+	}
+
+	a = Autostart{Name: "smol", Engine: "dmr", Model: "ai/smollm2", User: "nvidia", GPUMemory: 0.4}
+	if err := a.Validate(); err == nil {
+		t.Fatalf("expected a GPU memory share on dmr to be rejected")
+	}
+}
@@ -0,0 +1,203 @@
+package deploy
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// envDir holds the environment files of deployments on the DGX, in docker --env-file format
+const envDir = "~/.config/dgx/deploy"
+
+// secretMarker starts the comment line that marks a variable as secret; docker skips
+// comment lines in env files
+const secretMarker = "#dgx:secret "
+
+var (
+	envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// secretNamePattern matches variable names that usually hold credentials
+	secretNamePattern = regexp.MustCompile(`(?i)(TOKEN|SECRET|PASSWORD|PASSWD|CREDENTIAL|API_?KEY|PRIVATE_KEY|ACCESS_KEY)`)
+)
+
+// EnvPath returns the environment file of the named deployment on the DGX
+func EnvPath(name string) string {
+	return envDir + "/" + name + ".env"
+}
+
+// EnvVar is one variable passed to a deployment's container
+type EnvVar struct {
+	Name   string
+	Value  string
+	Secret bool // masked when listed
+}
+
+// Masked returns the value for display: secrets show only their length
+func (v EnvVar) Masked() string {
+	if !v.Secret {
+		return v.Value
+	}
+	return fmt.Sprintf("******** (%d chars)", len(v.Value))
+}
+
+// IsSecretName reports whether a variable name suggests a credential, such as HF_TOKEN or
+// OPENAI_API_KEY
+func IsSecretName(name string) bool {
+	return secretNamePattern.MatchString(name)
+}
+
+// ParseEnvAssignment splits NAME=value. Docker env files cannot hold newlines, so values
+// with one are rejected.
+func ParseEnvAssignment(s string) (EnvVar, error) {
+	name, value, _ := strings.Cut(s, "=")
+	if err := ValidateEnvName(name); err != nil {
+		return EnvVar{}, err
+	}
+	if strings.ContainsAny(value, "\n\r\x00") {
+		return EnvVar{}, fmt.Errorf("the value of %s must be a single line", name)
+	}
+	return EnvVar{Name: name, Value: value, Secret: IsSecretName(name)}, nil
+}
+
+// ValidateEnvName checks a variable name
+func ValidateEnvName(name string) error {
+	if !envNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid variable name %q: use letters, digits, and underscores", name)
+	}
+	return nil
+}
+
+// ParseEnv reads an environment file written by RenderEnv
+func ParseEnv(content string) []EnvVar {
+	secret := map[string]bool{}
+	var vars []EnvVar
+	for _, line := range strings.Split(content, "\n") {
+		if name, ok := strings.CutPrefix(line, secretMarker); ok {
+			secret[strings.TrimSpace(name)] = true
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		if !ok || ValidateEnvName(name) != nil {
+			continue
+		}
+		vars = append(vars, EnvVar{Name: name, Value: value})
+	}
+	for i := range vars {
+		vars[i].Secret = secret[vars[i].Name] || IsSecretName(vars[i].Name)
+	}
+	return vars
+}
+
+// RenderEnv writes variables in docker --env-file format, sorted by name, with a marker
+// comment for each secret
+func RenderEnv(vars []EnvVar) string {
+	sorted := append([]EnvVar(nil), vars...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	var b strings.Builder
+	b.WriteString("# Managed by dgx deploy env\n")
+	for _, v := range sorted {
+		if v.Secret && !IsSecretName(v.Name) {
+			b.WriteString(secretMarker + v.Name + "\n")
+		}
+	}
+	for _, v := range sorted {
+		fmt.Fprintf(&b, "%s=%s\n", v.Name, v.Value)
+	}
+	return b.String()
+}
+
+// SetEnv returns vars with updates applied: existing names get the new value, new names
+// are added. A variable stays secret once it was marked secret.
+func SetEnv(vars, updates []EnvVar) []EnvVar {
+	result := append([]EnvVar(nil), vars...)
+	for _, u := range updates {
+		found := false
+		for i := range result {
+			if result[i].Name == u.Name {
+				result[i].Value = u.Value
+				result[i].Secret = result[i].Secret || u.Secret
+				found = true
+			}
+		}
+		if !found {
+			result = append(result, u)
+		}
+	}
+	return result
+}
+
+// UnsetEnv returns vars without the named variables and the names that were not set
+func UnsetEnv(vars []EnvVar, names []string) ([]EnvVar, []string) {
+	drop := map[string]bool{}
+	for _, n := range names {
+		drop[n] = true
+	}
+	var result []EnvVar
+	for _, v := range vars {
+		if drop[v.Name] {
+			delete(drop, v.Name)
+			continue
+		}
+		result = append(result, v)
+	}
+	var missing []string
+	for _, n := range names {
+		if drop[n] {
+			missing = append(missing, n)
+		}
+	}
+	return result, missing
+}
+
+// homePath turns an envDir path into one the remote shell expands, even when quoted
+func homePath(p string) string {
+	return `"$HOME"` + strings.TrimPrefix(p, "~")
+}
+
+// Env returns the variables set for the named deployment
+func (m *Manager) Env(name string) ([]EnvVar, error) {
+	if !namePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid name %q", name)
+	}
+	output, err := m.sshClient.Execute(fmt.Sprintf("cat %s 2>/dev/null || true", homePath(EnvPath(name))))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the environment of %s: %w", name, err)
+	}
+	return ParseEnv(output), nil
+}
+
+// SaveEnv replaces the environment file of the named deployment. The file is only readable
+// by the user, since it may hold secrets, and is replaced atomically.
+func (m *Manager) SaveEnv(name string, vars []EnvVar) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid name %q", name)
+	}
+	path := homePath(EnvPath(name))
+	encoded := base64.StdEncoding.EncodeToString([]byte(RenderEnv(vars)))
+	script := fmt.Sprintf(`set -e
+umask 077
+mkdir -p %s
+echo %s | base64 -d > %s.tmp
+mv -f %s.tmp %s`, homePath(envDir), encoded, path, path, path)
+	if _, err := m.sshClient.Execute(script); err != nil {
+		return fmt.Errorf("failed to save the environment of %s: %w", name, err)
+	}
+	return nil
+}
+
+// Restart restarts the named deployment's unit so its container picks up the environment.
+// It uses sudo, so the user may be prompted unless the manager is in batch mode.
+func (m *Manager) Restart(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid name %q", name)
+	}
+	if err := m.runSudo("sudo systemctl restart " + UnitName(name)); err != nil {
+		return fmt.Errorf("failed to restart %s: %w", UnitName(name), err)
+	}
+	return nil
+}
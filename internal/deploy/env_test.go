@@ -0,0 +1,51 @@
+package deploy
+
+import "testing"
+
+func TestEnvRoundTrip(t *testing.T) {
+	vars := []EnvVar{
+		{Name: "VLLM_LOGGING_LEVEL", Value: "DEBUG"},
+		{Name: "HF_TOKEN", Value: "hf_abc", Secret: true},
+		{Name: "ENDPOINT_AUTH", Value: "a=b c", Secret: true},
+	}
+	got := ParseEnv(RenderEnv(vars))
+	want := map[string]EnvVar{}
+	for _, v := range vars {
+		want[v.Name] = v
+	}
+	if len(got) != len(vars) {
+		t.Fatalf("ParseEnv = %+v", got)
+	}
+	for _, v := range got {
+		if want[v.Name] != v {
+			t.Fatalf("round trip of %s = %+v, want %+v", v.Name, v, want[v.Name])
+		}
+	}
+	if m := got[0].Masked(); m != "******** (5 chars)" {
+		t.Fatalf("Masked = %q", m)
+	}
+}
+
+func TestParseEnvAssignment(t *testing.T) {
+	v, err := ParseEnvAssignment("OPENAI_API_KEY=sk=1")
+	if err != nil || v.Name != "OPENAI_API_KEY" || v.Value != "sk=1" || !v.Secret {
+		t.Fatalf("ParseEnvAssignment = %+v, %v", v, err)
+	}
+	for _, bad := range []string{"1X=y", "A B=c", "=x", "X=a\nb"} {
+		if _, err := ParseEnvAssignment(bad); err == nil {
+			t.Fatalf("ParseEnvAssignment(%q) accepted", bad)
+		}
+	}
+}
+
+func TestSetAndUnsetEnv(t *testing.T) {
+	vars := []EnvVar{{Name: "A", Value: "1", Secret: true}, {Name: "B", Value: "2"}}
+	vars = SetEnv(vars, []EnvVar{{Name: "A", Value: "3"}, {Name: "C", Value: "4"}})
+	if len(vars) != 3 || vars[0].Value != "3" || !vars[0].Secret || vars[2].Name != "C" {
+		t.Fatalf("SetEnv = %+v", vars)
+	}
+	vars, missing := UnsetEnv(vars, []string{"B", "D"})
+	if len(vars) != 2 || vars[1].Name != "C" || len(missing) != 1 || missing[0] != "D" {
+		t.Fatalf("UnsetEnv = %+v, %v", vars, missing)
+	}
+}
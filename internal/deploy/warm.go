@@ -0,0 +1,273 @@
+package deploy
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/serve"
+)
+
+const (
+	warmPrefix     = "dgx-warm-"
+	warmScriptPath = "/usr/local/lib/dgx/warm.sh"
+
+	// DefaultWarmPrompt is the prompt a warmup request sends
+	DefaultWarmPrompt = "Say hello."
+	// warmMaxTokens keeps warmup requests short; loading the weights is the point
+	warmMaxTokens = 8
+	// warmStartWait is how long a warmup after start waits for the engine to answer, which
+	// for vLLM includes loading the weights
+	warmStartWait = 15 * time.Minute
+	// warmTimerWait is how long a scheduled warmup waits; the engine should already be up
+	warmTimerWait = time.Minute
+)
+
+// warmScript waits up to $2 seconds for the OpenAI API at $1 to list models, then posts
+// the base64-encoded request $4 to its endpoint $3 (chat/completions, embeddings, or
+// rerank). It prints "ready <seconds>" and "warm <status> <seconds>".
+const warmScript = `#!/bin/sh
+# Managed by dgx deploy
+api=$1
+wait=$2
+endpoint=$3
+body=$(echo "$4" | base64 -d)
+start=$(date +%s)
+until curl -sf -o /dev/null "$api/models"; do
+  if [ $(($(date +%s) - start)) -ge "$wait" ]; then
+    echo "timeout $api did not answer within ${wait}s"
+    exit 1
+  fi
+  sleep 5
+done
+echo "ready $(($(date +%s) - start))"
+result=$(curl -s -o /dev/null -w '%{http_code} %{time_total}' -H 'Content-Type: application/json' -d "$body" "$api/$endpoint")
+echo "warm $result"
+case $result in
+2*) exit 0 ;;
+esac
+exit 1
+`
+
+// WarmUnitName returns the systemd unit name (without suffix) for a deployment's scheduled
+// warmup; the schedule is a .timer and .service pair
+func WarmUnitName(name string) string {
+	return warmPrefix + name
+}
+
+// APIBase returns the deployment's OpenAI-compatible API, as seen from the DGX
+func (a Autostart) APIBase() string {
+	switch a.Engine {
+	case "ollama":
+		return "http://localhost:11434/v1"
+	case "vllm":
+		port := a.Port
+		if port == 0 {
+			port = 8000
+		}
+		return fmt.Sprintf("http://localhost:%d/v1", port)
+	default:
+		return "http://localhost:12434/engines/v1"
+	}
+}
+
+// WarmRequest returns the API path (relative to /v1) and body of the request a warmup
+// sends for a model of the given type: a chat completion with a small token limit, an
+// embedding of the prompt, or a rerank of two documents against it. An empty prompt uses
+// DefaultWarmPrompt.
+func WarmRequest(kind, model, prompt string) (string, []byte, error) {
+	if prompt == "" {
+		prompt = DefaultWarmPrompt
+	}
+	var path string
+	var req map[string]any
+	switch kind {
+	case serve.TypeEmbedding:
+		path = "embeddings"
+		req = map[string]any{"model": model, "input": prompt}
+	case serve.TypeRerank:
+		path = "rerank"
+		req = map[string]any{"model": model, "query": prompt, "documents": []string{prompt, "warmup"}}
+	default:
+		path = "chat/completions"
+		req = map[string]any{
+			"model":      model,
+			"messages":   []map[string]string{{"role": "user", "content": prompt}},
+			"max_tokens": warmMaxTokens,
+			"stream":     false,
+		}
+	}
+	body, err := json.Marshal(req)
+	return path, body, err
+}
+
+// warmArgs returns the warm script's arguments for a deployment. They contain only URL and
+// base64 characters, so they are safe to embed in unit files.
+func warmArgs(a Autostart, prompt string, wait time.Duration) (string, error) {
+	path, body, err := WarmRequest(a.Type, a.Model, prompt)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %d %s %s", a.APIBase(), int(wait.Seconds()), path, base64.StdEncoding.EncodeToString(body)), nil
+}
+
+// WarmResult is the outcome of one warmup request
+type WarmResult struct {
+	Ready    time.Duration // time spent waiting for the engine to answer
+	Status   int           // HTTP status of the chat completion
+	Duration time.Duration // time the chat completion took, including any weight loading
+}
+
+// parseWarmOutput reads the warm script's output
+func parseWarmOutput(output string) (WarmResult, error) {
+	var r WarmResult
+	var warmed bool
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "timeout":
+			return r, fmt.Errorf("%s", strings.Join(fields[1:], " "))
+		case "ready":
+			if len(fields) > 1 {
+				seconds, _ := strconv.Atoi(fields[1])
+				r.Ready = time.Duration(seconds) * time.Second
+			}
+		case "warm":
+			if len(fields) < 3 {
+				continue
+			}
+			r.Status, _ = strconv.Atoi(fields[1])
+			seconds, _ := strconv.ParseFloat(fields[2], 64)
+			r.Duration = time.Duration(seconds * float64(time.Second))
+			warmed = true
+		}
+	}
+	if !warmed {
+		return r, fmt.Errorf("no response from the warmup request: %s", strings.TrimSpace(output))
+	}
+	if r.Status < 200 || r.Status >= 300 {
+		return r, fmt.Errorf("warmup request failed with HTTP %d", r.Status)
+	}
+	return r, nil
+}
+
+// Warm sends one warmup request to a deployment, waiting up to wait for the engine to
+// answer first
+func (m *Manager) Warm(a Autostart, prompt string, wait time.Duration) (WarmResult, error) {
+	args, err := warmArgs(a, prompt, wait)
+	if err != nil {
+		return WarmResult{}, err
+	}
+	script := base64.StdEncoding.EncodeToString([]byte(warmScript))
+	output, err := m.sshClient.Execute(fmt.Sprintf("echo %s | base64 -d | sh -s -- %s", script, args))
+	result, parseErr := parseWarmOutput(output)
+	if parseErr != nil {
+		return result, parseErr
+	}
+	if err != nil {
+		return result, fmt.Errorf("warmup failed: %w", err)
+	}
+	return result, nil
+}
+
+// Lookup returns the installed autostart entry with the given name
+func (m *Manager) Lookup(name string) (Autostart, error) {
+	entries, err := m.List()
+	if err != nil {
+		return Autostart{}, err
+	}
+	for _, e := range entries {
+		if e.Name == name {
+			return e, nil
+		}
+	}
+	return Autostart{}, fmt.Errorf("no autostart entry named %q", name)
+}
+
+// RenderWarmUnits returns the service and timer that warm a deployment every interval
+func RenderWarmUnits(a Autostart, every time.Duration) (service, timer string, err error) {
+	args, err := warmArgs(a, "", warmTimerWait)
+	if err != nil {
+		return "", "", err
+	}
+	header := "# Managed by dgx deploy warm; remove with: dgx deploy warm " + a.Name + " --unschedule\n"
+	service = header + fmt.Sprintf(`[Unit]
+Description=dgx warmup: %s
+After=%s
+
+[Service]
+Type=oneshot
+User=%s
+ExecStart=/bin/sh %s %s
+`, a.Name, UnitName(a.Name), a.User, warmScriptPath, args)
+	timer = header + fmt.Sprintf(`[Unit]
+Description=dgx warmup: %s every %v
+
+[Timer]
+OnBootSec=%d
+OnUnitActiveSec=%d
+
+[Install]
+WantedBy=timers.target
+`, a.Name, every, int(every.Seconds()), int(every.Seconds()))
+	return service, timer, nil
+}
+
+// stageWarmScript returns the commands that copy the warm script to the DGX; the caller
+// installs it with sudo
+func stageWarmScript() string {
+	return fmt.Sprintf(`mkdir -p "$HOME/.config/dgx/units" && echo %s | base64 -d > "$HOME/.config/dgx/units/warm.sh"`,
+		base64.StdEncoding.EncodeToString([]byte(warmScript)))
+}
+
+// installWarmScript is the sudo command that installs the staged warm script
+const installWarmScript = `sudo install -D -m 0755 "$HOME/.config/dgx/units/warm.sh" ` + warmScriptPath
+
+// Schedule installs a timer that warms the deployment every interval, so an engine that
+// unloads idle models (Docker Model Runner, Ollama) has them loaded again before users
+// arrive. Installing requires sudo, so the user may be prompted unless in batch mode.
+func (m *Manager) Schedule(a Autostart, every time.Duration) error {
+	if every < time.Minute {
+		return fmt.Errorf("warmup interval must be at least 1m")
+	}
+	service, timer, err := RenderWarmUnits(a, every)
+	if err != nil {
+		return err
+	}
+	unit := WarmUnitName(a.Name)
+	stage := fmt.Sprintf(`%s && echo %s | base64 -d > "$HOME/.config/dgx/units/%s.service" && echo %s | base64 -d > "$HOME/.config/dgx/units/%s.timer"`,
+		stageWarmScript(), base64.StdEncoding.EncodeToString([]byte(service)), unit,
+		base64.StdEncoding.EncodeToString([]byte(timer)), unit)
+	if _, err := m.sshClient.Execute(stage); err != nil {
+		return fmt.Errorf("failed to stage the warmup timer: %w", err)
+	}
+	install := fmt.Sprintf(`%[1]s && sudo install -m 0644 "$HOME/.config/dgx/units/%[2]s.service" "$HOME/.config/dgx/units/%[2]s.timer" %[3]s/ && sudo systemctl daemon-reload && sudo systemctl enable %[2]s.timer && sudo systemctl restart %[2]s.timer`,
+		installWarmScript, unit, unitDir)
+	if err := m.runSudo(install); err != nil {
+		return fmt.Errorf("failed to install %s.timer: %w", unit, err)
+	}
+	return nil
+}
+
+// Unschedule removes a deployment's warmup timer
+func (m *Manager) Unschedule(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid name %q", name)
+	}
+	if err := m.runSudo(removeWarmTimer(name) + " && sudo systemctl daemon-reload"); err != nil {
+		return fmt.Errorf("failed to remove %s.timer: %w", WarmUnitName(name), err)
+	}
+	return nil
+}
+
+// removeWarmTimer returns the commands that stop and delete a warmup timer, if there is one
+func removeWarmTimer(name string) string {
+	return fmt.Sprintf(`sudo systemctl disable --now %[1]s.timer 2>/dev/null; sudo rm -f %[2]s/%[1]s.timer %[2]s/%[1]s.service && rm -f "$HOME/.config/dgx/units/%[1]s.timer" "$HOME/.config/dgx/units/%[1]s.service"`,
+		WarmUnitName(name), unitDir)
+}
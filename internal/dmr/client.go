@@ -0,0 +1,200 @@
+package dmr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultAddr is where Docker Model Runner serves its API on the DGX when host TCP access
+// is enabled (the Docker Engine default)
+const DefaultAddr = "127.0.0.1:12434"
+
+// DialFunc opens a connection as seen from the DGX (typically ssh.Client.Dial)
+type DialFunc func(network, addr string) (net.Conn, error)
+
+// Client talks to the Docker Model Runner engine API over a forwarded connection
+type Client struct {
+	httpClient *http.Client
+}
+
+// NewClient creates a client that reaches the Model Runner at addr on network ("tcp" or
+// "unix") through dial
+func NewClient(dial DialFunc, network, addr string) *Client {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			type result struct {
+				conn net.Conn
+				err  error
+			}
+			done := make(chan result, 1)
+			go func() {
+				conn, err := dial(network, addr)
+				done <- result{conn, err}
+			}()
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case res := <-done:
+				return res.conn, res.err
+			}
+		},
+		IdleConnTimeout: 30 * time.Second,
+	}
+	return &Client{httpClient: &http.Client{Transport: transport}}
+}
+
+// Model is a model in the local Model Runner store
+type Model struct {
+	ID      string      `json:"id"`
+	Tags    []string    `json:"tags"`
+	Created int64       `json:"created"`
+	Config  ModelConfig `json:"config"`
+}
+
+// ModelConfig describes a model's format and size
+type ModelConfig struct {
+	Format       string `json:"format"`
+	Quantization string `json:"quantization"`
+	Parameters   string `json:"parameters"`
+	Architecture string `json:"architecture"`
+	Size         string `json:"size"`
+	ContextSize  *int64 `json:"context_size,omitempty"`
+}
+
+// Runner is a backend process with a model loaded
+type Runner struct {
+	Backend  string    `json:"backend_name"`
+	Model    string    `json:"model_name"`
+	Mode     string    `json:"mode"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+// DiskUsage is the space used by the model store and the default backend
+type DiskUsage struct {
+	Models  int64 `json:"models_disk_usage"`
+	Backend int64 `json:"default_backend_disk_usage"`
+}
+
+// UnloadRequest selects the runners to stop
+type UnloadRequest struct {
+	All     bool     `json:"all"`
+	Backend string   `json:"backend,omitempty"`
+	Models  []string `json:"models,omitempty"`
+}
+
+// ConfigureRequest sets backend options for a model; they apply the next time it loads
+type ConfigureRequest struct {
+	Model        string   `json:"model"`
+	ContextSize  int64    `json:"context-size,omitempty"`
+	RuntimeFlags []string `json:"runtime-flags,omitempty"`
+}
+
+// Status returns each backend's status line, keyed by backend name
+func (c *Client) Status(ctx context.Context) (map[string]string, error) {
+	var status map[string]string
+	err := c.do(ctx, http.MethodGet, "/engines/status", nil, &status)
+	return status, err
+}
+
+// Models lists the models in the store
+func (c *Client) Models(ctx context.Context) ([]Model, error) {
+	var models []Model
+	err := c.do(ctx, http.MethodGet, "/models", nil, &models)
+	return models, err
+}
+
+// Inspect returns one model by reference or ID
+func (c *Client) Inspect(ctx context.Context, ref string) (*Model, error) {
+	var model Model
+	if err := c.do(ctx, http.MethodGet, "/models/"+escapeRef(ref), nil, &model); err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+// Running lists the loaded runners
+func (c *Client) Running(ctx context.Context) ([]Runner, error) {
+	var runners []Runner
+	err := c.do(ctx, http.MethodGet, "/engines/ps", nil, &runners)
+	return runners, err
+}
+
+// DiskUsage reports the store and backend disk usage
+func (c *Client) DiskUsage(ctx context.Context) (*DiskUsage, error) {
+	var usage DiskUsage
+	if err := c.do(ctx, http.MethodGet, "/engines/df", nil, &usage); err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+// Unload stops the selected runners and returns how many were stopped
+func (c *Client) Unload(ctx context.Context, req UnloadRequest) (int, error) {
+	var resp struct {
+		Unloaded int `json:"unloaded_runners"`
+	}
+	err := c.do(ctx, http.MethodPost, "/engines/unload", req, &resp)
+	return resp.Unloaded, err
+}
+
+// Configure sets backend options for a model
+func (c *Client) Configure(ctx context.Context, req ConfigureRequest) error {
+	return c.do(ctx, http.MethodPost, "/engines/_configure", req, nil)
+}
+
+// do sends a request with an optional JSON body and decodes a JSON response into out
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	// The host is ignored by the dialer; it only has to be a valid URL
+	req, err := http.NewRequestWithContext(ctx, method, "http://model-runner"+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach Docker Model Runner: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("model runner returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// escapeRef escapes a model reference for a URL path, keeping the "/" between namespace
+// and name that the API expects unescaped
+func escapeRef(ref string) string {
+	parts := strings.Split(ref, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,66 @@
+package dmr
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClient(t *testing.T) {
+	var unload UnloadRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.EscapedPath() {
+		case "GET /models":
+			w.Write([]byte(`[{"id":"sha256:abc","tags":["ai/smollm2"],"created":1700000000,"config":{"format":"gguf","parameters":"361.82 M","size":"256.35 MiB"}}]`))
+		case "GET /models/ai/smollm2:360M-Q4_K_M":
+			w.Write([]byte(`{"id":"sha256:abc","tags":["ai/smollm2:360M-Q4_K_M"],"config":{"context_size":8192}}`))
+		case "GET /engines/ps":
+			w.Write([]byte(`[{"backend_name":"llama.cpp","model_name":"ai/smollm2","mode":"completion","last_used":"2025-01-01T12:00:00Z"}]`))
+		case "POST /engines/unload":
+			json.NewDecoder(r.Body).Decode(&unload)
+			w.Write([]byte(`{"unloaded_runners":2}`))
+		default:
+			http.Error(w, "model not found", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	var dialed string
+	dial := func(network, addr string) (net.Conn, error) {
+		dialed = network + " " + addr
+		return net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	}
+	client := NewClient(dial, "unix", "/run/model-runner.sock")
+	ctx := context.Background()
+
+	models, err := client.Models(ctx)
+	if err != nil || len(models) != 1 || models[0].Tags[0] != "ai/smollm2" || models[0].Config.Parameters != "361.82 M" {
+		t.Fatalf("unexpected models %+v err=%v", models, err)
+	}
+	if dialed != "unix /run/model-runner.sock" {
+		t.Fatalf("dialed %q", dialed)
+	}
+
+	model, err := client.Inspect(ctx, "ai/smollm2:360M-Q4_K_M")
+	if err != nil || model.Config.ContextSize == nil || *model.Config.ContextSize != 8192 {
+		t.Fatalf("unexpected model %+v err=%v", model, err)
+	}
+
+	runners, err := client.Running(ctx)
+	if err != nil || len(runners) != 1 || runners[0].Backend != "llama.cpp" || runners[0].LastUsed.IsZero() {
+		t.Fatalf("unexpected runners %+v err=%v", runners, err)
+	}
+
+	n, err := client.Unload(ctx, UnloadRequest{Models: []string{"ai/smollm2"}})
+	if err != nil || n != 2 || len(unload.Models) != 1 || unload.All {
+		t.Fatalf("unexpected unload result %d %+v err=%v", n, unload, err)
+	}
+
+	if _, err := client.Inspect(ctx, "missing"); err == nil || !strings.Contains(err.Error(), "404 Not Found: model not found") {
+		t.Fatalf("expected a not-found error, got %v", err)
+	}
+}
@@ -0,0 +1,336 @@
+// Package events merges Docker container events, systemd unit state changes, and Docker
+// Model Runner model loads and unloads on the DGX into one timestamped feed.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/dmr"
+	"github.com/weatherman/dgx-manager/internal/poll"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+// Event sources
+const (
+	SourceDocker  = "docker"
+	SourceSystemd = "systemd"
+	SourceDMR     = "dmr"
+)
+
+// Sources lists every event source, in the order they are shown in help
+var Sources = []string{SourceDocker, SourceSystemd, SourceDMR}
+
+// DefaultPollInterval is how often the loaded Model Runner models are compared
+const DefaultPollInterval = 2 * time.Second
+
+// Event is one entry in the feed. Kind is what changed (container, unit, or model) and
+// Action what happened to it, such as start, die, failed, or load.
+type Event struct {
+	Time   time.Time `json:"time"`
+	Source string    `json:"source"`
+	Kind   string    `json:"kind"`
+	Name   string    `json:"name"`
+	Action string    `json:"action"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// String formats the event as one aligned line in the local time zone
+func (e Event) String() string {
+	line := fmt.Sprintf("%s  %-7s  %-9s  %-24s  %-13s", e.Time.Local().Format("2006-01-02 15:04:05"), e.Source, e.Kind, e.Name, e.Action)
+	if e.Detail != "" {
+		line += "  " + e.Detail
+	}
+	return strings.TrimRight(line, " ")
+}
+
+// dockerEvents are the container events in the feed; exec, attach, and resize events are
+// left out as noise
+var dockerEvents = []string{"create", "start", "restart", "die", "oom", "kill", "stop", "destroy", "pause", "unpause", "health_status"}
+
+// DockerCommand returns the docker events command for the feed. since is a duration such
+// as 10m; without follow the command stops at the present.
+func DockerCommand(since string, follow bool) string {
+	var b strings.Builder
+	b.WriteString("docker events --format '{{json .}}' --filter type=container")
+	for _, e := range dockerEvents {
+		b.WriteString(" --filter event=" + e)
+	}
+	if since != "" {
+		b.WriteString(" --since " + ssh.ShellQuote(since))
+	}
+	if !follow {
+		b.WriteString(` --until "$(date +%s)"`)
+	}
+	return b.String()
+}
+
+// dockerEvent is the part of docker's event JSON used here
+type dockerEvent struct {
+	Type   string `json:"Type"`
+	Action string `json:"Action"`
+	Actor  struct {
+		ID         string            `json:"ID"`
+		Attributes map[string]string `json:"Attributes"`
+	} `json:"Actor"`
+	TimeNano int64 `json:"timeNano"`
+}
+
+// ParseDocker reads one line of DockerCommand output
+func ParseDocker(line string) (Event, bool) {
+	var d dockerEvent
+	if err := json.Unmarshal([]byte(line), &d); err != nil || d.Action == "" {
+		return Event{}, false
+	}
+	e := Event{Time: time.Unix(0, d.TimeNano), Source: SourceDocker, Kind: d.Type, Name: d.Actor.Attributes["name"], Action: d.Action}
+	if e.Name == "" && len(d.Actor.ID) >= 12 {
+		e.Name = d.Actor.ID[:12]
+	}
+	// health_status arrives as "health_status: healthy"
+	if action, status, ok := strings.Cut(d.Action, ": "); ok {
+		e.Action, e.Detail = action, status
+	}
+	switch e.Action {
+	case "die":
+		e.Detail = "exit " + d.Actor.Attributes["exitCode"]
+	case "create", "start":
+		e.Detail = d.Actor.Attributes["image"]
+	}
+	return e, true
+}
+
+// SystemdCommand returns the journalctl command that prints systemd's unit job results
+func SystemdCommand(since string, follow bool) string {
+	cmd := "journalctl -q -o json --output-fields=UNIT,MESSAGE,JOB_TYPE,JOB_RESULT,UNIT_RESULT _PID=1"
+	switch {
+	case since != "":
+		cmd += " --since " + ssh.ShellQuote("-"+since)
+	case follow:
+		cmd += " -n 0"
+	}
+	if follow {
+		cmd += " -f"
+	}
+	return cmd
+}
+
+// ParseSystemd reads one line of SystemdCommand output. Only finished jobs and unit
+// failures become events; session scopes and slices are left out.
+func ParseSystemd(line string) (Event, bool) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return Event{}, false
+	}
+	get := func(name string) string {
+		s, _ := fields[name].(string)
+		return s
+	}
+	unit := get("UNIT")
+	if unit == "" || strings.HasSuffix(unit, ".scope") || strings.HasSuffix(unit, ".slice") {
+		return Event{}, false
+	}
+	micros, err := strconv.ParseInt(get("__REALTIME_TIMESTAMP"), 10, 64)
+	if err != nil {
+		return Event{}, false
+	}
+
+	var action string
+	switch jobType, result := get("JOB_TYPE"), get("JOB_RESULT"); {
+	case get("UNIT_RESULT") != "", result == "failed":
+		action = "failed"
+	case result != "done":
+		return Event{}, false
+	case jobType == "start":
+		action = "started"
+	case jobType == "stop":
+		action = "stopped"
+	case jobType == "restart":
+		action = "restarted"
+	case jobType == "reload":
+		action = "reloaded"
+	default:
+		return Event{}, false
+	}
+	return Event{Time: time.UnixMicro(micros), Source: SourceSystemd, Kind: "unit", Name: unit, Action: action, Detail: get("MESSAGE")}, true
+}
+
+// DiffRunners returns a load event for each runner in after that is not in before, and an
+// unload event for each that is gone
+func DiffRunners(before, after []dmr.Runner, now time.Time) []Event {
+	key := func(r dmr.Runner) string { return r.Model + "\x00" + r.Backend + "\x00" + r.Mode }
+	was := make(map[string]bool, len(before))
+	for _, r := range before {
+		was[key(r)] = true
+	}
+	is := make(map[string]bool, len(after))
+	var events []Event
+	for _, r := range after {
+		is[key(r)] = true
+		if !was[key(r)] {
+			events = append(events, runnerEvent(r, "load", now))
+		}
+	}
+	for _, r := range before {
+		if !is[key(r)] {
+			events = append(events, runnerEvent(r, "unload", now))
+		}
+	}
+	return events
+}
+
+func runnerEvent(r dmr.Runner, action string, now time.Time) Event {
+	detail := r.Backend
+	if r.Mode != "" {
+		detail += " " + r.Mode
+	}
+	return Event{Time: now, Source: SourceDMR, Kind: "model", Name: r.Model, Action: action, Detail: detail}
+}
+
+// Streamer runs a command on the DGX, copying its output; *ssh.Client implements it
+type Streamer interface {
+	Stream(command string, stdin io.Reader, stdout, stderr io.Writer) error
+}
+
+// Options select the sources Collect reads and how
+type Options struct {
+	Sources []string
+	Since   string // a duration such as 10m; history before now is read first
+	Follow  bool
+
+	// Running lists the loaded Model Runner models; the dmr source polls it while
+	// following, every PollInterval or as Poll paces loops on Host
+	Running      func(ctx context.Context) ([]dmr.Runner, error)
+	PollInterval time.Duration
+	Poll         *poll.Scheduler
+	Host         string
+
+	// Warn is told about a source that failed; the others carry on
+	Warn func(source string, err error)
+}
+
+// Collect passes the events of the selected sources to emit, one at a time. Without
+// Follow it returns once the history is read, emitting it in time order; with Follow it
+// emits events as they arrive until ctx ends. Model Runner events are only seen while
+// following, since the runner keeps no history of loads.
+func Collect(ctx context.Context, client Streamer, opts Options, emit func(Event)) {
+	var mu sync.Mutex
+	var history []Event
+	add := func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if opts.Follow {
+			emit(e)
+		} else {
+			history = append(history, e)
+		}
+	}
+	warn := func(source string, err error) {
+		if opts.Warn != nil {
+			opts.Warn(source, err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	stream := func(source, command string, parse func(string) (Event, bool)) {
+		defer wg.Done()
+		w := &lineWriter{onLine: func(line string) {
+			if e, ok := parse(line); ok {
+				add(e)
+			}
+		}}
+		var stderr strings.Builder
+		if err := client.Stream(ssh.WithParseLocale(command), nil, w, &stderr); err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				err = errors.New(firstLine(msg))
+			}
+			warn(source, err)
+		}
+	}
+	for _, source := range opts.Sources {
+		switch source {
+		case SourceDocker:
+			wg.Add(1)
+			go stream(source, DockerCommand(opts.Since, opts.Follow), ParseDocker)
+		case SourceSystemd:
+			wg.Add(1)
+			go stream(source, SystemdCommand(opts.Since, opts.Follow), ParseSystemd)
+		case SourceDMR:
+			if opts.Follow && opts.Running != nil {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					pollRunners(ctx, opts, add, warn)
+				}()
+			}
+		}
+	}
+	wg.Wait()
+
+	sort.SliceStable(history, func(i, j int) bool { return history[i].Time.Before(history[j].Time) })
+	for _, e := range history {
+		emit(e)
+	}
+}
+
+// pollRunners compares the loaded models every PollInterval, as Poll paces it, until ctx
+// ends. The first list is the baseline, so models loaded before the feed started are not reported.
+func pollRunners(ctx context.Context, opts Options, add func(Event), warn func(string, error)) {
+	interval := opts.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	before, err := opts.Running(ctx)
+	if err != nil {
+		warn(SourceDMR, err)
+		return
+	}
+	loop := opts.Poll.Register(opts.Host, SourceDMR, interval)
+	defer loop.Close()
+	failing := false
+	for loop.Wait(ctx) {
+		after, err := opts.Running(ctx)
+		if err != nil {
+			// Warn once per outage, such as the runner restarting; polling carries on
+			if !failing {
+				warn(SourceDMR, err)
+			}
+			failing = true
+			continue
+		}
+		failing = false
+		for _, e := range DiffRunners(before, after, time.Now()) {
+			add(e)
+		}
+		before = after
+	}
+}
+
+// lineWriter calls onLine for each complete line written to it
+type lineWriter struct {
+	partial string
+	onLine  func(string)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	lines := strings.Split(w.partial+string(p), "\n")
+	w.partial = lines[len(lines)-1]
+	for _, line := range lines[:len(lines)-1] {
+		w.onLine(strings.TrimSpace(line))
+	}
+	return len(p), nil
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
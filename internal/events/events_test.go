@@ -0,0 +1,94 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/dmr"
+)
+
+const (
+	dockerDie   = `{"status":"die","id":"4f2a9c1b7e3d","Type":"container","Action":"die","Actor":{"ID":"4f2a9c1b7e3d8a","Attributes":{"exitCode":"137","image":"vllm/vllm-openai","name":"vllm-server"}},"time":1760443200,"timeNano":1760443200000000000}`
+	dockerState = `{"Type":"container","Action":"health_status: unhealthy","Actor":{"ID":"4f2a9c1b7e3d8a","Attributes":{"name":"vllm-server"}},"timeNano":1760443100000000000}`
+	unitFailed  = `{"__REALTIME_TIMESTAMP":"1760443150000000","UNIT":"dgx-autostart-llama.service","MESSAGE":"dgx-autostart-llama.service: Failed with result 'exit-code'.","UNIT_RESULT":"exit-code"}`
+	unitStarted = `{"__REALTIME_TIMESTAMP":"1760443000000000","UNIT":"docker.service","MESSAGE":"Started docker.service","JOB_TYPE":"start","JOB_RESULT":"done"}`
+	unitSession = `{"__REALTIME_TIMESTAMP":"1760443000000000","UNIT":"session-4.scope","JOB_TYPE":"start","JOB_RESULT":"done"}`
+)
+
+func TestParseDocker(t *testing.T) {
+	e, ok := ParseDocker(dockerDie)
+	if !ok || e.Name != "vllm-server" || e.Action != "die" || e.Detail != "exit 137" || e.Time.Unix() != 1760443200 {
+		t.Fatalf("ParseDocker(die) = %+v, %v", e, ok)
+	}
+	e, ok = ParseDocker(dockerState)
+	if !ok || e.Action != "health_status" || e.Detail != "unhealthy" {
+		t.Fatalf("ParseDocker(health_status) = %+v, %v", e, ok)
+	}
+	if _, ok := ParseDocker("Error response from daemon"); ok {
+		t.Fatalf("ParseDocker accepted a non-JSON line")
+	}
+}
+
+func TestParseSystemd(t *testing.T) {
+	cases := map[string]string{unitFailed: "failed", unitStarted: "started", unitSession: ""}
+	for line, want := range cases {
+		e, ok := ParseSystemd(line)
+		if ok != (want != "") || e.Action != want {
+			t.Fatalf("ParseSystemd(%s) = %+v, %v; want action %q", line, e, ok, want)
+		}
+	}
+}
+
+func TestDiffRunners(t *testing.T) {
+	now := time.Now()
+	before := []dmr.Runner{{Model: "ai/smollm2", Backend: "llama.cpp", Mode: "completion"}}
+	after := []dmr.Runner{{Model: "ai/qwen3", Backend: "llama.cpp", Mode: "completion"}}
+	events := DiffRunners(before, after, now)
+	if len(events) != 2 || events[0].Action != "load" || events[0].Name != "ai/qwen3" || events[1].Action != "unload" || events[1].Name != "ai/smollm2" {
+		t.Fatalf("DiffRunners() = %+v", events)
+	}
+	if events := DiffRunners(after, after, now); len(events) != 0 {
+		t.Fatalf("DiffRunners(same) = %+v, want none", events)
+	}
+}
+
+type fakeStreamer map[string]string
+
+func (f fakeStreamer) Stream(command string, stdin io.Reader, stdout, stderr io.Writer) error {
+	for prefix, output := range f {
+		if strings.Contains(command, prefix) {
+			io.WriteString(stdout, output)
+			return nil
+		}
+	}
+	io.WriteString(stderr, "permission denied\n")
+	return fmt.Errorf("exit status 1")
+}
+
+func TestCollectHistory(t *testing.T) {
+	client := fakeStreamer{
+		"docker events": dockerDie + "\n" + dockerState + "\n",
+		"journalctl":    unitStarted + "\n" + unitSession + "\n" + unitFailed + "\n",
+	}
+	var got []string
+	Collect(context.Background(), client, Options{Sources: Sources, Since: "1h"}, func(e Event) {
+		got = append(got, e.Source+" "+e.Action)
+	})
+	want := "systemd started,docker health_status,systemd failed,docker die"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("Collect() = %v, want %s", got, want)
+	}
+
+	var warned []string
+	Collect(context.Background(), fakeStreamer{"docker events": ""}, Options{
+		Sources: []string{SourceDocker, SourceSystemd},
+		Warn:    func(source string, err error) { warned = append(warned, source+": "+err.Error()) },
+	}, func(Event) {})
+	if len(warned) != 1 || warned[0] != "systemd: permission denied" {
+		t.Fatalf("warnings = %v", warned)
+	}
+}
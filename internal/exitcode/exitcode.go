@@ -0,0 +1,52 @@
+package exitcode
+
+import "errors"
+
+// Process exit codes. They are part of the CLI contract (see "Exit Codes" in the README),
+// so existing values must not change.
+const (
+	OK         = 0
+	General    = 1 // any failure not covered below
+	Config     = 2 // missing or invalid configuration
+	Connection = 3 // could not reach or authenticate to the DGX
+	Remote     = 4 // a command on the DGX exited non-zero
+	Aborted    = 5 // the user declined a confirmation prompt
+	Usage      = 6 // invalid command line
+)
+
+// ErrAborted is returned when the user declines to continue
+var ErrAborted = errors.New("aborted")
+
+// Error tags an error with the exit code it should produce
+type Error struct {
+	Code int
+	Err  error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Wrap tags err with code; a nil err stays nil
+func Wrap(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Code: code, Err: err}
+}
+
+// Of returns the exit code for err. The outermost tag wins, so callers can reclassify an
+// error from a lower layer by wrapping it again.
+func Of(err error) int {
+	if err == nil {
+		return OK
+	}
+	var coded *Error
+	if errors.As(err, &coded) {
+		return coded.Code
+	}
+	if errors.Is(err, ErrAborted) {
+		return Aborted
+	}
+	return General
+}
@@ -0,0 +1,33 @@
+package exitcode
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestOf(t *testing.T) {
+	remote := Wrap(Remote, errors.New("exit status 2"))
+	cases := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil", nil, OK},
+		{"untagged", errors.New("boom"), General},
+		{"tagged", remote, Remote},
+		{"wrapped tag", fmt.Errorf("failed to pull model: %w", remote), Remote},
+		{"outermost tag wins", Wrap(Connection, fmt.Errorf("failed to reconnect: %w", remote)), Connection},
+		{"aborted", fmt.Errorf("connection %w: host key not trusted", ErrAborted), Aborted},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Of(tc.err); got != tc.want {
+				t.Fatalf("Of(%v) = %d, want %d", tc.err, got, tc.want)
+			}
+		})
+	}
+	if Wrap(Config, nil) != nil {
+		t.Fatalf("Wrap(nil) should stay nil")
+	}
+}
@@ -0,0 +1,272 @@
+package firmware
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/state"
+	"gopkg.in/yaml.v3"
+)
+
+// Release describes the newest known version of a firmware/software component
+type Release struct {
+	Component  string `yaml:"component"`
+	Version    string `yaml:"version"`
+	UpdateHint string `yaml:"update_hint,omitempty"`
+}
+
+// Component is the installed state of a single firmware/software component
+type Component struct {
+	Name            string
+	Installed       string
+	Latest          string
+	UpdateAvailable bool
+	UpdateHint      string
+}
+
+const fwupdHint = `dgx connect, then: sudo fwupdmgr refresh && sudo fwupdmgr update`
+
+// KnownLatest is the built-in release metadata. Bump it when NVIDIA ships new DGX OS bits;
+// users can override it with --metadata until a new CLI release is cut.
+var KnownLatest = []Release{
+	{
+		Component:  "NVIDIA driver",
+		Version:    "580.95.05",
+		UpdateHint: `dgx exec "sudo apt-get update && sudo apt-get -y full-upgrade" (reboot afterwards: dgx reboot --wait)`,
+	},
+}
+
+// probes maps component names to remote commands that print the installed version
+var probes = []struct {
+	name    string
+	command string
+}{
+	{"DGX OS", `. /etc/dgx-release 2>/dev/null && echo "$DGX_SWBUILD_VERSION"`},
+	{"SBIOS", "cat /sys/class/dmi/id/bios_version"},
+	{"SBIOS date", "cat /sys/class/dmi/id/bios_date"},
+	{"GPU VBIOS", "nvidia-smi --query-gpu=vbios_version --format=csv,noheader | head -n1"},
+	{"NVIDIA driver", "nvidia-smi --query-gpu=driver_version --format=csv,noheader | head -n1"},
+}
+
+// CacheTTL is how long probed versions are reused; they only change on updates
+const CacheTTL = 15 * time.Minute
+
+// Checker queries firmware versions on the DGX
+type Checker struct {
+	sshClient *ssh.Client
+	releases  []Release
+	cache     *state.Cache
+	cacheKey  string
+}
+
+// installed is the cached result of the version probes
+type installed struct {
+	Versions map[string]string
+	FWUpd    []Component
+}
+
+// NewChecker creates a new firmware checker using the built-in release metadata
+func NewChecker(sshClient *ssh.Client) *Checker {
+	return &Checker{
+		sshClient: sshClient,
+		releases:  KnownLatest,
+	}
+}
+
+// LoadMetadata replaces the built-in release metadata with entries from a YAML file
+func (c *Checker) LoadMetadata(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read metadata: %w", err)
+	}
+	var releases []Release
+	if err := yaml.Unmarshal(data, &releases); err != nil {
+		return fmt.Errorf("failed to parse metadata: %w", err)
+	}
+	c.releases = releases
+	return nil
+}
+
+// UseCache reuses probed versions for host from cache for up to CacheTTL
+func (c *Checker) UseCache(cache *state.Cache, host string) {
+	c.cache = cache
+	c.cacheKey = CacheKey(host)
+}
+
+// CacheKey is the cache entry holding host's probed versions
+func CacheKey(host string) string {
+	return state.Key("firmware", host)
+}
+
+// Status returns installed versions compared against the known-latest metadata,
+// followed by any pending updates reported by fwupd on the device.
+func (c *Checker) Status() ([]Component, error) {
+	var probed installed
+	_, err := c.cache.Fetch(c.cacheKey, CacheTTL, &probed, func() error { return c.probe(&probed) })
+
+	components := make([]Component, 0, len(probes))
+	for _, probe := range probes {
+		version := probed.Versions[probe.name]
+		component := Component{Name: probe.name, Installed: version}
+		if release := c.release(probe.name); release != nil {
+			component.Latest = release.Version
+			component.UpdateHint = release.UpdateHint
+			component.UpdateAvailable = version != "unknown" && CompareVersions(version, release.Version) < 0
+		}
+		components = append(components, component)
+	}
+	return append(components, probed.FWUpd...), err
+}
+
+// probe runs the version probes and the fwupd query. The versions are filled in even
+// when fwupd fails, but the result is then not cached.
+func (c *Checker) probe(out *installed) error {
+	out.Versions = make(map[string]string, len(probes))
+	for _, probe := range probes {
+		output, err := c.sshClient.Execute(probe.command)
+		version := strings.TrimSpace(output)
+		if err != nil || version == "" {
+			version = "unknown"
+		}
+		out.Versions[probe.name] = version
+	}
+
+	fwupd, err := c.fwupdUpdates()
+	out.FWUpd = fwupd
+	return err
+}
+
+func (c *Checker) release(name string) *Release {
+	for i := range c.releases {
+		if strings.EqualFold(c.releases[i].Component, name) {
+			return &c.releases[i]
+		}
+	}
+	return nil
+}
+
+// fwupdUpdates returns devices that fwupd reports as having newer firmware available
+func (c *Checker) fwupdUpdates() ([]Component, error) {
+	output, err := c.sshClient.Execute("command -v fwupdmgr >/dev/null 2>&1 || exit 0; fwupdmgr get-updates --json 2>/dev/null || true")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query fwupd: %w", err)
+	}
+	output = strings.TrimSpace(output)
+	if output == "" || !strings.HasPrefix(output, "{") {
+		return nil, nil
+	}
+
+	var result struct {
+		Devices []struct {
+			Name     string `json:"Name"`
+			Version  string `json:"Version"`
+			Releases []struct {
+				Version string `json:"Version"`
+			} `json:"Releases"`
+		} `json:"Devices"`
+	}
+	if err := json.Unmarshal([]byte(output), &result); err != nil {
+		return nil, fmt.Errorf("failed to parse fwupd output: %w", err)
+	}
+
+	var components []Component
+	for _, device := range result.Devices {
+		if len(device.Releases) == 0 {
+			continue
+		}
+		components = append(components, Component{
+			Name:            device.Name,
+			Installed:       device.Version,
+			Latest:          device.Releases[0].Version,
+			UpdateAvailable: true,
+			UpdateHint:      fwupdHint,
+		})
+	}
+	return components, nil
+}
+
+// CompareVersions compares dotted version strings numerically segment by segment.
+// It returns -1 if a < b, 0 if equal, and 1 if a > b. Missing numeric segments count as
+// zero, so "1.2" equals "1.2.0". Segments after the leading numeric ones mark a
+// pre-release, which sorts before the release it precedes ("1.2.0-rc1" < "1.2.0") and
+// compares with other pre-releases segment by segment, non-numeric segments lexically.
+func CompareVersions(a, b string) int {
+	aCore, aPre := splitVersion(a)
+	bCore, bPre := splitVersion(b)
+	for i := 0; i < len(aCore) || i < len(bCore); i++ {
+		var x, y int
+		if i < len(aCore) {
+			x = aCore[i]
+		}
+		if i < len(bCore) {
+			y = bCore[i]
+		}
+		if x != y {
+			return cmp.Compare(x, y)
+		}
+	}
+	switch {
+	case len(aPre) == 0 && len(bPre) == 0:
+		return 0
+	case len(aPre) == 0:
+		return 1
+	case len(bPre) == 0:
+		return -1
+	}
+	for i := 0; i < len(aPre) && i < len(bPre); i++ {
+		x, y := aPre[i], bPre[i]
+		xi, xErr := strconv.Atoi(x)
+		yi, yErr := strconv.Atoi(y)
+		switch {
+		case xErr == nil && yErr == nil:
+			if xi != yi {
+				return cmp.Compare(xi, yi)
+			}
+		case x != y:
+			return strings.Compare(x, y)
+		}
+	}
+	return cmp.Compare(len(aPre), len(bPre))
+}
+
+// splitVersion splits v into its leading numeric segments and the segments after them
+func splitVersion(v string) (core []int, pre []string) {
+	segments := strings.FieldsFunc(v, func(r rune) bool {
+		return r == '.' || r == '-' || r == '_' || r == '+' || r == ' '
+	})
+	for i, seg := range segments {
+		n, err := strconv.Atoi(seg)
+		if err != nil {
+			return core, segments[i:]
+		}
+		core = append(core, n)
+	}
+	return core, nil
+}
+
+// FormatStatus renders components as a table
+func FormatStatus(components []Component) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%-22s %-28s %-16s %s\n", "COMPONENT", "INSTALLED", "LATEST", "STATUS"))
+	for _, c := range components {
+		latest := c.Latest
+		if latest == "" {
+			latest = "-"
+		}
+		status := "ok"
+		switch {
+		case c.UpdateAvailable:
+			status = "update available"
+		case c.Latest == "":
+			status = "untracked"
+		}
+		sb.WriteString(fmt.Sprintf("%-22s %-28s %-16s %s\n", c.Name, c.Installed, latest, status))
+	}
+	return sb.String()
+}
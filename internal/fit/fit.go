@@ -0,0 +1,195 @@
+// Package fit estimates how much memory a model needs at different quantizations and
+// context lengths, and whether that fits on one DGX Spark or a two-node pair.
+package fit
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// NodeMemory is the memory a model can use on one Spark: of the 128 GB of unified
+	// memory, the OS, firmware carve-outs, and the desktop keep roughly 10 GiB
+	NodeMemory int64 = 110 << 30
+
+	// overheadFraction and overheadBytes cover activations, the CUDA context, and the
+	// runtime's buffers on top of the weights and KV cache
+	overheadFraction = 0.08
+	overheadBytes    = 2 << 30
+
+	// kvBytes is the size of one cached key or value element (fp16/bf16)
+	kvBytes = 2
+)
+
+// Quantization is a weight format with its average storage cost, including the scales
+// of block formats
+type Quantization struct {
+	Name string
+	Bits float64
+}
+
+// Quantizations lists the formats reported, from largest to smallest
+var Quantizations = []Quantization{
+	{"BF16", 16},
+	{"FP8", 8},
+	{"Q8_0", 8.5},
+	{"Q6_K", 6.56},
+	{"Q5_K_M", 5.69},
+	{"Q4_K_M", 4.85},
+	{"NVFP4", 4.5},
+}
+
+// DefaultContexts are the context lengths reported when none are given
+var DefaultContexts = []int{4096, 32768, 131072}
+
+// Model is what the estimate needs to know about a model. Parameters is required; the
+// architecture fields are zero when unknown, and the KV cache is then approximated.
+type Model struct {
+	Ref          string `json:"ref"`
+	Source       string `json:"source"`
+	Parameters   int64  `json:"parameters"`
+	Layers       int    `json:"layers,omitempty"`
+	KVHeads      int    `json:"kv_heads,omitempty"`
+	HeadDim      int    `json:"head_dim,omitempty"`
+	MaxContext   int    `json:"max_context,omitempty"`
+	Quantization string `json:"quantization,omitempty"` // format of the published weights, when known
+}
+
+// KVBytesPerToken returns the KV cache size of one token of context
+func (m Model) KVBytesPerToken() (bytes int64, exact bool) {
+	if m.Layers > 0 && m.KVHeads > 0 && m.HeadDim > 0 {
+		return int64(2 * m.Layers * m.KVHeads * m.HeadDim * kvBytes), true
+	}
+	// Grouped-query attention models land near 40 KiB per token times the square root of
+	// the parameter count in billions (Llama 3.1 8B: 128 KiB, 70B: 320 KiB)
+	return int64(40 * 1024 * math.Sqrt(float64(m.Parameters)/1e9)), false
+}
+
+// Placement is where an estimate fits
+type Placement int
+
+const (
+	FitsNode Placement = iota
+	FitsPair
+	NoFit
+)
+
+// String names the placement for tables
+func (p Placement) String() string {
+	switch p {
+	case FitsNode:
+		return "1 node"
+	case FitsPair:
+		return "2 nodes"
+	}
+	return "no fit"
+}
+
+// Estimate is the memory needed for one quantization and context length
+type Estimate struct {
+	Quantization string    `json:"quantization"`
+	Context      int       `json:"context"`
+	Weights      int64     `json:"weights_bytes"`
+	KVCache      int64     `json:"kv_cache_bytes"`
+	Total        int64     `json:"total_bytes"`
+	Placement    Placement `json:"-"`
+	Fits         string    `json:"fits"`
+}
+
+// Estimate returns the memory m needs with q weights and a cache for context tokens. On
+// a pair the weights and cache are split across both nodes by tensor parallelism, and
+// each node pays the runtime overhead.
+func (m Model) Estimate(q Quantization, context int) Estimate {
+	weights := int64(float64(m.Parameters) * q.Bits / 8)
+	perToken, _ := m.KVBytesPerToken()
+	kv := perToken * int64(context)
+	e := Estimate{Quantization: q.Name, Context: context, Weights: weights, KVCache: kv}
+	e.Total = weights + kv + int64(float64(weights+kv)*overheadFraction) + overheadBytes
+
+	switch {
+	case e.Total <= NodeMemory:
+		e.Placement = FitsNode
+	case (e.Total-overheadBytes)/2+overheadBytes <= NodeMemory:
+		e.Placement = FitsPair
+	default:
+		e.Placement = NoFit
+	}
+	e.Fits = e.Placement.String()
+	return e
+}
+
+// Contexts returns the context lengths to report: the given ones, or the defaults capped
+// at the model's maximum
+func (m Model) Contexts(requested []int) []int {
+	if len(requested) > 0 {
+		return requested
+	}
+	var contexts []int
+	for _, c := range DefaultContexts {
+		if m.MaxContext > 0 && c > m.MaxContext {
+			break
+		}
+		contexts = append(contexts, c)
+	}
+	if m.MaxContext > 0 && (len(contexts) == 0 || contexts[len(contexts)-1] < m.MaxContext) {
+		contexts = append(contexts, m.MaxContext)
+	}
+	return contexts
+}
+
+// parameterPattern finds a parameter count in a model name or DMR metadata, such as
+// "70B", "360M", "8.03 B", or the "8x7B" of mixtures of experts
+var parameterPattern = regexp.MustCompile(`(?i)(?:^|[^a-z0-9.])(?:(\d+)x)?(\d+(?:\.\d+)?)\s?([bm])(?:$|[^a-z])`)
+
+// ParseParameters reads a parameter count such as "70B", "360M", or "8.03 B", or one
+// embedded in a model name such as meta-llama/Llama-3.1-70B-Instruct
+func ParseParameters(s string) (int64, bool) {
+	match := parameterPattern.FindStringSubmatch(s)
+	if match == nil {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(match[2], 64)
+	if err != nil || v == 0 {
+		return 0, false
+	}
+	if match[1] != "" {
+		experts, _ := strconv.Atoi(match[1])
+		v *= float64(experts)
+	}
+	if strings.EqualFold(match[3], "b") {
+		return int64(math.Round(v * 1e9)), true
+	}
+	return int64(math.Round(v * 1e6)), true
+}
+
+// FormatParameters renders a parameter count the way model cards do, e.g. "70.6B"
+func FormatParameters(n int64) string {
+	if n >= 1e9 {
+		return strconv.FormatFloat(float64(n)/1e9, 'f', 1, 64) + "B"
+	}
+	return strconv.FormatFloat(float64(n)/1e6, 'f', 0, 64) + "M"
+}
+
+// FormatContext renders a context length as 4K, 128K, or the exact count
+func FormatContext(n int) string {
+	if n >= 1024 && n%1024 == 0 {
+		return fmt.Sprintf("%dK", n/1024)
+	}
+	return strconv.Itoa(n)
+}
+
+// ParseContext reads a context length such as 8192, 8K, or 128k
+func ParseContext(s string) (int, error) {
+	number, mult := strings.TrimSpace(s), 1
+	if strings.HasSuffix(strings.ToUpper(number), "K") {
+		number, mult = number[:len(number)-1], 1024
+	}
+	n, err := strconv.Atoi(number)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid context length %q", s)
+	}
+	return n * mult, nil
+}
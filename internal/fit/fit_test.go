@@ -0,0 +1,105 @@
+package fit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseParameters(t *testing.T) {
+	cases := map[string]int64{
+		"meta-llama/Llama-3.1-70B-Instruct": 70e9,
+		"ai/smollm2:360M-Q4_K_M":            360e6,
+		"8.03 B":                            8.03e9,
+		"Mixtral-8x7B-v0.1":                 56e9,
+		"gemma-3-27b-it":                    27e9,
+	}
+	for s, want := range cases {
+		if got, ok := ParseParameters(s); !ok || got != want {
+			t.Fatalf("ParseParameters(%q) = %d, %v; want %d", s, got, ok, want)
+		}
+	}
+	if _, ok := ParseParameters("Phi-3-mini-4k-instruct"); ok {
+		t.Fatalf("ParseParameters read a count from a name without one")
+	}
+}
+
+func TestEstimatePlacement(t *testing.T) {
+	// Llama 3.1 70B: 80 layers, 8 KV heads of 128 dimensions
+	m := Model{Parameters: 70.6e9, Layers: 80, KVHeads: 8, HeadDim: 128, MaxContext: 131072}
+	if perToken, exact := m.KVBytesPerToken(); !exact || perToken != 320<<10 {
+		t.Fatalf("KVBytesPerToken = %d, %v", perToken, exact)
+	}
+	cases := []struct {
+		quant   Quantization
+		context int
+		want    Placement
+	}{
+		{Quantization{"Q4_K_M", 4.85}, 4096, FitsNode},
+		{Quantization{"BF16", 16}, 4096, FitsPair},
+		{Quantization{"BF16", 16}, 131072, FitsPair},
+		{Quantization{"F32", 32}, 131072, NoFit},
+	}
+	for _, c := range cases {
+		if e := m.Estimate(c.quant, c.context); e.Placement != c.want {
+			t.Fatalf("%s at %d: %s (%d bytes), want %s", c.quant.Name, c.context, e.Placement, e.Total, c.want)
+		}
+	}
+	if got := m.Contexts(nil); len(got) != 3 || got[2] != 131072 {
+		t.Fatalf("Contexts = %v", got)
+	}
+	if got := (Model{MaxContext: 8192}).Contexts(nil); len(got) != 2 || got[1] != 8192 {
+		t.Fatalf("Contexts capped = %v", got)
+	}
+}
+
+func TestParseRef(t *testing.T) {
+	cases := map[string]string{
+		"meta-llama/Llama-3.1-8B": SourceHF,
+		"hf.co/Qwen/Qwen2.5-7B":   SourceHF,
+		"ai/smollm2":              SourceDMR,
+		"llama3.1:8b":             SourceDMR,
+		"my-70B":                  SourceName,
+	}
+	for ref, want := range cases {
+		if kind, _ := ParseRef(ref); kind != want {
+			t.Fatalf("ParseRef(%q) = %s, want %s", ref, kind, want)
+		}
+	}
+}
+
+func TestFromHF(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/models/org/vlm":
+			w.Write([]byte(`{"safetensors":{"total":12000000000,"parameters":{"BF16":11990000000,"F32":10000000}}}`))
+		case "/org/vlm/resolve/main/config.json":
+			if r.Header.Get("Authorization") != "Bearer tok" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"text_config":{"num_hidden_layers":48,"num_attention_heads":32,"num_key_value_heads":8,"hidden_size":5120,"max_position_embeddings":131072}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	m, err := FromHF(context.Background(), srv.Client(), srv.URL, "org/vlm", "tok")
+	if err != nil {
+		t.Fatalf("FromHF: %v", err)
+	}
+	if m.Parameters != 12e9 || m.Quantization != "BF16" || m.Layers != 48 || m.KVHeads != 8 || m.HeadDim != 160 || m.MaxContext != 131072 {
+		t.Fatalf("FromHF = %+v", m)
+	}
+
+	// Without access to config.json the parameter count still comes through
+	m, err = FromHF(context.Background(), srv.Client(), srv.URL, "org/vlm", "")
+	if err != nil || m.Parameters != 12e9 || m.Layers != 0 {
+		t.Fatalf("FromHF without a token = %+v, %v", m, err)
+	}
+	if _, err := FromHF(context.Background(), srv.Client(), srv.URL, "org/missing", ""); err == nil {
+		t.Fatalf("FromHF found a missing repository")
+	}
+}
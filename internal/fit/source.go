@@ -0,0 +1,160 @@
+package fit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/weatherman/dgx-manager/internal/dmr"
+)
+
+// HFBaseURL is the Hugging Face Hub the metadata is read from
+const HFBaseURL = "https://huggingface.co"
+
+// Source kinds of a model reference
+const (
+	SourceHF   = "huggingface"
+	SourceDMR  = "dmr"
+	SourceName = "name"
+)
+
+// ParseRef classifies a model reference and returns what to look it up by: a Hugging
+// Face repository (org/repo, hf.co/org/repo), a Docker Model Runner reference (ai/name
+// or anything with a tag), or a bare name whose parameter count is read from the name
+func ParseRef(ref string) (kind, name string) {
+	for _, prefix := range []string{"hf:", "hf.co/", "huggingface.co/", "https://huggingface.co/"} {
+		if rest, ok := strings.CutPrefix(ref, prefix); ok {
+			return SourceHF, rest
+		}
+	}
+	switch {
+	case strings.HasPrefix(ref, "ai/"), strings.Contains(ref, ":"):
+		return SourceDMR, ref
+	case strings.Count(ref, "/") == 1:
+		return SourceHF, ref
+	}
+	return SourceName, ref
+}
+
+// FromName builds a model from the parameter count in its name
+func FromName(ref string) (Model, error) {
+	params, ok := ParseParameters(ref[strings.LastIndex(ref, "/")+1:])
+	if !ok {
+		return Model{}, fmt.Errorf("cannot tell the parameter count of %s from its name; pass --params", ref)
+	}
+	return Model{Ref: ref, Source: SourceName, Parameters: params}, nil
+}
+
+// FromDMR builds a model from Docker Model Runner metadata, falling back to the name
+// for the parameter count
+func FromDMR(ref string, info *dmr.Model) (Model, error) {
+	m := Model{Ref: ref, Source: SourceDMR, Quantization: info.Config.Quantization}
+	if info.Config.ContextSize != nil {
+		m.MaxContext = int(*info.Config.ContextSize)
+	}
+	if params, ok := ParseParameters(info.Config.Parameters); ok {
+		m.Parameters = params
+	} else if named, err := FromName(ref); err == nil {
+		m.Parameters = named.Parameters
+	} else {
+		return Model{}, err
+	}
+	return m, nil
+}
+
+// hfModelInfo is the part of the Hub's model API used here
+type hfModelInfo struct {
+	Safetensors *struct {
+		Total      int64            `json:"total"`
+		Parameters map[string]int64 `json:"parameters"`
+	} `json:"safetensors"`
+}
+
+// hfConfig is the part of a transformers config.json used here. Multimodal models keep
+// the language model's settings in text_config.
+type hfConfig struct {
+	Layers     int       `json:"num_hidden_layers"`
+	Heads      int       `json:"num_attention_heads"`
+	KVHeads    int       `json:"num_key_value_heads"`
+	Hidden     int       `json:"hidden_size"`
+	HeadDim    int       `json:"head_dim"`
+	MaxContext int       `json:"max_position_embeddings"`
+	Text       *hfConfig `json:"text_config"`
+}
+
+// safetensorsDtypes maps the Hub's tensor types to the quantization names used here
+var safetensorsDtypes = map[string]string{
+	"BF16": "BF16", "F16": "BF16", "F32": "BF16",
+	"F8_E4M3": "FP8", "F8_E5M2": "FP8",
+}
+
+// FromHF reads a model's parameter count from the Hugging Face Hub and its architecture
+// from config.json. token, when set, is sent for gated repositories; without access to
+// config.json the KV cache is approximated.
+func FromHF(ctx context.Context, client *http.Client, baseURL, repo, token string) (Model, error) {
+	m := Model{Ref: repo, Source: SourceHF}
+
+	var info hfModelInfo
+	if err := getJSON(ctx, client, baseURL+"/api/models/"+repo, token, &info); err != nil {
+		return Model{}, err
+	}
+	if info.Safetensors != nil && info.Safetensors.Total > 0 {
+		m.Parameters = info.Safetensors.Total
+		// The dominant tensor type is the format the weights are published in
+		var most int64
+		for dtype, n := range info.Safetensors.Parameters {
+			if n > most {
+				most, m.Quantization = n, safetensorsDtypes[dtype]
+			}
+		}
+	} else if named, err := FromName(repo); err == nil {
+		m.Parameters = named.Parameters
+	} else {
+		return Model{}, fmt.Errorf("%s publishes no safetensors metadata: %w", repo, err)
+	}
+
+	var config hfConfig
+	if err := getJSON(ctx, client, baseURL+"/"+repo+"/resolve/main/config.json", token, &config); err != nil {
+		return m, nil
+	}
+	if config.Layers == 0 && config.Text != nil {
+		config = *config.Text
+	}
+	m.Layers, m.KVHeads, m.HeadDim, m.MaxContext = config.Layers, config.KVHeads, config.HeadDim, config.MaxContext
+	if m.KVHeads == 0 {
+		m.KVHeads = config.Heads
+	}
+	if m.HeadDim == 0 && config.Heads > 0 {
+		m.HeadDim = config.Hidden / config.Heads
+	}
+	return m, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url, token string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach Hugging Face: %w", err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%s: not found on Hugging Face", strings.TrimPrefix(url, HFBaseURL+"/"))
+	case resp.StatusCode == http.StatusUnauthorized, resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%s: access denied (gated repositories need HF_TOKEN)", url)
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("invalid response from %s: %w", url, err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package fleet
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// hostColors are the ANSI colors given to hosts in turn
+var hostColors = []string{"36", "33", "35", "32", "34", "31"}
+
+// LogMux merges the log streams of several hosts onto one writer, line by line, with each
+// line prefixed by its host
+type LogMux struct {
+	mu     sync.Mutex
+	out    io.Writer
+	width  int
+	color  bool
+	prefix bool
+	next   int
+}
+
+// NewLogMux creates a mux writing to out. hosts sets the prefix width; with a single host
+// lines are passed on unprefixed. color turns on ANSI colors, one per host.
+func NewLogMux(out io.Writer, hosts []string, color bool) *LogMux {
+	m := &LogMux{out: out, color: color, prefix: len(hosts) > 1}
+	for _, h := range hosts {
+		if len(h) > m.width {
+			m.width = len(h)
+		}
+	}
+	return m
+}
+
+// Writer returns the writer for one host's stream. Only whole lines reach the output, so
+// hosts never interleave mid-line. Lines are also written, unprefixed, to file when it is
+// not nil. Close flushes a last line without a newline.
+func (m *LogMux) Writer(host string, file io.Writer) io.WriteCloser {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prefix := ""
+	if m.prefix {
+		prefix = fmt.Sprintf("%-*s | ", m.width, host)
+		if m.color {
+			prefix = fmt.Sprintf("\x1b[%sm%-*s\x1b[0m | ", hostColors[m.next%len(hostColors)], m.width, host)
+		}
+	}
+	m.next++
+	return &hostWriter{mux: m, prefix: prefix, file: file}
+}
+
+func (m *LogMux) emit(prefix string, line []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.out.Write(append([]byte(prefix), line...))
+}
+
+type hostWriter struct {
+	mux     *LogMux
+	prefix  string
+	file    io.Writer
+	partial []byte
+}
+
+func (w *hostWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		w.line(w.partial[:i+1])
+		w.partial = w.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+func (w *hostWriter) Close() error {
+	if len(w.partial) > 0 {
+		w.line(append(w.partial, '\n'))
+		w.partial = nil
+	}
+	return nil
+}
+
+func (w *hostWriter) line(line []byte) {
+	if w.file != nil {
+		w.file.Write(line)
+	}
+	w.mux.emit(w.prefix, line)
+}
@@ -0,0 +1,34 @@
+package fleet
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestLogMux(t *testing.T) {
+	var out, file bytes.Buffer
+	mux := NewLogMux(&out, []string{"spark-1", "lab"}, false)
+	a := mux.Writer("spark-1", &file)
+	b := mux.Writer("lab", nil)
+
+	io.WriteString(a, "step 1 loss")
+	io.WriteString(b, "ready\nserving\n")
+	io.WriteString(a, " 0.42\nstep 2")
+	a.Close()
+
+	want := "lab     | ready\nlab     | serving\nspark-1 | step 1 loss 0.42\nspark-1 | step 2\n"
+	if out.String() != want {
+		t.Fatalf("merged output:\n%s\nwant:\n%s", out.String(), want)
+	}
+	if file.String() != "step 1 loss 0.42\nstep 2\n" {
+		t.Fatalf("host file: %q", file.String())
+	}
+
+	out.Reset()
+	single := NewLogMux(&out, []string{"spark-1"}, true).Writer("spark-1", nil)
+	io.WriteString(single, "plain\n")
+	if out.String() != "plain\n" {
+		t.Fatalf("single host output %q, want it unprefixed", out.String())
+	}
+}
@@ -0,0 +1,209 @@
+package fleet
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/ui"
+)
+
+// DefaultParallel is how many jobs run at once unless --parallel says otherwise
+const DefaultParallel = 4
+
+// State is where a job is in its lifecycle
+type State string
+
+const (
+	StateQueued  State = "queued"
+	StateRunning State = "running"
+	StateDone    State = "done"
+	StateFailed  State = "failed"
+)
+
+// Job is one unit of fleet work against a host
+type Job struct {
+	Host  string
+	Label string
+	// Mutating jobs on the same host never overlap; read-only jobs run alongside anything
+	Mutating bool
+	Run      func(ctx context.Context) (string, error)
+}
+
+// Result is what became of a job
+type Result struct {
+	Job     Job
+	State   State
+	Output  string
+	Err     error
+	Elapsed time.Duration
+}
+
+// Pool runs jobs with at most Parallel in flight
+type Pool struct {
+	Parallel int
+	// Progress receives the queued/running/done table; nil disables it
+	Progress io.Writer
+	// Live redraws the table in place, for terminals. Otherwise each state change is
+	// printed as a line.
+	Live bool
+}
+
+// Run executes jobs and returns their results in the order given. A job waits in the queue
+// while a mutating job for its host is running, and later jobs that can start go ahead of it.
+func (p *Pool) Run(ctx context.Context, jobs []Job) []Result {
+	parallel := p.Parallel
+	if parallel <= 0 {
+		parallel = DefaultParallel
+	}
+
+	results := make([]Result, len(jobs))
+	started := make([]time.Time, len(jobs))
+	for i, job := range jobs {
+		results[i] = Result{Job: job, State: StateQueued}
+	}
+
+	var mu sync.Mutex
+	cond := sync.NewCond(&mu)
+	running := 0
+	busy := map[string]bool{} // hosts with a mutating job in flight
+	table := &progressTable{out: p.Progress, live: p.Live}
+
+	// next returns the first queued job allowed to start, or -1
+	next := func() int {
+		for i := range results {
+			if results[i].State != StateQueued {
+				continue
+			}
+			if jobs[i].Mutating && busy[jobs[i].Host] {
+				continue
+			}
+			return i
+		}
+		return -1
+	}
+
+	if p.Live && p.Progress != nil {
+		// Keep the elapsed column of running jobs ticking
+		stop, stopped := make(chan struct{}), make(chan struct{})
+		defer func() {
+			close(stop)
+			<-stopped
+		}()
+		go func() {
+			defer close(stopped)
+			ticker := time.NewTicker(time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-stop:
+					return
+				case <-ticker.C:
+					mu.Lock()
+					table.draw(results, started, -1)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	var wg sync.WaitGroup
+	mu.Lock()
+	table.draw(results, started, -1)
+	for remaining := len(jobs); remaining > 0; remaining-- {
+		i := next()
+		for running >= parallel || i < 0 {
+			cond.Wait()
+			i = next()
+		}
+		results[i].State = StateRunning
+		started[i] = time.Now()
+		running++
+		if jobs[i].Mutating {
+			busy[jobs[i].Host] = true
+		}
+		table.draw(results, started, i)
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			output, err := jobs[i].Run(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[i].Output, results[i].Err = output, err
+			results[i].Elapsed = time.Since(started[i])
+			results[i].State = StateDone
+			if err != nil {
+				results[i].State = StateFailed
+			}
+			running--
+			if jobs[i].Mutating {
+				busy[jobs[i].Host] = false
+			}
+			table.draw(results, started, i)
+			cond.Broadcast()
+		}(i)
+	}
+	mu.Unlock()
+	wg.Wait()
+	return results
+}
+
+// progressTable renders job states
+type progressTable struct {
+	out   io.Writer
+	live  bool
+	lines int
+}
+
+// draw renders the table, or on non-live output just the job that changed
+func (t *progressTable) draw(results []Result, started []time.Time, changed int) {
+	if t.out == nil {
+		return
+	}
+	if !t.live {
+		if changed >= 0 {
+			r := results[changed]
+			fmt.Fprintf(t.out, "[%s] %s: %s%s\n", r.Job.Host, r.Job.Label, r.State, suffix(r))
+		}
+		return
+	}
+
+	var b strings.Builder
+	if t.lines > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", t.lines)
+	}
+	counts := map[State]int{}
+	for _, r := range results {
+		counts[r.State]++
+	}
+	fmt.Fprintf(&b, "\x1b[2K%d queued, %d running, %d done, %d failed\n",
+		counts[StateQueued], counts[StateRunning], counts[StateDone], counts[StateFailed])
+	for i, r := range results {
+		elapsed := ""
+		switch r.State {
+		case StateRunning:
+			elapsed = time.Since(started[i]).Round(time.Second).String()
+		case StateDone, StateFailed:
+			elapsed = r.Elapsed.Round(100 * time.Millisecond).String()
+		}
+		fmt.Fprintf(&b, "\x1b[2K  %-24s %-28s %-8s %s\n", ui.Truncate(r.Job.Host, 24), ui.Truncate(r.Job.Label, 28), r.State, elapsed)
+	}
+	t.lines = len(results) + 1
+	io.WriteString(t.out, b.String())
+}
+
+func suffix(r Result) string {
+	switch r.State {
+	case StateDone:
+		return fmt.Sprintf(" (%s)", r.Elapsed.Round(100*time.Millisecond))
+	case StateFailed:
+		return fmt.Sprintf(" (%s): %v", r.Elapsed.Round(100*time.Millisecond), r.Err)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,65 @@
+package fleet
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPoolLimitsAndSerializesMutatingJobs(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	hostMutating := map[string]int{}
+
+	job := func(host string, mutating bool, fail bool) Job {
+		return Job{Host: host, Label: "job", Mutating: mutating, Run: func(ctx context.Context) (string, error) {
+			mu.Lock()
+			inFlight++
+			maxInFlight = max(maxInFlight, inFlight)
+			if mutating {
+				hostMutating[host]++
+				if hostMutating[host] > 1 {
+					t.Errorf("two mutating jobs overlapped on %s", host)
+				}
+			}
+			mu.Unlock()
+
+			time.Sleep(20 * time.Millisecond)
+
+			mu.Lock()
+			inFlight--
+			if mutating {
+				hostMutating[host]--
+			}
+			mu.Unlock()
+			if fail {
+				return "boom", errors.New("failed")
+			}
+			return host, nil
+		}}
+	}
+
+	jobs := []Job{
+		job("a", true, false), job("a", true, false), job("a", false, false),
+		job("b", true, true), job("c", true, false), job("d", false, false),
+	}
+	results := (&Pool{Parallel: 3}).Run(context.Background(), jobs)
+
+	if maxInFlight > 3 {
+		t.Fatalf("max in flight = %d, want <= 3", maxInFlight)
+	}
+	for i, r := range results {
+		want := StateDone
+		if i == 3 {
+			want = StateFailed
+		}
+		if r.State != want || r.Job.Host != jobs[i].Host {
+			t.Fatalf("result %d = %+v, want %s", i, r, want)
+		}
+	}
+	if results[3].Output != "boom" || results[3].Err == nil {
+		t.Fatalf("failed result = %+v", results[3])
+	}
+}
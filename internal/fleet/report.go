@@ -0,0 +1,128 @@
+package fleet
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ReportCommand prints key=value facts about a host for its report row
+const ReportCommand = `echo "gpu=$(nvidia-smi --query-gpu=name --format=csv,noheader 2>/dev/null | head -n1)"
+echo "driver=$(nvidia-smi --query-gpu=driver_version --format=csv,noheader 2>/dev/null | head -n1)"
+echo "gpu_temp=$(nvidia-smi --query-gpu=temperature.gpu --format=csv,noheader,nounits 2>/dev/null | head -n1)"
+echo "dmr=$(docker model version 2>/dev/null | awk 'tolower($0) ~ /version/ {print $NF; exit}')"
+echo "os=$(. /etc/dgx-release 2>/dev/null && echo "$DGX_SWBUILD_VERSION")"
+echo "disk_free_kb=$(df -Pk / 2>/dev/null | awk 'NR==2 {print $4}')"`
+
+// ReportRow is one host's line in an exported fleet report. Numeric fields are -1 when
+// the host did not report them.
+type ReportRow struct {
+	Host       string `json:"host"`
+	Address    string `json:"address"`
+	Status     State  `json:"status"`
+	GPU        string `json:"gpu"`
+	Driver     string `json:"driver"`
+	DMRVersion string `json:"dmr_version"`
+	DGXOS      string `json:"dgx_os"`
+	DiskFreeKB int64  `json:"disk_free_kb"`
+	GPUTempC   int    `json:"gpu_temp_c"`
+	Error      string `json:"error,omitempty"`
+}
+
+// reportHeader is the CSV header, in ReportRow field order
+var reportHeader = []string{"host", "address", "status", "gpu", "driver", "dmr_version", "dgx_os", "disk_free_kb", "gpu_temp_c", "error"}
+
+// ParseReport fills the probed fields of row from ReportCommand output
+func ParseReport(row *ReportRow, output string) {
+	row.DiskFreeKB, row.GPUTempC = -1, -1
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "gpu":
+			row.GPU = value
+		case "driver":
+			row.Driver = value
+		case "gpu_temp":
+			if n, err := strconv.Atoi(value); err == nil {
+				row.GPUTempC = n
+			}
+		case "dmr":
+			row.DMRVersion = value
+		case "os":
+			row.DGXOS = value
+		case "disk_free_kb":
+			if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+				row.DiskFreeKB = n
+			}
+		}
+	}
+}
+
+// ExportFormat returns "csv" or "json" from the extension of path
+func ExportFormat(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return "csv", nil
+	case ".json":
+		return "json", nil
+	default:
+		return "", fmt.Errorf("cannot tell the report format of %q: use a .csv or .json file", path)
+	}
+}
+
+// WriteReport writes rows to w as CSV or JSON
+func WriteReport(w io.Writer, format string, rows []ReportRow) error {
+	if format == "json" {
+		if rows == nil {
+			rows = []ReportRow{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write(reportHeader)
+	for _, r := range rows {
+		cw.Write([]string{
+			r.Host, r.Address, string(r.Status), r.GPU, r.Driver, r.DMRVersion, r.DGXOS,
+			optionalInt(r.DiskFreeKB), optionalInt(int64(r.GPUTempC)), r.Error,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ExportReport writes rows to path in the format its extension names
+func ExportReport(path string, rows []ReportRow) error {
+	format, err := ExportFormat(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report: %w", err)
+	}
+	if err := WriteReport(f, format, rows); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return f.Close()
+}
+
+// optionalInt formats n, leaving unknown (negative) values empty
+func optionalInt(n int64) string {
+	if n < 0 {
+		return ""
+	}
+	return strconv.FormatInt(n, 10)
+}
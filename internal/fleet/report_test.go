@@ -0,0 +1,31 @@
+package fleet
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseReportAndCSV(t *testing.T) {
+	row := ReportRow{Host: "spark-1", Address: "ubuntu@10.0.0.1:22", Status: StateDone}
+	ParseReport(&row, "gpu=NVIDIA GB10\ndriver=580.95.05\ngpu_temp=[N/A]\ndmr=v0.1.40\nos=7.2.3\ndisk_free_kb=1048576\n")
+	if row.Driver != "580.95.05" || row.DMRVersion != "v0.1.40" || row.DiskFreeKB != 1048576 {
+		t.Fatalf("unexpected row: %+v", row)
+	}
+	if row.GPUTempC != -1 {
+		t.Fatalf("GPUTempC = %d, want -1 for N/A", row.GPUTempC)
+	}
+
+	var sb strings.Builder
+	if err := WriteReport(&sb, "csv", []ReportRow{row}); err != nil {
+		t.Fatalf("WriteReport: %v", err)
+	}
+	want := "host,address,status,gpu,driver,dmr_version,dgx_os,disk_free_kb,gpu_temp_c,error\n" +
+		"spark-1,ubuntu@10.0.0.1:22,done,NVIDIA GB10,580.95.05,v0.1.40,7.2.3,1048576,,\n"
+	if sb.String() != want {
+		t.Fatalf("csv = %q, want %q", sb.String(), want)
+	}
+
+	if _, err := ExportFormat("report.xlsx"); err == nil {
+		t.Fatalf("expected an error for an unknown extension")
+	}
+}
@@ -0,0 +1,128 @@
+package gitsync
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// SnapshotRef is the ref on the DGX that receives pushed snapshots. It is outside
+// refs/heads, so pushing never conflicts with a branch checked out there.
+const SnapshotRef = "refs/dgx/push-run"
+
+// Repo is a local git checkout
+type Repo struct {
+	Dir string
+}
+
+// Open finds the checkout containing dir
+func Open(dir string) (*Repo, error) {
+	top, err := git(dir, nil, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("not inside a git checkout: %w", err)
+	}
+	return &Repo{Dir: top}, nil
+}
+
+// Name is the checkout's directory name, used as the default remote directory
+func (r *Repo) Name() string {
+	return filepath.Base(r.Dir)
+}
+
+// Snapshot returns a commit holding the working tree as it is now: HEAD plus uncommitted
+// changes and untracked files that .gitignore does not exclude. The index and branches are
+// left alone. When nothing differs from HEAD, HEAD itself is returned.
+func (r *Repo) Snapshot() (string, error) {
+	head, headErr := git(r.Dir, nil, "rev-parse", "--verify", "-q", "HEAD")
+
+	index, err := os.CreateTemp("", "dgx-push-index-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary index: %w", err)
+	}
+	index.Close()
+	defer os.Remove(index.Name())
+	// An empty file is not a valid index; git creates it from scratch when missing
+	os.Remove(index.Name())
+	env := []string{"GIT_INDEX_FILE=" + index.Name()}
+
+	if headErr == nil {
+		if _, err := git(r.Dir, env, "read-tree", head); err != nil {
+			return "", err
+		}
+	}
+	if _, err := git(r.Dir, env, "add", "-A", "."); err != nil {
+		return "", err
+	}
+	tree, err := git(r.Dir, env, "write-tree")
+	if err != nil {
+		return "", err
+	}
+
+	args := []string{"commit-tree", tree, "-m", "dgx push-run snapshot"}
+	if headErr == nil {
+		headTree, err := git(r.Dir, nil, "rev-parse", head+"^{tree}")
+		if err == nil && headTree == tree {
+			return head, nil
+		}
+		args = append(args, "-p", head)
+	}
+	// commit-tree needs an identity even for throwaway commits
+	env = append(env, "GIT_AUTHOR_NAME=dgx", "GIT_AUTHOR_EMAIL=dgx@localhost",
+		"GIT_COMMITTER_NAME=dgx", "GIT_COMMITTER_EMAIL=dgx@localhost")
+	return git(r.Dir, env, args...)
+}
+
+// Head returns the commit checked out, for pushing committed work only
+func (r *Repo) Head() (string, error) {
+	head, err := git(r.Dir, nil, "rev-parse", "--verify", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("the checkout has no commits yet: %w", err)
+	}
+	return head, nil
+}
+
+// Push force-pushes commit to SnapshotRef in the repository at url, running ssh with
+// sshCommand and the environment env
+func (r *Repo) Push(url, commit, sshCommand string, env []string) error {
+	cmd := exec.Command("git", "push", "--force", "--quiet", url, commit+":"+SnapshotRef)
+	cmd.Dir = r.Dir
+	cmd.Env = append(env, "GIT_SSH_COMMAND="+sshCommand)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git push failed: %w", err)
+	}
+	return nil
+}
+
+// RemoteURL returns the ssh:// URL for path on host. Relative paths and paths under ~ are
+// taken from the remote home directory.
+func RemoteURL(user, host string, port int, path string) string {
+	path = strings.TrimPrefix(path, "~/")
+	if !strings.HasPrefix(path, "/") {
+		path = "/~/" + path
+	}
+	if user != "" {
+		host = user + "@" + host
+	}
+	return fmt.Sprintf("ssh://%s:%d%s", host, port, path)
+}
+
+func git(dir string, env []string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,74 @@
+package gitsync
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func run(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := git(dir, []string{"GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@t", "GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@t"}, args...)
+	if err != nil {
+		t.Fatalf("git %v: %v", args, err)
+	}
+	return out
+}
+
+func TestSnapshotIncludesWorkingTree(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	run(t, dir, "init", "-q")
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(".gitignore", "*.ckpt\n")
+	write("train.py", "print(1)\n")
+	run(t, dir, "add", ".")
+	run(t, dir, "commit", "-q", "-m", "init")
+
+	repo, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	head, _ := repo.Head()
+	if snap, err := repo.Snapshot(); err != nil || snap != head {
+		t.Fatalf("clean snapshot = %s, %v; want HEAD %s", snap, err, head)
+	}
+
+	write("train.py", "print(2)\n")
+	write("config.yaml", "lr: 1e-4\n")
+	write("model.ckpt", "weights")
+	snap, err := repo.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	files := run(t, dir, "ls-tree", "--name-only", snap)
+	if !strings.Contains(files, "config.yaml") || strings.Contains(files, "model.ckpt") {
+		t.Fatalf("snapshot files = %q", files)
+	}
+	if got := run(t, dir, "show", snap+":train.py"); got != "print(2)" {
+		t.Fatalf("snapshot train.py = %q", got)
+	}
+	if status := run(t, dir, "status", "--porcelain"); !strings.Contains(status, "?? config.yaml") {
+		t.Fatalf("the real index was modified: %q", status)
+	}
+}
+
+func TestRemoteURL(t *testing.T) {
+	if got := RemoteURL("alice", "spark", 22, "~/src/app"); got != "ssh://alice@spark:22/~/src/app" {
+		t.Fatalf("RemoteURL = %s", got)
+	}
+	if got := RemoteURL("alice", "spark", 22, "src/app"); got != "ssh://alice@spark:22/~/src/app" {
+		t.Fatalf("RemoteURL = %s", got)
+	}
+	if got := RemoteURL("", "spark", 2222, "/data/app"); got != "ssh://spark:2222/data/app" {
+		t.Fatalf("RemoteURL = %s", got)
+	}
+}
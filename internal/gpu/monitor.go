@@ -5,6 +5,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/weatherman/dgx-manager/internal/ssh"
 	"github.com/weatherman/dgx-manager/pkg/types"
@@ -101,6 +102,21 @@ func (m *Monitor) getGPUProcesses(gpuID int) ([]types.GPUProcess, error) {
 		return nil, err
 	}
 
+	return parseComputeApps(output), nil
+}
+
+// ListProcesses retrieves all compute processes across every GPU
+func (m *Monitor) ListProcesses() ([]types.GPUProcess, error) {
+	output, err := m.sshClient.Execute("nvidia-smi --query-compute-apps=pid,process_name,used_memory --format=csv,noheader,nounits")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query GPU processes: %w", err)
+	}
+
+	return parseComputeApps(output), nil
+}
+
+// parseComputeApps parses nvidia-smi --query-compute-apps CSV output
+func parseComputeApps(output string) []types.GPUProcess {
 	lines := strings.Split(strings.TrimSpace(output), "\n")
 	processes := make([]types.GPUProcess, 0)
 
@@ -129,7 +145,7 @@ func (m *Monitor) getGPUProcesses(gpuID int) ([]types.GPUProcess, error) {
 		processes = append(processes, process)
 	}
 
-	return processes, nil
+	return processes
 }
 
 // GetGPUCount returns the number of GPUs
@@ -152,6 +168,45 @@ func (m *Monitor) GetGPUCount() (int, error) {
 	return count, nil
 }
 
+// InventoryCacheTTL is how long a GPU inventory is reused; it only changes with hardware
+// or driver updates
+const InventoryCacheTTL = 10 * time.Minute
+
+// Device is the static description of a GPU
+type Device struct {
+	Index       int
+	Name        string
+	MemoryTotal string
+	Driver      string
+}
+
+// Inventory lists the GPUs with their model, total memory, and driver version
+func (m *Monitor) Inventory() ([]Device, error) {
+	output, err := m.sshClient.Execute("nvidia-smi --query-gpu=index,name,memory.total,driver_version --format=csv,noheader")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query GPU inventory: %w", err)
+	}
+
+	var devices []Device
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Split(line, ",")
+		if len(fields) < 4 {
+			continue
+		}
+		index, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+		if err != nil {
+			continue
+		}
+		devices = append(devices, Device{
+			Index:       index,
+			Name:        strings.TrimSpace(fields[1]),
+			MemoryTotal: strings.TrimSpace(fields[2]),
+			Driver:      strings.TrimSpace(fields[3]),
+		})
+	}
+	return devices, nil
+}
+
 // WatchGPU monitors GPU usage in real-time
 func (m *Monitor) WatchGPU(interval int) error {
 	// Run nvidia-smi in watch mode (dmon for device monitoring)
@@ -0,0 +1,66 @@
+package gpu
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+// containerIDPattern matches the 64-character docker container ID embedded in cgroup paths,
+// e.g. "0::/system.slice/docker-<id>.scope" or "12:memory:/docker/<id>".
+var containerIDPattern = regexp.MustCompile(`(?:docker[-/]|containerd[-/])([0-9a-f]{64})`)
+
+// ProcessContainer returns the docker container ID and name owning a remote PID.
+// It returns empty strings when the process does not belong to a container.
+func (m *Monitor) ProcessContainer(pid int) (string, string, error) {
+	output, err := m.sshClient.Execute(fmt.Sprintf("cat /proc/%d/cgroup", pid))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read cgroup for PID %d: %w", pid, err)
+	}
+
+	id := ContainerIDFromCgroup(output)
+	if id == "" {
+		return "", "", nil
+	}
+
+	name, err := m.sshClient.Execute(fmt.Sprintf("docker inspect --format '{{.Name}}' %s", id))
+	if err != nil {
+		// The ID alone is enough to stop the container
+		return id, "", nil
+	}
+	return id, strings.TrimPrefix(strings.TrimSpace(name), "/"), nil
+}
+
+// KillProcess sends SIGTERM (or SIGKILL when force is set) to a remote PID.
+// It falls back to non-interactive sudo when the process is owned by another user.
+func (m *Monitor) KillProcess(pid int, force bool) error {
+	signal := "TERM"
+	if force {
+		signal = "KILL"
+	}
+	cmd := fmt.Sprintf("kill -s %s %d 2>/dev/null || sudo -n kill -s %s %d", signal, pid, signal, pid)
+	if _, err := m.sshClient.Execute(cmd); err != nil {
+		return fmt.Errorf("failed to kill PID %d: %w", pid, err)
+	}
+	return nil
+}
+
+// StopContainer stops a docker container by ID or name.
+func (m *Monitor) StopContainer(container string) error {
+	if _, err := m.sshClient.Execute(fmt.Sprintf("docker stop %s", ssh.ShellQuote(container))); err != nil {
+		return fmt.Errorf("failed to stop container %s: %w", container, err)
+	}
+	return nil
+}
+
+// ContainerIDFromCgroup extracts a docker container ID from /proc/<pid>/cgroup contents
+func ContainerIDFromCgroup(cgroup string) string {
+	for _, line := range strings.Split(cgroup, "\n") {
+		if matches := containerIDPattern.FindStringSubmatch(line); len(matches) > 1 {
+			return matches[1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,27 @@
+package gpu
+
+import "testing"
+
+func TestContainerIDFromCgroup(t *testing.T) {
+	id := "3f4e1a2b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f9012a3b4c5d6e7f8"
+
+	t.Run("cgroup v2 systemd scope", func(t *testing.T) {
+		got := ContainerIDFromCgroup("0::/system.slice/docker-" + id + ".scope\n")
+		if got != id {
+			t.Fatalf("unexpected id %q", got)
+		}
+	})
+
+	t.Run("cgroup v1 docker path", func(t *testing.T) {
+		cgroup := "12:pids:/user.slice\n11:memory:/docker/" + id + "\n"
+		if got := ContainerIDFromCgroup(cgroup); got != id {
+			t.Fatalf("unexpected id %q", got)
+		}
+	})
+
+	t.Run("bare process", func(t *testing.T) {
+		if got := ContainerIDFromCgroup("0::/user.slice/user-1000.slice/session-3.scope\n"); got != "" {
+			t.Fatalf("expected no container, got %q", got)
+		}
+	})
+}
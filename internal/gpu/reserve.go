@@ -0,0 +1,147 @@
+package gpu
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/weatherman/dgx-manager/internal/artifacts"
+	"github.com/weatherman/dgx-manager/internal/state"
+)
+
+// VLLMDefaultFraction is the share of GPU memory vLLM takes when --gpu-memory-utilization
+// is not given
+const VLLMDefaultFraction = 0.9
+
+// Reservation is a share of the GPU's memory set aside for one workload. Exactly one of
+// Fraction and Bytes is set.
+type Reservation struct {
+	Name     string  `json:"name"`
+	Fraction float64 `json:"fraction,omitempty"` // share of total memory
+	Bytes    int64   `json:"bytes,omitempty"`    // fixed size
+	Note     string  `json:"note,omitempty"`
+}
+
+// ParseReservation parses a reservation size: a fraction ("0.4"), a percentage ("40%"),
+// or a size with a unit ("48G", "48GiB", "50GB"; see artifacts.ParseBytes)
+func ParseReservation(s string) (Reservation, error) {
+	s = strings.TrimSpace(s)
+	invalid := fmt.Errorf("invalid reservation %q: use a fraction (0.4), a percentage (40%%), or a size (48GiB)", s)
+	upper := strings.ToUpper(s)
+	switch {
+	case strings.HasSuffix(upper, "%"):
+		v, err := strconv.ParseFloat(strings.TrimSuffix(upper, "%"), 64)
+		if err != nil || v <= 0 || v > 100 {
+			return Reservation{}, invalid
+		}
+		return Reservation{Fraction: v / 100}, nil
+	case strings.ContainsAny(upper, "KMGTB"):
+		n, err := artifacts.ParseBytes(s)
+		if err != nil || n <= 0 {
+			return Reservation{}, invalid
+		}
+		return Reservation{Bytes: n}, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v <= 0 || v > 1 {
+		return Reservation{}, invalid
+	}
+	return Reservation{Fraction: v}, nil
+}
+
+// Size returns the reservation in bytes of a GPU with total bytes of memory
+func (r Reservation) Size(total int64) int64 {
+	if r.Bytes > 0 {
+		return r.Bytes
+	}
+	return int64(r.Fraction * float64(total))
+}
+
+// Share returns the reservation as a fraction of total, which is what vLLM's
+// --gpu-memory-utilization takes
+func (r Reservation) Share(total int64) float64 {
+	if r.Bytes > 0 {
+		if total <= 0 {
+			return 0
+		}
+		return float64(r.Bytes) / float64(total)
+	}
+	return r.Fraction
+}
+
+// String renders the reservation the way it was given
+func (r Reservation) String() string {
+	if r.Bytes > 0 {
+		return strconv.FormatFloat(float64(r.Bytes)/(1<<30), 'f', -1, 64) + "GiB"
+	}
+	return strconv.FormatFloat(r.Fraction*100, 'f', -1, 64) + "%"
+}
+
+// reservationsKey is the state document holding a host's reservations
+func reservationsKey(host string) string {
+	return state.Key("gpu-reservations", host)
+}
+
+// LoadReservations returns the reservations recorded for host, sorted by name
+func LoadReservations(store *state.Store, host string) ([]Reservation, error) {
+	var reservations []Reservation
+	if _, err := store.Load(reservationsKey(host), &reservations); err != nil {
+		return nil, err
+	}
+	sort.Slice(reservations, func(i, j int) bool { return reservations[i].Name < reservations[j].Name })
+	return reservations, nil
+}
+
+// SaveReservations replaces the reservations recorded for host
+func SaveReservations(store *state.Store, host string, reservations []Reservation) error {
+	if len(reservations) == 0 {
+		return store.Delete(reservationsKey(host))
+	}
+	return store.Save(reservationsKey(host), reservations)
+}
+
+// FindReservation returns the reservation for name
+func FindReservation(reservations []Reservation, name string) (Reservation, bool) {
+	for _, r := range reservations {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Reservation{}, false
+}
+
+// memoryTotalScript prints the GPU memory nvidia-smi reports, then the system memory.
+// GB10 shares unified memory with the CPU, so nvidia-smi reports [N/A] there.
+const memoryTotalScript = `nvidia-smi --query-gpu=memory.total --format=csv,noheader,nounits 2>/dev/null | head -1
+awk '/^MemTotal:/ {print "mem " $2}' /proc/meminfo`
+
+// MemoryTotal returns the memory available to the GPU in bytes: its own memory, or the
+// system memory on unified-memory parts
+func (m *Monitor) MemoryTotal() (int64, error) {
+	output, err := m.sshClient.Execute(memoryTotalScript)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read memory size: %w", err)
+	}
+	total := parseMemoryTotal(output)
+	if total <= 0 {
+		return 0, fmt.Errorf("failed to read memory size from %q", strings.TrimSpace(output))
+	}
+	return total, nil
+}
+
+func parseMemoryTotal(output string) int64 {
+	var system int64
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if kb, ok := strings.CutPrefix(line, "mem "); ok {
+			v, _ := strconv.ParseInt(kb, 10, 64)
+			system = v * 1024
+			continue
+		}
+		if mib, err := strconv.ParseInt(line, 10, 64); err == nil && mib > 0 {
+			return mib << 20
+		}
+	}
+	return system
+}
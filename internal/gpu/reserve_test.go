@@ -0,0 +1,44 @@
+package gpu
+
+import "testing"
+
+func TestParseReservation(t *testing.T) {
+	const total = 128 << 30
+	cases := map[string]int64{
+		"0.25":  32 << 30,
+		"25%":   32 << 30,
+		"32G":   32 << 30,
+		"32g":   32 << 30,
+		"32GiB": 32 << 30,
+	}
+	for s, want := range cases {
+		r, err := ParseReservation(s)
+		if err != nil || r.Size(total) != want {
+			t.Fatalf("ParseReservation(%q) = %+v, %v; want %d bytes", s, r, err, want)
+		}
+		if share := r.Share(total); share != 0.25 {
+			t.Fatalf("Share(%q) = %v", s, share)
+		}
+	}
+	for _, bad := range []string{"", "0", "1.5", "120%", "-4GB", "lots"} {
+		if _, err := ParseReservation(bad); err == nil {
+			t.Fatalf("ParseReservation(%q) accepted", bad)
+		}
+	}
+	// GB is decimal, as everywhere else sizes are parsed
+	if r, err := ParseReservation("50GB"); err != nil || r.Bytes != 50e9 {
+		t.Fatalf("ParseReservation(\"50GB\") = %+v, %v", r, err)
+	}
+	if r, _ := ParseReservation("40%"); r.String() != "40%" {
+		t.Fatalf("String = %q", r.String())
+	}
+}
+
+func TestParseMemoryTotal(t *testing.T) {
+	if got := parseMemoryTotal("[N/A]\nmem 125000000\n"); got != 125000000*1024 {
+		t.Fatalf("unified memory = %d", got)
+	}
+	if got := parseMemoryTotal("81559\nmem 500000000\n"); got != 81559<<20 {
+		t.Fatalf("GPU memory = %d", got)
+	}
+}
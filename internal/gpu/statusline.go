@@ -0,0 +1,241 @@
+package gpu
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/poll"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/ui"
+)
+
+// statusQuery streams one CSV line per GPU every interval until the session is closed
+const statusQuery = "nvidia-smi --query-gpu=index,utilization.gpu,memory.used,memory.total,power.draw,temperature.gpu --format=csv,noheader,nounits -lms %d"
+
+// Sample is one nvidia-smi reading for a GPU. Fields nvidia-smi reports as [N/A] (memory
+// on unified-memory parts such as GB10) are -1.
+type Sample struct {
+	Index       int
+	Utilization int
+	MemoryUsed  int // MiB
+	MemoryTotal int // MiB
+	PowerDraw   float64
+	Temperature int
+}
+
+// parseSample parses a statusQuery line
+func parseSample(line string) (Sample, bool) {
+	fields := strings.Split(line, ",")
+	if len(fields) != 6 {
+		return Sample{}, false
+	}
+	index, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+	if err != nil {
+		return Sample{}, false
+	}
+	number := func(s string) float64 {
+		v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return -1
+		}
+		return v
+	}
+	return Sample{
+		Index:       index,
+		Utilization: int(number(fields[1])),
+		MemoryUsed:  int(number(fields[2])),
+		MemoryTotal: int(number(fields[3])),
+		PowerDraw:   number(fields[4]),
+		Temperature: int(number(fields[5])),
+	}, true
+}
+
+// String renders the sample for the status line
+func (s Sample) String() string {
+	parts := []string{fmt.Sprintf("GPU%d %3d%%", s.Index, max(s.Utilization, 0))}
+	if s.MemoryUsed >= 0 && s.MemoryTotal > 0 {
+		parts = append(parts, fmt.Sprintf("mem %.1f/%.1f GiB", float64(s.MemoryUsed)/1024, float64(s.MemoryTotal)/1024))
+	}
+	if s.PowerDraw >= 0 {
+		parts = append(parts, fmt.Sprintf("%.0f W", s.PowerDraw))
+	}
+	if s.Temperature >= 0 {
+		parts = append(parts, fmt.Sprintf("%d°C", s.Temperature))
+	}
+	return strings.Join(parts, " ")
+}
+
+// StatusLine pins a footer with live GPU readings to the bottom row of the terminal. The
+// rows above it become the scroll region, so output written by anything (including a
+// native ssh child) scrolls past without overwriting it.
+type StatusLine struct {
+	client   *ssh.Client
+	loop     *poll.Loop
+	interval time.Duration
+	fd       int
+
+	mu      sync.Mutex
+	width   int
+	height  int
+	samples map[int]Sample
+	stopped bool
+	done    chan struct{}
+}
+
+// StartStatusLine starts sampling over client, which should be a connection of its own so
+// sampling never competes with the job's sessions. It returns nil when stdout is not a
+// terminal. nvidia-smi samples at the interval loop gives when it starts; Stop closes loop.
+// Call Stop before writing final output.
+func StartStatusLine(client *ssh.Client, loop *poll.Loop) *StatusLine {
+	fd := int(os.Stdout.Fd())
+	if !ui.Live(os.Stdout) {
+		return nil
+	}
+	width, height, err := term.GetSize(fd)
+	if err != nil || height < 3 {
+		return nil
+	}
+
+	s := &StatusLine{
+		client:   client,
+		loop:     loop,
+		interval: loop.Interval(),
+		fd:       fd,
+		width:    width,
+		height:   height,
+		samples:  map[int]Sample{},
+		done:     make(chan struct{}),
+	}
+	// Make room for the footer, then confine scrolling to the rows above it
+	fmt.Fprint(os.Stdout, "\n\x1b[1A")
+	s.mu.Lock()
+	s.setRegion()
+	s.draw("GPU: sampling...")
+	s.mu.Unlock()
+
+	go s.sample()
+	go s.watch()
+	return s
+}
+
+// Stop restores the terminal's scroll region and clears the footer. It is safe to call
+// on a nil StatusLine and more than once.
+func (s *StatusLine) Stop() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return
+	}
+	s.stopped = true
+	close(s.done)
+	s.client.Close()
+	s.loop.Close()
+	fmt.Fprintf(os.Stdout, "\x1b7\x1b[r\x1b[%d;1H\x1b[2K\x1b8", s.height)
+}
+
+func (s *StatusLine) sample() {
+	w := &lineWriter{onLine: func(line string) {
+		sample, ok := parseSample(line)
+		if !ok {
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.samples[sample.Index] = sample
+		if !s.stopped {
+			s.draw(s.text())
+		}
+	}}
+	err := s.client.Stream(ssh.WithParseLocale(fmt.Sprintf(statusQuery, s.interval.Milliseconds())), nil, w, nil)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil && !s.stopped {
+		s.draw("GPU: sampling stopped: " + err.Error())
+	}
+}
+
+// watch follows terminal resizes and restores the terminal on Ctrl-C. Resizes are polled
+// because SIGWINCH does not exist on Windows.
+func (s *StatusLine) watch() {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-interrupt:
+			s.Stop()
+			os.Exit(exitcode.Aborted)
+		case <-ticker.C:
+			s.loop.Renew()
+			width, height, err := term.GetSize(s.fd)
+			s.mu.Lock()
+			if err == nil && !s.stopped && (width != s.width || height != s.height) && height >= 3 {
+				s.width, s.height = width, height
+				s.setRegion()
+				s.draw(s.text())
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *StatusLine) text() string {
+	if len(s.samples) == 0 {
+		return "GPU: sampling..."
+	}
+	indexes := make([]int, 0, len(s.samples))
+	for i := range s.samples {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	parts := make([]string, len(indexes))
+	for i, index := range indexes {
+		parts[i] = s.samples[index].String()
+	}
+	return strings.Join(parts, " | ")
+}
+
+// setRegion sets the scroll region to every row but the last. DECSTBM homes the cursor,
+// so it is saved and restored around it.
+func (s *StatusLine) setRegion() {
+	fmt.Fprintf(os.Stdout, "\x1b7\x1b[1;%dr\x1b8", s.height-1)
+}
+
+// draw writes text to the bottom row without moving the cursor
+func (s *StatusLine) draw(text string) {
+	if r := []rune(text); len(r) > s.width-1 {
+		text = string(r[:s.width-1])
+	}
+	fmt.Fprintf(os.Stdout, "\x1b7\x1b[%d;1H\x1b[2K\x1b[7m%s\x1b[0m\x1b8", s.height, text)
+}
+
+// lineWriter calls onLine for each complete line written to it
+type lineWriter struct {
+	partial string
+	onLine  func(string)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	lines := strings.Split(w.partial+string(p), "\n")
+	w.partial = lines[len(lines)-1]
+	for _, line := range lines[:len(lines)-1] {
+		w.onLine(strings.TrimSpace(line))
+	}
+	return len(p), nil
+}
@@ -0,0 +1,22 @@
+package gpu
+
+import "testing"
+
+func TestParseSample(t *testing.T) {
+	sample, ok := parseSample("0, 87, 42188, 122573, 142.35, 61")
+	if !ok {
+		t.Fatalf("parseSample failed")
+	}
+	if got := sample.String(); got != "GPU0  87% mem 41.2/119.7 GiB 142 W 61°C" {
+		t.Fatalf("String = %q", got)
+	}
+
+	// GB10 reports unified memory as [N/A]
+	sample, ok = parseSample("0, 3, [N/A], [N/A], 11.20, 44")
+	if !ok || sample.String() != "GPU0   3% 11 W 44°C" {
+		t.Fatalf("N/A sample = %+v %q", sample, sample.String())
+	}
+	if _, ok := parseSample("No devices were found"); ok {
+		t.Fatalf("parseSample accepted an error line")
+	}
+}
@@ -0,0 +1,491 @@
+package gpu
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/health"
+	"github.com/weatherman/dgx-manager/internal/pins"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+// StressContainer is the name of the container a stress test runs in
+const StressContainer = "dgx-stress"
+
+// Stress test defaults. Sustained load at DefaultMaxTemp means the unit's cooling cannot
+// keep up, so the test is stopped rather than left to the GPU's own thermal shutdown.
+const (
+	DefaultStressDuration = 10 * time.Minute
+	DefaultMaxTemp        = 90
+	DefaultStressImage    = "nvcr.io/nvidia/pytorch:25.09-py3"
+	DefaultGPUBurnImage   = "nvcr.io/nvidia/cuda:13.0.1-devel-ubuntu24.04"
+)
+
+// StressMethods are the load generators a stress test can use
+var StressMethods = []string{"matmul", "gpu-burn"}
+
+// stressQuery streams one CSV line per GPU every interval while the stress container runs
+const stressQuery = "nvidia-smi --query-gpu=index,temperature.gpu,clocks.sm,clocks.max.sm,power.draw,utilization.gpu,clocks_throttle_reasons.active --format=csv,noheader,nounits -lms %d"
+
+// eccQuery reads each GPU's volatile ECC error counts
+const eccQuery = "nvidia-smi --query-gpu=index,ecc.errors.corrected.volatile.total,ecc.errors.uncorrected.volatile.total --format=csv,noheader,nounits"
+
+// Throttle reason bits of clocks_throttle_reasons.active worth reporting under load
+const (
+	throttleSWThermal = 0x20
+	throttleHWSlow    = 0x08
+	throttleHWThermal = 0x40
+	throttleHWPower   = 0x80
+)
+
+// matmulScript keeps every GPU busy with bf16 matrix multiplies for the given number of
+// seconds, one process per GPU, and checks each result against the first so silent
+// computation errors are caught as gpu-burn would
+const matmulScript = `import os, sys, time, torch
+seconds = float(sys.argv[1])
+dev = torch.device("cuda", 0)
+n = 8192
+torch.manual_seed(0)
+a = torch.randn(n, n, device=dev, dtype=torch.bfloat16)
+b = torch.randn(n, n, device=dev, dtype=torch.bfloat16)
+ref = (a @ b).float()
+gpu = os.environ.get("DGX_STRESS_GPU", "0")
+start = last = time.time()
+iters = errors = 0
+while time.time() - start < seconds:
+    c = a @ b
+    iters += 1
+    if iters % 50 == 0:
+        if not torch.allclose(c.float(), ref, rtol=1e-2, atol=1e-1):
+            errors += 1
+        torch.cuda.synchronize()
+        now = time.time()
+        if now - last >= 30:
+            print(f"GPU {gpu}: {iters} iterations, {2 * n**3 * iters / (now - start) / 1e12:.1f} TFLOPS, {errors} errors", flush=True)
+            last = now
+torch.cuda.synchronize()
+elapsed = time.time() - start
+print(f"GPU {gpu}: done, {2 * n**3 * iters / elapsed / 1e12:.1f} TFLOPS average, {errors} errors", flush=True)
+`
+
+// DefaultImage returns the container image method runs in by default
+func DefaultImage(method string) string {
+	if method == "gpu-burn" {
+		return DefaultGPUBurnImage
+	}
+	return DefaultStressImage
+}
+
+// StressCommand starts the detached stress container for method, which stops on its own
+// after duration. gpu-burn is built from source at commit, a full hash, in a CUDA devel
+// image for the GPU's compute capability, so its run starts a few minutes later. The
+// container is kept after it exits so its status can be read; removeCommand removes it.
+func StressCommand(method, image, commit string, duration time.Duration) (string, error) {
+	seconds := int(duration.Seconds())
+	var script string
+	switch method {
+	case "matmul":
+		script = fmt.Sprintf(`cat > /tmp/stress.py <<'EOF'
+%sEOF
+pids=""
+for i in $(nvidia-smi --query-gpu=index --format=csv,noheader); do
+  CUDA_VISIBLE_DEVICES=$i DGX_STRESS_GPU=$i python /tmp/stress.py %d & pids="$pids $!"
+done
+status=0
+for p in $pids; do wait $p || status=1; done
+exit $status`, matmulScript, seconds)
+	case "gpu-burn":
+		if !pins.CommitPattern.MatchString(commit) {
+			return "", fmt.Errorf("gpu-burn is built at a pinned commit: give the full wilicc/gpu-burn commit hash with --gpu-burn-commit or pins.gpu_burn")
+		}
+		script = fmt.Sprintf(`set -e
+export DEBIAN_FRONTEND=noninteractive
+apt-get update -qq && apt-get install -y -qq git make >/dev/null
+git init -q /tmp/gpu-burn
+cd /tmp/gpu-burn
+git fetch -q --depth 1 https://github.com/wilicc/gpu-burn %s
+git checkout -q FETCH_HEAD
+cc=$(nvidia-smi --query-gpu=compute_cap --format=csv,noheader | head -1 | tr -d .)
+make -s COMPUTE="$cc" >/dev/null
+./gpu_burn -tc %d`, commit, seconds)
+	default:
+		return "", fmt.Errorf("unknown stress method %q (use %s)", method, strings.Join(StressMethods, " or "))
+	}
+	return fmt.Sprintf("docker rm -f %[1]s >/dev/null 2>&1; docker run -d --gpus all --ipc=host --name %[1]s %[2]s bash -c %[3]s",
+		StressContainer, ssh.ShellQuote(image), ssh.ShellQuote(script)), nil
+}
+
+// sampleCommand streams readings until the stress container exits, then prints the
+// container's exit status as "exit N"
+func sampleCommand(interval time.Duration) string {
+	return fmt.Sprintf("%[1]s & pid=$!; code=$(docker wait %[2]s 2>/dev/null || docker inspect -f '{{.State.ExitCode}}' %[2]s 2>/dev/null); kill $pid 2>/dev/null; wait $pid 2>/dev/null; echo \"exit $code\"",
+		fmt.Sprintf(stressQuery, interval.Milliseconds()), StressContainer)
+}
+
+// parseLoadExit reads the status printed by sampleCommand; one that cannot be read, such
+// as for a container that vanished, is -1 and fails the run
+func parseLoadExit(code string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(code))
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// logsCommand follows the stress container's output until it exits
+const logsCommand = "docker logs -f " + StressContainer + " 2>&1"
+
+// stopCommand stops the stress container early
+const stopCommand = "docker stop -t 5 " + StressContainer + " >/dev/null 2>&1 || true"
+
+// removeCommand removes the stress container once its status and output are read
+const removeCommand = "docker rm -f " + StressContainer + " >/dev/null 2>&1 || true"
+
+// ECCCounts are a GPU's volatile ECC error totals; -1 when the GPU does not report ECC
+// (GB10's unified LPDDR5X memory, for one)
+type ECCCounts struct {
+	Corrected   int64 `json:"corrected"`
+	Uncorrected int64 `json:"uncorrected"`
+}
+
+// ParseECC parses the ECC query's output by GPU index
+func ParseECC(output string) map[int]ECCCounts {
+	counts := map[int]ECCCounts{}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Split(line, ",")
+		if len(fields) != 3 {
+			continue
+		}
+		index, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+		if err != nil {
+			continue
+		}
+		count := func(s string) int64 {
+			v, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+			if err != nil {
+				return -1
+			}
+			return v
+		}
+		counts[index] = ECCCounts{Corrected: count(fields[1]), Uncorrected: count(fields[2])}
+	}
+	return counts
+}
+
+// ParseXid returns the Xid lines of the kernel log query's output
+func ParseXid(output string) []string {
+	var events []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); strings.Contains(line, "Xid") {
+			events = append(events, line)
+		}
+	}
+	return events
+}
+
+// GPUStress summarizes one GPU's readings over a stress test
+type GPUStress struct {
+	Index        int      `json:"index"`
+	Samples      int      `json:"samples"`
+	MaxTemp      int      `json:"max_temp_c"`
+	MaxPower     float64  `json:"max_power_w"`
+	MaxUtil      int      `json:"max_utilization"`
+	MinSMClock   int      `json:"min_sm_clock_mhz"`
+	AvgSMClock   int      `json:"avg_sm_clock_mhz"`
+	MaxSMClock   int      `json:"max_sm_clock_mhz"`
+	RatedSMClock int      `json:"rated_sm_clock_mhz"`
+	Throttle     []string `json:"throttle,omitempty"`
+
+	clockSum     int64
+	clockSamples int
+	throttleBits uint64
+}
+
+// StressReport is the outcome of a stress test
+type StressReport struct {
+	Method    string            `json:"method"`
+	Duration  time.Duration     `json:"duration_ns"`
+	Aborted   string            `json:"aborted,omitempty"`
+	GPUs      []*GPUStress      `json:"gpus"`
+	ECCBefore map[int]ECCCounts `json:"ecc_before,omitempty"`
+	ECCAfter  map[int]ECCCounts `json:"ecc_after,omitempty"`
+	Xid       []string          `json:"xid,omitempty"`
+	LoadExit  int               `json:"load_exit_status"`
+	Errors    []string          `json:"computation_errors,omitempty"`
+}
+
+// Observe adds a streamed reading and returns the GPU's index and temperature, or false
+// when the line is not a reading
+func (r *StressReport) Observe(line string) (int, int, bool) {
+	fields := strings.Split(line, ",")
+	if len(fields) != 7 {
+		return 0, 0, false
+	}
+	index, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+	if err != nil {
+		return 0, 0, false
+	}
+	number := func(s string) float64 {
+		v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return -1
+		}
+		return v
+	}
+
+	var g *GPUStress
+	for _, known := range r.GPUs {
+		if known.Index == index {
+			g = known
+		}
+	}
+	if g == nil {
+		g = &GPUStress{Index: index, MinSMClock: -1}
+		r.GPUs = append(r.GPUs, g)
+		sort.Slice(r.GPUs, func(i, j int) bool { return r.GPUs[i].Index < r.GPUs[j].Index })
+	}
+
+	temp := int(number(fields[1]))
+	clock := int(number(fields[2]))
+	g.Samples++
+	g.MaxTemp = max(g.MaxTemp, temp)
+	g.MaxPower = max(g.MaxPower, number(fields[4]))
+	g.MaxUtil = max(g.MaxUtil, int(number(fields[5])))
+	g.RatedSMClock = max(g.RatedSMClock, int(number(fields[3])))
+	if clock > 0 {
+		// Clocks before the load ramps up would skew the minimum, so only loaded samples count
+		if int(number(fields[5])) >= 90 {
+			if g.MinSMClock < 0 || clock < g.MinSMClock {
+				g.MinSMClock = clock
+			}
+			g.clockSum += int64(clock)
+			g.clockSamples++
+			g.AvgSMClock = int(g.clockSum / int64(g.clockSamples))
+		}
+		g.MaxSMClock = max(g.MaxSMClock, clock)
+	}
+	if bits, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimSpace(fields[6]), "0x"), 16, 64); err == nil {
+		g.throttleBits |= bits
+		g.Throttle = throttleNames(g.throttleBits)
+	}
+	return index, temp, true
+}
+
+// ObserveOutput records computation errors the load generator reported
+func (r *StressReport) ObserveOutput(line string) {
+	line = strings.TrimSpace(line)
+	switch {
+	case strings.Contains(line, "FAULTY"):
+		r.Errors = append(r.Errors, line)
+	case strings.Contains(line, "done,") && !strings.HasSuffix(line, " 0 errors"):
+		r.Errors = append(r.Errors, line)
+	}
+}
+
+func throttleNames(bits uint64) []string {
+	var names []string
+	for _, t := range []struct {
+		bit  uint64
+		name string
+	}{
+		{throttleSWThermal, "software thermal slowdown"},
+		{throttleHWSlow, "hardware slowdown"},
+		{throttleHWThermal, "hardware thermal slowdown"},
+		{throttleHWPower, "hardware power brake"},
+	} {
+		if bits&t.bit != 0 {
+			names = append(names, t.name)
+		}
+	}
+	return names
+}
+
+// Assess classifies the run. Xid events, new uncorrectable ECC errors, computation errors,
+// hitting the temperature limit, and a load that did not finish fail the unit; thermal throttling and new correctable
+// ECC errors are warnings.
+func (r *StressReport) Assess(maxTemp int) (health.Severity, []string) {
+	severity := health.SeverityOK
+	var findings []string
+	flag := func(s health.Severity, format string, args ...any) {
+		if s > severity {
+			severity = s
+		}
+		findings = append(findings, fmt.Sprintf(format, args...))
+	}
+
+	if r.Aborted != "" {
+		flag(health.SeverityCritical, "stopped early: %s", r.Aborted)
+	} else if r.LoadExit < 0 {
+		flag(health.SeverityCritical, "the exit status of the %s load could not be read; see its output above", r.Method)
+	} else if r.LoadExit != 0 {
+		flag(health.SeverityCritical, "the %s load exited with status %d; see its output above", r.Method, r.LoadExit)
+	}
+	if len(r.GPUs) == 0 {
+		flag(health.SeverityCritical, "no GPU readings were taken")
+	}
+	for _, e := range r.Errors {
+		flag(health.SeverityCritical, "computation errors: %s", e)
+	}
+	if len(r.Xid) > 0 {
+		flag(health.SeverityCritical, "%d Xid error(s) in the kernel log", len(r.Xid))
+	}
+	for _, g := range r.GPUs {
+		before, after := r.ECCBefore[g.Index], r.ECCAfter[g.Index]
+		if after.Uncorrected > 0 && after.Uncorrected > before.Uncorrected {
+			flag(health.SeverityCritical, "GPU%d: %d new uncorrectable ECC errors", g.Index, after.Uncorrected-max(before.Uncorrected, 0))
+		}
+		if after.Corrected > 0 && after.Corrected > before.Corrected {
+			flag(health.SeverityWarn, "GPU%d: %d new correctable ECC errors", g.Index, after.Corrected-max(before.Corrected, 0))
+		}
+		if g.MaxTemp >= maxTemp && r.Aborted == "" {
+			flag(health.SeverityCritical, "GPU%d reached %d°C", g.Index, g.MaxTemp)
+		}
+		if len(g.Throttle) > 0 {
+			flag(health.SeverityWarn, "GPU%d throttled (%s); check airflow and ambient temperature", g.Index, strings.Join(g.Throttle, ", "))
+		}
+		if g.MaxUtil < 90 && g.Samples > 0 {
+			flag(health.SeverityWarn, "GPU%d peaked at %d%% utilization; the load may not have reached it", g.Index, g.MaxUtil)
+		}
+	}
+	return severity, findings
+}
+
+// StressOptions configure a stress test
+type StressOptions struct {
+	Method string
+	Image  string
+	// GPUBurnCommit is the gpu-burn commit built for the gpu-burn method
+	GPUBurnCommit string
+	Duration      time.Duration
+	MaxTemp       int           // stop the test when any GPU reaches this temperature
+	Interval      time.Duration // sampling interval
+	Progress      time.Duration // how often a reading summary is printed
+}
+
+// RunStress runs a stress test and reports what it observed. The load's own output and
+// periodic readings are written to out. The run stops early when a GPU reaches
+// opts.MaxTemp or on Ctrl-C; either way the container is stopped before RunStress
+// returns.
+func RunStress(client *ssh.Client, opts StressOptions, out io.Writer) (*StressReport, error) {
+	command, err := StressCommand(opts.Method, opts.Image, opts.GPUBurnCommit, opts.Duration)
+	if err != nil {
+		return nil, err
+	}
+	// Until sampling prints the container's status, the load counts as failed
+	report := &StressReport{Method: opts.Method, LoadExit: -1}
+
+	// The remote clock bounds the kernel log query, so local clock skew cannot hide events
+	since, err := client.Execute("date +%s")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the DGX clock: %w", err)
+	}
+	start, _ := strconv.ParseInt(strings.TrimSpace(since), 10, 64)
+	if output, err := client.Execute(eccQuery + " 2>/dev/null || true"); err == nil {
+		report.ECCBefore = ParseECC(output)
+	}
+
+	image := ssh.ShellQuote(opts.Image)
+	fmt.Fprintf(out, "Pulling %s if needed...\n", opts.Image)
+	if err := client.Stream(fmt.Sprintf("docker image inspect %s >/dev/null 2>&1 || docker pull %s", image, image), nil, out, out); err != nil {
+		return nil, fmt.Errorf("failed to pull %s: %w", opts.Image, err)
+	}
+	if output, err := client.Execute(command); err != nil {
+		return nil, fmt.Errorf("failed to start the stress container: %s", strings.TrimSpace(output))
+	}
+	began := time.Now()
+
+	var mu sync.Mutex
+	var stopOnce sync.Once
+	stop := func(reason string) {
+		stopOnce.Do(func() {
+			mu.Lock()
+			report.Aborted = reason
+			mu.Unlock()
+			fmt.Fprintf(out, "Stopping: %s\n", reason)
+			client.Execute(stopCommand)
+		})
+	}
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-interrupt:
+			stop("interrupted")
+		case <-done:
+		}
+	}()
+
+	logsDone := make(chan struct{})
+	go func() {
+		defer close(logsDone)
+		client.Stream(logsCommand, nil, &lineWriter{onLine: func(line string) {
+			fmt.Fprintln(out, line)
+			mu.Lock()
+			report.ObserveOutput(line)
+			mu.Unlock()
+		}}, nil)
+	}()
+
+	lastProgress := time.Now()
+	err = client.Stream(ssh.WithParseLocale(sampleCommand(opts.Interval)), nil, &lineWriter{onLine: func(line string) {
+		if code, ok := strings.CutPrefix(line, "exit "); ok {
+			mu.Lock()
+			report.LoadExit = parseLoadExit(code)
+			mu.Unlock()
+			return
+		}
+		mu.Lock()
+		index, temp, ok := report.Observe(line)
+		var summary string
+		if ok && time.Since(lastProgress) >= opts.Progress {
+			lastProgress = time.Now()
+			summary = report.summary(time.Since(began))
+		}
+		mu.Unlock()
+		if summary != "" {
+			fmt.Fprintln(out, summary)
+		}
+		if ok && opts.MaxTemp > 0 && temp >= opts.MaxTemp {
+			go stop(fmt.Sprintf("GPU%d reached %d°C (limit %d°C)", index, temp, opts.MaxTemp))
+		}
+	}}, nil)
+	if err != nil {
+		client.Execute(stopCommand + "; " + removeCommand)
+		return nil, fmt.Errorf("GPU sampling failed: %w", err)
+	}
+	select {
+	case <-logsDone:
+	case <-time.After(10 * time.Second):
+	}
+	client.Execute(removeCommand)
+	// Let a stop triggered by the last readings finish before reporting
+	stopOnce.Do(func() {})
+
+	report.Duration = time.Since(began).Round(time.Second)
+	if output, err := client.Execute(eccQuery + " 2>/dev/null || true"); err == nil {
+		report.ECCAfter = ParseECC(output)
+	}
+	if output, err := client.Execute(KernelLogCommand(start, false)); err == nil {
+		report.Xid = ParseXid(output)
+	}
+	return report, nil
+}
+
+// summary is a one-line reading of every GPU for progress output
+func (r *StressReport) summary(elapsed time.Duration) string {
+	parts := []string{fmt.Sprintf("[%s]", elapsed.Round(time.Second))}
+	for _, g := range r.GPUs {
+		parts = append(parts, fmt.Sprintf("GPU%d max %d°C, %d MHz avg, %.0f W max", g.Index, g.MaxTemp, max(g.AvgSMClock, 0), max(g.MaxPower, 0)))
+	}
+	return strings.Join(parts, " ")
+}
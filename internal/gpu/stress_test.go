@@ -0,0 +1,118 @@
+package gpu
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/health"
+)
+
+func TestStressReport(t *testing.T) {
+	r := &StressReport{Method: "matmul"}
+	for _, line := range []string{
+		"0, 45, 900, 3003, 20.5, 3, 0x0000000000000001",
+		"0, 71, 2400, 3003, 140.2, 100, 0x0000000000000000",
+		"0, 84, 2100, 3003, 151.0, 100, 0x0000000000000020",
+		"exit 0",
+		"garbage",
+	} {
+		r.Observe(line)
+	}
+	if len(r.GPUs) != 1 {
+		t.Fatalf("got %d GPUs, want 1", len(r.GPUs))
+	}
+	g := r.GPUs[0]
+	if g.Samples != 3 || g.MaxTemp != 84 || g.MaxPower != 151.0 || g.MaxUtil != 100 {
+		t.Fatalf("unexpected summary: %+v", g)
+	}
+	// The idle sample's clock does not count toward the loaded minimum and average
+	if g.MinSMClock != 2100 || g.AvgSMClock != 2250 || g.MaxSMClock != 2400 || g.RatedSMClock != 3003 {
+		t.Fatalf("unexpected clocks: %+v", g)
+	}
+	if len(g.Throttle) != 1 || g.Throttle[0] != "software thermal slowdown" {
+		t.Fatalf("throttle = %v", g.Throttle)
+	}
+
+	severity, findings := r.Assess(DefaultMaxTemp)
+	if severity != health.SeverityWarn || len(findings) != 1 {
+		t.Fatalf("Assess = %v %v, want one warning", severity, findings)
+	}
+
+	r.ObserveOutput("GPU 0: done, 95.2 TFLOPS average, 0 errors")
+	r.ObserveOutput("GPU 0: OK")
+	if len(r.Errors) != 0 {
+		t.Fatalf("clean output recorded errors: %v", r.Errors)
+	}
+	r.ObserveOutput("GPU 0: done, 95.2 TFLOPS average, 3 errors")
+	r.ObserveOutput("GPU 1: FAULTY")
+	r.Xid = ParseXid("2025-01-01T10:00:00 spark kernel: NVRM: Xid (PCI:0000:01:00): 79, GPU has fallen off the bus\n")
+	r.ECCBefore = ParseECC("0, 0, 0\n")
+	r.ECCAfter = ParseECC("0, 2, 1\n")
+	severity, findings = r.Assess(DefaultMaxTemp)
+	if severity != health.SeverityCritical {
+		t.Fatalf("Assess = %v, want critical", severity)
+	}
+	joined := strings.Join(findings, "\n")
+	for _, want := range []string{"3 errors", "FAULTY", "1 Xid", "1 new uncorrectable", "2 new correctable"} {
+		if !strings.Contains(joined, want) {
+			t.Fatalf("findings %q lack %q", joined, want)
+		}
+	}
+}
+
+func TestParseECC(t *testing.T) {
+	counts := ParseECC("0, [N/A], [N/A]\n1, 4, 0\n")
+	if c := counts[0]; c.Corrected != -1 || c.Uncorrected != -1 {
+		t.Fatalf("GPU0 = %+v, want -1 for N/A", c)
+	}
+	if c := counts[1]; c.Corrected != 4 || c.Uncorrected != 0 {
+		t.Fatalf("GPU1 = %+v", c)
+	}
+
+	// Unreported ECC before and after is not an error
+	r := &StressReport{GPUs: []*GPUStress{{Index: 0, Samples: 1, MaxUtil: 100}}, ECCBefore: counts, ECCAfter: counts}
+	if severity, findings := r.Assess(DefaultMaxTemp); severity != health.SeverityOK {
+		t.Fatalf("Assess = %v %v, want ok", severity, findings)
+	}
+}
+
+func TestStressCommand(t *testing.T) {
+	commit := strings.Repeat("0123456789", 4)
+	for _, method := range StressMethods {
+		command, err := StressCommand(method, DefaultImage(method), commit, 5*time.Minute)
+		if err != nil {
+			t.Fatalf("%s: %v", method, err)
+		}
+		if !strings.Contains(command, "--name "+StressContainer) || !strings.Contains(command, "300") {
+			t.Fatalf("%s: unexpected command %s", method, command)
+		}
+		// The container must outlive the load so docker wait can read its status
+		if strings.Contains(command, "--rm") {
+			t.Fatalf("%s: container removed on exit: %s", method, command)
+		}
+	}
+	if command, _ := StressCommand("gpu-burn", DefaultGPUBurnImage, commit, time.Minute); !strings.Contains(command, commit) {
+		t.Fatalf("gpu-burn not built at the pinned commit: %s", command)
+	}
+	for _, bad := range []string{"", "master", "0123abc"} {
+		if _, err := StressCommand("gpu-burn", DefaultGPUBurnImage, bad, time.Minute); err == nil {
+			t.Fatalf("gpu-burn accepted commit %q", bad)
+		}
+	}
+	if _, err := StressCommand("prime95", "", "", time.Minute); err == nil {
+		t.Fatalf("unknown method accepted")
+	}
+}
+
+func TestParseLoadExit(t *testing.T) {
+	for code, want := range map[string]int{"0": 0, " 137\n": 137, "": -1, "Error: No such container": -1} {
+		if got := parseLoadExit(code); got != want {
+			t.Fatalf("parseLoadExit(%q) = %d, want %d", code, got, want)
+		}
+	}
+	r := &StressReport{Method: "matmul", LoadExit: -1, GPUs: []*GPUStress{{}}}
+	if severity, findings := r.Assess(DefaultMaxTemp); severity != health.SeverityCritical || !strings.Contains(strings.Join(findings, "\n"), "could not be read") {
+		t.Fatalf("unreadable exit status should fail, got %v %v", severity, findings)
+	}
+}
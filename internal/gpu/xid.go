@@ -0,0 +1,256 @@
+package gpu
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/health"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+// XidInfo explains one Xid code. Cause is who is usually at fault: the application, the
+// driver or firmware, or the hardware.
+type XidInfo struct {
+	Name     string
+	Cause    string
+	Advice   string
+	Severity health.Severity
+}
+
+// Xid causes
+const (
+	CauseApplication = "application"
+	CauseDriver      = "driver/firmware"
+	CauseHardware    = "hardware"
+)
+
+// xids are the codes seen in practice on DGX systems, after NVIDIA's Xid catalog
+var xids = map[int]XidInfo{
+	8:   {"GPU stopped processing (watchdog)", CauseDriver, "Usually follows an application hang; restart the workload and update the driver if it recurs.", health.SeverityWarn},
+	13:  {"Graphics engine exception", CauseApplication, "Often an out-of-bounds access in a kernel; run the workload under compute-sanitizer. Recurring across workloads points at the hardware.", health.SeverityWarn},
+	31:  {"GPU memory page fault", CauseApplication, "An illegal memory access by the process named in the message; debug it with compute-sanitizer.", health.SeverityWarn},
+	32:  {"Invalid or corrupted push buffer stream", CauseDriver, "Driver or PCIe corruption; update the driver and check dmesg for bus errors.", health.SeverityWarn},
+	38:  {"Driver firmware error", CauseDriver, "Update the driver; a reboot clears the GPU state.", health.SeverityWarn},
+	43:  {"GPU stopped processing", CauseApplication, "The application's channel was reset after a fault; other work is unaffected.", health.SeverityWarn},
+	45:  {"Preemptive cleanup", CauseApplication, "Cleanup after a killed process or an earlier error; harmless on its own.", health.SeverityInfo},
+	48:  {"Double-bit ECC error", CauseHardware, "Uncorrectable memory error; reset the GPU or reboot, and contact NVIDIA support if it recurs.", health.SeverityCritical},
+	61:  {"Internal micro-controller breakpoint", CauseDriver, "Firmware fault; reboot, and update the driver if it recurs.", health.SeverityCritical},
+	62:  {"Internal micro-controller halt", CauseDriver, "Firmware fault; reboot, and update the driver if it recurs.", health.SeverityCritical},
+	63:  {"ECC page retirement or row remapping event", CauseHardware, "Memory was retired after errors; reboot to apply it. Frequent events mean failing memory.", health.SeverityWarn},
+	64:  {"ECC page retirement or row remapping failure", CauseHardware, "Failing memory could not be retired; contact NVIDIA support.", health.SeverityCritical},
+	69:  {"Graphics engine class error", CauseDriver, "Usually a driver bug; update the driver.", health.SeverityWarn},
+	74:  {"NVLink error", CauseHardware, "Check the link between the two Sparks and reseat the cable.", health.SeverityCritical},
+	79:  {"GPU has fallen off the bus", CauseHardware, "The GPU stopped responding; check power and cooling and reboot. Contact NVIDIA support if it recurs.", health.SeverityCritical},
+	92:  {"High single-bit ECC error rate", CauseHardware, "Correctable but frequent memory errors; watch for Xid 48/63 and plan a service call.", health.SeverityWarn},
+	94:  {"Contained ECC error", CauseHardware, "An uncorrectable memory error was contained to one application; restart it. Other work is unaffected.", health.SeverityWarn},
+	95:  {"Uncontained ECC error", CauseHardware, "An uncorrectable memory error affected every application; reset the GPU or reboot.", health.SeverityCritical},
+	109: {"Context switch timeout", CauseDriver, "Often an application hang; update the driver if it recurs.", health.SeverityWarn},
+	119: {"GSP RPC timeout", CauseDriver, "The GPU system processor stopped answering; reboot and update the driver.", health.SeverityCritical},
+	120: {"GSP error", CauseDriver, "GPU system processor fault; reboot and update the driver.", health.SeverityCritical},
+	121: {"C2C link error", CauseHardware, "Error on the chip-to-chip link between CPU and GPU; reboot, and contact NVIDIA support if it recurs.", health.SeverityCritical},
+	154: {"GPU recovery action changed", CauseDriver, "The message names the recovery needed (GPU reset or node reboot); do what it says.", health.SeverityCritical},
+}
+
+// LookupXid explains code. Unknown codes are reported as warnings pointing at NVIDIA's
+// catalog.
+func LookupXid(code int) XidInfo {
+	if info, ok := xids[code]; ok {
+		return info
+	}
+	return XidInfo{
+		Name:     "Unlisted Xid",
+		Cause:    "unknown",
+		Advice:   "See https://docs.nvidia.com/deploy/xid-errors/ for this code.",
+		Severity: health.SeverityWarn,
+	}
+}
+
+// nvrmNotes explain NVRM messages that are not Xids, matched by substring
+var nvrmNotes = []struct {
+	match    string
+	name     string
+	advice   string
+	severity health.Severity
+}{
+	{"fallen off the bus", "GPU has fallen off the bus", "The GPU stopped responding; check power and cooling and reboot.", health.SeverityCritical},
+	{"RmInitAdapter failed", "GPU initialization failed", "The driver could not bring the GPU up; reboot, and reinstall the driver if it persists.", health.SeverityCritical},
+	{"API mismatch", "Driver version mismatch", "The kernel module and user-space driver differ, usually after an upgrade; reboot.", health.SeverityWarn},
+	{"nvAssertFailed", "Driver assertion", "Internal driver check failed; report it with 'dgx support bundle' if it recurs.", health.SeverityWarn},
+	{"GPU lost", "GPU lost", "The GPU stopped responding; reboot.", health.SeverityCritical},
+}
+
+// FaultEvent is one NVRM kernel message. Xid is 0 for messages that are not Xid reports.
+type FaultEvent struct {
+	Time    time.Time `json:"time"`
+	Xid     int       `json:"xid,omitempty"`
+	Device  string    `json:"device,omitempty"`
+	Process string    `json:"process,omitempty"`
+	Message string    `json:"message"`
+}
+
+var (
+	xidPattern     = regexp.MustCompile(`NVRM: Xid \(([^)]*)\): (\d+),\s*(.*)`)
+	nvrmPattern    = regexp.MustCompile(`NVRM: (.*)`)
+	processPattern = regexp.MustCompile(`pid=(\d+), name=([^,]+)`)
+	// Numbers, addresses, and PIDs vary between otherwise identical messages
+	variablePattern = regexp.MustCompile(`0x[0-9a-fA-F]+|\b\d+\b`)
+)
+
+// ParseFault parses a kernel log line written by KernelLogCommand, reporting false for
+// lines that are not NVRM messages
+func ParseFault(line string) (FaultEvent, bool) {
+	line = strings.TrimSpace(line)
+	m := nvrmPattern.FindStringSubmatch(line)
+	if m == nil {
+		return FaultEvent{}, false
+	}
+	e := FaultEvent{Time: parseLogTime(line), Message: strings.TrimSpace(m[1])}
+	if x := xidPattern.FindStringSubmatch(line); x != nil {
+		e.Device = x[1]
+		e.Xid, _ = strconv.Atoi(x[2])
+		e.Message = strings.TrimSpace(x[3])
+		if p := processPattern.FindStringSubmatch(x[3]); p != nil {
+			e.Process = fmt.Sprintf("%s (pid %s)", strings.TrimSpace(p[2]), p[1])
+		}
+	}
+	return e, true
+}
+
+// parseLogTime reads the leading ISO timestamp of journalctl -o short-iso or dmesg
+// --time-format iso, or returns the zero time
+func parseLogTime(line string) time.Time {
+	stamp, _, _ := strings.Cut(line, " ")
+	stamp = strings.Replace(stamp, ",", ".", 1)
+	for _, layout := range []string{"2006-01-02T15:04:05-0700", "2006-01-02T15:04:05.999999999-07:00", "2006-01-02T15:04:05.999999999-0700"} {
+		if t, err := time.Parse(layout, stamp); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Explain returns the name, cause, advice, and severity of e's kind of fault
+func (e FaultEvent) Explain() (name, cause, advice string, severity health.Severity) {
+	if e.Xid != 0 {
+		info := LookupXid(e.Xid)
+		return info.Name, info.Cause, info.Advice, info.Severity
+	}
+	for _, n := range nvrmNotes {
+		if strings.Contains(e.Message, n.match) {
+			return n.name, CauseDriver, n.advice, n.severity
+		}
+	}
+	return "Driver message", "", "", health.SeverityInfo
+}
+
+// FaultGroup is every event of one kind: one Xid code, or one NVRM message that differs
+// only in its numbers
+type FaultGroup struct {
+	Xid       int             `json:"xid,omitempty"`
+	Name      string          `json:"name"`
+	Cause     string          `json:"cause,omitempty"`
+	Advice    string          `json:"advice,omitempty"`
+	Severity  health.Severity `json:"-"`
+	Level     string          `json:"severity"`
+	Count     int             `json:"count"`
+	First     time.Time       `json:"first"`
+	Last      time.Time       `json:"last"`
+	Devices   []string        `json:"devices,omitempty"`
+	Processes []string        `json:"processes,omitempty"`
+	Example   string          `json:"example"`
+}
+
+// GroupFaults groups events by kind, most severe first and then most recent
+func GroupFaults(events []FaultEvent) []*FaultGroup {
+	byKey := map[string]*FaultGroup{}
+	var groups []*FaultGroup
+	for _, e := range events {
+		key := fmt.Sprintf("xid %d", e.Xid)
+		if e.Xid == 0 {
+			key = variablePattern.ReplaceAllString(e.Message, "#")
+		}
+		g, ok := byKey[key]
+		if !ok {
+			g = &FaultGroup{Xid: e.Xid, First: e.Time}
+			g.Name, g.Cause, g.Advice, g.Severity = e.Explain()
+			g.Level = g.Severity.String()
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.Count++
+		g.Last = e.Time
+		g.Example = e.Message
+		if e.Device != "" && !slices.Contains(g.Devices, e.Device) {
+			g.Devices = append(g.Devices, e.Device)
+		}
+		if e.Process != "" && !slices.Contains(g.Processes, e.Process) && len(g.Processes) < 5 {
+			g.Processes = append(g.Processes, e.Process)
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].Severity != groups[j].Severity {
+			return groups[i].Severity > groups[j].Severity
+		}
+		return groups[i].Last.After(groups[j].Last)
+	})
+	return groups
+}
+
+// KernelLogCommand prints the kernel's NVRM messages with ISO timestamps: since the given
+// Unix time, or in the current boot when since is 0. With follow it instead waits and
+// prints new messages as they are logged. The journal needs the user in the adm or
+// systemd-journal group; otherwise dmesg is read, with sudo when it runs without a
+// password.
+func KernelLogCommand(since int64, follow bool) string {
+	window, dmesgFlags := "-b", ""
+	switch {
+	case follow:
+		window, dmesgFlags = "-n 0 -f", " -W"
+	case since > 0:
+		window = fmt.Sprintf("--since @%d", since)
+	}
+	return fmt.Sprintf(`if [ -n "$(journalctl -k -b -n 1 -q --no-pager 2>/dev/null)" ]; then
+  log="journalctl -k --no-pager -q -o short-iso %s"
+elif sudo -n true 2>/dev/null; then
+  log="sudo -n dmesg --time-format iso%s"
+elif dmesg >/dev/null 2>&1; then
+  log="dmesg --time-format iso%[2]s"
+else
+  echo "cannot read the kernel log; add the user to the adm group or allow passwordless sudo"
+  exit 1
+fi
+$log | grep --line-buffered 'NVRM' || true`, window, dmesgFlags)
+}
+
+// ReadFaults returns the NVRM messages logged since the given Unix time, or in the
+// current boot when since is 0
+func ReadFaults(client *ssh.Client, since int64) ([]FaultEvent, error) {
+	output, err := client.Execute(KernelLogCommand(since, false))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", strings.TrimSpace(output), err)
+	}
+	var events []FaultEvent
+	for _, line := range strings.Split(output, "\n") {
+		if e, ok := ParseFault(line); ok {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// FollowFaults calls onEvent for each NVRM message as the kernel logs it, until the
+// connection is closed
+func FollowFaults(client *ssh.Client, onEvent func(FaultEvent)) error {
+	w := &lineWriter{onLine: func(line string) {
+		if e, ok := ParseFault(line); ok {
+			onEvent(e)
+		}
+	}}
+	return client.Stream(KernelLogCommand(0, true), nil, w, os.Stderr)
+}
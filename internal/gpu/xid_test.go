@@ -0,0 +1,72 @@
+package gpu
+
+import (
+	"testing"
+
+	"github.com/weatherman/dgx-manager/internal/health"
+)
+
+func TestParseFault(t *testing.T) {
+	e, ok := ParseFault("2025-10-14T09:12:03+0000 spark kernel: NVRM: Xid (PCI:000f:01:00): 31, pid=4242, name=python3, Ch 00000008, intr 00000000. MMU Fault")
+	if !ok || e.Xid != 31 || e.Device != "PCI:000f:01:00" || e.Process != "python3 (pid 4242)" {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	if e.Time.IsZero() || e.Time.Hour() != 9 {
+		t.Fatalf("unexpected time %v", e.Time)
+	}
+
+	e, ok = ParseFault("2025-10-14T09:12:03,123456+00:00 NVRM: GPU at PCI:000f:01:00: GPU has fallen off the bus.")
+	if !ok || e.Xid != 0 || e.Time.IsZero() {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	if name, _, _, severity := e.Explain(); name != "GPU has fallen off the bus" || severity != health.SeverityCritical {
+		t.Fatalf("unexpected explanation %q %v", name, severity)
+	}
+
+	if _, ok := ParseFault("2025-10-14T09:12:03+0000 spark kernel: usb 1-1: new device"); ok {
+		t.Fatalf("parsed a non-NVRM line")
+	}
+}
+
+func TestGroupFaults(t *testing.T) {
+	var events []FaultEvent
+	for _, line := range []string{
+		"2025-10-14T09:00:00+0000 spark kernel: NVRM: Xid (PCI:000f:01:00): 13, pid=10, name=a, Graphics Exception",
+		"2025-10-14T09:05:00+0000 spark kernel: NVRM: Xid (PCI:000f:01:00): 79, pid=0, name=b, GPU has fallen off the bus.",
+		"2025-10-14T09:10:00+0000 spark kernel: NVRM: Xid (PCI:000f:01:00): 13, pid=11, name=a, Graphics Exception",
+		"2025-10-14T09:11:00+0000 spark kernel: NVRM: nvAssertFailed: 0x1234 @ file.c:10",
+		"2025-10-14T09:12:00+0000 spark kernel: NVRM: nvAssertFailed: 0x5678 @ file.c:99",
+	} {
+		if e, ok := ParseFault(line); ok {
+			events = append(events, e)
+		}
+	}
+	groups := GroupFaults(events)
+	if len(groups) != 3 {
+		t.Fatalf("expected 3 groups, got %d", len(groups))
+	}
+	if groups[0].Xid != 79 || groups[0].Level != "critical" {
+		t.Fatalf("expected the critical Xid first, got %+v", groups[0])
+	}
+	var xid13 *FaultGroup
+	for _, g := range groups {
+		if g.Xid == 13 {
+			xid13 = g
+		}
+	}
+	if xid13 == nil || xid13.Count != 2 || len(xid13.Processes) != 2 || !xid13.Last.After(xid13.First) {
+		t.Fatalf("unexpected Xid 13 group %+v", xid13)
+	}
+	if groups[1].Count != 2 || groups[1].Name != "Driver assertion" {
+		t.Fatalf("expected assertions grouped despite differing numbers, got %+v", groups[1])
+	}
+}
+
+func TestLookupXid(t *testing.T) {
+	if info := LookupXid(48); info.Cause != CauseHardware || info.Severity != health.SeverityCritical {
+		t.Fatalf("unexpected Xid 48 %+v", info)
+	}
+	if info := LookupXid(9999); info.Name != "Unlisted Xid" {
+		t.Fatalf("unexpected unknown Xid %+v", info)
+	}
+}
@@ -0,0 +1,90 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Clock skew thresholds. A few seconds already trips short-lived tokens; beyond a minute
+// TLS handshakes, OAuth, and registry logins inside containers start failing.
+const (
+	SkewWarn     = 2 * time.Second
+	SkewCritical = time.Minute
+)
+
+// ClockSkew returns how far the DGX clock is ahead of this machine's (negative when it is
+// behind). The remote reading is compared with the middle of the round trip, so the result
+// is accurate to about half the command latency.
+func ClockSkew(ctx context.Context, exec Executor) (time.Duration, error) {
+	start := time.Now()
+	output, err := exec.ExecuteContext(ctx, "date +%s.%N")
+	end := time.Now()
+	if err != nil {
+		return 0, err
+	}
+	remote, err := parseEpoch(firstLine(output))
+	if err != nil {
+		return 0, err
+	}
+	return remote.Sub(start.Add(end.Sub(start) / 2)), nil
+}
+
+// parseEpoch parses "seconds.nanoseconds" as printed by date +%s.%N
+func parseEpoch(s string) (time.Time, error) {
+	secs, frac, _ := strings.Cut(s, ".")
+	sec, err := strconv.ParseInt(secs, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unexpected date output %q", s)
+	}
+	var nsec int64
+	if frac != "" {
+		// Busybox date prints %N literally; treat it as whole seconds
+		if n, err := strconv.ParseInt((frac + "000000000")[:9], 10, 64); err == nil {
+			nsec = n
+		}
+	}
+	return time.Unix(sec, nsec), nil
+}
+
+// FormatSkew describes a skew as "3.2s ahead of this machine"
+func FormatSkew(skew time.Duration) string {
+	direction := "ahead of"
+	if skew < 0 {
+		direction = "behind"
+	}
+	abs := time.Duration(math.Abs(float64(skew)))
+	return fmt.Sprintf("%s %s this machine", abs.Round(100*time.Millisecond), direction)
+}
+
+// probeSkew reports the skew in seconds for classifySkew
+func probeSkew(ctx context.Context, exec Executor) (string, error) {
+	skew, err := ClockSkew(ctx, exec)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatFloat(skew.Seconds(), 'f', 3, 64), nil
+}
+
+func classifySkew(output string, err error) (Severity, string) {
+	if err != nil {
+		return SeverityInfo, "could not read the DGX clock"
+	}
+	seconds, convErr := strconv.ParseFloat(firstLine(output), 64)
+	if convErr != nil {
+		return SeverityInfo, fmt.Sprintf("unexpected skew %q", firstLine(output))
+	}
+	skew := time.Duration(seconds * float64(time.Second))
+	abs := time.Duration(math.Abs(float64(skew)))
+	switch {
+	case abs >= SkewCritical:
+		return SeverityCritical, FormatSkew(skew) + "; TLS and token auth will fail (dgx run time sync)"
+	case abs >= SkewWarn:
+		return SeverityWarn, FormatSkew(skew) + " (dgx run time sync)"
+	default:
+		return SeverityOK, FormatSkew(skew)
+	}
+}
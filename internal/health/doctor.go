@@ -0,0 +1,351 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+// Severity classifies a diagnostic result
+type Severity int
+
+const (
+	SeverityOK Severity = iota
+	SeverityInfo
+	SeverityWarn
+	SeverityCritical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarn:
+		return "warn"
+	case SeverityCritical:
+		return "critical"
+	default:
+		return "ok"
+	}
+}
+
+// DefaultTimeout bounds a diagnostic that does not set its own timeout
+const DefaultTimeout = 10 * time.Second
+
+// Executor runs a remote command; *ssh.Client implements it
+type Executor interface {
+	ExecuteContext(ctx context.Context, command string) (string, error)
+}
+
+// Remedy is a known fix for a failing diagnostic: a remote command, or a playbook run
+// as by 'dgx run' (the playbook name and its arguments)
+type Remedy struct {
+	Description string
+	Command     string
+	Playbook    []string
+}
+
+// CommandLine returns what the remedy runs, as shown to the user
+func (r *Remedy) CommandLine() string {
+	if len(r.Playbook) > 0 {
+		return "dgx run " + strings.Join(r.Playbook, " ")
+	}
+	return r.Command
+}
+
+// Diagnostic is a doctor probe: a remote command and a classifier for its outcome. Probe,
+// when set, replaces Command for checks that need more than one command's output.
+type Diagnostic struct {
+	Name     string
+	Command  string
+	Probe    func(ctx context.Context, exec Executor) (string, error)
+	Timeout  time.Duration
+	Classify func(output string, err error) (Severity, string)
+	Fix      *Remedy
+}
+
+// Result is the outcome of one diagnostic
+type Result struct {
+	Name     string
+	Severity Severity
+	Detail   string
+	Fix      *Remedy
+}
+
+// Diagnostics are the checks run by dgx doctor
+var Diagnostics = []Diagnostic{
+	{
+		Name:     "NVIDIA driver",
+		Command:  "nvidia-smi --query-gpu=driver_version --format=csv,noheader",
+		Classify: required(SeverityCritical),
+	},
+	{
+		Name:     "GPU persistence mode",
+		Command:  "nvidia-smi --query-gpu=persistence_mode --format=csv,noheader",
+		Classify: classifyPersistence,
+		Fix:      &Remedy{Description: "enable persistence mode", Command: "sudo nvidia-smi -pm 1"},
+	},
+	{
+		Name:     "Docker daemon",
+		Command:  "systemctl is-active docker",
+		Classify: required(SeverityCritical),
+		Fix:      &Remedy{Description: "enable and start Docker", Command: "sudo systemctl enable --now docker"},
+	},
+	{
+		Name:     "Docker group",
+		Command:  "id -un; id -nG",
+		Classify: classifyDockerGroup,
+		Fix:      &Remedy{Description: "add the user to the docker group (log in again to apply)", Command: `sudo usermod -aG docker "$(whoami)"`},
+	},
+	{
+		Name:     "NVIDIA container runtime",
+		Command:  "docker info --format '{{json .Runtimes}}'",
+		Classify: classifyRuntime,
+		Fix: &Remedy{
+			Description: "register the NVIDIA runtime with Docker",
+			Command:     "sudo nvidia-ctk runtime configure --runtime=docker && sudo systemctl restart docker",
+		},
+	},
+	{
+		Name:     "Root disk usage",
+		Command:  "df -P / | awk 'NR==2 {print $5}'",
+		Classify: classifyDisk,
+	},
+	{
+		Name:     "Clock sync",
+		Command:  "timedatectl show -p NTPSynchronized --value",
+		Classify: classifyClock,
+		Fix:      &Remedy{Description: "enable NTP time sync", Command: "sudo timedatectl set-ntp true"},
+	},
+	{
+		Name:     "Clock skew",
+		Probe:    probeSkew,
+		Classify: classifySkew,
+		Fix: &Remedy{
+			Description: "step the clock from NTP now ('dgx run time sync' configures NTP properly)",
+			Command:     "sudo timedatectl set-ntp true && { sudo chronyc makestep 2>/dev/null || sudo systemctl restart systemd-timesyncd; }",
+		},
+	},
+	{
+		Name:     "Shell locale",
+		Command:  `printf '%s\n' "$` + ssh.ShellLocaleVar + `"; locale 2>&1 >/dev/null`,
+		Classify: classifyLocale,
+	},
+	{
+		Name:     "Failed systemd units",
+		Command:  "systemctl --failed --no-legend --plain | awk '{print $1}'",
+		Classify: classifyFailedUnits,
+	},
+	{
+		Name:     "Pending reboot",
+		Command:  "if [ -f /var/run/reboot-required ]; then echo yes; else echo no; fi",
+		Classify: classifyReboot,
+	},
+	{
+		Name:     "Docker Model Runner",
+		Command:  "docker model status",
+		Classify: required(SeverityInfo),
+	},
+}
+
+// Diagnose runs the diagnostics concurrently, each bounded by its timeout, and returns the
+// results in the order given
+func Diagnose(ctx context.Context, exec Executor, diagnostics []Diagnostic) []Result {
+	results := make([]Result, len(diagnostics))
+	var wg sync.WaitGroup
+	for i, d := range diagnostics {
+		wg.Add(1)
+		go func(i int, d Diagnostic) {
+			defer wg.Done()
+			results[i] = diagnose(ctx, exec, d)
+		}(i, d)
+	}
+	wg.Wait()
+	return results
+}
+
+func diagnose(ctx context.Context, exec Executor, d Diagnostic) Result {
+	timeout := d.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var output string
+	var err error
+	if d.Probe != nil {
+		output, err = d.Probe(ctx, exec)
+	} else {
+		output, err = exec.ExecuteContext(ctx, d.Command)
+	}
+	severity, detail := d.Classify(output, err)
+	if errors.Is(err, context.DeadlineExceeded) {
+		detail = fmt.Sprintf("timed out after %v", timeout)
+	}
+	result := Result{Name: d.Name, Severity: severity, Detail: detail}
+	if severity >= SeverityWarn {
+		result.Fix = d.Fix
+	}
+	return result
+}
+
+// FormatResults renders results as an aligned list, with a hint under each fixable problem
+func FormatResults(results []Result) string {
+	var sb strings.Builder
+	for _, r := range results {
+		status := "OK  "
+		switch r.Severity {
+		case SeverityInfo:
+			status = "INFO"
+		case SeverityWarn:
+			status = "WARN"
+		case SeverityCritical:
+			status = "CRIT"
+		}
+		sb.WriteString(fmt.Sprintf("  [%s] %-24s %s\n", status, r.Name, r.Detail))
+		if r.Fix != nil {
+			sb.WriteString(fmt.Sprintf("         fix: %s (%s)\n", r.Fix.Description, r.Fix.CommandLine()))
+		}
+	}
+	return sb.String()
+}
+
+// Worst returns the highest severity among results
+func Worst(results []Result) Severity {
+	worst := SeverityOK
+	for _, r := range results {
+		if r.Severity > worst {
+			worst = r.Severity
+		}
+	}
+	return worst
+}
+
+// required classifies a command that must succeed, reporting failures at severity
+func required(severity Severity) func(string, error) (Severity, string) {
+	return func(output string, err error) (Severity, string) {
+		if err != nil {
+			if line := firstLine(output); line != "" {
+				return severity, line
+			}
+			return severity, err.Error()
+		}
+		return SeverityOK, firstLine(output)
+	}
+}
+
+func classifyPersistence(output string, err error) (Severity, string) {
+	if err != nil {
+		return SeverityInfo, "unavailable"
+	}
+	switch mode := firstLine(output); mode {
+	case "Enabled":
+		return SeverityOK, "enabled"
+	case "Disabled":
+		return SeverityWarn, "disabled; the first CUDA call after idle pays the driver init cost"
+	default:
+		return SeverityInfo, fmt.Sprintf("not supported (%s)", mode)
+	}
+}
+
+func classifyDockerGroup(output string, err error) (Severity, string) {
+	if err != nil {
+		return SeverityWarn, err.Error()
+	}
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if strings.TrimSpace(lines[0]) == "root" {
+		return SeverityOK, "running as root"
+	}
+	if len(lines) > 1 {
+		for _, group := range strings.Fields(lines[1]) {
+			if group == "docker" {
+				return SeverityOK, "member"
+			}
+		}
+	}
+	return SeverityWarn, "not a member; docker commands need sudo"
+}
+
+func classifyRuntime(output string, err error) (Severity, string) {
+	if err != nil {
+		return SeverityWarn, "docker info failed"
+	}
+	if strings.Contains(output, `"nvidia"`) {
+		return SeverityOK, "registered"
+	}
+	return SeverityWarn, "not registered; containers cannot use the GPU"
+}
+
+func classifyDisk(output string, err error) (Severity, string) {
+	if err != nil {
+		return SeverityWarn, err.Error()
+	}
+	used, convErr := strconv.Atoi(strings.TrimSuffix(firstLine(output), "%"))
+	if convErr != nil {
+		return SeverityWarn, fmt.Sprintf("unexpected df output %q", firstLine(output))
+	}
+	detail := fmt.Sprintf("%d%% used", used)
+	switch {
+	case used >= 95:
+		return SeverityCritical, detail
+	case used >= 85:
+		return SeverityWarn, detail
+	default:
+		return SeverityOK, detail
+	}
+}
+
+func classifyClock(output string, err error) (Severity, string) {
+	if err != nil {
+		return SeverityInfo, "timedatectl unavailable"
+	}
+	if firstLine(output) == "yes" {
+		return SeverityOK, "synchronized"
+	}
+	return SeverityWarn, "not synchronized; TLS and package downloads may fail (dgx run time sync)"
+}
+
+// classifyLocale reports the locale the remote shell sets, which the output dgx parses no
+// longer depends on but interactive and streamed commands still use
+func classifyLocale(output string, err error) (Severity, string) {
+	if err != nil {
+		return SeverityInfo, "locale unavailable"
+	}
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) > 1 {
+		return SeverityWarn, fmt.Sprintf("%s is unavailable (%s); parsed output may include locale warnings", ssh.ParseLocale, strings.TrimSpace(lines[1]))
+	}
+	shell := strings.TrimSpace(lines[0])
+	switch {
+	case shell == "":
+		return SeverityOK, "not set"
+	case shell == "C", shell == "POSIX", strings.HasPrefix(shell, "C."), strings.HasPrefix(shell, "en_"):
+		return SeverityOK, shell
+	}
+	return SeverityWarn, fmt.Sprintf("the shell sets %s; dgx parses output under %s, but interactive and streamed commands are localized", shell, ssh.ParseLocale)
+}
+
+func classifyFailedUnits(output string, err error) (Severity, string) {
+	if err != nil {
+		return SeverityInfo, "systemctl unavailable"
+	}
+	units := strings.Fields(output)
+	if len(units) == 0 {
+		return SeverityOK, "none"
+	}
+	return SeverityWarn, strings.Join(units, ", ")
+}
+
+func classifyReboot(output string, err error) (Severity, string) {
+	if err == nil && firstLine(output) == "yes" {
+		return SeverityInfo, "updates are waiting for a reboot (dgx reboot --wait)"
+	}
+	return SeverityOK, "no"
+}
@@ -0,0 +1,101 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type fakeExecutor map[string]string
+
+func (f fakeExecutor) ExecuteContext(ctx context.Context, command string) (string, error) {
+	output, ok := f[command]
+	switch {
+	case output == "hang":
+		<-ctx.Done()
+		return "", ctx.Err()
+	case !ok:
+		return "not found", errors.New("command failed")
+	}
+	return output, nil
+}
+
+func TestDiagnose(t *testing.T) {
+	diagnostics := []Diagnostic{
+		{Name: "driver", Command: "driver", Classify: required(SeverityCritical)},
+		{Name: "disk", Command: "disk", Classify: classifyDisk},
+		{Name: "group", Command: "group", Classify: classifyDockerGroup, Fix: &Remedy{Description: "add"}},
+		{Name: "slow", Command: "slow", Timeout: 10 * time.Millisecond, Classify: required(SeverityWarn)},
+		{Name: "dmr", Command: "dmr", Classify: required(SeverityInfo), Fix: &Remedy{Description: "unused"}},
+	}
+	exec := fakeExecutor{
+		"driver": "580.95.05\n",
+		"disk":   "91%\n",
+		"group":  "alice\nalice sudo\n",
+		"slow":   "hang",
+	}
+
+	results := Diagnose(context.Background(), exec, diagnostics)
+	want := []struct {
+		severity Severity
+		detail   string
+		fix      bool
+	}{
+		{SeverityOK, "580.95.05", false},
+		{SeverityWarn, "91% used", false},
+		{SeverityWarn, "not a member; docker commands need sudo", true},
+		{SeverityWarn, "timed out after 10ms", false},
+		{SeverityInfo, "not found", false},
+	}
+	for i, w := range want {
+		r := results[i]
+		if r.Name != diagnostics[i].Name || r.Severity != w.severity || r.Detail != w.detail || (r.Fix != nil) != w.fix {
+			t.Fatalf("result %d = %+v, want %+v", i, r, w)
+		}
+	}
+	if Worst(results) != SeverityWarn {
+		t.Fatalf("Worst() = %v, want warn", Worst(results))
+	}
+}
+
+func TestClassifyDisk(t *testing.T) {
+	cases := map[string]Severity{"12%": SeverityOK, "85%": SeverityWarn, "97%": SeverityCritical, "garbage": SeverityWarn}
+	for output, want := range cases {
+		if got, _ := classifyDisk(output, nil); got != want {
+			t.Fatalf("classifyDisk(%q) = %v, want %v", output, got, want)
+		}
+	}
+}
+
+func TestClassifyLocale(t *testing.T) {
+	cases := map[string]Severity{
+		"\n":            SeverityOK,
+		"en_US.UTF-8\n": SeverityOK,
+		"C.UTF-8\n":     SeverityOK,
+		"de_DE.UTF-8\n": SeverityWarn,
+		"\nlocale: Cannot set LC_ALL to default locale: No such file or directory\n": SeverityWarn,
+	}
+	for output, want := range cases {
+		if got, _ := classifyLocale(output, nil); got != want {
+			t.Fatalf("classifyLocale(%q) = %v, want %v", output, got, want)
+		}
+	}
+}
+
+func TestClockSkew(t *testing.T) {
+	ahead := time.Now().Add(90 * time.Second)
+	exec := fakeExecutor{"date +%s.%N": fmt.Sprintf("%d.%09d\n", ahead.Unix(), ahead.Nanosecond())}
+	skew, err := ClockSkew(context.Background(), exec)
+	if err != nil || skew < 89*time.Second || skew > 91*time.Second {
+		t.Fatalf("ClockSkew() = %v, %v; want about 90s", skew, err)
+	}
+
+	cases := map[string]Severity{"0.150": SeverityOK, "-3.5": SeverityWarn, "90.000": SeverityCritical, "garbage": SeverityInfo}
+	for output, want := range cases {
+		if got, _ := classifySkew(output, nil); got != want {
+			t.Fatalf("classifySkew(%q) = %v, want %v", output, got, want)
+		}
+	}
+}
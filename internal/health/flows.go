@@ -0,0 +1,355 @@
+package health
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Flow is a guided troubleshooting tree for a symptom, walked by dgx fix: its steps run in
+// order, and a problem that later steps depend on ends the walk until it is fixed.
+type Flow struct {
+	Symptom string
+	Aliases []string
+	Summary string
+	Steps   []FlowStep
+}
+
+// FlowStep is one diagnostic in a flow. Why explains how a problem it finds causes the
+// symptom; Blocking marks problems that make the remaining steps meaningless.
+type FlowStep struct {
+	Diagnostic
+	Why      string
+	Blocking bool
+}
+
+// Flows are the symptoms dgx fix knows how to troubleshoot
+var Flows = []Flow{
+	{
+		Symptom: "oom",
+		Aliases: []string{"out-of-memory", "memory"},
+		Summary: "a model fails to load or is killed for lack of memory",
+		Steps: []FlowStep{
+			{
+				Diagnostic: Diagnostic{Name: "Available memory", Command: "free -b | awk '/^Mem:/ {print $2, $7}'", Classify: classifyAvailableMemory},
+				Why:        "CPU and GPU share one pool of memory; whatever other processes hold is not there for the model (see dgx ps)",
+			},
+			{
+				Diagnostic: Diagnostic{Name: "Model servers", Command: "docker ps --format '{{.Names}} {{.Image}}'", Classify: classifyModelServers},
+				Why:        "each server reserves memory up front; plan the shares with 'dgx gpu reserve' or stop the ones not in use",
+			},
+			{
+				Diagnostic: Diagnostic{
+					Name:     "Swap",
+					Command:  "swapon --noheadings --show=SIZE --bytes | awk '{s += $1} END {print s + 0}'",
+					Classify: classifySwap,
+					Fix:      &Remedy{Description: "add a 32G swap file", Playbook: []string{"memory", "configure", "--swap", "32G"}},
+				},
+				Why: "without swap, a load that runs past the limit is killed instead of paging cold memory out",
+			},
+			{
+				Diagnostic: Diagnostic{
+					Name:     "OOM kills",
+					Command:  "journalctl -k -q --no-pager --since -24h 2>/dev/null | grep -ci 'out of memory' || true",
+					Classify: classifyOOMKills,
+				},
+				Why: "a smaller quantization or context frees the most memory ('dgx models fit <model>' shows what fits)",
+			},
+		},
+	},
+	{
+		Symptom: "docker-permission",
+		Aliases: []string{"docker", "permission-denied"},
+		Summary: "docker commands fail with permission denied on the socket",
+		Steps: []FlowStep{
+			{Diagnostic: diagnostic("Docker daemon"), Why: "nothing answers on the socket while the daemon is down", Blocking: true},
+			{Diagnostic: diagnostic("Docker group"), Why: "the socket is only open to root and the docker group; the change applies to new logins"},
+			{
+				Diagnostic: Diagnostic{
+					Name:     "Docker socket",
+					Command:  "stat -c '%G %a' /var/run/docker.sock",
+					Classify: classifyDockerSocket,
+					Fix: &Remedy{
+						Description: "give the docker group access to the socket",
+						Command:     "sudo chown root:docker /var/run/docker.sock && sudo chmod 660 /var/run/docker.sock",
+					},
+				},
+				Why: "a socket re-created by hand or by another tool keeps the wrong owner until Docker restarts",
+			},
+			{
+				Diagnostic: Diagnostic{Name: "Docker without sudo", Command: "docker info --format '{{.ServerVersion}}'", Classify: required(SeverityCritical)},
+				Why:        "if the checks above pass, reconnect so the session picks up the docker group",
+			},
+		},
+	},
+	{
+		Symptom: "cuda-container",
+		Aliases: []string{"gpu-container", "cuda"},
+		Summary: "CUDA or the GPU is not visible inside a container",
+		Steps: []FlowStep{
+			{Diagnostic: diagnostic("NVIDIA driver"), Why: "containers use the host driver; without it there is no GPU to pass through ('dgx run nvidia status')", Blocking: true},
+			{Diagnostic: diagnostic("Docker daemon"), Blocking: true},
+			{
+				Diagnostic: Diagnostic{
+					Name:     "NVIDIA Container Toolkit",
+					Command:  "nvidia-ctk --version",
+					Classify: required(SeverityCritical),
+					Fix:      &Remedy{Description: "install the NVIDIA Container Toolkit", Command: "sudo apt-get update && sudo apt-get install -y nvidia-container-toolkit"},
+				},
+				Why:      "the toolkit mounts the driver libraries and devices into containers",
+				Blocking: true,
+			},
+			{Diagnostic: diagnostic("NVIDIA container runtime"), Why: "--gpus only works once Docker knows the nvidia runtime"},
+			{
+				Diagnostic: Diagnostic{
+					Name:     "GPU in a container",
+					Command:  "docker run --rm --gpus all ubuntu:24.04 nvidia-smi -L",
+					Timeout:  3 * time.Minute,
+					Classify: required(SeverityCritical),
+				},
+				Why: "if this works, start the failing container with --gpus all (or --runtime nvidia)",
+			},
+		},
+	},
+	{
+		Symptom: "slow-tokens",
+		Aliases: []string{"slow", "tokens"},
+		Summary: "generation is slower in tokens/sec than it should be",
+		Steps: []FlowStep{
+			{
+				Diagnostic: Diagnostic{
+					Name:     "CPU fallback",
+					Command:  "if command -v ollama >/dev/null 2>&1; then ollama ps; fi",
+					Classify: classifyCPUFallback,
+				},
+				Why: "layers on the CPU run an order of magnitude slower; pick a smaller quantization ('dgx models fit <model>')",
+			},
+			{
+				Diagnostic: Diagnostic{
+					Name:     "GPU throttling",
+					Command:  "nvidia-smi --query-gpu=clocks_throttle_reasons.active --format=csv,noheader",
+					Classify: classifyThrottle,
+				},
+				Why: "check the airflow around the Spark and the power supply ('dgx power' shows the draw)",
+			},
+			{
+				Diagnostic: Diagnostic{Name: "Paging", Command: "vmstat 1 2 | tail -n 1 | awk '{print $7, $8}'", Classify: classifyPaging},
+				Why:        "weights paged out to swap are read back on every token; free memory or use a smaller model",
+			},
+			{Diagnostic: diagnostic("GPU persistence mode"), Why: "the first request after idle waits for the driver to initialize"},
+			{
+				Diagnostic: Diagnostic{
+					Name:     "Transparent hugepages",
+					Command:  "cat /sys/kernel/mm/transparent_hugepage/enabled",
+					Classify: classifyHugepages,
+					Fix:      &Remedy{Description: "apply the recommended kernel tuning", Playbook: []string{"tune", "apply"}},
+				},
+				Why: "PyTorch and vLLM allocators ask for huge pages to cut TLB misses",
+			},
+		},
+	},
+}
+
+// FindFlow returns the flow for a symptom or one of its aliases
+func FindFlow(symptom string) (*Flow, error) {
+	symptom = strings.ToLower(symptom)
+	names := make([]string, len(Flows))
+	for i := range Flows {
+		f := &Flows[i]
+		names[i] = f.Symptom
+		if f.Symptom == symptom {
+			return f, nil
+		}
+		for _, alias := range f.Aliases {
+			if alias == symptom {
+				return f, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("unknown symptom %q (known: %s)", symptom, strings.Join(names, ", "))
+}
+
+// diagnostic returns the doctor diagnostic of the given name, so flows share its checks
+// and fixes
+func diagnostic(name string) Diagnostic {
+	for _, d := range Diagnostics {
+		if d.Name == name {
+			return d
+		}
+	}
+	panic("health: no diagnostic named " + name)
+}
+
+func classifyAvailableMemory(output string, err error) (Severity, string) {
+	fields := strings.Fields(output)
+	if err != nil || len(fields) != 2 {
+		return SeverityInfo, "free unavailable"
+	}
+	total, _ := strconv.ParseFloat(fields[0], 64)
+	available, _ := strconv.ParseFloat(fields[1], 64)
+	if total <= 0 {
+		return SeverityInfo, fmt.Sprintf("unexpected free output %q", strings.TrimSpace(output))
+	}
+	detail := fmt.Sprintf("%.1f GiB of %.1f GiB available", available/(1<<30), total/(1<<30))
+	switch share := available / total; {
+	case share < 0.05:
+		return SeverityCritical, detail
+	case share < 0.15:
+		return SeverityWarn, detail
+	default:
+		return SeverityOK, detail
+	}
+}
+
+// modelServerImages are image name fragments of inference servers
+var modelServerImages = []string{"vllm", "ollama", "tensorrt", "triton", "nim", "sglang", "llama.cpp", "llamacpp"}
+
+func classifyModelServers(output string, err error) (Severity, string) {
+	if err != nil {
+		return SeverityInfo, "docker ps failed"
+	}
+	var servers []string
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		name, image, _ := strings.Cut(line, " ")
+		for _, fragment := range modelServerImages {
+			if strings.Contains(strings.ToLower(image), fragment) {
+				servers = append(servers, name)
+				break
+			}
+		}
+	}
+	switch len(servers) {
+	case 0:
+		return SeverityOK, "none running"
+	case 1:
+		return SeverityOK, servers[0]
+	default:
+		return SeverityWarn, fmt.Sprintf("%d share the memory: %s", len(servers), strings.Join(servers, ", "))
+	}
+}
+
+func classifySwap(output string, err error) (Severity, string) {
+	if err != nil {
+		return SeverityInfo, "swapon unavailable"
+	}
+	size, convErr := strconv.ParseInt(firstLine(output), 10, 64)
+	if convErr != nil {
+		return SeverityInfo, fmt.Sprintf("unexpected swapon output %q", firstLine(output))
+	}
+	if size == 0 {
+		return SeverityWarn, "none"
+	}
+	return SeverityOK, fmt.Sprintf("%.1f GiB", float64(size)/(1<<30))
+}
+
+func classifyOOMKills(output string, err error) (Severity, string) {
+	count, convErr := strconv.Atoi(firstLine(output))
+	if err != nil || convErr != nil {
+		return SeverityInfo, "kernel log unavailable"
+	}
+	if count == 0 {
+		return SeverityOK, "none in the last 24h"
+	}
+	return SeverityWarn, fmt.Sprintf("%d in the last 24h", count)
+}
+
+func classifyDockerSocket(output string, err error) (Severity, string) {
+	fields := strings.Fields(output)
+	if err != nil || len(fields) != 2 {
+		return SeverityWarn, "/var/run/docker.sock is missing"
+	}
+	group, mode := fields[0], fields[1]
+	if group != "docker" || len(mode) < 2 || mode[len(mode)-2] < '6' {
+		return SeverityWarn, fmt.Sprintf("group %s, mode %s; the docker group cannot use it", group, mode)
+	}
+	return SeverityOK, fmt.Sprintf("group %s, mode %s", group, mode)
+}
+
+func classifyCPUFallback(output string, err error) (Severity, string) {
+	if err != nil {
+		return SeverityInfo, "ollama ps failed"
+	}
+	var cpu, split []string
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch {
+		case strings.Contains(line, "CPU/GPU"):
+			split = append(split, fields[0])
+		case strings.Contains(line, "100% CPU"):
+			cpu = append(cpu, fields[0])
+		}
+	}
+	switch {
+	case len(cpu) > 0:
+		return SeverityWarn, "on the CPU: " + strings.Join(cpu, ", ")
+	case len(split) > 0:
+		return SeverityWarn, "partly on the CPU: " + strings.Join(split, ", ")
+	default:
+		return SeverityOK, "models run on the GPU"
+	}
+}
+
+// throttleReasons are the clocks_throttle_reasons bits that slow a busy GPU down. Idle,
+// application clock settings, and sync boost are left out: they are not faults.
+var throttleReasons = []struct {
+	bit  uint64
+	name string
+}{
+	{0x4, "power cap"},
+	{0x8, "hardware slowdown"},
+	{0x20, "thermal (software)"},
+	{0x40, "thermal (hardware)"},
+	{0x80, "power brake"},
+}
+
+func classifyThrottle(output string, err error) (Severity, string) {
+	if err != nil {
+		return SeverityInfo, "nvidia-smi failed"
+	}
+	mask, convErr := strconv.ParseUint(strings.TrimPrefix(firstLine(output), "0x"), 16, 64)
+	if convErr != nil {
+		return SeverityInfo, fmt.Sprintf("not supported (%s)", firstLine(output))
+	}
+	var reasons []string
+	for _, r := range throttleReasons {
+		if mask&r.bit != 0 {
+			reasons = append(reasons, r.name)
+		}
+	}
+	if len(reasons) == 0 {
+		return SeverityOK, "none"
+	}
+	return SeverityWarn, strings.Join(reasons, ", ")
+}
+
+func classifyPaging(output string, err error) (Severity, string) {
+	fields := strings.Fields(output)
+	if err != nil || len(fields) != 2 {
+		return SeverityInfo, "vmstat unavailable"
+	}
+	in, _ := strconv.Atoi(fields[0])
+	out, _ := strconv.Atoi(fields[1])
+	if in == 0 && out == 0 {
+		return SeverityOK, "none"
+	}
+	return SeverityWarn, fmt.Sprintf("swapping %d KB/s in, %d KB/s out", in, out)
+}
+
+func classifyHugepages(output string, err error) (Severity, string) {
+	line := firstLine(output)
+	start, end := strings.IndexByte(line, '['), strings.IndexByte(line, ']')
+	if err != nil || start < 0 || end < start {
+		return SeverityInfo, "unavailable"
+	}
+	switch mode := line[start+1 : end]; mode {
+	case "never":
+		return SeverityWarn, "disabled"
+	default:
+		return SeverityOK, mode
+	}
+}
@@ -0,0 +1,65 @@
+package health
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFindFlow(t *testing.T) {
+	for _, name := range []string{"oom", "OOM", "docker", "cuda", "slow-tokens"} {
+		if _, err := FindFlow(name); err != nil {
+			t.Fatalf("FindFlow(%q) failed: %v", name, err)
+		}
+	}
+	if _, err := FindFlow("flaky-wifi"); err == nil {
+		t.Fatalf("FindFlow(flaky-wifi) succeeded, want an error")
+	}
+	for _, f := range Flows {
+		for _, s := range f.Steps {
+			if s.Name == "" || s.Classify == nil {
+				t.Fatalf("flow %s has an incomplete step %+v", f.Symptom, s)
+			}
+		}
+	}
+}
+
+func TestFlowClassifiers(t *testing.T) {
+	cases := []struct {
+		name     string
+		classify func(string, error) (Severity, string)
+		output   string
+		severity Severity
+		detail   string
+	}{
+		{"memory", classifyAvailableMemory, "128000000000 6400000000\n", SeverityWarn, "6.0 GiB of 119.2 GiB available"},
+		{"servers", classifyModelServers, "vllm-server vllm/vllm-openai:latest\nollama ollama/ollama\ngrafana grafana/grafana\n", SeverityWarn, "2 share the memory: vllm-server, ollama"},
+		{"swap", classifySwap, "0\n", SeverityWarn, "none"},
+		{"socket", classifyDockerSocket, "root 600\n", SeverityWarn, "group root, mode 600; the docker group cannot use it"},
+		{"socket ok", classifyDockerSocket, "docker 660\n", SeverityOK, "group docker, mode 660"},
+		{"cpu", classifyCPUFallback, "NAME ID SIZE PROCESSOR UNTIL\nllama3:70b abc 48 GB 38%/62% CPU/GPU 4 minutes from now\n", SeverityWarn, "partly on the CPU: llama3:70b"},
+		{"throttle", classifyThrottle, "0x0000000000000024\n", SeverityWarn, "power cap, thermal (software)"},
+		{"throttle idle", classifyThrottle, "0x0000000000000001\n", SeverityOK, "none"},
+		{"paging", classifyPaging, "512 0\n", SeverityWarn, "swapping 512 KB/s in, 0 KB/s out"},
+		{"hugepages", classifyHugepages, "always madvise [never]\n", SeverityWarn, "disabled"},
+	}
+	for _, c := range cases {
+		severity, detail := c.classify(c.output, nil)
+		if severity != c.severity || detail != c.detail {
+			t.Fatalf("%s: got (%v, %q), want (%v, %q)", c.name, severity, detail, c.severity, c.detail)
+		}
+	}
+}
+
+func TestFlowPlaybookFix(t *testing.T) {
+	flow, _ := FindFlow("oom")
+	var swap Diagnostic
+	for _, s := range flow.Steps {
+		if s.Name == "Swap" {
+			swap = s.Diagnostic
+		}
+	}
+	r := Diagnose(context.Background(), fakeExecutor{swap.Command: "0\n"}, []Diagnostic{swap})[0]
+	if r.Fix == nil || r.Fix.CommandLine() != "dgx run memory configure --swap 32G" {
+		t.Fatalf("swap fix = %+v, want the memory playbook", r.Fix)
+	}
+}
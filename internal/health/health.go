@@ -0,0 +1,73 @@
+package health
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+// Check is the result of a single remote health probe
+type Check struct {
+	Name   string
+	OK     bool
+	Detail string
+}
+
+// Probe describes a remote health probe
+type Probe struct {
+	Name    string
+	Command string
+}
+
+// PostBootProbes are the checks run after the DGX comes back from a reboot
+var PostBootProbes = []Probe{
+	{Name: "NVIDIA driver", Command: "nvidia-smi --query-gpu=driver_version --format=csv,noheader"},
+	{Name: "Docker daemon", Command: "systemctl is-active docker"},
+	{Name: "Docker Model Runner", Command: "docker model status"},
+}
+
+// Run executes the given probes sequentially on the remote host
+func Run(client *ssh.Client, probes []Probe) []Check {
+	checks := make([]Check, 0, len(probes))
+	for _, probe := range probes {
+		output, err := client.Execute(probe.Command)
+		checks = append(checks, Check{
+			Name:   probe.Name,
+			OK:     err == nil,
+			Detail: firstLine(output),
+		})
+	}
+	return checks
+}
+
+// FormatChecks renders checks as an aligned pass/fail list
+func FormatChecks(checks []Check) string {
+	var sb strings.Builder
+	for _, c := range checks {
+		status := "OK  "
+		if !c.OK {
+			status = "FAIL"
+		}
+		sb.WriteString(fmt.Sprintf("  [%s] %-22s %s\n", status, c.Name, c.Detail))
+	}
+	return sb.String()
+}
+
+// AllOK reports whether every check passed
+func AllOK(checks []Check) bool {
+	for _, c := range checks {
+		if !c.OK {
+			return false
+		}
+	}
+	return true
+}
+
+func firstLine(output string) string {
+	output = strings.TrimSpace(output)
+	if idx := strings.IndexByte(output, '\n'); idx >= 0 {
+		return strings.TrimSpace(output[:idx])
+	}
+	return output
+}
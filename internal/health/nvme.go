@@ -0,0 +1,207 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// NVMe wear thresholds on the drive's percentage-used estimate. The Spark's model store sees
+// far more writes than a desktop drive, so plan a replacement before the rated endurance.
+const (
+	WearWarn     = 80
+	WearCritical = 95
+)
+
+// NVMe composite temperature thresholds in degrees Celsius
+const (
+	TempWarn     = 70
+	TempCritical = 80
+)
+
+// SmartScript collects smartctl JSON for every NVMe controller into path, installing
+// smartmontools first when it is missing. It needs root, so callers run it with a terminal
+// when sudo asks for a password and read path afterwards.
+func SmartScript(path string) string {
+	return fmt.Sprintf(`set -e
+if ! command -v smartctl >/dev/null 2>&1; then
+  echo "Installing smartmontools..."
+  sudo apt-get update -qq
+  sudo env DEBIAN_FRONTEND=noninteractive apt-get install -y -qq smartmontools
+fi
+out=%s
+mkdir -p "$(dirname "$out")"
+: > "$out"
+for d in /dev/nvme[0-9] /dev/nvme[0-9][0-9]; do
+  [ -c "$d" ] || continue
+  sudo smartctl --json -a "$d" >> "$out" || true
+done`, path)
+}
+
+// Drive is the SMART health of one NVMe drive. Counters are lifetime totals.
+type Drive struct {
+	Device          string `json:"device"`
+	Model           string `json:"model"`
+	Serial          string `json:"serial"`
+	Firmware        string `json:"firmware"`
+	CapacityBytes   int64  `json:"capacity_bytes"`
+	Passed          bool   `json:"smart_passed"`
+	CriticalWarning int    `json:"critical_warning"`
+	PercentUsed     int    `json:"percent_used"`
+	AvailableSpare  int    `json:"available_spare"`
+	SpareThreshold  int    `json:"spare_threshold"`
+	TemperatureC    int    `json:"temperature_c"`
+	MediaErrors     int64  `json:"media_errors"`
+	ErrorLogEntries int64  `json:"error_log_entries"`
+	UnsafeShutdowns int64  `json:"unsafe_shutdowns"`
+	PowerOnHours    int64  `json:"power_on_hours"`
+	BytesRead       int64  `json:"bytes_read"`
+	BytesWritten    int64  `json:"bytes_written"`
+}
+
+// smartctlOutput is the part of smartctl --json -a this package reads
+type smartctlOutput struct {
+	Smartctl struct {
+		Messages []struct {
+			String   string `json:"string"`
+			Severity string `json:"severity"`
+		} `json:"messages"`
+	} `json:"smartctl"`
+	Device struct {
+		Name string `json:"name"`
+	} `json:"device"`
+	ModelName       string `json:"model_name"`
+	SerialNumber    string `json:"serial_number"`
+	FirmwareVersion string `json:"firmware_version"`
+	TotalCapacity   int64  `json:"nvme_total_capacity"`
+	UserCapacity    struct {
+		Bytes int64 `json:"bytes"`
+	} `json:"user_capacity"`
+	SmartStatus *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+	Log *struct {
+		CriticalWarning  int   `json:"critical_warning"`
+		Temperature      int   `json:"temperature"`
+		AvailableSpare   int   `json:"available_spare"`
+		SpareThreshold   int   `json:"available_spare_threshold"`
+		PercentageUsed   int   `json:"percentage_used"`
+		DataUnitsRead    int64 `json:"data_units_read"`
+		DataUnitsWritten int64 `json:"data_units_written"`
+		PowerOnHours     int64 `json:"power_on_hours"`
+		UnsafeShutdowns  int64 `json:"unsafe_shutdowns"`
+		MediaErrors      int64 `json:"media_errors"`
+		NumErrLogEntries int64 `json:"num_err_log_entries"`
+	} `json:"nvme_smart_health_information_log"`
+}
+
+// ParseSmartctl parses one or more concatenated smartctl --json -a documents. Devices that
+// smartctl could not read are reported in the returned error alongside the drives it could.
+func ParseSmartctl(r io.Reader) ([]Drive, error) {
+	var drives []Drive
+	var problems []string
+	dec := json.NewDecoder(r)
+	for {
+		var out smartctlOutput
+		if err := dec.Decode(&out); err == io.EOF {
+			break
+		} else if err != nil {
+			return drives, fmt.Errorf("unexpected smartctl output: %w", err)
+		}
+		if out.Log == nil {
+			msg := "no NVMe health log"
+			for _, m := range out.Smartctl.Messages {
+				if m.Severity == "error" {
+					msg = m.String
+					break
+				}
+			}
+			problems = append(problems, fmt.Sprintf("%s: %s", out.Device.Name, msg))
+			continue
+		}
+
+		d := Drive{
+			Device:          out.Device.Name,
+			Model:           out.ModelName,
+			Serial:          out.SerialNumber,
+			Firmware:        out.FirmwareVersion,
+			CapacityBytes:   out.TotalCapacity,
+			Passed:          out.SmartStatus == nil || out.SmartStatus.Passed,
+			CriticalWarning: out.Log.CriticalWarning,
+			PercentUsed:     out.Log.PercentageUsed,
+			AvailableSpare:  out.Log.AvailableSpare,
+			SpareThreshold:  out.Log.SpareThreshold,
+			TemperatureC:    out.Log.Temperature,
+			MediaErrors:     out.Log.MediaErrors,
+			ErrorLogEntries: out.Log.NumErrLogEntries,
+			UnsafeShutdowns: out.Log.UnsafeShutdowns,
+			PowerOnHours:    out.Log.PowerOnHours,
+			// NVMe data units are thousands of 512-byte blocks
+			BytesRead:    out.Log.DataUnitsRead * 512000,
+			BytesWritten: out.Log.DataUnitsWritten * 512000,
+		}
+		if d.CapacityBytes == 0 {
+			d.CapacityBytes = out.UserCapacity.Bytes
+		}
+		drives = append(drives, d)
+	}
+	if len(problems) > 0 {
+		return drives, errors.New(strings.Join(problems, "; "))
+	}
+	return drives, nil
+}
+
+// Assess classifies a drive, listing each finding at warn level or worse
+func (d Drive) Assess(wearWarn, wearCritical int) (Severity, []string) {
+	severity := SeverityOK
+	var findings []string
+	flag := func(s Severity, format string, args ...any) {
+		if s > severity {
+			severity = s
+		}
+		findings = append(findings, fmt.Sprintf(format, args...))
+	}
+
+	if !d.Passed {
+		flag(SeverityCritical, "SMART overall health check failed")
+	}
+	if d.CriticalWarning != 0 {
+		flag(SeverityCritical, "critical warning set (%s)", criticalWarnings(d.CriticalWarning))
+	}
+	switch {
+	case d.PercentUsed >= wearCritical:
+		flag(SeverityCritical, "%d%% of rated endurance used; replace the drive soon", d.PercentUsed)
+	case d.PercentUsed >= wearWarn:
+		flag(SeverityWarn, "%d%% of rated endurance used; plan a replacement", d.PercentUsed)
+	}
+	if d.SpareThreshold > 0 && d.AvailableSpare <= d.SpareThreshold {
+		flag(SeverityCritical, "available spare %d%% is at or below the %d%% threshold", d.AvailableSpare, d.SpareThreshold)
+	}
+	switch {
+	case d.TemperatureC >= TempCritical:
+		flag(SeverityCritical, "%d°C; the drive will throttle or shut down", d.TemperatureC)
+	case d.TemperatureC >= TempWarn:
+		flag(SeverityWarn, "%d°C; check airflow around the Spark", d.TemperatureC)
+	}
+	if d.MediaErrors > 0 {
+		flag(SeverityWarn, "%d media/data integrity errors; back up models and data", d.MediaErrors)
+	}
+	return severity, findings
+}
+
+// criticalWarnings names the bits of the NVMe critical warning field
+func criticalWarnings(bits int) string {
+	names := []string{"spare below threshold", "temperature", "reliability degraded", "read-only", "volatile backup failed", "persistent memory read-only"}
+	var set []string
+	for i, name := range names {
+		if bits&(1<<i) != 0 {
+			set = append(set, name)
+		}
+	}
+	if len(set) == 0 {
+		return fmt.Sprintf("0x%02x", bits)
+	}
+	return strings.Join(set, ", ")
+}
@@ -0,0 +1,49 @@
+package health
+
+import (
+	"strings"
+	"testing"
+)
+
+const smartctlSample = `{
+  "smartctl": {"exit_status": 0},
+  "device": {"name": "/dev/nvme0", "type": "nvme"},
+  "model_name": "ACME NVMe 4TB",
+  "serial_number": "S123",
+  "firmware_version": "1.2",
+  "nvme_total_capacity": 4000787030016,
+  "smart_status": {"passed": true},
+  "nvme_smart_health_information_log": {
+    "critical_warning": 0, "temperature": 72, "available_spare": 100, "available_spare_threshold": 10,
+    "percentage_used": 83, "data_units_read": 2000, "data_units_written": 1000,
+    "power_on_hours": 1234, "unsafe_shutdowns": 3, "media_errors": 0, "num_err_log_entries": 5
+  }
+}
+{
+  "smartctl": {"exit_status": 2, "messages": [{"string": "Permission denied", "severity": "error"}]},
+  "device": {"name": "/dev/nvme1"}
+}`
+
+func TestParseSmartctl(t *testing.T) {
+	drives, err := ParseSmartctl(strings.NewReader(smartctlSample))
+	if err == nil || !strings.Contains(err.Error(), "/dev/nvme1: Permission denied") {
+		t.Fatalf("unreadable drive not reported: %v", err)
+	}
+	if len(drives) != 1 {
+		t.Fatalf("got %d drives, want 1", len(drives))
+	}
+	d := drives[0]
+	if d.Device != "/dev/nvme0" || d.CapacityBytes != 4000787030016 || d.BytesWritten != 512000000 || d.PercentUsed != 83 || !d.Passed {
+		t.Fatalf("unexpected drive: %+v", d)
+	}
+
+	severity, findings := d.Assess(WearWarn, WearCritical)
+	if severity != SeverityWarn || len(findings) != 2 {
+		t.Fatalf("Assess = %v %q, want a wear and a temperature warning", severity, findings)
+	}
+
+	d.CriticalWarning = 0x08
+	if severity, findings := d.Assess(WearWarn, WearCritical); severity != SeverityCritical || !strings.Contains(findings[0], "read-only") {
+		t.Fatalf("Assess = %v %q, want critical read-only", severity, findings)
+	}
+}
@@ -0,0 +1,40 @@
+package history
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitCommandLine(t *testing.T) {
+	for _, tc := range []struct {
+		line string
+		want []string
+	}{
+		{"status", []string{"status"}},
+		{"  exec   nvidia-smi \n", []string{"exec", "nvidia-smi"}},
+		{`exec "ls -la" '/tmp/my dir'`, []string{"exec", "ls -la", "/tmp/my dir"}},
+		{`run dmr --model=q"wen 3"`, []string{"run", "dmr", "--model=qwen 3"}},
+		{`push ''`, []string{"push", ""}},
+		{"", nil},
+	} {
+		got, err := SplitCommandLine(tc.line)
+		if err != nil || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("SplitCommandLine(%q) = %q, %v; want %q", tc.line, got, err, tc.want)
+		}
+	}
+	if _, err := SplitCommandLine(`exec "unterminated`); err == nil {
+		t.Fatalf("expected an error for an unterminated quote")
+	}
+}
+
+func TestCommandLineRoundTrip(t *testing.T) {
+	args := []string{"exec", "--", "echo", "it's $HOME", "", "plain"}
+	line := CommandLine(args)
+	if want := `dgx exec -- echo 'it'"'"'s $HOME' '' plain`; line != want {
+		t.Fatalf("CommandLine = %q, want %q", line, want)
+	}
+	got, err := SplitCommandLine(line)
+	if err != nil || !reflect.DeepEqual(got, append([]string{"dgx"}, args...)) {
+		t.Fatalf("SplitCommandLine(CommandLine(args)) = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,287 @@
+// Package history records dgx invocations, their target host, and their outcome so they
+// can be listed and re-run.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultFile is the history log name inside the dgx config directory
+const DefaultFile = "history.jsonl"
+
+// EnvDisable turns recording off when set, e.g. for the per-host children of a fan-out,
+// whose parent invocation is already recorded
+const EnvDisable = "DGX_NO_HISTORY"
+
+// MaxEntries is how many invocations the log keeps; older ones are dropped
+const MaxEntries = 1000
+
+// maxEntries is MaxEntries, lowered by tests
+var maxEntries = MaxEntries
+
+// Entry is one invocation. The log holds a line when the command starts and another when
+// it exits, so a run that was killed still shows up, without an exit code.
+type Entry struct {
+	N        int           `json:"n"`
+	PID      int           `json:"pid"`
+	Time     time.Time     `json:"time"`
+	Args     []string      `json:"args,omitempty"`
+	Dir      string        `json:"dir,omitempty"`
+	Command  string        `json:"command,omitempty"`
+	Host     string        `json:"host,omitempty"`
+	Profile  string        `json:"profile,omitempty"`
+	Redacted bool          `json:"redacted,omitempty"`
+	Done     bool          `json:"done,omitempty"`
+	ExitCode int           `json:"exit_code,omitempty"`
+	Duration time.Duration `json:"duration_ns,omitempty"`
+}
+
+// exitLine is the line written when an invocation exits
+type exitLine struct {
+	N        int           `json:"n"`
+	PID      int           `json:"pid"`
+	Done     bool          `json:"done"`
+	ExitCode int           `json:"exit_code"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// Failed reports whether the invocation exited non-zero
+func (e Entry) Failed() bool {
+	return e.Done && e.ExitCode != 0
+}
+
+// Status describes the outcome: ok, exit N, or unfinished for a run that was killed or is
+// still going
+func (e Entry) Status() string {
+	switch {
+	case !e.Done:
+		return "unfinished"
+	case e.ExitCode == 0:
+		return "ok"
+	default:
+		return fmt.Sprintf("exit %d", e.ExitCode)
+	}
+}
+
+// Store is an append-only JSON-lines history log
+type Store struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewStore creates a store backed by the file at path
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// DefaultPath returns ~/.config/dgx/history.jsonl
+func DefaultPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".config", "dgx", DefaultFile), nil
+}
+
+// Path returns the log file
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Load returns the recorded invocations, oldest first, with each start line merged with
+// its exit line. A missing log yields no entries.
+func (s *Store) Load() ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+func (s *Store) load() ([]Entry, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	index := make(map[[2]int]int)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		var e Entry
+		// Skip lines truncated by a crash mid-write
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || e.N == 0 {
+			continue
+		}
+		key := [2]int{e.N, e.PID}
+		i, started := index[key]
+		if !started {
+			index[key] = len(entries)
+			entries = append(entries, e)
+			continue
+		}
+		if e.Done {
+			entries[i].Done, entries[i].ExitCode, entries[i].Duration = true, e.ExitCode, e.Duration
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	return entries, nil
+}
+
+// Find returns the entry numbered n
+func Find(entries []Entry, n int) (Entry, bool) {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].N == n {
+			return entries[i], true
+		}
+	}
+	return Entry{}, false
+}
+
+// LastFailed returns the most recent invocation that exited non-zero
+func LastFailed(entries []Entry) (Entry, bool) {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Failed() {
+			return entries[i], true
+		}
+	}
+	return Entry{}, false
+}
+
+// Invocation is the history entry of the running command. A nil *Invocation records
+// nothing.
+type Invocation struct {
+	store *Store
+	entry Entry
+	start time.Time
+	begun bool
+}
+
+// Begin starts the entry for an invocation with args. Nothing is written until Start or
+// Finish.
+func (s *Store) Begin(args []string, redacted bool) *Invocation {
+	dir, _ := os.Getwd()
+	now := time.Now()
+	return &Invocation{store: s, start: now, entry: Entry{
+		PID:      os.Getpid(),
+		Time:     now,
+		Args:     args,
+		Dir:      dir,
+		Redacted: redacted,
+	}}
+}
+
+// Start writes the entry once the command and its target are known
+func (inv *Invocation) Start(command, host, profile string) error {
+	if inv == nil || inv.begun {
+		return nil
+	}
+	inv.entry.Command, inv.entry.Host, inv.entry.Profile = command, host, profile
+	return inv.write()
+}
+
+// Finish records the exit code. An invocation that never reached Start, such as one
+// rejected for a bad flag, is written in full.
+func (inv *Invocation) Finish(code int) error {
+	if inv == nil {
+		return nil
+	}
+	if !inv.begun {
+		if err := inv.write(); err != nil {
+			return err
+		}
+	}
+	return inv.store.append(exitLine{
+		N:        inv.entry.N,
+		PID:      inv.entry.PID,
+		Done:     true,
+		ExitCode: code,
+		Duration: time.Since(inv.start).Round(time.Millisecond),
+	})
+}
+
+// N returns the entry's number, or 0 before it is written
+func (inv *Invocation) N() int {
+	if inv == nil {
+		return 0
+	}
+	return inv.entry.N
+}
+
+// write numbers the entry after the last one in the log and appends it
+func (inv *Invocation) write() error {
+	s := inv.store
+	s.mu.Lock()
+	entries, err := s.load()
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	inv.entry.N = 1
+	if len(entries) > 0 {
+		inv.entry.N = entries[len(entries)-1].N + 1
+	}
+	// Trim with some slack so the log is not rewritten on every invocation
+	if len(entries) > maxEntries+maxEntries/10 {
+		s.rewrite(entries[len(entries)-maxEntries:])
+	}
+	s.mu.Unlock()
+	inv.begun = true
+	return s.append(inv.entry)
+}
+
+// rewrite replaces the log with entries, keeping their numbers
+func (s *Store) rewrite(entries []Entry) error {
+	var b strings.Builder
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0600); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) append(v interface{}) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode history entry: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package history
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestInvocations(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), DefaultFile))
+
+	first := store.Begin([]string{"run", "dmr", "pull", "ai/smollm2"}, false)
+	if err := first.Start("dgx run", "spark.local", "home"); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	first.Finish(4)
+
+	// Rejected before the command ran: written in full at exit
+	second := store.Begin([]string{"exex"}, false)
+	second.Finish(6)
+
+	// Killed: no exit line
+	third := store.Begin([]string{"connect"}, false)
+	third.Start("dgx connect", "spark.local", "")
+
+	entries, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+	for i, e := range entries {
+		if e.N != i+1 {
+			t.Fatalf("entry %d numbered %d", i, e.N)
+		}
+	}
+	if e := entries[0]; e.Status() != "exit 4" || !e.Failed() || e.Host != "spark.local" || e.Profile != "home" || len(e.Args) != 4 {
+		t.Fatalf("first entry: %+v", e)
+	}
+	if e := entries[1]; e.Status() != "exit 6" || e.Command != "" {
+		t.Fatalf("second entry: %+v", e)
+	}
+	if e := entries[2]; e.Status() != "unfinished" || e.Failed() {
+		t.Fatalf("third entry: %+v", e)
+	}
+	if e, ok := LastFailed(entries); !ok || e.N != 2 {
+		t.Fatalf("LastFailed: %+v, %v", e, ok)
+	}
+	if e, ok := Find(entries, 1); !ok || e.Args[2] != "pull" {
+		t.Fatalf("Find(1): %+v, %v", e, ok)
+	}
+	if _, ok := Find(entries, 9); ok {
+		t.Fatalf("Find(9) found an entry")
+	}
+
+	var nilInv *Invocation
+	if err := nilInv.Start("dgx", "", ""); err != nil || nilInv.Finish(0) != nil || nilInv.N() != 0 {
+		t.Fatalf("nil invocation recorded something")
+	}
+}
+
+func TestTrim(t *testing.T) {
+	defer func(n int) { maxEntries = n }(maxEntries)
+	maxEntries = 20
+
+	store := NewStore(filepath.Join(t.TempDir(), DefaultFile))
+	var last int
+	for i := 0; i < 100; i++ {
+		inv := store.Begin([]string{"exec", "true"}, false)
+		inv.Start("dgx exec", "spark", "")
+		inv.Finish(0)
+		last = inv.N()
+	}
+	entries, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(entries) < 20 || len(entries) > 22 {
+		t.Fatalf("got %d entries after trimming, want 20-22", len(entries))
+	}
+	if e := entries[len(entries)-1]; e.N != last || last != 100 || !e.Done {
+		t.Fatalf("last entry %+v, want number 100 and done", e)
+	}
+}
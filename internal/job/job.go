@@ -0,0 +1,687 @@
+// Package job runs commands on the DGX as detached transient systemd user units, so they
+// outlive the SSH session, and reads back their state and output.
+package job
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+const (
+	unitPrefix = "dgx-job-"
+
+	// jobsDir holds one directory per job: job (its settings), command, run.sh, output.log,
+	// and status, which run.sh and Cancel append to
+	jobsDir = "$HOME/.local/share/dgx/jobs"
+	// queueDir holds a ticket for each queued job, named by submission time and job ID so
+	// that listing it gives the queue in order
+	queueDir = jobsDir + "/.queue"
+)
+
+// Job states
+const (
+	StateQueued    = "queued"
+	StateRunning   = "running"
+	StateSucceeded = "succeeded"
+	StateFailed    = "failed"
+	StateCanceled  = "canceled"
+	// StateKilled is a job stopped other than by 'dgx job cancel', such as by its timeout,
+	// or one that ended without recording how, such as when the DGX rebooted
+	StateKilled = "killed"
+)
+
+// GPU access modes
+const (
+	GPUShared    = "shared"
+	GPUExclusive = "exclusive"
+	GPUNone      = "none"
+)
+
+var (
+	namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,39}$`)
+	// memoryPattern matches systemd's MemoryMax= values: bytes with an optional K, M, G, or T
+	// suffix, or a percentage of the DGX's memory
+	memoryPattern = regexp.MustCompile(`^([0-9]+[KMGT]?|[0-9]+(\.[0-9]+)?%)$`)
+	nameChars     = regexp.MustCompile(`[^a-z0-9-]+`)
+	ioNicePattern = regexp.MustCompile(`^(idle|best-effort(:[0-7])?)$`)
+)
+
+// runScript runs the job's command from the directory the job was submitted in, logging
+// its output and recording when it started and how it ended. The deployment environment
+// file is sourced like other dgx commands do.
+//
+// A queued job is given its ticket as $1 and waits until the ticket is first in the
+// queue, and for an exclusive GPU job also until no process is using the GPU. Tickets of
+// jobs whose unit is gone, such as after a reboot, are dropped so they do not hold up the
+// queue; a job removes its own when it ends.
+//
+// While the command runs, the peak CPU use of the job's cgroup (in percent of one CPU),
+// GPU utilization, and GPU memory of the job's processes are sampled every 5s into peak.
+// When it ends, its CPU time and peak memory are added from the cgroup, and the webhook
+// in notify, if any, is sent the outcome.
+const runScript = `#!/bin/sh
+# Managed by dgx job submit
+d=$(dirname "$0")
+id=$(basename "$d")
+ticket=$1
+q="$(dirname "$d")/.queue"
+cg="/sys/fs/cgroup$(sed -n 's/^0:://p' /proc/self/cgroup)"
+sampler=
+
+sample() {
+  cpu=0 gpu=0 gmem=0
+  last=$(sed -n 's/^usage_usec //p' "$cg/cpu.stat" 2>/dev/null)
+  while sleep 5; do
+    now=$(sed -n 's/^usage_usec //p' "$cg/cpu.stat" 2>/dev/null)
+    c=$(((${now:-0} - ${last:-0}) / 50000))
+    last=$now
+    u=$(nvidia-smi --query-gpu=utilization.gpu --format=csv,noheader,nounits 2>/dev/null | awk '$1+0 == $1 && $1 > m { m = $1 } END { print m+0 }')
+    m=$(nvidia-smi --query-compute-apps=pid,used_memory --format=csv,noheader,nounits 2>/dev/null |
+      awk -F', *' -v procs=" $(tr '\n' ' ' 2>/dev/null <"$cg/cgroup.procs") " 'index(procs, " " $1 " ") && $2+0 == $2 { s += $2 } END { print s+0 }')
+    [ "$c" -gt "$cpu" ] && cpu=$c
+    [ "$u" -gt "$gpu" ] && gpu=$u
+    [ "$m" -gt "$gmem" ] && gmem=$m
+    printf 'PeakCPU=%d\nPeakGPU=%d\nPeakGPUMemory=%d\n' "$cpu" "$gpu" "$gmem" >"$d/peak"
+  done
+}
+
+# finish records how the job ended: $1 is its exit status, or TERM when it was stopped
+finish() {
+  if [ -n "$sampler" ]; then
+    kill "$sampler" 2>/dev/null
+    wait "$sampler" 2>/dev/null
+  fi
+  [ -n "$ticket" ] && rm -f "$q/$ticket"
+  if [ "$1" = TERM ]; then
+    echo "Signal=TERM" >>"$d/status"
+  else
+    echo "Exit=$1" >>"$d/status"
+  fi
+  echo "Finished=$(date +%s)" >>"$d/status"
+  echo "CPUTime=$(sed -n 's/^usage_usec //p' "$cg/cpu.stat" 2>/dev/null)" >>"$d/peak"
+  echo "PeakMemory=$(cat "$cg/memory.peak" 2>/dev/null)" >>"$d/peak"
+
+  if [ -s "$d/notify" ]; then
+    case "$1" in
+    0) state=succeeded code=0 ;;
+    TERM) state=killed code=null; grep -q '^Canceled=' "$d/status" && state=canceled ;;
+    *) state=failed code=$1 ;;
+    esac
+    host=$(hostname)
+    text="[$host] dgx job $id $state"
+    [ "$state" = failed ] && text="$text with exit code $1"
+    curl -fsS -m 30 -H 'Content-Type: application/json' -o /dev/null \
+      -d "{\"text\":\"$text\",\"job\":\"$id\",\"host\":\"$host\",\"state\":\"$state\",\"exit_code\":$code}" \
+      "$(cat "$d/notify")" 2>>"$d/output.log"
+  fi
+  [ "$1" = TERM ] && exit 143
+  exit "$1"
+}
+trap 'finish TERM' TERM INT
+
+if [ -n "$ticket" ]; then
+  while [ -e "$q/$ticket" ]; do
+    head=$(ls "$q" | head -n 1)
+    if [ "$head" != "$ticket" ]; then
+      systemctl --user is-active -q "dgx-job-${head#*-}.service" 2>/dev/null || rm -f "$q/$head"
+    elif [ "$DGX_JOB_GPU" != exclusive ] || [ -z "$(nvidia-smi --query-compute-apps=pid --format=csv,noheader 2>/dev/null)" ]; then
+      break
+    fi
+    sleep 5
+  done
+fi
+echo "Started=$(date +%s)" >>"$d/status"
+sample &
+sampler=$!
+[ -f "$HOME/.config/dgx/env.sh" ] && . "$HOME/.config/dgx/env.sh"
+/bin/bash "$d/command" >>"$d/output.log" 2>&1 </dev/null
+finish $?
+`
+
+// Spec describes a job to submit
+type Spec struct {
+	Name    string        // prefix of the job ID; taken from the command when empty
+	Command string        // shell script run with bash
+	Script  string        // local file Command was read from, if any; names the job
+	Dir     string        // working directory on the DGX; the home directory when empty
+	Memory  string        // MemoryMax= limit, such as 32G or 50%
+	Timeout time.Duration // stop the job after this long; zero for no limit
+
+	MemoryHigh string  // MemoryHigh= soft limit: above it the job is throttled and reclaimed
+	CPUs       float64 // CPUQuota= in CPUs, such as 4 or 2.5; zero for no limit
+	Nice       int     // CPU priority from 0 (the default) to 19 (the lowest)
+	IONice     string  // I/O priority: idle, or best-effort with an optional level 0-7
+	GPU        string  // GPU access: shared (the default), exclusive, or none
+	Queue      bool    // wait in the DGX's job queue; exclusive GPU jobs always do
+
+	Notify string // webhook the DGX posts the job's outcome to when it ends
+}
+
+// Validate checks the spec and fills in its name
+func (s *Spec) Validate() error {
+	if strings.TrimSpace(s.Command) == "" {
+		return fmt.Errorf("no command to run")
+	}
+	if s.Name == "" && s.Script != "" {
+		s.Name = programName(strings.TrimSuffix(path.Base(s.Script), path.Ext(s.Script)))
+	} else if s.Name == "" {
+		s.Name = defaultName(s.Command)
+	}
+	if !namePattern.MatchString(s.Name) {
+		return fmt.Errorf("invalid job name %q: use up to 40 lowercase letters, digits, and dashes", s.Name)
+	}
+	if s.Memory != "" && !memoryPattern.MatchString(s.Memory) {
+		return fmt.Errorf("invalid memory limit %q (want a size such as 32G or a percentage such as 50%%)", s.Memory)
+	}
+	if s.Timeout < 0 || (s.Timeout > 0 && s.Timeout < time.Second) {
+		return fmt.Errorf("invalid timeout %s", s.Timeout)
+	}
+	if s.MemoryHigh != "" && !memoryPattern.MatchString(s.MemoryHigh) {
+		return fmt.Errorf("invalid soft memory limit %q (want a size such as 24G or a percentage such as 40%%)", s.MemoryHigh)
+	}
+	if s.CPUs < 0 || (s.CPUs > 0 && s.CPUs < 0.01) {
+		return fmt.Errorf("invalid CPU limit %g", s.CPUs)
+	}
+	// Raising priority needs root, which user units do not have
+	if s.Nice < 0 || s.Nice > 19 {
+		return fmt.Errorf("invalid nice value %d: use 0 to 19, higher is lower priority", s.Nice)
+	}
+	if s.IONice != "" && !ioNicePattern.MatchString(s.IONice) {
+		return fmt.Errorf("invalid I/O priority %q (want idle, best-effort, or best-effort:0 to best-effort:7)", s.IONice)
+	}
+	switch s.GPU {
+	case "":
+		s.GPU = GPUShared
+	case GPUShared, GPUNone:
+	case GPUExclusive:
+		s.Queue = true
+	default:
+		return fmt.Errorf("invalid GPU mode %q (want shared, exclusive, or none)", s.GPU)
+	}
+	if s.Notify != "" {
+		if u, err := url.Parse(s.Notify); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.ContainsAny(s.Notify, "\n\"") {
+			return fmt.Errorf("invalid notification URL %q (want an http or https webhook)", s.Notify)
+		}
+	}
+	return nil
+}
+
+// properties returns the systemd-run options that apply the spec's limits and priorities.
+// Limits on CPU and memory need those cgroup controllers delegated to the user's systemd
+// instance, as recent systemd releases do by default.
+func (s Spec) properties() []string {
+	var props []string
+	if s.Memory != "" {
+		props = append(props, "--property=MemoryMax="+s.Memory)
+	}
+	if s.MemoryHigh != "" {
+		props = append(props, "--property=MemoryHigh="+s.MemoryHigh)
+	}
+	if s.CPUs > 0 {
+		props = append(props, fmt.Sprintf("--property=CPUQuota=%.0f%%", s.CPUs*100))
+	}
+	if s.Timeout > 0 {
+		props = append(props, fmt.Sprintf("--property=RuntimeMaxSec=%d", int(s.Timeout.Seconds())))
+	}
+	if s.Nice > 0 {
+		props = append(props, fmt.Sprintf("--nice=%d", s.Nice))
+	}
+	if s.IONice != "" {
+		class, level, ok := strings.Cut(s.IONice, ":")
+		props = append(props, "--property=IOSchedulingClass="+class)
+		if ok {
+			props = append(props, "--property=IOSchedulingPriority="+level)
+		}
+	}
+	switch s.GPU {
+	case GPUNone:
+		props = append(props, "--setenv=CUDA_VISIBLE_DEVICES=")
+	case GPUExclusive:
+		props = append(props, "--setenv=DGX_JOB_GPU=exclusive")
+	}
+	return props
+}
+
+// defaultName names a job after the program it runs: "python train.py" becomes python
+func defaultName(command string) string {
+	fields := strings.Fields(command)
+	for len(fields) > 1 && strings.Contains(fields[0], "=") {
+		fields = fields[1:] // skip environment assignments
+	}
+	return programName(path.Base(fields[0]))
+}
+
+// programName turns a program or script name into a job name
+func programName(program string) string {
+	name := strings.Trim(nameChars.ReplaceAllString(strings.ToLower(program), "-"), "-")
+	if len(name) > 32 {
+		name = strings.Trim(name[:32], "-")
+	}
+	if name == "" {
+		return "job"
+	}
+	return name
+}
+
+// newID appends a random suffix to name, such as python-3f9a
+func newID(name string) (string, error) {
+	buf := make([]byte, 2)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate a job ID: %w", err)
+	}
+	return name + "-" + hex.EncodeToString(buf), nil
+}
+
+// UnitName returns the systemd user unit that runs a job
+func UnitName(id string) string {
+	return unitPrefix + id + ".service"
+}
+
+// Job is a submitted job and how far it got
+type Job struct {
+	ID        string        `json:"id"`
+	Command   string        `json:"command"` // the first line of the command
+	Dir       string        `json:"dir,omitempty"`
+	Memory    string        `json:"memory,omitempty"`
+	Timeout   time.Duration `json:"timeout_ns,omitempty"`
+	Limits    Limits        `json:"limits"`
+	State     string        `json:"state"`
+	Position  int           `json:"queue_position,omitempty"` // 1 is next, while queued
+	Usage     Usage         `json:"usage"`
+	Notify    bool          `json:"notify,omitempty"`
+	ExitCode  *int          `json:"exit_code,omitempty"`
+	Submitted time.Time     `json:"submitted"`
+	Started   time.Time     `json:"started,omitempty"`
+	Finished  time.Time     `json:"finished,omitempty"`
+}
+
+// Limits are a job's resource limits and priorities beyond its memory cap and timeout
+type Limits struct {
+	MemoryHigh string  `json:"memory_high,omitempty"`
+	CPUs       float64 `json:"cpus,omitempty"`
+	Nice       int     `json:"nice,omitempty"`
+	IONice     string  `json:"ionice,omitempty"`
+	GPU        string  `json:"gpu"`
+	Queue      bool    `json:"queue,omitempty"`
+}
+
+// String lists the limits that are set, such as "cpus=4 nice=10 gpu=exclusive"
+func (l Limits) String() string {
+	var parts []string
+	if l.MemoryHigh != "" {
+		parts = append(parts, "memory-high="+l.MemoryHigh)
+	}
+	if l.CPUs > 0 {
+		parts = append(parts, fmt.Sprintf("cpus=%g", l.CPUs))
+	}
+	if l.Nice > 0 {
+		parts = append(parts, fmt.Sprintf("nice=%d", l.Nice))
+	}
+	if l.IONice != "" {
+		parts = append(parts, "ionice="+l.IONice)
+	}
+	if l.GPU != GPUShared {
+		parts = append(parts, "gpu="+l.GPU)
+	}
+	if l.Queue && l.GPU != GPUExclusive {
+		parts = append(parts, "queued")
+	}
+	return strings.Join(parts, " ")
+}
+
+// Done reports whether the job has ended, one way or another
+func (j Job) Done() bool {
+	return j.State != StateRunning && j.State != StateQueued
+}
+
+// Duration is how long the job ran, or has been running at now
+func (j Job) Duration(now time.Time) time.Duration {
+	switch {
+	case j.Started.IsZero():
+		return 0
+	case !j.Finished.IsZero():
+		return j.Finished.Sub(j.Started)
+	case j.Done():
+		return 0
+	}
+	return now.Sub(j.Started)
+}
+
+// Manager submits and inspects jobs on the DGX
+type Manager struct {
+	sshClient *ssh.Client
+}
+
+// NewManager creates a new job manager
+func NewManager(sshClient *ssh.Client) *Manager {
+	return &Manager{sshClient: sshClient}
+}
+
+// SubmitResult is a submitted job's ID. Linger is false when the user's systemd instance
+// could not be kept running after logout, so the job stops when the last session ends.
+type SubmitResult struct {
+	ID     string
+	Linger bool
+}
+
+// Submit starts the job under systemd-run and returns once it is running
+func (m *Manager) Submit(spec Spec) (SubmitResult, error) {
+	if err := spec.Validate(); err != nil {
+		return SubmitResult{}, err
+	}
+	id, err := newID(spec.Name)
+	if err != nil {
+		return SubmitResult{}, err
+	}
+	output, err := m.sshClient.Execute(submitScript(id, spec, time.Now()))
+	if err != nil {
+		if msg := strings.TrimSpace(output); msg != "" {
+			return SubmitResult{}, fmt.Errorf("failed to submit job: %s", lastLine(msg))
+		}
+		return SubmitResult{}, fmt.Errorf("failed to submit job: %w", err)
+	}
+	return SubmitResult{ID: id, Linger: !strings.Contains(output, "linger=no")}, nil
+}
+
+// submitScript stages the job's files and starts its unit. User units only outlive the
+// last SSH session when lingering is enabled, so it is turned on when the user may.
+func submitScript(id string, spec Spec, now time.Time) string {
+	var meta strings.Builder
+	fmt.Fprintf(&meta, "ID=%s\nSubmitted=%d\n", id, now.Unix())
+	if spec.Dir != "" {
+		fmt.Fprintf(&meta, "Dir=%s\n", strings.ReplaceAll(spec.Dir, "\n", " "))
+	}
+	if spec.Memory != "" {
+		fmt.Fprintf(&meta, "Memory=%s\n", spec.Memory)
+	}
+	if spec.Timeout > 0 {
+		fmt.Fprintf(&meta, "Timeout=%d\n", int(spec.Timeout.Seconds()))
+	}
+	if spec.MemoryHigh != "" {
+		fmt.Fprintf(&meta, "MemoryHigh=%s\n", spec.MemoryHigh)
+	}
+	if spec.CPUs > 0 {
+		fmt.Fprintf(&meta, "CPUs=%g\n", spec.CPUs)
+	}
+	if spec.Nice > 0 {
+		fmt.Fprintf(&meta, "Nice=%d\n", spec.Nice)
+	}
+	if spec.IONice != "" {
+		fmt.Fprintf(&meta, "IONice=%s\n", spec.IONice)
+	}
+	if spec.GPU != "" && spec.GPU != GPUShared {
+		fmt.Fprintf(&meta, "GPU=%s\n", spec.GPU)
+	}
+	if spec.Queue {
+		meta.WriteString("Queue=true\n")
+	}
+	if spec.Notify != "" {
+		meta.WriteString("Notify=true\n")
+	}
+
+	encode := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	dir := "~"
+	if spec.Dir != "" {
+		dir = spec.Dir
+	}
+	props := append([]string{"--property=Description=" + ssh.ShellQuote("dgx job "+id)}, spec.properties()...)
+
+	// The ticket is taken before the unit starts, so the queue is in submission order
+	notify := ""
+	if spec.Notify != "" {
+		notify = fmt.Sprintf(`
+echo %s | base64 -d >"$d/notify" && chmod 600 "$d/notify"`, encode(spec.Notify))
+	}
+
+	ticket, runArgs := "", ""
+	if spec.Queue {
+		runArgs = ` "$ticket"`
+		ticket = fmt.Sprintf(`
+ticket="$(date +%%s%%N)-%s"
+mkdir -p "%s" && touch "%s/$ticket"`, id, queueDir, queueDir)
+	}
+
+	return fmt.Sprintf(`set -e
+wd=$(cd && cd %s 2>/dev/null && pwd) || { printf 'no such directory on the DGX: %%s\n' %s >&2; exit 1; }
+d="%s/%s"
+mkdir -p "$d"
+echo %s | base64 -d >"$d/job"
+echo %s | base64 -d >"$d/command"
+echo %s | base64 -d >"$d/run.sh"%s%s
+if [ "$(loginctl show-user "$(id -un)" -p Linger --value 2>/dev/null)" != yes ]; then
+  loginctl enable-linger 2>/dev/null || echo linger=no
+fi
+systemd-run --user --quiet --collect --unit=%s --working-directory="$wd" %s /bin/sh "$d/run.sh"%s`,
+		ssh.QuoteRemotePath(dir), ssh.ShellQuote(dir), jobsDir, id,
+		encode(meta.String()), encode(spec.Command), encode(runScript), notify, ticket,
+		UnitName(id), strings.Join(props, " "), runArgs)
+}
+
+// List returns the jobs on the DGX, newest first
+func (m *Manager) List() ([]Job, error) {
+	output, err := m.sshClient.Execute(listScript("*"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	return parseList(output), nil
+}
+
+// listScript prints each job matching the glob as a block: a header with its ID and unit
+// state, then its settings, command, and status lines
+func listScript(glob string) string {
+	return fmt.Sprintf(`for d in "%s"/%s/; do
+  [ -f "$d/job" ] || continue
+  id=$(basename "$d")
+  echo "=== $id $(systemctl --user is-active %s"$id".service 2>/dev/null)"
+  cat "$d/job" "$d/status" "$d/peak" 2>/dev/null
+  printf 'Command=%%s\n' "$(head -n 1 "$d/command")"
+  echo "Position=$(ls "%s" 2>/dev/null | grep -n -- "-$id\$" | cut -d: -f1)"
+done; true`, jobsDir, glob, unitPrefix, queueDir)
+}
+
+func parseList(output string) []Job {
+	var jobs []Job
+	for _, block := range strings.Split(output, "=== ")[1:] {
+		header, body, _ := strings.Cut(block, "\n")
+		fields := strings.Fields(header)
+		if len(fields) == 0 {
+			continue
+		}
+		active := ""
+		if len(fields) > 1 {
+			active = fields[1]
+		}
+		jobs = append(jobs, parseJob(fields[0], active, body))
+	}
+	sort.SliceStable(jobs, func(i, j int) bool { return jobs[i].Submitted.After(jobs[j].Submitted) })
+	return jobs
+}
+
+// parseJob reads a job's settings and status lines. active is its unit's state from
+// systemctl is-active; the unit is gone once the job ends.
+func parseJob(id, active, body string) Job {
+	j := Job{ID: id, Limits: Limits{GPU: GPUShared}}
+	canceled, signaled := false, false
+	for _, line := range strings.Split(body, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		unix := func() time.Time {
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return time.Time{}
+			}
+			return time.Unix(n, 0)
+		}
+		switch key {
+		case "Command":
+			j.Command = value
+		case "Dir":
+			j.Dir = value
+		case "Memory":
+			j.Memory = value
+		case "Timeout":
+			n, _ := strconv.Atoi(value)
+			j.Timeout = time.Duration(n) * time.Second
+		case "MemoryHigh":
+			j.Limits.MemoryHigh = value
+		case "CPUs":
+			j.Limits.CPUs, _ = strconv.ParseFloat(value, 64)
+		case "Nice":
+			j.Limits.Nice, _ = strconv.Atoi(value)
+		case "IONice":
+			j.Limits.IONice = value
+		case "GPU":
+			j.Limits.GPU = value
+		case "Queue":
+			j.Limits.Queue = value == "true"
+		case "Notify":
+			j.Notify = value == "true"
+		case "CPUTime":
+			n, _ := strconv.ParseInt(value, 10, 64)
+			j.Usage.CPUTime = time.Duration(n) * time.Microsecond
+		case "PeakCPU":
+			j.Usage.PeakCPU, _ = strconv.Atoi(value)
+		case "PeakMemory":
+			j.Usage.PeakMemory, _ = strconv.ParseInt(value, 10, 64)
+		case "PeakGPU":
+			j.Usage.PeakGPU, _ = strconv.Atoi(value)
+		case "PeakGPUMemory":
+			n, _ := strconv.ParseInt(value, 10, 64)
+			j.Usage.PeakGPUMemory = n << 20
+		case "Signal":
+			signaled = true
+		case "Position":
+			j.Position, _ = strconv.Atoi(value)
+		case "Submitted":
+			j.Submitted = unix()
+		case "Started":
+			j.Started = unix()
+		case "Finished":
+			j.Finished = unix()
+		case "Exit":
+			if code, err := strconv.Atoi(value); err == nil {
+				j.ExitCode = &code
+			}
+		case "Canceled":
+			canceled = true
+			if j.Finished.IsZero() {
+				j.Finished = unix()
+			}
+		}
+	}
+
+	switch {
+	case canceled:
+		j.State = StateCanceled
+	case signaled:
+		j.State = StateKilled
+	case j.ExitCode != nil && *j.ExitCode == 0:
+		j.State = StateSucceeded
+	case j.ExitCode != nil:
+		j.State = StateFailed
+	case active == "active" || active == "activating" || active == "deactivating":
+		j.State = StateRunning
+		// A queued job records its start once it leaves the queue
+		if j.Limits.Queue && j.Started.IsZero() {
+			j.State = StateQueued
+		}
+	default:
+		j.State = StateKilled
+	}
+	if j.State != StateQueued {
+		j.Position = 0
+	}
+	return j
+}
+
+// Find returns the job whose ID is ref or starts with it
+func Find(jobs []Job, ref string) (Job, error) {
+	var matches []Job
+	for _, j := range jobs {
+		if j.ID == ref {
+			return j, nil
+		}
+		if strings.HasPrefix(j.ID, ref) {
+			matches = append(matches, j)
+		}
+	}
+	switch len(matches) {
+	case 1:
+		return matches[0], nil
+	case 0:
+		return Job{}, fmt.Errorf("no job matches %q (see 'dgx job list')", ref)
+	}
+	ids := make([]string, len(matches))
+	for i, j := range matches {
+		ids[i] = j.ID
+	}
+	return Job{}, fmt.Errorf("%q matches several jobs: %s", ref, strings.Join(ids, ", "))
+}
+
+// Get returns the job named by ref, an ID or a unique prefix of one
+func (m *Manager) Get(ref string) (Job, error) {
+	jobs, err := m.List()
+	if err != nil {
+		return Job{}, err
+	}
+	return Find(jobs, ref)
+}
+
+// LogsCommand returns the command that prints the last tail lines of a job's output. With
+// follow it keeps printing until the job ends.
+func LogsCommand(id string, tail int, follow bool) string {
+	log := fmt.Sprintf(`"%s/%s/output.log"`, jobsDir, id)
+	if !follow {
+		return fmt.Sprintf("tail -n %d %s 2>/dev/null; true", tail, log)
+	}
+	return fmt.Sprintf(`pid=$(systemctl --user show -p MainPID --value %s 2>/dev/null)
+if [ -n "$pid" ] && [ "$pid" != 0 ]; then exec tail -n %d -F --pid="$pid" %s 2>/dev/null; fi
+tail -n %d %s 2>/dev/null; true`, UnitName(id), tail, log, tail, log)
+}
+
+// Cancel stops a running job, killing everything it started
+func (m *Manager) Cancel(id string) error {
+	script := fmt.Sprintf(`echo "Canceled=$(date +%%s)" >>"%s/%s/status" && systemctl --user stop %s`, jobsDir, id, UnitName(id))
+	if output, err := m.sshClient.Execute(script); err != nil {
+		if msg := strings.TrimSpace(output); msg != "" {
+			return fmt.Errorf("failed to cancel %s: %s", id, lastLine(msg))
+		}
+		return fmt.Errorf("failed to cancel %s: %w", id, err)
+	}
+	return nil
+}
+
+// Wait blocks until the job ends or ctx does, then returns the job as it ended
+func (m *Manager) Wait(ctx context.Context, id string) (Job, error) {
+	script := fmt.Sprintf(`while systemctl --user is-active -q %s; do sleep 2; done; %s`, UnitName(id), listScript(id))
+	output, err := m.sshClient.ExecuteContext(ctx, script)
+	if err != nil {
+		return Job{}, err
+	}
+	jobs := parseList(output)
+	if len(jobs) != 1 {
+		return Job{}, fmt.Errorf("job %s not found", id)
+	}
+	return jobs[0], nil
+}
+
+func lastLine(s string) string {
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
@@ -0,0 +1,156 @@
+package job
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateNamesJob(t *testing.T) {
+	cases := map[string]string{
+		"python train.py --epochs 3":        "python",
+		"CUDA_VISIBLE_DEVICES=0 ./prep.sh":  "prep-sh",
+		"/usr/bin/env bash -c 'echo hi'":    "env",
+		"HF_HOME=/data/hf huggingface-cli ": "huggingface-cli",
+	}
+	for command, want := range cases {
+		s := Spec{Command: command}
+		if err := s.Validate(); err != nil {
+			t.Fatalf("Validate(%q): %v", command, err)
+		}
+		if s.Name != want {
+			t.Fatalf("name for %q = %q, want %q", command, s.Name, want)
+		}
+	}
+
+	s := Spec{Command: "#!/bin/bash\nset -e\n", Script: "scripts/Prep_Data.sh"}
+	if err := s.Validate(); err != nil || s.Name != "prep-data" {
+		t.Fatalf("script job named %q (%v), want prep-data", s.Name, err)
+	}
+
+	for _, s := range []Spec{
+		{Command: " "},
+		{Command: "true", Name: "Bad Name"},
+		{Command: "true", Memory: "32 GB"},
+		{Command: "true", Timeout: time.Millisecond},
+		{Command: "true", Nice: -5},
+		{Command: "true", IONice: "realtime"},
+		{Command: "true", GPU: "all"},
+		{Command: "true", Notify: "slack.example.com/hook"},
+	} {
+		if err := s.Validate(); err == nil {
+			t.Fatalf("expected %+v to be rejected", s)
+		}
+	}
+}
+
+func TestSubmitScript(t *testing.T) {
+	spec := Spec{Name: "train", Command: "python train.py", Dir: "~/proj", Memory: "64G", Timeout: 2 * time.Hour}
+	script := submitScript("train-3f9a", spec, time.Unix(1760443200, 0))
+	for _, want := range []string{
+		`cd "$HOME"/'proj'`,
+		"systemd-run --user --quiet --collect --unit=dgx-job-train-3f9a.service",
+		"--property=MemoryMax=64G",
+		"--property=RuntimeMaxSec=7200",
+		"loginctl enable-linger",
+	} {
+		if !strings.Contains(script, want) {
+			t.Fatalf("script missing %q:\n%s", want, script)
+		}
+	}
+	if strings.Contains(script, "ticket") {
+		t.Fatalf("unqueued job took a queue ticket:\n%s", script)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	spec := Spec{Command: "python prep.py", CPUs: 2.5, MemoryHigh: "24G", Nice: 10, IONice: "best-effort:7", GPU: GPUExclusive}
+	if err := spec.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !spec.Queue {
+		t.Fatalf("exclusive GPU job should be queued")
+	}
+	got := strings.Join(spec.properties(), " ")
+	want := "--property=MemoryHigh=24G --property=CPUQuota=250% --nice=10 --property=IOSchedulingClass=best-effort --property=IOSchedulingPriority=7 --setenv=DGX_JOB_GPU=exclusive"
+	if got != want {
+		t.Fatalf("properties = %s\nwant %s", got, want)
+	}
+
+	script := submitScript("prep-0b1c", spec, time.Unix(1760443200, 0))
+	if !strings.Contains(script, `touch "$HOME/.local/share/dgx/jobs/.queue/$ticket"`) || !strings.Contains(script, `/bin/sh "$d/run.sh" "$ticket"`) {
+		t.Fatalf("queued job without a ticket:\n%s", script)
+	}
+
+	none := Spec{Command: "python eval.py", GPU: GPUNone}
+	if err := none.Validate(); err != nil || strings.Join(none.properties(), " ") != "--setenv=CUDA_VISIBLE_DEVICES=" {
+		t.Fatalf("unexpected properties for a job without the GPU: %v %v", none.properties(), err)
+	}
+}
+
+func TestParseList(t *testing.T) {
+	output := `=== train-3f9a active
+ID=train-3f9a
+Submitted=1760443200
+Memory=64G
+Started=1760443201
+Command=python train.py
+=== prep-0b1c inactive
+ID=prep-0b1c
+Submitted=1760440000
+Started=1760440001
+Exit=2
+Finished=1760440061
+Command=./prep.sh
+=== eval-77aa inactive
+ID=eval-77aa
+Submitted=1760441000
+Started=1760441001
+Canceled=1760441100
+Command=python eval.py
+=== gpu-9e9e active
+ID=gpu-9e9e
+Submitted=1760443300
+GPU=exclusive
+Queue=true
+Command=python finetune.py
+Position=2
+=== old-1234 inactive
+ID=old-1234
+Submitted=1760430000
+Started=1760430001
+Command=sleep 1000
+`
+	jobs := parseList(output)
+	if len(jobs) != 5 {
+		t.Fatalf("expected 5 jobs, got %d", len(jobs))
+	}
+	want := []struct{ id, state string }{
+		{"gpu-9e9e", StateQueued},
+		{"train-3f9a", StateRunning},
+		{"eval-77aa", StateCanceled},
+		{"prep-0b1c", StateFailed},
+		{"old-1234", StateKilled},
+	}
+	for i, w := range want {
+		if jobs[i].ID != w.id || jobs[i].State != w.state {
+			t.Fatalf("job %d = %s %s, want %s %s", i, jobs[i].ID, jobs[i].State, w.id, w.state)
+		}
+	}
+	if prep := jobs[3]; *prep.ExitCode != 2 || prep.Duration(time.Now()) != time.Minute {
+		t.Fatalf("unexpected prep job: %+v", prep)
+	}
+	if queued := jobs[0]; queued.Done() || queued.Position != 2 || queued.Limits.String() != "gpu=exclusive" {
+		t.Fatalf("unexpected queued job: %+v", queued)
+	}
+	if train := jobs[1]; train.Memory != "64G" || train.Command != "python train.py" || train.Limits.String() != "" {
+		t.Fatalf("unexpected train job: %+v", train)
+	}
+
+	if j, err := Find(jobs, "pr"); err != nil || j.ID != "prep-0b1c" {
+		t.Fatalf("Find(pr) = %+v, %v", j, err)
+	}
+	if _, err := Find(jobs, "x"); err == nil {
+		t.Fatalf("expected no match for x")
+	}
+}
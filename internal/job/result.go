@@ -0,0 +1,107 @@
+package job
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/state"
+)
+
+const (
+	// TailLines is how much of a job's output its result keeps
+	TailLines = 20
+	// maxResults bounds the results kept per host; the oldest are dropped first
+	maxResults = 200
+)
+
+// Usage is what a job used, sampled every 5s while it ran. CPU is in percent of one CPU;
+// GPU utilization is the whole GPU's, which other work may have shared.
+type Usage struct {
+	CPUTime       time.Duration `json:"cpu_time_ns,omitempty"`
+	PeakCPU       int           `json:"peak_cpu_percent,omitempty"`
+	PeakMemory    int64         `json:"peak_memory_bytes,omitempty"`
+	PeakGPU       int           `json:"peak_gpu_percent,omitempty"`
+	PeakGPUMemory int64         `json:"peak_gpu_memory_bytes,omitempty"`
+}
+
+// Result is the local record of a job that ended: how it ended, what it used, and the end
+// of its output. It is kept after the job's directory on the DGX is gone.
+type Result struct {
+	Job
+	Tail     []string  `json:"tail"`
+	Recorded time.Time `json:"recorded"`
+}
+
+func resultsKey(host string) string {
+	return state.Key("jobs", host)
+}
+
+// LoadResults returns the results recorded for host, newest job first
+func LoadResults(store *state.Store, host string) ([]Result, error) {
+	var results []Result
+	if _, err := store.Load(resultsKey(host), &results); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Submitted.After(results[j].Submitted) })
+	return results, nil
+}
+
+// SaveResult records r for host, replacing an earlier record of the same job
+func SaveResult(store *state.Store, host string, r Result) error {
+	results, err := LoadResults(store, host)
+	if err != nil {
+		return err
+	}
+	kept := []Result{r}
+	for _, old := range results {
+		if old.ID != r.ID && len(kept) < maxResults {
+			kept = append(kept, old)
+		}
+	}
+	return store.Save(resultsKey(host), kept)
+}
+
+// FindResult returns the recorded result whose job ID is ref or starts with it
+func FindResult(results []Result, ref string) (Result, error) {
+	jobs := make([]Job, len(results))
+	for i, r := range results {
+		jobs[i] = r.Job
+	}
+	j, err := Find(jobs, ref)
+	if err != nil {
+		return Result{}, err
+	}
+	for _, r := range results {
+		if r.ID == j.ID {
+			return r, nil
+		}
+	}
+	return Result{}, fmt.Errorf("no job matches %q", ref)
+}
+
+// Tail returns the last lines of a job's output
+func (m *Manager) Tail(id string, lines int) ([]string, error) {
+	output, err := m.sshClient.Execute(LogsCommand(id, lines, false))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the output of %s: %w", id, err)
+	}
+	output = strings.TrimRight(output, "\n")
+	if output == "" {
+		return []string{}, nil
+	}
+	return strings.Split(output, "\n"), nil
+}
+
+// Record returns the result of a job that ended, reading the end of its output
+func (m *Manager) Record(j Job, now time.Time) (Result, error) {
+	if !j.Done() {
+		return Result{}, fmt.Errorf("job %s has not ended", j.ID)
+	}
+	tail, err := m.Tail(j.ID, TailLines)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Job: j, Tail: tail, Recorded: now}, nil
+}
@@ -0,0 +1,58 @@
+package job
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/state"
+)
+
+func TestParseUsage(t *testing.T) {
+	j := parseJob("train-3f9a", "inactive", `ID=train-3f9a
+Submitted=1760443200
+Notify=true
+Started=1760443201
+Signal=TERM
+Finished=1760446801
+PeakCPU=380
+PeakGPU=97
+PeakGPUMemory=40960
+CPUTime=11400000000
+PeakMemory=13207024845
+`)
+	if j.State != StateKilled || !j.Notify {
+		t.Fatalf("unexpected job: %+v", j)
+	}
+	want := Usage{CPUTime: 190 * time.Minute, PeakCPU: 380, PeakMemory: 13207024845, PeakGPU: 97, PeakGPUMemory: 40 << 30}
+	if j.Usage != want {
+		t.Fatalf("usage = %+v, want %+v", j.Usage, want)
+	}
+}
+
+func TestSaveResult(t *testing.T) {
+	store := state.NewStore(t.TempDir())
+	first := Result{Job: Job{ID: "prep-0b1c", State: StateRunning, Submitted: time.Unix(1760440000, 0)}}
+	if err := SaveResult(store, "spark", first); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	code := 0
+	done := Result{Job: Job{ID: "prep-0b1c", State: StateSucceeded, ExitCode: &code, Submitted: first.Submitted}, Tail: []string{"done"}}
+	if err := SaveResult(store, "spark", done); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := SaveResult(store, "spark", Result{Job: Job{ID: "train-3f9a", Submitted: time.Unix(1760443200, 0)}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	results, err := LoadResults(store, "spark")
+	if err != nil || len(results) != 2 || results[0].ID != "train-3f9a" {
+		t.Fatalf("unexpected results: %+v, %v", results, err)
+	}
+	r, err := FindResult(results, "prep")
+	if err != nil || r.State != StateSucceeded || len(r.Tail) != 1 {
+		t.Fatalf("FindResult(prep) = %+v, %v", r, err)
+	}
+	if other, _ := LoadResults(store, "spark-2"); len(other) != 0 {
+		t.Fatalf("results leaked across hosts: %+v", other)
+	}
+}
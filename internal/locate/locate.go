@@ -0,0 +1,191 @@
+// Package locate finds a DGX whose DHCP address changed, by its mDNS name, the address it
+// last answered on, or its MAC address in the ARP table.
+package locate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/state"
+)
+
+// Methods reported in a Result
+const (
+	MethodMDNS      = "mDNS"
+	MethodLastKnown = "last known address"
+	MethodARP       = "ARP scan"
+)
+
+// ErrNoHints is returned by Find when there is no MAC, mDNS name, or earlier address to
+// look for the DGX by
+var ErrNoHints = errors.New("nothing to look for the DGX by: set mac or mdns, or connect once so dgx can remember it")
+
+// Target is the DGX to look for
+type Target struct {
+	Host string // configured address, which no longer answers
+	Port int
+	MAC  string // optional; MACs seen on earlier connections are used otherwise
+	MDNS string // optional .local name; defaults to the remembered hostname
+}
+
+// Sighting is where a DGX was last reached, kept in the state store
+type Sighting struct {
+	Address  string    `json:"address"`
+	MAC      string    `json:"mac,omitempty"`
+	Hostname string    `json:"hostname,omitempty"`
+	SeenAt   time.Time `json:"seen_at"`
+}
+
+// Result is a new address that accepts connections on the SSH port
+type Result struct {
+	Address string
+	Method  string
+}
+
+// Locator runs the lookups. The zero value is not usable; use New.
+type Locator struct {
+	Timeout     time.Duration
+	LookupMDNS  func(ctx context.Context, name string, timeout time.Duration) ([]string, error)
+	Neighbors   func() ([]Neighbor, error)
+	Sweep       func(ctx context.Context, port int, timeout time.Duration)
+	Reachable   func(addr string, port int, timeout time.Duration) bool
+	DialTimeout time.Duration
+}
+
+// New returns a locator that uses the network
+func New() *Locator {
+	return &Locator{
+		Timeout:     2 * time.Second,
+		LookupMDNS:  LookupMDNS,
+		Neighbors:   Neighbors,
+		Sweep:       Sweep,
+		Reachable:   Reachable,
+		DialTimeout: 300 * time.Millisecond,
+	}
+}
+
+// Find looks for t by mDNS, then at the last known address, then by MAC in the ARP table
+// (sweeping the local subnets first when the MAC is not listed yet). Only addresses that
+// differ from t.Host and accept TCP connections on t.Port are returned; the caller must
+// still verify the host key.
+func (l *Locator) Find(ctx context.Context, t Target, last *Sighting) (Result, error) {
+	if last == nil {
+		last = &Sighting{}
+	}
+	var tried []string
+	accept := func(addr string) bool {
+		return addr != "" && addr != t.Host && l.Reachable(addr, t.Port, l.Timeout)
+	}
+
+	if name := MDNSName(t, last); name != "" {
+		tried = append(tried, MethodMDNS+" ("+name+")")
+		if addrs, err := l.LookupMDNS(ctx, name, l.Timeout); err == nil {
+			for _, addr := range addrs {
+				if accept(addr) {
+					return Result{Address: addr, Method: MethodMDNS}, nil
+				}
+			}
+		}
+	}
+
+	if last.Address != "" && last.Address != t.Host {
+		tried = append(tried, MethodLastKnown+" ("+last.Address+")")
+		if accept(last.Address) {
+			return Result{Address: last.Address, Method: MethodLastKnown}, nil
+		}
+	}
+
+	mac := t.MAC
+	if mac == "" {
+		mac = last.MAC
+	}
+	if mac, ok := NormalizeMAC(mac); ok {
+		tried = append(tried, MethodARP+" ("+mac+")")
+		addr := l.neighborIP(mac)
+		if addr == "" && ctx.Err() == nil {
+			l.Sweep(ctx, t.Port, l.DialTimeout)
+			addr = l.neighborIP(mac)
+		}
+		if accept(addr) {
+			return Result{Address: addr, Method: MethodARP}, nil
+		}
+	}
+
+	if len(tried) == 0 {
+		return Result{}, ErrNoHints
+	}
+	return Result{}, fmt.Errorf("DGX was not found elsewhere (tried %s)", strings.Join(tried, ", "))
+}
+
+func (l *Locator) neighborIP(mac string) string {
+	neighbors, err := l.Neighbors()
+	if err != nil {
+		return ""
+	}
+	for _, n := range neighbors {
+		if n.MAC == mac {
+			return n.IP
+		}
+	}
+	return ""
+}
+
+// MDNSName returns the .local name to query: the configured one, the remembered hostname,
+// or the configured host itself when it is a bare or .local name
+func MDNSName(t Target, last *Sighting) string {
+	switch {
+	case t.MDNS != "":
+		return localName(t.MDNS)
+	case last != nil && last.Hostname != "":
+		return localName(last.Hostname)
+	case net.ParseIP(t.Host) == nil && (!strings.Contains(t.Host, ".") || strings.HasSuffix(t.Host, ".local")):
+		return localName(t.Host)
+	}
+	return ""
+}
+
+func localName(name string) string {
+	name = strings.TrimSuffix(name, ".")
+	if !strings.HasSuffix(name, ".local") {
+		// Short hostnames such as spark-1a2b; qualified names are not mDNS names
+		if strings.Contains(name, ".") {
+			return ""
+		}
+		name += ".local"
+	}
+	return name
+}
+
+// Reachable reports whether addr accepts TCP connections on port within timeout
+func Reachable(addr string, port int, timeout time.Duration) bool {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(addr, strconv.Itoa(port)), timeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// SightingKey is the state store key for the DGX known as name (a profile or host)
+func SightingKey(name string) string {
+	return state.Key("hosts", name)
+}
+
+// Load returns the sighting stored for name, or nil
+func Load(store *state.Store, name string) *Sighting {
+	var s Sighting
+	if found, err := store.Load(SightingKey(name), &s); err != nil || !found {
+		return nil
+	}
+	return &s
+}
+
+// Save stores the sighting for name
+func Save(store *state.Store, name string, s Sighting) error {
+	return store.Save(SightingKey(name), s)
+}
@@ -0,0 +1,114 @@
+package locate
+
+import (
+	"context"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseNeighbors(t *testing.T) {
+	output := `IP address       HW type     Flags       HW address            Mask     Device
+192.168.1.23     0x1         0x2         a0:b1:c2:d3:e4:f5     *        eth0
+192.168.1.99     0x1         0x0         00:00:00:00:00:00     *        eth0
+? (192.168.1.40) at 0:1b:2:d3:e4:f5 on en0 ifscope [ethernet]
+? (192.168.1.41) at (incomplete) on en0 ifscope [ethernet]
+  192.168.1.50          AA-BB-CC-DD-EE-0F     dynamic
+fe80::1 dev eth0 lladdr 11:22:33:44:55:66 router STALE`
+	want := []Neighbor{
+		{IP: "192.168.1.23", MAC: "a0:b1:c2:d3:e4:f5"},
+		{IP: "192.168.1.40", MAC: "00:1b:02:d3:e4:f5"},
+		{IP: "192.168.1.50", MAC: "aa:bb:cc:dd:ee:0f"},
+	}
+	if got := ParseNeighbors(output); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestMDNSName(t *testing.T) {
+	cases := []struct {
+		target Target
+		last   *Sighting
+		want   string
+	}{
+		{Target{Host: "192.168.1.23", MDNS: "lab-spark"}, nil, "lab-spark.local"},
+		{Target{Host: "192.168.1.23"}, &Sighting{Hostname: "spark-1a2b"}, "spark-1a2b.local"},
+		{Target{Host: "spark-1a2b.local"}, nil, "spark-1a2b.local"},
+		{Target{Host: "spark-1a2b"}, nil, "spark-1a2b.local"},
+		{Target{Host: "spark.example.com"}, nil, ""},
+		{Target{Host: "192.168.1.23"}, nil, ""},
+	}
+	for _, c := range cases {
+		if got := MDNSName(c.target, c.last); got != c.want {
+			t.Fatalf("MDNSName(%+v, %+v) = %q, want %q", c.target, c.last, got, c.want)
+		}
+	}
+}
+
+func TestParseAnswers(t *testing.T) {
+	query, err := buildQuery("spark-1a2b.local")
+	if err != nil {
+		t.Fatalf("buildQuery: %v", err)
+	}
+	// Response echoing the question, with an answer whose name points back at it
+	msg := append([]byte{}, query...)
+	msg[2] = 0x84
+	binary.BigEndian.PutUint16(msg[6:], 1)
+	msg = append(msg, 0xC0, 12)
+	msg = binary.BigEndian.AppendUint16(msg, dnsTypeA)
+	msg = binary.BigEndian.AppendUint16(msg, dnsClassIN)
+	msg = binary.BigEndian.AppendUint32(msg, 120)
+	msg = binary.BigEndian.AppendUint16(msg, 4)
+	msg = append(msg, 192, 168, 1, 77)
+
+	if got := parseAnswers(msg, "spark-1a2b.local"); !reflect.DeepEqual(got, []string{"192.168.1.77"}) {
+		t.Fatalf("got %v", got)
+	}
+	if got := parseAnswers(msg, "other.local"); len(got) != 0 {
+		t.Fatalf("answers for another name: %v", got)
+	}
+	if got := parseAnswers(msg[:len(msg)-3], "spark-1a2b.local"); len(got) != 0 {
+		t.Fatalf("truncated packet gave %v", got)
+	}
+}
+
+func TestFind(t *testing.T) {
+	swept := false
+	newLocator := func(up map[string]bool) *Locator {
+		return &Locator{
+			LookupMDNS: func(ctx context.Context, name string, timeout time.Duration) ([]string, error) {
+				return []string{"192.168.1.10"}, nil
+			},
+			Neighbors: func() ([]Neighbor, error) {
+				if !swept {
+					return nil, nil
+				}
+				return []Neighbor{{IP: "192.168.1.30", MAC: "a0:b1:c2:d3:e4:f5"}}, nil
+			},
+			Sweep:     func(ctx context.Context, port int, timeout time.Duration) { swept = true },
+			Reachable: func(addr string, port int, timeout time.Duration) bool { return up[addr] },
+		}
+	}
+	target := Target{Host: "192.168.1.5", Port: 22}
+	last := &Sighting{Address: "192.168.1.20", MAC: "A0-B1-C2-D3-E4-F5", Hostname: "spark-1a2b"}
+
+	res, err := newLocator(map[string]bool{"192.168.1.10": true, "192.168.1.20": true}).Find(context.Background(), target, last)
+	if err != nil || res.Method != MethodMDNS || res.Address != "192.168.1.10" {
+		t.Fatalf("mDNS: %+v, %v", res, err)
+	}
+
+	res, err = newLocator(map[string]bool{"192.168.1.20": true}).Find(context.Background(), target, last)
+	if err != nil || res.Method != MethodLastKnown {
+		t.Fatalf("last known: %+v, %v", res, err)
+	}
+
+	res, err = newLocator(map[string]bool{"192.168.1.30": true}).Find(context.Background(), target, last)
+	if err != nil || res.Method != MethodARP || res.Address != "192.168.1.30" || !swept {
+		t.Fatalf("ARP: %+v, %v (swept %v)", res, err, swept)
+	}
+
+	if _, err := newLocator(nil).Find(context.Background(), target, nil); err != ErrNoHints {
+		t.Fatalf("expected an error with nothing to look for")
+	}
+}
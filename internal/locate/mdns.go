@@ -0,0 +1,168 @@
+package locate
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// mdnsGroup is the IPv4 multicast DNS group and port
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+const (
+	dnsTypeA   = 1
+	dnsClassIN = 1
+	// dnsUnicastResponse asks responders to answer the querier directly (QU question)
+	dnsUnicastResponse = 0x8000
+)
+
+// LookupMDNS resolves a .local name by sending a one-shot multicast DNS query, which works
+// without avahi or Bonjour on the workstation. It falls back to the system resolver, which
+// handles .local through nss-mdns or mDNSResponder where those are installed.
+func LookupMDNS(ctx context.Context, name string, timeout time.Duration) ([]string, error) {
+	name = strings.TrimSuffix(name, ".")
+	if addrs, err := queryMDNS(name, timeout); err == nil && len(addrs) > 0 {
+		return addrs, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var ips []string
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", name, err)
+	}
+	for _, a := range addrs {
+		if v4 := a.IP.To4(); v4 != nil {
+			ips = append(ips, v4.String())
+		}
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no IPv4 address for %s", name)
+	}
+	return ips, nil
+}
+
+func queryMDNS(name string, timeout time.Duration) ([]string, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	query, err := buildQuery(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.WriteToUDP(query, mdnsGroup); err != nil {
+		return nil, err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 9000)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return nil, err
+		}
+		if addrs := parseAnswers(buf[:n], name); len(addrs) > 0 {
+			return addrs, nil
+		}
+	}
+}
+
+// buildQuery encodes an mDNS question for the A record of name
+func buildQuery(name string) ([]byte, error) {
+	msg := make([]byte, 12, 12+len(name)+6)
+	binary.BigEndian.PutUint16(msg[4:], 1) // QDCOUNT
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 {
+			return nil, fmt.Errorf("invalid mDNS name %q", name)
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0)
+	msg = binary.BigEndian.AppendUint16(msg, dnsTypeA)
+	msg = binary.BigEndian.AppendUint16(msg, dnsClassIN|dnsUnicastResponse)
+	return msg, nil
+}
+
+// parseAnswers returns the IPv4 addresses of A records for name in a DNS response.
+// Malformed packets yield no addresses.
+func parseAnswers(msg []byte, name string) []string {
+	if len(msg) < 12 || msg[2]&0x80 == 0 { // not a response
+		return nil
+	}
+	questions := int(binary.BigEndian.Uint16(msg[4:]))
+	records := int(binary.BigEndian.Uint16(msg[6:])) + int(binary.BigEndian.Uint16(msg[8:])) + int(binary.BigEndian.Uint16(msg[10:]))
+
+	off := 12
+	for i := 0; i < questions; i++ {
+		_, next, err := readName(msg, off)
+		if err != nil || next+4 > len(msg) {
+			return nil
+		}
+		off = next + 4
+	}
+
+	var addrs []string
+	for i := 0; i < records; i++ {
+		owner, next, err := readName(msg, off)
+		if err != nil || next+10 > len(msg) {
+			return addrs
+		}
+		typ := binary.BigEndian.Uint16(msg[next:])
+		length := int(binary.BigEndian.Uint16(msg[next+8:]))
+		data := next + 10
+		if data+length > len(msg) {
+			return addrs
+		}
+		if typ == dnsTypeA && length == 4 && strings.EqualFold(owner, name) {
+			addrs = append(addrs, net.IP(msg[data:data+4]).String())
+		}
+		off = data + length
+	}
+	return addrs
+}
+
+var errBadName = errors.New("malformed DNS name")
+
+// readName decodes the possibly compressed name at off and returns it with the offset just
+// past it in the original position
+func readName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	end := -1
+	for jumps := 0; ; {
+		if off >= len(msg) {
+			return "", 0, errBadName
+		}
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			if end < 0 {
+				end = off + 1
+			}
+			return strings.Join(labels, "."), end, nil
+		case n&0xC0 == 0xC0:
+			if off+1 >= len(msg) || jumps > 10 {
+				return "", 0, errBadName
+			}
+			if end < 0 {
+				end = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3FFF)
+			jumps++
+		default:
+			if off+1+n > len(msg) {
+				return "", 0, errBadName
+			}
+			labels = append(labels, string(msg[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+}
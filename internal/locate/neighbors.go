@@ -0,0 +1,176 @@
+package locate
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Neighbor is one entry of the workstation's ARP table
+type Neighbor struct {
+	IP  string
+	MAC string
+}
+
+// Neighbors reads the ARP table: /proc/net/arp on Linux, 'arp -a' elsewhere
+func Neighbors() ([]Neighbor, error) {
+	if runtime.GOOS == "linux" {
+		if data, err := os.ReadFile("/proc/net/arp"); err == nil {
+			return ParseNeighbors(string(data)), nil
+		}
+	}
+	args := []string{"-an"}
+	if runtime.GOOS == "windows" {
+		args = []string{"-a"}
+	}
+	out, err := exec.Command("arp", args...).Output()
+	if err != nil {
+		return nil, err
+	}
+	return ParseNeighbors(string(out)), nil
+}
+
+// ParseNeighbors extracts IPv4/MAC pairs from /proc/net/arp, 'ip neigh', BSD 'arp -an'
+// or Windows 'arp -a' output. Incomplete entries have no MAC and are skipped.
+func ParseNeighbors(output string) []Neighbor {
+	var neighbors []Neighbor
+	for _, line := range strings.Split(output, "\n") {
+		var n Neighbor
+		for _, field := range strings.Fields(line) {
+			field = strings.Trim(field, "()")
+			if ip := net.ParseIP(field); ip != nil && ip.To4() != nil && n.IP == "" {
+				n.IP = ip.String()
+			} else if mac, ok := NormalizeMAC(field); ok && n.MAC == "" {
+				n.MAC = mac
+			}
+		}
+		if n.IP != "" && n.MAC != "" && n.MAC != "00:00:00:00:00:00" {
+			neighbors = append(neighbors, n)
+		}
+	}
+	return neighbors
+}
+
+// NormalizeMAC returns mac as lowercase colon-separated octets. It accepts '-' separators
+// and the single-digit octets macOS prints (0:1b:...).
+func NormalizeMAC(mac string) (string, bool) {
+	parts := strings.FieldsFunc(mac, func(r rune) bool { return r == ':' || r == '-' })
+	if len(parts) != 6 || strings.Count(mac, ":")+strings.Count(mac, "-") != 5 {
+		return "", false
+	}
+	for i, p := range parts {
+		if len(p) > 2 {
+			return "", false
+		}
+		b, err := strconv.ParseUint(p, 16, 8)
+		if err != nil {
+			return "", false
+		}
+		parts[i] = strconv.FormatUint(b|0x100, 16)[1:]
+	}
+	return strings.Join(parts, ":"), true
+}
+
+// NeighborMAC returns the MAC address the ARP table has for ip
+func NeighborMAC(ip string) string {
+	neighbors, err := Neighbors()
+	if err != nil {
+		return ""
+	}
+	for _, n := range neighbors {
+		if n.IP == ip {
+			return n.MAC
+		}
+	}
+	return ""
+}
+
+// sweepWorkers bounds concurrent probes while filling the ARP table
+const sweepWorkers = 64
+
+// Sweep sends a TCP connection attempt to port on every address of the workstation's
+// local IPv4 subnets (at most a /24 each), so the kernel resolves their MAC addresses and
+// the ARP table lists hosts that have not talked to us recently.
+func Sweep(ctx context.Context, port int, timeout time.Duration) {
+	targets := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < sweepWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dialer := net.Dialer{Timeout: timeout}
+			for addr := range targets {
+				if conn, err := dialer.DialContext(ctx, "tcp", addr); err == nil {
+					conn.Close()
+				}
+			}
+		}()
+	}
+
+	portStr := strconv.Itoa(port)
+	for _, subnet := range localSubnets() {
+		for _, ip := range subnetHosts(subnet) {
+			select {
+			case targets <- net.JoinHostPort(ip, portStr):
+			case <-ctx.Done():
+			}
+		}
+	}
+	close(targets)
+	wg.Wait()
+}
+
+// localSubnets returns the private IPv4 networks of the workstation's active interfaces,
+// narrowed to the /24 around its own address so a sweep stays small
+func localSubnets() []*net.IPNet {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	var subnets []*net.IPNet
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			ipnet, ok := a.(*net.IPNet)
+			if !ok || ipnet.IP.To4() == nil || !ipnet.IP.IsPrivate() {
+				continue
+			}
+			if ones, _ := ipnet.Mask.Size(); ones < 24 {
+				mask := net.CIDRMask(24, 32)
+				ipnet = &net.IPNet{IP: ipnet.IP.Mask(mask), Mask: mask}
+			}
+			subnets = append(subnets, ipnet)
+		}
+	}
+	return subnets
+}
+
+// subnetHosts lists the host addresses of an IPv4 subnet, without network and broadcast
+func subnetHosts(subnet *net.IPNet) []string {
+	base := subnet.IP.Mask(subnet.Mask).To4()
+	ones, bits := subnet.Mask.Size()
+	if base == nil || bits != 32 || ones < 24 || ones > 30 {
+		return nil
+	}
+	size := 1 << (32 - ones)
+	hosts := make([]string, 0, size-2)
+	for i := 1; i < size-1; i++ {
+		ip := make(net.IP, 4)
+		copy(ip, base)
+		ip[3] += byte(i)
+		hosts = append(hosts, ip.String())
+	}
+	return hosts
+}
@@ -0,0 +1,193 @@
+package netperf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+// Result captures a single throughput measurement
+type Result struct {
+	Direction string
+	Bytes     int64
+	Duration  time.Duration
+}
+
+// MBps returns the throughput in megabytes per second
+func (r Result) MBps() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Bytes) / r.Duration.Seconds() / (1024 * 1024)
+}
+
+// Mbps returns the throughput in megabits per second
+func (r Result) Mbps() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Bytes) * 8 / r.Duration.Seconds() / 1e6
+}
+
+// LatencyStats summarizes command round-trip times
+type LatencyStats struct {
+	Samples int
+	Min     time.Duration
+	Avg     time.Duration
+	Max     time.Duration
+}
+
+// Tester runs network measurements between the workstation and the DGX
+type Tester struct {
+	sshClient *ssh.Client
+	host      string
+}
+
+// NewTester creates a new network tester
+func NewTester(sshClient *ssh.Client, host string) *Tester {
+	return &Tester{
+		sshClient: sshClient,
+		host:      host,
+	}
+}
+
+// Latency measures the round-trip time of a no-op remote command over the SSH connection
+func (t *Tester) Latency(samples int) (LatencyStats, error) {
+	// Warm up the connection so the handshake isn't counted
+	if _, err := t.sshClient.Execute("true"); err != nil {
+		return LatencyStats{}, err
+	}
+
+	stats := LatencyStats{Samples: samples}
+	var total time.Duration
+	for i := 0; i < samples; i++ {
+		start := time.Now()
+		if _, err := t.sshClient.Execute("true"); err != nil {
+			return LatencyStats{}, err
+		}
+		rtt := time.Since(start)
+		total += rtt
+		if stats.Min == 0 || rtt < stats.Min {
+			stats.Min = rtt
+		}
+		if rtt > stats.Max {
+			stats.Max = rtt
+		}
+	}
+	if samples > 0 {
+		stats.Avg = total / time.Duration(samples)
+	}
+	return stats, nil
+}
+
+// Upload streams size bytes of zeros to the DGX over the SSH channel
+func (t *Tester) Upload(size int64) (Result, error) {
+	start := time.Now()
+	src := io.LimitReader(zeroReader{}, size)
+	if err := t.sshClient.Stream("cat > /dev/null", src, nil, nil); err != nil {
+		return Result{}, fmt.Errorf("upload test failed: %w", err)
+	}
+	return Result{Direction: "upload", Bytes: size, Duration: time.Since(start)}, nil
+}
+
+// Download streams size bytes of zeros from the DGX over the SSH channel
+func (t *Tester) Download(size int64) (Result, error) {
+	counter := &countingWriter{}
+	start := time.Now()
+	if err := t.sshClient.Stream(fmt.Sprintf("head -c %d /dev/zero", size), nil, counter, nil); err != nil {
+		return Result{}, fmt.Errorf("download test failed: %w", err)
+	}
+	return Result{Direction: "download", Bytes: counter.n, Duration: time.Since(start)}, nil
+}
+
+// EnsureIperf3 installs iperf3 on the DGX if it is missing
+func (t *Tester) EnsureIperf3() error {
+	if _, err := t.sshClient.Execute("command -v iperf3"); err == nil {
+		return nil
+	}
+	fmt.Println("iperf3 not found on DGX, installing (you may be prompted for your sudo password)...")
+	if err := t.sshClient.RunInteractive("sudo apt-get install -y iperf3"); err != nil {
+		return fmt.Errorf("failed to install iperf3: %w", err)
+	}
+	return nil
+}
+
+// Iperf3 runs an iperf3 test against a one-shot server on the DGX.
+// When reverse is set the DGX sends and the workstation receives.
+func (t *Tester) Iperf3(duration time.Duration, reverse bool) (Result, error) {
+	if _, err := exec.LookPath("iperf3"); err != nil {
+		return Result{}, fmt.Errorf("iperf3 not found locally; install it to use --iperf")
+	}
+
+	if _, err := t.sshClient.Execute("iperf3 -s -1 -D"); err != nil {
+		return Result{}, fmt.Errorf("failed to start remote iperf3 server: %w", err)
+	}
+	// Give the daemon a moment to bind
+	time.Sleep(500 * time.Millisecond)
+
+	seconds := int(duration.Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+	args := []string{"-c", t.host, "-t", fmt.Sprintf("%d", seconds), "-J"}
+	direction := "upload (iperf3)"
+	if reverse {
+		args = append(args, "-R")
+		direction = "download (iperf3)"
+	}
+
+	output, err := exec.Command("iperf3", args...).Output()
+	if err != nil {
+		return Result{}, fmt.Errorf("iperf3 failed: %w", err)
+	}
+
+	return parseIperf3(output, direction)
+}
+
+// parseIperf3 reads the received byte count and duration from iperf3's JSON report
+func parseIperf3(output []byte, direction string) (Result, error) {
+	var report struct {
+		End struct {
+			SumReceived struct {
+				Bytes   float64 `json:"bytes"`
+				Seconds float64 `json:"seconds"`
+			} `json:"sum_received"`
+		} `json:"end"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(output, &report); err != nil {
+		return Result{}, fmt.Errorf("failed to parse iperf3 output: %w", err)
+	}
+	if report.Error != "" {
+		return Result{}, fmt.Errorf("iperf3: %s", strings.TrimSpace(report.Error))
+	}
+
+	return Result{
+		Direction: direction,
+		Bytes:     int64(report.End.SumReceived.Bytes),
+		Duration:  time.Duration(report.End.SumReceived.Seconds * float64(time.Second)),
+	}, nil
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,55 @@
+package netperf
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseIperf3(t *testing.T) {
+	output := []byte(`{"start": {}, "end": {"sum_sent": {"bytes": 9}, "sum_received": {"bytes": 1250000000, "seconds": 10.0}}}`)
+	r, err := parseIperf3(output, "upload (iperf3)")
+	if err != nil {
+		t.Fatalf("parseIperf3: %v", err)
+	}
+	if r.Direction != "upload (iperf3)" || r.Bytes != 1250000000 || r.Duration != 10*time.Second {
+		t.Fatalf("unexpected result: %+v", r)
+	}
+	if got := r.Mbps(); got != 1000 {
+		t.Fatalf("Mbps = %v, want 1000", got)
+	}
+
+	if _, err := parseIperf3([]byte(`{"error": "unable to connect to server: Connection refused\n"}`), "upload"); err == nil ||
+		err.Error() != "iperf3: unable to connect to server: Connection refused" {
+		t.Fatalf("expected the iperf3 error, got %v", err)
+	}
+	if _, err := parseIperf3([]byte("iperf3: not json"), "upload"); err == nil {
+		t.Fatalf("expected a parse error")
+	}
+}
+
+func TestResultRates(t *testing.T) {
+	r := Result{Bytes: 64 * 1024 * 1024, Duration: 2 * time.Second}
+	if got := r.MBps(); got != 32 {
+		t.Fatalf("MBps = %v, want 32", got)
+	}
+	if got := r.Mbps(); got != float64(64*1024*1024)*8/2/1e6 {
+		t.Fatalf("Mbps = %v", got)
+	}
+	if (Result{Bytes: 10}).MBps() != 0 || (Result{Bytes: 10}).Mbps() != 0 {
+		t.Fatalf("a zero duration should give a zero rate")
+	}
+}
+
+func TestZeroReaderAndCounter(t *testing.T) {
+	counter := &countingWriter{}
+	n, err := io.Copy(counter, io.LimitReader(zeroReader{}, 100000))
+	if err != nil || n != 100000 || counter.n != 100000 {
+		t.Fatalf("copied %d (%v), counted %d", n, err, counter.n)
+	}
+	data, _ := io.ReadAll(io.LimitReader(zeroReader{}, 16))
+	if strings.Trim(string(data), "\x00") != "" {
+		t.Fatalf("zeroReader produced non-zero bytes: %q", data)
+	}
+}
@@ -0,0 +1,226 @@
+// Package picker offers an interactive, fuzzy-searchable list for choosing one value in a
+// terminal, such as a model when a command was given none.
+package picker
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+
+	"github.com/weatherman/dgx-manager/internal/ui"
+)
+
+// maxRows is how many matches are shown at once
+const maxRows = 10
+
+var (
+	// ErrCanceled is returned when the user leaves the picker with Esc or Ctrl+C
+	ErrCanceled = errors.New("selection canceled")
+	// ErrNoTerminal is returned when stdin or stderr is not a terminal, or plain output
+	// rules out drawing the list, so callers can fall back to asking for the value on the
+	// command line
+	ErrNoTerminal = errors.New("not a terminal")
+)
+
+// Item is one choice. Note is shown dimmed beside the value, e.g. where it came from.
+type Item struct {
+	Value string
+	Note  string
+}
+
+// Pick shows prompt and items on the terminal and returns the chosen value. Typing narrows
+// the list by fuzzy match; Enter with no match returns the query itself, so a value that
+// is not listed can still be given.
+func Pick(prompt string, items []Item) (string, error) {
+	in, out := int(os.Stdin.Fd()), int(os.Stderr.Fd())
+	if !term.IsTerminal(in) || !ui.Live(os.Stderr) {
+		return "", ErrNoTerminal
+	}
+	width := 80
+	if w, _, err := term.GetSize(out); err == nil && w > 0 {
+		width = w
+	}
+	state, err := term.MakeRaw(in)
+	if err != nil {
+		return "", fmt.Errorf("failed to read from the terminal: %w", err)
+	}
+	defer term.Restore(in, state)
+	return run(os.Stdin, os.Stderr, prompt, items, width)
+}
+
+// run is the picker loop over a raw terminal's input and output
+func run(in io.Reader, out io.Writer, prompt string, items []Item, width int) (string, error) {
+	r := bufio.NewReader(in)
+	var query []rune
+	selected := 0
+	matches := Filter("", items)
+	for {
+		draw(out, prompt, string(query), matches, selected, width)
+		c, _, err := r.ReadRune()
+		if err != nil {
+			erase(out)
+			return "", ErrCanceled
+		}
+		switch c {
+		case '\r', '\n':
+			value := strings.TrimSpace(string(query))
+			if len(matches) > 0 {
+				value = matches[selected].Value
+			}
+			erase(out)
+			if value == "" {
+				return "", ErrCanceled
+			}
+			fmt.Fprintf(out, "%s%s\r\n", prompt, value)
+			return value, nil
+		case 3: // Ctrl+C
+			erase(out)
+			return "", ErrCanceled
+		case 0x1b:
+			// A lone Esc cancels; arrow keys arrive as Esc [ A and Esc [ B
+			if r.Buffered() == 0 {
+				erase(out)
+				return "", ErrCanceled
+			}
+			seq := make([]byte, 2)
+			if _, err := io.ReadFull(r, seq); err != nil || seq[0] != '[' {
+				continue
+			}
+			switch seq[1] {
+			case 'A':
+				selected = max(selected-1, 0)
+			case 'B':
+				selected = min(selected+1, max(len(matches)-1, 0))
+			}
+			continue
+		case 16: // Ctrl+P
+			selected = max(selected-1, 0)
+			continue
+		case 14: // Ctrl+N
+			selected = min(selected+1, max(len(matches)-1, 0))
+			continue
+		case 127, 8: // Backspace
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+			}
+		case 21: // Ctrl+U
+			query = query[:0]
+		default:
+			if c < ' ' {
+				continue
+			}
+			query = append(query, c)
+		}
+		matches = Filter(string(query), items)
+		selected = 0
+	}
+}
+
+// draw redraws the prompt line and the visible matches below it, leaving the cursor after
+// the query
+func draw(out io.Writer, prompt, query string, matches []Item, selected, width int) {
+	var b strings.Builder
+	b.WriteString("\r\x1b[J")
+	b.WriteString(prompt)
+	b.WriteString(query)
+
+	start := 0
+	if selected >= maxRows {
+		start = selected - maxRows + 1
+	}
+	rows := 0
+	for i := start; i < len(matches) && rows < maxRows; i++ {
+		marker := "  "
+		if i == selected {
+			marker = "> "
+		}
+		line := ui.Truncate(marker+matches[i].Value, width-1)
+		b.WriteString("\r\n")
+		if i == selected {
+			b.WriteString("\x1b[7m" + line + "\x1b[0m")
+		} else {
+			b.WriteString(line)
+		}
+		if note := matches[i].Note; note != "" && utf8.RuneCountInString(line)+len(note)+2 < width {
+			b.WriteString("  \x1b[2m" + note + "\x1b[0m")
+		}
+		rows++
+	}
+	if len(matches) == 0 && query != "" {
+		b.WriteString("\r\n  \x1b[2m(no match; Enter uses what you typed)\x1b[0m")
+		rows++
+	}
+	if rows > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", rows)
+	}
+	b.WriteString("\r")
+	if col := utf8.RuneCountInString(prompt + query); col > 0 {
+		fmt.Fprintf(&b, "\x1b[%dC", col)
+	}
+	io.WriteString(out, b.String())
+}
+
+// erase removes the picker from the terminal
+func erase(out io.Writer) {
+	io.WriteString(out, "\r\x1b[J")
+}
+
+// Filter returns the items whose value contains the letters of query in order, best match
+// first. Matches at the start of a path or tag segment and runs of consecutive letters rank
+// higher; ties keep the items' order. An empty query returns every item.
+func Filter(query string, items []Item) []Item {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return items
+	}
+	type scored struct {
+		item  Item
+		score int
+	}
+	var matches []scored
+	for _, item := range items {
+		if s, ok := score(query, strings.ToLower(item.Value)); ok {
+			matches = append(matches, scored{item, s})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+	result := make([]Item, len(matches))
+	for i, m := range matches {
+		result[i] = m.item
+	}
+	return result
+}
+
+// score matches query against value as a subsequence, greedily from the left
+func score(query, value string) (int, bool) {
+	q := []rune(query)
+	v := []rune(value)
+	total, qi, last := 0, 0, -2
+	for vi := 0; vi < len(v) && qi < len(q); vi++ {
+		if v[vi] != q[qi] {
+			continue
+		}
+		points := 1
+		if vi == last+1 {
+			points += 4
+		}
+		if vi == 0 || strings.ContainsRune("/:-_. ", v[vi-1]) {
+			points += 3
+		}
+		total += points
+		last = vi
+		qi++
+	}
+	if qi < len(q) {
+		return 0, false
+	}
+	// Prefer shorter values when the letters match equally well
+	return total*100 - len(v), true
+}
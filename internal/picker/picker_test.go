@@ -0,0 +1,65 @@
+package picker
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+var models = []Item{
+	{Value: "ai/smollm2:360M-Q4_K_M"},
+	{Value: "ai/llama3.2:3B-Q4_K_M"},
+	{Value: "meta-llama/Llama-3.1-8B-Instruct"},
+	{Value: "ai/qwen2.5:7B-Q4_K_M"},
+}
+
+func values(items []Item) []string {
+	var v []string
+	for _, item := range items {
+		v = append(v, item.Value)
+	}
+	return v
+}
+
+func TestFilter(t *testing.T) {
+	if got := Filter("", models); len(got) != len(models) {
+		t.Fatalf("empty query: got %v", values(got))
+	}
+	if got := values(Filter("qwen", models)); len(got) != 1 || got[0] != "ai/qwen2.5:7B-Q4_K_M" {
+		t.Fatalf("qwen: got %v", got)
+	}
+	// Both llama models match; the one with "llama" after a segment boundary and fewer
+	// gaps ranks first
+	got := values(Filter("llama", models))
+	if len(got) != 2 || got[0] != "ai/llama3.2:3B-Q4_K_M" {
+		t.Fatalf("llama: got %v", got)
+	}
+	if got := values(Filter("L3I", models)); len(got) != 1 || got[0] != "meta-llama/Llama-3.1-8B-Instruct" {
+		t.Fatalf("L3I: got %v", got)
+	}
+	if got := Filter("mistral", models); len(got) != 0 {
+		t.Fatalf("mistral: got %v", values(got))
+	}
+}
+
+func TestRun(t *testing.T) {
+	for _, tc := range []struct {
+		name, input, want string
+		err               error
+	}{
+		{"first", "\r", "ai/smollm2:360M-Q4_K_M", nil},
+		{"arrows", "\x1b[B\x1b[B\x1b[A\r", "ai/llama3.2:3B-Q4_K_M", nil},
+		{"ctrl-n", "\x0e\x0e\x0e\r", "ai/qwen2.5:7B-Q4_K_M", nil},
+		{"query", "qw\r", "ai/qwen2.5:7B-Q4_K_M", nil},
+		{"backspace", "qx\x7f\x7fsmol\r", "ai/smollm2:360M-Q4_K_M", nil},
+		{"unlisted", "ai/mistral\r", "ai/mistral", nil},
+		{"esc", "\x1b", "", ErrCanceled},
+		{"ctrl-c", "ll\x03", "", ErrCanceled},
+		{"eof", "ll", "", ErrCanceled},
+	} {
+		got, err := run(strings.NewReader(tc.input), io.Discard, "model: ", models, 80)
+		if got != tc.want || err != tc.err {
+			t.Fatalf("%s: got %q, %v; want %q, %v", tc.name, got, err, tc.want, tc.err)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package pins
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// Pinned components
+const (
+	ModelPlugin  = "docker-model-plugin"
+	Runner       = "model runner"
+	DriverBranch = "driver branch"
+)
+
+// RunnerContainer is the container Docker Model Runner's controller runs in
+const RunnerContainer = "docker-model-runner"
+
+// runnerVersionEnv makes 'docker model install-runner' pull this image version
+const runnerVersionEnv = "MODEL_RUNNER_CONTROLLER_VERSION"
+
+var (
+	// packageVersionPattern is what Debian and RPM allow in a package version
+	packageVersionPattern = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z.+~:_-]*$`)
+	// imageTagPattern is what Docker allows in an image tag
+	imageTagPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+	branchPattern   = regexp.MustCompile(`^[0-9]+$`)
+	// CommitPattern is a full git commit hash, which GitHub fetches by itself
+	CommitPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+)
+
+// Executor runs a remote command; *ssh.Client implements it
+type Executor interface {
+	ExecuteContext(ctx context.Context, command string) (string, error)
+}
+
+// Validate reports the first pin that is not a well-formed version
+func Validate(p *types.VersionPins) error {
+	if p == nil {
+		return nil
+	}
+	if p.ModelPlugin != "" && !packageVersionPattern.MatchString(p.ModelPlugin) {
+		return fmt.Errorf("pins.model_plugin %q is not a package version", p.ModelPlugin)
+	}
+	if p.Runner != "" && !imageTagPattern.MatchString(p.Runner) {
+		return fmt.Errorf("pins.runner %q is not an image tag", p.Runner)
+	}
+	if p.DriverBranch != "" && !branchPattern.MatchString(p.DriverBranch) {
+		return fmt.Errorf("pins.driver_branch %q is not a branch number such as 580", p.DriverBranch)
+	}
+	if p.GPUBurn != "" && !CommitPattern.MatchString(p.GPUBurn) {
+		return fmt.Errorf("pins.gpu_burn %q is not a full 40-character commit hash", p.GPUBurn)
+	}
+	return nil
+}
+
+// Merge layers the pins set in override over base, for a profile's pins on top of the
+// top-level ones
+func Merge(base, override *types.VersionPins) *types.VersionPins {
+	if override == nil {
+		return base
+	}
+	if base == nil {
+		return override
+	}
+	merged := *base
+	if override.ModelPlugin != "" {
+		merged.ModelPlugin = override.ModelPlugin
+	}
+	if override.Runner != "" {
+		merged.Runner = override.Runner
+	}
+	if override.DriverBranch != "" {
+		merged.DriverBranch = override.DriverBranch
+	}
+	if override.GPUBurn != "" {
+		merged.GPUBurn = override.GPUBurn
+	}
+	return &merged
+}
+
+// Describe lists the pins as key=value pairs, for dgx config show
+func Describe(p *types.VersionPins) string {
+	if p == nil {
+		return ""
+	}
+	var parts []string
+	for _, kv := range [][2]string{{"model_plugin", p.ModelPlugin}, {"runner", p.Runner}, {"driver_branch", p.DriverBranch}, {"gpu_burn", p.GPUBurn}} {
+		if kv[1] != "" {
+			parts = append(parts, kv[0]+"="+kv[1])
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// RunnerEnv returns the environment assignment that makes install-runner use the pinned
+// image, with a trailing space, or "" when the runner is not pinned
+func RunnerEnv(p *types.VersionPins) string {
+	if p == nil || p.Runner == "" {
+		return ""
+	}
+	return runnerVersionEnv + "=" + ssh.ShellQuote(p.Runner) + " "
+}
+
+// OnBranch reports whether an NVIDIA driver package belongs to branch: versioned packages
+// carry it in their name, e.g. nvidia-driver-580-open or libnvidia-compute-580
+func OnBranch(pkg, branch string) bool {
+	for _, part := range strings.Split(pkg, "-") {
+		if part == branch {
+			return true
+		}
+	}
+	return false
+}
+
+// Status is one pinned component as installed on the DGX
+type Status struct {
+	Component string
+	Pinned    string
+	Installed string // "" when not installed
+	Drift     bool
+}
+
+// installedScript prints the installed version of each pinned component as key=value lines
+const installedScript = `echo "plugin=$(dpkg-query -W -f='${Version}' ` + ModelPlugin + ` 2>/dev/null || rpm -q --qf '%{VERSION}-%{RELEASE}' ` + ModelPlugin + ` 2>/dev/null)"
+echo "runner=$(docker inspect --format '{{.Config.Image}}' ` + RunnerContainer + ` 2>/dev/null)"
+echo "driver=$(nvidia-smi --query-gpu=driver_version --format=csv,noheader 2>/dev/null | head -n 1)"
+true`
+
+// Check reads the installed versions of the pinned components
+func Check(ctx context.Context, exec Executor, p *types.VersionPins) ([]Status, error) {
+	if p == nil {
+		return nil, nil
+	}
+	output, err := exec.ExecuteContext(ctx, installedScript)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read installed versions: %w", err)
+	}
+	return compare(p, output), nil
+}
+
+// compare matches installedScript output against the pins
+func compare(p *types.VersionPins, output string) []Status {
+	installed := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+			installed[key] = strings.TrimSpace(value)
+		}
+	}
+
+	var statuses []Status
+	if p.ModelPlugin != "" {
+		v := installed["plugin"]
+		statuses = append(statuses, Status{Component: ModelPlugin, Pinned: p.ModelPlugin, Installed: v, Drift: v != p.ModelPlugin})
+	}
+	if p.Runner != "" {
+		image := installed["runner"]
+		tag := ""
+		if i := strings.LastIndex(image, ":"); i >= 0 && !strings.Contains(image[i:], "/") {
+			tag = image[i+1:]
+		}
+		// The image tag adds a variant to the version, e.g. v0.1.44-cuda
+		statuses = append(statuses, Status{Component: Runner, Pinned: p.Runner, Installed: tag,
+			Drift: tag != p.Runner && !strings.HasPrefix(tag, p.Runner+"-")})
+	}
+	if p.DriverBranch != "" {
+		v := installed["driver"]
+		branch, _, _ := strings.Cut(v, ".")
+		statuses = append(statuses, Status{Component: DriverBranch, Pinned: p.DriverBranch, Installed: v, Drift: branch != p.DriverBranch})
+	}
+	return statuses
+}
@@ -0,0 +1,80 @@
+package pins
+
+import (
+	"testing"
+
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+func TestValidate(t *testing.T) {
+	if err := Validate(&types.VersionPins{ModelPlugin: "0.1.44-1~ubuntu.24.04~noble", Runner: "v0.1.44", DriverBranch: "580"}); err != nil {
+		t.Fatalf("valid pins: %v", err)
+	}
+	bad := []types.VersionPins{
+		{ModelPlugin: "1.0; reboot"},
+		{Runner: "v1 latest"},
+		{DriverBranch: "r580"},
+	}
+	for _, p := range bad {
+		if err := Validate(&p); err == nil {
+			t.Fatalf("Validate(%+v): expected an error", p)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	base := &types.VersionPins{ModelPlugin: "0.1.40", DriverBranch: "570"}
+	merged := Merge(base, &types.VersionPins{DriverBranch: "580"})
+	if merged.ModelPlugin != "0.1.40" || merged.DriverBranch != "580" || base.DriverBranch != "570" {
+		t.Fatalf("merged = %+v, base = %+v", merged, base)
+	}
+	if Merge(nil, nil) != nil || Merge(base, nil) != base {
+		t.Fatalf("Merge without an override changed the base")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	p := &types.VersionPins{ModelPlugin: "0.1.44", Runner: "v0.1.44", DriverBranch: "580"}
+	statuses := compare(p, "plugin=0.1.44\nrunner=docker/model-runner:v0.1.44-cuda\ndriver=580.95.05\n")
+	if len(statuses) != 3 {
+		t.Fatalf("statuses = %+v", statuses)
+	}
+	for _, s := range statuses {
+		if s.Drift {
+			t.Fatalf("%s drifted: %+v", s.Component, s)
+		}
+	}
+
+	statuses = compare(p, "plugin=0.1.46\nrunner=docker/model-runner:latest\ndriver=\n")
+	for _, s := range statuses {
+		if !s.Drift {
+			t.Fatalf("%s did not drift: %+v", s.Component, s)
+		}
+	}
+	if statuses[1].Installed != "latest" || statuses[2].Installed != "" {
+		t.Fatalf("statuses = %+v", statuses)
+	}
+}
+
+func TestOnBranch(t *testing.T) {
+	for pkg, want := range map[string]bool{
+		"nvidia-driver-580-open": true,
+		"libnvidia-compute-580":  true,
+		"nvidia-driver-590-open": false,
+		"cuda-drivers":           false,
+		"nvidia-utils-5800":      false,
+	} {
+		if got := OnBranch(pkg, "580"); got != want {
+			t.Fatalf("OnBranch(%s) = %v, want %v", pkg, got, want)
+		}
+	}
+}
+
+func TestRunnerEnv(t *testing.T) {
+	if env := RunnerEnv(&types.VersionPins{Runner: "v0.1.44"}); env != "MODEL_RUNNER_CONTROLLER_VERSION='v0.1.44' " {
+		t.Fatalf("RunnerEnv = %q", env)
+	}
+	if env := RunnerEnv(nil); env != "" {
+		t.Fatalf("RunnerEnv(nil) = %q", env)
+	}
+}
@@ -0,0 +1,46 @@
+package playbook
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/changes"
+	"github.com/weatherman/dgx-manager/internal/state"
+)
+
+// trackChanges runs an update and records the package and component versions it changed,
+// for 'dgx changes'. Failing to read the versions only warns: the update still runs.
+func (m *Manager) trackChanges(playbook string, update func() error) error {
+	ctx := context.Background()
+	before, err := changes.Collect(ctx, m.sshClient)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v (the changes will not be recorded)\n", err)
+		return update()
+	}
+
+	record := changes.Record{Host: m.sshClient.Host(), Playbook: playbook, Started: time.Now()}
+	updateErr := update()
+	record.Finished = time.Now()
+	if updateErr != nil {
+		record.Error = updateErr.Error()
+	}
+
+	after, err := changes.Collect(ctx, m.sshClient)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v (the changes will not be recorded)\n", err)
+		return updateErr
+	}
+	record.Changes = changes.Diff(before, after)
+	store, err := state.DefaultStore()
+	if err == nil {
+		err = changes.Append(store, record)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record the changes: %v\n", err)
+	} else {
+		fmt.Printf("\n%d versions changed; see 'dgx changes --last'\n", len(record.Changes))
+	}
+	return updateErr
+}
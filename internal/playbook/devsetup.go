@@ -0,0 +1,237 @@
+package playbook
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/ui"
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// DefaultDevTools are installed when the config declares no devsetup tools
+var DefaultDevTools = []string{"git", "tmux", "htop", "nvtop", "uv", "build-essential"}
+
+// dotfilesDir is where the dotfiles repository is checked out on the DGX
+const dotfilesDir = "~/.dotfiles"
+
+var (
+	// Tools become apt-get arguments, so only package-name characters are allowed
+	devToolPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9+.-]*$`)
+	gitRefPattern  = regexp.MustCompile(`^[A-Za-z0-9._/-]+$`)
+)
+
+// SetDevSetup sets the tool list and dotfiles repository used by the devsetup playbook
+func (m *Manager) SetDevSetup(cfg *types.DevSetupConfig) {
+	m.devSetup = cfg
+}
+
+// runDevSetup handles developer tool bootstrap commands
+func (m *Manager) runDevSetup(args []string) error {
+	command := "install"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "--") {
+		command, args = args[0], args[1:]
+	}
+
+	args, resume := removeFlag(args, "--resume")
+	args, yes := removeFlag(args, "--yes")
+	args, noDotfiles := removeFlag(args, "--no-dotfiles")
+	args, tools := flagValue(args, "--tools")
+	args, repo := flagValue(args, "--dotfiles")
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+	}
+
+	cfg := types.DevSetupConfig{}
+	if m.devSetup != nil {
+		cfg = *m.devSetup
+	}
+	if tools != "" {
+		cfg.Tools = strings.Split(tools, ",")
+	}
+	if len(cfg.Tools) == 0 {
+		cfg.Tools = DefaultDevTools
+	}
+	if repo != "" {
+		cfg.DotfilesRepo = repo
+	}
+	if noDotfiles {
+		cfg.DotfilesRepo = ""
+	}
+
+	switch command {
+	case "install":
+		return m.devSetupInstall(cfg, resume, yes)
+	case "status", "list":
+		return m.devSetupStatus(cfg)
+	default:
+		return fmt.Errorf("unknown devsetup command: %s. Usage: dgx run devsetup [install|status] [--tools a,b] [--dotfiles URL|--no-dotfiles] [--resume] [--yes]", command)
+	}
+}
+
+// devSetupSteps builds the idempotent install steps: apt packages in one step, then uv,
+// then the dotfiles checkout
+func devSetupSteps(cfg types.DevSetupConfig) ([]Step, error) {
+	var packages []string
+	uv := false
+	for _, tool := range cfg.Tools {
+		tool = strings.TrimSpace(tool)
+		switch {
+		case tool == "":
+			continue
+		case tool == "uv":
+			uv = true
+		case devToolPattern.MatchString(tool):
+			packages = append(packages, tool)
+		default:
+			return nil, fmt.Errorf("invalid tool name %q", tool)
+		}
+	}
+
+	var steps []Step
+	if len(packages) > 0 {
+		steps = append(steps, Step{
+			Name:        "packages",
+			Description: "Packages: " + strings.Join(packages, ", "),
+			Command: fmt.Sprintf(`set -euo pipefail
+missing=""
+for p in %s; do
+  dpkg -s "$p" >/dev/null 2>&1 || missing="$missing $p"
+done
+if [ -n "$missing" ]; then
+  sudo apt-get update -qq
+  sudo env DEBIAN_FRONTEND=noninteractive apt-get install -y -qq $missing
+  echo "Installed:$missing"
+fi`, strings.Join(packages, " ")),
+		})
+	}
+	if uv {
+		steps = append(steps, Step{
+			Name:        "uv",
+			Description: "uv",
+			Command: `set -euo pipefail
+export PATH="$HOME/.local/bin:$PATH"
+if ! command -v uv >/dev/null 2>&1; then
+  curl -LsSf https://astral.sh/uv/install.sh | sh
+fi`,
+		})
+	}
+
+	if cfg.DotfilesRepo != "" {
+		if strings.HasPrefix(cfg.DotfilesRepo, "-") || strings.ContainsAny(cfg.DotfilesRepo, " \t\n") {
+			return nil, fmt.Errorf("invalid dotfiles repository %q", cfg.DotfilesRepo)
+		}
+		branch := ""
+		if cfg.DotfilesBranch != "" {
+			if !gitRefPattern.MatchString(cfg.DotfilesBranch) || strings.HasPrefix(cfg.DotfilesBranch, "-") {
+				return nil, fmt.Errorf("invalid dotfiles branch %q", cfg.DotfilesBranch)
+			}
+			branch = " --branch " + ssh.ShellQuote(cfg.DotfilesBranch)
+		}
+		install := `if [ -x ./install.sh ]; then ./install.sh; elif [ -x ./bootstrap.sh ]; then ./bootstrap.sh; fi`
+		if cfg.DotfilesInstall != "" {
+			install = cfg.DotfilesInstall
+		}
+		steps = append(steps,
+			Step{
+				Name:        "dotfiles",
+				Description: "Dotfiles from " + cfg.DotfilesRepo,
+				Command: fmt.Sprintf(`set -euo pipefail
+if [ -d %[1]s/.git ]; then
+  git -C %[1]s pull --ff-only --quiet
+else
+  GIT_TERMINAL_PROMPT=0 git clone --quiet%[2]s %[3]s %[1]s
+fi`, dotfilesDir, branch, ssh.ShellQuote(cfg.DotfilesRepo)),
+			},
+			Step{
+				Name:        "dotfiles-install",
+				Description: "Dotfiles install script",
+				Command:     fmt.Sprintf("cd %s && %s", dotfilesDir, install),
+			},
+		)
+	}
+	return steps, nil
+}
+
+func (m *Manager) devSetupInstall(cfg types.DevSetupConfig, resume, yes bool) error {
+	steps, err := devSetupSteps(cfg)
+	if err != nil {
+		return err
+	}
+	if len(steps) == 0 {
+		fmt.Println("Nothing to install.")
+		return nil
+	}
+
+	fmt.Printf("Setting up developer tools on the DGX: %s\n", strings.Join(cfg.Tools, ", "))
+	if cfg.DotfilesRepo != "" {
+		fmt.Printf("Dotfiles: %s -> %s\n", cfg.DotfilesRepo, dotfilesDir)
+	}
+	if err := m.confirmMutating("Continue?", yes); err != nil {
+		return err
+	}
+
+	if err := m.runSteps("devsetup", steps, resume); err != nil {
+		return fmt.Errorf("failed to set up developer tools: %w", err)
+	}
+	fmt.Println("Developer tools installed. Check with: dgx run devsetup status")
+	return nil
+}
+
+// devSetupStatusScript prints "tool|version" for each tool, with an empty version when
+// the tool is missing
+const devSetupStatusScript = `export PATH="$HOME/.local/bin:$PATH"
+for t in %s; do
+  v=""
+  if [ "$t" = uv ]; then
+    command -v uv >/dev/null 2>&1 && v=$(uv --version 2>/dev/null | awk '{print $2}')
+  else
+    v=$(dpkg-query -W -f='${Status} ${Version}' "$t" 2>/dev/null | awk '$3 == "installed" {print $4}')
+  fi
+  echo "$t|$v"
+done
+if [ -d %s/.git ]; then echo "@dotfiles|$(git -C %s log -1 --format='%%h %%cr' 2>/dev/null)"; fi`
+
+func (m *Manager) devSetupStatus(cfg types.DevSetupConfig) error {
+	var tools []string
+	for _, tool := range cfg.Tools {
+		tool = strings.TrimSpace(tool)
+		if tool == "uv" || devToolPattern.MatchString(tool) {
+			tools = append(tools, tool)
+		}
+	}
+	output, err := m.sshClient.Execute(fmt.Sprintf(devSetupStatusScript, strings.Join(tools, " "), dotfilesDir, dotfilesDir))
+	if err != nil {
+		return fmt.Errorf("failed to check developer tools: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TOOL\tSTATUS\tVERSION")
+	dotfiles := ""
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		name, version, ok := strings.Cut(line, "|")
+		if !ok {
+			continue
+		}
+		if name == "@dotfiles" {
+			dotfiles = version
+			continue
+		}
+		status := "installed"
+		if version == "" {
+			status = "missing"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", name, status, ui.OrDash(version))
+	}
+	w.Flush()
+
+	if dotfiles != "" {
+		fmt.Printf("\nDotfiles: %s (last commit %s)\n", dotfilesDir, dotfiles)
+	} else if cfg.DotfilesRepo != "" {
+		fmt.Printf("\nDotfiles: not cloned yet (%s)\n", cfg.DotfilesRepo)
+	}
+	return nil
+}
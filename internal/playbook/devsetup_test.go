@@ -0,0 +1,36 @@
+package playbook
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+func TestDevSetupSteps(t *testing.T) {
+	steps, err := devSetupSteps(types.DevSetupConfig{
+		Tools:        []string{"git", " tmux", "uv", ""},
+		DotfilesRepo: "https://example.com/me/dotfiles.git",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var names []string
+	for _, s := range steps {
+		names = append(names, s.Name)
+	}
+	if got := strings.Join(names, ","); got != "packages,uv,dotfiles,dotfiles-install" {
+		t.Fatalf("steps = %s", got)
+	}
+	if !strings.Contains(steps[0].Command, "for p in git tmux;") {
+		t.Fatalf("uv should not be installed with apt: %s", steps[0].Command)
+	}
+
+	if _, err := devSetupSteps(types.DevSetupConfig{Tools: []string{"git; reboot"}}); err == nil {
+		t.Fatal("expected an invalid tool name to be rejected")
+	}
+	if _, err := devSetupSteps(types.DevSetupConfig{DotfilesRepo: "--upload-pack=evil"}); err == nil {
+		t.Fatal("expected an option-like repository to be rejected")
+	}
+}
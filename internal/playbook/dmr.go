@@ -2,15 +2,20 @@ package playbook
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/pins"
 	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/ui"
+	"github.com/weatherman/dgx-manager/pkg/types"
 )
 
 // runDMR handles Docker Model Runner helper commands
 func (m *Manager) runDMR(args []string) error {
 	if len(args) == 0 {
-		return fmt.Errorf("dmr command required. Usage: dgx run dmr <setup|install|update|status|logs|list|pull|run|uninstall>")
+		return fmt.Errorf("dmr command required. Usage: dgx run dmr <setup|install|update|status|logs|list|ps|pull|run|unload|uninstall|rollback|api>")
 	}
 
 	command := args[0]
@@ -18,17 +23,24 @@ func (m *Manager) runDMR(args []string) error {
 
 	switch command {
 	case "setup":
-		return m.dmrSetup()
+		return m.dmrSetup(hasFlag(rest, "--resume"))
 	case "install":
 		return m.dmrInstallRunner()
 	case "update":
-		return m.dmrUpdateRunner()
+		return m.trackChanges("dmr update", m.dmrUpdateRunner)
 	case "status":
 		return m.dmrStatus()
 	case "logs":
 		return m.dmrLogs(rest)
 	case "list":
 		return m.dmrList(rest)
+	case "ps":
+		return m.dmrPs()
+	case "unload":
+		if len(rest) == 0 {
+			return fmt.Errorf("model reference required. Usage: dgx run dmr unload <model|--all>")
+		}
+		return m.dmrUnload(rest[0])
 	case "pull":
 		if len(rest) == 0 {
 			return fmt.Errorf("model reference required. Usage: dgx run dmr pull <model> [flags]")
@@ -45,27 +57,37 @@ func (m *Manager) runDMR(args []string) error {
 		}
 		return m.dmrRun(model, prompt)
 	case "uninstall":
-		return m.dmrUninstall()
+		return m.dmrUninstall(hasFlag(rest, "--yes"))
+	case "rollback":
+		return m.rollback("dmr")
+	case "api":
+		return m.dmrAPI(rest)
 	default:
 		return fmt.Errorf("unknown dmr command: %s", command)
 	}
 }
 
-func (m *Manager) dmrSetup() error {
-	fmt.Println("Installing Docker Model Runner prerequisites (Docker Engine, plugin, GPU runtime)...")
-	fmt.Println("Warning: This may download and run scripts from https://get.docker.com with sudo.")
-	fmt.Print("Continue? [Y/n]: ")
-	var confirm string
-	fmt.Scanln(&confirm)
-	if confirm != "" && strings.ToLower(confirm) != "y" {
-		fmt.Println("Setup cancelled.")
-		return nil
-	}
+// dmrTouchedFiles are the system files dmr setup may create or modify; they are
+// snapshotted before the first setup so 'dgx run dmr rollback' can restore them
+var dmrTouchedFiles = []string{
+	"/etc/docker/daemon.json",
+	"/etc/nvidia-container-runtime/config.toml",
+	"/etc/apt/sources.list.d/docker.list",
+	"/etc/apt/keyrings/docker.asc",
+}
 
-	script := `set -euo pipefail
-if ! command -v docker >/dev/null 2>&1; then
-  curl -fsSL https://get.docker.com | sudo sh
-fi
+// SetPins sets the component versions the dmr and nvidia playbooks install
+func (m *Manager) SetPins(p *types.VersionPins) {
+	m.pins = p
+}
+
+// modelPluginStep installs docker-model-plugin. A pinned version is installed exactly, even
+// as a downgrade, and held so that apt upgrades leave it alone.
+func modelPluginStep(p *types.VersionPins) Step {
+	step := Step{
+		Name:        "model-plugin",
+		Description: "docker-model-plugin",
+		Command: `set -euo pipefail
 if command -v apt-get >/dev/null 2>&1; then
   sudo apt-get update
   if ! dpkg -s docker-model-plugin >/dev/null 2>&1; then
@@ -73,52 +95,126 @@ if command -v apt-get >/dev/null 2>&1; then
   fi
 elif command -v dnf >/dev/null 2>&1; then
   sudo dnf install -y docker-model-plugin
-fi
+fi`,
+	}
+	if p != nil && p.ModelPlugin != "" {
+		v := ssh.ShellQuote(p.ModelPlugin)
+		step.Description = "docker-model-plugin " + p.ModelPlugin + " (pinned)"
+		step.Command = fmt.Sprintf(`set -euo pipefail
+if command -v apt-get >/dev/null 2>&1; then
+  sudo apt-get update
+  sudo apt-mark unhold docker-model-plugin >/dev/null 2>&1 || true
+  sudo apt-get install -y --allow-downgrades docker-model-plugin=%s
+  sudo apt-mark hold docker-model-plugin >/dev/null
+elif command -v dnf >/dev/null 2>&1; then
+  sudo dnf install -y --allowerasing docker-model-plugin-%s
+fi`, v, v)
+	}
+	return step
+}
+
+// dmrSetupSteps are the prerequisite installs for Docker Model Runner. Each step is safe to
+// rerun, so a failed setup can resume where it stopped.
+func dmrSetupSteps(p *types.VersionPins) []Step {
+	return []Step{
+		dmrSetupEngine,
+		modelPluginStep(p),
+		dmrSetupToolkit,
+		dmrSetupRuntime,
+		dmrSetupGroup,
+	}
+}
+
+var dmrSetupEngine = Step{
+	Name:        "docker-engine",
+	Description: "Docker Engine",
+	Command: `set -euo pipefail
+if ! command -v docker >/dev/null 2>&1; then
+  curl -fsSL https://get.docker.com | sudo sh
+fi`,
+}
+
+var dmrSetupToolkit = Step{
+	Name:        "container-toolkit",
+	Description: "NVIDIA Container Toolkit",
+	Command: `set -euo pipefail
 if command -v apt-get >/dev/null 2>&1; then
   if ! dpkg -s nvidia-container-toolkit >/dev/null 2>&1; then
     sudo apt-get install -y nvidia-container-toolkit
   fi
 elif command -v dnf >/dev/null 2>&1; then
   sudo dnf install -y nvidia-container-toolkit
-fi
-if command -v nvidia-ctk >/dev/null 2>&1; then
+fi`,
+}
+
+var dmrSetupRuntime = Step{
+	Name:        "gpu-runtime",
+	Description: "Docker GPU runtime",
+	Command: `if command -v nvidia-ctk >/dev/null 2>&1; then
   sudo nvidia-ctk runtime configure --runtime=docker >/dev/null 2>&1 || true
   sudo systemctl restart docker >/dev/null 2>&1 || true
-fi
-sudo usermod -aG docker $(whoami) >/dev/null 2>&1 || true
-`
+fi`,
+}
+
+var dmrSetupGroup = Step{
+	Name:        "docker-group",
+	Description: "docker group membership",
+	Command:     `sudo usermod -aG docker $(whoami) >/dev/null 2>&1 || true`,
+}
 
-	output, err := m.sshClient.Execute(script)
+func (m *Manager) dmrSetup(resume bool) error {
+	fmt.Println("Installing Docker Model Runner prerequisites (Docker Engine, plugin, GPU runtime)...")
+	fmt.Println("Warning: This may download and run scripts from https://get.docker.com with sudo.")
+	fmt.Print("Continue? [Y/n]: ")
+	var confirm string
+	fmt.Scanln(&confirm)
+	if confirm != "" && strings.ToLower(confirm) != "y" {
+		fmt.Println("Setup cancelled.")
+		return exitcode.ErrAborted
+	}
+
+	if err := m.ensureSnapshot("dmr", dmrTouchedFiles); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v (rollback will not be available)\n", err)
+	}
+
+	err := m.runSteps("dmr setup", dmrSetupSteps(m.pins), resume)
+	m.recordInstalled("dmr")
 	if err != nil {
 		return fmt.Errorf("failed to set up Docker Model Runner prerequisites: %w", err)
 	}
 
-	if strings.TrimSpace(output) != "" {
-		fmt.Println(output)
-	}
 	fmt.Println("Prerequisites installed. Log out/in to apply docker group membership if prompted.")
 	return nil
 }
 
+// runnerPinNote tells which runner version install-runner will pull
+func (m *Manager) runnerPinNote() {
+	if m.pins != nil && m.pins.Runner != "" {
+		fmt.Printf("Runner pinned to %s\n", m.pins.Runner)
+	}
+}
+
 func (m *Manager) dmrInstallRunner() error {
 	fmt.Println("Installing Docker Model Runner controller container...")
-	output, err := m.sshClient.Execute("docker model install-runner --gpu auto")
+	m.runnerPinNote()
+	output, err := m.execStep(pins.RunnerEnv(m.pins) + "docker model install-runner --gpu auto")
 	if err != nil {
 		return fmt.Errorf("failed to install Docker Model Runner: %w", err)
 	}
-	fmt.Println(output)
+	printOutput(output)
 	fmt.Println("Docker Model Runner installed. Use 'dgx run dmr status' to verify.")
 	return nil
 }
 
 func (m *Manager) dmrUpdateRunner() error {
 	fmt.Println("Updating Docker Model Runner...")
-	cmd := "docker model uninstall-runner --images && docker model install-runner --gpu auto"
+	m.runnerPinNote()
+	cmd := "docker model uninstall-runner --images && " + pins.RunnerEnv(m.pins) + "docker model install-runner --gpu auto"
 	output, err := m.sshClient.Execute(cmd)
 	if err != nil {
 		return fmt.Errorf("failed to update Docker Model Runner: %w", err)
 	}
-	fmt.Println(output)
+	printOutput(output)
 	return nil
 }
 
@@ -128,35 +224,96 @@ func (m *Manager) dmrStatus() error {
 	if err != nil {
 		return fmt.Errorf("failed to get Docker Model Runner status: %w", err)
 	}
-	fmt.Println(output)
+	printOutput(output)
 	return nil
 }
 
-func (m *Manager) dmrLogs(args []string) error {
-	cmd := "docker model logs"
-	if len(args) == 0 {
-		cmd += " --tail 200"
-	} else {
-		cmd += " " + strings.Join(args, " ")
+// LoadedModel is a model currently resident in the Docker Model Runner
+type LoadedModel struct {
+	Name     string
+	Backend  string
+	Mode     string
+	LastUsed string
+	Until    string
+}
+
+// parseLoadedModels converts 'docker model ps' output into typed rows
+func parseLoadedModels(output string) []LoadedModel {
+	var models []LoadedModel
+	for _, row := range parseTable(output) {
+		name := firstField(row, "MODEL NAME", "MODEL", "NAME")
+		if name == "" {
+			continue
+		}
+		models = append(models, LoadedModel{
+			Name:     name,
+			Backend:  firstField(row, "BACKEND"),
+			Mode:     firstField(row, "MODE"),
+			LastUsed: firstField(row, "LAST USED", "IDLE"),
+			Until:    firstField(row, "UNTIL", "EXPIRES", "KEEP ALIVE"),
+		})
 	}
-	output, err := m.sshClient.Execute(cmd)
+	return models
+}
+
+func (m *Manager) dmrPs() error {
+	output, err := m.sshClient.Execute("docker model ps")
 	if err != nil {
-		return fmt.Errorf("failed to retrieve Docker Model Runner logs: %w", err)
+		return fmt.Errorf("failed to list loaded models: %w", err)
+	}
+
+	models := parseLoadedModels(output)
+	if len(models) == 0 {
+		fmt.Println("No models loaded in Docker Model Runner")
+		return nil
+	}
+
+	fmt.Printf("%-40s %-12s %-12s %-18s %s\n", "MODEL", "BACKEND", "MODE", "LAST USED", "UNLOADS (KEEP-ALIVE)")
+	for _, model := range models {
+		fmt.Printf("%-40s %-12s %-12s %-18s %s\n", model.Name, ui.OrDash(model.Backend), ui.OrDash(model.Mode), ui.OrDash(model.LastUsed), ui.OrDash(model.Until))
 	}
-	fmt.Println(output)
+
+	// Memory is reported per runner container and per backend process, not per model
+	stats, err := m.sshClient.Execute("docker stats --no-stream --format '{{.Name}}: {{.MemUsage}}' 2>/dev/null | grep -i model-runner")
+	if err == nil && strings.TrimSpace(stats) != "" {
+		fmt.Println()
+		fmt.Println("Runner memory:")
+		for _, line := range strings.Split(strings.TrimSpace(stats), "\n") {
+			fmt.Printf("  %s\n", strings.TrimSpace(line))
+		}
+	}
+
+	apps, err := m.sshClient.Execute("nvidia-smi --query-compute-apps=pid,process_name,used_memory --format=csv,noheader 2>/dev/null | grep -Ei 'llama|vllm|model-runner'")
+	if err == nil && strings.TrimSpace(apps) != "" {
+		fmt.Println()
+		fmt.Println("GPU memory held by backends:")
+		for _, line := range strings.Split(strings.TrimSpace(apps), "\n") {
+			fmt.Printf("  %s\n", strings.TrimSpace(line))
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("Free memory with: dgx run dmr unload <model> (or --all)")
 	return nil
 }
 
-func (m *Manager) dmrList(args []string) error {
-	cmd := "docker model list"
-	if len(args) > 0 {
-		cmd += " " + strings.Join(args, " ")
+func (m *Manager) dmrUnload(model string) error {
+	cmd := "docker model unload --all"
+	if model != "--all" {
+		cmd = fmt.Sprintf("docker model unload %s", ssh.ShellQuote(model))
 	}
 	output, err := m.sshClient.Execute(cmd)
 	if err != nil {
-		return fmt.Errorf("failed to list models: %w", err)
+		return fmt.Errorf("failed to unload model: %w", err)
+	}
+	if strings.TrimSpace(output) != "" {
+		printOutput(strings.TrimSpace(output))
+	}
+	if model == "--all" {
+		fmt.Println("All models unloaded")
+	} else {
+		fmt.Printf("Unloaded %s\n", model)
 	}
-	fmt.Println(output)
 	return nil
 }
 
@@ -168,11 +325,11 @@ func (m *Manager) dmrPull(model string, extra []string) error {
 	if len(extra) > 0 {
 		cmd += " " + strings.Join(extra, " ")
 	}
-	output, err := m.sshClient.Execute(cmd)
+	output, err := m.execStep(cmd)
 	if err != nil {
 		return fmt.Errorf("failed to pull model: %w", err)
 	}
-	fmt.Println(output)
+	printOutput(output)
 	return nil
 }
 
@@ -187,16 +344,19 @@ func (m *Manager) dmrRun(model string, prompt string) error {
 	if err != nil {
 		return fmt.Errorf("failed to run model: %w", err)
 	}
-	fmt.Println(output)
+	printOutput(output)
 	return nil
 }
 
-func (m *Manager) dmrUninstall() error {
+func (m *Manager) dmrUninstall(yes bool) error {
+	if err := m.confirmDestructive("Remove Docker Model Runner and all cached model images?", yes); err != nil {
+		return err
+	}
 	fmt.Println("Removing Docker Model Runner and cached images...")
 	output, err := m.sshClient.Execute("docker model uninstall-runner --images")
 	if err != nil {
 		return fmt.Errorf("failed to uninstall Docker Model Runner: %w", err)
 	}
-	fmt.Println(output)
+	printOutput(output)
 	return nil
 }
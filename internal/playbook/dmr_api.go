@@ -0,0 +1,235 @@
+package playbook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/artifacts"
+	"github.com/weatherman/dgx-manager/internal/dmr"
+	"github.com/weatherman/dgx-manager/internal/ui"
+)
+
+// dmrAPITimeout bounds a single Model Runner API call
+const dmrAPITimeout = 30 * time.Second
+
+// dmrAPI talks to the Model Runner engine API directly over the SSH connection instead of
+// running the docker model CLI on the DGX
+func (m *Manager) dmrAPI(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("api command required. Usage: dgx run dmr api <status|models|inspect|ps|df|unload|configure> [--json] [--addr host:port | --socket path]")
+	}
+	command := args[0]
+	args, asJSON := removeFlag(args[1:], "--json")
+	args, socket := flagValue(args, "--socket")
+	args, addr := flagValue(args, "--addr")
+
+	network := "tcp"
+	if addr == "" {
+		addr = dmr.DefaultAddr
+	}
+	if socket != "" {
+		network, addr = "unix", socket
+	}
+	client := dmr.NewClient(m.sshClient.Dial, network, addr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), dmrAPITimeout)
+	defer cancel()
+
+	switch command {
+	case "status":
+		status, err := client.Status(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get Docker Model Runner status: %w", err)
+		}
+		if asJSON {
+			return printJSON(status)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "BACKEND\tSTATUS")
+		names := make([]string, 0, len(status))
+		for name := range status {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(w, "%s\t%s\n", name, status[name])
+		}
+		return w.Flush()
+
+	case "models", "list":
+		models, err := client.Models(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list models: %w", err)
+		}
+		if asJSON {
+			return printJSON(models)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "MODEL\tID\tPARAMETERS\tQUANTIZATION\tARCHITECTURE\tSIZE")
+		for _, model := range models {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", modelName(model), ui.ShortID(model.ID),
+				ui.OrDash(model.Config.Parameters), ui.OrDash(model.Config.Quantization),
+				ui.OrDash(model.Config.Architecture), ui.OrDash(model.Config.Size))
+		}
+		return w.Flush()
+
+	case "inspect":
+		if len(args) == 0 {
+			return fmt.Errorf("model reference required. Usage: dgx run dmr api inspect <model>")
+		}
+		model, err := client.Inspect(ctx, args[0])
+		if err != nil {
+			return fmt.Errorf("failed to inspect %s: %w", args[0], err)
+		}
+		if asJSON {
+			return printJSON(model)
+		}
+		fmt.Printf("Model:        %s\n", modelName(*model))
+		fmt.Printf("ID:           %s\n", model.ID)
+		if len(model.Tags) > 1 {
+			fmt.Printf("Tags:         %s\n", strings.Join(model.Tags, ", "))
+		}
+		if model.Created > 0 {
+			fmt.Printf("Created:      %s\n", time.Unix(model.Created, 0).Format("2006-01-02 15:04"))
+		}
+		fmt.Printf("Format:       %s\n", ui.OrDash(model.Config.Format))
+		fmt.Printf("Architecture: %s\n", ui.OrDash(model.Config.Architecture))
+		fmt.Printf("Parameters:   %s\n", ui.OrDash(model.Config.Parameters))
+		fmt.Printf("Quantization: %s\n", ui.OrDash(model.Config.Quantization))
+		fmt.Printf("Size:         %s\n", ui.OrDash(model.Config.Size))
+		if model.Config.ContextSize != nil {
+			fmt.Printf("Context size: %d\n", *model.Config.ContextSize)
+		}
+		return nil
+
+	case "ps":
+		runners, err := client.Running(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list loaded models: %w", err)
+		}
+		if asJSON {
+			return printJSON(runners)
+		}
+		if len(runners) == 0 {
+			fmt.Println("No models loaded in Docker Model Runner")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "MODEL\tBACKEND\tMODE\tLAST USED")
+		for _, r := range runners {
+			lastUsed := "-"
+			if !r.LastUsed.IsZero() {
+				lastUsed = time.Since(r.LastUsed).Round(time.Second).String() + " ago"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Model, r.Backend, ui.OrDash(r.Mode), lastUsed)
+		}
+		return w.Flush()
+
+	case "df":
+		usage, err := client.DiskUsage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get disk usage: %w", err)
+		}
+		if asJSON {
+			return printJSON(usage)
+		}
+		fmt.Printf("Models:  %s\n", artifacts.FormatBytes(usage.Models))
+		fmt.Printf("Backend: %s\n", artifacts.FormatBytes(usage.Backend))
+		return nil
+
+	case "unload":
+		args, all := removeFlag(args, "--all")
+		args, backend := flagValue(args, "--backend")
+		if !all && len(args) == 0 {
+			return fmt.Errorf("model reference required. Usage: dgx run dmr api unload <model...|--all> [--backend name]")
+		}
+		unloaded, err := client.Unload(ctx, dmr.UnloadRequest{All: all, Backend: backend, Models: args})
+		if err != nil {
+			return fmt.Errorf("failed to unload: %w", err)
+		}
+		if asJSON {
+			return printJSON(map[string]int{"unloaded_runners": unloaded})
+		}
+		fmt.Printf("Unloaded %d runner(s)\n", unloaded)
+		return nil
+
+	case "configure":
+		args, contextSize := flagValue(args, "--context-size")
+		args, runtimeFlags := flagValue(args, "--runtime-flags")
+		if len(args) == 0 {
+			return fmt.Errorf("model reference required. Usage: dgx run dmr api configure <model> [--context-size N] [--runtime-flags \"...\"]")
+		}
+		req := dmr.ConfigureRequest{Model: args[0], RuntimeFlags: strings.Fields(runtimeFlags)}
+		if contextSize != "" {
+			size, err := strconv.ParseInt(contextSize, 10, 64)
+			if err != nil || size <= 0 {
+				return fmt.Errorf("invalid --context-size %q", contextSize)
+			}
+			req.ContextSize = size
+		}
+		if req.ContextSize == 0 && len(req.RuntimeFlags) == 0 {
+			return fmt.Errorf("nothing to configure: pass --context-size and/or --runtime-flags")
+		}
+		if err := client.Configure(ctx, req); err != nil {
+			return fmt.Errorf("failed to configure %s: %w", req.Model, err)
+		}
+		fmt.Printf("Configured %s (applies the next time it loads; unload it to reload now)\n", req.Model)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown dmr api command: %s", command)
+	}
+}
+
+// removeFlag removes a boolean flag from args and reports whether it was present
+func removeFlag(args []string, flag string) ([]string, bool) {
+	kept := make([]string, 0, len(args))
+	found := false
+	for _, a := range args {
+		if a == flag {
+			found = true
+			continue
+		}
+		kept = append(kept, a)
+	}
+	return kept, found
+}
+
+// flagValue removes "--flag value" or "--flag=value" from args and returns the value
+func flagValue(args []string, flag string) ([]string, string) {
+	kept := make([]string, 0, len(args))
+	value := ""
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == flag && i+1 < len(args):
+			value = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], flag+"="):
+			value = strings.TrimPrefix(args[i], flag+"=")
+		default:
+			kept = append(kept, args[i])
+		}
+	}
+	return kept, value
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// modelName is the first tag, which is how the CLI refers to a model
+func modelName(m dmr.Model) string {
+	if len(m.Tags) > 0 {
+		return m.Tags[0]
+	}
+	return ui.ShortID(m.ID)
+}
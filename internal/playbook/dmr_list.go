@@ -0,0 +1,239 @@
+package playbook
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/artifacts"
+	"github.com/weatherman/dgx-manager/internal/ui"
+)
+
+// ListedModel is a model in the Docker Model Runner store, as shown by 'docker model list'
+type ListedModel struct {
+	Name         string `json:"name"`
+	Parameters   string `json:"parameters,omitempty"`
+	Quantization string `json:"quantization,omitempty"`
+	Architecture string `json:"architecture,omitempty"`
+	ID           string `json:"id,omitempty"`
+	Modified     string `json:"modified,omitempty"`
+	Size         string `json:"size,omitempty"`
+	Bytes        int64  `json:"bytes"`
+	// Age is how long ago the model was created, parsed from Modified
+	Age time.Duration `json:"-"`
+}
+
+// modelListSorts are the --sort keys of 'dgx run dmr list'
+var modelListSorts = []string{"name", "size", "quant", "modified"}
+
+// parseModelList converts 'docker model list' output into typed rows
+func parseModelList(output string) []ListedModel {
+	var models []ListedModel
+	for _, row := range parseTable(output) {
+		name := firstField(row, "MODEL NAME", "MODEL", "NAME")
+		if name == "" {
+			continue
+		}
+		model := ListedModel{
+			Name:         name,
+			Parameters:   firstField(row, "PARAMETERS"),
+			Quantization: firstField(row, "QUANTIZATION", "QUANT"),
+			Architecture: firstField(row, "ARCHITECTURE"),
+			ID:           firstField(row, "MODEL ID", "ID"),
+			Modified:     firstField(row, "CREATED", "MODIFIED"),
+			Size:         firstField(row, "SIZE"),
+		}
+		model.Bytes, _ = artifacts.ParseBytes(model.Size)
+		model.Age = parseAge(model.Modified)
+		models = append(models, model)
+	}
+	return models
+}
+
+var agePattern = regexp.MustCompile(`(?i)^(an?|about an?|[0-9]+)\s+(second|minute|hour|day|week|month|year)s?\s+ago$`)
+
+// parseAge turns docker's relative times ("3 months ago", "About an hour ago") into a
+// duration; unknown forms count as zero
+func parseAge(s string) time.Duration {
+	match := agePattern.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		return 0
+	}
+	n, err := strconv.Atoi(match[1])
+	if err != nil {
+		n = 1
+	}
+	units := map[string]time.Duration{
+		"second": time.Second,
+		"minute": time.Minute,
+		"hour":   time.Hour,
+		"day":    24 * time.Hour,
+		"week":   7 * 24 * time.Hour,
+		"month":  30 * 24 * time.Hour,
+		"year":   365 * 24 * time.Hour,
+	}
+	return time.Duration(n) * units[strings.ToLower(match[2])]
+}
+
+// modelCondition is one term of a --filter expression, such as size>20GB or name~llama
+type modelCondition struct {
+	field string
+	op    string
+	value string
+	bytes int64
+}
+
+var conditionPattern = regexp.MustCompile(`^\s*([a-z]+)\s*(>=|<=|!=|>|<|=|~)\s*(.+?)\s*$`)
+
+// parseModelFilter parses comma-separated conditions; a model must match all of them.
+// size compares with >, >=, <, <=, =, and !=; name, quant, arch, and params compare as
+// text with = and !=, or ~ for a case-insensitive substring.
+func parseModelFilter(expr string) ([]modelCondition, error) {
+	var conditions []modelCondition
+	for _, term := range splitList(expr) {
+		match := conditionPattern.FindStringSubmatch(term)
+		if match == nil {
+			return nil, fmt.Errorf("invalid --filter %q (want e.g. size>20GB, quant=Q4_K_M, or name~llama)", term)
+		}
+		c := modelCondition{field: match[1], op: match[2], value: match[3]}
+		switch c.field {
+		case "size":
+			if c.op == "~" {
+				return nil, fmt.Errorf("invalid --filter %q: size compares with >, >=, <, <=, =, or !=", term)
+			}
+			var err error
+			if c.bytes, err = artifacts.ParseBytes(c.value); err != nil {
+				return nil, fmt.Errorf("invalid --filter %q: %w", term, err)
+			}
+		case "name", "quant", "arch", "params":
+			if c.op != "=" && c.op != "!=" && c.op != "~" {
+				return nil, fmt.Errorf("invalid --filter %q: %s compares with =, !=, or ~", term, c.field)
+			}
+		default:
+			return nil, fmt.Errorf("invalid --filter %q: unknown field %q (want size, name, quant, arch, or params)", term, c.field)
+		}
+		conditions = append(conditions, c)
+	}
+	return conditions, nil
+}
+
+func (c modelCondition) matches(model ListedModel) bool {
+	if c.field == "size" {
+		switch c.op {
+		case ">":
+			return model.Bytes > c.bytes
+		case ">=":
+			return model.Bytes >= c.bytes
+		case "<":
+			return model.Bytes < c.bytes
+		case "<=":
+			return model.Bytes <= c.bytes
+		case "=":
+			return model.Bytes == c.bytes
+		default:
+			return model.Bytes != c.bytes
+		}
+	}
+	text := map[string]string{
+		"name":   model.Name,
+		"quant":  model.Quantization,
+		"arch":   model.Architecture,
+		"params": model.Parameters,
+	}[c.field]
+	switch c.op {
+	case "~":
+		return strings.Contains(strings.ToLower(text), strings.ToLower(c.value))
+	case "=":
+		return strings.EqualFold(text, c.value)
+	default:
+		return !strings.EqualFold(text, c.value)
+	}
+}
+
+// filterModels returns the models matching every condition
+func filterModels(models []ListedModel, conditions []modelCondition) []ListedModel {
+	var kept []ListedModel
+next:
+	for _, model := range models {
+		for _, c := range conditions {
+			if !c.matches(model) {
+				continue next
+			}
+		}
+		kept = append(kept, model)
+	}
+	return kept
+}
+
+// sortModels orders models by key: size largest first, modified newest first, and name
+// and quant alphabetically
+func sortModels(models []ListedModel, key string) {
+	sort.SliceStable(models, func(i, j int) bool {
+		a, b := models[i], models[j]
+		switch key {
+		case "size":
+			return a.Bytes > b.Bytes
+		case "modified":
+			return a.Age < b.Age
+		case "quant":
+			return a.Quantization < b.Quantization
+		default:
+			return a.Name < b.Name
+		}
+	})
+}
+
+func (m *Manager) dmrList(args []string) error {
+	args, asJSON := removeFlag(args, "--json")
+	args, sortKey := flagValue(args, "--sort")
+	args, filter := flagValue(args, "--filter")
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments: %s. Usage: dgx run dmr list [--sort %s] [--filter expr] [--json]", strings.Join(args, " "), strings.Join(modelListSorts, "|"))
+	}
+	if sortKey != "" && !slices.Contains(modelListSorts, sortKey) {
+		return fmt.Errorf("unknown --sort %q (want %s)", sortKey, strings.Join(modelListSorts, ", "))
+	}
+	conditions, err := parseModelFilter(filter)
+	if err != nil {
+		return err
+	}
+
+	output, err := m.sshClient.Execute("docker model list")
+	if err != nil {
+		printOutput(output)
+		return fmt.Errorf("failed to list models: %w", err)
+	}
+	models := filterModels(parseModelList(output), conditions)
+	if sortKey != "" {
+		sortModels(models, sortKey)
+	}
+
+	if asJSON {
+		if models == nil {
+			models = []ListedModel{}
+		}
+		return printJSON(models)
+	}
+	if len(models) == 0 {
+		if filter != "" {
+			fmt.Println("No models match the filter")
+		} else {
+			fmt.Println("No models in Docker Model Runner")
+			fmt.Println("\nPull one with: dgx run dmr pull <model>")
+		}
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MODEL\tPARAMETERS\tQUANTIZATION\tARCHITECTURE\tSIZE\tMODIFIED")
+	for _, model := range models {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", model.Name, ui.OrDash(model.Parameters),
+			ui.OrDash(model.Quantization), ui.OrDash(model.Architecture), ui.OrDash(model.Size), ui.OrDash(model.Modified))
+	}
+	return w.Flush()
+}
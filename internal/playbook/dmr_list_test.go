@@ -0,0 +1,66 @@
+package playbook
+
+import (
+	"testing"
+	"time"
+)
+
+const modelListOutput = `MODEL NAME                  PARAMETERS  QUANTIZATION    ARCHITECTURE  MODEL ID      CREATED            SIZE
+ai/smollm2:360M-Q4_K_M      361.82 M    IQ2_XXS/Q4_K_M  llama         354bf30d0aa3  3 months ago       256.35 MiB
+ai/llama3.3:70B-Q4_K_M      70.55 B     Q4_K_M          llama         a3c5f2d1e017  About an hour ago  39.59 GiB
+ai/qwen2.5-coder:32B-Q8_0   32.76 B     Q8_0            qwen2         0d9e8a7b6c5f  2 weeks ago        32.43 GiB
+`
+
+// smollmSize is 256.35 MiB, the size of the first model above
+var smollmSize = 256.35 * (1 << 20)
+
+func TestParseModelList(t *testing.T) {
+	models := parseModelList(modelListOutput)
+	if len(models) != 3 {
+		t.Fatalf("expected 3 models, got %d", len(models))
+	}
+	if models[0].Name != "ai/smollm2:360M-Q4_K_M" || models[0].Quantization != "IQ2_XXS/Q4_K_M" || models[0].ID != "354bf30d0aa3" {
+		t.Fatalf("unexpected row %+v", models[0])
+	}
+	if models[0].Bytes != int64(smollmSize) {
+		t.Fatalf("unexpected bytes %d", models[0].Bytes)
+	}
+	if models[1].Age != time.Hour || models[2].Age != 14*24*time.Hour {
+		t.Fatalf("unexpected ages %v, %v", models[1].Age, models[2].Age)
+	}
+}
+
+func TestFilterAndSortModels(t *testing.T) {
+	models := parseModelList(modelListOutput)
+	conditions, err := parseModelFilter("size>20GB")
+	if err != nil {
+		t.Fatalf("parseModelFilter: %v", err)
+	}
+	big := filterModels(models, conditions)
+	if len(big) != 2 {
+		t.Fatalf("expected 2 models over 20GB, got %+v", big)
+	}
+
+	conditions, err = parseModelFilter("arch=llama, quant~q4")
+	if err != nil {
+		t.Fatalf("parseModelFilter: %v", err)
+	}
+	if got := filterModels(models, conditions); len(got) != 2 || got[1].Name != "ai/llama3.3:70B-Q4_K_M" {
+		t.Fatalf("unexpected llama Q4 models %+v", got)
+	}
+
+	for _, bad := range []string{"size~20GB", "name>a", "color=red", "size>huge", "size"} {
+		if _, err := parseModelFilter(bad); err == nil {
+			t.Fatalf("expected an error for --filter %q", bad)
+		}
+	}
+
+	sortModels(models, "size")
+	if models[0].Name != "ai/llama3.3:70B-Q4_K_M" || models[2].Name != "ai/smollm2:360M-Q4_K_M" {
+		t.Fatalf("unexpected size order %s, %s, %s", models[0].Name, models[1].Name, models[2].Name)
+	}
+	sortModels(models, "modified")
+	if models[0].Name != "ai/llama3.3:70B-Q4_K_M" || models[2].Name != "ai/smollm2:360M-Q4_K_M" {
+		t.Fatalf("unexpected modified order %s, %s, %s", models[0].Name, models[1].Name, models[2].Name)
+	}
+}
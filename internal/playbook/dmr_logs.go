@@ -0,0 +1,172 @@
+package playbook
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logLevels orders the levels 'dmr logs --level' filters on
+var logLevels = map[string]int{
+	"trace": 0, "debug": 1, "info": 2, "warn": 3, "warning": 3, "error": 4, "fatal": 5, "panic": 5,
+}
+
+var (
+	// The runner logs with logrus (time="..." level=info msg="..."); Docker Desktop style
+	// lines start with a bracketed timestamp instead
+	logTimePattern  = regexp.MustCompile(`time="([^"]+)"|^\[([0-9]{4}-[0-9]{2}-[0-9]{2}T[^\]]+)\]`)
+	logLevelPattern = regexp.MustCompile(`\blevel=(\w+)|\[(TRACE|DEBUG|INFO|WARN|WARNING|ERROR|FATAL)\]`)
+	logSinceLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+)
+
+// allLevels disables the level filter; lines with a level it does not know rank below all
+const allLevels = -1
+
+// logFilter keeps runner log lines that match a pattern, reach a level, and are newer
+// than a time. Lines without a level or timestamp of their own, such as stack traces and
+// engine output, take those of the line before them.
+type logFilter struct {
+	pattern  *regexp.Regexp
+	minLevel int // allLevels keeps every level
+	since    time.Time
+
+	lastLevel int
+	lastTime  time.Time
+}
+
+func newLogFilter(pattern, level, since string, now time.Time) (*logFilter, error) {
+	f := &logFilter{minLevel: allLevels, lastLevel: logLevels["info"]}
+	if pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --grep pattern: %w", err)
+		}
+		f.pattern = re
+	}
+	if level != "" {
+		min, ok := logLevels[strings.ToLower(level)]
+		if !ok {
+			return nil, fmt.Errorf("invalid --level %q (want debug, info, warn, or error)", level)
+		}
+		f.minLevel = min
+	}
+	if since != "" {
+		t, err := parseSince(since, now)
+		if err != nil {
+			return nil, err
+		}
+		f.since = t
+	}
+	return f, nil
+}
+
+// parseSince reads --since as a duration before now (10m, 2h) or a time in the local zone
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return now.Add(-d), nil
+	}
+	for _, layout := range logSinceLayouts {
+		if t, err := time.ParseInLocation(layout, value, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q (want a duration such as 10m or a time such as 2006-01-02T15:04:05)", value)
+}
+
+// keep reports whether line passes the filter
+func (f *logFilter) keep(line string) bool {
+	if m := logTimePattern.FindStringSubmatch(line); m != nil {
+		if t, err := time.Parse(time.RFC3339Nano, m[1]+m[2]); err == nil {
+			f.lastTime = t
+		}
+	}
+	if m := logLevelPattern.FindStringSubmatch(line); m != nil {
+		level, ok := logLevels[strings.ToLower(m[1]+m[2])]
+		if !ok {
+			level = allLevels
+		}
+		f.lastLevel = level
+	}
+
+	if !f.since.IsZero() && (f.lastTime.IsZero() || f.lastTime.Before(f.since)) {
+		return false
+	}
+	if f.minLevel != allLevels && f.lastLevel < f.minLevel {
+		return false
+	}
+	return f.pattern == nil || f.pattern.MatchString(line)
+}
+
+// logFilterWriter passes the complete lines written to it through a filter to out. The
+// runner's stdout and stderr share one, so it is safe for concurrent writes.
+type logFilterWriter struct {
+	mu      sync.Mutex
+	filter  *logFilter
+	out     io.Writer
+	partial string
+}
+
+func (w *logFilterWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	lines := strings.Split(w.partial+string(p), "\n")
+	w.partial = lines[len(lines)-1]
+	for _, line := range lines[:len(lines)-1] {
+		w.emit(strings.TrimSuffix(line, "\r"))
+	}
+	return len(p), nil
+}
+
+// Flush writes a final line that did not end in a newline
+func (w *logFilterWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.partial != "" {
+		w.emit(w.partial)
+		w.partial = ""
+	}
+}
+
+func (w *logFilterWriter) emit(line string) {
+	if w.filter.keep(line) {
+		fmt.Fprintln(w.out, line)
+	}
+}
+
+// dmrLogs prints the runner logs, or follows them with -f, filtered on this side by
+// --grep (a regular expression), --level (the lowest level shown), and --since. Other
+// arguments are passed to 'docker model logs'.
+func (m *Manager) dmrLogs(args []string) error {
+	args, follow := removeFlag(args, "-f")
+	args, followLong := removeFlag(args, "--follow")
+	follow = follow || followLong
+	args, pattern := flagValue(args, "--grep")
+	args, level := flagValue(args, "--level")
+	args, since := flagValue(args, "--since")
+
+	filter, err := newLogFilter(pattern, level, since, time.Now())
+	if err != nil {
+		return err
+	}
+
+	cmd := "docker model logs"
+	if follow {
+		cmd += " --follow"
+	}
+	if len(args) > 0 {
+		cmd += " " + strings.Join(args, " ")
+	} else if since == "" {
+		cmd += " --tail 200"
+	}
+
+	out := &logFilterWriter{filter: filter, out: os.Stdout}
+	defer out.Flush()
+	if err := m.sshClient.Stream(cmd, nil, out, out); err != nil {
+		return fmt.Errorf("failed to retrieve Docker Model Runner logs: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package playbook
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogFilter(t *testing.T) {
+	now := time.Date(2025, 10, 14, 12, 0, 0, 0, time.UTC)
+	lines := []string{
+		`time="2025-10-14T11:00:00Z" level=error msg="old failure"`,
+		`time="2025-10-14T11:55:00Z" level=info msg="Loading model ai/llama3.2"`,
+		`time="2025-10-14T11:56:00Z" level=warning msg="context size reduced"`,
+		`  at llama.cpp/loader.cpp:42`,
+		`time="2025-10-14T11:57:00Z" level=debug msg="heartbeat"`,
+	}
+	cases := []struct {
+		pattern, level, since string
+		want                  []int
+	}{
+		{"", "", "", []int{0, 1, 2, 3, 4}},
+		{"", "warn", "", []int{0, 2, 3}},
+		{"", "", "10m", []int{1, 2, 3, 4}},
+		{"(?i)loading|loader", "", "10m", []int{1, 3}},
+	}
+	for _, c := range cases {
+		f, err := newLogFilter(c.pattern, c.level, c.since, now)
+		if err != nil {
+			t.Fatalf("newLogFilter(%+v) failed: %v", c, err)
+		}
+		var kept []int
+		for i, line := range lines {
+			if f.keep(line) {
+				kept = append(kept, i)
+			}
+		}
+		if len(kept) != len(c.want) {
+			t.Fatalf("filter %+v kept lines %v, want %v", c, kept, c.want)
+		}
+		for i := range kept {
+			if kept[i] != c.want[i] {
+				t.Fatalf("filter %+v kept lines %v, want %v", c, kept, c.want)
+			}
+		}
+	}
+
+	if _, err := newLogFilter("", "loud", "", now); err == nil {
+		t.Fatalf("newLogFilter accepted level loud")
+	}
+}
+
+func TestLogFilterWriter(t *testing.T) {
+	f, _ := newLogFilter("keep", "", "", time.Now())
+	var out strings.Builder
+	w := &logFilterWriter{filter: f, out: &out}
+	w.Write([]byte("keep one\r\ndrop\nkee"))
+	w.Write([]byte("p two"))
+	w.Flush()
+	if got := out.String(); got != "keep one\nkeep two\n" {
+		t.Fatalf("output = %q", got)
+	}
+}
@@ -8,23 +8,276 @@ func PrintHelp(name string) {
 	case "dmr":
 		fmt.Println("Docker Model Runner (dmr) playbook")
 		fmt.Println("Commands:")
-		fmt.Println("  setup       - Install Docker + GPU runtime prerequisites on the DGX")
+		fmt.Println("  setup       - Install Docker + GPU runtime prerequisites on the DGX (--resume to skip finished steps)")
 		fmt.Println("  install     - Install/upgrade the Docker Model Runner controller")
 		fmt.Println("  update      - Reinstall the controller with fresh bits")
 		fmt.Println("  status      - Check Docker Model Runner status")
-		fmt.Println("  logs        - Tail controller logs (pass extra args like --tail 100)")
-		fmt.Println("  list        - List cached models (same as 'docker model list')")
+		fmt.Println("  logs        - Tail controller logs; -f follows, --grep <regex>, --level warn, --since 10m filter")
+		fmt.Println("  list        - List cached models: --sort name|size|quant|modified, --filter 'size>20GB,quant~Q4', --json")
+		fmt.Println("  ps          - Show loaded models, backends, and keep-alive expiry")
+		fmt.Println("  unload      - Unload a model to free memory (usage: dgx run dmr unload <ref|--all>)")
 		fmt.Println("  pull        - Pull models from Docker Hub/HF/nvcr.io (usage: dgx run dmr pull <ref>)")
 		fmt.Println("  run         - Run a model with a single prompt (usage: dgx run dmr run <ref> \"prompt\")")
-		fmt.Println("  uninstall   - Remove the controller and cached images")
+		fmt.Println("  uninstall   - Remove the controller and cached images (--yes skips confirmation)")
+		fmt.Println("  rollback    - Undo 'setup': restore docker/runtime config, purge packages it installed")
+		fmt.Println("  api         - Query the runner API directly: status, models, inspect, ps, df, unload, configure")
+		fmt.Println("                (--json for typed output; --addr host:port or --socket path to override 127.0.0.1:12434)")
 		fmt.Println()
 		fmt.Println("Examples:")
 		fmt.Println("  dgx run dmr setup")
+		fmt.Println("  dgx run dmr setup --resume")
 		fmt.Println("  dgx run dmr install")
 		fmt.Println("  dgx run dmr pull ai/smollm2:360M-Q4_K_M")
 		fmt.Println("  dgx run dmr run ai/smollm2:360M-Q4_K_M \"Explain quantum computing\"")
+		fmt.Println("  dgx run dmr list --sort size --filter 'size>20GB'")
 		fmt.Println("  dgx run dmr status")
+		fmt.Println("  dgx run dmr ps")
 		fmt.Println("  dgx run dmr logs --tail 100")
+		fmt.Println("  dgx run dmr logs -f --grep 'load|llama' --level info")
+		fmt.Println("  dgx run dmr api models --json")
+		fmt.Println("  dgx run dmr api configure ai/smollm2 --context-size 8192")
+	case "devsetup":
+		fmt.Println("Developer tools (devsetup) playbook")
+		fmt.Println("Commands:")
+		fmt.Println("  install     - Install the declared tools and sync dotfiles (the default; --resume to skip finished steps)")
+		fmt.Println("  status      - Show which tools are installed and the dotfiles checkout")
+		fmt.Println()
+		fmt.Println("Tools come from 'devsetup.tools' in ~/.config/dgx/config.yaml (apt package names plus uv),")
+		fmt.Println("defaulting to git, tmux, htop, nvtop, uv, and build-essential. 'devsetup.dotfiles_repo' is")
+		fmt.Println("cloned to ~/.dotfiles and its install.sh or bootstrap.sh (or 'dotfiles_install') is run.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --tools a,b,c    Install these tools instead of the configured list")
+		fmt.Println("  --dotfiles URL   Use this dotfiles repository; --no-dotfiles skips it")
+		fmt.Println("  --yes            Skip the confirmation prompt")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  dgx run devsetup")
+		fmt.Println("  dgx run devsetup --tools git,tmux,ripgrep --no-dotfiles")
+		fmt.Println("  dgx run devsetup status")
+	case "pyenv":
+		fmt.Println("Python environment (pyenv) playbook")
+		fmt.Println("Commands:")
+		fmt.Println("  create      - Create an environment with PyTorch aarch64 CUDA wheels preinstalled")
+		fmt.Println("                (--python 3.11, --cuda 12.8|12.9|13.0 or 12.x/13.x, --tool uv|micromamba, --resume)")
+		fmt.Println("  list        - List environments with their tool, Python, and CUDA versions")
+		fmt.Println("  remove      - Delete an environment (--yes skips confirmation)")
+		fmt.Println("  activate    - Print activation snippets for a shell, dgx exec, VS Code, and Jupyter")
+		fmt.Println()
+		fmt.Println("Environments live in ~/.local/share/dgx/envs/<name> on the DGX.")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  dgx run pyenv create train --cuda 12.x --python 3.11")
+		fmt.Println("  dgx run pyenv create notebooks --tool micromamba --python 3.12")
+		fmt.Println("  dgx run pyenv create train --resume")
+		fmt.Println("  dgx run pyenv list")
+		fmt.Println("  dgx run pyenv activate train")
+		fmt.Println("  dgx run pyenv remove notebooks")
+	case "nvidia":
+		fmt.Println("NVIDIA driver (nvidia) playbook")
+		fmt.Println("Commands:")
+		fmt.Println("  status      - Show the running driver, the loaded kernel module, and pending driver updates (the default)")
+		fmt.Println("  update      - Refresh the package lists and upgrade the installed NVIDIA driver packages")
+		fmt.Println()
+		fmt.Println("The new driver loads at the next reboot. Until then nvidia-smi may report a driver/library")
+		fmt.Println("version mismatch. Roll updates out across several DGX hosts one canary at a time with")
+		fmt.Println("'dgx fleet run --canary <host>'.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --reboot   Reboot the DGX once the packages are installed (asks first)")
+		fmt.Println("  --yes      Reboot without asking")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  dgx run nvidia status")
+		fmt.Println("  dgx run nvidia update --reboot")
+		fmt.Println("  dgx fleet run --group lab --canary lab1 nvidia update --reboot --yes")
+	case "time":
+		fmt.Println("Clock and NTP (time) playbook")
+		fmt.Println("Commands:")
+		fmt.Println("  status      - Show the DGX clock's skew from this machine, the NTP service, and sync state (the default)")
+		fmt.Println("  sync        - Make one NTP client active, keep the RTC in UTC, and step the clock (--resume to skip finished steps)")
+		fmt.Println()
+		fmt.Println("A skewed clock breaks TLS, registry logins, and token auth inside containers. 'sync' uses chrony")
+		fmt.Println("when it is installed and systemd-timesyncd otherwise, disabling any other NTP daemon.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --service chrony|timesyncd   Use this NTP client instead of detecting one")
+		fmt.Println("  --servers a,b                NTP servers to use instead of the distribution defaults")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  dgx run time status")
+		fmt.Println("  dgx run time sync")
+		fmt.Println("  dgx run time sync --servers time.cloudflare.com,pool.ntp.org")
+	case "memory":
+		fmt.Println("Swap and zram (memory) playbook")
+		fmt.Println("Commands:")
+		fmt.Println("  status      - Show memory, swap devices, zram compression, and memory pressure (the default)")
+		fmt.Println("  configure   - Create, resize, or remove the swap file and zram swap (--resume to skip finished steps)")
+		fmt.Println()
+		fmt.Println("The GPU shares the 128 GB of unified memory, so a model close to the limit can push the")
+		fmt.Println("system into the OOM killer. zram (priority 100) absorbs pages that compress; the swap file")
+		fmt.Println("(/swapfile, priority 10) takes the rest. Swap is only turned off when its pages fit in free")
+		fmt.Println("memory, and a new swap file must leave 10 GiB of the disk free.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --swap SIZE|off      Swap file size, e.g. 64G or 512M; off removes it")
+		fmt.Println("  --zram on|off        Compressed swap in RAM via systemd-zram-generator (zstd)")
+		fmt.Println("  --zram-size SIZE     zram size as a share of RAM or absolute, e.g. 25% (the default) or 16G")
+		fmt.Println("  --swappiness N       vm.swappiness, 0-200")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  dgx run memory status")
+		fmt.Println("  dgx run memory configure --swap 64G --zram on")
+		fmt.Println("  dgx run memory configure --zram on --zram-size 16G --swappiness 100")
+		fmt.Println("  dgx run memory configure --swap off")
+	case "tune":
+		fmt.Println("Kernel tuning (tune) playbook")
+		fmt.Println("Commands:")
+		fmt.Println("  diff        - Compare current kernel settings with the recommendations for inference (the default)")
+		fmt.Println("  apply       - Persist the recommendations (--resume to skip finished steps)")
+		fmt.Println("  rollback    - Restore the files and values from before the first apply")
+		fmt.Println()
+		fmt.Println("sysctls go to /etc/sysctl.d/90-dgx-tune.conf, transparent hugepage settings to a boot-time")
+		fmt.Println("dgx-thp.service unit, and with --iommu-passthrough iommu.passthrough to a GRUB drop-in, which needs a reboot.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --skip a,b            Leave these settings alone, e.g. --skip net.core.somaxconn")
+		fmt.Println("  --hugepages N         Also reserve N 2 MiB hugepages (taken away from the GPU; none by default)")
+		fmt.Println("  --iommu-passthrough   Also set iommu.passthrough=1 on the kernel command line (reboot; off by default)")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  dgx run tune")
+		fmt.Println("  dgx run tune apply")
+		fmt.Println("  dgx run tune apply --skip net.core.somaxconn")
+		fmt.Println("  dgx run tune apply --iommu-passthrough")
+		fmt.Println("  dgx run tune rollback")
+	case "sdgen":
+		fmt.Println("Image generation (sdgen) playbook")
+		fmt.Println("Commands:")
+		fmt.Println("  deploy      - Build the server image, fetch a checkpoint, start the server, and tunnel to it")
+		fmt.Println("  status      - Show the container, whether the server answers, and the checkpoints on the DGX")
+		fmt.Println("  stop        - Remove the container; checkpoints and generated images are kept")
+		fmt.Println()
+		fmt.Println("The server listens on 127.0.0.1 only and is reached through an SSH tunnel. Checkpoints live in")
+		fmt.Println("~/.local/share/dgx/sdgen/models/checkpoints and images in ~/.local/share/dgx/sdgen/output on")
+		fmt.Println("the DGX. Generate from the command line with 'dgx imagine'.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --ui comfyui|sd-webui     Server to deploy (default comfyui)")
+		fmt.Println("  --port N                  Port on the DGX (default 8188 for ComfyUI, 7860 for sd-webui)")
+		fmt.Println("  --local-port N            Local end of the tunnel (default: the same port, or the next free one)")
+		fmt.Println("  --checkpoint ORG/REPO/FILE  Hugging Face checkpoint to download, or none (default SDXL base 1.0)")
+		fmt.Println("  --no-tunnel               Do not open a tunnel")
+		fmt.Println("  --rebuild                 Rebuild the server image to pick up upstream changes")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  dgx run sdgen deploy")
+		fmt.Println("  dgx run sdgen deploy --ui sd-webui --local-port 7861")
+		fmt.Println("  dgx run sdgen deploy --checkpoint stabilityai/sdxl-turbo/sd_xl_turbo_1.0_fp16.safetensors")
+		fmt.Println("  dgx imagine \"a lighthouse at dusk, oil painting\" -o out.png")
+	case "webui":
+		fmt.Println("Open WebUI (webui) playbook")
+		fmt.Println("Commands:")
+		fmt.Println("  install     - Install Open WebUI connected to the model servers on the DGX and tunnel to it")
+		fmt.Println("  status      - Show the container, the address it listens on, and its model servers")
+		fmt.Println("  update      - Pull the newest image and recreate the container; data is kept")
+		fmt.Println("  uninstall   - Remove the container (--purge also deletes accounts and chats, --yes skips the prompt)")
+		fmt.Println()
+		fmt.Println("Without --backend or --url, install connects to whichever of DMR (port 12434), vLLM (8000),")
+		fmt.Println("and Ollama (11434) answer. Accounts and chats live in the open-webui Docker volume; the first")
+		fmt.Println("account to sign up becomes the administrator.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --backend dmr,vllm,ollama   Model servers on the DGX to connect to")
+		fmt.Println("  --url URL[,URL]             Other OpenAI-compatible base URLs to add")
+		fmt.Println("  --port N                    Port on the DGX (default 3000)")
+		fmt.Println("  --local-port N              Local end of the tunnel (default: the same port, or the next free one)")
+		fmt.Println("  --lan                       Listen on every interface so others on the network can use it")
+		fmt.Println("  --no-tunnel                 Do not open a tunnel")
+		fmt.Println("  --image IMAGE               Open WebUI image (default ghcr.io/open-webui/open-webui:main)")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  dgx run webui install")
+		fmt.Println("  dgx run webui install --backend vllm --lan")
+		fmt.Println("  dgx run webui update")
+		fmt.Println("  dgx run webui uninstall --purge")
+	case "monitoring":
+		fmt.Println("Monitoring (monitoring) playbook")
+		fmt.Println("Commands:")
+		fmt.Println("  grafana     - Deploy Grafana with the DGX Spark dashboard, plus Prometheus and the exporters, and tunnel to it")
+		fmt.Println("  status      - Show the containers and which scrape targets answer")
+		fmt.Println("  uninstall   - Remove the containers (--purge also deletes metrics and dashboards, --yes skips the prompt)")
+		fmt.Println()
+		fmt.Println("The dashboard shows GPU utilization, temperature, power, and clocks (DCGM exporter), unified")
+		fmt.Println("memory, CPU, and network (node exporter), and DMR and vLLM token rates. Prometheus keeps 15 days")
+		fmt.Println("of metrics in the dgx-prometheus volume. The Grafana admin password is generated on first install")
+		fmt.Println("and kept in ~/.config/dgx/monitoring/grafana.secret on the DGX.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --prometheus URL            Use this Prometheus instead of deploying one; the exporters then listen")
+		fmt.Println("                              on every interface and the scrape config to add is printed")
+		fmt.Println("  --port N                    Grafana port on the DGX (default 3001)")
+		fmt.Println("  --local-port N              Local end of the tunnel (default: the same port, or the next free one)")
+		fmt.Println("  --lan                       Listen on every interface so others on the network can use Grafana")
+		fmt.Println("  --no-tunnel                 Do not open a tunnel")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  dgx run monitoring grafana")
+		fmt.Println("  dgx run monitoring grafana --prometheus http://prometheus.lan:9090 --lan")
+		fmt.Println("  dgx run monitoring status")
+		fmt.Println("  dgx run monitoring uninstall --purge")
+	case "registry-cache":
+		fmt.Println("Pull-through registry cache (registry-cache) playbook")
+		fmt.Println("Commands:")
+		fmt.Println("  install     - Start the Docker Hub and NGC caches and add the Docker Hub one to dockerd's registry-mirrors")
+		fmt.Println("  status      - Show the caches, how many repositories and how much data they hold, and whether dockerd uses them")
+		fmt.Println("  uninstall   - Remove the caches and the mirror (--purge also deletes the cached layers, --yes skips the prompt)")
+		fmt.Println()
+		fmt.Println("Each upstream gets a registry:3 container proxying it, with layers kept in a volume, so repeated")
+		fmt.Println("pulls of the same image come from the Spark's disk. dockerd only uses registry-mirrors for Docker")
+		fmt.Println("Hub, so those pulls are cached transparently; pull NGC images through the second cache by")
+		fmt.Println("prefixing its address, e.g. docker pull 127.0.0.1:5001/nvidia/pytorch:25.09-py3. Credentials")
+		fmt.Println("stored with 'dgx registry set docker.io' or 'dgx registry set nvcr.io' (or NGC_API_KEY in the")
+		fmt.Println("env store) authenticate the upstream pulls; without them the caches pull anonymously. dockerd is")
+		fmt.Println("reloaded, not restarted, so running containers keep running.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --port N                    Docker Hub cache port; the NGC cache uses the next one (default 5000)")
+		fmt.Println("  --ttl DURATION              How long unused layers are kept (default 168h)")
+		fmt.Println("  --lan                       Listen on every interface so CI runners on the network can pull through")
+		fmt.Println("                              the caches (they speak plain HTTP: list them under insecure-registries)")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  dgx run registry-cache install")
+		fmt.Println("  dgx run registry-cache install --ttl 336h --lan")
+		fmt.Println("  dgx run registry-cache status")
+		fmt.Println("  dgx run registry-cache uninstall --purge")
+	case "whisper":
+		fmt.Println("Audio transcription (whisper) playbook")
+		fmt.Println("Commands:")
+		fmt.Println("  <audio-file>        - Upload the file, transcribe it on the GPU, and save the transcript locally")
+		fmt.Println("  setup               - Build (or rebuild) the faster-whisper image on the DGX; done on first use otherwise")
+		fmt.Println("  cache [list]        - Show the whisper models cached on the DGX and their size")
+		fmt.Println("  cache clear [model] - Delete one cached model, or all of them (--yes to skip the prompt)")
+		fmt.Println()
+		fmt.Println("Segments stream to stderr as they are transcribed. Models are downloaded from Hugging Face")
+		fmt.Println("into ~/.cache/dgx/whisper on the DGX and reused; the uploaded audio is deleted afterwards.")
+		fmt.Println("The dgx-whisper image builds CTranslate2 with CUDA, since there are no GPU wheels for aarch64.")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fmt.Println("  --model NAME                  faster-whisper size (large-v3, turbo, medium, ...) or Hugging Face repo (default large-v3)")
+		fmt.Println("  --language CODE               Spoken language, e.g. en; detected when left out")
+		fmt.Println("  --translate                   Translate the speech to English instead of transcribing it")
+		fmt.Println("  --format txt|srt|vtt|json     Transcript format (default txt)")
+		fmt.Println("  -o, --output FILE             Where to write it (default: the audio file's name with the format's extension; - for stdout)")
+		fmt.Println("  --image IMAGE                 Use this image instead of dgx-whisper (it needs python3 and faster-whisper)")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  dgx run whisper meeting.m4a")
+		fmt.Println("  dgx run whisper talk.mp3 --format srt --language en")
+		fmt.Println("  dgx run whisper interview.wav --model turbo -o - | less")
+		fmt.Println("  dgx run whisper cache")
+		fmt.Println("  dgx run whisper cache clear large-v3")
 	default:
 		fmt.Printf("No dedicated help available for playbook '%s'. Refer to README/PLAYBOOKS for usage.\n", name)
 	}
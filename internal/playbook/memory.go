@@ -0,0 +1,403 @@
+package playbook
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/weatherman/dgx-manager/internal/artifacts"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/ui"
+)
+
+// swapFile is the swap file the memory playbook manages
+const swapFile = "/swapfile"
+
+// zramConfig is the systemd zram-generator config written by 'memory configure --zram on'
+const zramConfig = "/etc/systemd/zram-generator.conf"
+
+// memorySysctl holds the swappiness set by the playbook
+const memorySysctl = "/etc/sysctl.d/99-dgx-memory.conf"
+
+var (
+	swapSizePattern = regexp.MustCompile(`^([0-9]+)([MmGg])$`)
+	// zram sizes are a percentage of RAM or an absolute size
+	zramSizePattern = regexp.MustCompile(`^([0-9]{1,3})%$|^([0-9]+)([MmGg])$`)
+)
+
+// memoryOptions are the changes requested from 'dgx run memory configure'
+type memoryOptions struct {
+	SwapMB     int    // -1 leaves swap alone, 0 removes the swap file
+	Zram       string // "", "on", or "off"
+	ZramSize   string // zram-generator size expression
+	Swappiness int    // -1 leaves it alone
+}
+
+// runMemory handles swap, zram, and memory pressure commands
+func (m *Manager) runMemory(args []string) error {
+	command := "status"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "--") {
+		command, args = args[0], args[1:]
+	}
+
+	args, resume := removeFlag(args, "--resume")
+	args, swap := flagValue(args, "--swap")
+	args, zram := flagValue(args, "--zram")
+	args, zramSize := flagValue(args, "--zram-size")
+	args, swappiness := flagValue(args, "--swappiness")
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+	}
+
+	switch command {
+	case "status":
+		return m.memoryStatus()
+	case "configure":
+		opts, err := parseMemoryOptions(swap, zram, zramSize, swappiness)
+		if err != nil {
+			return err
+		}
+		return m.memoryConfigure(opts, resume)
+	default:
+		return fmt.Errorf("unknown memory command: %s. Usage: dgx run memory [status|configure] [--swap 64G|off] [--zram on|off] [--zram-size 25%%|16G] [--swappiness N] [--resume]", command)
+	}
+}
+
+// parseMemoryOptions validates the configure flags
+func parseMemoryOptions(swap, zram, zramSize, swappiness string) (memoryOptions, error) {
+	opts := memoryOptions{SwapMB: -1, Swappiness: -1}
+	switch strings.ToLower(swap) {
+	case "":
+	case "0", "off", "none":
+		opts.SwapMB = 0
+	default:
+		match := swapSizePattern.FindStringSubmatch(swap)
+		if match == nil {
+			return opts, fmt.Errorf("invalid --swap %q: use a size like 64G or 512M, or off", swap)
+		}
+		n, _ := strconv.Atoi(match[1])
+		if strings.EqualFold(match[2], "g") {
+			n *= 1024
+		}
+		if n < 256 {
+			return opts, fmt.Errorf("--swap %s is too small: use at least 256M", swap)
+		}
+		opts.SwapMB = n
+	}
+
+	switch strings.ToLower(zram) {
+	case "", "on", "off":
+		opts.Zram = strings.ToLower(zram)
+	default:
+		return opts, fmt.Errorf("invalid --zram %q: use on or off", zram)
+	}
+
+	if zramSize != "" {
+		if opts.Zram == "off" {
+			return opts, fmt.Errorf("--zram-size needs --zram on")
+		}
+		match := zramSizePattern.FindStringSubmatch(zramSize)
+		switch {
+		case match == nil:
+			return opts, fmt.Errorf("invalid --zram-size %q: use a percentage of RAM like 25%% or a size like 16G", zramSize)
+		case match[1] != "":
+			pct, _ := strconv.Atoi(match[1])
+			if pct < 1 || pct > 100 {
+				return opts, fmt.Errorf("--zram-size %s must be between 1%% and 100%%", zramSize)
+			}
+			opts.ZramSize = fmt.Sprintf("ram * %d / 100", pct)
+		default:
+			n, _ := strconv.Atoi(match[2])
+			if strings.EqualFold(match[3], "g") {
+				n *= 1024
+			}
+			opts.ZramSize = strconv.Itoa(n)
+		}
+		opts.Zram = "on"
+	}
+	if opts.Zram == "on" && opts.ZramSize == "" {
+		// A quarter of RAM compresses to roughly a tenth, leaving the rest for the GPU
+		opts.ZramSize = "ram / 4"
+	}
+
+	if swappiness != "" {
+		n, err := strconv.Atoi(swappiness)
+		if err != nil || n < 0 || n > 200 {
+			return opts, fmt.Errorf("invalid --swappiness %q: want 0-200", swappiness)
+		}
+		opts.Swappiness = n
+	}
+
+	if opts.SwapMB < 0 && opts.Zram == "" && opts.Swappiness < 0 {
+		return opts, fmt.Errorf("nothing to configure: pass --swap, --zram, or --swappiness")
+	}
+	return opts, nil
+}
+
+// memorySteps builds the configure steps. Swap is only turned off when the pages in it fit
+// into available memory, and a new swap file must leave 10 GiB of the disk free.
+func memorySteps(opts memoryOptions) []Step {
+	var steps []Step
+	if opts.SwapMB == 0 {
+		steps = append(steps, Step{
+			Name:        "swapfile",
+			Description: "Remove " + swapFile,
+			Command: fmt.Sprintf(`set -euo pipefail
+f=%[1]s
+if swapon --show=NAME --noheadings | grep -qx "$f"; then
+  used=$(swapon --show=NAME,USED --bytes --noheadings | awk -v f="$f" '$1 == f {print $2}')
+  avail=$(awk '/^MemAvailable:/ {print $2 * 1024}' /proc/meminfo)
+  if [ "${used:-0}" -gt "$avail" ]; then
+    echo "$f holds more than the available memory; free memory first" >&2
+    exit 1
+  fi
+  sudo swapoff "$f"
+fi
+sudo rm -f "$f"
+sudo sed -i '\#^%[1]s[[:space:]]#d' /etc/fstab
+echo "Removed $f"`, swapFile),
+		})
+	} else if opts.SwapMB > 0 {
+		steps = append(steps, Step{
+			Name:        "swapfile",
+			Description: fmt.Sprintf("Swap file %s (%s)", swapFile, artifacts.FormatBytes(int64(opts.SwapMB)<<20)),
+			Command: fmt.Sprintf(`set -euo pipefail
+f=%[1]s
+want=$((%[2]d * 1024 * 1024))
+have=0
+if [ -f "$f" ]; then have=$(stat -c %%s "$f"); fi
+if [ "$have" -ne "$want" ]; then
+  if swapon --show=NAME --noheadings | grep -qx "$f"; then
+    used=$(swapon --show=NAME,USED --bytes --noheadings | awk -v f="$f" '$1 == f {print $2}')
+    avail=$(awk '/^MemAvailable:/ {print $2 * 1024}' /proc/meminfo)
+    if [ "${used:-0}" -gt "$avail" ]; then
+      echo "Cannot resize $f: it holds more than the available memory; free memory first" >&2
+      exit 1
+    fi
+    sudo swapoff "$f"
+  fi
+  free=$(df --output=avail -B1 "$(dirname "$f")" | tail -1)
+  if [ $((free + have)) -lt $((want + 10 * 1024 * 1024 * 1024)) ]; then
+    echo "Not enough disk space for a $((want >> 30)) GiB swap file (keeping 10 GiB free)" >&2
+    exit 1
+  fi
+  sudo rm -f "$f"
+  sudo fallocate -l "$want" "$f" 2>/dev/null || sudo dd if=/dev/zero of="$f" bs=1M count=%[2]d status=none
+  sudo chmod 600 "$f"
+  sudo mkswap "$f" >/dev/null
+  echo "Created $f"
+fi
+grep -q '^%[1]s[[:space:]]' /etc/fstab || echo '%[1]s none swap sw,pri=10 0 0' | sudo tee -a /etc/fstab >/dev/null
+swapon --show=NAME --noheadings | grep -qx "$f" || sudo swapon -p 10 "$f"`, swapFile, opts.SwapMB),
+		})
+	}
+
+	switch opts.Zram {
+	case "on":
+		// zram gets the higher priority so the swap file only takes what does not compress
+		conf := fmt.Sprintf("[zram0]\nzram-size = %s\ncompression-algorithm = zstd\nswap-priority = 100\n", opts.ZramSize)
+		steps = append(steps, Step{
+			Name:        "zram",
+			Description: "zram swap (" + opts.ZramSize + ", zstd)",
+			Command: fmt.Sprintf(`set -euo pipefail
+if [ ! -e /usr/lib/systemd/system-generators/zram-generator ] && [ ! -e /lib/systemd/system-generators/zram-generator ]; then
+  sudo apt-get update -qq
+  sudo env DEBIAN_FRONTEND=noninteractive apt-get install -y -qq systemd-zram-generator
+fi
+if systemctl is-active --quiet zramswap 2>/dev/null; then
+  sudo systemctl disable --now zramswap
+  echo "Disabled zramswap (zram-tools)"
+fi
+printf '%%s' %[1]s | sudo tee %[2]s >/dev/null
+sudo systemctl daemon-reload
+sudo systemctl restart systemd-zram-setup@zram0.service`, ssh.ShellQuote(conf), zramConfig),
+		})
+	case "off":
+		steps = append(steps, Step{
+			Name:        "zram",
+			Description: "Disable zram swap",
+			Command: fmt.Sprintf(`set -euo pipefail
+if swapon --show=NAME --noheadings | grep -qx /dev/zram0; then
+  used=$(swapon --show=NAME,USED --bytes --noheadings | awk '$1 == "/dev/zram0" {print $2}')
+  avail=$(awk '/^MemAvailable:/ {print $2 * 1024}' /proc/meminfo)
+  if [ "${used:-0}" -gt "$avail" ]; then
+    echo "/dev/zram0 holds more than the available memory; free memory first" >&2
+    exit 1
+  fi
+fi
+sudo systemctl stop systemd-zram-setup@zram0.service 2>/dev/null || true
+sudo rm -f %s
+sudo systemctl daemon-reload
+echo "zram swap disabled"`, zramConfig),
+		})
+	}
+
+	if opts.Swappiness >= 0 {
+		steps = append(steps, Step{
+			Name:        "swappiness",
+			Description: fmt.Sprintf("vm.swappiness = %d", opts.Swappiness),
+			Command: fmt.Sprintf(`set -euo pipefail
+echo 'vm.swappiness = %d' | sudo tee %s >/dev/null
+sudo sysctl -q -p %s`, opts.Swappiness, memorySysctl, memorySysctl),
+		})
+	}
+	return steps
+}
+
+func (m *Manager) memoryConfigure(opts memoryOptions, resume bool) error {
+	fmt.Println("Configuring memory on the DGX")
+	if err := m.runSteps("memory configure", memorySteps(opts), resume); err != nil {
+		return fmt.Errorf("failed to configure memory: %w", err)
+	}
+	fmt.Println()
+	return m.memoryStatus()
+}
+
+// memoryStatusScript prints /proc/meminfo totals, pressure stall information, swap devices,
+// swappiness, and zram devices in @-marked sections
+const memoryStatusScript = `echo @meminfo; grep -E '^(MemTotal|MemAvailable|SwapTotal|SwapFree):' /proc/meminfo
+echo @pressure; cat /proc/pressure/memory 2>/dev/null
+echo @swaps; swapon --show=NAME,TYPE,SIZE,USED,PRIO --bytes --noheadings 2>/dev/null
+echo @swappiness; cat /proc/sys/vm/swappiness
+echo @zram; zramctl --output NAME,ALGORITHM,DATA,COMPR --bytes --noheadings 2>/dev/null`
+
+// swapDevice is one line of swapon --show
+type swapDevice struct {
+	Name     string
+	Type     string
+	Size     int64
+	Used     int64
+	Priority string
+}
+
+// zramDevice is one line of zramctl
+type zramDevice struct {
+	Name       string
+	Algorithm  string
+	Data       int64
+	Compressed int64
+}
+
+// memoryStatus is the parsed output of memoryStatusScript. Sizes are bytes and pressure
+// values are the percentage of time tasks stalled on memory.
+type memoryStatus struct {
+	Total, Available    int64
+	SwapTotal, SwapFree int64
+	Some10, Some60      float64
+	Full10, Full60      float64
+	HasPressure         bool
+	Swaps               []swapDevice
+	Swappiness          string
+	Zram                []zramDevice
+}
+
+func parseMemoryStatus(output string) memoryStatus {
+	var st memoryStatus
+	section := ""
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "@") {
+			section = line[1:]
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch section {
+		case "meminfo":
+			if len(fields) < 2 {
+				continue
+			}
+			kb, _ := strconv.ParseInt(fields[1], 10, 64)
+			switch strings.TrimSuffix(fields[0], ":") {
+			case "MemTotal":
+				st.Total = kb << 10
+			case "MemAvailable":
+				st.Available = kb << 10
+			case "SwapTotal":
+				st.SwapTotal = kb << 10
+			case "SwapFree":
+				st.SwapFree = kb << 10
+			}
+		case "pressure":
+			// some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+			values := map[string]float64{}
+			for _, f := range fields[1:] {
+				if k, v, ok := strings.Cut(f, "="); ok {
+					values[k], _ = strconv.ParseFloat(v, 64)
+				}
+			}
+			st.HasPressure = true
+			if fields[0] == "some" {
+				st.Some10, st.Some60 = values["avg10"], values["avg60"]
+			} else if fields[0] == "full" {
+				st.Full10, st.Full60 = values["avg10"], values["avg60"]
+			}
+		case "swaps":
+			if len(fields) < 5 {
+				continue
+			}
+			size, _ := strconv.ParseInt(fields[2], 10, 64)
+			used, _ := strconv.ParseInt(fields[3], 10, 64)
+			st.Swaps = append(st.Swaps, swapDevice{Name: fields[0], Type: fields[1], Size: size, Used: used, Priority: fields[4]})
+		case "swappiness":
+			st.Swappiness = fields[0]
+		case "zram":
+			if len(fields) < 4 {
+				continue
+			}
+			data, _ := strconv.ParseInt(fields[2], 10, 64)
+			compr, _ := strconv.ParseInt(fields[3], 10, 64)
+			st.Zram = append(st.Zram, zramDevice{Name: fields[0], Algorithm: fields[1], Data: data, Compressed: compr})
+		}
+	}
+	return st
+}
+
+func (m *Manager) memoryStatus() error {
+	output, err := m.sshClient.Execute(memoryStatusScript)
+	if err != nil {
+		return fmt.Errorf("failed to read memory status: %w", err)
+	}
+	st := parseMemoryStatus(output)
+
+	fmt.Printf("Memory:      %s available of %s (unified with the GPU)\n", artifacts.FormatBytes(st.Available), artifacts.FormatBytes(st.Total))
+	if st.SwapTotal > 0 {
+		fmt.Printf("Swap:        %s used of %s\n", artifacts.FormatBytes(st.SwapTotal-st.SwapFree), artifacts.FormatBytes(st.SwapTotal))
+	} else {
+		fmt.Println("Swap:        none")
+	}
+	if st.HasPressure {
+		fmt.Printf("Pressure:    some %.2f%% / %.2f%%, full %.2f%% / %.2f%% (10s / 60s stalled)\n", st.Some10, st.Some60, st.Full10, st.Full60)
+	}
+	fmt.Printf("Swappiness:  %s\n", ui.OrDash(st.Swappiness))
+
+	if len(st.Swaps) > 0 {
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "DEVICE\tTYPE\tSIZE\tUSED\tPRIORITY")
+		for _, s := range st.Swaps {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.Name, s.Type, artifacts.FormatBytes(s.Size), artifacts.FormatBytes(s.Used), s.Priority)
+		}
+		w.Flush()
+	}
+	for _, z := range st.Zram {
+		ratio := "-"
+		if z.Compressed > 0 {
+			ratio = fmt.Sprintf("%.1fx", float64(z.Data)/float64(z.Compressed))
+		}
+		fmt.Printf("%s: %s, %s stored in %s (%s)\n", z.Name, z.Algorithm, artifacts.FormatBytes(z.Data), artifacts.FormatBytes(z.Compressed), ratio)
+	}
+
+	switch {
+	case st.Full10 >= 10:
+		fmt.Println("\nHeavy memory pressure: tasks are stalled waiting for memory. Unload models or add swap/zram.")
+	case st.Some10 >= 10:
+		fmt.Println("\nModerate memory pressure: the kernel is reclaiming memory. Consider zram (dgx run memory configure --zram on).")
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package playbook
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMemoryOptions(t *testing.T) {
+	opts, err := parseMemoryOptions("64G", "on", "", "")
+	if err != nil {
+		t.Fatalf("parseMemoryOptions: %v", err)
+	}
+	if opts.SwapMB != 64*1024 || opts.Zram != "on" || opts.ZramSize != "ram / 4" || opts.Swappiness != -1 {
+		t.Fatalf("unexpected options: %+v", opts)
+	}
+
+	opts, err = parseMemoryOptions("off", "", "50%", "100")
+	if err != nil {
+		t.Fatalf("parseMemoryOptions: %v", err)
+	}
+	if opts.SwapMB != 0 || opts.Zram != "on" || opts.ZramSize != "ram * 50 / 100" || opts.Swappiness != 100 {
+		t.Fatalf("unexpected options: %+v", opts)
+	}
+
+	for _, bad := range [][4]string{
+		{"", "", "", ""},
+		{"64", "", "", ""},
+		{"64T", "", "", ""},
+		{"100M", "", "", ""},
+		{"", "maybe", "", ""},
+		{"", "off", "16G", ""},
+		{"", "", "150%", ""},
+		{"", "", "", "300"},
+	} {
+		if _, err := parseMemoryOptions(bad[0], bad[1], bad[2], bad[3]); err == nil {
+			t.Fatalf("parseMemoryOptions(%q) accepted invalid input", bad)
+		}
+	}
+}
+
+func TestMemorySteps(t *testing.T) {
+	steps := memorySteps(memoryOptions{SwapMB: 1024, Zram: "on", ZramSize: "ram / 4", Swappiness: 60})
+	if len(steps) != 3 || steps[0].Name != "swapfile" || steps[1].Name != "zram" || steps[2].Name != "swappiness" {
+		t.Fatalf("unexpected steps: %+v", steps)
+	}
+	if !strings.Contains(steps[0].Command, "want=$((1024 * 1024 * 1024))") || !strings.Contains(steps[0].Command, "swapon -p 10") {
+		t.Fatalf("unexpected swap step:\n%s", steps[0].Command)
+	}
+	if !strings.Contains(steps[1].Command, "zram-size = ram / 4") {
+		t.Fatalf("unexpected zram step:\n%s", steps[1].Command)
+	}
+
+	steps = memorySteps(memoryOptions{SwapMB: -1, Zram: "off", Swappiness: -1})
+	if len(steps) != 1 || !strings.Contains(steps[0].Command, "rm -f "+zramConfig) {
+		t.Fatalf("unexpected steps: %+v", steps)
+	}
+}
+
+func TestParseMemoryStatus(t *testing.T) {
+	output := `@meminfo
+MemTotal:       128000000 kB
+MemAvailable:    32000000 kB
+SwapTotal:       67108864 kB
+SwapFree:        66060288 kB
+@pressure
+some avg10=12.50 avg60=3.00 avg300=1.00 total=123
+full avg10=1.25 avg60=0.50 avg300=0.10 total=45
+@swaps
+/dev/zram0 partition 34359738368 1073741824 100
+/swapfile  file      68719476736 0          10
+@swappiness
+60
+@zram
+/dev/zram0 zstd 1073741824 268435456
+`
+	st := parseMemoryStatus(output)
+	if st.Total != 128000000<<10 || st.Available != 32000000<<10 || st.SwapTotal-st.SwapFree != 1<<30 {
+		t.Fatalf("unexpected totals: %+v", st)
+	}
+	if !st.HasPressure || st.Some10 != 12.5 || st.Full60 != 0.5 {
+		t.Fatalf("unexpected pressure: %+v", st)
+	}
+	if len(st.Swaps) != 2 || st.Swaps[1].Name != "/swapfile" || st.Swaps[0].Used != 1<<30 || st.Swaps[0].Priority != "100" {
+		t.Fatalf("unexpected swaps: %+v", st.Swaps)
+	}
+	if st.Swappiness != "60" || len(st.Zram) != 1 || st.Zram[0].Compressed != 256<<20 {
+		t.Fatalf("unexpected zram: %+v", st)
+	}
+}
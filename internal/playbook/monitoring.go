@@ -0,0 +1,436 @@
+package playbook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/ui"
+)
+
+// Monitoring stack. Every container shares the host network, so Prometheus scrapes the
+// exporters and model servers on the DGX's loopback and Grafana reaches Prometheus the
+// same way. Generated configuration lives under monitoringDir on the DGX; metrics and
+// Grafana's own database are kept in volumes.
+const (
+	monitoringDir       = "$HOME/.config/dgx/monitoring"
+	grafanaSecretFile   = monitoringDir + "/grafana.secret"
+	grafanaContainer    = "dgx-grafana"
+	grafanaImage        = "grafana/grafana-oss:latest"
+	grafanaVolume       = "dgx-grafana"
+	grafanaPort         = 3001
+	prometheusImage     = "prom/prometheus:latest"
+	prometheusVolume    = "dgx-prometheus"
+	prometheusPort      = 9090
+	monitoringUID       = "dgx-spark"
+	monitoringSourceUID = "dgx-prometheus"
+)
+
+// monitoringService is a container of the stack
+type monitoringService struct {
+	Name  string
+	Image string
+	Port  int
+}
+
+var (
+	prometheusService   = monitoringService{Name: "dgx-prometheus", Image: prometheusImage, Port: prometheusPort}
+	nodeExporterService = monitoringService{Name: "dgx-node-exporter", Image: "prom/node-exporter:latest", Port: 9100}
+	dcgmExporterService = monitoringService{Name: "dgx-dcgm-exporter", Image: "nvcr.io/nvidia/k8s/dcgm-exporter:4.2.3-4.1.3-ubuntu22.04", Port: 9400}
+)
+
+// scrapeTarget is an endpoint Prometheus collects metrics from
+type scrapeTarget struct {
+	Job  string
+	Port int
+	Path string
+}
+
+// monitoringTargets are scraped on the DGX. The model servers are only up while they run,
+// which Prometheus shows as a down target rather than an error.
+var monitoringTargets = []scrapeTarget{
+	{Job: "node", Port: 9100, Path: "/metrics"},
+	{Job: "gpu", Port: 9400, Path: "/metrics"},
+	{Job: "dmr", Port: 12434, Path: "/metrics"},
+	{Job: "vllm", Port: 8000, Path: "/metrics"},
+}
+
+// grafanaOptions are the flags of 'dgx run monitoring grafana'
+type grafanaOptions struct {
+	prometheus string // external Prometheus URL; empty deploys one on the DGX
+	port       int
+	localPort  int
+	lan        bool
+	noTunnel   bool
+}
+
+// runMonitoring handles the monitoring stack
+func (m *Manager) runMonitoring(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("monitoring command required. Usage: dgx run monitoring <grafana|status|uninstall>")
+	}
+	command, rest := args[0], args[1:]
+
+	switch command {
+	case "grafana":
+		opts, err := parseGrafanaOptions(rest)
+		if err != nil {
+			return err
+		}
+		return m.monitoringGrafana(opts)
+	case "status":
+		if len(rest) > 0 {
+			return fmt.Errorf("unexpected arguments: %s", strings.Join(rest, " "))
+		}
+		return m.monitoringStatus()
+	case "uninstall":
+		rest, yes := removeFlag(rest, "--yes")
+		rest, purge := removeFlag(rest, "--purge")
+		if len(rest) > 0 {
+			return fmt.Errorf("unexpected arguments: %s", strings.Join(rest, " "))
+		}
+		return m.monitoringUninstall(purge, yes)
+	default:
+		return fmt.Errorf("unknown monitoring command: %s", command)
+	}
+}
+
+func parseGrafanaOptions(args []string) (grafanaOptions, error) {
+	var opts grafanaOptions
+	var port, localPort string
+	args, opts.lan = removeFlag(args, "--lan")
+	args, opts.noTunnel = removeFlag(args, "--no-tunnel")
+	args, opts.prometheus = flagValue(args, "--prometheus")
+	args, port = flagValue(args, "--port")
+	args, localPort = flagValue(args, "--local-port")
+	if len(args) > 0 {
+		return opts, fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+	}
+
+	var err error
+	if opts.port, opts.localPort, err = parsePorts(port, localPort, grafanaPort); err != nil {
+		return opts, err
+	}
+	if opts.prometheus != "" {
+		parsed, err := url.Parse(opts.prometheus)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return opts, fmt.Errorf("invalid --prometheus %q (want a URL such as http://prometheus.lan:9090)", opts.prometheus)
+		}
+		opts.prometheus = strings.TrimSuffix(opts.prometheus, "/")
+	}
+	return opts, nil
+}
+
+// prometheusConfig returns prometheus.yml scraping host's exporters and model servers
+func prometheusConfig(host string) string {
+	var b strings.Builder
+	b.WriteString("global:\n  scrape_interval: 15s\n\nscrape_configs:\n")
+	for _, t := range monitoringTargets {
+		fmt.Fprintf(&b, "  - job_name: %s\n    metrics_path: %s\n    static_configs:\n      - targets: ['%s:%d']\n", t.Job, t.Path, host, t.Port)
+	}
+	return b.String()
+}
+
+// grafanaDatasource returns the provisioning file for the Prometheus data source
+func grafanaDatasource(prometheusURL string) string {
+	return fmt.Sprintf(`apiVersion: 1
+datasources:
+  - name: Prometheus
+    uid: %s
+    type: prometheus
+    access: proxy
+    url: %s
+    isDefault: true
+`, monitoringSourceUID, prometheusURL)
+}
+
+// grafanaDashboardProvider loads the dashboards next to it into a DGX Spark folder
+const grafanaDashboardProvider = `apiVersion: 1
+providers:
+  - name: dgx
+    folder: DGX Spark
+    type: file
+    allowUiUpdates: true
+    options:
+      path: /etc/grafana/provisioning/dashboards
+`
+
+// dashboardPanel is one time-series panel of the provisioned dashboard
+type dashboardPanel struct {
+	Title string
+	Unit  string
+	Exprs []string // one series per query; legends use the metric labels
+}
+
+// monitoringPanels are laid out two per row: GPU, then the unified memory and CPU the GPU
+// shares with the system, then the model servers
+var monitoringPanels = []dashboardPanel{
+	{Title: "GPU utilization", Unit: "percent", Exprs: []string{"DCGM_FI_DEV_GPU_UTIL"}},
+	{Title: "GPU temperature", Unit: "celsius", Exprs: []string{"DCGM_FI_DEV_GPU_TEMP"}},
+	{Title: "GPU power", Unit: "watt", Exprs: []string{"DCGM_FI_DEV_POWER_USAGE"}},
+	{Title: "GPU SM clock", Unit: "hertz", Exprs: []string{"DCGM_FI_DEV_SM_CLOCK * 1000000"}},
+	{Title: "Unified memory used", Unit: "bytes", Exprs: []string{"node_memory_MemTotal_bytes - node_memory_MemAvailable_bytes", "node_memory_SwapTotal_bytes - node_memory_SwapFree_bytes"}},
+	{Title: "CPU busy", Unit: "percent", Exprs: []string{`100 * (1 - avg(rate(node_cpu_seconds_total{mode="idle"}[1m])))`}},
+	{Title: "DMR tokens/s", Unit: "short", Exprs: []string{"sum(rate(llamacpp:tokens_predicted_total[1m]))", "sum(rate(llamacpp:prompt_tokens_total[1m]))"}},
+	{Title: "DMR requests in flight", Unit: "short", Exprs: []string{"sum(llamacpp:requests_processing)", "sum(llamacpp:requests_deferred)"}},
+	{Title: "vLLM tokens/s", Unit: "short", Exprs: []string{"sum(rate(vllm:generation_tokens_total[1m]))", "sum(rate(vllm:prompt_tokens_total[1m]))"}},
+	{Title: "Network throughput", Unit: "Bps", Exprs: []string{`sum(rate(node_network_receive_bytes_total{device!~"lo|docker.*|veth.*"}[1m]))`, `sum(rate(node_network_transmit_bytes_total{device!~"lo|docker.*|veth.*"}[1m]))`}},
+}
+
+// grafanaDashboard returns the DGX Spark dashboard as Grafana JSON
+func grafanaDashboard() ([]byte, error) {
+	datasource := map[string]string{"type": "prometheus", "uid": monitoringSourceUID}
+	panels := make([]map[string]any, len(monitoringPanels))
+	for i, p := range monitoringPanels {
+		targets := make([]map[string]any, len(p.Exprs))
+		for j, expr := range p.Exprs {
+			targets[j] = map[string]any{"refId": string(rune('A' + j)), "expr": expr, "datasource": datasource}
+		}
+		panels[i] = map[string]any{
+			"id":          i + 1,
+			"type":        "timeseries",
+			"title":       p.Title,
+			"datasource":  datasource,
+			"gridPos":     map[string]int{"x": (i % 2) * 12, "y": (i / 2) * 8, "w": 12, "h": 8},
+			"fieldConfig": map[string]any{"defaults": map[string]string{"unit": p.Unit}, "overrides": []any{}},
+			"targets":     targets,
+		}
+	}
+	return json.MarshalIndent(map[string]any{
+		"uid":           monitoringUID,
+		"title":         "DGX Spark",
+		"tags":          []string{"dgx"},
+		"timezone":      "browser",
+		"schemaVersion": 39,
+		"refresh":       "30s",
+		"time":          map[string]string{"from": "now-1h", "to": "now"},
+		"panels":        panels,
+	}, "", "  ")
+}
+
+// writeRemoteFile returns a command that writes content to path on the DGX. path may use
+// $HOME and is double-quoted.
+func writeRemoteFile(path, content string) string {
+	return fmt.Sprintf(`mkdir -p "$(dirname "%[1]s")" && printf '%%s' %[2]s > "%[1]s"`, path, ssh.ShellQuote(content))
+}
+
+// runService returns a command that (re)creates a stack container on the host network
+func runService(s monitoringService, flags string, args string) string {
+	return fmt.Sprintf("{ docker rm -f %[1]s >/dev/null 2>&1; true; } && docker run -d --name %[1]s --restart unless-stopped --network host %[2]s %[3]s %[4]s",
+		s.Name, flags, ssh.ShellQuote(s.Image), args)
+}
+
+func (m *Manager) monitoringGrafana(opts grafanaOptions) error {
+	external := opts.prometheus != ""
+	total := 5
+	step := func(n int, msg string) { fmt.Printf("[%d/%d] %s\n", n, total, msg) }
+
+	// Exporters listen on every interface when an external Prometheus has to reach them
+	listen := "127.0.0.1"
+	if external {
+		listen = "0.0.0.0"
+	}
+	services := []monitoringService{nodeExporterService, dcgmExporterService}
+	if !external {
+		services = append(services, prometheusService)
+	}
+	services = append(services, monitoringService{Name: grafanaContainer, Image: grafanaImage, Port: opts.port})
+
+	step(1, "Pulling images...")
+	for _, s := range services {
+		if output, err := m.execStep("docker pull " + ssh.ShellQuote(s.Image)); err != nil {
+			printOutput(output)
+			return fmt.Errorf("failed to pull %s: %w", s.Image, err)
+		}
+	}
+
+	step(2, "Starting the node and GPU exporters...")
+	node := runService(nodeExporterService, "--pid host -v /:/host:ro,rslave",
+		fmt.Sprintf("--path.rootfs=/host --web.listen-address=%s:%d", listen, nodeExporterService.Port))
+	if output, err := m.sshClient.Execute(node); err != nil {
+		printOutput(output)
+		return fmt.Errorf("failed to start the node exporter: %w", err)
+	}
+	gpu := runService(dcgmExporterService, "--gpus all --cap-add SYS_ADMIN",
+		fmt.Sprintf("-a %s:%d", listen, dcgmExporterService.Port))
+	if output, err := m.sshClient.Execute(gpu); err != nil {
+		// The rest of the dashboard still works without GPU metrics
+		printOutput(output)
+		fmt.Fprintf(os.Stderr, "Warning: the DCGM exporter did not start, so the GPU panels stay empty: %v\n", err)
+	}
+
+	prometheusURL := opts.prometheus
+	if external {
+		step(3, fmt.Sprintf("Using the Prometheus at %s", opts.prometheus))
+	} else {
+		prometheusURL = fmt.Sprintf("http://127.0.0.1:%d", prometheusPort)
+		step(3, fmt.Sprintf("Starting Prometheus on 127.0.0.1:%d (metrics are kept in the %s volume)...", prometheusPort, prometheusVolume))
+		config := monitoringDir + "/prometheus.yml"
+		run := writeRemoteFile(config, prometheusConfig("127.0.0.1")) + " && " + runService(prometheusService,
+			fmt.Sprintf(`-v "%s":/etc/prometheus/prometheus.yml:ro -v %s:/prometheus`, config, prometheusVolume),
+			fmt.Sprintf("--config.file=/etc/prometheus/prometheus.yml --storage.tsdb.path=/prometheus --storage.tsdb.retention.time=15d --web.listen-address=127.0.0.1:%d", prometheusPort))
+		if output, err := m.sshClient.Execute(run); err != nil {
+			printOutput(output)
+			return fmt.Errorf("failed to start Prometheus: %w", err)
+		}
+	}
+
+	step(4, fmt.Sprintf("Starting Grafana on port %d with the DGX Spark dashboard...", opts.port))
+	dashboard, err := grafanaDashboard()
+	if err != nil {
+		return err
+	}
+	host := "127.0.0.1"
+	if opts.lan {
+		host = "0.0.0.0"
+	}
+	provisioning := monitoringDir + "/provisioning"
+	// The admin password is generated once and kept on the DGX, like Open WebUI's secret
+	run := strings.Join([]string{
+		writeRemoteFile(provisioning+"/datasources/prometheus.yml", grafanaDatasource(prometheusURL)),
+		writeRemoteFile(provisioning+"/dashboards/dgx.yml", grafanaDashboardProvider),
+		writeRemoteFile(provisioning+"/dashboards/dgx-spark.json", string(dashboard)),
+		fmt.Sprintf(`{ [ -s "%[1]s" ] || (umask 077; head -c 18 /dev/urandom | base64 | tr -d '/+=' > "%[1]s"); }`, grafanaSecretFile),
+		runService(monitoringService{Name: grafanaContainer, Image: grafanaImage},
+			fmt.Sprintf(`-v %s:/var/lib/grafana -v "%s":/etc/grafana/provisioning:ro -e GF_SERVER_HTTP_ADDR=%s -e GF_SERVER_HTTP_PORT=%d -e GF_SECURITY_ADMIN_PASSWORD="$(cat "%s")" -e GF_DASHBOARDS_DEFAULT_HOME_DASHBOARD_PATH=/etc/grafana/provisioning/dashboards/dgx-spark.json`,
+				grafanaVolume, provisioning, host, opts.port, grafanaSecretFile), ""),
+	}, " && ")
+	if output, err := m.sshClient.Execute(run); err != nil {
+		printOutput(output)
+		return fmt.Errorf("failed to start Grafana: %w", err)
+	}
+
+	step(5, "Waiting for Grafana to answer...")
+	wait := fmt.Sprintf(`i=0; until curl -sf -o /dev/null http://127.0.0.1:%d/api/health; do
+  i=$((i+2)); [ $i -ge 120 ] && exit 1
+  docker inspect -f '{{.State.Running}}' %s 2>/dev/null | grep -q true || exit 2
+  sleep 2
+done`, opts.port, grafanaContainer)
+	if _, err := m.sshClient.Execute(wait); err != nil {
+		return fmt.Errorf("Grafana did not come up; check 'dgx exec docker logs %s': %w", grafanaContainer, err)
+	}
+	password, _ := m.sshClient.Execute(fmt.Sprintf(`cat "%s"`, grafanaSecretFile))
+
+	fmt.Printf("\nGrafana is running with the DGX Spark dashboard.\n")
+	fmt.Printf("Log in as admin with password %s (kept in %s on the DGX).\n", strings.TrimSpace(password), strings.Replace(grafanaSecretFile, "$HOME", "~", 1))
+	if external {
+		fmt.Printf("\nAdd the DGX to %s's scrape_configs:\n\n%s\n", opts.prometheus, prometheusConfig(m.sshClient.Host()))
+	}
+	if opts.lan {
+		fmt.Printf("Others on your network can open http://%s:%d\n", m.sshClient.Host(), opts.port)
+	}
+	m.openTunnel(opts.localPort, opts.port, "Grafana", opts.noTunnel)
+	return nil
+}
+
+// promQueryResult is the part of a Prometheus /api/v1/query answer that status reads
+type promQueryResult struct {
+	Data struct {
+		Result []struct {
+			Metric map[string]string `json:"metric"`
+			Value  []any             `json:"value"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// parseTargetHealth maps each scrape job in an "up" query to whether it answered
+func parseTargetHealth(output string) (map[string]bool, error) {
+	var result promQueryResult
+	if err := json.Unmarshal([]byte(output), &result); err != nil {
+		return nil, fmt.Errorf("unexpected Prometheus answer: %w", err)
+	}
+	health := map[string]bool{}
+	for _, r := range result.Data.Result {
+		if len(r.Value) == 2 {
+			health[r.Metric["job"]] = r.Value[1] == "1"
+		}
+	}
+	return health, nil
+}
+
+func (m *Manager) monitoringStatus() error {
+	names := []string{nodeExporterService.Name, dcgmExporterService.Name, prometheusService.Name, grafanaContainer}
+	filters := make([]string, len(names))
+	for i, name := range names {
+		filters[i] = fmt.Sprintf("--filter 'name=^%s$'", name)
+	}
+	output, err := m.sshClient.Execute(fmt.Sprintf("docker ps -a %s --format '{{.Names}}\t{{.Status}}'", strings.Join(filters, " ")))
+	if err != nil {
+		return fmt.Errorf("failed to check status: %w", err)
+	}
+	status := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if name, s, ok := strings.Cut(line, "\t"); ok {
+			status[name] = s
+		}
+	}
+	if len(status) == 0 {
+		fmt.Println("The monitoring stack is not installed.")
+		fmt.Println("\nTo install it:")
+		fmt.Println("  dgx run monitoring grafana")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CONTAINER\tSTATUS")
+	for _, name := range names {
+		fmt.Fprintf(w, "%s\t%s\n", name, ui.OrDash(status[name]))
+	}
+	w.Flush()
+
+	if status[prometheusService.Name] == "" {
+		return nil
+	}
+	answer, err := m.sshClient.Execute(fmt.Sprintf("curl -sf 'http://127.0.0.1:%d/api/v1/query?query=up'", prometheusPort))
+	if err != nil {
+		fmt.Println("\nPrometheus is not answering.")
+		return nil
+	}
+	health, err := parseTargetHealth(answer)
+	if err != nil {
+		return err
+	}
+	jobs := make([]string, 0, len(health))
+	for job := range health {
+		jobs = append(jobs, job)
+	}
+	sort.Strings(jobs)
+	fmt.Println("\nScrape targets:")
+	for _, job := range jobs {
+		state := "down"
+		if health[job] {
+			state = "up"
+		}
+		fmt.Printf("  %-6s %s\n", job, state)
+	}
+	return nil
+}
+
+func (m *Manager) monitoringUninstall(purge, yes bool) error {
+	prompt := "Remove the monitoring containers? Metrics and Grafana settings are kept in the " + prometheusVolume + " and " + grafanaVolume + " volumes."
+	if purge {
+		prompt = "Remove the monitoring stack and delete its metrics, Grafana settings, and generated configuration?"
+	}
+	if err := m.confirmDestructive(prompt, yes); err != nil {
+		return err
+	}
+	cmd := fmt.Sprintf("docker rm -f %s %s %s %s >/dev/null 2>&1; true", grafanaContainer, prometheusService.Name, dcgmExporterService.Name, nodeExporterService.Name)
+	if purge {
+		cmd += fmt.Sprintf(` && docker volume rm -f %s %s && rm -rf "%s"`, prometheusVolume, grafanaVolume, monitoringDir)
+	}
+	if output, err := m.execStep(cmd); err != nil {
+		printOutput(output)
+		return fmt.Errorf("failed to remove the monitoring stack: %w", err)
+	}
+	if purge {
+		fmt.Println("Monitoring stack and its data removed.")
+	} else {
+		fmt.Println("Monitoring stack removed; reinstalling picks up the kept metrics and dashboards.")
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package playbook
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseGrafanaOptions(t *testing.T) {
+	opts, err := parseGrafanaOptions([]string{"--prometheus", "http://prom.lan:9090/", "--local-port", "3300"})
+	if err != nil {
+		t.Fatalf("parseGrafanaOptions: %v", err)
+	}
+	if opts.prometheus != "http://prom.lan:9090" || opts.port != grafanaPort || opts.localPort != 3300 {
+		t.Fatalf("options = %+v", opts)
+	}
+	for _, args := range [][]string{
+		{"--prometheus", "prom.lan:9090"},
+		{"--port", "99999"},
+		{"extra"},
+	} {
+		if _, err := parseGrafanaOptions(args); err == nil {
+			t.Fatalf("parseGrafanaOptions(%v): expected an error", args)
+		}
+	}
+}
+
+func TestGrafanaDashboard(t *testing.T) {
+	data, err := grafanaDashboard()
+	if err != nil {
+		t.Fatalf("grafanaDashboard: %v", err)
+	}
+	var dashboard struct {
+		UID    string `json:"uid"`
+		Panels []struct {
+			GridPos struct{ X, Y int } `json:"gridPos"`
+			Targets []struct {
+				Expr       string            `json:"expr"`
+				Datasource map[string]string `json:"datasource"`
+			} `json:"targets"`
+		} `json:"panels"`
+	}
+	if err := json.Unmarshal(data, &dashboard); err != nil {
+		t.Fatalf("dashboard is not valid JSON: %v", err)
+	}
+	if dashboard.UID != monitoringUID || len(dashboard.Panels) != len(monitoringPanels) {
+		t.Fatalf("dashboard %s has %d panels", dashboard.UID, len(dashboard.Panels))
+	}
+	if p := dashboard.Panels[3]; p.GridPos.X != 12 || p.GridPos.Y != 8 {
+		t.Fatalf("fourth panel at %+v, want the right half of the second row", p.GridPos)
+	}
+	for _, p := range dashboard.Panels {
+		for _, target := range p.Targets {
+			if target.Datasource["uid"] != monitoringSourceUID {
+				t.Fatalf("query %q does not use the provisioned data source", target.Expr)
+			}
+		}
+	}
+	if !strings.Contains(grafanaDatasource("http://127.0.0.1:9090"), "uid: "+monitoringSourceUID) {
+		t.Fatal("data source provisioning does not set the dashboard's uid")
+	}
+}
+
+func TestParseTargetHealth(t *testing.T) {
+	answer := `{"status":"success","data":{"resultType":"vector","result":[
+		{"metric":{"__name__":"up","instance":"127.0.0.1:9100","job":"node"},"value":[1760443200.1,"1"]},
+		{"metric":{"__name__":"up","instance":"127.0.0.1:8000","job":"vllm"},"value":[1760443200.1,"0"]}]}}`
+	health, err := parseTargetHealth(answer)
+	if err != nil {
+		t.Fatalf("parseTargetHealth: %v", err)
+	}
+	if !health["node"] || health["vllm"] || len(health) != 2 {
+		t.Fatalf("health = %v", health)
+	}
+	if _, err := parseTargetHealth("<html>"); err == nil {
+		t.Fatal("expected an error for a non-JSON answer")
+	}
+}
@@ -41,12 +41,12 @@ func (m *Manager) nvfp4Setup() error {
 
 	// Pull TensorRT container
 	fmt.Println("Pulling TensorRT container...")
-	output, err := m.sshClient.Execute("docker pull nvcr.io/nvidia/tensorrt:25.12-py3")
+	output, err := m.execStep("docker pull nvcr.io/nvidia/tensorrt:25.12-py3")
 	if err != nil {
 		return fmt.Errorf("failed to pull container: %w", err)
 	}
 
-	fmt.Println(output)
+	printOutput(output)
 	fmt.Println("\nNVFP4 environment setup complete!")
 	fmt.Println("\nNext steps:")
 	fmt.Println("  1. Set HF_TOKEN: export HF_TOKEN=your_token_here")
@@ -85,7 +85,7 @@ func (m *Manager) nvfp4Quantize(modelName string) error {
 		return fmt.Errorf("quantization failed: %w", err)
 	}
 
-	fmt.Println(output)
+	printOutput(output)
 	fmt.Println("\nNVFP4 quantization complete!")
 	fmt.Printf("Output saved to: ~/nvfp4_output on DGX\n")
 	fmt.Println("\nTo download the quantized model:")
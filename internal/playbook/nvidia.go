@@ -0,0 +1,175 @@
+package playbook
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/weatherman/dgx-manager/internal/pins"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/ui"
+)
+
+// nvidiaPackages matches the apt packages of the NVIDIA driver stack
+const nvidiaPackages = `^(nvidia-|libnvidia-|cuda-drivers|cuda-compat)`
+
+// nvidiaStatusScript prints key=value lines: the loaded kernel module's version, the
+// user-space driver's, the driver packages apt can upgrade, and whether a reboot is due
+const nvidiaStatusScript = `echo "module=$(cat /sys/module/nvidia/version 2>/dev/null)"
+echo "driver=$(nvidia-smi --query-gpu=driver_version --format=csv,noheader 2>/dev/null | head -n 1)"
+echo "upgradable=$(apt list --upgradable 2>/dev/null | cut -d/ -f1 | grep -E '` + nvidiaPackages + `' | tr '\n' ' ')"
+[ -f /var/run/reboot-required ] && echo "reboot=yes"
+true`
+
+// packageNamePattern is what Debian allows in package names; they are passed to apt-get
+var packageNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.+-]*$`)
+
+// runNvidia checks for and installs NVIDIA driver updates
+func (m *Manager) runNvidia(args []string) error {
+	command := "status"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "--") {
+		command, args = args[0], args[1:]
+	}
+
+	args, reboot := removeFlag(args, "--reboot")
+	args, yes := removeFlag(args, "--yes")
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+	}
+
+	switch command {
+	case "status":
+		return m.nvidiaStatus()
+	case "update":
+		return m.nvidiaUpdate(reboot, yes)
+	default:
+		return fmt.Errorf("unknown nvidia command: %s. Usage: dgx run nvidia [status|update] [--reboot] [--yes]", command)
+	}
+}
+
+// nvidiaState is the parsed output of nvidiaStatusScript
+type nvidiaState struct {
+	module     string
+	driver     string
+	upgradable []string
+	reboot     bool
+}
+
+func (m *Manager) readNvidiaState() (nvidiaState, error) {
+	output, err := m.sshClient.Execute(nvidiaStatusScript)
+	if err != nil {
+		return nvidiaState{}, fmt.Errorf("failed to read the NVIDIA driver state: %w", err)
+	}
+	return parseNvidiaState(output), nil
+}
+
+func parseNvidiaState(output string) nvidiaState {
+	values := parseKeyValues(output)
+	st := nvidiaState{module: values["module"], driver: values["driver"], reboot: values["reboot"] == "yes"}
+	for _, name := range strings.Fields(values["upgradable"]) {
+		if packageNamePattern.MatchString(name) {
+			st.upgradable = append(st.upgradable, name)
+		}
+	}
+	return st
+}
+
+// branchUpgrades splits the upgradable packages into those the pinned driver branch allows
+// and those held back, which include unversioned metapackages that could switch branches
+func (m *Manager) branchUpgrades(upgradable []string) (allowed, held []string) {
+	if m.pins == nil || m.pins.DriverBranch == "" {
+		return upgradable, nil
+	}
+	for _, name := range upgradable {
+		if pins.OnBranch(name, m.pins.DriverBranch) {
+			allowed = append(allowed, name)
+		} else {
+			held = append(held, name)
+		}
+	}
+	return allowed, held
+}
+
+func (m *Manager) nvidiaStatus() error {
+	st, err := m.readNvidiaState()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Driver:        %s\n", ui.OrDash(st.driver))
+	fmt.Printf("Kernel module: %s\n", ui.OrDash(st.module))
+	upgrades, held := m.branchUpgrades(st.upgradable)
+	if m.pins != nil && m.pins.DriverBranch != "" {
+		fmt.Printf("Branch:        %s (pinned)\n", m.pins.DriverBranch)
+	}
+	if len(upgrades) == 0 {
+		fmt.Println("Updates:       none in the package lists ('update' refreshes them first)")
+	} else {
+		fmt.Printf("Updates:       %s\n", strings.Join(upgrades, " "))
+	}
+	if len(held) > 0 {
+		fmt.Printf("Held back:     %s (not on the pinned branch)\n", strings.Join(held, " "))
+	}
+	if st.reboot || (st.module != "" && st.driver != "" && st.module != st.driver) {
+		fmt.Println("\nA reboot is pending; the loaded driver may not match the installed one (dgx reboot --wait)")
+	}
+	return nil
+}
+
+func (m *Manager) nvidiaUpdate(reboot, yes bool) error {
+	upgraded := false
+	err := m.trackChanges("nvidia update", func() error {
+		var err error
+		upgraded, err = m.nvidiaUpgrade()
+		return err
+	})
+	if err != nil || !upgraded {
+		return err
+	}
+
+	if !reboot {
+		fmt.Println("\nThe new driver loads at the next reboot: dgx reboot --wait")
+		return nil
+	}
+	if err := m.confirmDestructive(fmt.Sprintf("Reboot %s now to load the new driver?", m.sshClient.Host()), yes); err != nil {
+		return err
+	}
+	fmt.Println("Rebooting...")
+	// The connection drops as the DGX goes down, so the command's own status means nothing
+	m.sshClient.Execute("sudo systemctl reboot")
+	fmt.Println("Check the driver once the DGX is back: dgx run nvidia status")
+	return nil
+}
+
+// nvidiaUpgrade installs the driver package upgrades and reports whether there were any
+func (m *Manager) nvidiaUpgrade() (bool, error) {
+	fmt.Println("Refreshing the package lists...")
+	if output, err := m.execStep("sudo apt-get update -qq"); err != nil {
+		printOutput(output)
+		return false, fmt.Errorf("apt-get update failed: %w", err)
+	}
+	st, err := m.readNvidiaState()
+	if err != nil {
+		return false, err
+	}
+	upgrades, held := m.branchUpgrades(st.upgradable)
+	if len(held) > 0 {
+		fmt.Printf("Holding back %s: not on the pinned branch %s\n", strings.Join(held, " "), m.pins.DriverBranch)
+	}
+	if len(upgrades) == 0 {
+		fmt.Printf("The NVIDIA driver is up to date (%s)\n", ui.OrDash(st.driver))
+		return false, nil
+	}
+
+	fmt.Printf("Upgrading %d packages: %s\n", len(upgrades), strings.Join(upgrades, " "))
+	quoted := make([]string, len(upgrades))
+	for i, name := range upgrades {
+		quoted[i] = ssh.ShellQuote(name)
+	}
+	output, err := m.execStep("sudo env DEBIAN_FRONTEND=noninteractive apt-get install -y -qq --only-upgrade " + strings.Join(quoted, " "))
+	if err != nil {
+		printOutput(output)
+		return false, fmt.Errorf("failed to upgrade the NVIDIA driver: %w", err)
+	}
+	fmt.Printf("Installed the new driver packages (running driver: %s)\n", ui.OrDash(st.driver))
+	return true, nil
+}
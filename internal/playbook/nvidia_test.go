@@ -0,0 +1,16 @@
+package playbook
+
+import "testing"
+
+func TestParseNvidiaState(t *testing.T) {
+	st := parseNvidiaState("module=580.95.05\ndriver=580.82.09\nupgradable=nvidia-driver-580-open libnvidia-compute-580 Bad;name \nreboot=yes\n")
+	if st.module != "580.95.05" || st.driver != "580.82.09" || !st.reboot {
+		t.Fatalf("state = %+v", st)
+	}
+	if len(st.upgradable) != 2 || st.upgradable[0] != "nvidia-driver-580-open" || st.upgradable[1] != "libnvidia-compute-580" {
+		t.Fatalf("upgradable = %q", st.upgradable)
+	}
+	if st := parseNvidiaState("module=\ndriver=\nupgradable=\n"); st.reboot || len(st.upgradable) != 0 {
+		t.Fatalf("empty state = %+v", st)
+	}
+}
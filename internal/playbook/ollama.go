@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/weatherman/dgx-manager/internal/exitcode"
 	"github.com/weatherman/dgx-manager/internal/ssh"
 )
 
@@ -52,7 +53,7 @@ func (m *Manager) ollamaInstall() error {
 	fmt.Scanln(&confirm)
 	if confirm != "" && strings.ToLower(confirm) != "y" {
 		fmt.Println("Installation cancelled.")
-		return nil
+		return exitcode.ErrAborted
 	}
 	fmt.Println("Running: curl -fsSL https://ollama.com/install.sh | sh")
 	fmt.Println("(You may be prompted for your DGX sudo password)")
@@ -68,12 +69,12 @@ func (m *Manager) ollamaInstall() error {
 func (m *Manager) ollamaPull(model string) error {
 	fmt.Printf("Pulling model: %s...\n", model)
 
-	output, err := m.sshClient.Execute(fmt.Sprintf("ollama pull %s", model))
+	output, err := m.execStep(fmt.Sprintf("ollama pull %s", model))
 	if err != nil {
 		return fmt.Errorf("failed to pull model: %w", err)
 	}
 
-	fmt.Println(output)
+	printOutput(output)
 	fmt.Printf("\nModel %s downloaded successfully!\n", model)
 	return nil
 }
@@ -87,7 +88,7 @@ func (m *Manager) ollamaList() error {
 		return fmt.Errorf("failed to list models: %w", err)
 	}
 
-	fmt.Println(output)
+	printOutput(output)
 	return nil
 }
 
@@ -154,6 +155,6 @@ func (m *Manager) ollamaRun(model string, prompt string) error {
 	}
 
 	fmt.Println("\nResponse:")
-	fmt.Println(output)
+	printOutput(output)
 	return nil
 }
@@ -2,8 +2,12 @@ package playbook
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/weatherman/dgx-manager/internal/policy"
 	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/tunnel"
+	"github.com/weatherman/dgx-manager/pkg/types"
 )
 
 // Playbook represents a DGX Spark workflow
@@ -16,13 +20,22 @@ type Playbook struct {
 
 // Manager handles DGX Spark playbook execution
 type Manager struct {
-	sshClient *ssh.Client
+	sshClient  *ssh.Client
+	retries    int
+	retryDelay time.Duration
+	devSetup   *types.DevSetupConfig
+	pins       *types.VersionPins
+	readOnly   bool
+	policy     policy.Policy
+	tunnels    *tunnel.Manager
 }
 
 // NewManager creates a new playbook manager
 func NewManager(client *ssh.Client) *Manager {
 	return &Manager{
-		sshClient: client,
+		sshClient:  client,
+		retries:    DefaultRetries,
+		retryDelay: DefaultRetryDelay,
 	}
 }
 
@@ -33,6 +46,7 @@ const (
 	CategoryDevelopment = "Development Tools"
 	CategoryNetworking  = "Networking"
 	CategoryAdvanced    = "Advanced Applications"
+	CategorySystem      = "System"
 )
 
 // GetAvailablePlaybooks returns a list of all available playbooks
@@ -69,6 +83,16 @@ func GetAvailablePlaybooks() []Playbook {
 			Description: "Faster inference with speculative decoding",
 			Category:    CategoryInference,
 		},
+		{
+			Name:        "sdgen",
+			Description: "Image generation server (ComfyUI or sd-webui)",
+			Category:    CategoryInference,
+		},
+		{
+			Name:        "whisper",
+			Description: "Audio transcription with faster-whisper",
+			Category:    CategoryInference,
+		},
 
 		// Fine-tuning & Training
 		{
@@ -103,16 +127,58 @@ func GetAvailablePlaybooks() []Playbook {
 			Description: "JupyterLab environment",
 			Category:    CategoryDevelopment,
 		},
+		{
+			Name:        "devsetup",
+			Description: "Developer tools and dotfiles bootstrap",
+			Category:    CategoryDevelopment,
+		},
+		{
+			Name:        "pyenv",
+			Description: "Python environments with CUDA PyTorch (uv or micromamba)",
+			Category:    CategoryDevelopment,
+		},
 		{
 			Name:        "comfyui",
 			Description: "Node-based image generation UI",
 			Category:    CategoryDevelopment,
 		},
 		{
-			Name:        "open-webui",
-			Description: "Web interface for local models",
+			Name:        "webui",
+			Description: "Open WebUI chat interface for local models",
 			Category:    CategoryDevelopment,
 		},
+
+		// System
+		{
+			Name:        "time",
+			Description: "Clock skew check and NTP setup (chrony or systemd-timesyncd)",
+			Category:    CategorySystem,
+		},
+		{
+			Name:        "memory",
+			Description: "Swap file, zram, and memory pressure for models near the unified memory limit",
+			Category:    CategorySystem,
+		},
+		{
+			Name:        "tune",
+			Description: "Kernel sysctl, hugepage, and IOMMU tuning for inference (diff, apply, rollback)",
+			Category:    CategorySystem,
+		},
+		{
+			Name:        "monitoring",
+			Description: "Grafana and Prometheus with a GPU and model server dashboard",
+			Category:    CategorySystem,
+		},
+		{
+			Name:        "registry-cache",
+			Description: "Pull-through caches for Docker Hub and NGC images, with dockerd using the Docker Hub one",
+			Category:    CategorySystem,
+		},
+		{
+			Name:        "nvidia",
+			Description: "NVIDIA driver version check and package updates",
+			Category:    CategorySystem,
+		},
 	}
 }
 
@@ -146,6 +212,9 @@ func (m *Manager) Execute(playbookName string, args []string) error {
 	if err != nil {
 		return err
 	}
+	if err := m.checkReadOnly(playbookName, args); err != nil {
+		return err
+	}
 
 	switch playbookName {
 	case "ollama":
@@ -156,6 +225,28 @@ func (m *Manager) Execute(playbookName string, args []string) error {
 		return m.runNVFP4(args)
 	case "dmr":
 		return m.runDMR(args)
+	case "pyenv":
+		return m.runPyenv(args)
+	case "devsetup":
+		return m.runDevSetup(args)
+	case "time":
+		return m.runTime(args)
+	case "memory":
+		return m.runMemory(args)
+	case "tune":
+		return m.runTune(args)
+	case "whisper":
+		return m.runWhisper(args)
+	case "sdgen":
+		return m.runSDGen(args)
+	case "webui":
+		return m.runWebUI(args)
+	case "monitoring":
+		return m.runMonitoring(args)
+	case "registry-cache":
+		return m.runRegistryCache(args)
+	case "nvidia":
+		return m.runNvidia(args)
 	default:
 		return fmt.Errorf("playbook '%s' is not yet implemented", playbook.Name)
 	}
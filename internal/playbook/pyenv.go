@@ -0,0 +1,324 @@
+package playbook
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/ui"
+)
+
+// pyenvRoot is where environments live on the DGX; each one is a directory holding the
+// environment and a .dgx-env file describing how it was built
+const pyenvRoot = "~/.local/share/dgx/envs"
+
+const (
+	defaultPyenvPython = "3.11"
+	defaultPyenvCUDA   = "13.0"
+	defaultPyenvTool   = "uv"
+)
+
+// pyenvTorchIndexes maps CUDA versions to the PyTorch wheel index with aarch64 builds for
+// that CUDA release. GB10 needs 12.8 or newer.
+var pyenvTorchIndexes = map[string]string{
+	"12.8": "https://download.pytorch.org/whl/cu128",
+	"12.9": "https://download.pytorch.org/whl/cu129",
+	"13.0": "https://download.pytorch.org/whl/cu130",
+}
+
+// pyenvCUDAAliases resolve a major version to its newest supported release
+var pyenvCUDAAliases = map[string]string{
+	"12": "12.9", "12.x": "12.9",
+	"13": "13.0", "13.x": "13.0",
+}
+
+var (
+	pyenvNamePattern    = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+	pyenvVersionPattern = regexp.MustCompile(`^3\.[0-9]{1,2}(\.[0-9]{1,2})?$`)
+)
+
+// pyenvOptions describes an environment to create
+type pyenvOptions struct {
+	Name   string
+	Python string
+	CUDA   string
+	Tool   string
+}
+
+// runPyenv handles Python environment commands
+func (m *Manager) runPyenv(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("pyenv command required. Usage: dgx run pyenv <create|list|remove|activate>")
+	}
+
+	command := args[0]
+	rest := args[1:]
+
+	switch command {
+	case "create":
+		rest, resume := removeFlag(rest, "--resume")
+		rest, python := flagValue(rest, "--python")
+		rest, cuda := flagValue(rest, "--cuda")
+		rest, tool := flagValue(rest, "--tool")
+		if len(rest) == 0 {
+			return fmt.Errorf("environment name required. Usage: dgx run pyenv create <name> [--python 3.11] [--cuda 13.0] [--tool uv|micromamba]")
+		}
+		opts, err := newPyenvOptions(rest[0], python, cuda, tool)
+		if err != nil {
+			return err
+		}
+		return m.pyenvCreate(opts, resume)
+	case "list":
+		return m.pyenvList()
+	case "remove":
+		rest, yes := removeFlag(rest, "--yes")
+		if len(rest) == 0 {
+			return fmt.Errorf("environment name required. Usage: dgx run pyenv remove <name> [--yes]")
+		}
+		return m.pyenvRemove(rest[0], yes)
+	case "activate":
+		if len(rest) == 0 {
+			return fmt.Errorf("environment name required. Usage: dgx run pyenv activate <name>")
+		}
+		return m.pyenvActivate(rest[0])
+	default:
+		return fmt.Errorf("unknown pyenv command: %s", command)
+	}
+}
+
+// newPyenvOptions validates create arguments and fills in defaults
+func newPyenvOptions(name, python, cuda, tool string) (pyenvOptions, error) {
+	opts := pyenvOptions{Name: name, Python: python, CUDA: cuda, Tool: tool}
+	if opts.Python == "" {
+		opts.Python = defaultPyenvPython
+	}
+	if opts.CUDA == "" {
+		opts.CUDA = defaultPyenvCUDA
+	}
+	if opts.Tool == "" {
+		opts.Tool = defaultPyenvTool
+	}
+	if alias, ok := pyenvCUDAAliases[opts.CUDA]; ok {
+		opts.CUDA = alias
+	}
+
+	if !pyenvNamePattern.MatchString(opts.Name) {
+		return opts, fmt.Errorf("invalid environment name %q: use letters, digits, '.', '_', and '-'", opts.Name)
+	}
+	if !pyenvVersionPattern.MatchString(opts.Python) {
+		return opts, fmt.Errorf("invalid --python %q (use e.g. 3.11 or 3.12.7)", opts.Python)
+	}
+	if _, ok := pyenvTorchIndexes[opts.CUDA]; !ok {
+		return opts, fmt.Errorf("unsupported --cuda %q (choose from %s)", opts.CUDA, strings.Join(pyenvCUDAVersions(), ", "))
+	}
+	if opts.Tool != "uv" && opts.Tool != "micromamba" {
+		return opts, fmt.Errorf("unknown --tool %q (use uv or micromamba)", opts.Tool)
+	}
+	return opts, nil
+}
+
+func pyenvCUDAVersions() []string {
+	versions := make([]string, 0, len(pyenvTorchIndexes))
+	for v := range pyenvTorchIndexes {
+		versions = append(versions, v)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// pyenvPath is the environment directory, left unquoted so the remote shell expands ~
+func pyenvPath(name string) string {
+	return pyenvRoot + "/" + name
+}
+
+// pyenvSteps builds the idempotent steps that create an environment
+func pyenvSteps(opts pyenvOptions) []Step {
+	dir := pyenvPath(opts.Name)
+	python := ssh.ShellQuote(opts.Python)
+	index := ssh.ShellQuote(pyenvTorchIndexes[opts.CUDA])
+
+	var steps []Step
+	switch opts.Tool {
+	case "uv":
+		steps = []Step{
+			{
+				Name:        "uv",
+				Description: "uv",
+				Command: `set -euo pipefail
+export PATH="$HOME/.local/bin:$PATH"
+if ! command -v uv >/dev/null 2>&1; then
+  curl -LsSf https://astral.sh/uv/install.sh | sh
+fi`,
+			},
+			{
+				Name:        "python",
+				Description: "Python " + opts.Python + " environment",
+				Command: fmt.Sprintf(`set -euo pipefail
+export PATH="$HOME/.local/bin:$PATH"
+mkdir -p %s
+if [ ! -x %s/bin/python ]; then
+  uv venv --seed --python %s %s
+fi`, pyenvRoot, dir, python, dir),
+			},
+			{
+				Name:        "torch",
+				Description: "PyTorch (CUDA " + opts.CUDA + " aarch64 wheels)",
+				Command: fmt.Sprintf(`set -euo pipefail
+export PATH="$HOME/.local/bin:$PATH"
+uv pip install --quiet --python %s/bin/python --index-url %s torch torchvision torchaudio`, dir, index),
+			},
+		}
+	case "micromamba":
+		steps = []Step{
+			{
+				Name:        "micromamba",
+				Description: "micromamba",
+				Command: `set -euo pipefail
+if [ ! -x "$HOME/.local/bin/micromamba" ]; then
+  mkdir -p "$HOME/.local/bin"
+  curl -Ls https://micro.mamba.pm/api/micromamba/linux-aarch64/latest | tar -xj -C "$HOME/.local" bin/micromamba
+fi`,
+			},
+			{
+				Name:        "python",
+				Description: "Python " + opts.Python + " environment",
+				Command: fmt.Sprintf(`set -euo pipefail
+mkdir -p %s
+if [ ! -x %s/bin/python ]; then
+  "$HOME/.local/bin/micromamba" create --yes --quiet --prefix %s -c conda-forge python=%s pip
+fi`, pyenvRoot, dir, dir, python),
+			},
+			{
+				Name:        "torch",
+				Description: "PyTorch (CUDA " + opts.CUDA + " aarch64 wheels)",
+				Command: fmt.Sprintf(`set -euo pipefail
+%s/bin/python -m pip install --quiet --index-url %s torch torchvision torchaudio`, dir, index),
+			},
+		}
+	}
+
+	return append(steps,
+		Step{
+			Name:        "metadata",
+			Description: "Environment metadata",
+			Command: fmt.Sprintf(`printf 'tool=%%s\npython=%%s\ncuda=%%s\n' %s %s %s > %s/.dgx-env`,
+				ssh.ShellQuote(opts.Tool), python, ssh.ShellQuote(opts.CUDA), dir),
+		},
+		Step{
+			Name:        "verify",
+			Description: "CUDA check",
+			Command: fmt.Sprintf(`%s/bin/python -c 'import torch; print("torch", torch.__version__, "| CUDA", torch.version.cuda, "| GPU available:", torch.cuda.is_available())'`,
+				dir),
+		},
+	)
+}
+
+func (m *Manager) pyenvCreate(opts pyenvOptions, resume bool) error {
+	fmt.Printf("Creating Python environment %s (Python %s, CUDA %s, %s)...\n", opts.Name, opts.Python, opts.CUDA, opts.Tool)
+	fmt.Println("PyTorch wheels are large; the first create can take several minutes.")
+
+	run := "pyenv create " + opts.Name
+	if err := m.runSteps(run, pyenvSteps(opts), resume); err != nil {
+		return fmt.Errorf("failed to create environment %s: %w", opts.Name, err)
+	}
+
+	fmt.Printf("\nEnvironment %s is ready.\n\n", opts.Name)
+	fmt.Print(pyenvActivation(opts.Name, opts.Tool))
+	return nil
+}
+
+// pyenvListScript prints one "name|tool|python|cuda" line per environment
+const pyenvListScript = `for d in ` + pyenvRoot + `/*/; do
+  [ -d "$d" ] || continue
+  name=$(basename "$d")
+  tool=$(sed -n 's/^tool=//p' "$d/.dgx-env" 2>/dev/null)
+  cuda=$(sed -n 's/^cuda=//p' "$d/.dgx-env" 2>/dev/null)
+  version=$("$d/bin/python" -c 'import platform; print(platform.python_version())' 2>/dev/null)
+  echo "$name|$tool|$version|$cuda"
+done`
+
+func (m *Manager) pyenvList() error {
+	output, err := m.sshClient.Execute(pyenvListScript)
+	if err != nil {
+		return fmt.Errorf("failed to list environments: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTOOL\tPYTHON\tCUDA\tSTATUS")
+	count := 0
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Split(line, "|")
+		if len(fields) < 4 {
+			continue
+		}
+		status := "ok"
+		if fields[1] == "" {
+			// No metadata means create did not finish
+			status = "incomplete"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", fields[0], ui.OrDash(fields[1]), ui.OrDash(fields[2]), ui.OrDash(fields[3]), status)
+		count++
+	}
+	if count == 0 {
+		fmt.Println("No environments. Create one with: dgx run pyenv create <name>")
+		return nil
+	}
+	return w.Flush()
+}
+
+func (m *Manager) pyenvRemove(name string, yes bool) error {
+	if !pyenvNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid environment name %q", name)
+	}
+	dir := pyenvPath(name)
+	if _, err := m.sshClient.Execute(fmt.Sprintf("test -d %s", dir)); err != nil {
+		return fmt.Errorf("environment %s not found", name)
+	}
+
+	if err := m.confirmDestructive(fmt.Sprintf("Remove environment %s (%s)?", name, dir), yes); err != nil {
+		return err
+	}
+
+	if _, err := m.sshClient.Execute(fmt.Sprintf("rm -rf %s", dir)); err != nil {
+		return fmt.Errorf("failed to remove environment %s: %w", name, err)
+	}
+	fmt.Printf("Environment %s removed\n", name)
+	return nil
+}
+
+func (m *Manager) pyenvActivate(name string) error {
+	if !pyenvNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid environment name %q", name)
+	}
+	tool, err := m.sshClient.Execute(fmt.Sprintf("sed -n 's/^tool=//p' %s/.dgx-env", pyenvPath(name)))
+	if err != nil {
+		return fmt.Errorf("environment %s not found", name)
+	}
+	fmt.Print(pyenvActivation(name, strings.TrimSpace(tool)))
+	return nil
+}
+
+// pyenvActivation returns the snippets for using an environment from a DGX shell, a
+// one-off command, and editors or notebooks
+func pyenvActivation(name, tool string) string {
+	dir := pyenvPath(name)
+	var b strings.Builder
+	b.WriteString("Activate on the DGX (after 'dgx connect'):\n")
+	if tool == "micromamba" {
+		b.WriteString("  eval \"$(~/.local/bin/micromamba shell hook -s bash)\"\n")
+		fmt.Fprintf(&b, "  micromamba activate %s\n", dir)
+	} else {
+		fmt.Fprintf(&b, "  source %s/bin/activate\n", dir)
+	}
+	b.WriteString("\nRun a single command from your laptop:\n")
+	fmt.Fprintf(&b, "  dgx exec \"%s/bin/python train.py\"\n", dir)
+	b.WriteString("\nInterpreter path for VS Code Remote:\n")
+	fmt.Fprintf(&b, "  %s/bin/python\n", dir)
+	b.WriteString("\nRegister as a Jupyter kernel:\n")
+	fmt.Fprintf(&b, "  %s/bin/python -m pip install ipykernel && %s/bin/python -m ipykernel install --user --name %s\n", dir, dir, name)
+	return b.String()
+}
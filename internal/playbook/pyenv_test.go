@@ -0,0 +1,45 @@
+package playbook
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewPyenvOptions(t *testing.T) {
+	opts, err := newPyenvOptions("train", "", "12.x", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.Python != defaultPyenvPython || opts.CUDA != "12.9" || opts.Tool != "uv" {
+		t.Fatalf("unexpected defaults %+v", opts)
+	}
+
+	for _, bad := range [][4]string{
+		{"../etc", "3.11", "13.0", "uv"},
+		{"train", "3.11; rm -rf ~", "13.0", "uv"},
+		{"train", "3.11", "11.8", "uv"},
+		{"train", "3.11", "13.0", "conda"},
+	} {
+		if _, err := newPyenvOptions(bad[0], bad[1], bad[2], bad[3]); err == nil {
+			t.Fatalf("expected %v to be rejected", bad)
+		}
+	}
+}
+
+func TestPyenvStepsUseCUDAIndex(t *testing.T) {
+	for _, tool := range []string{"uv", "micromamba"} {
+		opts, err := newPyenvOptions("train", "3.12", "12.8", tool)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var torch string
+		for _, step := range pyenvSteps(opts) {
+			if step.Name == "torch" {
+				torch = step.Command
+			}
+		}
+		if !strings.Contains(torch, "whl/cu128") {
+			t.Fatalf("%s torch step does not use the cu128 index: %s", tool, torch)
+		}
+	}
+}
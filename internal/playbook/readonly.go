@@ -0,0 +1,123 @@
+package playbook
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/policy"
+)
+
+// readOnlyCommands are the playbook commands that only inspect the DGX. Everything else,
+// including commands added later, counts as mutating.
+var readOnlyCommands = map[string][]string{
+	"ollama":         {"list", "status"},
+	"vllm":           {"status"},
+	"dmr":            {"status", "logs", "list", "ps"},
+	"pyenv":          {"list", "activate"},
+	"devsetup":       {"status", "list"},
+	"time":           {"status"},
+	"memory":         {"status"},
+	"tune":           {"diff"},
+	"whisper":        {"cache"},
+	"sdgen":          {"status"},
+	"webui":          {"status"},
+	"monitoring":     {"status"},
+	"nvidia":         {"status"},
+	"registry-cache": {"status"},
+}
+
+// defaultCommands are what playbooks run when no command is given
+var defaultCommands = map[string]string{
+	"time":   "status",
+	"memory": "status",
+	"tune":   "diff",
+	"nvidia": "status",
+}
+
+// readOnlyDMRAPI are the 'dmr api' queries that change nothing
+var readOnlyDMRAPI = []string{"status", "models", "list", "inspect", "ps", "df"}
+
+// destructiveCommands remove installed software or data
+var destructiveCommands = map[string][]string{
+	"dmr":            {"uninstall", "rollback"},
+	"pyenv":          {"remove"},
+	"tune":           {"rollback"},
+	"webui":          {"uninstall"},
+	"monitoring":     {"uninstall"},
+	"registry-cache": {"uninstall"},
+}
+
+// destructiveWhisperCache are the 'whisper cache' commands that delete models
+var destructiveWhisperCache = []string{"clear"}
+
+// SetReadOnly makes Execute refuse playbook commands that change the DGX
+func (m *Manager) SetReadOnly(readOnly bool) {
+	m.readOnly = readOnly
+}
+
+// SetPolicy sets how destructive playbook commands are confirmed
+func (m *Manager) SetPolicy(p policy.Policy) {
+	m.policy = p
+}
+
+// confirmDestructive asks before a destructive playbook step, returning ErrAborted when
+// the user declines
+func (m *Manager) confirmDestructive(prompt string, yes bool) error {
+	if !m.policy.Allow(policy.Destructive, prompt, yes) {
+		fmt.Println("Cancelled.")
+		return exitcode.ErrAborted
+	}
+	return nil
+}
+
+// confirmMutating asks before a playbook changes the DGX, returning ErrAborted when the
+// user declines
+func (m *Manager) confirmMutating(prompt string, yes bool) error {
+	if !m.policy.Allow(policy.Mutating, prompt, yes) {
+		fmt.Println("Cancelled.")
+		return exitcode.ErrAborted
+	}
+	return nil
+}
+
+// Classify returns what running the playbook with args may do to the DGX
+func Classify(playbookName string, args []string) policy.Level {
+	command := defaultCommands[playbookName]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command = args[0]
+	}
+	switch {
+	case slices.Contains(destructiveCommands[playbookName], command):
+		return policy.Destructive
+	case playbookName == "whisper" && command == "cache" && len(args) > 1 && slices.Contains(destructiveWhisperCache, args[1]):
+		return policy.Destructive
+	case playbookName == "dmr" && command == "api":
+		if len(args) > 1 && slices.Contains(readOnlyDMRAPI, args[1]) {
+			return policy.Safe
+		}
+		return policy.Mutating
+	case slices.Contains(readOnlyCommands[playbookName], command):
+		return policy.Safe
+	default:
+		return policy.Mutating
+	}
+}
+
+// checkReadOnly rejects mutating commands in read-only mode
+func (m *Manager) checkReadOnly(playbookName string, args []string) error {
+	if !m.readOnly || Classify(playbookName, args) == policy.Safe {
+		return nil
+	}
+	command := playbookName
+	if len(args) > 0 {
+		command += " " + args[0]
+	}
+	allowed := "none"
+	if commands := readOnlyCommands[playbookName]; len(commands) > 0 {
+		allowed = strings.Join(commands, ", ")
+	}
+	return exitcode.Wrap(exitcode.Usage, fmt.Errorf("'dgx run %s' changes the DGX, but this connection is read-only (readonly in the config or --readonly); read-only %s commands: %s",
+		command, playbookName, allowed))
+}
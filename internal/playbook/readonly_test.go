@@ -0,0 +1,51 @@
+package playbook
+
+import (
+	"testing"
+
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/policy"
+)
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		playbook string
+		args     []string
+		want     policy.Level
+	}{
+		{"dmr", []string{"status"}, policy.Safe},
+		{"dmr", []string{"install"}, policy.Mutating},
+		{"dmr", []string{"uninstall"}, policy.Destructive},
+		{"dmr", []string{"api", "models", "--json"}, policy.Safe},
+		{"dmr", []string{"api", "unload", "ai/smollm2"}, policy.Mutating},
+		{"devsetup", nil, policy.Mutating},
+		{"devsetup", []string{"--tools", "git"}, policy.Mutating},
+		{"devsetup", []string{"status"}, policy.Safe},
+		{"nvfp4", []string{"quantize", "m"}, policy.Mutating},
+		{"ollama", []string{"run", "qwen"}, policy.Mutating},
+		{"pyenv", []string{"remove", "train"}, policy.Destructive},
+		{"time", nil, policy.Safe},
+		{"memory", nil, policy.Safe},
+		{"memory", []string{"configure", "--swap", "64G"}, policy.Mutating},
+		{"tune", nil, policy.Safe},
+		{"tune", []string{"apply"}, policy.Mutating},
+		{"tune", []string{"rollback"}, policy.Destructive},
+		{"whisper", []string{"talk.mp3", "--format", "srt"}, policy.Mutating},
+		{"whisper", []string{"cache"}, policy.Safe},
+		{"sdgen", []string{"status"}, policy.Safe},
+		{"webui", []string{"install", "--lan"}, policy.Mutating},
+		{"webui", []string{"uninstall", "--purge"}, policy.Destructive},
+		{"sdgen", []string{"deploy", "--ui", "sd-webui"}, policy.Mutating},
+		{"whisper", []string{"cache", "clear", "large-v3"}, policy.Destructive},
+	}
+	for _, c := range cases {
+		if got := Classify(c.playbook, c.args); got != c.want {
+			t.Fatalf("Classify(%s %v) = %v, want %v", c.playbook, c.args, got, c.want)
+		}
+	}
+
+	m := &Manager{readOnly: true}
+	if err := m.Execute("dmr", []string{"install"}); exitcode.Of(err) != exitcode.Usage {
+		t.Fatalf("read-only dmr install: got %v, want a usage error", err)
+	}
+}
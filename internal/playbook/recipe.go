@@ -0,0 +1,59 @@
+package playbook
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/recipe"
+)
+
+// RunRecipe runs a recipe's steps in order with the given parameter values, starting at
+// step from (1-based). Playbook steps go through Execute, so they are checked and confirmed
+// like 'dgx run'; every step is checked against read-only mode before the first one runs.
+func (m *Manager) RunRecipe(r *recipe.Recipe, values map[string]string, from int) error {
+	for i, step := range r.Steps {
+		if step.Exec != "" {
+			if m.readOnly {
+				return exitcode.Wrap(exitcode.Usage, fmt.Errorf("recipe %s runs shell steps, but this connection is read-only (readonly in the config or --readonly)", r.Name))
+			}
+			continue
+		}
+		args := step.RunArgs(values)
+		if _, err := GetPlaybook(args[0]); err != nil {
+			return fmt.Errorf("recipe %s step %d: %w", r.Name, i+1, err)
+		}
+		if err := m.checkReadOnly(args[0], args[1:]); err != nil {
+			return err
+		}
+	}
+
+	for i, step := range r.Steps {
+		prefix := fmt.Sprintf("\n[%d/%d] %s", i+1, len(r.Steps), step.Name)
+		if i+1 < from {
+			fmt.Printf("%s (skipped)\n", prefix)
+			continue
+		}
+
+		var err error
+		if step.Exec != "" {
+			fmt.Println(prefix)
+			var output string
+			output, err = m.execStep(step.Script(values))
+			if strings.TrimSpace(output) != "" {
+				printOutput(strings.TrimRight(output, "\n"))
+			}
+		} else {
+			args := step.RunArgs(values)
+			fmt.Printf("%s: dgx run %s\n", prefix, strings.Join(args, " "))
+			err = m.Execute(args[0], args[1:])
+		}
+		if err != nil {
+			if i > 0 {
+				fmt.Printf("\nContinue from this step with: dgx recipes run %s --from %d\n", r.Name, i+1)
+			}
+			return fmt.Errorf("step %d (%s) failed: %w", i+1, step.Name, err)
+		}
+	}
+	return nil
+}
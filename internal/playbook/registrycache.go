@@ -0,0 +1,377 @@
+package playbook
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/artifacts"
+	"github.com/weatherman/dgx-manager/internal/registry"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/tracking"
+	"github.com/weatherman/dgx-manager/internal/ui"
+)
+
+// Pull-through registry caches. Each upstream gets its own registry container, since a
+// distribution registry proxies exactly one. dockerd only consults registry-mirrors for
+// Docker Hub, so that cache is transparent; NGC images are pulled through theirs by
+// prefixing the cache address (127.0.0.1:5001/nvidia/pytorch:25.09-py3).
+const (
+	registryCacheImage   = "registry:3"
+	registryCachePort    = 5000
+	registryCacheTTL     = "168h"
+	dockerDaemonConfig   = "/etc/docker/daemon.json"
+	registryCacheAddrVar = "REGISTRY_HTTP_ADDR"
+)
+
+// registryCache is one pull-through cache and the upstream it proxies
+type registryCache struct {
+	Name     string // container and volume
+	Upstream string
+	Registry string // whose 'dgx registry set' credentials authenticate upstream pulls
+	Offset   int    // port offset from --port
+}
+
+var registryCaches = []registryCache{
+	{Name: "dgx-registry-cache", Upstream: "https://registry-1.docker.io", Registry: "docker.io", Offset: 0},
+	{Name: "dgx-registry-cache-nvcr", Upstream: "https://nvcr.io", Registry: "nvcr.io", Offset: 1},
+}
+
+// registryCacheOptions are the flags of 'dgx run registry-cache install'
+type registryCacheOptions struct {
+	port int
+	ttl  string
+	lan  bool
+}
+
+// runRegistryCache handles the pull-through registry caches
+func (m *Manager) runRegistryCache(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("registry-cache command required. Usage: dgx run registry-cache <install|status|uninstall>")
+	}
+	command, rest := args[0], args[1:]
+
+	switch command {
+	case "install":
+		opts, err := parseRegistryCacheOptions(rest)
+		if err != nil {
+			return err
+		}
+		return m.registryCacheInstall(opts)
+	case "status":
+		if len(rest) > 0 {
+			return fmt.Errorf("unexpected arguments: %s", strings.Join(rest, " "))
+		}
+		return m.registryCacheStatus()
+	case "uninstall":
+		rest, yes := removeFlag(rest, "--yes")
+		rest, purge := removeFlag(rest, "--purge")
+		if len(rest) > 0 {
+			return fmt.Errorf("unexpected arguments: %s", strings.Join(rest, " "))
+		}
+		return m.registryCacheUninstall(purge, yes)
+	default:
+		return fmt.Errorf("unknown registry-cache command: %s", command)
+	}
+}
+
+func parseRegistryCacheOptions(args []string) (registryCacheOptions, error) {
+	opts := registryCacheOptions{ttl: registryCacheTTL}
+	var port, ttl string
+	args, opts.lan = removeFlag(args, "--lan")
+	args, port = flagValue(args, "--port")
+	args, ttl = flagValue(args, "--ttl")
+	if len(args) > 0 {
+		return opts, fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+	}
+
+	var err error
+	if opts.port, _, err = parsePorts(port, "", registryCachePort); err != nil {
+		return opts, err
+	}
+	if opts.port+len(registryCaches)-1 > 65535 {
+		return opts, fmt.Errorf("invalid port %q: the caches use it and the next %d", port, len(registryCaches)-1)
+	}
+	if ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d < time.Hour {
+			return opts, fmt.Errorf("invalid --ttl %q (want a duration of at least 1h, such as 336h)", ttl)
+		}
+		opts.ttl = ttl
+	}
+	return opts, nil
+}
+
+// mirrorURL is the address dockerd reaches the Docker Hub cache on
+func mirrorURL(port int) string {
+	return fmt.Sprintf("http://127.0.0.1:%d", port)
+}
+
+// setRegistryMirror adds url to the front of registry-mirrors in daemon.json content, or
+// removes it, keeping every other setting. It reports whether anything changed.
+func setRegistryMirror(content, url string, add bool) (string, bool, error) {
+	config := map[string]any{}
+	if strings.TrimSpace(content) != "" {
+		if err := json.Unmarshal([]byte(content), &config); err != nil {
+			return "", false, fmt.Errorf("%s is not valid JSON: %w", dockerDaemonConfig, err)
+		}
+	}
+	var mirrors []any
+	found := false
+	if existing, ok := config["registry-mirrors"].([]any); ok {
+		for _, m := range existing {
+			if s, _ := m.(string); strings.TrimSuffix(s, "/") == url {
+				found = true
+				continue
+			}
+			mirrors = append(mirrors, m)
+		}
+	}
+	if found == add {
+		return content, false, nil
+	}
+	if add {
+		mirrors = append([]any{url}, mirrors...)
+	}
+	if len(mirrors) > 0 {
+		config["registry-mirrors"] = mirrors
+	} else {
+		delete(config, "registry-mirrors")
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return "", false, err
+	}
+	return string(data) + "\n", true, nil
+}
+
+// updateDockerMirror adds or removes the Docker Hub cache in dockerd's registry-mirrors
+// and reloads dockerd, which applies mirrors without restarting running containers
+func (m *Manager) updateDockerMirror(url string, add bool) (bool, error) {
+	content, err := m.sshClient.Execute(fmt.Sprintf("sudo cat %s 2>/dev/null || true", dockerDaemonConfig))
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", dockerDaemonConfig, err)
+	}
+	updated, changed, err := setRegistryMirror(content, url, add)
+	if err != nil || !changed {
+		return false, err
+	}
+	write := fmt.Sprintf(`sudo mkdir -p /etc/docker && printf '%%s' %s | sudo tee %s >/dev/null && sudo systemctl reload docker`,
+		ssh.ShellQuote(updated), dockerDaemonConfig)
+	if output, err := m.execStep(write); err != nil {
+		printOutput(output)
+		return false, fmt.Errorf("failed to update %s: %w", dockerDaemonConfig, err)
+	}
+	return true, nil
+}
+
+// startRegistryCache returns a command that (re)creates the cache container. Stored
+// credentials for the upstream are passed by name, so they stay off the command line;
+// without them the cache pulls anonymously.
+func startRegistryCache(c registryCache, listen string, port int, ttl string) string {
+	r, _ := registry.Parse(c.Registry)
+	pass := fmt.Sprintf("${%s:-}", r.PasswordVar)
+	if r.FallbackVar != "" {
+		pass = fmt.Sprintf("${%s:-${%s:-}}", r.PasswordVar, r.FallbackVar)
+	}
+	credentials := fmt.Sprintf(`[ -f %[1]s ] && . %[1]s
+export REGISTRY_PROXY_USERNAME="${%[2]s:-%[3]s}" REGISTRY_PROXY_PASSWORD="%[4]s"
+if [ -n "$REGISTRY_PROXY_PASSWORD" ] && [ -n "$REGISTRY_PROXY_USERNAME" ]; then echo authenticated; else unset REGISTRY_PROXY_USERNAME REGISTRY_PROXY_PASSWORD; echo anonymous; fi
+`, tracking.EnvFile, r.UserVar, strings.ReplaceAll(r.DefaultUser, "$", `\$`), pass)
+	flags := fmt.Sprintf("-v %s:/var/lib/registry -e %s=%s:%d -e REGISTRY_PROXY_REMOTEURL=%s -e REGISTRY_PROXY_TTL=%s -e REGISTRY_STORAGE_DELETE_ENABLED=true -e REGISTRY_PROXY_USERNAME -e REGISTRY_PROXY_PASSWORD",
+		c.Name, registryCacheAddrVar, listen, port, c.Upstream, ssh.ShellQuote(ttl))
+	return credentials + runService(monitoringService{Name: c.Name, Image: registryCacheImage}, flags, "") + " >/dev/null"
+}
+
+func (m *Manager) registryCacheInstall(opts registryCacheOptions) error {
+	total := 4
+	step := func(n int, msg string) { fmt.Printf("[%d/%d] %s\n", n, total, msg) }
+
+	listen := "127.0.0.1"
+	if opts.lan {
+		listen = "0.0.0.0"
+	}
+
+	step(1, "Pulling the registry image...")
+	if output, err := m.execStep("docker pull " + ssh.ShellQuote(registryCacheImage)); err != nil {
+		printOutput(output)
+		return fmt.Errorf("failed to pull %s: %w", registryCacheImage, err)
+	}
+
+	step(2, "Starting the caches...")
+	for _, c := range registryCaches {
+		port := opts.port + c.Offset
+		output, err := m.sshClient.Execute(startRegistryCache(c, listen, port, opts.ttl))
+		if err != nil {
+			printOutput(output)
+			return fmt.Errorf("failed to start %s: %w", c.Name, err)
+		}
+		fmt.Printf("  %s  %s:%d -> %s (%s)\n", c.Registry, listen, port, c.Upstream, strings.TrimSpace(output))
+	}
+
+	step(3, "Adding the Docker Hub cache to dockerd's registry-mirrors...")
+	changed, err := m.updateDockerMirror(mirrorURL(opts.port), true)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		fmt.Println("  already configured")
+	}
+
+	step(4, "Waiting for the caches to answer...")
+	for _, c := range registryCaches {
+		wait := fmt.Sprintf(`i=0; until curl -sf -o /dev/null http://127.0.0.1:%d/v2/; do
+  i=$((i+1)); [ $i -ge 30 ] && exit 1
+  docker inspect -f '{{.State.Running}}' %s 2>/dev/null | grep -q true || exit 2
+  sleep 1
+done`, opts.port+c.Offset, c.Name)
+		if _, err := m.sshClient.Execute(wait); err != nil {
+			return fmt.Errorf("%s did not come up; check 'dgx exec docker logs %s': %w", c.Name, c.Name, err)
+		}
+	}
+
+	fmt.Printf("\nDocker Hub pulls on the DGX now go through the cache on port %d.\n", opts.port)
+	fmt.Printf("Pull NGC images through theirs by prefixing the cache address:\n")
+	fmt.Printf("  docker pull 127.0.0.1:%d/nvidia/pytorch:25.09-py3\n", opts.port+1)
+	fmt.Printf("Unused layers are dropped after %s; volumes %s keep the rest.\n", opts.ttl, strings.Join(registryCacheNames(), " and "))
+	if opts.lan {
+		fmt.Printf("\nOther machines can use the caches at %s:%d and %s:%d; list them under\n", m.sshClient.Host(), opts.port, m.sshClient.Host(), opts.port+1)
+		fmt.Println("insecure-registries in their daemon.json, since the caches speak plain HTTP.")
+	}
+	return nil
+}
+
+func registryCacheNames() []string {
+	names := make([]string, len(registryCaches))
+	for i, c := range registryCaches {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// registryCacheState is what status reports for one cache
+type registryCacheState struct {
+	Name   string
+	Status string
+	Addr   string
+	Repos  string
+	Bytes  int64
+}
+
+// registryCacheStatusScript prints "<name>\t<status>\t<addr>\t<repositories>\t<bytes>" per
+// cache, followed by dockerd's configured mirrors
+func registryCacheStatusScript() string {
+	var b strings.Builder
+	for _, c := range registryCaches {
+		fmt.Fprintf(&b, `name=%[1]s
+st=$(docker ps -a --filter "name=^$name$" --format '{{.Status}}')
+addr=$(docker inspect -f '{{range .Config.Env}}{{println .}}{{end}}' "$name" 2>/dev/null | sed -n 's/^%[2]s=//p')
+repos=$(curl -sf "http://$(echo "$addr" | sed 's/^0\.0\.0\.0:/127.0.0.1:/')/v2/_catalog?n=10000" 2>/dev/null | python3 -c 'import json,sys; print(len(json.load(sys.stdin)["repositories"]))' 2>/dev/null)
+size=$(sudo -n du -sb "$(docker volume inspect -f '{{.Mountpoint}}' "$name" 2>/dev/null)" 2>/dev/null | cut -f1)
+printf '%%s\t%%s\t%%s\t%%s\t%%s\n' "$name" "$st" "$addr" "$repos" "$size"
+`, c.Name, registryCacheAddrVar)
+	}
+	b.WriteString("echo \"mirrors:$(docker info --format '{{json .RegistryConfig.Mirrors}}' 2>/dev/null)\"\n")
+	return b.String()
+}
+
+// parseRegistryCacheStatus reads the output of registryCacheStatusScript
+func parseRegistryCacheStatus(output string) ([]registryCacheState, []string) {
+	var states []registryCacheState
+	var mirrors []string
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if rest, ok := strings.CutPrefix(line, "mirrors:"); ok {
+			_ = json.Unmarshal([]byte(strings.TrimSpace(rest)), &mirrors)
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 5 {
+			continue
+		}
+		size, _ := strconv.ParseInt(fields[4], 10, 64)
+		states = append(states, registryCacheState{Name: fields[0], Status: fields[1], Addr: fields[2], Repos: fields[3], Bytes: size})
+	}
+	return states, mirrors
+}
+
+func (m *Manager) registryCacheStatus() error {
+	output, err := m.sshClient.Execute(registryCacheStatusScript())
+	if err != nil {
+		return fmt.Errorf("failed to check status: %w", err)
+	}
+	states, mirrors := parseRegistryCacheStatus(output)
+	installed := false
+	for _, s := range states {
+		installed = installed || s.Status != ""
+	}
+	if !installed {
+		fmt.Println("The registry caches are not installed.")
+		fmt.Println("\nTo install them:")
+		fmt.Println("  dgx run registry-cache install")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CACHE\tUPSTREAM\tLISTEN\tREPOSITORIES\tSIZE\tSTATUS")
+	for i, s := range states {
+		size := "-"
+		if s.Bytes > 0 {
+			size = artifacts.FormatBytes(s.Bytes)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", s.Name, registryCaches[i].Registry, ui.OrDash(s.Addr), ui.OrDash(s.Repos), size, ui.OrDash(s.Status))
+	}
+	w.Flush()
+
+	if len(states) > 0 && states[0].Addr != "" {
+		_, port, _ := strings.Cut(states[0].Addr, ":")
+		n, _ := strconv.Atoi(port)
+		if !slices.Contains(mirrors, mirrorURL(n)) && !slices.Contains(mirrors, mirrorURL(n)+"/") {
+			fmt.Println("\ndockerd is not using the Docker Hub cache; rerun 'dgx run registry-cache install' to add it.")
+		}
+	}
+	return nil
+}
+
+func (m *Manager) registryCacheUninstall(purge, yes bool) error {
+	prompt := "Remove the registry caches? Cached layers are kept in the " + strings.Join(registryCacheNames(), " and ") + " volumes."
+	if purge {
+		prompt = "Remove the registry caches and delete every cached layer?"
+	}
+	if err := m.confirmDestructive(prompt, yes); err != nil {
+		return err
+	}
+
+	// The mirror goes first, so dockerd stops sending pulls to a cache that is going away
+	output, _ := m.sshClient.Execute(registryCacheStatusScript())
+	states, _ := parseRegistryCacheStatus(output)
+	port := registryCachePort
+	if len(states) > 0 {
+		if _, p, ok := strings.Cut(states[0].Addr, ":"); ok {
+			port, _ = strconv.Atoi(p)
+		}
+	}
+	if _, err := m.updateDockerMirror(mirrorURL(port), false); err != nil {
+		return err
+	}
+
+	names := strings.Join(registryCacheNames(), " ")
+	cmd := fmt.Sprintf("docker rm -f %s >/dev/null 2>&1; true", names)
+	if purge {
+		cmd += fmt.Sprintf(" && docker volume rm -f %s >/dev/null", names)
+	}
+	if output, err := m.execStep(cmd); err != nil {
+		printOutput(output)
+		return fmt.Errorf("failed to remove the registry caches: %w", err)
+	}
+	if purge {
+		fmt.Println("Registry caches and their layers removed.")
+	} else {
+		fmt.Println("Registry caches removed; reinstalling picks up the kept layers.")
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package playbook
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRegistryCacheOptions(t *testing.T) {
+	opts, err := parseRegistryCacheOptions([]string{"--port", "6000", "--lan"})
+	if err != nil {
+		t.Fatalf("parseRegistryCacheOptions: %v", err)
+	}
+	if opts.port != 6000 || !opts.lan || opts.ttl != registryCacheTTL {
+		t.Fatalf("options = %+v", opts)
+	}
+	for _, args := range [][]string{
+		{"--port", "65535"},
+		{"--ttl", "10m"},
+		{"--ttl", "week"},
+		{"extra"},
+	} {
+		if _, err := parseRegistryCacheOptions(args); err == nil {
+			t.Fatalf("parseRegistryCacheOptions(%v): expected an error", args)
+		}
+	}
+}
+
+func TestSetRegistryMirror(t *testing.T) {
+	url := mirrorURL(5000)
+	original := `{"runtimes": {"nvidia": {"path": "nvidia-container-runtime"}}, "registry-mirrors": ["https://mirror.lan"]}`
+	added, changed, err := setRegistryMirror(original, url, true)
+	if err != nil || !changed {
+		t.Fatalf("add: changed=%v err=%v", changed, err)
+	}
+	if !strings.Contains(added, `"nvidia-container-runtime"`) || strings.Index(added, url) > strings.Index(added, "https://mirror.lan") {
+		t.Fatalf("add lost settings or did not put the cache first:\n%s", added)
+	}
+	if _, changed, _ := setRegistryMirror(added, url, true); changed {
+		t.Fatalf("adding the cache twice changed daemon.json")
+	}
+
+	removed, changed, err := setRegistryMirror(added, url, false)
+	if err != nil || !changed || strings.Contains(removed, url) || !strings.Contains(removed, "https://mirror.lan") {
+		t.Fatalf("remove: changed=%v err=%v\n%s", changed, err, removed)
+	}
+	if empty, _, _ := setRegistryMirror("", url, true); !strings.Contains(empty, url) {
+		t.Fatalf("add to a missing daemon.json:\n%s", empty)
+	}
+	if cleared, _, _ := setRegistryMirror(`{"registry-mirrors": ["`+url+`/"]}`, url, false); strings.Contains(cleared, "registry-mirrors") {
+		t.Fatalf("removing the only mirror left the key:\n%s", cleared)
+	}
+	if _, _, err := setRegistryMirror("{not json", url, true); err == nil {
+		t.Fatalf("invalid daemon.json: expected an error")
+	}
+}
+
+func TestParseRegistryCacheStatus(t *testing.T) {
+	output := "dgx-registry-cache\tUp 2 hours\t127.0.0.1:5000\t12\t4096\n" +
+		"dgx-registry-cache-nvcr\t\t\t\t\n" +
+		`mirrors:["http://127.0.0.1:5000/"]` + "\n"
+	states, mirrors := parseRegistryCacheStatus(output)
+	if len(states) != 2 || states[0].Repos != "12" || states[0].Bytes != 4096 || states[1].Status != "" {
+		t.Fatalf("states = %+v", states)
+	}
+	if len(mirrors) != 1 || mirrors[0] != "http://127.0.0.1:5000/" {
+		t.Fatalf("mirrors = %v", mirrors)
+	}
+}
@@ -0,0 +1,74 @@
+package playbook
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultRetries is how many times an idempotent step is retried after a transient failure
+const DefaultRetries = 2
+
+// DefaultRetryDelay is the wait before the first retry; it doubles on each attempt
+const DefaultRetryDelay = 10 * time.Second
+
+// transientPatterns are output fragments of failures that usually clear up on their own
+var transientPatterns = []struct {
+	fragment string
+	reason   string
+}{
+	{"Could not get lock", "apt/dpkg lock is held by another process"},
+	{"Unable to acquire the dpkg frontend lock", "apt/dpkg lock is held by another process"},
+	{"is another process using it?", "apt/dpkg lock is held by another process"},
+	{"Cannot connect to the Docker daemon", "docker daemon is restarting"},
+	{"Is the docker daemon running?", "docker daemon is restarting"},
+	{"docker.sock: connect: connection refused", "docker daemon is restarting"},
+	{"TLS handshake timeout", "registry connection timed out"},
+	{"net/http: request canceled while waiting for connection", "registry connection timed out"},
+	{"i/o timeout", "network timed out"},
+	{"connection reset by peer", "network connection was reset"},
+	{"Temporary failure in name resolution", "DNS lookup failed"},
+	{"toomanyrequests", "registry rate limit hit"},
+	{"503 Service Unavailable", "upstream service unavailable"},
+}
+
+// transientReason reports whether command output matches a known transient failure
+func transientReason(output string) (string, bool) {
+	for _, p := range transientPatterns {
+		if strings.Contains(output, p.fragment) {
+			return p.reason, true
+		}
+	}
+	return "", false
+}
+
+// SetRetries sets how many times idempotent steps are retried after a transient failure
+func (m *Manager) SetRetries(retries int) {
+	if retries < 0 {
+		retries = 0
+	}
+	m.retries = retries
+}
+
+// execStep runs an idempotent step on the DGX. If it fails with a known transient error
+// (apt lock held, docker daemon restarting, registry timeouts) the step is retried with
+// backoff instead of failing the whole playbook. Only use it for commands that are safe
+// to run more than once.
+func (m *Manager) execStep(command string) (string, error) {
+	delay := m.retryDelay
+	for attempt := 0; ; attempt++ {
+		output, err := m.sshClient.Execute(command)
+		if err == nil {
+			return output, nil
+		}
+
+		reason, transient := transientReason(output + err.Error())
+		if !transient || attempt >= m.retries {
+			return output, err
+		}
+
+		fmt.Printf("Transient failure (%s); retrying in %v [%d/%d]...\n", reason, delay, attempt+1, m.retries)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
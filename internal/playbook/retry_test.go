@@ -0,0 +1,26 @@
+package playbook
+
+import "testing"
+
+func TestTransientReason(t *testing.T) {
+	t.Run("apt lock", func(t *testing.T) {
+		out := "E: Could not get lock /var/lib/dpkg/lock-frontend. It is held by process 1234 (unattended-upgr)"
+		if _, ok := transientReason(out); !ok {
+			t.Fatalf("expected apt lock to be transient")
+		}
+	})
+
+	t.Run("docker daemon", func(t *testing.T) {
+		out := "Cannot connect to the Docker daemon at unix:///var/run/docker.sock. Is the docker daemon running?"
+		if reason, ok := transientReason(out); !ok || reason != "docker daemon is restarting" {
+			t.Fatalf("expected docker restart, got %q %v", reason, ok)
+		}
+	})
+
+	t.Run("real failure", func(t *testing.T) {
+		out := "Error response from daemon: manifest for nvcr.io/nvidia/vllm:bogus not found"
+		if _, ok := transientReason(out); ok {
+			t.Fatalf("expected missing manifest to be permanent")
+		}
+	})
+}
@@ -0,0 +1,248 @@
+package playbook
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/weatherman/dgx-manager/internal/sdgen"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/state"
+)
+
+// sdgenDir holds checkpoints and generated images on the DGX; they outlive the container
+const sdgenDir = "$HOME/.local/share/dgx/sdgen"
+
+// sdgenDefaultCheckpoint is downloaded by deploy unless --checkpoint says otherwise
+const sdgenDefaultCheckpoint = "stabilityai/stable-diffusion-xl-base-1.0/sd_xl_base_1.0.safetensors"
+
+// sdgenReadyWait bounds how long deploy waits for the server; sd-webui installs its
+// dependencies on first start
+const sdgenReadyWait = 900
+
+var sdgenCheckpointPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*/[A-Za-z0-9._-]+/[A-Za-z0-9._/-]+\.(safetensors|ckpt)$`)
+
+// sdgenDockerfiles build each server on the NGC PyTorch image, which already has CUDA
+// PyTorch for aarch64; the upstream torch pins are skipped so they do not replace it
+var sdgenDockerfiles = map[string]string{
+	sdgen.UIComfy: `FROM nvcr.io/nvidia/pytorch:25.09-py3
+RUN git clone --depth 1 https://github.com/comfyanonymous/ComfyUI.git /opt/ComfyUI \
+ && grep -vE '^(torch|torchvision|torchaudio)([<>=~ ]|$)' /opt/ComfyUI/requirements.txt > /tmp/requirements.txt \
+ && pip install --no-cache-dir -r /tmp/requirements.txt
+WORKDIR /opt/ComfyUI
+CMD ["python3", "main.py", "--listen", "0.0.0.0", "--port", "8188"]
+`,
+	sdgen.UIWebUI: `FROM nvcr.io/nvidia/pytorch:25.09-py3
+RUN git clone --depth 1 https://github.com/AUTOMATIC1111/stable-diffusion-webui.git /opt/sd-webui \
+ && grep -vE '^(torch|torchvision|xformers)([<>=~ ]|$)' /opt/sd-webui/requirements_versions.txt > /tmp/requirements.txt \
+ && pip install --no-cache-dir -r /tmp/requirements.txt
+WORKDIR /opt/sd-webui
+RUN python3 launch.py --exit --skip-torch-cuda-test
+CMD ["python3", "launch.py", "--listen", "--port", "7860", "--api", "--skip-version-check"]
+`,
+}
+
+// sdgenMounts maps the shared checkpoint and output directories into each server
+var sdgenMounts = map[string][2]string{
+	sdgen.UIComfy: {"/opt/ComfyUI/models/checkpoints", "/opt/ComfyUI/output"},
+	sdgen.UIWebUI: {"/opt/sd-webui/models/Stable-diffusion", "/opt/sd-webui/outputs"},
+}
+
+// sdgenOptions are the flags of 'dgx run sdgen deploy'
+type sdgenOptions struct {
+	ui         string
+	port       int
+	localPort  int
+	checkpoint string
+	noTunnel   bool
+	rebuild    bool
+}
+
+// runSDGen handles the image generation server
+func (m *Manager) runSDGen(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("sdgen command required. Usage: dgx run sdgen <deploy|status|stop>")
+	}
+	command, rest := args[0], args[1:]
+
+	switch command {
+	case "deploy":
+		opts, err := parseSDGenOptions(rest)
+		if err != nil {
+			return err
+		}
+		return m.sdgenDeploy(opts)
+	case "status":
+		return m.sdgenStatus()
+	case "stop":
+		return m.sdgenStop()
+	default:
+		return fmt.Errorf("unknown sdgen command: %s", command)
+	}
+}
+
+func parseSDGenOptions(args []string) (sdgenOptions, error) {
+	var opts sdgenOptions
+	var port, localPort string
+	args, opts.noTunnel = removeFlag(args, "--no-tunnel")
+	args, opts.rebuild = removeFlag(args, "--rebuild")
+	args, opts.ui = flagValue(args, "--ui")
+	args, port = flagValue(args, "--port")
+	args, localPort = flagValue(args, "--local-port")
+	args, opts.checkpoint = flagValue(args, "--checkpoint")
+	if len(args) > 0 {
+		return opts, fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+	}
+
+	if opts.ui == "" {
+		opts.ui = sdgen.UIComfy
+	}
+	if !slices.Contains(sdgen.UIs, opts.ui) {
+		return opts, fmt.Errorf("unknown --ui %q (want %s)", opts.ui, strings.Join(sdgen.UIs, " or "))
+	}
+	opts.port = sdgen.DefaultPort(opts.ui)
+	var err error
+	if opts.port, opts.localPort, err = parsePorts(port, localPort, opts.port); err != nil {
+		return opts, err
+	}
+
+	if opts.checkpoint == "" {
+		opts.checkpoint = sdgenDefaultCheckpoint
+	}
+	if opts.checkpoint != "none" && !sdgenCheckpointPattern.MatchString(opts.checkpoint) {
+		return opts, fmt.Errorf("invalid --checkpoint %q (want <org>/<repo>/<file>.safetensors on Hugging Face, or none)", opts.checkpoint)
+	}
+	return opts, nil
+}
+
+// sdgenImage is the image built on the DGX for a server
+func sdgenImage(ui string) string {
+	return "dgx-" + ui + ":latest"
+}
+
+// checkpointURL returns the Hugging Face download URL and file name of a checkpoint given
+// as <org>/<repo>/<path>
+func checkpointURL(checkpoint string) (string, string) {
+	parts := strings.SplitN(checkpoint, "/", 3)
+	return fmt.Sprintf("https://huggingface.co/%s/%s/resolve/main/%s", parts[0], parts[1], parts[2]), path.Base(parts[2])
+}
+
+func (m *Manager) sdgenDeploy(opts sdgenOptions) error {
+	image := sdgenImage(opts.ui)
+	imageMissing := true
+	if !opts.rebuild {
+		_, err := m.sshClient.Execute("docker image inspect " + image + " >/dev/null 2>&1")
+		imageMissing = err != nil
+	}
+	if imageMissing {
+		fmt.Printf("[1/4] Building %s on the DGX (this takes a while the first time)...\n", image)
+		if err := m.sshClient.Stream("docker build -t "+image+" -", strings.NewReader(sdgenDockerfiles[opts.ui]), os.Stdout, os.Stderr); err != nil {
+			return fmt.Errorf("failed to build %s: %w", image, err)
+		}
+	} else {
+		fmt.Printf("[1/4] Image %s is already built (--rebuild to update it)\n", image)
+	}
+
+	checkpoints := sdgenDir + "/models/checkpoints"
+	name := ""
+	if opts.checkpoint == "none" {
+		fmt.Println("[2/4] Skipping the checkpoint download")
+	} else {
+		var url string
+		url, name = checkpointURL(opts.checkpoint)
+		target := fmt.Sprintf(`"%s/"%s`, checkpoints, ssh.ShellQuote(name))
+		fmt.Printf("[2/4] Fetching checkpoint %s...\n", name)
+		cmd := fmt.Sprintf(`mkdir -p "%s" "%s/output" && if [ ! -s %s ]; then
+  set -- ; [ -n "$HF_TOKEN" ] && set -- -H "Authorization: Bearer $HF_TOKEN"
+  curl -fL --retry 3 "$@" -o %s.part %s && mv %s.part %s
+fi`, checkpoints, sdgenDir, target, target, ssh.ShellQuote(url), target, target)
+		if err := m.sshClient.Stream(cmd, nil, os.Stdout, os.Stderr); err != nil {
+			return fmt.Errorf("failed to download %s (gated models need HF_TOKEN on the DGX): %w", opts.checkpoint, err)
+		}
+	}
+
+	fmt.Printf("[3/4] Starting %s on port %d...\n", opts.ui, opts.port)
+	mounts := sdgenMounts[opts.ui]
+	run := fmt.Sprintf(`mkdir -p "%s" "%s/output" && docker rm -f %s >/dev/null 2>&1; docker run -d \
+		--name %s \
+		--restart unless-stopped \
+		--gpus all \
+		--ipc=host \
+		-p 127.0.0.1:%d:%d \
+		-v "%s":%s \
+		-v "%s/output":%s \
+		%s`,
+		checkpoints, sdgenDir, sdgen.ContainerName, sdgen.ContainerName,
+		opts.port, sdgen.DefaultPort(opts.ui), checkpoints, mounts[0], sdgenDir, mounts[1], image)
+	if output, err := m.sshClient.Execute(run); err != nil {
+		printOutput(output)
+		return fmt.Errorf("failed to start %s: %w", opts.ui, err)
+	}
+
+	fmt.Println("[4/4] Waiting for the server to answer...")
+	wait := fmt.Sprintf(`i=0; until curl -sf -o /dev/null http://127.0.0.1:%d%s; do
+  i=$((i+5)); [ $i -ge %d ] && exit 1
+  docker inspect -f '{{.State.Running}}' %s 2>/dev/null | grep -q true || exit 2
+  sleep 5
+done`, opts.port, sdgen.ReadyPath(opts.ui), sdgenReadyWait, sdgen.ContainerName)
+	if _, err := m.sshClient.Execute(wait); err != nil {
+		return fmt.Errorf("%s did not come up; check 'dgx exec docker logs %s': %w", opts.ui, sdgen.ContainerName, err)
+	}
+
+	deployment := sdgen.Deployment{UI: opts.ui, Port: opts.port, Checkpoint: name}
+	if store, err := state.DefaultStore(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	} else if err := store.Save(sdgen.StateKey(m.sshClient.Host()), deployment); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	fmt.Printf("\n%s is running on the DGX (127.0.0.1:%d).\n", opts.ui, opts.port)
+	m.openTunnel(opts.localPort, opts.port, opts.ui, opts.noTunnel)
+	fmt.Println("\nGenerate from the command line:")
+	fmt.Println(`  dgx imagine "a lighthouse at dusk, oil painting" -o out.png`)
+	return nil
+}
+
+func (m *Manager) sdgenStatus() error {
+	output, err := m.sshClient.Execute(fmt.Sprintf("docker ps -a --filter 'name=^%s$' --format '{{.Image}}\t{{.Status}}'", sdgen.ContainerName))
+	if err != nil {
+		return fmt.Errorf("failed to check status: %w", err)
+	}
+	image, status, _ := strings.Cut(strings.TrimSpace(output), "\t")
+	if image == "" {
+		fmt.Println("No image generation server is deployed.")
+		fmt.Println("\nTo deploy one:")
+		fmt.Println("  dgx run sdgen deploy [--ui comfyui|sd-webui]")
+		return nil
+	}
+
+	var deployment sdgen.Deployment
+	if store, err := state.DefaultStore(); err == nil {
+		store.Load(sdgen.StateKey(m.sshClient.Host()), &deployment)
+	}
+	fmt.Printf("Image:       %s\n", image)
+	fmt.Printf("Container:   %s\n", status)
+	if deployment.Port > 0 {
+		fmt.Printf("Server:      %s on 127.0.0.1:%d\n", deployment.UI, deployment.Port)
+		ready := "no"
+		if _, err := m.sshClient.Execute(fmt.Sprintf("curl -sf -o /dev/null http://127.0.0.1:%d%s", deployment.Port, sdgen.ReadyPath(deployment.UI))); err == nil {
+			ready = "yes"
+		}
+		fmt.Printf("Answering:   %s\n", ready)
+	}
+	if files, err := m.sshClient.Execute(fmt.Sprintf(`ls "%s/models/checkpoints" 2>/dev/null`, sdgenDir)); err == nil && strings.TrimSpace(files) != "" {
+		fmt.Printf("Checkpoints: %s\n", strings.Join(strings.Fields(files), ", "))
+	}
+	return nil
+}
+
+func (m *Manager) sdgenStop() error {
+	if _, err := m.sshClient.Execute(fmt.Sprintf("docker rm -f %s", sdgen.ContainerName)); err != nil {
+		return fmt.Errorf("failed to stop the server: %w", err)
+	}
+	fmt.Printf("Image generation server stopped. Checkpoints and images stay in %s.\n", strings.Replace(sdgenDir, "$HOME", "~", 1))
+	return nil
+}
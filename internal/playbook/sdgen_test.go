@@ -0,0 +1,29 @@
+package playbook
+
+import "testing"
+
+func TestParseSDGenOptions(t *testing.T) {
+	opts, err := parseSDGenOptions([]string{"--ui", "sd-webui", "--local-port=7861"})
+	if err != nil {
+		t.Fatalf("parseSDGenOptions: %v", err)
+	}
+	if opts.port != 7860 || opts.localPort != 7861 || opts.checkpoint != sdgenDefaultCheckpoint {
+		t.Fatalf("options = %+v", opts)
+	}
+
+	url, name := checkpointURL("stabilityai/sdxl-turbo/sd_xl_turbo_1.0_fp16.safetensors")
+	if url != "https://huggingface.co/stabilityai/sdxl-turbo/resolve/main/sd_xl_turbo_1.0_fp16.safetensors" || name != "sd_xl_turbo_1.0_fp16.safetensors" {
+		t.Fatalf("checkpointURL = %s, %s", url, name)
+	}
+
+	for _, args := range [][]string{
+		{"--ui", "invokeai"},
+		{"--port", "99999"},
+		{"--checkpoint", "model.safetensors"},
+		{"--checkpoint", "org/repo/$(reboot).safetensors"},
+	} {
+		if _, err := parseSDGenOptions(args); err == nil {
+			t.Fatalf("parseSDGenOptions(%v): expected an error", args)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package playbook
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/state"
+)
+
+// Snapshot is the pre-change state of a DGX captured before a playbook modifies it. Backups
+// live on the DGX under Dir; the local state only records where they are.
+type Snapshot struct {
+	Playbook string         `json:"playbook"`
+	Host     string         `json:"host"`
+	Dir      string         `json:"dir"`
+	Files    []SnapshotFile `json:"files"`
+	Groups   []string       `json:"groups"`
+	// Packages are the playbook's own packages that were installed when the snapshot was
+	// taken; Installed are those the playbook installed since, the only ones rollback purges
+	Packages  []string  `json:"packages,omitempty"`
+	Installed []string  `json:"installed,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SnapshotFile records whether a touched file existed before the playbook ran
+type SnapshotFile struct {
+	Path    string `json:"path"`
+	Existed bool   `json:"existed"`
+}
+
+func snapshotKey(host, playbook string) string {
+	return state.Key("snapshot", host, playbook)
+}
+
+// backupName maps an absolute path to its file name inside the snapshot directory
+func backupName(path string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(path, "/"), "/", "__")
+}
+
+// installedQuery lists which of pkgs are installed, one "pkg:" line each
+func installedQuery(pkgs []string) string {
+	if len(pkgs) == 0 {
+		return ""
+	}
+	quoted := make([]string, len(pkgs))
+	for i, p := range pkgs {
+		quoted[i] = ssh.ShellQuote(p)
+	}
+	return fmt.Sprintf(`for p in %s; do
+  if dpkg-query -W -f='${Status}' "$p" 2>/dev/null | grep -q 'ok installed' || rpm -q "$p" >/dev/null 2>&1; then echo "pkg:$p"; fi
+done
+`, strings.Join(quoted, " "))
+}
+
+// parseInstalled reads the package names printed by installedQuery
+func parseInstalled(output string) []string {
+	var pkgs []string
+	for _, line := range strings.Split(output, "\n") {
+		if name, ok := strings.CutPrefix(strings.TrimSpace(line), "pkg:"); ok && name != "" {
+			pkgs = append(pkgs, name)
+		}
+	}
+	return pkgs
+}
+
+// newlyInstalled returns the packages of declared, in order, that are in now but not in
+// before
+func newlyInstalled(declared, before, now []string) []string {
+	var pkgs []string
+	for _, p := range declared {
+		if slices.Contains(now, p) && !slices.Contains(before, p) && !slices.Contains(pkgs, p) {
+			pkgs = append(pkgs, p)
+		}
+	}
+	return pkgs
+}
+
+// ensureSnapshot captures touched files, the playbook's installed packages, and group membership
+// before a playbook changes the system. An existing snapshot is kept so that reruns and
+// resumes still roll back to the state before the first run.
+func (m *Manager) ensureSnapshot(playbook string, files []string) error {
+	store, err := state.DefaultStore()
+	if err != nil {
+		return err
+	}
+	key := snapshotKey(m.sshClient.Host(), playbook)
+	var existing Snapshot
+	if found, err := store.Load(key, &existing); err != nil || found {
+		return err
+	}
+
+	now := time.Now()
+	dir := fmt.Sprintf("$HOME/.config/dgx/snapshots/%s-%s", strings.ReplaceAll(playbook, " ", "-"), now.Format("20060102-150405"))
+	var script strings.Builder
+	fmt.Fprintf(&script, "set -e\ndir=\"%s\"\nmkdir -p \"$dir/files\"\n", dir)
+	for _, f := range files {
+		q := ssh.ShellQuote(f)
+		fmt.Fprintf(&script, "if [ -e %s ]; then sudo -n cp -a %s \"$dir/files/%s\" 2>/dev/null || cp -a %s \"$dir/files/%s\"; echo \"file:1:%s\"; else echo \"file:0:%s\"; fi\n",
+			q, q, backupName(f), q, backupName(f), f, f)
+	}
+	script.WriteString(installedQuery(rollbackPlans[playbook].Packages))
+	script.WriteString(`echo "groups:$(id -nG)"
+echo "dir:$(cd "$dir" && pwd)"
+`)
+
+	output, err := m.sshClient.Execute(script.String())
+	if err != nil {
+		return fmt.Errorf("failed to snapshot system state: %w", err)
+	}
+
+	snap := Snapshot{Playbook: playbook, Host: m.sshClient.Host(), Packages: parseInstalled(output), CreatedAt: now}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "file:"):
+			parts := strings.SplitN(line, ":", 3)
+			if len(parts) == 3 {
+				snap.Files = append(snap.Files, SnapshotFile{Path: parts[2], Existed: parts[1] == "1"})
+			}
+		case strings.HasPrefix(line, "groups:"):
+			snap.Groups = strings.Fields(strings.TrimPrefix(line, "groups:"))
+		case strings.HasPrefix(line, "dir:"):
+			snap.Dir = strings.TrimPrefix(line, "dir:")
+		}
+	}
+	if snap.Dir == "" {
+		return fmt.Errorf("failed to snapshot system state: unexpected output")
+	}
+
+	fmt.Printf("Saved pre-change snapshot on the DGX (%s). Undo with: dgx run %s rollback\n", snap.Dir, playbook)
+	return store.Save(key, snap)
+}
+
+// recordInstalled adds the packages the playbook installed since its snapshot to the
+// snapshot, so rollback purges those and nothing the user installed in between. Call it
+// after the playbook ran, even if it failed partway.
+func (m *Manager) recordInstalled(playbook string) {
+	declared := rollbackPlans[playbook].Packages
+	if len(declared) == 0 {
+		return
+	}
+	store, err := state.DefaultStore()
+	if err != nil {
+		return
+	}
+	key := snapshotKey(m.sshClient.Host(), playbook)
+	var snap Snapshot
+	if found, err := store.Load(key, &snap); err != nil || !found {
+		return
+	}
+	output, err := m.sshClient.Execute(installedQuery(declared))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record installed packages (rollback will not purge them): %v\n", err)
+		return
+	}
+	added := newlyInstalled(declared, append(snap.Packages, snap.Installed...), parseInstalled(output))
+	if len(added) == 0 {
+		return
+	}
+	snap.Installed = append(snap.Installed, added...)
+	if err := store.Save(key, snap); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record installed packages (rollback will not purge them): %v\n", err)
+	}
+}
+
+// rollbackPlan is what a playbook's rollback undoes besides restoring touched files
+type rollbackPlan struct {
+	Packages []string // packages the playbook may install; those it did are purged
+	Groups   []string // drop memberships in these groups that the snapshot did not have
+	After    string   // shell run after the files are restored, e.g. to reload a service
+}
+
+var rollbackPlans = map[string]rollbackPlan{
+	"dmr": {
+		// get.docker.com, dmr setup's plugin step, and the container toolkit
+		Packages: []string{
+			"docker-ce", "docker-ce-cli", "containerd.io", "docker-buildx-plugin",
+			"docker-compose-plugin", "docker-ce-rootless-extras", "docker-model-plugin",
+			"nvidia-container-toolkit", "nvidia-container-toolkit-base",
+		},
+		Groups: []string{"docker"},
+		After:  "if systemctl list-unit-files docker.service >/dev/null 2>&1; then sudo systemctl restart docker || true; fi\n",
+	},
+	"tune": {
+		After: tuneReload,
+	},
+}
+
+// rollback restores the snapshot taken before a playbook first ran: touched files are put
+// back (or removed if they did not exist), and depending on the playbook's rollbackPlan,
+// the packages it installed are purged and added group memberships are dropped.
+func (m *Manager) rollback(playbook string) error {
+	store, err := state.DefaultStore()
+	if err != nil {
+		return err
+	}
+	key := snapshotKey(m.sshClient.Host(), playbook)
+	var snap Snapshot
+	found, err := store.Load(key, &snap)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("no snapshot recorded for '%s' on %s; nothing to roll back", playbook, m.sshClient.Host())
+	}
+
+	plan := rollbackPlans[playbook]
+	// Only what the playbook recorded installing, and is still installed
+	var newPackages []string
+	if len(snap.Installed) > 0 {
+		output, err := m.sshClient.Execute(installedQuery(snap.Installed))
+		if err != nil {
+			return fmt.Errorf("failed to check installed packages: %w", err)
+		}
+		newPackages = newlyInstalled(snap.Installed, nil, parseInstalled(output))
+	}
+
+	var dropGroups []string
+	for _, g := range plan.Groups {
+		if !slices.Contains(snap.Groups, g) {
+			dropGroups = append(dropGroups, g)
+		}
+	}
+
+	fmt.Printf("Rolling back '%s' to the snapshot from %s:\n", playbook, snap.CreatedAt.Format(time.RFC822))
+	for _, f := range snap.Files {
+		if f.Existed {
+			fmt.Printf("  restore  %s\n", f.Path)
+		} else {
+			fmt.Printf("  remove   %s\n", f.Path)
+		}
+	}
+	if len(newPackages) > 0 {
+		fmt.Printf("  purge    %s\n", strings.Join(newPackages, " "))
+	}
+	for _, g := range dropGroups {
+		fmt.Printf("  drop     %s group membership\n", g)
+	}
+	if err := m.confirmDestructive("Continue?", false); err != nil {
+		return err
+	}
+	if len(newPackages) > 0 {
+		if err := m.confirmDestructive(fmt.Sprintf("Purge %d packages installed by '%s' (%s)?", len(newPackages), playbook, strings.Join(newPackages, ", ")), false); err != nil {
+			return err
+		}
+	}
+
+	var script strings.Builder
+	for _, f := range snap.Files {
+		if f.Existed {
+			fmt.Fprintf(&script, "sudo cp -a %s %s\n", ssh.ShellQuote(snap.Dir+"/files/"+backupName(f.Path)), ssh.ShellQuote(f.Path))
+		} else {
+			fmt.Fprintf(&script, "sudo rm -f %s\n", ssh.ShellQuote(f.Path))
+		}
+	}
+	if len(newPackages) > 0 {
+		quoted := make([]string, len(newPackages))
+		for i, p := range newPackages {
+			quoted[i] = ssh.ShellQuote(p)
+		}
+		pkgs := strings.Join(quoted, " ")
+		fmt.Fprintf(&script, "if command -v apt-get >/dev/null 2>&1; then sudo apt-get purge -y %s; elif command -v dnf >/dev/null 2>&1; then sudo dnf remove -y %s; fi\n", pkgs, pkgs)
+	}
+	for _, g := range dropGroups {
+		fmt.Fprintf(&script, "sudo gpasswd -d \"$(whoami)\" %s >/dev/null 2>&1 || true\n", ssh.ShellQuote(g))
+	}
+	script.WriteString(plan.After)
+
+	if err := m.sshClient.RunInteractive(script.String()); err != nil {
+		return fmt.Errorf("rollback failed: %w", err)
+	}
+
+	if _, err := m.sshClient.Execute("rm -rf " + ssh.ShellQuote(snap.Dir)); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to remove snapshot directory %s: %v\n", snap.Dir, err)
+	}
+	if err := store.Delete(key); err != nil {
+		return err
+	}
+	fmt.Println("Rollback complete.")
+	return nil
+}
@@ -0,0 +1,46 @@
+package playbook
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+func TestParseInstalled(t *testing.T) {
+	output := "pkg:docker-ce\n  pkg:docker-model-plugin  \npkg:\nwarning: something\n"
+	got := parseInstalled(output)
+	if want := []string{"docker-ce", "docker-model-plugin"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseInstalled = %v, want %v", got, want)
+	}
+	if installedQuery(nil) != "" {
+		t.Fatalf("expected no query without packages")
+	}
+	if q := installedQuery([]string{"it's"}); !strings.Contains(q, ssh.ShellQuote("it's")) {
+		t.Fatalf("package names not quoted: %s", q)
+	}
+}
+
+func TestNewlyInstalled(t *testing.T) {
+	declared := rollbackPlans["dmr"].Packages
+	// docker was there before setup; htop was installed by the user in between
+	before := []string{"docker-ce", "docker-ce-cli", "containerd.io"}
+	now := []string{"docker-ce", "docker-ce-cli", "containerd.io", "docker-model-plugin", "nvidia-container-toolkit", "htop"}
+	got := newlyInstalled(declared, before, now)
+	if want := []string{"docker-model-plugin", "nvidia-container-toolkit"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("newlyInstalled = %v, want %v", got, want)
+	}
+
+	// The purge list is what setup recorded and is still installed
+	purge := newlyInstalled([]string{"docker-model-plugin", "nvidia-container-toolkit"}, nil, []string{"nvidia-container-toolkit", "htop"})
+	if want := []string{"nvidia-container-toolkit"}; !reflect.DeepEqual(purge, want) {
+		t.Fatalf("purge list = %v, want %v", purge, want)
+	}
+	if got := newlyInstalled([]string{"a", "a"}, nil, []string{"a"}); len(got) != 1 {
+		t.Fatalf("duplicates kept: %v", got)
+	}
+	if got := newlyInstalled(nil, nil, now); got != nil {
+		t.Fatalf("a playbook without packages should purge nothing, got %v", got)
+	}
+}
@@ -0,0 +1,111 @@
+package playbook
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/redact"
+	"github.com/weatherman/dgx-manager/internal/state"
+)
+
+// Step is one idempotent unit of a multi-step playbook
+type Step struct {
+	Name        string
+	Description string
+	Command     string
+}
+
+// Checkpoint records which steps of a playbook run have completed on a host
+type Checkpoint struct {
+	Playbook  string    `json:"playbook"`
+	Host      string    `json:"host"`
+	Completed []string  `json:"completed"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (c *Checkpoint) done(step string) bool {
+	for _, s := range c.Completed {
+		if s == step {
+			return true
+		}
+	}
+	return false
+}
+
+// runSteps executes steps in order, recording each success in local state. With resume,
+// steps completed by a previous failed run are skipped. The checkpoint is cleared once
+// every step has succeeded.
+func (m *Manager) runSteps(run string, steps []Step, resume bool) error {
+	key := state.Key("checkpoint", m.sshClient.Host(), run)
+	checkpoint := Checkpoint{Playbook: run, Host: m.sshClient.Host()}
+
+	store, err := state.DefaultStore()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: checkpoints disabled: %v\n", err)
+	}
+	if store != nil {
+		if resume {
+			found, err := store.Load(key, &checkpoint)
+			if err != nil {
+				return err
+			}
+			if !found {
+				fmt.Println("No checkpoint found; running all steps.")
+			}
+		} else if err := store.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	for i, step := range steps {
+		prefix := fmt.Sprintf("[%d/%d] %s", i+1, len(steps), step.Description)
+		if checkpoint.done(step.Name) {
+			fmt.Printf("%s (done, skipping)\n", prefix)
+			continue
+		}
+
+		fmt.Println(prefix)
+		output, err := m.execStep(step.Command)
+		if strings.TrimSpace(output) != "" {
+			printOutput(strings.TrimRight(output, "\n"))
+		}
+		if err != nil {
+			if store != nil && len(checkpoint.Completed) > 0 {
+				fmt.Printf("\nCompleted steps were saved. Resume with: dgx run %s --resume\n", run)
+			}
+			return fmt.Errorf("step %q failed: %w", step.Name, err)
+		}
+
+		checkpoint.Completed = append(checkpoint.Completed, step.Name)
+		checkpoint.UpdatedAt = time.Now()
+		if store != nil {
+			if err := store.Save(key, checkpoint); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}
+	}
+
+	if store != nil {
+		if err := store.Delete(key); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// hasFlag reports whether flag appears in args (playbook args bypass cobra flag parsing)
+func hasFlag(args []string, flag string) bool {
+	for _, a := range args {
+		if a == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// printOutput shows remote command output with secrets masked
+func printOutput(output string) {
+	fmt.Println(redact.Default().String(output))
+}
@@ -0,0 +1,72 @@
+package playbook
+
+import "strings"
+
+// parseTable parses whitespace-aligned CLI table output (docker, docker model) into rows keyed
+// by upper-cased header. Column boundaries are taken from the header line, so values that
+// contain single spaces (e.g. "4 minutes from now") are kept intact.
+func parseTable(output string) []map[string]string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	var header string
+	start := 0
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			header = line
+			start = i + 1
+			break
+		}
+	}
+	if header == "" {
+		return nil
+	}
+
+	type column struct {
+		name  string
+		start int
+	}
+	var columns []column
+	for i := 0; i < len(header); {
+		if header[i] == ' ' {
+			i++
+			continue
+		}
+		// A column name ends at two consecutive spaces or end of line
+		j := i
+		for j < len(header) && !(header[j] == ' ' && (j+1 >= len(header) || header[j+1] == ' ')) {
+			j++
+		}
+		columns = append(columns, column{name: strings.ToUpper(strings.TrimSpace(header[i:j])), start: i})
+		i = j
+	}
+
+	var rows []map[string]string
+	for _, line := range lines[start:] {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		row := make(map[string]string, len(columns))
+		for idx, col := range columns {
+			if col.start >= len(line) {
+				row[col.name] = ""
+				continue
+			}
+			end := len(line)
+			if idx+1 < len(columns) && columns[idx+1].start < len(line) {
+				end = columns[idx+1].start
+			}
+			row[col.name] = strings.TrimSpace(line[col.start:end])
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// firstField returns the first non-empty value among the given column names
+func firstField(row map[string]string, names ...string) string {
+	for _, name := range names {
+		if v := row[name]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,23 @@
+package playbook
+
+import "testing"
+
+func TestParseLoadedModels(t *testing.T) {
+	output := `MODEL NAME                 BACKEND    MODE        UNTIL
+ai/smollm2:360M-Q4_K_M     llama.cpp  completion  4 minutes from now
+ai/qwen3-embedding         llama.cpp  embedding   Never
+`
+	models := parseLoadedModels(output)
+	if len(models) != 2 {
+		t.Fatalf("expected 2 models, got %d", len(models))
+	}
+	if models[0].Name != "ai/smollm2:360M-Q4_K_M" {
+		t.Fatalf("unexpected name %q", models[0].Name)
+	}
+	if models[0].Until != "4 minutes from now" {
+		t.Fatalf("unexpected until %q", models[0].Until)
+	}
+	if models[1].Mode != "embedding" || models[1].Backend != "llama.cpp" {
+		t.Fatalf("unexpected row %+v", models[1])
+	}
+}
@@ -0,0 +1,262 @@
+package playbook
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/health"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/ui"
+)
+
+// NTP services the time playbook can configure
+const (
+	ntpChrony    = "chrony"
+	ntpTimesyncd = "timesyncd"
+)
+
+// syncWait bounds how long 'time sync' waits for the first NTP synchronization
+const syncWait = 45 * time.Second
+
+// ntpServerPattern limits servers to host names and addresses; they end up in config files
+var ntpServerPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.:-]*$`)
+
+// timeStatusScript prints key=value lines describing the clock and NTP setup
+const timeStatusScript = `svc=none
+for u in chrony chronyd systemd-timesyncd ntp ntpsec; do
+  if systemctl is-active --quiet "$u" 2>/dev/null; then svc=$u; break; fi
+done
+echo "service=$svc"
+echo "synced=$(timedatectl show -p NTPSynchronized --value 2>/dev/null)"
+echo "ntp=$(timedatectl show -p NTP --value 2>/dev/null)"
+echo "local_rtc=$(timedatectl show -p LocalRTC --value 2>/dev/null)"
+echo "timezone=$(timedatectl show -p Timezone --value 2>/dev/null)"
+case "$svc" in
+  chrony*) echo "server=$(chronyc -n tracking 2>/dev/null | awk -F': ' '/Reference ID/ {print $2}')" ;;
+  systemd-timesyncd) echo "server=$(timedatectl show-timesync -p ServerName --value 2>/dev/null)" ;;
+esac`
+
+// runTime handles clock checks and NTP setup
+func (m *Manager) runTime(args []string) error {
+	command := "status"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "--") {
+		command, args = args[0], args[1:]
+	}
+
+	args, resume := removeFlag(args, "--resume")
+	args, service := flagValue(args, "--service")
+	args, servers := flagValue(args, "--servers")
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+	}
+
+	switch command {
+	case "status":
+		return m.timeStatus()
+	case "sync":
+		return m.timeSync(service, servers, resume)
+	default:
+		return fmt.Errorf("unknown time command: %s. Usage: dgx run time [status|sync] [--service chrony|timesyncd] [--servers a,b] [--resume]", command)
+	}
+}
+
+func (m *Manager) timeStatus() error {
+	skew, skewErr := health.ClockSkew(context.Background(), m.sshClient)
+	output, err := m.sshClient.Execute(timeStatusScript)
+	if err != nil {
+		return fmt.Errorf("failed to read the time configuration: %w", err)
+	}
+	st := parseKeyValues(output)
+
+	if skewErr != nil {
+		fmt.Printf("Skew:          unknown (%v)\n", skewErr)
+	} else {
+		fmt.Printf("Skew:          DGX is %s\n", health.FormatSkew(skew))
+	}
+	fmt.Printf("NTP service:   %s\n", st["service"])
+	fmt.Printf("Synchronized:  %s\n", ui.OrDash(st["synced"]))
+	fmt.Printf("NTP enabled:   %s\n", ui.OrDash(st["ntp"]))
+	fmt.Printf("Server:        %s\n", ui.OrDash(st["server"]))
+	fmt.Printf("Time zone:     %s\n", ui.OrDash(st["timezone"]))
+	if st["local_rtc"] == "yes" {
+		fmt.Println("RTC:           local time (dual-boot setting; 'dgx run time sync' switches it to UTC)")
+	}
+
+	abs := skew
+	if abs < 0 {
+		abs = -abs
+	}
+	if st["service"] == "none" || st["synced"] != "yes" || (skewErr == nil && abs >= health.SkewWarn) {
+		fmt.Println("\nFix with: dgx run time sync")
+	}
+	return nil
+}
+
+// parseKeyValues parses key=value lines, ignoring anything else
+func parseKeyValues(output string) map[string]string {
+	values := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+			values[key] = strings.TrimSpace(value)
+		}
+	}
+	return values
+}
+
+func (m *Manager) timeSync(service, servers string, resume bool) error {
+	if service == "" {
+		out, err := m.sshClient.Execute("command -v chronyd >/dev/null 2>&1 && echo chrony || echo timesyncd")
+		if err != nil {
+			return fmt.Errorf("failed to detect the NTP service: %w", err)
+		}
+		service = strings.TrimSpace(out)
+	}
+	var list []string
+	if servers != "" {
+		list = strings.Split(servers, ",")
+	}
+	steps, err := timeSyncSteps(service, list)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Configuring %s on the DGX\n", ntpUnit(service))
+	if err := m.runSteps("time sync", steps, resume); err != nil {
+		return fmt.Errorf("failed to configure time sync: %w", err)
+	}
+
+	fmt.Print("Waiting for NTP synchronization...")
+	deadline := time.Now().Add(syncWait)
+	synced := false
+	for !synced && time.Now().Before(deadline) {
+		out, _ := m.sshClient.Execute("timedatectl show -p NTPSynchronized --value")
+		if synced = strings.TrimSpace(out) == "yes"; !synced {
+			time.Sleep(3 * time.Second)
+		}
+	}
+	if synced {
+		fmt.Println(" done")
+	} else {
+		fmt.Println(" not yet (servers may be unreachable; check with 'dgx run time status')")
+	}
+
+	if skew, err := health.ClockSkew(context.Background(), m.sshClient); err == nil {
+		fmt.Printf("DGX clock is %s\n", health.FormatSkew(skew))
+		if skew >= health.SkewWarn || skew <= -health.SkewWarn {
+			fmt.Println("If the DGX is synchronized, this machine's clock may be the one that is off.")
+		}
+	}
+	return nil
+}
+
+// ntpUnit returns the systemd unit of an NTP service
+func ntpUnit(service string) string {
+	if service == ntpTimesyncd {
+		return "systemd-timesyncd"
+	}
+	return service
+}
+
+// timeSyncSteps builds the steps that make service the only NTP client, point it at
+// servers (distribution defaults when empty), keep the RTC in UTC, and step the clock
+func timeSyncSteps(service string, servers []string) ([]Step, error) {
+	var clean []string
+	for _, s := range servers {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !ntpServerPattern.MatchString(s) {
+			return nil, fmt.Errorf("invalid NTP server %q", s)
+		}
+		clean = append(clean, s)
+	}
+
+	var steps []Step
+	switch service {
+	case ntpTimesyncd:
+		steps = append(steps, Step{
+			Name:        "service",
+			Description: "systemd-timesyncd as the only NTP client",
+			Command: `set -euo pipefail
+for u in chrony ntp ntpsec openntpd; do
+  if systemctl is-enabled --quiet "$u" 2>/dev/null || systemctl is-active --quiet "$u" 2>/dev/null; then
+    sudo systemctl disable --now "$u"
+    echo "Disabled $u"
+  fi
+done
+if ! systemctl cat systemd-timesyncd >/dev/null 2>&1; then
+  sudo apt-get update -qq
+  sudo env DEBIAN_FRONTEND=noninteractive apt-get install -y -qq systemd-timesyncd
+fi`,
+		})
+		if len(clean) > 0 {
+			steps = append(steps, Step{
+				Name:        "servers",
+				Description: "NTP servers: " + strings.Join(clean, ", "),
+				Command: fmt.Sprintf(`set -euo pipefail
+sudo mkdir -p /etc/systemd/timesyncd.conf.d
+printf '[Time]\nNTP=%s\n' | sudo tee /etc/systemd/timesyncd.conf.d/dgx.conf >/dev/null`, strings.Join(clean, " ")),
+			})
+		}
+		steps = append(steps, Step{
+			Name:        "enable",
+			Description: "Enable NTP and step the clock",
+			Command: `set -euo pipefail
+sudo timedatectl set-local-rtc 0
+sudo timedatectl set-ntp true
+sudo systemctl enable systemd-timesyncd >/dev/null 2>&1 || true
+sudo systemctl restart systemd-timesyncd`,
+		})
+	case ntpChrony:
+		steps = append(steps, Step{
+			Name:        "service",
+			Description: "chrony as the only NTP client",
+			Command: `set -euo pipefail
+if ! command -v chronyd >/dev/null 2>&1; then
+  sudo apt-get update -qq
+  sudo env DEBIAN_FRONTEND=noninteractive apt-get install -y -qq chrony
+fi
+for u in systemd-timesyncd ntp ntpsec openntpd; do
+  if systemctl is-active --quiet "$u" 2>/dev/null; then
+    sudo systemctl disable --now "$u"
+    echo "Disabled $u"
+  fi
+done`,
+		})
+		// makestep lets chrony jump a badly wrong clock at startup instead of slewing it for hours
+		conf := "makestep 1.0 3\n"
+		desc := "chrony step threshold"
+		if len(clean) > 0 {
+			for _, s := range clean {
+				conf += "server " + s + " iburst\n"
+			}
+			desc = "NTP servers: " + strings.Join(clean, ", ")
+		}
+		steps = append(steps,
+			Step{
+				Name:        "servers",
+				Description: desc,
+				Command: fmt.Sprintf(`set -euo pipefail
+sudo mkdir -p /etc/chrony/conf.d
+printf '%%s' %s | sudo tee /etc/chrony/conf.d/dgx.conf >/dev/null`, ssh.ShellQuote(conf)),
+			},
+			Step{
+				Name:        "enable",
+				Description: "Enable NTP and step the clock",
+				Command: `set -euo pipefail
+sudo timedatectl set-local-rtc 0
+sudo systemctl enable chrony >/dev/null 2>&1 || true
+sudo systemctl restart chrony
+sudo chronyc waitsync 10 0.5 >/dev/null 2>&1 || true
+sudo chronyc makestep >/dev/null`,
+			},
+		)
+	default:
+		return nil, fmt.Errorf("unknown NTP service %q: use %s or %s", service, ntpChrony, ntpTimesyncd)
+	}
+	return steps, nil
+}
@@ -0,0 +1,35 @@
+package playbook
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTimeSyncSteps(t *testing.T) {
+	steps, err := timeSyncSteps(ntpTimesyncd, nil)
+	if err != nil || len(steps) != 2 || steps[0].Name != "service" || steps[1].Name != "enable" {
+		t.Fatalf("timesyncd without servers: %+v, %v", steps, err)
+	}
+
+	steps, err = timeSyncSteps(ntpChrony, []string{"time.cloudflare.com", " 10.0.0.1 "})
+	if err != nil || len(steps) != 3 {
+		t.Fatalf("chrony with servers: %+v, %v", steps, err)
+	}
+	if !strings.Contains(steps[1].Command, "server time.cloudflare.com iburst\nserver 10.0.0.1 iburst\n") {
+		t.Fatalf("chrony servers not written: %s", steps[1].Command)
+	}
+
+	if _, err := timeSyncSteps(ntpTimesyncd, []string{"pool.ntp.org; reboot"}); err == nil {
+		t.Fatal("expected an invalid server to be rejected")
+	}
+	if _, err := timeSyncSteps("ntpd", nil); err == nil {
+		t.Fatal("expected an unknown service to be rejected")
+	}
+}
+
+func TestParseKeyValues(t *testing.T) {
+	got := parseKeyValues("service=chrony\nsynced=yes\ngarbage\nserver=\n")
+	if got["service"] != "chrony" || got["synced"] != "yes" || got["server"] != "" || len(got) != 3 {
+		t.Fatalf("parseKeyValues() = %v", got)
+	}
+}
@@ -0,0 +1,336 @@
+package playbook
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/ui"
+)
+
+// Files written by 'dgx run tune apply'
+const (
+	tuneSysctl  = "/etc/sysctl.d/90-dgx-tune.conf"
+	tuneTHPUnit = "/etc/systemd/system/dgx-thp.service"
+	tuneGrub    = "/etc/default/grub.d/99-dgx-tune.cfg"
+	// tuneBefore keeps the runtime values from before the first apply, so rollback can put
+	// back settings that no config file carried
+	tuneBefore = "/etc/dgx/tune-before.conf"
+)
+
+// tuneFiles are snapshotted before the first apply so 'dgx run tune rollback' can restore them
+var tuneFiles = []string{tuneSysctl, tuneTHPUnit, tuneGrub}
+
+// Kinds of tunable settings
+const (
+	tuneKindSysctl  = "sysctl"
+	tuneKindTHP     = "thp"
+	tuneKindCmdline = "cmdline"
+)
+
+// tuneSetting is one recommended kernel setting
+type tuneSetting struct {
+	Name        string
+	Kind        string
+	Recommended string
+	Why         string
+}
+
+// tuneSettings are the recommendations for inference on the Spark's single node of unified memory
+var tuneSettings = []tuneSetting{
+	{"vm.max_map_count", tuneKindSysctl, "1048576", "mmap-loaded models map many regions"},
+	{"vm.min_free_kbytes", tuneKindSysctl, "1048576", "headroom so GPU allocations avoid direct reclaim"},
+	{"vm.zone_reclaim_mode", tuneKindSysctl, "0", "one memory node; reclaim is never local"},
+	{"kernel.numa_balancing", tuneKindSysctl, "0", "one memory node; page scanning only costs faults"},
+	{"net.core.somaxconn", tuneKindSysctl, "4096", "inference servers with many clients"},
+	{"net.core.rmem_max", tuneKindSysctl, "67108864", "model downloads and NCCL over ConnectX"},
+	{"net.core.wmem_max", tuneKindSysctl, "67108864", "model downloads and NCCL over ConnectX"},
+	{"net.ipv4.tcp_rmem", tuneKindSysctl, "4096 131072 67108864", "model downloads and NCCL over ConnectX"},
+	{"net.ipv4.tcp_wmem", tuneKindSysctl, "4096 65536 67108864", "model downloads and NCCL over ConnectX"},
+	{"transparent_hugepage.enabled", tuneKindTHP, "madvise", "huge pages for allocators that ask (PyTorch, vLLM)"},
+	{"transparent_hugepage.defrag", tuneKindTHP, "defer+madvise", "compaction off the allocation path"},
+}
+
+// tuneIOMMU is only selected with --iommu-passthrough: it edits the GRUB command line, needs
+// a reboot, and drops the DMA isolation between devices
+var tuneIOMMU = tuneSetting{"iommu.passthrough", tuneKindCmdline, "1", "no DMA translation for the GPU and NICs (reboot)"}
+
+// tuneReload makes the restored files take effect after a rollback: runtime values that no
+// config file sets any more go back to what tuneBefore recorded
+const tuneReload = `sudo systemctl daemon-reload
+if [ -f /etc/dgx/tune-before.conf ]; then
+  sudo sysctl -q -p /etc/dgx/tune-before.conf >/dev/null 2>&1 || true
+  for k in enabled defrag; do
+    v=$(sed -n "s/^# thp.$k=//p" /etc/dgx/tune-before.conf)
+    if [ -n "$v" ]; then echo "$v" | sudo tee /sys/kernel/mm/transparent_hugepage/$k >/dev/null; fi
+  done
+  sudo rm -f /etc/dgx/tune-before.conf
+fi
+sudo sysctl -q --system >/dev/null 2>&1 || true
+if command -v update-grub >/dev/null 2>&1; then sudo update-grub >/dev/null 2>&1 || true; fi
+echo "Kernel command line changes are undone at the next reboot"
+`
+
+// runTune compares, applies, and rolls back kernel tuning
+func (m *Manager) runTune(args []string) error {
+	command := "diff"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "--") {
+		command, args = args[0], args[1:]
+	}
+
+	args, resume := removeFlag(args, "--resume")
+	args, hugepages := flagValue(args, "--hugepages")
+	args, skip := flagValue(args, "--skip")
+	args, iommu := removeFlag(args, "--iommu-passthrough")
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+	}
+
+	if command == "rollback" {
+		return m.rollback("tune")
+	}
+	settings, err := selectTuneSettings(hugepages, skip, iommu)
+	if err != nil {
+		return err
+	}
+	switch command {
+	case "diff":
+		_, err := m.tuneDiff(settings)
+		return err
+	case "apply":
+		return m.tuneApply(settings, resume)
+	default:
+		return fmt.Errorf("unknown tune command: %s. Usage: dgx run tune [diff|apply|rollback] [--hugepages N] [--iommu-passthrough] [--skip a,b] [--resume]", command)
+	}
+}
+
+// selectTuneSettings returns the recommendations minus skipped ones, plus IOMMU passthrough
+// and a reservation of hugepages 2 MiB pages when requested. Reserved pages are taken away
+// from the GPU, so none are recommended by default.
+func selectTuneSettings(hugepages, skip string, iommu bool) ([]tuneSetting, error) {
+	skipped := map[string]bool{}
+	for _, name := range strings.Split(skip, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if !tuneKnown(name) {
+			return nil, fmt.Errorf("unknown setting %q in --skip", name)
+		}
+		skipped[name] = true
+	}
+
+	var settings []tuneSetting
+	for _, s := range tuneSettings {
+		if !skipped[s.Name] {
+			settings = append(settings, s)
+		}
+	}
+	if iommu {
+		settings = append(settings, tuneIOMMU)
+	}
+	if hugepages != "" {
+		n, err := strconv.Atoi(hugepages)
+		// 128 GiB of 2 MiB pages; leave at least a quarter of memory unreserved
+		if err != nil || n < 0 || n > 49152 {
+			return nil, fmt.Errorf("invalid --hugepages %q: want 0-49152 2 MiB pages", hugepages)
+		}
+		settings = append(settings, tuneSetting{"vm.nr_hugepages", tuneKindSysctl, strconv.Itoa(n), "reserved 2 MiB pages"})
+	}
+	return settings, nil
+}
+
+func tuneKnown(name string) bool {
+	for _, s := range tuneSettings {
+		if s.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// tuneReadScript prints name=value for the current value of each setting
+func tuneReadScript(settings []tuneSetting) string {
+	var script strings.Builder
+	for _, s := range settings {
+		switch s.Kind {
+		case tuneKindSysctl:
+			fmt.Fprintf(&script, "echo \"%s=$(sysctl -n %s 2>/dev/null)\"\n", s.Name, s.Name)
+		case tuneKindTHP:
+			file := "/sys/kernel/mm/transparent_hugepage/" + strings.TrimPrefix(s.Name, "transparent_hugepage.")
+			fmt.Fprintf(&script, "echo \"%s=$(sed 's/.*\\[\\(.*\\)\\].*/\\1/' %s 2>/dev/null)\"\n", s.Name, file)
+		case tuneKindCmdline:
+			fmt.Fprintf(&script, "echo \"%s=$(tr ' ' '\\n' </proc/cmdline | sed -n 's/^%s=//p' | tail -1)\"\n", s.Name, s.Name)
+		}
+	}
+	return script.String()
+}
+
+// tuneChange is a setting whose current value differs from the recommendation
+type tuneChange struct {
+	tuneSetting
+	Current string
+}
+
+// tuneCompare pairs each setting with its current value and returns those that differ.
+// Multi-value sysctls are compared field by field, since sysctl -n separates them with tabs.
+func tuneCompare(settings []tuneSetting, current map[string]string) []tuneChange {
+	var changes []tuneChange
+	for _, s := range settings {
+		have := strings.Join(strings.Fields(current[s.Name]), " ")
+		if have != s.Recommended {
+			changes = append(changes, tuneChange{tuneSetting: s, Current: have})
+		}
+	}
+	return changes
+}
+
+// tuneDiff prints current against recommended values and returns the differences
+func (m *Manager) tuneDiff(settings []tuneSetting) ([]tuneChange, error) {
+	output, err := m.sshClient.Execute(tuneReadScript(settings))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kernel settings: %w", err)
+	}
+	current := parseKeyValues(output)
+	changes := tuneCompare(settings, current)
+	differs := map[string]bool{}
+	for _, c := range changes {
+		differs[c.Name] = true
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\tSETTING\tCURRENT\tRECOMMENDED\tWHY")
+	for _, s := range settings {
+		mark := " "
+		if differs[s.Name] {
+			mark = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", mark, s.Name, ui.OrDash(strings.Join(strings.Fields(current[s.Name]), " ")), s.Recommended, s.Why)
+	}
+	w.Flush()
+
+	if len(changes) == 0 {
+		fmt.Println("\nAll settings match the recommendations.")
+	} else {
+		fmt.Printf("\n%d setting(s) differ (*). Apply with: dgx run tune apply\n", len(changes))
+	}
+	return changes, nil
+}
+
+// tuneApplySteps persists the settings: sysctls in tuneSysctl, transparent hugepages in a
+// boot-time unit, and the kernel command line through a GRUB drop-in
+func tuneApplySteps(settings []tuneSetting, changes []tuneChange) []Step {
+	var sysctl, before strings.Builder
+	sysctl.WriteString("# Written by dgx run tune; undo with dgx run tune rollback\n")
+	var thp []string
+	var cmdline []string
+	for _, s := range settings {
+		switch s.Kind {
+		case tuneKindSysctl:
+			fmt.Fprintf(&sysctl, "%s = %s\n", s.Name, s.Recommended)
+		case tuneKindTHP:
+			file := "/sys/kernel/mm/transparent_hugepage/" + strings.TrimPrefix(s.Name, "transparent_hugepage.")
+			thp = append(thp, fmt.Sprintf("echo %s > %s", s.Recommended, file))
+		case tuneKindCmdline:
+			cmdline = append(cmdline, s.Name+"="+s.Recommended)
+		}
+	}
+	for _, c := range changes {
+		if c.Current == "" {
+			continue
+		}
+		switch c.Kind {
+		case tuneKindSysctl:
+			fmt.Fprintf(&before, "%s = %s\n", c.Name, c.Current)
+		case tuneKindTHP:
+			fmt.Fprintf(&before, "# thp.%s=%s\n", strings.TrimPrefix(c.Name, "transparent_hugepage."), c.Current)
+		}
+	}
+
+	steps := []Step{{
+		Name:        "record",
+		Description: "Record current values for rollback",
+		Command: fmt.Sprintf(`set -euo pipefail
+if [ ! -f %[1]s ]; then
+  sudo mkdir -p "$(dirname %[1]s)"
+  printf '%%s' %[2]s | sudo tee %[1]s >/dev/null
+fi`, tuneBefore, ssh.ShellQuote(before.String())),
+	}}
+
+	steps = append(steps, Step{
+		Name:        "sysctl",
+		Description: "sysctl settings in " + tuneSysctl,
+		Command: fmt.Sprintf(`set -euo pipefail
+printf '%%s' %s | sudo tee %s >/dev/null
+sudo sysctl -q -p %s`, ssh.ShellQuote(sysctl.String()), tuneSysctl, tuneSysctl),
+	})
+
+	if len(thp) > 0 {
+		unit := fmt.Sprintf(`[Unit]
+Description=Transparent hugepage settings from dgx run tune
+After=sysinit.target local-fs.target
+
+[Service]
+Type=oneshot
+ExecStart=/bin/sh -c '%s'
+
+[Install]
+WantedBy=basic.target
+`, strings.Join(thp, "; "))
+		steps = append(steps, Step{
+			Name:        "thp",
+			Description: "Transparent hugepages at boot (dgx-thp.service)",
+			Command: fmt.Sprintf(`set -euo pipefail
+printf '%%s' %s | sudo tee %s >/dev/null
+sudo systemctl daemon-reload
+sudo systemctl enable --now dgx-thp.service >/dev/null 2>&1
+sudo systemctl restart dgx-thp.service`, ssh.ShellQuote(unit), tuneTHPUnit),
+		})
+	}
+
+	if len(cmdline) > 0 {
+		args := strings.Join(cmdline, " ")
+		cfg := fmt.Sprintf("GRUB_CMDLINE_LINUX_DEFAULT=\"$GRUB_CMDLINE_LINUX_DEFAULT %s\"\n", args)
+		steps = append(steps, Step{
+			Name:        "cmdline",
+			Description: "Kernel command line: " + args,
+			Command: fmt.Sprintf(`set -euo pipefail
+if ! command -v update-grub >/dev/null 2>&1; then
+  echo "update-grub not found; add '%[1]s' to the kernel command line by hand" >&2
+  exit 1
+fi
+sudo mkdir -p /etc/default/grub.d
+printf '%%s' %[2]s | sudo tee %[3]s >/dev/null
+sudo update-grub >/dev/null 2>&1`, args, ssh.ShellQuote(cfg), tuneGrub),
+		})
+	}
+	return steps
+}
+
+func (m *Manager) tuneApply(settings []tuneSetting, resume bool) error {
+	changes, err := m.tuneDiff(settings)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 && !resume {
+		return nil
+	}
+	fmt.Println()
+
+	if err := m.ensureSnapshot("tune", tuneFiles); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v (rollback will not be available)\n", err)
+	}
+	if err := m.runSteps("tune apply", tuneApplySteps(settings, changes), resume); err != nil {
+		return fmt.Errorf("failed to apply kernel tuning: %w", err)
+	}
+
+	for _, c := range changes {
+		if c.Kind == tuneKindCmdline {
+			fmt.Println("\nReboot the DGX for the kernel command line to take effect: dgx reboot --wait")
+			break
+		}
+	}
+	return nil
+}
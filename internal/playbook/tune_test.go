@@ -0,0 +1,60 @@
+package playbook
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelectTuneSettings(t *testing.T) {
+	settings, err := selectTuneSettings("512", "net.core.somaxconn", false)
+	if err != nil {
+		t.Fatalf("selectTuneSettings: %v", err)
+	}
+	if len(settings) != len(tuneSettings) || settings[len(settings)-1].Name != "vm.nr_hugepages" {
+		t.Fatalf("unexpected settings: %+v", settings)
+	}
+	for _, s := range settings {
+		if s.Name == "net.core.somaxconn" {
+			t.Fatalf("skipped setting still selected")
+		}
+		// The GRUB command line is only touched when asked for
+		if s.Name == tuneIOMMU.Name {
+			t.Fatalf("iommu.passthrough selected without --iommu-passthrough")
+		}
+	}
+	settings, err = selectTuneSettings("", "", true)
+	if err != nil || settings[len(settings)-1] != tuneIOMMU {
+		t.Fatalf("--iommu-passthrough not selected: %+v, %v", settings, err)
+	}
+
+	if _, err := selectTuneSettings("", "vm.bogus", false); err == nil {
+		t.Fatalf("unknown --skip setting accepted")
+	}
+	if _, err := selectTuneSettings("lots", "", false); err == nil {
+		t.Fatalf("invalid --hugepages accepted")
+	}
+}
+
+func TestTuneCompare(t *testing.T) {
+	settings := []tuneSetting{
+		{"net.ipv4.tcp_rmem", tuneKindSysctl, "4096 131072 67108864", ""},
+		{"vm.max_map_count", tuneKindSysctl, "1048576", ""},
+		{"transparent_hugepage.enabled", tuneKindTHP, "madvise", ""},
+	}
+	current := parseKeyValues("net.ipv4.tcp_rmem=4096\t131072\t67108864\nvm.max_map_count=65530\ntransparent_hugepage.enabled=always\n")
+	changes := tuneCompare(settings, current)
+	if len(changes) != 2 || changes[0].Name != "vm.max_map_count" || changes[0].Current != "65530" || changes[1].Current != "always" {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+
+	steps := tuneApplySteps(settings, changes)
+	if len(steps) != 3 || steps[0].Name != "record" || steps[1].Name != "sysctl" || steps[2].Name != "thp" {
+		t.Fatalf("unexpected steps: %+v", steps)
+	}
+	if !strings.Contains(steps[0].Command, "vm.max_map_count = 65530") || !strings.Contains(steps[0].Command, "# thp.enabled=always") {
+		t.Fatalf("previous values not recorded:\n%s", steps[0].Command)
+	}
+	if !strings.Contains(steps[1].Command, "net.ipv4.tcp_rmem = 4096 131072 67108864") {
+		t.Fatalf("unexpected sysctl step:\n%s", steps[1].Command)
+	}
+}
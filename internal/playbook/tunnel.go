@@ -0,0 +1,67 @@
+package playbook
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/weatherman/dgx-manager/internal/tunnel"
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// SetTunnels lets playbooks open SSH tunnels to the services they start
+func (m *Manager) SetTunnels(tm *tunnel.Manager) {
+	m.tunnels = tm
+}
+
+// openTunnel forwards a local port to a service listening on the DGX's loopback, reusing a
+// tunnel that already does, and prints the URL to open. With skip, or when tunnels are not
+// available, it only prints how to open one.
+func (m *Manager) openTunnel(localPort, remotePort int, description string, skip bool) {
+	if skip || m.tunnels == nil {
+		fmt.Printf("Open it in a browser with: dgx tunnel create %d:%d \"%s\"\n", localPort, remotePort, description)
+		return
+	}
+	if tunnels, err := m.tunnels.List(); err == nil {
+		for _, t := range tunnels {
+			if t.RemotePort == remotePort {
+				fmt.Printf("Open http://localhost:%d (existing tunnel)\n", t.LocalPort)
+				return
+			}
+		}
+	}
+	local := localPort
+	if m.tunnels.IsPortInUse(local) {
+		local = m.tunnels.FindAvailablePort(local + 1)
+	}
+	err := m.tunnels.Create(types.Tunnel{LocalPort: local, RemotePort: remotePort, RemoteHost: "localhost", Description: description})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		fmt.Printf("Open it in a browser with: dgx tunnel create %d:%d \"%s\"\n", local, remotePort, description)
+		return
+	}
+	fmt.Printf("Open http://localhost:%d\n", local)
+}
+
+// parsePorts parses --port and --local-port values. The remote port defaults to def and the
+// local port to the remote one.
+func parsePorts(port, localPort string, def int) (int, int, error) {
+	remote, local := def, 0
+	for _, p := range []struct {
+		value string
+		dst   *int
+	}{{port, &remote}, {localPort, &local}} {
+		if p.value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(p.value)
+		if err != nil || n < 1 || n > 65535 {
+			return 0, 0, fmt.Errorf("invalid port %q", p.value)
+		}
+		*p.dst = n
+	}
+	if local == 0 {
+		local = remote
+	}
+	return remote, local, nil
+}
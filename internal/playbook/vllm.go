@@ -37,12 +37,12 @@ func (m *Manager) vllmPull() error {
 	fmt.Println("Pulling vLLM container...")
 	fmt.Println("Image: nvcr.io/nvidia/vllm:25.09-py3")
 
-	output, err := m.sshClient.Execute("docker pull nvcr.io/nvidia/vllm:25.09-py3")
+	output, err := m.execStep("docker pull nvcr.io/nvidia/vllm:25.09-py3")
 	if err != nil {
 		return fmt.Errorf("failed to pull container: %w", err)
 	}
 
-	fmt.Println(output)
+	printOutput(output)
 	fmt.Println("\nvLLM container pulled successfully!")
 	return nil
 }
@@ -94,7 +94,8 @@ func (m *Manager) vllmStatus() error {
 		return nil
 	}
 
-	fmt.Printf("vLLM server is running\n%s\n", output)
+	fmt.Println("vLLM server is running")
+	printOutput(output)
 
 	// Try to get health status
 	healthCmd := "docker exec vllm-server curl -s http://localhost:8000/health || echo 'Not accessible'"
@@ -115,7 +116,7 @@ func (m *Manager) vllmStop() error {
 		return fmt.Errorf("failed to stop server: %w", err)
 	}
 
-	fmt.Println(output)
+	printOutput(output)
 	fmt.Println("vLLM server stopped and removed")
 	return nil
 }
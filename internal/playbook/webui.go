@@ -0,0 +1,314 @@
+package playbook
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+// Open WebUI deployment. The container shares the host network so it can reach model
+// servers that only listen on the DGX's loopback; the data volume keeps accounts, chats,
+// and settings across upgrades.
+const (
+	webUIContainer  = "open-webui"
+	webUIImage      = "ghcr.io/open-webui/open-webui:main"
+	webUIVolume     = "open-webui"
+	webUIPort       = 3000
+	webUISecretFile = "$HOME/.config/dgx/open-webui.secret"
+)
+
+// webUIBackend is a model server on the DGX that Open WebUI can be pointed at
+type webUIBackend struct {
+	Name   string
+	URL    string // OpenAI-compatible base URL, or Ollama's root for ollama
+	Health string // path of a GET that answers when the server is up
+}
+
+var webUIBackends = []webUIBackend{
+	{Name: "dmr", URL: "http://127.0.0.1:12434/engines/v1", Health: "/models"},
+	{Name: "vllm", URL: "http://127.0.0.1:8000/v1", Health: "/models"},
+	{Name: "ollama", URL: "http://127.0.0.1:11434", Health: "/api/tags"},
+}
+
+// webUIOptions are the flags of 'dgx run webui install'
+type webUIOptions struct {
+	backends  []string
+	urls      []string
+	port      int
+	localPort int
+	image     string
+	lan       bool
+	noTunnel  bool
+}
+
+// runWebUI handles the Open WebUI deployment
+func (m *Manager) runWebUI(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("webui command required. Usage: dgx run webui <install|status|update|uninstall>")
+	}
+	command, rest := args[0], args[1:]
+
+	switch command {
+	case "install":
+		opts, err := parseWebUIOptions(rest)
+		if err != nil {
+			return err
+		}
+		return m.webUIInstall(opts, false)
+	case "update":
+		opts, err := parseWebUIOptions(rest)
+		if err != nil {
+			return err
+		}
+		return m.trackChanges("webui update", func() error { return m.webUIInstall(opts, true) })
+	case "status":
+		return m.webUIStatus()
+	case "uninstall":
+		rest, yes := removeFlag(rest, "--yes")
+		rest, purge := removeFlag(rest, "--purge")
+		if len(rest) > 0 {
+			return fmt.Errorf("unexpected arguments: %s", strings.Join(rest, " "))
+		}
+		return m.webUIUninstall(purge, yes)
+	default:
+		return fmt.Errorf("unknown webui command: %s", command)
+	}
+}
+
+func parseWebUIOptions(args []string) (webUIOptions, error) {
+	var opts webUIOptions
+	var backends, urls, port, localPort string
+	args, opts.lan = removeFlag(args, "--lan")
+	args, opts.noTunnel = removeFlag(args, "--no-tunnel")
+	args, backends = flagValue(args, "--backend")
+	args, urls = flagValue(args, "--url")
+	args, port = flagValue(args, "--port")
+	args, localPort = flagValue(args, "--local-port")
+	args, opts.image = flagValue(args, "--image")
+	if len(args) > 0 {
+		return opts, fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+	}
+
+	var err error
+	if opts.port, opts.localPort, err = parsePorts(port, localPort, webUIPort); err != nil {
+		return opts, err
+	}
+	if opts.image == "" {
+		opts.image = webUIImage
+	}
+	for _, b := range splitList(backends) {
+		if findWebUIBackend(b) == nil {
+			return opts, fmt.Errorf("unknown --backend %q (want dmr, vllm, or ollama)", b)
+		}
+		opts.backends = append(opts.backends, b)
+	}
+	for _, u := range splitList(urls) {
+		parsed, err := url.Parse(u)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return opts, fmt.Errorf("invalid --url %q (want an OpenAI-compatible base URL such as http://127.0.0.1:8080/v1)", u)
+		}
+		opts.urls = append(opts.urls, strings.TrimSuffix(u, "/"))
+	}
+	return opts, nil
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func findWebUIBackend(name string) *webUIBackend {
+	for i := range webUIBackends {
+		if webUIBackends[i].Name == name {
+			return &webUIBackends[i]
+		}
+	}
+	return nil
+}
+
+// webUIEnv returns the Open WebUI settings for the chosen backends and extra
+// OpenAI-compatible URLs. The servers need no keys, but Open WebUI wants one per URL.
+func webUIEnv(backends, urls []string) map[string]string {
+	env := map[string]string{"ENABLE_OLLAMA_API": "false", "ENABLE_OPENAI_API": "false"}
+	var openAI []string
+	for _, name := range backends {
+		b := findWebUIBackend(name)
+		if b.Name == "ollama" {
+			env["ENABLE_OLLAMA_API"] = "true"
+			env["OLLAMA_BASE_URL"] = b.URL
+			continue
+		}
+		openAI = append(openAI, b.URL)
+	}
+	openAI = append(openAI, urls...)
+	if len(openAI) > 0 {
+		keys := make([]string, len(openAI))
+		for i := range keys {
+			keys[i] = "none"
+		}
+		env["ENABLE_OPENAI_API"] = "true"
+		env["OPENAI_API_BASE_URLS"] = strings.Join(openAI, ";")
+		env["OPENAI_API_KEYS"] = strings.Join(keys, ";")
+	}
+	return env
+}
+
+// detectWebUIBackends returns the model servers answering on the DGX
+func (m *Manager) detectWebUIBackends() []string {
+	var probes []string
+	for _, b := range webUIBackends {
+		probes = append(probes, fmt.Sprintf("curl -sf -m 3 -o /dev/null %s && echo %s", ssh.ShellQuote(b.URL+b.Health), b.Name))
+	}
+	output, _ := m.sshClient.Execute(strings.Join(probes, "; ") + "; true")
+	return strings.Fields(output)
+}
+
+func (m *Manager) webUIInstall(opts webUIOptions, update bool) error {
+	total := 4
+	step := func(n int, msg string) { fmt.Printf("[%d/%d] %s\n", n, total, msg) }
+
+	backends := opts.backends
+	if len(backends) == 0 && len(opts.urls) == 0 {
+		step(1, "Looking for model servers on the DGX...")
+		backends = m.detectWebUIBackends()
+		if len(backends) == 0 {
+			fmt.Println("  No DMR, vLLM, or Ollama server is answering; connecting to DMR anyway (start one with 'dgx run dmr setup').")
+			backends = []string{"dmr"}
+		} else {
+			fmt.Printf("  Found: %s\n", strings.Join(backends, ", "))
+		}
+	} else {
+		step(1, "Using the model servers given on the command line")
+	}
+
+	step(2, fmt.Sprintf("Pulling %s...", opts.image))
+	output, err := m.execStep("docker pull " + ssh.ShellQuote(opts.image))
+	if err != nil {
+		printOutput(output)
+		return fmt.Errorf("failed to pull %s: %w", opts.image, err)
+	}
+
+	host := "127.0.0.1"
+	if opts.lan {
+		host = "0.0.0.0"
+	}
+	env := webUIEnv(backends, opts.urls)
+	env["HOST"] = host
+	env["PORT"] = fmt.Sprint(opts.port)
+	var envArgs []string
+	for key, value := range env {
+		envArgs = append(envArgs, "-e "+ssh.ShellQuote(key+"="+value))
+	}
+	sort.Strings(envArgs)
+
+	verb := "Starting"
+	if update {
+		verb = "Recreating"
+	}
+	step(3, fmt.Sprintf("%s Open WebUI on port %d (chats are kept in the %s volume)...", verb, opts.port, webUIVolume))
+	// The secret signs login sessions; keeping it on the DGX keeps users logged in when the
+	// container is recreated
+	run := fmt.Sprintf(`mkdir -p "$(dirname "%[1]s")" && { [ -s "%[1]s" ] || (umask 077; head -c 32 /dev/urandom | base64 > "%[1]s"); } \
+		&& { docker rm -f %[2]s >/dev/null 2>&1; true; } && docker run -d \
+		--name %[2]s \
+		--restart unless-stopped \
+		--network host \
+		-v %[3]s:/app/backend/data \
+		-e WEBUI_SECRET_KEY="$(cat "%[1]s")" \
+		%[4]s \
+		%[5]s`,
+		webUISecretFile, webUIContainer, webUIVolume, strings.Join(envArgs, " "), ssh.ShellQuote(opts.image))
+	if output, err := m.sshClient.Execute(run); err != nil {
+		printOutput(output)
+		return fmt.Errorf("failed to start Open WebUI: %w", err)
+	}
+
+	step(4, "Waiting for Open WebUI to answer (the first start downloads its embedding model)...")
+	wait := fmt.Sprintf(`i=0; until curl -sf -o /dev/null http://127.0.0.1:%d/health; do
+  i=$((i+5)); [ $i -ge 600 ] && exit 1
+  docker inspect -f '{{.State.Running}}' %s 2>/dev/null | grep -q true || exit 2
+  sleep 5
+done`, opts.port, webUIContainer)
+	if _, err := m.sshClient.Execute(wait); err != nil {
+		return fmt.Errorf("Open WebUI did not come up; check 'dgx exec docker logs %s': %w", webUIContainer, err)
+	}
+
+	fmt.Printf("\nOpen WebUI is running, connected to %s.\n", strings.Join(append(backends, opts.urls...), ", "))
+	if opts.lan {
+		fmt.Printf("Others on your network can open http://%s:%d\n", m.sshClient.Host(), opts.port)
+	}
+	m.openTunnel(opts.localPort, opts.port, "Open WebUI", opts.noTunnel)
+	if !update {
+		fmt.Println("\nThe first account to sign up becomes the administrator; later sign-ups need its approval.")
+	}
+	return nil
+}
+
+func (m *Manager) webUIStatus() error {
+	output, err := m.sshClient.Execute(fmt.Sprintf("docker ps -a --filter 'name=^%s$' --format '{{.Image}}\t{{.Status}}'", webUIContainer))
+	if err != nil {
+		return fmt.Errorf("failed to check status: %w", err)
+	}
+	image, status, _ := strings.Cut(strings.TrimSpace(output), "\t")
+	if image == "" {
+		fmt.Println("Open WebUI is not installed.")
+		fmt.Println("\nTo install it:")
+		fmt.Println("  dgx run webui install")
+		return nil
+	}
+	fmt.Printf("Image:      %s\n", image)
+	fmt.Printf("Container:  %s\n", status)
+
+	env, _ := m.sshClient.Execute(fmt.Sprintf(`docker inspect -f '{{range .Config.Env}}{{println .}}{{end}}' %s`, webUIContainer))
+	settings := parseKeyValues(env)
+	if port := settings["PORT"]; port != "" {
+		ready := "no"
+		if _, err := m.sshClient.Execute(fmt.Sprintf("curl -sf -o /dev/null http://127.0.0.1:%s/health", port)); err == nil {
+			ready = "yes"
+		}
+		fmt.Printf("Listening:  %s:%s (answering: %s)\n", settings["HOST"], port, ready)
+	}
+	var backends []string
+	if urls := settings["OPENAI_API_BASE_URLS"]; settings["ENABLE_OPENAI_API"] == "true" && urls != "" {
+		backends = append(backends, strings.Split(urls, ";")...)
+	}
+	if settings["ENABLE_OLLAMA_API"] == "true" {
+		backends = append(backends, settings["OLLAMA_BASE_URL"]+" (ollama)")
+	}
+	if len(backends) > 0 {
+		fmt.Printf("Backends:   %s\n", strings.Join(backends, ", "))
+	}
+	return nil
+}
+
+func (m *Manager) webUIUninstall(purge, yes bool) error {
+	prompt := "Remove the Open WebUI container? Accounts and chats are kept in the " + webUIVolume + " volume."
+	if purge {
+		prompt = "Remove Open WebUI and delete every account, chat, and setting in the " + webUIVolume + " volume?"
+	}
+	if err := m.confirmDestructive(prompt, yes); err != nil {
+		return err
+	}
+	cmd := "docker rm -f " + webUIContainer
+	if purge {
+		cmd += fmt.Sprintf(` && docker volume rm -f %s && rm -f "%s"`, webUIVolume, webUISecretFile)
+	}
+	if output, err := m.execStep(cmd); err != nil {
+		printOutput(output)
+		return fmt.Errorf("failed to remove Open WebUI: %w", err)
+	}
+	if purge {
+		fmt.Println("Open WebUI and its data removed.")
+	} else {
+		fmt.Printf("Open WebUI removed; reinstalling picks up the chats in the %s volume.\n", webUIVolume)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package playbook
+
+import "testing"
+
+func TestWebUIEnv(t *testing.T) {
+	env := webUIEnv([]string{"dmr", "ollama"}, []string{"http://10.0.0.5:8000/v1"})
+	if env["OPENAI_API_BASE_URLS"] != "http://127.0.0.1:12434/engines/v1;http://10.0.0.5:8000/v1" || env["OPENAI_API_KEYS"] != "none;none" {
+		t.Fatalf("OpenAI settings = %v", env)
+	}
+	if env["ENABLE_OLLAMA_API"] != "true" || env["OLLAMA_BASE_URL"] != "http://127.0.0.1:11434" {
+		t.Fatalf("Ollama settings = %v", env)
+	}
+
+	env = webUIEnv([]string{"ollama"}, nil)
+	if env["ENABLE_OPENAI_API"] != "false" || env["OPENAI_API_BASE_URLS"] != "" {
+		t.Fatalf("OpenAI API should be off without OpenAI backends: %v", env)
+	}
+}
+
+func TestParseWebUIOptions(t *testing.T) {
+	opts, err := parseWebUIOptions([]string{"--backend", "vllm, dmr", "--port", "3100", "--lan"})
+	if err != nil {
+		t.Fatalf("parseWebUIOptions: %v", err)
+	}
+	if len(opts.backends) != 2 || opts.backends[0] != "vllm" || opts.port != 3100 || opts.localPort != 3100 || !opts.lan || opts.image != webUIImage {
+		t.Fatalf("options = %+v", opts)
+	}
+	for _, args := range [][]string{
+		{"--backend", "llamacpp"},
+		{"--url", "127.0.0.1:8000"},
+		{"--port", "0"},
+		{"extra"},
+	} {
+		if _, err := parseWebUIOptions(args); err == nil {
+			t.Fatalf("parseWebUIOptions(%v): expected an error", args)
+		}
+	}
+}
@@ -0,0 +1,480 @@
+package playbook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/artifacts"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+// Whisper defaults. The image is built on the DGX on first use because CTranslate2 has no
+// CUDA wheels for aarch64.
+const (
+	whisperImage        = "dgx-whisper:latest"
+	whisperDefaultModel = "large-v3"
+	whisperCacheDir     = "$HOME/.cache/dgx/whisper"
+	whisperJobsDir      = "$HOME/.cache/dgx/whisper-jobs"
+)
+
+// whisperFormats are the transcript formats 'dgx run whisper' writes
+var whisperFormats = []string{"txt", "srt", "vtt", "json"}
+
+var (
+	whisperModelPattern    = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]*$`)
+	whisperLanguagePattern = regexp.MustCompile(`^[a-z]{2,3}$`)
+	audioExtPattern        = regexp.MustCompile(`^\.[a-z0-9]{1,5}$`)
+)
+
+// whisperDockerfile builds CTranslate2 with CUDA for the GB10 and installs faster-whisper
+// on top of it
+const whisperDockerfile = `FROM nvcr.io/nvidia/pytorch:25.09-py3
+RUN git clone --depth 1 --branch v4.6.0 --recursive https://github.com/OpenNMT/CTranslate2.git /tmp/ct2 \
+ && cmake -S /tmp/ct2 -B /tmp/ct2/build -DCMAKE_BUILD_TYPE=Release -DWITH_CUDA=ON -DWITH_CUDNN=ON \
+      -DWITH_MKL=OFF -DWITH_DNNL=OFF -DOPENMP_RUNTIME=COMP -DCUDA_ARCH_LIST=12.1 \
+ && cmake --build /tmp/ct2/build -j"$(nproc)" && cmake --install /tmp/ct2/build && ldconfig \
+ && pip install --no-cache-dir /tmp/ct2/python && rm -rf /tmp/ct2
+RUN pip install --no-cache-dir faster-whisper
+`
+
+// whisperScript transcribes $INPUT inside the container. Progress lines start with
+// "dgx-whisper" on stderr; the transcript is printed to stdout as JSON.
+const whisperScript = `import json, os, sys
+import ctranslate2
+from faster_whisper import WhisperModel
+
+def say(*parts):
+    print("dgx-whisper", *parts, file=sys.stderr, flush=True)
+
+device, compute = "cuda", "float16"
+if ctranslate2.get_cuda_device_count() == 0:
+    device, compute = "cpu", "int8"
+say("device", device)
+
+model = WhisperModel(os.environ["MODEL"], device=device, compute_type=compute)
+segments, info = model.transcribe(os.environ["INPUT"], language=os.environ.get("LANGUAGE") or None,
+                                  task=os.environ.get("TASK") or "transcribe", vad_filter=True)
+say("info", info.language, "%.1f" % info.duration)
+
+out = []
+for s in segments:
+    text = " ".join(s.text.split())
+    out.append({"start": round(s.start, 3), "end": round(s.end, 3), "text": text})
+    say("segment", "%.1f" % s.end, text)
+json.dump({"language": info.language, "duration": round(info.duration, 3), "segments": out}, sys.stdout)
+`
+
+// whisperCacheScript prints "<bytes> <dir>" for each model in the Hugging Face cache
+const whisperCacheScript = `for d in "` + whisperCacheDir + `"/hub/models--*; do
+  [ -d "$d" ] && printf '%s %s\n' "$(du -sb "$d" | cut -f1)" "${d##*/}"
+done; true`
+
+// Transcript is what the transcription script returns
+type Transcript struct {
+	Language string              `json:"language"`
+	Duration float64             `json:"duration"`
+	Segments []TranscriptSegment `json:"segments"`
+}
+
+// TranscriptSegment is one timed piece of a transcript, in seconds from the start
+type TranscriptSegment struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+// Render formats the transcript as txt, srt, vtt, or json
+func (t Transcript) Render(format string) ([]byte, error) {
+	var b bytes.Buffer
+	switch format {
+	case "txt":
+		for _, s := range t.Segments {
+			b.WriteString(s.Text + "\n")
+		}
+	case "srt":
+		for i, s := range t.Segments {
+			fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n", i+1, subtitleTime(s.Start, ","), subtitleTime(s.End, ","), s.Text)
+		}
+	case "vtt":
+		b.WriteString("WEBVTT\n\n")
+		for _, s := range t.Segments {
+			fmt.Fprintf(&b, "%s --> %s\n%s\n\n", subtitleTime(s.Start, "."), subtitleTime(s.End, "."), s.Text)
+		}
+	case "json":
+		data, err := json.MarshalIndent(t, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		b.Write(append(data, '\n'))
+	default:
+		return nil, fmt.Errorf("unknown format %q (want %s)", format, strings.Join(whisperFormats, ", "))
+	}
+	return b.Bytes(), nil
+}
+
+// subtitleTime formats seconds as HH:MM:SS followed by sep and milliseconds
+func subtitleTime(seconds float64, sep string) string {
+	ms := int64(math.Round(seconds * 1000))
+	if ms < 0 {
+		ms = 0
+	}
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
+
+// whisperEvent is a progress line from the transcription script. Seconds is the audio
+// duration for "info" and the end of the segment for "segment".
+type whisperEvent struct {
+	Kind    string
+	Value   string
+	Seconds float64
+	Text    string
+}
+
+// parseWhisperEvent parses a "dgx-whisper" progress line; other output is not an event
+func parseWhisperEvent(line string) (whisperEvent, bool) {
+	rest, ok := strings.CutPrefix(line, "dgx-whisper ")
+	if !ok {
+		return whisperEvent{}, false
+	}
+	kind, rest, _ := strings.Cut(rest, " ")
+	switch kind {
+	case "device":
+		return whisperEvent{Kind: kind, Value: rest}, true
+	case "info":
+		language, duration, _ := strings.Cut(rest, " ")
+		seconds, err := strconv.ParseFloat(duration, 64)
+		if err != nil {
+			return whisperEvent{}, false
+		}
+		return whisperEvent{Kind: kind, Value: language, Seconds: seconds}, true
+	case "segment":
+		end, text, _ := strings.Cut(rest, " ")
+		seconds, err := strconv.ParseFloat(end, 64)
+		if err != nil {
+			return whisperEvent{}, false
+		}
+		return whisperEvent{Kind: kind, Seconds: seconds, Text: text}, true
+	}
+	return whisperEvent{}, false
+}
+
+// whisperOptions are the flags of 'dgx run whisper <audio-file>'
+type whisperOptions struct {
+	model, language, format, output, image string
+	translate                              bool
+}
+
+// runWhisper handles audio transcription and the model cache
+func (m *Manager) runWhisper(args []string) error {
+	const usage = "Usage: dgx run whisper <audio-file> [--model NAME] [--language CODE] [--format txt|srt|vtt|json] [-o FILE] | setup | cache [clear [model]]"
+	if len(args) == 0 {
+		return fmt.Errorf("audio file required. %s", usage)
+	}
+
+	switch args[0] {
+	case "setup":
+		args, image := flagValue(args[1:], "--image")
+		if len(args) > 0 {
+			return fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+		}
+		return m.whisperSetup(image, true)
+	case "cache":
+		rest := args[1:]
+		if len(rest) == 0 || rest[0] == "list" {
+			return m.whisperCacheList()
+		}
+		if rest[0] != "clear" {
+			return fmt.Errorf("unknown whisper cache command: %s. Usage: dgx run whisper cache [list|clear [model] [--yes]]", rest[0])
+		}
+		rest, yes := removeFlag(rest[1:], "--yes")
+		if len(rest) > 1 {
+			return fmt.Errorf("unexpected arguments: %s", strings.Join(rest[1:], " "))
+		}
+		model := ""
+		if len(rest) == 1 {
+			model = rest[0]
+		}
+		return m.whisperCacheClear(model, yes)
+	}
+
+	rest, translate := removeFlag(args, "--translate")
+	rest, opts := whisperFlags(rest)
+	opts.translate = translate
+	if len(rest) != 1 {
+		return fmt.Errorf("one audio file required. %s", usage)
+	}
+	file := rest[0]
+	if opts.model == "" {
+		opts.model = whisperDefaultModel
+	}
+	if opts.format == "" {
+		opts.format = "txt"
+	}
+	if !slices.Contains(whisperFormats, opts.format) {
+		return fmt.Errorf("unknown format %q (want %s)", opts.format, strings.Join(whisperFormats, ", "))
+	}
+	if !whisperModelPattern.MatchString(opts.model) {
+		return fmt.Errorf("invalid model name %q", opts.model)
+	}
+	if opts.language != "" && !whisperLanguagePattern.MatchString(opts.language) {
+		return fmt.Errorf("invalid language %q (want a code such as en or de)", opts.language)
+	}
+	if opts.output == "" {
+		opts.output = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)) + "." + opts.format
+	}
+	return m.whisperTranscribe(file, opts)
+}
+
+func whisperFlags(args []string) ([]string, whisperOptions) {
+	var opts whisperOptions
+	args, opts.model = flagValue(args, "--model")
+	args, opts.language = flagValue(args, "--language")
+	args, opts.format = flagValue(args, "--format")
+	args, opts.image = flagValue(args, "--image")
+	args, opts.output = flagValue(args, "--output")
+	if opts.output == "" {
+		args, opts.output = flagValue(args, "-o")
+	}
+	return args, opts
+}
+
+// whisperSetup builds the faster-whisper image on the DGX unless it is already there. A
+// custom image is left to docker to pull.
+func (m *Manager) whisperSetup(image string, rebuild bool) error {
+	if image != "" && image != whisperImage {
+		if rebuild {
+			fmt.Fprintf(os.Stderr, "Pulling %s...\n", image)
+			return m.sshClient.Stream("docker pull "+ssh.ShellQuote(image), nil, os.Stderr, os.Stderr)
+		}
+		return nil
+	}
+	if !rebuild {
+		if _, err := m.sshClient.Execute("docker image inspect " + whisperImage + " >/dev/null 2>&1"); err == nil {
+			return nil
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Building %s on the DGX (CTranslate2 with CUDA; this takes a while the first time)...\n", whisperImage)
+	if err := m.sshClient.Stream("docker build -t "+whisperImage+" -", strings.NewReader(whisperDockerfile), os.Stderr, os.Stderr); err != nil {
+		return fmt.Errorf("failed to build %s: %w", whisperImage, err)
+	}
+	return nil
+}
+
+func (m *Manager) whisperTranscribe(file string, opts whisperOptions) error {
+	in, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("failed to open audio file: %w", err)
+	}
+	defer in.Close()
+	if info, err := in.Stat(); err != nil || info.IsDir() {
+		return fmt.Errorf("%s is not an audio file", file)
+	}
+
+	if err := m.whisperSetup(opts.image, false); err != nil {
+		return err
+	}
+	image := opts.image
+	if image == "" {
+		image = whisperImage
+	}
+
+	// The extension tells ffmpeg the container format; anything odd is dropped
+	ext := strings.ToLower(filepath.Ext(file))
+	if !audioExtPattern.MatchString(ext) {
+		ext = ""
+	}
+	job := fmt.Sprintf("%s/%d", whisperJobsDir, time.Now().UnixNano())
+	fmt.Fprintf(os.Stderr, "Uploading %s...\n", filepath.Base(file))
+	if err := m.sshClient.Stream(fmt.Sprintf(`mkdir -p "%s" && cat > "%s/input%s"`, job, job, ext), in, nil, os.Stderr); err != nil {
+		return fmt.Errorf("failed to upload the audio file: %w", err)
+	}
+	defer func() {
+		if _, err := m.sshClient.Execute(fmt.Sprintf(`rm -rf "%s"`, job)); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to remove %s on the DGX: %v\n", job, err)
+		}
+	}()
+
+	task := "transcribe"
+	if opts.translate {
+		task = "translate"
+	}
+	cmd := fmt.Sprintf(`mkdir -p "%s" && docker run --rm -i --gpus all \
+		-v "%s":/cache -v "%s":/work:ro \
+		-e HF_HOME=/cache -e HF_TOKEN \
+		-e MODEL=%s -e INPUT=/work/input%s -e LANGUAGE=%s -e TASK=%s \
+		%s python3 -`,
+		whisperCacheDir, whisperCacheDir, job,
+		ssh.ShellQuote(opts.model), ext, ssh.ShellQuote(opts.language), task, ssh.ShellQuote(image))
+
+	fmt.Fprintf(os.Stderr, "Transcribing with %s (the model is downloaded to the DGX's cache on first use)...\n", opts.model)
+	var stdout bytes.Buffer
+	var duration float64
+	progress := &lineWriter{onLine: func(line string) {
+		event, ok := parseWhisperEvent(line)
+		if !ok {
+			if line != "" {
+				fmt.Fprintln(os.Stderr, line)
+			}
+			return
+		}
+		switch event.Kind {
+		case "device":
+			if event.Value != "cuda" {
+				fmt.Fprintln(os.Stderr, "Warning: CTranslate2 in this image cannot use the GPU; transcribing on the CPU")
+			}
+		case "info":
+			duration = event.Seconds
+			fmt.Fprintf(os.Stderr, "Language: %s, %s of audio\n", event.Value, (time.Duration(duration) * time.Second).String())
+		case "segment":
+			percent := 100.0
+			if duration > 0 {
+				percent = math.Min(100, 100*event.Seconds/duration)
+			}
+			fmt.Fprintf(os.Stderr, "[%3.0f%%] %s  %s\n", percent, subtitleTime(event.Seconds, ".")[:8], event.Text)
+		}
+	}}
+	if err := m.sshClient.Stream(cmd, strings.NewReader(whisperScript), &stdout, progress); err != nil {
+		return fmt.Errorf("transcription failed: %w", err)
+	}
+
+	var transcript Transcript
+	if err := json.Unmarshal(stdout.Bytes(), &transcript); err != nil {
+		return fmt.Errorf("failed to read the transcript: %w", err)
+	}
+	data, err := transcript.Render(opts.format)
+	if err != nil {
+		return err
+	}
+	if opts.output == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(opts.output, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write the transcript: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d segments to %s\n", len(transcript.Segments), opts.output)
+	return nil
+}
+
+// whisperCacheEntry is one model in the Hugging Face cache on the DGX
+type whisperCacheEntry struct {
+	Repo string
+	Dir  string
+	Size int64
+}
+
+// parseWhisperCache parses the output of whisperCacheScript, largest first
+func parseWhisperCache(output string) []whisperCacheEntry {
+	var entries []whisperCacheEntry
+	for _, line := range strings.Split(output, "\n") {
+		size, dir, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(size, 10, 64)
+		if err != nil || !strings.HasPrefix(dir, "models--") {
+			continue
+		}
+		repo := strings.ReplaceAll(strings.TrimPrefix(dir, "models--"), "--", "/")
+		entries = append(entries, whisperCacheEntry{Repo: repo, Dir: dir, Size: n})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Size > entries[j].Size })
+	return entries
+}
+
+// matches reports whether model names this cache entry, either as the repository or as a
+// faster-whisper size name such as large-v3
+func (e whisperCacheEntry) matches(model string) bool {
+	return e.Repo == model || strings.HasSuffix(e.Repo, "whisper-"+model)
+}
+
+func (m *Manager) whisperCache() ([]whisperCacheEntry, error) {
+	output, err := m.sshClient.Execute(whisperCacheScript)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the model cache: %w", err)
+	}
+	return parseWhisperCache(output), nil
+}
+
+func (m *Manager) whisperCacheList() error {
+	entries, err := m.whisperCache()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Println("No whisper models cached on the DGX.")
+		return nil
+	}
+	var total int64
+	fmt.Printf("%-50s %10s\n", "MODEL", "SIZE")
+	for _, e := range entries {
+		fmt.Printf("%-50s %10s\n", e.Repo, artifacts.FormatBytes(e.Size))
+		total += e.Size
+	}
+	fmt.Printf("\n%d models, %s in %s\n", len(entries), artifacts.FormatBytes(total), strings.Replace(whisperCacheDir, "$HOME", "~", 1))
+	return nil
+}
+
+func (m *Manager) whisperCacheClear(model string, yes bool) error {
+	entries, err := m.whisperCache()
+	if err != nil {
+		return err
+	}
+	var remove []whisperCacheEntry
+	var size int64
+	for _, e := range entries {
+		if model == "" || e.matches(model) {
+			remove = append(remove, e)
+			size += e.Size
+		}
+	}
+	if len(remove) == 0 {
+		if model != "" {
+			return fmt.Errorf("model %s is not cached on the DGX", model)
+		}
+		fmt.Println("No whisper models cached on the DGX.")
+		return nil
+	}
+
+	prompt := fmt.Sprintf("Remove %d cached whisper models (%s)?", len(remove), artifacts.FormatBytes(size))
+	if len(remove) == 1 {
+		prompt = fmt.Sprintf("Remove cached model %s (%s)?", remove[0].Repo, artifacts.FormatBytes(size))
+	}
+	if err := m.confirmDestructive(prompt, yes); err != nil {
+		return err
+	}
+	dirs := make([]string, len(remove))
+	for i, e := range remove {
+		dirs[i] = fmt.Sprintf(`"%s/hub/"%s`, whisperCacheDir, ssh.ShellQuote(e.Dir))
+	}
+	if _, err := m.sshClient.Execute("rm -rf " + strings.Join(dirs, " ")); err != nil {
+		return fmt.Errorf("failed to clear the model cache: %w", err)
+	}
+	fmt.Printf("Freed %s.\n", artifacts.FormatBytes(size))
+	return nil
+}
+
+// lineWriter calls onLine for each complete line written to it
+type lineWriter struct {
+	partial string
+	onLine  func(string)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	lines := strings.Split(w.partial+string(p), "\n")
+	w.partial = lines[len(lines)-1]
+	for _, line := range lines[:len(lines)-1] {
+		w.onLine(strings.TrimSpace(line))
+	}
+	return len(p), nil
+}
@@ -0,0 +1,58 @@
+package playbook
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTranscriptRender(t *testing.T) {
+	transcript := Transcript{Language: "en", Duration: 3725.5, Segments: []TranscriptSegment{
+		{Start: 0, End: 2.5, Text: "Hello there."},
+		{Start: 3723.25, End: 3725.5, Text: "Goodbye."},
+	}}
+
+	cases := map[string]string{
+		"txt": "Hello there.\nGoodbye.\n",
+		"srt": "1\n00:00:00,000 --> 00:00:02,500\nHello there.\n\n2\n01:02:03,250 --> 01:02:05,500\nGoodbye.\n\n",
+		"vtt": "WEBVTT\n\n00:00:00.000 --> 00:00:02.500\nHello there.\n\n01:02:03.250 --> 01:02:05.500\nGoodbye.\n\n",
+	}
+	for format, want := range cases {
+		got, err := transcript.Render(format)
+		if err != nil {
+			t.Fatalf("Render(%s): %v", format, err)
+		}
+		if string(got) != want {
+			t.Fatalf("Render(%s) = %q, want %q", format, got, want)
+		}
+	}
+	if got, err := transcript.Render("json"); err != nil || !strings.Contains(string(got), `"text": "Goodbye."`) {
+		t.Fatalf("Render(json) = %s, %v", got, err)
+	}
+	if _, err := transcript.Render("docx"); err == nil {
+		t.Fatalf("Render(docx): expected an error")
+	}
+}
+
+func TestParseWhisperEvent(t *testing.T) {
+	if e, ok := parseWhisperEvent("dgx-whisper info de 197.3"); !ok || e.Kind != "info" || e.Value != "de" || e.Seconds != 197.3 {
+		t.Fatalf("info event = %+v, %v", e, ok)
+	}
+	if e, ok := parseWhisperEvent("dgx-whisper segment 12.4 Guten Tag, wie geht's?"); !ok || e.Seconds != 12.4 || e.Text != "Guten Tag, wie geht's?" {
+		t.Fatalf("segment event = %+v, %v", e, ok)
+	}
+	for _, line := range []string{"Downloading model.bin", "dgx-whisper segment abc text", "dgx-whisper other"} {
+		if _, ok := parseWhisperEvent(line); ok {
+			t.Fatalf("parseWhisperEvent(%q) should not be an event", line)
+		}
+	}
+}
+
+func TestParseWhisperCache(t *testing.T) {
+	entries := parseWhisperCache("1620000000 models--mobiuslabsgmbh--faster-whisper-large-v3-turbo\n3100000000 models--Systran--faster-whisper-large-v3\nnot a line\n")
+	if len(entries) != 2 || entries[0].Repo != "Systran/faster-whisper-large-v3" || entries[0].Size != 3100000000 {
+		t.Fatalf("entries = %+v", entries)
+	}
+	if !entries[0].matches("large-v3") || entries[1].matches("large-v3") || !entries[1].matches("large-v3-turbo") {
+		t.Fatalf("size names matched the wrong models: %+v", entries)
+	}
+}
@@ -0,0 +1,114 @@
+package policy
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+// AnyArgs ends an exec_allow pattern that accepts any further arguments, as in
+// "docker logs ..."
+const AnyArgs = "..."
+
+// ExecWords splits the arguments of 'dgx exec' into the words of the remote command. A
+// single argument is split on whitespace, so `dgx exec "df -h"` and `dgx exec df -h` are
+// the same command; otherwise each argument is one word.
+func ExecWords(args []string) []string {
+	if len(args) == 1 {
+		return strings.Fields(args[0])
+	}
+	return args
+}
+
+// ValidateExecAllow reports the first malformed pattern in an exec_allow list
+func ValidateExecAllow(patterns []string) error {
+	for _, pattern := range patterns {
+		words := strings.Fields(pattern)
+		if len(words) == 0 {
+			return fmt.Errorf("exec_allow has an empty pattern")
+		}
+		for i, word := range words {
+			if word == AnyArgs {
+				if i != len(words)-1 {
+					return fmt.Errorf("exec_allow pattern %q: %s may only end a pattern", pattern, AnyArgs)
+				}
+				continue
+			}
+			if _, err := path.Match(word, ""); err != nil {
+				return fmt.Errorf("exec_allow pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// MatchExec reports whether the command words match one of the patterns. Each pattern
+// word matches one command word as a glob (*, ?, [...]; * does not cross a /), and a
+// trailing ... matches any remaining words.
+func MatchExec(patterns, words []string) bool {
+	if len(words) == 0 {
+		return false
+	}
+	for _, pattern := range patterns {
+		if matchWords(strings.Fields(pattern), words) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchWords(pattern, words []string) bool {
+	for i, p := range pattern {
+		if p == AnyArgs && i == len(pattern)-1 {
+			return true
+		}
+		if i >= len(words) {
+			return false
+		}
+		if ok, err := path.Match(p, words[i]); err != nil || !ok {
+			return false
+		}
+	}
+	return len(words) == len(pattern)
+}
+
+// ExecCommand returns the remote command for the arguments of a command run on the DGX.
+// Without an allowlist the arguments are the command as given. With one, the words must
+// match one of its patterns, and each is quoted so the DGX shell runs the command without
+// interpreting it.
+func ExecCommand(allow, args []string) (string, error) {
+	if len(allow) == 0 {
+		return strings.Join(args, " "), nil
+	}
+	words := ExecWords(args)
+	if !MatchExec(allow, words) {
+		return "", fmt.Errorf("%q is not allowed on this connection; exec_allow permits: %s",
+			strings.Join(words, " "), strings.Join(allow, "; "))
+	}
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = ssh.ShellQuote(word)
+	}
+	return strings.Join(quoted, " "), nil
+}
+
+// RequireShell refuses command, which opens an unrestricted shell or session, when the
+// connection limits commands to an allowlist
+func RequireShell(allow []string, command string) error {
+	if len(allow) > 0 {
+		return fmt.Errorf("'%s' opens a shell, but this connection only allows the commands in exec_allow (see 'dgx config show')", command)
+	}
+	return nil
+}
+
+// RequireFileAccess refuses command, which reads or writes any file the user can, when the
+// connection limits commands to an allowlist: overwriting ~/.bashrc or authorized_keys is
+// as good as a shell
+func RequireFileAccess(allow []string, command string) error {
+	if len(allow) > 0 {
+		return fmt.Errorf("'%s' can read and write any file, but this connection only allows the commands in exec_allow (see 'dgx config show')", command)
+	}
+	return nil
+}
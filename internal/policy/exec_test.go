@@ -0,0 +1,91 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchExec(t *testing.T) {
+	patterns := []string{"nvidia-smi ...", "docker ps", "docker logs --tail=* *", "df -h /*"}
+	cases := []struct {
+		command string
+		want    bool
+	}{
+		{"nvidia-smi", true},
+		{"nvidia-smi -L", true},
+		{"docker ps", true},
+		{"docker ps -a", false},
+		{"docker logs --tail=50 vllm-server", true},
+		{"docker logs vllm-server", false},
+		{"docker rm -f vllm-server", false},
+		{"df -h /home", true},
+		{"df -h /home/../etc", false},
+		{"nvidia-smi -L ; rm -rf ~", true}, // ";" and the rest are arguments to nvidia-smi: commands run without a shell
+		{"nvidia-smi; rm -rf ~", false},
+		{"rm -rf /", false},
+		{"", false},
+	}
+	for _, c := range cases {
+		if got := MatchExec(patterns, ExecWords([]string{c.command})); got != c.want {
+			t.Fatalf("MatchExec(%q) = %v, want %v", c.command, got, c.want)
+		}
+	}
+	if words := ExecWords([]string{"grep", "two words", "file"}); len(words) != 3 || words[1] != "two words" {
+		t.Fatalf("separate arguments should stay whole: %q", words)
+	}
+}
+
+func TestValidateExecAllow(t *testing.T) {
+	if err := ValidateExecAllow([]string{"docker logs ...", "ls /data/*"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, bad := range []string{"", "docker ... ps", "ls [a-"} {
+		if err := ValidateExecAllow([]string{bad}); err == nil {
+			t.Fatalf("expected an error for pattern %q", bad)
+		} else if !strings.Contains(err.Error(), "exec_allow") {
+			t.Fatalf("error should name the setting: %v", err)
+		}
+	}
+}
+
+func TestExecCommand(t *testing.T) {
+	if got, err := ExecCommand(nil, []string{"ls | wc -l"}); err != nil || got != "ls | wc -l" {
+		t.Fatalf("unrestricted command changed: %q err=%v", got, err)
+	}
+
+	allow := []string{"nvidia-smi ...", "git status"}
+	if got, err := ExecCommand(allow, []string{"nvidia-smi -L"}); err != nil || got != "'nvidia-smi' '-L'" {
+		t.Fatalf("allowed command = %q err=%v", got, err)
+	}
+	// A shell snippet, as 'dgx exec "..."' takes it, is split into words and
+	// must match like any other command
+	for _, args := range [][]string{{"make train && rm -rf ~"}, {"bash"}, {"python", "train.py"}} {
+		if _, err := ExecCommand(allow, args); err == nil || !strings.Contains(err.Error(), "not allowed") {
+			t.Fatalf("%q should be denied, got %v", args, err)
+		}
+	}
+	// Allowed words are quoted, so shell syntax in them is not interpreted
+	if got, _ := ExecCommand(allow, []string{"nvidia-smi", "; rm -rf ~"}); got != "'nvidia-smi' '; rm -rf ~'" {
+		t.Fatalf("arguments not quoted: %q", got)
+	}
+}
+
+func TestRequireShell(t *testing.T) {
+	if err := RequireShell(nil, "dgx code"); err != nil {
+		t.Fatalf("unrestricted connection refused a shell: %v", err)
+	}
+	if err := RequireShell([]string{"nvidia-smi"}, "dgx code"); err == nil || !strings.Contains(err.Error(), "dgx code") {
+		t.Fatalf("restricted connection allowed a shell: %v", err)
+	}
+}
+
+func TestRequireFileAccess(t *testing.T) {
+	if err := RequireFileAccess(nil, "dgx sync"); err != nil {
+		t.Fatalf("unrestricted connection refused a sync: %v", err)
+	}
+	for _, command := range []string{"dgx sync", "dgx git push-run", "dgx __rsh"} {
+		if err := RequireFileAccess([]string{"nvidia-smi", "rsync ..."}, command); err == nil || !strings.Contains(err.Error(), command) {
+			t.Fatalf("restricted connection allowed %s: %v", command, err)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package policy
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Level classifies what a command can do to the DGX
+type Level int
+
+const (
+	// Safe commands only read
+	Safe Level = iota
+	// Mutating commands change the DGX in ways that can be redone or undone
+	Mutating
+	// Destructive commands remove data, stop running work, or take the machine down
+	Destructive
+)
+
+func (l Level) String() string {
+	switch l {
+	case Mutating:
+		return "mutating"
+	case Destructive:
+		return "destructive"
+	default:
+		return "safe"
+	}
+}
+
+// Confirmation modes for destructive commands, set with 'confirm' in the config
+const (
+	// ConfirmPrompt asks [y/N], skipped by --yes (the default)
+	ConfirmPrompt = "prompt"
+	// ConfirmTyped requires typing the DGX hostname, even with --yes
+	ConfirmTyped = "typed"
+)
+
+// Commands classifies the dgx commands that change the DGX by command path; anything not
+// listed only reads. Playbooks are classified per command by the playbook package.
+var Commands = map[string]Level{
+	"dgx exec":                     Mutating,
+	"dgx sync":                     Mutating,
+	"dgx data push":                Mutating,
+	"dgx fs edit":                  Mutating,
+	"dgx archive extract":          Mutating,
+	"dgx git push-run":             Mutating,
+	"dgx gpu stress":               Mutating,
+	"dgx gpu reserve":              Mutating,
+	"dgx recipes run":              Mutating,
+	"dgx deploy autostart":         Mutating,
+	"dgx deploy autostart disable": Mutating,
+	"dgx deploy warm":              Mutating,
+	"dgx deploy env set":           Mutating,
+	"dgx deploy env unset":         Mutating,
+	"dgx power track":              Mutating,
+	"dgx power track disable":      Mutating,
+	"dgx power idle enable":        Mutating,
+	"dgx power idle disable":       Mutating,
+	"dgx power on":                 Mutating,
+	"dgx fleet exec":               Mutating,
+	"dgx fleet run":                Mutating,
+	"dgx run":                      Mutating,
+	"dgx job submit":               Mutating,
+	"dgx build":                    Mutating,
+	"dgx images copy":              Mutating,
+	"dgx registry login":           Mutating,
+	"dgx registry logout":          Mutating,
+	"dgx data rm":                  Destructive,
+	"dgx gpu kill":                 Destructive,
+	"dgx ps stop":                  Destructive,
+	"dgx job cancel":               Destructive,
+	"dgx reboot":                   Destructive,
+}
+
+// Classify returns the level of the command at path, such as "dgx data rm"
+func Classify(path string) Level {
+	return Commands[path]
+}
+
+// ValidateConfirm reports whether mode is a known confirmation mode
+func ValidateConfirm(mode string) error {
+	switch mode {
+	case "", ConfirmPrompt, ConfirmTyped:
+		return nil
+	default:
+		return fmt.Errorf("confirm %q must be %s or %s", mode, ConfirmPrompt, ConfirmTyped)
+	}
+}
+
+// Policy decides how operations on one DGX are confirmed
+type Policy struct {
+	Confirm string // ConfirmPrompt (default) or ConfirmTyped
+	Host    string // what ConfirmTyped asks the user to type
+
+	in  io.Reader
+	out io.Writer
+}
+
+// New returns the policy for confirm mode on host, prompting on the terminal
+func New(confirm, host string) Policy {
+	return Policy{Confirm: confirm, Host: host, in: os.Stdin, out: os.Stdout}
+}
+
+// Allow asks before an operation of the given level and reports whether to go ahead.
+// Destructive operations under ConfirmTyped need the hostname typed back; everything else
+// is a [y/N] question that yes answers in advance.
+func (p Policy) Allow(level Level, prompt string, yes bool) bool {
+	in, out := p.in, p.out
+	if in == nil {
+		in = os.Stdin
+	}
+	if out == nil {
+		out = os.Stdout
+	}
+
+	if level == Destructive && p.Confirm == ConfirmTyped && p.Host != "" {
+		fmt.Fprintf(out, "%s\nThis is destructive. Type the hostname (%s) to continue: ", prompt, p.Host)
+		if readLine(in) == p.Host {
+			return true
+		}
+		fmt.Fprintln(out, "Hostname did not match.")
+		return false
+	}
+	if yes {
+		return true
+	}
+	fmt.Fprintf(out, "%s [y/N]: ", prompt)
+	response := readLine(in)
+	return strings.EqualFold(response, "y") || strings.EqualFold(response, "yes")
+}
+
+// readLine reads one line byte by byte, so input meant for later prompts stays unread
+func readLine(r io.Reader) string {
+	var line []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if buf[0] == '\n' {
+				break
+			}
+			line = append(line, buf[0])
+		}
+		if err != nil {
+			break
+		}
+	}
+	return strings.TrimSpace(string(line))
+}
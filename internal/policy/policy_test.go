@@ -0,0 +1,39 @@
+package policy
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestAllow(t *testing.T) {
+	cases := []struct {
+		name    string
+		confirm string
+		level   Level
+		input   string
+		yes     bool
+		want    bool
+	}{
+		{"prompt accepts y", ConfirmPrompt, Destructive, "y\n", false, true},
+		{"prompt defaults to no", "", Destructive, "\n", false, false},
+		{"yes skips the prompt", "", Destructive, "", true, true},
+		{"typed needs the hostname", ConfirmTyped, Destructive, "spark-1\n", false, true},
+		{"typed rejects y", ConfirmTyped, Destructive, "y\n", false, false},
+		{"typed ignores --yes", ConfirmTyped, Destructive, "", true, false},
+		{"typed only applies to destructive", ConfirmTyped, Mutating, "", true, true},
+	}
+	for _, c := range cases {
+		p := Policy{Confirm: c.confirm, Host: "spark-1", in: strings.NewReader(c.input), out: io.Discard}
+		if got := p.Allow(c.level, "Delete?", c.yes); got != c.want {
+			t.Fatalf("%s: Allow = %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	if Classify("dgx reboot") != Destructive || Classify("dgx ps") != Safe {
+		t.Fatalf("unexpected classification")
+	}
+	if ValidateConfirm("always") == nil {
+		t.Fatalf("expected an error for an unknown confirm mode")
+	}
+}
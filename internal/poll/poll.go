@@ -0,0 +1,212 @@
+// Package poll paces the loops that query a DGX over and over: status waits, monitors, the
+// GPU status line, and the daemon's alert sampling. Each loop registers under a host and
+// the kind of reading it takes. While n loops take the same kind of reading from one host,
+// in this process or any other dgx process on the machine, each one waits at least n times
+// the host's minimum interval, so five open dashboards together poll no faster than one.
+// Waits are jittered so that loops started together drift apart.
+package poll
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// DefaultMinInterval is the fastest one host is polled for the same reading when the
+// config sets no minimum
+const DefaultMinInterval = time.Second
+
+// jitter is the fraction by which each wait is randomly lengthened or shortened
+const jitter = 0.1
+
+// minLease is the shortest time a loop's registration outlives its last renewal, so a loop
+// between polls is still counted while one that died is soon forgotten
+const minLease = 15 * time.Second
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// leaseSeq numbers the lease files of this process
+var leaseSeq atomic.Int64
+
+// Scheduler hands out waits to polling loops. A nil Scheduler paces each loop on its own
+// with the default minimum.
+type Scheduler struct {
+	// dir holds a lease file per registered loop; empty, loops are only counted within
+	// this process
+	dir     string
+	minimum func(host string) time.Duration
+
+	mu    sync.Mutex
+	local map[string]int
+}
+
+// New returns a scheduler that coordinates through lease files in dir, or within this
+// process when dir is empty. minimum gives a host's minimum interval; nil uses
+// DefaultMinInterval for every host.
+func New(dir string, minimum func(host string) time.Duration) *Scheduler {
+	return &Scheduler{dir: dir, minimum: minimum, local: map[string]int{}}
+}
+
+// Minimums returns the minimum interval per host set by cfg: a host's own entry, else the
+// shared min_interval, else DefaultMinInterval
+func Minimums(cfg *types.PollConfig) func(host string) time.Duration {
+	return func(host string) time.Duration {
+		if cfg == nil {
+			return DefaultMinInterval
+		}
+		if d, ok := cfg.Hosts[host]; ok && d > 0 {
+			return d
+		}
+		if cfg.MinInterval > 0 {
+			return cfg.MinInterval
+		}
+		return DefaultMinInterval
+	}
+}
+
+// Loop is one registered polling loop. Close it when the loop ends.
+type Loop struct {
+	s        *Scheduler
+	host     string
+	key      string
+	interval time.Duration
+	lease    string // lease file, empty without a directory
+
+	mu      sync.Mutex
+	renewed time.Time
+	closed  bool
+}
+
+// Register adds a loop that would like to poll kind on host every interval
+func (s *Scheduler) Register(host, kind string, interval time.Duration) *Loop {
+	key := unsafeChars.ReplaceAllString(host, "-") + "_" + unsafeChars.ReplaceAllString(kind, "-")
+	l := &Loop{s: s, host: host, key: key, interval: interval}
+	if s == nil {
+		return l
+	}
+	s.mu.Lock()
+	s.local[key]++
+	s.mu.Unlock()
+	if s.dir != "" && os.MkdirAll(s.dir, 0700) == nil {
+		l.lease = filepath.Join(s.dir, fmt.Sprintf("%s.%d.%d", key, os.Getpid(), leaseSeq.Add(1)))
+		l.renew(time.Now())
+	}
+	return l
+}
+
+// Interval returns how long the loop waits between polls now: the interval it asked for,
+// raised to its share of the host's minimum. It renews the loop's registration.
+func (l *Loop) Interval() time.Duration {
+	if l.s == nil {
+		return max(l.interval, DefaultMinInterval)
+	}
+	minimum := DefaultMinInterval
+	if l.s.minimum != nil {
+		minimum = l.s.minimum(l.host)
+	}
+	now := time.Now()
+	l.renew(now)
+	return max(l.interval, minimum*time.Duration(l.s.active(l.key, now)))
+}
+
+// Next returns the jittered wait before the next poll
+func (l *Loop) Next() time.Duration {
+	d := l.Interval()
+	return time.Duration(float64(d) * (1 + jitter*(2*rand.Float64()-1)))
+}
+
+// Wait sleeps until the next poll is due. It returns false, without waiting out the
+// interval, when ctx ends first.
+func (l *Loop) Wait(ctx context.Context) bool {
+	timer := time.NewTimer(l.Next())
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// Renew keeps the loop counted without asking for a wait, for loops that poll on their own
+// clock such as a sampling command streaming readings
+func (l *Loop) Renew() {
+	l.renew(time.Now())
+}
+
+// Close unregisters the loop. It is safe to call more than once.
+func (l *Loop) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed || l.s == nil {
+		return
+	}
+	l.closed = true
+	l.s.mu.Lock()
+	l.s.local[l.key]--
+	l.s.mu.Unlock()
+	if l.lease != "" {
+		os.Remove(l.lease)
+	}
+}
+
+// renew extends the loop's lease file, at most once per half lease; the file holds the
+// time the lease expires
+func (l *Loop) renew(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lease := l.leaseTime()
+	if l.lease == "" || l.closed || now.Sub(l.renewed) < lease/2 {
+		return
+	}
+	l.renewed = now
+	os.WriteFile(l.lease, []byte(strconv.FormatInt(now.Add(lease).UnixNano(), 10)), 0600)
+}
+
+// leaseTime is how long a registration outlives its renewal: several of the loop's own
+// intervals, so it is counted while it waits
+func (l *Loop) leaseTime() time.Duration {
+	return max(3*l.interval, minLease)
+}
+
+// active counts the loops registered under key: the unexpired lease files, or this
+// process's loops without a directory. Expired leases are removed.
+func (s *Scheduler) active(key string, now time.Time) int {
+	if s.dir == "" {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return max(s.local[key], 1)
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 1
+	}
+	n := 0
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), key+".") {
+			continue
+		}
+		path := filepath.Join(s.dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		expires, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil || now.UnixNano() > expires {
+			os.Remove(path)
+			continue
+		}
+		n++
+	}
+	return max(n, 1)
+}
@@ -0,0 +1,102 @@
+package poll
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+func TestIntervalSharesMinimum(t *testing.T) {
+	dir := t.TempDir()
+	s := New(dir, func(string) time.Duration { return time.Second })
+
+	a := s.Register("spark", "gpu", 500*time.Millisecond)
+	if got := a.Interval(); got != time.Second {
+		t.Fatalf("a lone loop should be raised to the minimum, got %v", got)
+	}
+	loops := []*Loop{a}
+	for range 4 {
+		loops = append(loops, s.Register("spark", "gpu", 500*time.Millisecond))
+	}
+	if got := a.Interval(); got != 5*time.Second {
+		t.Fatalf("five loops should share the minimum, got %v", got)
+	}
+	if got := s.Register("other", "gpu", 500*time.Millisecond).Interval(); got != time.Second {
+		t.Fatalf("another host should not be slowed, got %v", got)
+	}
+	if got := s.Register("spark", "events", 2*time.Second).Interval(); got != 2*time.Second {
+		t.Fatalf("another reading should keep its interval, got %v", got)
+	}
+
+	// Another process sees the same leases
+	if got := New(dir, nil).Register("spark", "gpu", 0).Interval(); got != 6*time.Second {
+		t.Fatalf("expected six loops across schedulers, got %v", got)
+	}
+
+	for _, l := range loops[1:] {
+		l.Close()
+		l.Close()
+	}
+	if got := a.Interval(); got != 2*time.Second {
+		t.Fatalf("closed loops should not count, got %v", got)
+	}
+}
+
+func TestExpiredLeases(t *testing.T) {
+	dir := t.TempDir()
+	s := New(dir, nil)
+	l := s.Register("spark", "gpu", time.Second)
+
+	stale := filepath.Join(dir, "spark_gpu.1.1")
+	os.WriteFile(stale, []byte(strconv.FormatInt(time.Now().Add(-time.Minute).UnixNano(), 10)), 0600)
+	os.WriteFile(filepath.Join(dir, "spark_gpu.1.2"), []byte("garbage"), 0600)
+	if got := l.Interval(); got != DefaultMinInterval {
+		t.Fatalf("stale leases should be ignored, got %v", got)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Fatalf("stale lease should be removed, got %v", err)
+	}
+}
+
+func TestLocalAndNil(t *testing.T) {
+	s := New("", nil)
+	a := s.Register("spark", "gpu", 0)
+	b := s.Register("spark", "gpu", 0)
+	if got := a.Interval(); got != 2*DefaultMinInterval {
+		t.Fatalf("expected loops counted in process, got %v", got)
+	}
+	b.Close()
+	if got := a.Interval(); got != DefaultMinInterval {
+		t.Fatalf("expected one loop after close, got %v", got)
+	}
+
+	var none *Scheduler
+	l := none.Register("spark", "gpu", 3*time.Second)
+	if got := l.Interval(); got != 3*time.Second {
+		t.Fatalf("nil scheduler should keep the interval, got %v", got)
+	}
+	l.Close()
+
+	for range 100 {
+		if d := l.Next(); d < 2700*time.Millisecond || d > 3300*time.Millisecond {
+			t.Fatalf("jitter out of bounds: %v", d)
+		}
+	}
+}
+
+func TestMinimums(t *testing.T) {
+	cfg := &types.PollConfig{MinInterval: 2 * time.Second, Hosts: map[string]time.Duration{"lab": 5 * time.Second}}
+	if got := Minimums(cfg)("lab"); got != 5*time.Second {
+		t.Fatalf("expected the host's minimum, got %v", got)
+	}
+	if got := Minimums(cfg)("spark"); got != 2*time.Second {
+		t.Fatalf("expected the shared minimum, got %v", got)
+	}
+	if got := Minimums(nil)("spark"); got != DefaultMinInterval {
+		t.Fatalf("expected the default, got %v", got)
+	}
+}
@@ -0,0 +1,282 @@
+package power
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// IdleUnit is the systemd unit that applies the idle policy on the DGX
+	IdleUnit = "dgx-idle.service"
+
+	// Idle actions
+	ActionSuspend    = "suspend"
+	ActionPowerLimit = "powerlimit"
+
+	// DefaultIdleAfter is how long the GPU must be idle before the action is taken
+	DefaultIdleAfter = 30 * time.Minute
+	// DefaultIdleThreshold is the GPU utilization (%) at or below which the GPU is idle
+	DefaultIdleThreshold = 5
+
+	// idleDir is the policy's runtime directory. It is world-writable so any user can ask
+	// for a wake-up by creating wakeFile.
+	idleDir        = "/run/dgx-idle"
+	wakeFile       = idleDir + "/wake"
+	idleScriptPath = "/usr/local/lib/dgx/idle.sh"
+)
+
+// IdleActions lists the supported idle actions
+var IdleActions = []string{ActionSuspend, ActionPowerLimit}
+
+// idleScript applies the policy: $1 action, $2 idle seconds, $3 utilization threshold,
+// $4 power limit in watts. Logged-in users keep the system awake, since suspending would
+// freeze their sessions; SSH port forwards such as the serve proxy's do not.
+const idleScript = `#!/bin/sh
+# Managed by dgx power idle
+action=$1
+after=$2
+threshold=$3
+limit=$4
+dir=` + idleDir + `
+default=$(nvidia-smi --query-gpu=power.default_limit --format=csv,noheader,nounits 2>/dev/null | head -n 1)
+if [ "$action" = suspend ] && command -v ethtool >/dev/null; then
+  # Wake-on-LAN is reset at boot by many drivers
+  iface=$(ip route show default 2>/dev/null | awk '{ print $5; exit }')
+  [ -n "$iface" ] && ethtool -s "$iface" wol g 2>/dev/null
+fi
+low=
+restore() {
+  if [ -n "$low" ]; then
+    nvidia-smi -pl "$default" >/dev/null 2>&1
+    low=
+  fi
+}
+# Stopping the policy puts the default power limit back
+trap 'restore; exit 0' TERM INT
+last=$(date +%s)
+prev=$last
+while :; do
+  now=$(date +%s)
+  # A jump in the clock means the system has just resumed from suspend
+  [ $((now - prev)) -gt 60 ] && last=$now
+  prev=$now
+  busy=
+  if [ -e "$dir/wake" ]; then
+    rm -f "$dir/wake"
+    busy=wake
+  fi
+  if nvidia-smi --query-gpu=utilization.gpu --format=csv,noheader,nounits 2>/dev/null | awk -v t="$threshold" '$1+0 > t { b = 1 } END { exit !b }'; then
+    busy=gpu
+  fi
+  [ -n "$(who)" ] && busy=${busy:-login}
+  if [ -n "$busy" ]; then
+    last=$now
+    restore
+    echo "active $now $busy" >"$dir/status"
+  elif [ $((now - last)) -ge "$after" ]; then
+    case $action in
+    suspend)
+      echo "suspending $now" >"$dir/status"
+      systemctl suspend
+      ;;
+    powerlimit)
+      [ -z "$low" ] && nvidia-smi -pl "$limit" >/dev/null 2>&1 && low=1
+      echo "lowpower $last" >"$dir/status"
+      ;;
+    esac
+  else
+    echo "idle $last" >"$dir/status"
+  fi
+  sleep 10
+done
+`
+
+// IdlePolicy is what the DGX does once its GPU has been idle for After
+type IdlePolicy struct {
+	Action    string
+	After     time.Duration
+	Threshold int // utilization %, at or below which the GPU counts as idle
+	Limit     int // power limit in watts for ActionPowerLimit
+}
+
+// Validate checks the policy
+func (p IdlePolicy) Validate() error {
+	switch p.Action {
+	case ActionSuspend:
+	case ActionPowerLimit:
+		if p.Limit <= 0 {
+			return fmt.Errorf("a power limit in watts is required for %s", ActionPowerLimit)
+		}
+	default:
+		return fmt.Errorf("unknown idle action %q (expected one of: %s)", p.Action, strings.Join(IdleActions, ", "))
+	}
+	if p.After < time.Minute {
+		return fmt.Errorf("idle time must be at least 1m")
+	}
+	if p.Threshold < 0 || p.Threshold > 100 {
+		return fmt.Errorf("utilization threshold must be between 0 and 100")
+	}
+	return nil
+}
+
+// RenderIdleUnit returns the systemd unit that runs the idle policy as root
+func RenderIdleUnit(p IdlePolicy) string {
+	return fmt.Sprintf(`# Managed by dgx power idle; remove with: dgx power idle disable
+[Unit]
+Description=dgx idle policy (%s after %v)
+After=nvidia-persistenced.service
+
+[Service]
+Type=simple
+Restart=always
+RestartSec=30
+RuntimeDirectory=dgx-idle
+RuntimeDirectoryMode=1777
+ExecStart=/bin/sh %s %s %d %d %d
+
+[Install]
+WantedBy=multi-user.target
+`, p.Action, p.After, idleScriptPath, p.Action, int(p.After.Seconds()), p.Threshold, p.Limit)
+}
+
+// PowerLimits are the GPU's settable power limit range in watts; zero when the GPU does not
+// allow changing it
+type PowerLimits struct {
+	Min, Max, Default float64
+}
+
+// ParsePowerLimits parses nvidia-smi's power.min_limit,power.max_limit,power.default_limit
+func ParsePowerLimits(output string) PowerLimits {
+	line, _, _ := strings.Cut(strings.TrimSpace(output), "\n")
+	fields := strings.Split(line, ",")
+	if len(fields) != 3 {
+		return PowerLimits{}
+	}
+	var values [3]float64
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil {
+			return PowerLimits{}
+		}
+		values[i] = v
+	}
+	return PowerLimits{Min: values[0], Max: values[1], Default: values[2]}
+}
+
+// EnableIdle installs the idle policy and starts it. For ActionPowerLimit the limit must
+// be within the GPU's settable range. Installing requires sudo, so the user may be
+// prompted for a password.
+func (m *Manager) EnableIdle(p IdlePolicy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if p.Action == ActionPowerLimit {
+		output, err := m.sshClient.Execute("nvidia-smi --query-gpu=power.min_limit,power.max_limit,power.default_limit --format=csv,noheader,nounits")
+		if err != nil {
+			return fmt.Errorf("failed to read the GPU power limits: %w", err)
+		}
+		limits := ParsePowerLimits(output)
+		if limits.Max == 0 {
+			return fmt.Errorf("this GPU does not allow changing its power limit; use --action %s", ActionSuspend)
+		}
+		if float64(p.Limit) < limits.Min || float64(p.Limit) > limits.Max {
+			return fmt.Errorf("power limit must be between %.0f and %.0f W", limits.Min, limits.Max)
+		}
+	}
+
+	staging := "$HOME/.config/dgx/units/"
+	stage := fmt.Sprintf(`mkdir -p "%[1]s" && echo %[2]s | base64 -d > "%[1]sdgx-idle.sh" && echo %[3]s | base64 -d > "%[1]s%[4]s"`,
+		staging, base64.StdEncoding.EncodeToString([]byte(idleScript)),
+		base64.StdEncoding.EncodeToString([]byte(RenderIdleUnit(p))), IdleUnit)
+	if _, err := m.sshClient.Execute(stage); err != nil {
+		return fmt.Errorf("failed to stage the idle policy: %w", err)
+	}
+	install := fmt.Sprintf(`sudo install -D -m 0755 "%[1]sdgx-idle.sh" %[2]s && sudo install -m 0644 "%[1]s%[3]s" %[4]s/%[3]s && sudo systemctl daemon-reload && sudo systemctl enable %[3]s && sudo systemctl restart %[3]s`,
+		staging, idleScriptPath, IdleUnit, unitDir)
+	if err := m.sshClient.RunInteractive(install); err != nil {
+		return fmt.Errorf("failed to install %s: %w", IdleUnit, err)
+	}
+	return nil
+}
+
+// DisableIdle stops and removes the idle policy. Stopping it restores the default power
+// limit if the policy had lowered it.
+func (m *Manager) DisableIdle() error {
+	remove := fmt.Sprintf(`sudo systemctl disable --now %[1]s; sudo rm -f %[2]s/%[1]s %[3]s && sudo systemctl daemon-reload && rm -f "$HOME/.config/dgx/units/%[1]s" "$HOME/.config/dgx/units/dgx-idle.sh"`,
+		IdleUnit, unitDir, idleScriptPath)
+	if err := m.sshClient.RunInteractive(remove); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", IdleUnit, err)
+	}
+	return nil
+}
+
+// IdleStatus is the idle policy's view of the DGX
+type IdleStatus struct {
+	Unit   string    // the unit's active state
+	Policy string    // the unit's description, e.g. "suspend after 30m0s"
+	State  string    // active, idle, lowpower, or suspending
+	Since  time.Time // when the state began (for idle and lowpower, when the GPU went idle)
+	Reason string    // what counted as activity: gpu, login, or wake
+}
+
+// IdleStatus returns the policy's state
+func (m *Manager) IdleStatus() (IdleStatus, error) {
+	output, err := m.sshClient.Execute(fmt.Sprintf(`systemctl is-active %[1]s 2>/dev/null || true
+systemctl show -P Description %[1]s 2>/dev/null || echo
+cat %[2]s/status 2>/dev/null || true`, IdleUnit, idleDir))
+	if err != nil {
+		return IdleStatus{}, fmt.Errorf("failed to read the idle policy: %w", err)
+	}
+	return parseIdleStatus(output), nil
+}
+
+func parseIdleStatus(output string) IdleStatus {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	var s IdleStatus
+	if len(lines) > 0 {
+		s.Unit = strings.TrimSpace(lines[0])
+	}
+	if len(lines) > 1 {
+		desc := strings.TrimSpace(lines[1])
+		if open, end := strings.Index(desc, "("), strings.LastIndex(desc, ")"); open >= 0 && end > open {
+			s.Policy = desc[open+1 : end]
+		}
+	}
+	if len(lines) > 2 {
+		fields := strings.Fields(lines[2])
+		if len(fields) > 0 {
+			s.State = fields[0]
+		}
+		if len(fields) > 1 {
+			if epoch, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+				s.Since = time.Unix(epoch, 0)
+			}
+		}
+		if len(fields) > 2 {
+			s.Reason = fields[2]
+		}
+	}
+	return s
+}
+
+// Wake tells the idle policy the DGX is wanted: the idle timer restarts and a lowered power
+// limit is restored within seconds. It is harmless without a policy installed.
+func (m *Manager) Wake() error {
+	if _, err := m.sshClient.Execute(fmt.Sprintf("[ -d %s ] && touch %s || true", idleDir, wakeFile)); err != nil {
+		return fmt.Errorf("failed to signal the idle policy: %w", err)
+	}
+	return nil
+}
+
+// InterfaceMAC returns the MAC address of the DGX's default-route interface, which
+// Wake-on-LAN packets must be addressed to
+func (m *Manager) InterfaceMAC() (string, error) {
+	output, err := m.sshClient.Execute(`cat "/sys/class/net/$(ip route show default | awk '{ print $5; exit }')/address"`)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the network interface address: %w", err)
+	}
+	return strings.TrimSpace(output), nil
+}
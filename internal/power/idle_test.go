@@ -0,0 +1,63 @@
+package power
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestIdlePolicyValidate(t *testing.T) {
+	for _, tc := range []struct {
+		policy IdlePolicy
+		ok     bool
+	}{
+		{IdlePolicy{Action: ActionSuspend, After: 30 * time.Minute, Threshold: 5}, true},
+		{IdlePolicy{Action: ActionPowerLimit, After: 30 * time.Minute, Threshold: 5}, false},
+		{IdlePolicy{Action: ActionPowerLimit, After: 30 * time.Minute, Threshold: 5, Limit: 60}, true},
+		{IdlePolicy{Action: ActionSuspend, After: 10 * time.Second}, false},
+		{IdlePolicy{Action: "hibernate", After: time.Hour}, false},
+	} {
+		if err := tc.policy.Validate(); (err == nil) != tc.ok {
+			t.Fatalf("Validate(%+v) = %v, want ok=%v", tc.policy, err, tc.ok)
+		}
+	}
+}
+
+func TestParseIdleStatus(t *testing.T) {
+	s := parseIdleStatus("active\ndgx idle policy (suspend after 30m0s)\nidle 1700000000\n")
+	if s.Unit != "active" || s.Policy != "suspend after 30m0s" || s.State != "idle" || s.Since.Unix() != 1700000000 {
+		t.Fatalf("unexpected status %+v", s)
+	}
+	s = parseIdleStatus("active\ndgx idle policy (powerlimit after 1h0m0s)\nactive 1700000100 login\n")
+	if s.State != "active" || s.Reason != "login" {
+		t.Fatalf("unexpected status %+v", s)
+	}
+	if s := parseIdleStatus("inactive\n\n"); s.Unit != "inactive" || s.State != "" {
+		t.Fatalf("unexpected status %+v", s)
+	}
+}
+
+func TestParsePowerLimits(t *testing.T) {
+	if l := ParsePowerLimits("100.00, 600.00, 575.00\n"); l.Min != 100 || l.Max != 600 || l.Default != 575 {
+		t.Fatalf("unexpected limits %+v", l)
+	}
+	if l := ParsePowerLimits("[N/A], [N/A], [N/A]\n"); l.Max != 0 {
+		t.Fatalf("expected no limits, got %+v", l)
+	}
+}
+
+func TestMagicPacket(t *testing.T) {
+	packet, err := MagicPacket("4c:bb:47:01:02:03")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packet) != 102 || !bytes.Equal(packet[:6], bytes.Repeat([]byte{0xff}, 6)) {
+		t.Fatalf("unexpected packet %x", packet)
+	}
+	if !bytes.Equal(packet[96:], []byte{0x4c, 0xbb, 0x47, 0x01, 0x02, 0x03}) {
+		t.Fatalf("unexpected MAC repetition %x", packet[96:])
+	}
+	if _, err := MagicPacket("not-a-mac"); err == nil {
+		t.Fatalf("expected an error for an invalid MAC")
+	}
+}
@@ -0,0 +1,295 @@
+// Package power logs GPU and platform power draw on the DGX and turns the log into energy
+// and cost estimates.
+package power
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+const (
+	// LoggerUnit is the systemd unit that samples power on the DGX
+	LoggerUnit = "dgx-power-log.service"
+	unitDir    = "/etc/systemd/system"
+
+	// DefaultInterval is how often the logger samples
+	DefaultInterval = time.Minute
+	// DefaultRetention is how many days of samples the logger keeps
+	DefaultRetention = 90
+
+	// logDir holds one CSV file per day of samples: epoch,interval,gpu watts,platform watts
+	logDir     = "~/.local/share/dgx/power"
+	scriptPath = "~/.config/dgx/power-log.sh"
+)
+
+// loggerScript samples every $1 seconds and deletes files older than $2 days. GPU power is
+// the sum over GPUs; platform power comes from an ACPI power meter when the system has
+// one and is left empty otherwise.
+const loggerScript = `#!/bin/sh
+# Managed by dgx power track
+interval=${1:-60}
+retain=${2:-90}
+dir="$HOME/.local/share/dgx/power"
+mkdir -p "$dir"
+while :; do
+  now=$(date +%s)
+  gpu=$(nvidia-smi --query-gpu=power.draw --format=csv,noheader,nounits 2>/dev/null | awk '$1+0 == $1 { s += $1; n++ } END { if (n) printf "%.1f", s }')
+  platform=
+  for h in /sys/class/hwmon/hwmon*; do
+    case "$(cat "$h/name" 2>/dev/null)" in
+    power_meter | acpi_power_meter)
+      for f in "$h/power1_average" "$h/power1_input"; do
+        if [ -r "$f" ]; then
+          platform=$(awk '{ printf "%.1f", $1 / 1000000 }' "$f")
+          break
+        fi
+      done
+      ;;
+    esac
+  done
+  echo "$now,$interval,$gpu,$platform" >>"$dir/$(date +%Y%m%d).csv"
+  find "$dir" -name '*.csv' -mtime +"$retain" -delete 2>/dev/null
+  sleep "$interval"
+done
+`
+
+// RenderUnit returns the logger's systemd unit, running as user
+func RenderUnit(user string, interval time.Duration, retention int) string {
+	return fmt.Sprintf(`# Managed by dgx power track; remove with: dgx power track disable
+[Unit]
+Description=dgx power logger
+After=nvidia-persistenced.service
+
+[Service]
+User=%s
+Type=simple
+Restart=always
+RestartSec=30
+ExecStart=/bin/bash -c "exec /bin/sh %s %d %d"
+
+[Install]
+WantedBy=multi-user.target
+`, user, scriptPath, int(interval.Seconds()), retention)
+}
+
+// Manager installs the power logger on the DGX and reads its samples
+type Manager struct {
+	sshClient *ssh.Client
+}
+
+// NewManager creates a new power manager
+func NewManager(sshClient *ssh.Client) *Manager {
+	return &Manager{sshClient: sshClient}
+}
+
+// Enable installs the logger script and unit and starts it. Installing the unit requires
+// sudo, so the user may be prompted for a password.
+func (m *Manager) Enable(user string, interval time.Duration, retention int) error {
+	if interval < 5*time.Second {
+		return fmt.Errorf("interval must be at least 5s")
+	}
+	if retention < 1 {
+		return fmt.Errorf("retention must be at least one day")
+	}
+	staging := "$HOME/.config/dgx/units/" + LoggerUnit
+	stage := fmt.Sprintf(`mkdir -p "$HOME/.config/dgx/units" && echo %s | base64 -d > %s && chmod 0755 %s && echo %s | base64 -d > "%s"`,
+		base64.StdEncoding.EncodeToString([]byte(loggerScript)), scriptPath, scriptPath,
+		base64.StdEncoding.EncodeToString([]byte(RenderUnit(user, interval, retention))), staging)
+	if _, err := m.sshClient.Execute(stage); err != nil {
+		return fmt.Errorf("failed to stage the power logger: %w", err)
+	}
+	install := fmt.Sprintf(`sudo install -m 0644 "%s" %s/%s && sudo systemctl daemon-reload && sudo systemctl enable %s && sudo systemctl restart %s`,
+		staging, unitDir, LoggerUnit, LoggerUnit, LoggerUnit)
+	if err := m.sshClient.RunInteractive(install); err != nil {
+		return fmt.Errorf("failed to install %s: %w", LoggerUnit, err)
+	}
+	return nil
+}
+
+// Disable stops and removes the logger. Samples already logged are kept.
+func (m *Manager) Disable() error {
+	remove := fmt.Sprintf(`sudo systemctl disable --now %s; sudo rm -f %s/%s && sudo systemctl daemon-reload && rm -f "$HOME/.config/dgx/units/%s" %s`,
+		LoggerUnit, unitDir, LoggerUnit, LoggerUnit, scriptPath)
+	if err := m.sshClient.RunInteractive(remove); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", LoggerUnit, err)
+	}
+	return nil
+}
+
+// Status returns the logger unit's active state ("inactive" when not installed)
+func (m *Manager) Status() string {
+	output, _ := m.sshClient.Execute("systemctl is-active " + LoggerUnit + " 2>/dev/null")
+	if state := strings.TrimSpace(output); state != "" {
+		return state
+	}
+	return "inactive"
+}
+
+// Samples returns the logged samples at or after the given Unix time, oldest first
+func (m *Manager) Samples(since int64) ([]Sample, error) {
+	output, err := m.sshClient.Execute(fmt.Sprintf(`cat %s/*.csv 2>/dev/null | awk -F, -v since=%d '$1 >= since' || true`, logDir, since))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the power log: %w", err)
+	}
+	return ParseLog(output), nil
+}
+
+// Sample is one logger reading. Power is in watts; Platform is -1 when the system has no
+// power meter.
+type Sample struct {
+	Time     time.Time
+	Interval time.Duration
+	GPU      float64
+	Platform float64
+}
+
+// ParseLog parses the logger's CSV lines, skipping malformed ones and readings where
+// nvidia-smi reported nothing
+func ParseLog(output string) []Sample {
+	var samples []Sample
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), ",")
+		if len(fields) != 4 {
+			continue
+		}
+		epoch, err1 := strconv.ParseInt(fields[0], 10, 64)
+		interval, err2 := strconv.Atoi(fields[1])
+		gpu, err3 := strconv.ParseFloat(fields[2], 64)
+		if err1 != nil || err2 != nil || err3 != nil || interval <= 0 {
+			continue
+		}
+		platform, err := strconv.ParseFloat(fields[3], 64)
+		if err != nil {
+			platform = -1
+		}
+		samples = append(samples, Sample{
+			Time:     time.Unix(epoch, 0),
+			Interval: time.Duration(interval) * time.Second,
+			GPU:      gpu,
+			Platform: platform,
+		})
+	}
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].Time.Before(samples[j].Time) })
+	return samples
+}
+
+// Energy is the energy drawn over a span. Platform figures are zero when no sample had a
+// platform reading.
+type Energy struct {
+	GPUkWh       float64       `json:"gpu_kwh"`
+	PlatformkWh  float64       `json:"platform_kwh,omitempty"`
+	AvgGPU       float64       `json:"avg_gpu_watts"`
+	PeakGPU      float64       `json:"peak_gpu_watts"`
+	AvgPlatform  float64       `json:"avg_platform_watts,omitempty"`
+	PeakPlatform float64       `json:"peak_platform_watts,omitempty"`
+	Covered      time.Duration `json:"covered_ns"`
+	Samples      int           `json:"samples"`
+	platformSpan time.Duration
+}
+
+// Day is one local calendar day of a Report
+type Day struct {
+	Date string `json:"date"`
+	Energy
+}
+
+// Report estimates energy and cost over a window
+type Report struct {
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Total    Energy    `json:"total"`
+	Days     []Day     `json:"days"`
+	Rate     float64   `json:"rate,omitempty"`
+	Currency string    `json:"currency,omitempty"`
+}
+
+// Summarize integrates samples between from and to. Each sample counts until the next
+// one, or for one interval when the next is more than two intervals away, so gaps while
+// the DGX was off or the logger stopped are not filled in.
+func Summarize(samples []Sample, from, to time.Time) *Report {
+	r := &Report{From: from, To: to}
+	days := map[string]*Day{}
+	var order []string
+	for i, s := range samples {
+		if s.Time.Before(from) || s.Time.After(to) {
+			continue
+		}
+		span := s.Interval
+		if i+1 < len(samples) {
+			if next := samples[i+1].Time.Sub(s.Time); next <= 2*s.Interval {
+				span = next
+			}
+		}
+		span = min(span, to.Sub(s.Time))
+
+		date := s.Time.Local().Format("2006-01-02")
+		day, ok := days[date]
+		if !ok {
+			day = &Day{Date: date}
+			days[date] = day
+			order = append(order, date)
+		}
+		r.Total.add(s, span)
+		day.add(s, span)
+	}
+	r.Total.finish()
+	for _, date := range order {
+		days[date].finish()
+		r.Days = append(r.Days, *days[date])
+	}
+	return r
+}
+
+func (e *Energy) add(s Sample, span time.Duration) {
+	hours := span.Hours()
+	e.Samples++
+	e.Covered += span
+	e.GPUkWh += s.GPU * hours / 1000
+	e.PeakGPU = max(e.PeakGPU, s.GPU)
+	if s.Platform >= 0 {
+		e.PlatformkWh += s.Platform * hours / 1000
+		e.PeakPlatform = max(e.PeakPlatform, s.Platform)
+		e.platformSpan += span
+	}
+}
+
+func (e *Energy) finish() {
+	if e.Covered > 0 {
+		e.AvgGPU = e.GPUkWh * 1000 / e.Covered.Hours()
+	}
+	if e.platformSpan > 0 {
+		e.AvgPlatform = e.PlatformkWh * 1000 / e.platformSpan.Hours()
+	}
+}
+
+// KWh is the best energy estimate: the platform meter's when there is one, else the GPU's
+func (e Energy) KWh() float64 {
+	if e.PlatformkWh > 0 {
+		return e.PlatformkWh
+	}
+	return e.GPUkWh
+}
+
+// AvgWatts is the average draw matching KWh
+func (e Energy) AvgWatts() float64 {
+	if e.PlatformkWh > 0 {
+		return e.AvgPlatform
+	}
+	return e.AvgGPU
+}
+
+// MonthlyKWh projects the average draw over a 30-day month of continuous running
+func (e Energy) MonthlyKWh() float64 {
+	return e.AvgWatts() * 24 * 30 / 1000
+}
+
+// Cost prices e at the report's rate
+func (r *Report) Cost(e Energy) float64 {
+	return e.KWh() * r.Rate
+}
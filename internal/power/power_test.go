@@ -0,0 +1,51 @@
+package power
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseLog(t *testing.T) {
+	samples := ParseLog("1700000060,60,40.5,\n1700000000,60,30.0,95.2\ngarbage\n1700000120,60,,\n")
+	if len(samples) != 2 {
+		t.Fatalf("expected 2 samples, got %d", len(samples))
+	}
+	if samples[0].Time.Unix() != 1700000000 || samples[0].Platform != 95.2 {
+		t.Fatalf("unexpected first sample %+v", samples[0])
+	}
+	if samples[1].GPU != 40.5 || samples[1].Platform != -1 || samples[1].Interval != time.Minute {
+		t.Fatalf("unexpected second sample %+v", samples[1])
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	start := time.Date(2025, 10, 14, 10, 0, 0, 0, time.Local)
+	var samples []Sample
+	// One hour at 100 W, then a three-hour gap, then one hour at 50 W
+	for i := 0; i < 60; i++ {
+		samples = append(samples, Sample{Time: start.Add(time.Duration(i) * time.Minute), Interval: time.Minute, GPU: 100, Platform: -1})
+	}
+	for i := 0; i < 60; i++ {
+		samples = append(samples, Sample{Time: start.Add(4*time.Hour + time.Duration(i)*time.Minute), Interval: time.Minute, GPU: 50, Platform: -1})
+	}
+
+	r := Summarize(samples, start.Add(-time.Hour), start.Add(6*time.Hour))
+	if math.Abs(r.Total.GPUkWh-0.15) > 1e-9 {
+		t.Fatalf("expected 0.15 kWh, got %v", r.Total.GPUkWh)
+	}
+	if r.Total.Covered != 2*time.Hour || math.Abs(r.Total.AvgGPU-75) > 1e-9 || r.Total.PeakGPU != 100 {
+		t.Fatalf("unexpected totals %+v", r.Total)
+	}
+	if r.Total.PlatformkWh != 0 || r.Total.KWh() != r.Total.GPUkWh {
+		t.Fatalf("expected GPU energy without a power meter, got %+v", r.Total)
+	}
+	if len(r.Days) != 1 || r.Days[0].Samples != 120 {
+		t.Fatalf("unexpected days %+v", r.Days)
+	}
+
+	r.Rate = 0.2
+	if math.Abs(r.Cost(r.Total)-0.03) > 1e-9 {
+		t.Fatalf("unexpected cost %v", r.Cost(r.Total))
+	}
+}
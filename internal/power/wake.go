@@ -0,0 +1,57 @@
+package power
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+)
+
+// wolPort is the discard port Wake-on-LAN packets are conventionally sent to
+const wolPort = 9
+
+// MagicPacket returns the Wake-on-LAN packet for mac: six 0xff bytes, then the MAC sixteen
+// times
+func MagicPacket(mac string) ([]byte, error) {
+	hw, err := net.ParseMAC(mac)
+	if err != nil || len(hw) != 6 {
+		return nil, fmt.Errorf("invalid MAC address %q", mac)
+	}
+	packet := bytes.Repeat([]byte{0xff}, 6)
+	for range 16 {
+		packet = append(packet, hw...)
+	}
+	return packet, nil
+}
+
+// SendWake broadcasts a Wake-on-LAN packet for mac on the local network, and also sends it
+// to lastAddress when given, which reaches the DGX while its ARP entry is still cached
+func SendWake(mac, lastAddress string) error {
+	packet, err := MagicPacket(mac)
+	if err != nil {
+		return err
+	}
+	targets := []string{fmt.Sprintf("255.255.255.255:%d", wolPort)}
+	if lastAddress != "" {
+		targets = append(targets, net.JoinHostPort(lastAddress, fmt.Sprint(wolPort)))
+	}
+	var sent bool
+	var lastErr error
+	for _, target := range targets {
+		conn, err := net.Dial("udp4", target)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		_, err = conn.Write(packet)
+		conn.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		sent = true
+	}
+	if !sent {
+		return fmt.Errorf("failed to send the Wake-on-LAN packet: %w", lastErr)
+	}
+	return nil
+}
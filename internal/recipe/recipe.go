@@ -0,0 +1,243 @@
+// Package recipe holds the catalog of end-to-end recipes: named sequences of playbook
+// commands and shell steps with parameters, run by dgx recipes.
+package recipe
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultCatalogURL is where 'dgx recipes update' downloads the newest catalog: this
+// package's catalog.yaml on the main branch
+const DefaultCatalogURL = "https://raw.githubusercontent.com/jwjohns/dgx-spark-cli/main/internal/recipe/catalog.yaml"
+
+// CatalogFile is the name of the downloaded catalog in the recipes directory
+const CatalogFile = "catalog.yaml"
+
+//go:embed catalog.yaml
+var builtinCatalog []byte
+
+var (
+	namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+	// defaultValuePattern allows model references, dataset names, and paths, but nothing
+	// the shell treats specially
+	defaultValuePattern = regexp.MustCompile(`^[A-Za-z0-9._:/@+-]+$`)
+	placeholderPattern  = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_]+)\s*\}\}`)
+)
+
+// Recipe is an end-to-end workflow
+type Recipe struct {
+	Name        string  `yaml:"name"`
+	Description string  `yaml:"description"`
+	Params      []Param `yaml:"params,omitempty"`
+	Steps       []Step  `yaml:"steps"`
+	Notes       string  `yaml:"notes,omitempty"` // printed when the recipe finishes
+
+	// Source is where the recipe was loaded from: "built-in" or a file path
+	Source string `yaml:"-"`
+}
+
+// Param is a value the recipe asks for before it runs
+type Param struct {
+	Name    string `yaml:"name"`
+	Prompt  string `yaml:"prompt"`
+	Default string `yaml:"default,omitempty"`
+	Pattern string `yaml:"pattern,omitempty"` // regular expression the value must match
+}
+
+// Step is one playbook command (Run, as given to 'dgx run') or shell script run on the
+// DGX (Exec)
+type Step struct {
+	Name string `yaml:"name"`
+	Run  string `yaml:"run,omitempty"`
+	Exec string `yaml:"exec,omitempty"`
+}
+
+// Catalog is a recipes file
+type Catalog struct {
+	Recipes []Recipe `yaml:"recipes"`
+}
+
+// ParseCatalog reads and validates a catalog file's contents
+func ParseCatalog(data []byte, source string) ([]Recipe, error) {
+	var catalog Catalog
+	if err := yaml.Unmarshal(data, &catalog); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", source, err)
+	}
+	for i := range catalog.Recipes {
+		r := &catalog.Recipes[i]
+		r.Source = source
+		if err := r.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", source, err)
+		}
+	}
+	return catalog.Recipes, nil
+}
+
+// Validate checks that the recipe's steps are well formed and only use its parameters
+func (r *Recipe) Validate() error {
+	if !namePattern.MatchString(r.Name) {
+		return fmt.Errorf("invalid recipe name %q", r.Name)
+	}
+	if len(r.Steps) == 0 {
+		return fmt.Errorf("recipe %s has no steps", r.Name)
+	}
+	params := map[string]bool{}
+	for _, p := range r.Params {
+		if p.Name == "" || params[p.Name] {
+			return fmt.Errorf("recipe %s: missing or repeated parameter name %q", r.Name, p.Name)
+		}
+		params[p.Name] = true
+		if p.Pattern != "" {
+			if _, err := regexp.Compile(p.Pattern); err != nil {
+				return fmt.Errorf("recipe %s: parameter %s: invalid pattern: %w", r.Name, p.Name, err)
+			}
+		}
+		if p.Default != "" {
+			if err := p.Check(p.Default); err != nil {
+				return fmt.Errorf("recipe %s: default of %w", r.Name, err)
+			}
+		}
+	}
+	for i, s := range r.Steps {
+		if (s.Run == "") == (s.Exec == "") {
+			return fmt.Errorf("recipe %s: step %d needs exactly one of run and exec", r.Name, i+1)
+		}
+		for _, match := range placeholderPattern.FindAllStringSubmatch(s.Run+s.Exec, -1) {
+			if !params[match[1]] {
+				return fmt.Errorf("recipe %s: step %d uses undefined parameter %s", r.Name, i+1, match[1])
+			}
+		}
+	}
+	return nil
+}
+
+// Check validates a value for the parameter
+func (p Param) Check(value string) error {
+	pattern := defaultValuePattern
+	if p.Pattern != "" {
+		pattern = regexp.MustCompile(p.Pattern)
+	}
+	if !pattern.MatchString(value) {
+		return fmt.Errorf("%s: invalid value %q", p.Name, value)
+	}
+	return nil
+}
+
+// HasExec reports whether the recipe runs shell scripts of its own
+func (r *Recipe) HasExec() bool {
+	for _, s := range r.Steps {
+		if s.Exec != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// RunArgs returns a run step's playbook and arguments with values filled in. Values are
+// substituted after splitting, so each stays one argument.
+func (s Step) RunArgs(values map[string]string) []string {
+	fields := strings.Fields(s.Run)
+	for i, f := range fields {
+		fields[i] = expand(f, values, false)
+	}
+	return fields
+}
+
+// Script returns an exec step's script with values filled in, shell-quoted
+func (s Step) Script(values map[string]string) string {
+	return expand(s.Exec, values, true)
+}
+
+// ExpandNotes returns the notes with values filled in as they are
+func (r *Recipe) ExpandNotes(values map[string]string) string {
+	return expand(r.Notes, values, false)
+}
+
+func expand(text string, values map[string]string, quote bool) string {
+	return placeholderPattern.ReplaceAllStringFunc(text, func(m string) string {
+		value := values[placeholderPattern.FindStringSubmatch(m)[1]]
+		if quote {
+			return ssh.ShellQuote(value)
+		}
+		return value
+	})
+}
+
+// DefaultDir returns ~/.config/dgx/recipes, which holds the downloaded catalog and
+// recipes of your own
+func DefaultDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".config", "dgx", "recipes"), nil
+}
+
+// Load returns the recipes sorted by name: the built-in catalog, updated from the
+// downloaded catalog in dir when there is one, then the other *.yaml files in dir. Later
+// recipes replace earlier ones of the same name.
+func Load(dir string) ([]Recipe, error) {
+	recipes, err := ParseCatalog(builtinCatalog, "built-in")
+	if err != nil {
+		return nil, err
+	}
+	byName := map[string]Recipe{}
+	add := func(list []Recipe) {
+		for _, r := range list {
+			byName[r.Name] = r
+		}
+	}
+	add(recipes)
+
+	if dir != "" {
+		files, _ := filepath.Glob(filepath.Join(dir, "*.yaml"))
+		sort.Slice(files, func(i, j int) bool {
+			// The downloaded catalog first, so local recipes win
+			if (filepath.Base(files[i]) == CatalogFile) != (filepath.Base(files[j]) == CatalogFile) {
+				return filepath.Base(files[i]) == CatalogFile
+			}
+			return files[i] < files[j]
+		})
+		for _, path := range files {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			list, err := ParseCatalog(data, path)
+			if err != nil {
+				return nil, err
+			}
+			add(list)
+		}
+	}
+
+	result := make([]Recipe, 0, len(byName))
+	for _, r := range byName {
+		result = append(result, r)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// Find returns the named recipe
+func Find(recipes []Recipe, name string) (*Recipe, error) {
+	for i := range recipes {
+		if recipes[i].Name == name {
+			return &recipes[i], nil
+		}
+	}
+	names := make([]string, len(recipes))
+	for i, r := range recipes {
+		names[i] = r.Name
+	}
+	return nil, fmt.Errorf("unknown recipe %q (available: %s)", name, strings.Join(names, ", "))
+}
@@ -0,0 +1,79 @@
+package recipe
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinCatalog(t *testing.T) {
+	recipes, err := ParseCatalog(builtinCatalog, "built-in")
+	if err != nil {
+		t.Fatalf("built-in catalog: %v", err)
+	}
+	for _, name := range []string{"llama-70b-vllm", "qwen-lora", "comfyui"} {
+		if _, err := Find(recipes, name); err != nil {
+			t.Fatalf("built-in catalog: %v", err)
+		}
+	}
+}
+
+func TestLoadOverrides(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, CatalogFile), []byte(`recipes:
+  - name: comfyui
+    description: downloaded
+    steps: [{name: start, run: sdgen deploy}]
+`), 0644)
+	os.WriteFile(filepath.Join(dir, "a-mine.yaml"), []byte(`recipes:
+  - name: comfyui
+    description: mine
+    steps: [{name: start, run: sdgen deploy --ui comfyui}]
+  - name: hello
+    description: local
+    steps: [{name: hi, exec: echo hi}]
+`), 0644)
+
+	recipes, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	comfy, _ := Find(recipes, "comfyui")
+	if comfy == nil || comfy.Description != "mine" {
+		t.Fatalf("local recipe should replace the catalog's: %+v", comfy)
+	}
+	if r, err := Find(recipes, "hello"); err != nil || !r.HasExec() {
+		t.Fatalf("Find(hello) = %+v, %v", r, err)
+	}
+	if _, err := Find(recipes, "qwen-lora"); err != nil {
+		t.Fatalf("built-in recipes should remain: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	bad := []Recipe{
+		{Name: "x"},
+		{Name: "x", Steps: []Step{{Name: "s"}}},
+		{Name: "x", Steps: []Step{{Name: "s", Run: "vllm serve {{model}}"}}},
+		{Name: "x", Params: []Param{{Name: "m", Default: "a b"}}, Steps: []Step{{Name: "s", Run: "vllm serve {{m}}"}}},
+		{Name: "Bad Name", Steps: []Step{{Name: "s", Run: "vllm pull"}}},
+	}
+	for _, r := range bad {
+		if err := r.Validate(); err == nil {
+			t.Fatalf("Validate accepted %+v", r)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	values := map[string]string{"model": "org/m", "split": "train[:10%]"}
+	step := Step{Run: "vllm serve {{model}}"}
+	if args := step.RunArgs(values); strings.Join(args, "|") != "vllm|serve|org/m" {
+		t.Fatalf("RunArgs = %q", args)
+	}
+	step = Step{Exec: "python t.py {{ model }} {{split}}"}
+	if got := step.Script(values); got != "python t.py 'org/m' 'train[:10%]'" {
+		t.Fatalf("Script = %q", got)
+	}
+}
@@ -0,0 +1,121 @@
+package redact
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+)
+
+// maxPending bounds how much of an unterminated line a Writer holds back
+const maxPending = 64 << 10
+
+var (
+	keyBegin   = []byte("-----BEGIN ")
+	keyEnd     = []byte("-----END ")
+	privateKey = []byte("PRIVATE KEY-----")
+)
+
+// Writer masks secrets in text on its way to another writer. Output is passed on a line at
+// a time (a carriage return also ends a line, for progress bars) so a secret split across
+// writes is still recognized; a private key block is held until its END line. Call Flush
+// when the output is complete.
+type Writer struct {
+	mu      sync.Mutex
+	w       io.Writer
+	r       *Redactor
+	pending []byte
+	idle    time.Duration
+	timer   *time.Timer
+}
+
+// NewWriter returns a writer that redacts with r before writing to w
+func NewWriter(w io.Writer, r *Redactor) *Writer {
+	return &Writer{w: w, r: r}
+}
+
+// FlushIdle makes w pass on a partial line once output pauses for idle, so prompts without
+// a newline, such as sudo's password prompt, still show. It returns w.
+func (w *Writer) FlushIdle(idle time.Duration) *Writer {
+	w.idle = idle
+	return w
+}
+
+// Write buffers p and writes out every complete line, redacted
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	defer w.scheduleIdle()
+
+	w.pending = append(w.pending, p...)
+	cut := bytes.LastIndexAny(w.pending, "\r\n") + 1
+	if open := openKeyBlock(w.pending[:cut]); open >= 0 {
+		cut = open
+	}
+	if len(w.pending)-cut > maxPending {
+		cut = len(w.pending)
+	}
+	if cut == 0 {
+		return len(p), nil
+	}
+	if _, err := w.w.Write(w.r.Bytes(w.pending[:cut])); err != nil {
+		return 0, err
+	}
+	w.pending = append(w.pending[:0], w.pending[cut:]...)
+	return len(p), nil
+}
+
+// scheduleIdle restarts the idle flush while a partial line is held
+func (w *Writer) scheduleIdle() {
+	if w.idle <= 0 || len(w.pending) == 0 {
+		return
+	}
+	if w.timer == nil {
+		w.timer = time.AfterFunc(w.idle, w.flushIdle)
+	} else {
+		w.timer.Reset(w.idle)
+	}
+}
+
+// flushIdle writes out a held partial line, unless it may be part of a private key
+func (w *Writer) flushIdle() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.pending) == 0 || bytes.Contains(w.pending, keyBegin) {
+		return
+	}
+	w.w.Write(w.r.Bytes(w.pending))
+	w.pending = w.pending[:0]
+}
+
+// Flush writes out whatever is still buffered
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	if len(w.pending) == 0 {
+		return nil
+	}
+	_, err := w.w.Write(w.r.Bytes(w.pending))
+	w.pending = w.pending[:0]
+	return err
+}
+
+// openKeyBlock returns the start of the line that begins a private key block without an
+// END line after it, or -1
+func openKeyBlock(b []byte) int {
+	begin := bytes.LastIndex(b, keyBegin)
+	if begin < 0 || bytes.Contains(b[begin:], keyEnd) {
+		return -1
+	}
+	line := b[begin:]
+	if end := bytes.IndexByte(line, '\n'); end >= 0 {
+		line = line[:end]
+	}
+	if !bytes.Contains(line, privateKey) {
+		return -1
+	}
+	return bytes.LastIndexByte(b[:begin], '\n') + 1
+}
@@ -0,0 +1,169 @@
+// Package registry manages docker registry logins on the DGX for dgx registry. Credentials
+// are kept in the dgx env store on the DGX and passed to docker login on stdin there, so
+// they never appear on a command line or travel back to the laptop.
+package registry
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/tracking"
+)
+
+var (
+	hostPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9.-]*[a-z0-9])?(:[0-9]{1,5})?$`)
+	// storedPattern matches the host variables of stored credentials in the env store
+	storedPattern = regexp.MustCompile(`^export DGX_REGISTRY_[A-Z0-9_]+_HOST=(\S+)$`)
+)
+
+// Registry is a registry the DGX can log in to, and where its credentials are stored
+type Registry struct {
+	Host        string
+	HostVar     string // records the host, for listing
+	UserVar     string
+	PasswordVar string
+	// DefaultUser and FallbackVar cover registries with a fixed user name, such as NGC,
+	// whose API key may already be in the env store under its usual name
+	DefaultUser string
+	FallbackVar string
+}
+
+// Parse returns the registry for host, such as ghcr.io or registry.lab:5000
+func Parse(host string) (Registry, error) {
+	host = strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(host), "https://"), "http://"), "/")
+	if !hostPattern.MatchString(host) {
+		return Registry{}, fmt.Errorf("invalid registry %q: give a host name such as ghcr.io or registry.lab:5000", host)
+	}
+	r := Registry{
+		Host:        host,
+		HostVar:     "DGX_REGISTRY_" + varName(host) + "_HOST",
+		UserVar:     "DGX_REGISTRY_" + varName(host) + "_USERNAME",
+		PasswordVar: "DGX_REGISTRY_" + varName(host) + "_PASSWORD",
+	}
+	if host == "nvcr.io" {
+		r.DefaultUser, r.FallbackVar = "$oauthtoken", "NGC_API_KEY"
+	}
+	return r, nil
+}
+
+// varName turns a host into the part of a variable name that identifies it
+func varName(host string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, host)
+}
+
+// timerUnit is the transient systemd user unit that logs out of the registry after --ttl
+func (r Registry) timerUnit() string {
+	return "dgx-registry-logout-" + strings.ToLower(varName(r.Host))
+}
+
+// credentials is shell that loads the env store into $user and $pass, failing when no
+// password is stored
+func (r Registry) credentials() string {
+	pass := fmt.Sprintf("${%s:-}", r.PasswordVar)
+	if r.FallbackVar != "" {
+		pass = fmt.Sprintf("${%s:-${%s:-}}", r.PasswordVar, r.FallbackVar)
+	}
+	return fmt.Sprintf(`[ -f %[1]s ] && . %[1]s
+user="${%[2]s:-%[3]s}"
+pass="%[4]s"
+if [ -z "$pass" ] || [ -z "$user" ]; then
+  echo "No credentials for %[5]s on the DGX; store them with: dgx registry set %[5]s" >&2
+  exit 1
+fi
+`, tracking.EnvFile, r.UserVar, strings.ReplaceAll(r.DefaultUser, "$", `\$`), pass, r.Host)
+}
+
+// LoginScript returns a remote script that logs docker in to the registry with the stored
+// credentials. With a ttl, a systemd user timer logs out again when it passes.
+func LoginScript(r Registry, ttl time.Duration) string {
+	var s strings.Builder
+	s.WriteString(r.credentials())
+	fmt.Fprintf(&s, "printf '%%s' \"$pass\" | docker login --username \"$user\" --password-stdin %s || exit\n", ssh.ShellQuote(r.Host))
+	fmt.Fprintf(&s, "systemctl --user stop %s.timer 2>/dev/null || true\n", r.timerUnit())
+	if ttl > 0 {
+		fmt.Fprintf(&s, "systemd-run --user --quiet --collect --unit=%s --on-active=%d docker logout %s >/dev/null &&\n  echo 'Logs out in %s'\n",
+			r.timerUnit(), int(ttl.Seconds()), ssh.ShellQuote(r.Host), strings.TrimSuffix(strings.TrimSuffix(ttl.String(), "0s"), "0m"))
+	}
+	return s.String()
+}
+
+// RunScript returns a remote script that logs in, runs command, and logs out again
+// however the command ends, exiting with its status
+func RunScript(r Registry, command string) string {
+	var s strings.Builder
+	s.WriteString(r.credentials())
+	fmt.Fprintf(&s, "printf '%%s' \"$pass\" | docker login --username \"$user\" --password-stdin %s >/dev/null || exit\n", ssh.ShellQuote(r.Host))
+	fmt.Fprintf(&s, "unset pass\ntrap 'docker logout %s >/dev/null' EXIT\n", ssh.ShellQuote(r.Host))
+	fmt.Fprintf(&s, "%s\n", command)
+	return s.String()
+}
+
+// LogoutScript returns a remote script that logs docker out of the registry and cancels a
+// pending timed logout
+func LogoutScript(r Registry) string {
+	return fmt.Sprintf("systemctl --user stop %s.timer 2>/dev/null\ndocker logout %s\n", r.timerUnit(), ssh.ShellQuote(r.Host))
+}
+
+// ListScript returns a remote script that prints the env store followed by the registries
+// docker is logged in to, one per line after a separator
+func ListScript() string {
+	return fmt.Sprintf(`cat %s 2>/dev/null
+echo ===
+python3 -c 'import json,os; print("\n".join(json.load(open(os.path.expanduser("~/.docker/config.json"))).get("auths", {})))' 2>/dev/null
+true
+`, tracking.EnvFile)
+}
+
+// Status is a registry known to the DGX
+type Status struct {
+	Host     string `json:"registry"`
+	Stored   bool   `json:"credentials_stored"`
+	LoggedIn bool   `json:"logged_in"`
+}
+
+// ParseList reads the output of ListScript
+func ParseList(output string) []Status {
+	store, logins, _ := strings.Cut(output, "===\n")
+	byHost := map[string]*Status{}
+	get := func(host string) *Status {
+		if byHost[host] == nil {
+			byHost[host] = &Status{Host: host}
+		}
+		return byHost[host]
+	}
+	for _, line := range strings.Split(store, "\n") {
+		line = strings.TrimSpace(line)
+		if m := storedPattern.FindStringSubmatch(line); m != nil {
+			get(strings.Trim(m[1], `'"`)).Stored = true
+		} else if strings.HasPrefix(line, "export NGC_API_KEY=") {
+			get("nvcr.io").Stored = true
+		}
+	}
+	for _, line := range strings.Split(logins, "\n") {
+		host := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(line), "https://"), "http://"), "/")
+		if host == "index.docker.io/v1" {
+			host = "docker.io"
+		}
+		if host != "" {
+			get(host).LoggedIn = true
+		}
+	}
+	result := make([]Status, 0, len(byHost))
+	for _, s := range byHost {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Host < result[j].Host })
+	return result
+}
@@ -0,0 +1,88 @@
+package registry
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	r, err := Parse("https://Registry.Lab:5000/")
+	if err != nil || r.Host != "registry.lab:5000" || r.PasswordVar != "DGX_REGISTRY_REGISTRY_LAB_5000_PASSWORD" {
+		t.Fatalf("Parse = %+v, %v", r, err)
+	}
+	for _, bad := range []string{"", "ghcr.io/acme", "-x.io", "a b"} {
+		if _, err := Parse(bad); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+// fakeDocker puts a docker on PATH that logs its arguments and stdin to calls
+func fakeDocker(t *testing.T) (env []string, calls string) {
+	home, bin := t.TempDir(), t.TempDir()
+	calls = filepath.Join(home, "calls")
+	script := "#!/bin/sh\necho \"$*\" >> " + calls + "\n[ \"$1\" = login ] && { cat >> " + calls + "; echo >> " + calls + "; }\nexit 0\n"
+	if err := os.WriteFile(filepath.Join(bin, "docker"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(home, ".config", "dgx"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	store := "export DGX_REGISTRY_GHCR_IO_HOST=ghcr.io\nexport DGX_REGISTRY_GHCR_IO_USERNAME=octocat\nexport DGX_REGISTRY_GHCR_IO_PASSWORD='s3cr$t'\nexport NGC_API_KEY=nvapi-123\n"
+	if err := os.WriteFile(filepath.Join(home, ".config", "dgx", "env.sh"), []byte(store), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return []string{"HOME=" + home, "PATH=" + bin + ":/usr/bin:/bin"}, calls
+}
+
+func TestScripts(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not installed")
+	}
+	env, calls := fakeDocker(t)
+	run := func(script string) (string, error) {
+		c := exec.Command("sh", "-c", script)
+		c.Env = env
+		out, err := c.CombinedOutput()
+		return string(out), err
+	}
+
+	ghcr, _ := Parse("ghcr.io")
+	if out, err := run(LoginScript(ghcr, 0)); err != nil {
+		t.Fatalf("login: %v: %s", err, out)
+	}
+	ngc, _ := Parse("nvcr.io")
+	if out, err := run(RunScript(ngc, "docker pull nvcr.io/nvidia/pytorch:25.09-py3; exit 3")); err == nil {
+		t.Fatalf("expected the command's exit status, got success: %s", out)
+	}
+	data, _ := os.ReadFile(calls)
+	want := "login --username octocat --password-stdin ghcr.io\ns3cr$t\n" +
+		"login --username $oauthtoken --password-stdin nvcr.io\nnvapi-123\n" +
+		"pull nvcr.io/nvidia/pytorch:25.09-py3\n" +
+		"logout nvcr.io\n"
+	if string(data) != want {
+		t.Fatalf("docker calls:\n%s\nwant:\n%s", data, want)
+	}
+
+	lab, _ := Parse("registry.lab:5000")
+	if out, err := run(LoginScript(lab, time.Hour)); err == nil || !strings.Contains(out, "dgx registry set registry.lab:5000") {
+		t.Fatalf("login without credentials: %v: %s", err, out)
+	}
+}
+
+func TestParseList(t *testing.T) {
+	output := "export HF_TOKEN=hf_x\nexport DGX_REGISTRY_GHCR_IO_HOST=ghcr.io\nexport DGX_REGISTRY_GHCR_IO_PASSWORD=x\nexport NGC_API_KEY=y\n===\nghcr.io\nhttps://index.docker.io/v1/\n"
+	want := []Status{
+		{Host: "docker.io", LoggedIn: true},
+		{Host: "ghcr.io", Stored: true, LoggedIn: true},
+		{Host: "nvcr.io", Stored: true},
+	}
+	if got := ParseList(output); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ParseList = %+v, want %+v", got, want)
+	}
+}
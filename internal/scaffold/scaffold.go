@@ -0,0 +1,130 @@
+// Package scaffold creates new local projects from built-in templates set up for running
+// on the DGX with dgx, for dgx new.
+package scaffold
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"text/template"
+)
+
+// DefaultImage is the base image of the PyTorch template: NGC PyTorch, built for arm64
+const DefaultImage = "nvcr.io/nvidia/pytorch:25.09-py3"
+
+//go:embed all:templates
+var templates embed.FS
+
+// namePattern is what docker allows in an image name, which the project name becomes
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,62}$`)
+
+// Template is a kind of project dgx new can create
+type Template struct {
+	Name        string
+	Description string
+}
+
+// Templates lists the built-in templates
+var Templates = []Template{
+	{Name: "pytorch-project", Description: "PyTorch training on the NGC container, with a compose file and Makefile for remote runs"},
+}
+
+// Params fill in a template
+type Params struct {
+	Name  string // project name, also the image and job name
+	Image string // base image
+}
+
+// Find returns the template called name
+func Find(name string) (Template, error) {
+	for _, t := range Templates {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+	return Template{}, fmt.Errorf("unknown template %q (see 'dgx new' for the list)", name)
+}
+
+// ValidateName checks a project name
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid project name %q: use lowercase letters, digits, dots, dashes, and underscores", name)
+	}
+	return nil
+}
+
+// Create writes the template's files into dir, creating it, and returns the paths written
+// relative to dir. Files that already exist are left alone and reported as an error
+// unless force is set.
+func Create(t Template, dir string, p Params, force bool) ([]string, error) {
+	if err := ValidateName(p.Name); err != nil {
+		return nil, err
+	}
+	if p.Image == "" {
+		p.Image = DefaultImage
+	}
+	files, err := Render(t, p)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if !force {
+		for _, name := range names {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				return nil, fmt.Errorf("%s already exists (use --force to overwrite)", filepath.Join(dir, name))
+			}
+		}
+	}
+	for _, name := range names {
+		target := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", filepath.Dir(target), err)
+		}
+		if err := os.WriteFile(target, files[name], 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", target, err)
+		}
+	}
+	return names, nil
+}
+
+// Render returns the template's files, by path relative to the project directory, with
+// the parameters filled in
+func Render(t Template, p Params) (map[string][]byte, error) {
+	root := path.Join("templates", t.Name)
+	files := map[string][]byte{}
+	err := fs.WalkDir(templates, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := templates.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		rel := name[len(root)+1:]
+		tmpl, err := template.New(rel).Option("missingkey=error").Parse(string(content))
+		if err != nil {
+			return fmt.Errorf("template %s: %w", rel, err)
+		}
+		var b bytes.Buffer
+		if err := tmpl.Execute(&b, p); err != nil {
+			return fmt.Errorf("template %s: %w", rel, err)
+		}
+		files[rel] = b.Bytes()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
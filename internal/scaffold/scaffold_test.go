@@ -0,0 +1,48 @@
+package scaffold
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderPyTorchProject(t *testing.T) {
+	tmpl, err := Find("pytorch-project")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	files, err := Render(tmpl, Params{Name: "mnist", Image: DefaultImage})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for name, want := range map[string]string{
+		"Dockerfile":    "FROM " + DefaultImage,
+		"compose.yaml":  "image: mnist:latest",
+		"Makefile":      "\tdgx git push-run -- docker compose run --rm --build train",
+		".dockerignore": "outputs/",
+		"train.py":      "Minimal training loop for mnist",
+	} {
+		if !strings.Contains(string(files[name]), want) {
+			t.Fatalf("%s missing %q:\n%s", name, want, files[name])
+		}
+	}
+}
+
+func TestCreateKeepsExistingFiles(t *testing.T) {
+	tmpl, _ := Find("pytorch-project")
+	dir := filepath.Join(t.TempDir(), "demo")
+	written, err := Create(tmpl, dir, Params{Name: "demo"}, false)
+	if err != nil || len(written) == 0 {
+		t.Fatalf("Create = %v, %v", written, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".gitignore")); err != nil {
+		t.Fatalf("dotfiles not written: %v", err)
+	}
+	if _, err := Create(tmpl, dir, Params{Name: "demo"}, false); err == nil {
+		t.Fatalf("expected an error for existing files")
+	}
+	if _, err := Create(tmpl, dir, Params{Name: "Demo Project"}, true); err == nil {
+		t.Fatalf("expected an invalid name to be rejected")
+	}
+}
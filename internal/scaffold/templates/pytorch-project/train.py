@@ -0,0 +1,45 @@
+"""Minimal training loop for {{.Name}}: fits a small MLP on synthetic data on the GPU.
+
+Replace the model and data with your own; checkpoints written to outputs/ can be
+copied back with 'make pull'.
+"""
+
+import os
+import time
+
+import torch
+from torch import nn
+
+
+def main():
+    device = "cuda" if torch.cuda.is_available() else "cpu"
+    if device == "cuda":
+        print(f"GPU: {torch.cuda.get_device_name()} (CUDA {torch.version.cuda})")
+    else:
+        print("Warning: CUDA is not available, training on the CPU")
+
+    torch.manual_seed(0)
+    x = torch.randn(65536, 256, device=device)
+    y = (x[:, :8].sum(dim=1, keepdim=True) > 0).float()
+    model = nn.Sequential(nn.Linear(256, 1024), nn.GELU(), nn.Linear(1024, 1)).to(device)
+    optimizer = torch.optim.AdamW(model.parameters(), lr=1e-3)
+    loss_fn = nn.BCEWithLogitsLoss()
+
+    epochs = int(os.environ.get("EPOCHS", "5"))
+    for epoch in range(1, epochs + 1):
+        start = time.time()
+        for batch in range(0, len(x), 4096):
+            optimizer.zero_grad(set_to_none=True)
+            with torch.autocast(device, dtype=torch.bfloat16):
+                loss = loss_fn(model(x[batch : batch + 4096]), y[batch : batch + 4096])
+            loss.backward()
+            optimizer.step()
+        print(f"epoch {epoch}/{epochs}  loss {loss.item():.4f}  {time.time() - start:.2f}s")
+
+    os.makedirs("outputs", exist_ok=True)
+    torch.save(model.state_dict(), "outputs/model.pt")
+    print("Saved outputs/model.pt")
+
+
+if __name__ == "__main__":
+    main()
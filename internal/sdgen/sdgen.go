@@ -0,0 +1,340 @@
+// Package sdgen talks to the image generation server deployed by 'dgx run sdgen', either
+// ComfyUI or the AUTOMATIC1111 Stable Diffusion web UI
+package sdgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/state"
+)
+
+// Supported servers
+const (
+	UIComfy  = "comfyui"
+	UIWebUI  = "sd-webui"
+	pollWait = 500 * time.Millisecond
+)
+
+// UIs lists the servers 'dgx run sdgen deploy' can stand up
+var UIs = []string{UIComfy, UIWebUI}
+
+// ContainerName is the container running the server on the DGX
+const ContainerName = "dgx-sdgen"
+
+// DefaultPort returns the port a server listens on unless told otherwise
+func DefaultPort(ui string) int {
+	if ui == UIWebUI {
+		return 7860
+	}
+	return 8188
+}
+
+// ReadyPath is a GET endpoint that answers once the server is up
+func ReadyPath(ui string) string {
+	if ui == UIWebUI {
+		return "/sdapi/v1/sd-models"
+	}
+	return "/system_stats"
+}
+
+// Deployment records which server runs on a DGX, so 'dgx imagine' knows how to reach it
+type Deployment struct {
+	UI         string `json:"ui"`
+	Port       int    `json:"port"`
+	Checkpoint string `json:"checkpoint,omitempty"`
+}
+
+// StateKey is the local state document holding the deployment on host
+func StateKey(host string) string {
+	return state.Key("sdgen", host)
+}
+
+// Request describes one text-to-image generation. Zero values take the defaults below.
+type Request struct {
+	Prompt     string
+	Negative   string
+	Width      int
+	Height     int
+	Steps      int
+	CFG        float64
+	Seed       int64
+	Checkpoint string
+}
+
+// Generation defaults, suited to SDXL
+const (
+	DefaultSize  = 1024
+	DefaultSteps = 30
+	DefaultCFG   = 7.0
+)
+
+func (r Request) withDefaults() Request {
+	if r.Width == 0 {
+		r.Width = DefaultSize
+	}
+	if r.Height == 0 {
+		r.Height = DefaultSize
+	}
+	if r.Steps == 0 {
+		r.Steps = DefaultSteps
+	}
+	if r.CFG == 0 {
+		r.CFG = DefaultCFG
+	}
+	return r
+}
+
+// Client generates images through a server's HTTP API
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	ui         string
+}
+
+// NewClient creates a client for the server of kind ui at baseURL
+func NewClient(httpClient *http.Client, baseURL, ui string) *Client {
+	return &Client{httpClient: httpClient, baseURL: strings.TrimSuffix(baseURL, "/"), ui: ui}
+}
+
+// Detect reports which kind of server answers at baseURL
+func Detect(ctx context.Context, httpClient *http.Client, baseURL string) (string, error) {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	var lastErr error
+	for _, ui := range UIs {
+		c := NewClient(httpClient, baseURL, ui)
+		if lastErr = c.get(ctx, ReadyPath(ui), nil); lastErr == nil {
+			return ui, nil
+		}
+	}
+	return "", fmt.Errorf("no image generation server at %s: %w", baseURL, lastErr)
+}
+
+// Checkpoints lists the model files the server can load
+func (c *Client) Checkpoints(ctx context.Context) ([]string, error) {
+	if c.ui == UIWebUI {
+		var models []struct {
+			Title string `json:"title"`
+		}
+		if err := c.get(ctx, "/sdapi/v1/sd-models", &models); err != nil {
+			return nil, err
+		}
+		names := make([]string, len(models))
+		for i, m := range models {
+			names[i] = m.Title
+		}
+		return names, nil
+	}
+	var names []string
+	if err := c.get(ctx, "/models/checkpoints", &names); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// Generate renders the request and returns the image as PNG
+func (c *Client) Generate(ctx context.Context, req Request) ([]byte, error) {
+	req = req.withDefaults()
+	if c.ui == UIWebUI {
+		return c.generateWebUI(ctx, req)
+	}
+	return c.generateComfy(ctx, req)
+}
+
+func (c *Client) generateWebUI(ctx context.Context, req Request) ([]byte, error) {
+	payload := map[string]interface{}{
+		"prompt":          req.Prompt,
+		"negative_prompt": req.Negative,
+		"width":           req.Width,
+		"height":          req.Height,
+		"steps":           req.Steps,
+		"cfg_scale":       req.CFG,
+		"seed":            req.Seed,
+	}
+	if req.Checkpoint != "" {
+		payload["override_settings"] = map[string]string{"sd_model_checkpoint": req.Checkpoint}
+	}
+	var resp struct {
+		Images []string `json:"images"`
+	}
+	if err := c.post(ctx, "/sdapi/v1/txt2img", payload, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Images) == 0 {
+		return nil, fmt.Errorf("the server returned no image")
+	}
+	data := resp.Images[0]
+	if _, after, ok := strings.Cut(data, "base64,"); ok {
+		data = after
+	}
+	image, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the image: %w", err)
+	}
+	return image, nil
+}
+
+// ComfyWorkflow builds a ComfyUI API-format text-to-image graph ending in a SaveImage node
+// with id "9"
+func ComfyWorkflow(req Request) map[string]interface{} {
+	node := func(class string, inputs map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"class_type": class, "inputs": inputs}
+	}
+	link := func(id string, output int) []interface{} { return []interface{}{id, output} }
+	return map[string]interface{}{
+		"3": node("KSampler", map[string]interface{}{
+			"seed": req.Seed, "steps": req.Steps, "cfg": req.CFG, "sampler_name": "euler", "scheduler": "normal",
+			"denoise": 1, "model": link("4", 0), "positive": link("6", 0), "negative": link("7", 0), "latent_image": link("5", 0),
+		}),
+		"4": node("CheckpointLoaderSimple", map[string]interface{}{"ckpt_name": req.Checkpoint}),
+		"5": node("EmptyLatentImage", map[string]interface{}{"width": req.Width, "height": req.Height, "batch_size": 1}),
+		"6": node("CLIPTextEncode", map[string]interface{}{"text": req.Prompt, "clip": link("4", 1)}),
+		"7": node("CLIPTextEncode", map[string]interface{}{"text": req.Negative, "clip": link("4", 1)}),
+		"8": node("VAEDecode", map[string]interface{}{"samples": link("3", 0), "vae": link("4", 2)}),
+		"9": node("SaveImage", map[string]interface{}{"filename_prefix": "dgx-imagine", "images": link("8", 0)}),
+	}
+}
+
+// comfyHistory is the part of a ComfyUI /history entry that tells how a prompt ended
+type comfyHistory struct {
+	Status struct {
+		StatusStr string            `json:"status_str"`
+		Completed bool              `json:"completed"`
+		Messages  []json.RawMessage `json:"messages"`
+	} `json:"status"`
+	Outputs map[string]struct {
+		Images []struct {
+			Filename  string `json:"filename"`
+			Subfolder string `json:"subfolder"`
+			Type      string `json:"type"`
+		} `json:"images"`
+	} `json:"outputs"`
+}
+
+func (c *Client) generateComfy(ctx context.Context, req Request) ([]byte, error) {
+	if req.Checkpoint == "" {
+		names, err := c.Checkpoints(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("ComfyUI has no checkpoints; deploy with --checkpoint")
+		}
+		req.Checkpoint = names[0]
+	}
+
+	var queued struct {
+		PromptID string `json:"prompt_id"`
+	}
+	if err := c.post(ctx, "/prompt", map[string]interface{}{"prompt": ComfyWorkflow(req)}, &queued); err != nil {
+		return nil, err
+	}
+
+	for {
+		var history map[string]comfyHistory
+		if err := c.get(ctx, "/history/"+url.PathEscape(queued.PromptID), &history); err != nil {
+			return nil, err
+		}
+		if entry, ok := history[queued.PromptID]; ok {
+			if entry.Status.StatusStr == "error" {
+				return nil, fmt.Errorf("ComfyUI failed to run the prompt: %s", comfyError(entry.Status.Messages))
+			}
+			if out := entry.Outputs["9"]; len(out.Images) > 0 {
+				img := out.Images[0]
+				query := url.Values{"filename": {img.Filename}, "subfolder": {img.Subfolder}, "type": {img.Type}}
+				return c.download(ctx, "/view?"+query.Encode())
+			}
+			if entry.Status.Completed {
+				return nil, fmt.Errorf("ComfyUI finished without an image")
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pollWait):
+		}
+	}
+}
+
+// comfyError picks the exception message out of ComfyUI status messages, which are
+// [name, details] pairs
+func comfyError(messages []json.RawMessage) string {
+	for _, raw := range messages {
+		var msg []json.RawMessage
+		if json.Unmarshal(raw, &msg) != nil || len(msg) != 2 {
+			continue
+		}
+		var details struct {
+			Message string `json:"exception_message"`
+		}
+		if json.Unmarshal(msg[1], &details) == nil && details.Message != "" {
+			return strings.TrimSpace(details.Message)
+		}
+	}
+	return "unknown error"
+}
+
+func (c *Client) get(ctx context.Context, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	return c.do(req, out)
+}
+
+func (c *Client) post(ctx context.Context, path string, payload, out interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.do(req, out)
+}
+
+// do sends req and decodes the JSON response into out, unless out is nil
+func (c *Client) do(req *http.Request, out interface{}) error {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach the image server: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("image server returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) download(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach the image server: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("image server returned %s for the image", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,105 @@
+package sdgen
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGenerateComfy(t *testing.T) {
+	var workflow map[string]map[string]interface{}
+	polls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/system_stats":
+			w.Write([]byte(`{}`))
+		case "/models/checkpoints":
+			w.Write([]byte(`["sd_xl_base_1.0.safetensors"]`))
+		case "/prompt":
+			var body struct {
+				Prompt map[string]map[string]interface{} `json:"prompt"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			workflow = body.Prompt
+			w.Write([]byte(`{"prompt_id":"p1"}`))
+		case "/history/p1":
+			if polls++; polls < 2 {
+				w.Write([]byte(`{}`))
+				return
+			}
+			w.Write([]byte(`{"p1":{"status":{"status_str":"success","completed":true},"outputs":{"9":{"images":[{"filename":"dgx-imagine_00001_.png","subfolder":"","type":"output"}]}}}}`))
+		case "/view":
+			if r.URL.Query().Get("filename") != "dgx-imagine_00001_.png" {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte("PNG"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	ui, err := Detect(context.Background(), srv.Client(), srv.URL)
+	if err != nil || ui != UIComfy {
+		t.Fatalf("Detect = %q, %v", ui, err)
+	}
+	image, err := NewClient(srv.Client(), srv.URL, ui).Generate(context.Background(), Request{Prompt: "a fox", Seed: 7})
+	if err != nil || string(image) != "PNG" {
+		t.Fatalf("Generate = %q, %v", image, err)
+	}
+	if got := workflow["4"]["inputs"].(map[string]interface{})["ckpt_name"]; got != "sd_xl_base_1.0.safetensors" {
+		t.Fatalf("checkpoint = %v, want the server's first", got)
+	}
+	sampler := workflow["3"]["inputs"].(map[string]interface{})
+	if sampler["seed"] != float64(7) || sampler["steps"] != float64(DefaultSteps) {
+		t.Fatalf("sampler inputs = %v", sampler)
+	}
+}
+
+func TestGenerateComfyError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/prompt":
+			w.Write([]byte(`{"prompt_id":"p1"}`))
+		case "/history/p1":
+			w.Write([]byte(`{"p1":{"status":{"status_str":"error","completed":false,"messages":[["execution_start",{}],["execution_error",{"exception_message":"CUDA out of memory\n"}]]},"outputs":{}}}`))
+		}
+	}))
+	defer srv.Close()
+
+	_, err := NewClient(srv.Client(), srv.URL, UIComfy).Generate(context.Background(), Request{Prompt: "x", Checkpoint: "a.safetensors"})
+	if err == nil || err.Error() != "ComfyUI failed to run the prompt: CUDA out of memory" {
+		t.Fatalf("Generate error = %v", err)
+	}
+}
+
+func TestGenerateWebUI(t *testing.T) {
+	var payload map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sdapi/v1/sd-models":
+			w.Write([]byte(`[{"title":"sd_xl_base_1.0.safetensors [31e35c80fc]"}]`))
+		case "/sdapi/v1/txt2img":
+			json.NewDecoder(r.Body).Decode(&payload)
+			w.Write([]byte(`{"images":["UE5H"]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	ui, err := Detect(context.Background(), srv.Client(), srv.URL)
+	if err != nil || ui != UIWebUI {
+		t.Fatalf("Detect = %q, %v", ui, err)
+	}
+	image, err := NewClient(srv.Client(), srv.URL, ui).Generate(context.Background(), Request{Prompt: "a fox", Width: 512, Checkpoint: "sd_xl_base_1.0.safetensors"})
+	if err != nil || string(image) != "PNG" {
+		t.Fatalf("Generate = %q, %v", image, err)
+	}
+	if payload["width"] != float64(512) || payload["height"] != float64(DefaultSize) || payload["override_settings"] == nil {
+		t.Fatalf("txt2img payload = %v", payload)
+	}
+}
@@ -0,0 +1,142 @@
+package serve
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// keyPrefix marks keys generated by GenerateKey
+const keyPrefix = "dgx-"
+
+// Authenticator checks bearer tokens and applies per-key rate limits.
+// With no keys configured every request is allowed.
+type Authenticator struct {
+	keys     []types.APIKey
+	mu       sync.Mutex
+	limiters map[string]*rateLimiter
+}
+
+// NewAuthenticator creates an authenticator for the configured keys
+func NewAuthenticator(keys []types.APIKey) *Authenticator {
+	a := &Authenticator{
+		keys:     keys,
+		limiters: make(map[string]*rateLimiter),
+	}
+	for _, k := range keys {
+		if k.RateLimit > 0 {
+			a.limiters[k.Name] = newRateLimiter(k.RateLimit, time.Now())
+		}
+	}
+	return a
+}
+
+// Enabled reports whether requests must present a key
+func (a *Authenticator) Enabled() bool {
+	return len(a.keys) > 0
+}
+
+// Wrap returns a handler that rejects unauthenticated or rate-limited requests before they
+// reach next. The matched key name is recorded for request logging.
+func (a *Authenticator) Wrap(next http.Handler) http.Handler {
+	if !a.Enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, ok := a.lookup(bearerToken(r))
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="dgx"`)
+			writeError(w, http.StatusUnauthorized, "missing or invalid API key")
+			return
+		}
+		infoFrom(r).Key = key.Name
+
+		if wait, ok := a.allow(key.Name, time.Now()); !ok {
+			w.Header().Set("Retry-After", fmt.Sprintf("%d", int(math.Ceil(wait.Seconds()))))
+			writeError(w, http.StatusTooManyRequests, fmt.Sprintf("rate limit of %d requests/minute exceeded for key %q", key.RateLimit, key.Name))
+			return
+		}
+
+		// The proxy's keys are not meant for the backends
+		r.Header.Del("Authorization")
+		r.Header.Del("X-Api-Key")
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *Authenticator) lookup(token string) (types.APIKey, bool) {
+	if token == "" {
+		return types.APIKey{}, false
+	}
+	for _, k := range a.keys {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(k.Key)) == 1 {
+			return k, true
+		}
+	}
+	return types.APIKey{}, false
+}
+
+func (a *Authenticator) allow(name string, now time.Time) (time.Duration, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	limiter, ok := a.limiters[name]
+	if !ok {
+		return 0, true
+	}
+	return limiter.take(now)
+}
+
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if scheme, token, ok := strings.Cut(auth, " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	// Some clients (e.g. Anthropic-style SDKs) send the key in a dedicated header
+	return r.Header.Get("X-Api-Key")
+}
+
+// rateLimiter is a token bucket that refills at perMinute/60 tokens per second and holds
+// at most perMinute tokens, so a full minute's allowance can be used in a burst
+type rateLimiter struct {
+	tokens   float64
+	capacity float64
+	rate     float64
+	last     time.Time
+}
+
+func newRateLimiter(perMinute int, now time.Time) *rateLimiter {
+	return &rateLimiter{
+		tokens:   float64(perMinute),
+		capacity: float64(perMinute),
+		rate:     float64(perMinute) / 60,
+		last:     now,
+	}
+}
+
+// take consumes one token, or reports how long until one is available
+func (l *rateLimiter) take(now time.Time) (time.Duration, bool) {
+	l.tokens = math.Min(l.capacity, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0, true
+	}
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second)), false
+}
+
+// GenerateKey returns a new random API key
+func GenerateKey() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	return keyPrefix + hex.EncodeToString(buf), nil
+}
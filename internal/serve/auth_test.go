@@ -0,0 +1,74 @@
+package serve
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+func TestAuthenticator(t *testing.T) {
+	auth := NewAuthenticator([]types.APIKey{
+		{Name: "alice", Key: "dgx-alice"},
+		{Name: "bob", Key: "dgx-bob", RateLimit: 2},
+	})
+	handler := logRequests(nilLogger(), nil, auth.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Fatalf("proxy key leaked to backend")
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	call := func(key string) int {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if got := call(""); got != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without key, got %d", got)
+	}
+	if got := call("dgx-wrong"); got != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for unknown key, got %d", got)
+	}
+	if got := call("dgx-alice"); got != http.StatusNoContent {
+		t.Fatalf("expected alice to pass, got %d", got)
+	}
+	for i := 0; i < 2; i++ {
+		if got := call("dgx-bob"); got != http.StatusNoContent {
+			t.Fatalf("expected bob request %d to pass, got %d", i+1, got)
+		}
+	}
+	if got := call("dgx-bob"); got != http.StatusTooManyRequests {
+		t.Fatalf("expected bob to be rate limited, got %d", got)
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	now := time.Now()
+	limiter := newRateLimiter(60, now)
+	for i := 0; i < 60; i++ {
+		if _, ok := limiter.take(now); !ok {
+			t.Fatalf("expected burst of 60, denied at %d", i)
+		}
+	}
+	wait, ok := limiter.take(now)
+	if ok || wait <= 0 || wait > time.Second {
+		t.Fatalf("expected denial with wait <= 1s, got ok=%v wait=%v", ok, wait)
+	}
+	if _, ok := limiter.take(now.Add(time.Second)); !ok {
+		t.Fatalf("expected a token after one second")
+	}
+}
+
+func nilLogger() *log.Logger {
+	return log.New(io.Discard, "", 0)
+}
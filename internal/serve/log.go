@@ -0,0 +1,96 @@
+package serve
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/usage"
+)
+
+type infoKey struct{}
+
+// requestInfo collects per-request details filled in by later handlers for the access log
+type requestInfo struct {
+	Key              string
+	Model            string
+	Backend          string
+	PromptTokens     int
+	CompletionTokens int
+}
+
+func infoFrom(r *http.Request) *requestInfo {
+	if info, ok := r.Context().Value(infoKey{}).(*requestInfo); ok {
+		return info
+	}
+	return &requestInfo{}
+}
+
+// statusRecorder captures the status code and body size written to a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(p []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(p)
+	s.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer to flush streams
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// logRequests writes one access-log line per request and, when store is non-nil, a usage
+// record for each request that reached a backend
+func logRequests(logger *log.Logger, store *usage.Store, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		info := &requestInfo{}
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), infoKey{}, info)))
+		elapsed := time.Since(start)
+
+		if store != nil && info.Backend != "" {
+			err := store.Append(usage.Record{
+				Time:             start,
+				Key:              info.Key,
+				Model:            info.Model,
+				Backend:          info.Backend,
+				Status:           rec.status,
+				PromptTokens:     info.PromptTokens,
+				CompletionTokens: info.CompletionTokens,
+				Duration:         elapsed,
+			})
+			if err != nil {
+				logger.Printf("warning: %v", err)
+			}
+		}
+
+		key := info.Key
+		if key == "" {
+			key = "-"
+		}
+		backend := info.Backend
+		if backend == "" {
+			backend = "-"
+		}
+		logger.Printf("%s key=%s %s %s model=%q backend=%s status=%d bytes=%d tokens=%d/%d %v",
+			r.RemoteAddr, key, r.Method, r.URL.Path, info.Model, backend, rec.status, rec.bytes,
+			info.PromptTokens, info.CompletionTokens, elapsed.Round(time.Millisecond))
+	})
+}
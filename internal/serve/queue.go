@@ -0,0 +1,168 @@
+package serve
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultQueueTimeout is how long a request may wait for a slot when none is configured
+	DefaultQueueTimeout = 2 * time.Minute
+	// queuePerSlot sizes the queue when max_queue is not set
+	queuePerSlot = 4
+)
+
+// QueueStatus is a snapshot of admission control, served in the status report
+type QueueStatus struct {
+	MaxConcurrency int    `json:"max_concurrency"`
+	MaxQueue       int    `json:"max_queue"`
+	Active         int    `json:"active"`
+	Queued         int    `json:"queued"`
+	Rejected       uint64 `json:"rejected"`
+}
+
+// Admission bounds how many requests reach the backends at once, so a burst from several
+// clients cannot run the GPU out of memory. Requests beyond the limit wait in a bounded
+// queue; when the queue is full, or a request waits longer than the timeout, it is refused
+// with 429 and a Retry-After estimate.
+type Admission struct {
+	slots   chan struct{}
+	timeout time.Duration
+
+	mu       sync.Mutex
+	maxQueue int
+	queued   int
+	rejected uint64
+	// avg is a moving average of how long admitted requests hold their slot
+	avg time.Duration
+}
+
+// NewAdmission creates admission control for maxConcurrency requests at a time, or returns
+// nil (no limit) when maxConcurrency is not positive. maxQueue 0 allows four waiting
+// requests per slot, and timeout 0 uses DefaultQueueTimeout.
+func NewAdmission(maxConcurrency, maxQueue int, timeout time.Duration) *Admission {
+	if maxConcurrency <= 0 {
+		return nil
+	}
+	if maxQueue <= 0 {
+		maxQueue = queuePerSlot * maxConcurrency
+	}
+	if timeout <= 0 {
+		timeout = DefaultQueueTimeout
+	}
+	return &Admission{
+		slots:    make(chan struct{}, maxConcurrency),
+		timeout:  timeout,
+		maxQueue: maxQueue,
+	}
+}
+
+// Wrap returns a handler that admits requests to next. A nil Admission admits everything.
+func (a *Admission) Wrap(next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Status and model listings are cheap and must answer while the queue is full
+		if r.URL.Path == "/dgx/status" || (r.Method == http.MethodGet && r.URL.Path == "/v1/models") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !a.acquire(w, r) {
+			return
+		}
+		start := time.Now()
+		defer func() { a.release(time.Since(start)) }()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// acquire waits for a slot, writing the refusal and returning false when none comes
+func (a *Admission) acquire(w http.ResponseWriter, r *http.Request) bool {
+	select {
+	case a.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	a.mu.Lock()
+	if a.queued >= a.maxQueue {
+		a.rejected++
+		wait := a.retryAfter()
+		a.mu.Unlock()
+		a.refuse(w, wait, fmt.Sprintf("server busy: %d requests running and %d queued", cap(a.slots), a.maxQueue))
+		return false
+	}
+	a.queued++
+	a.mu.Unlock()
+
+	timer := time.NewTimer(a.timeout)
+	defer timer.Stop()
+	admitted := false
+	select {
+	case a.slots <- struct{}{}:
+		admitted = true
+	case <-timer.C:
+	case <-r.Context().Done():
+	}
+
+	a.mu.Lock()
+	a.queued--
+	var wait time.Duration
+	if !admitted {
+		a.rejected++
+		wait = a.retryAfter()
+	}
+	a.mu.Unlock()
+
+	if !admitted && r.Context().Err() == nil {
+		a.refuse(w, wait, fmt.Sprintf("server busy: no slot freed within %v", a.timeout))
+	}
+	return admitted
+}
+
+func (a *Admission) release(held time.Duration) {
+	<-a.slots
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.avg == 0 {
+		a.avg = held
+	} else {
+		a.avg = (a.avg*7 + held) / 8
+	}
+}
+
+// retryAfter estimates when a slot frees up for a new request: the average request time
+// for each round of slots ahead of it. Callers hold a.mu.
+func (a *Admission) retryAfter() time.Duration {
+	avg := a.avg
+	if avg == 0 {
+		avg = time.Second
+	}
+	rounds := float64(a.queued+1) / float64(cap(a.slots))
+	return time.Duration(math.Max(1, rounds) * float64(avg))
+}
+
+func (a *Admission) refuse(w http.ResponseWriter, wait time.Duration, message string) {
+	w.Header().Set("Retry-After", fmt.Sprintf("%d", int(math.Max(1, math.Ceil(wait.Seconds())))))
+	writeError(w, http.StatusTooManyRequests, message)
+}
+
+// Status returns a snapshot of the queue, or nil without admission control
+func (a *Admission) Status() *QueueStatus {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return &QueueStatus{
+		MaxConcurrency: cap(a.slots),
+		MaxQueue:       a.maxQueue,
+		Active:         len(a.slots),
+		Queued:         a.queued,
+		Rejected:       a.rejected,
+	}
+}
@@ -0,0 +1,83 @@
+package serve
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAdmission(t *testing.T) {
+	admission := NewAdmission(1, 1, time.Minute)
+	unblock := make(chan struct{})
+	entered := make(chan struct{}, 2)
+	handler := admission.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-unblock
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	call := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil))
+		return rec
+	}
+
+	var wg sync.WaitGroup
+	codes := make([]int, 2)
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i] = call().Code
+		}()
+		if i == 0 {
+			<-entered
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for admission.Status().Queued != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected one queued request, got %+v", admission.Status())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	rec := call()
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 with a full queue, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected a Retry-After header")
+	}
+	if got := admission.Status(); got.Active != 1 || got.Rejected != 1 {
+		t.Fatalf("unexpected status %+v", got)
+	}
+
+	close(unblock)
+	wg.Wait()
+	for i, code := range codes {
+		if code != http.StatusNoContent {
+			t.Fatalf("expected request %d to be served, got %d", i+1, code)
+		}
+	}
+	if got := admission.Status(); got.Active != 0 || got.Queued != 0 {
+		t.Fatalf("expected an empty queue, got %+v", got)
+	}
+}
+
+func TestAdmissionTimeout(t *testing.T) {
+	admission := NewAdmission(1, 1, 10*time.Millisecond)
+	admission.slots <- struct{}{}
+	rec := httptest.NewRecorder()
+	admission.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatalf("request admitted without a free slot")
+	})).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/completions", nil))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 after the queue timeout, got %d", rec.Code)
+	}
+	if NewAdmission(0, 0, 0) != nil {
+		t.Fatalf("expected no admission control without a limit")
+	}
+}
@@ -0,0 +1,217 @@
+package serve
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// DefaultListen is the address the proxy binds when none is configured
+const DefaultListen = "127.0.0.1:8080"
+
+// DefaultHealthInterval is how often backends are probed when none is configured
+const DefaultHealthInterval = 10 * time.Second
+
+// Model types a route can be restricted to
+const (
+	TypeChat      = "chat"
+	TypeEmbedding = "embedding"
+	TypeRerank    = "rerank"
+)
+
+// ModelTypes lists the route types
+var ModelTypes = []string{TypeChat, TypeEmbedding, TypeRerank}
+
+// RequestType returns the model type an API path needs: embeddings, reranking (including
+// vLLM's /score), or chat for everything else
+func RequestType(path string) string {
+	switch {
+	case strings.HasSuffix(path, "/embeddings"):
+		return TypeEmbedding
+	case strings.HasSuffix(path, "/rerank"), strings.HasSuffix(path, "/score"):
+		return TypeRerank
+	default:
+		return TypeChat
+	}
+}
+
+// ValidateRoutes checks the route types
+func ValidateRoutes(routes []types.Route) error {
+	for _, route := range routes {
+		if route.Type != "" && !slices.Contains(ModelTypes, route.Type) {
+			return fmt.Errorf("route %q: unknown type %q (expected one of: %s)", route.Model, route.Type, strings.Join(ModelTypes, ", "))
+		}
+	}
+	return nil
+}
+
+// DefaultRoutes sends every model to Docker Model Runner on the configured DGX
+func DefaultRoutes() []types.Route {
+	return []types.Route{
+		{
+			Model: "*",
+			Backends: []types.Backend{
+				{Name: "dmr", Port: 12434, BasePath: "/engines"},
+			},
+		},
+	}
+}
+
+// BackendStatus is the last observed health of a backend
+type BackendStatus struct {
+	Backend   types.Backend `json:"backend"`
+	Healthy   bool          `json:"healthy"`
+	LastCheck time.Time     `json:"last_check"`
+	LastError string        `json:"last_error,omitempty"`
+}
+
+// Router resolves model names to healthy backends
+type Router struct {
+	routes []types.Route
+	mu     sync.RWMutex
+	status map[string]*BackendStatus
+}
+
+// NewRouter creates a router for the given routes. All backends start out healthy so that
+// requests are attempted before the first health check completes.
+func NewRouter(routes []types.Route) *Router {
+	if len(routes) == 0 {
+		routes = DefaultRoutes()
+	}
+	r := &Router{
+		routes: routes,
+		status: make(map[string]*BackendStatus),
+	}
+	for _, route := range routes {
+		for _, b := range route.Backends {
+			if _, ok := r.status[backendKey(b)]; !ok {
+				r.status[backendKey(b)] = &BackendStatus{Backend: b, Healthy: true}
+			}
+		}
+	}
+	return r
+}
+
+// Candidates returns the backends for chat requests to a model in failover order, healthy
+// ones first. It returns nil when no route matches.
+func (r *Router) Candidates(model string) []types.Backend {
+	return r.CandidatesFor(model, TypeChat)
+}
+
+// CandidatesFor returns the backends for requests of the given model type, skipping routes
+// restricted to other types
+func (r *Router) CandidatesFor(model, kind string) []types.Backend {
+	route := r.match(model, kind)
+	if route == nil {
+		return nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var healthy, unhealthy []types.Backend
+	for _, b := range route.Backends {
+		if st := r.status[backendKey(b)]; st == nil || st.Healthy {
+			healthy = append(healthy, b)
+		} else {
+			unhealthy = append(unhealthy, b)
+		}
+	}
+	// Unhealthy backends are still tried last in case the health check is stale
+	return append(healthy, unhealthy...)
+}
+
+// Backends returns every distinct backend across all routes
+func (r *Router) Backends() []types.Backend {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.backends()
+}
+
+func (r *Router) backends() []types.Backend {
+	backends := make([]types.Backend, 0, len(r.status))
+	seen := make(map[string]bool)
+	for _, route := range r.routes {
+		for _, b := range route.Backends {
+			if key := backendKey(b); !seen[key] {
+				seen[key] = true
+				backends = append(backends, b)
+			}
+		}
+	}
+	return backends
+}
+
+// Routes returns the configured routing table
+func (r *Router) Routes() []types.Route {
+	return r.routes
+}
+
+// MarkHealth records the outcome of a health check or proxied request
+func (r *Router) MarkHealth(b types.Backend, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st, ok := r.status[backendKey(b)]
+	if !ok {
+		st = &BackendStatus{Backend: b}
+		r.status[backendKey(b)] = st
+	}
+	st.Healthy = err == nil
+	st.LastCheck = time.Now()
+	st.LastError = ""
+	if err != nil {
+		st.LastError = err.Error()
+	}
+}
+
+// Status returns a snapshot of backend health
+func (r *Router) Status() []BackendStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	statuses := make([]BackendStatus, 0, len(r.status))
+	for _, b := range r.backends() {
+		statuses = append(statuses, *r.status[backendKey(b)])
+	}
+	return statuses
+}
+
+// TypeOf returns the type of the first route matching model, "" when that route is untyped
+func (r *Router) TypeOf(model string) string {
+	for _, route := range r.routes {
+		if MatchModel(route.Model, model) {
+			return route.Type
+		}
+	}
+	return ""
+}
+
+func (r *Router) match(model, kind string) *types.Route {
+	for i := range r.routes {
+		if t := r.routes[i].Type; t != "" && t != kind {
+			continue
+		}
+		if MatchModel(r.routes[i].Model, model) {
+			return &r.routes[i]
+		}
+	}
+	return nil
+}
+
+// MatchModel reports whether a model name matches a route pattern
+func MatchModel(pattern, model string) bool {
+	switch {
+	case pattern == "*":
+		return true
+	case strings.HasSuffix(pattern, "*"):
+		return strings.HasPrefix(model, strings.TrimSuffix(pattern, "*"))
+	default:
+		return pattern == model
+	}
+}
+
+func backendKey(b types.Backend) string {
+	return b.Name + "@" + b.Host
+}
@@ -0,0 +1,66 @@
+package serve
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+func TestRouterCandidates(t *testing.T) {
+	dmr := types.Backend{Name: "dmr", Port: 12434, BasePath: "/engines"}
+	vllm := types.Backend{Name: "vllm", Port: 8000}
+	spark2 := types.Backend{Name: "vllm", Host: "spark2", Port: 8000}
+
+	router := NewRouter([]types.Route{
+		{Model: "ai/*", Backends: []types.Backend{dmr}},
+		{Model: "meta-llama/Llama-3.1-8B-Instruct", Backends: []types.Backend{vllm, spark2}},
+	})
+
+	t.Run("prefix match", func(t *testing.T) {
+		got := router.Candidates("ai/gemma3")
+		if len(got) != 1 || got[0] != dmr {
+			t.Fatalf("expected dmr, got %+v", got)
+		}
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		if got := router.Candidates("mistral"); got != nil {
+			t.Fatalf("expected no candidates, got %+v", got)
+		}
+	})
+
+	t.Run("unhealthy backend moves last", func(t *testing.T) {
+		router.MarkHealth(vllm, errors.New("connection refused"))
+		got := router.Candidates("meta-llama/Llama-3.1-8B-Instruct")
+		if len(got) != 2 || got[0] != spark2 || got[1] != vllm {
+			t.Fatalf("expected spark2 before vllm, got %+v", got)
+		}
+
+		router.MarkHealth(vllm, nil)
+		got = router.Candidates("meta-llama/Llama-3.1-8B-Instruct")
+		if got[0] != vllm {
+			t.Fatalf("expected vllm first after recovery, got %+v", got)
+		}
+	})
+
+	t.Run("typed routes", func(t *testing.T) {
+		embed := types.Backend{Name: "vllm-embed", Port: 8001}
+		typed := NewRouter([]types.Route{
+			{Model: "BAAI/*", Type: TypeEmbedding, Backends: []types.Backend{embed}},
+			{Model: "*", Backends: []types.Backend{dmr}},
+		})
+		if got := typed.CandidatesFor("BAAI/bge-m3", RequestType("/v1/embeddings")); len(got) != 1 || got[0] != embed {
+			t.Fatalf("expected embedding backend, got %+v", got)
+		}
+		if got := typed.Candidates("BAAI/bge-m3"); len(got) != 1 || got[0] != dmr {
+			t.Fatalf("expected chat to skip the embedding route, got %+v", got)
+		}
+		if got := typed.TypeOf("BAAI/bge-m3"); got != TypeEmbedding {
+			t.Fatalf("expected embedding type, got %q", got)
+		}
+		if got := RequestType("/v1/rerank"); got != TypeRerank {
+			t.Fatalf("expected rerank for /v1/rerank, got %q", got)
+		}
+	})
+}
@@ -0,0 +1,379 @@
+package serve
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/usage"
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// maxRequestBody bounds how much of a request is buffered to read the model name and
+// replay it to a failover backend. Larger requests are refused with 413.
+const maxRequestBody = 64 << 20
+
+// Server is an OpenAI-compatible reverse proxy that routes requests by model name
+type Server struct {
+	router         *Router
+	auth           *Authenticator
+	admission      *Admission
+	client         *http.Client
+	handler        http.Handler
+	logger         *log.Logger
+	healthInterval time.Duration
+	waker          WakeFunc
+}
+
+// WakeFunc wakes the backend host (empty for the configured DGX) if it is asleep, and
+// reports whether it was just woken and so is worth trying again
+type WakeFunc func(host string) bool
+
+// NewServer creates a proxy for the given config. dialers maps backend hosts to dial
+// functions; the empty key is the configured DGX. Access logs go to logger, and usage is
+// recorded to store when it is non-nil.
+func NewServer(cfg *types.ServeConfig, dialers map[string]DialFunc, logger *log.Logger, store *usage.Store) *Server {
+	if cfg == nil {
+		cfg = &types.ServeConfig{}
+	}
+	interval := DefaultHealthInterval
+	if cfg.HealthInterval > 0 {
+		interval = cfg.HealthInterval
+	}
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	s := &Server{
+		router:         NewRouter(cfg.Routes),
+		auth:           NewAuthenticator(cfg.Keys),
+		admission:      NewAdmission(cfg.MaxConcurrency, cfg.MaxQueue, cfg.QueueTimeout),
+		client:         &http.Client{Transport: NewTransport(dialers)},
+		logger:         logger,
+		healthInterval: interval,
+	}
+	s.handler = logRequests(logger, store, s.auth.Wrap(s.admission.Wrap(http.HandlerFunc(s.handle))))
+	return s
+}
+
+// SetWaker makes requests whose backends all fail wake the backend hosts with wake and try
+// once more
+func (s *Server) SetWaker(wake WakeFunc) {
+	s.waker = wake
+}
+
+// Admission returns the server's admission control, nil when concurrency is unlimited
+func (s *Server) Admission() *Admission {
+	return s.admission
+}
+
+// Router returns the server's routing table
+func (s *Server) Router() *Router {
+	return s.router
+}
+
+// ListenAndServe starts health checks and serves HTTP until ctx is cancelled
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	if addr == "" {
+		addr = DefaultListen
+	}
+	go s.runHealthChecks(ctx)
+
+	srv := &http.Server{Addr: addr, Handler: s}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return fmt.Errorf("proxy failed: %w", err)
+	}
+	return nil
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// AuthEnabled reports whether clients must present an API key
+func (s *Server) AuthEnabled() bool {
+	return s.auth.Enabled()
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/dgx/status":
+		s.handleStatus(w)
+		return
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/models":
+		s.handleModels(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body is larger than %d MiB", maxRequestBody>>20))
+			return
+		}
+		writeError(w, http.StatusBadRequest, "failed to read request body")
+		return
+	}
+	model := requestModel(body)
+	info := infoFrom(r)
+	info.Model = model
+
+	kind := RequestType(r.URL.Path)
+	candidates := s.router.CandidatesFor(model, kind)
+	if len(candidates) == 0 {
+		if t := s.router.TypeOf(model); t != "" && t != kind {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("model %q is routed as a %s model and cannot serve %s requests", model, t, kind))
+			return
+		}
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no route for model %q", model))
+		return
+	}
+
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		for _, backend := range candidates {
+			resp, err := s.forward(r, backend, body)
+			if err != nil {
+				lastErr = err
+				s.router.MarkHealth(backend, err)
+				s.logger.Printf("backend %s failed for model %q, trying next: %v", backend.Name, model, err)
+				continue
+			}
+
+			s.router.MarkHealth(backend, nil)
+			info.Backend = backend.Name
+			copyHeaders(w.Header(), resp.Header)
+			if isEventStream(resp.Header) {
+				// Keep intermediaries (e.g. nginx) from buffering the stream
+				w.Header().Set("X-Accel-Buffering", "no")
+			}
+			w.WriteHeader(resp.StatusCode)
+			counter := newTokenCounter(isEventStream(resp.Header))
+			resp.Body = tappedBody{io.TeeReader(resp.Body, counter), resp.Body}
+			_, err = copyResponse(w, resp)
+			resp.Body.Close()
+			info.PromptTokens, info.CompletionTokens = counter.Totals()
+			if err != nil {
+				// Usually the client went away; closing the body aborts generation upstream
+				s.logger.Printf("stream from %s ended early: %v", backend.Name, err)
+			}
+			return
+		}
+		// A sleeping DGX refuses connections; wake it once and try again
+		if attempt > 0 || !s.wakeBackends(model, candidates) {
+			break
+		}
+	}
+
+	writeError(w, http.StatusBadGateway, fmt.Sprintf("all backends failed for model %q: %v", model, lastErr))
+}
+
+// wakeBackends wakes the hosts of the candidates and reports whether any was woken
+func (s *Server) wakeBackends(model string, candidates []types.Backend) bool {
+	if s.waker == nil {
+		return false
+	}
+	woke := false
+	seen := map[string]bool{}
+	for _, b := range candidates {
+		if seen[b.Host] {
+			continue
+		}
+		seen[b.Host] = true
+		name := b.Host
+		if name == "" {
+			name = "the DGX"
+		}
+		s.logger.Printf("no backend answered for model %q; waking %s", model, name)
+		if s.waker(b.Host) {
+			woke = true
+		}
+	}
+	return woke
+}
+
+// forward sends the request to one backend. Connection failures and gateway errors are
+// returned as errors so the caller can fail over.
+func (s *Server) forward(r *http.Request, backend types.Backend, body []byte) (*http.Response, error) {
+	url := BackendURL(backend, r.URL.Path)
+	if r.URL.RawQuery != "" {
+		url += "?" + r.URL.RawQuery
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	copyHeaders(req.Header, r.Header)
+	req.Header.Del("Host")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		resp.Body.Close()
+		return nil, fmt.Errorf("backend returned %s", resp.Status)
+	}
+	return resp, nil
+}
+
+// handleModels merges /v1/models from every healthy backend
+func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
+	type model struct {
+		ID      string `json:"id"`
+		Object  string `json:"object"`
+		OwnedBy string `json:"owned_by,omitempty"`
+	}
+	seen := make(map[string]bool)
+	merged := []model{}
+
+	for _, backend := range s.router.Backends() {
+		req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, BackendURL(backend, "/v1/models"), nil)
+		if err != nil {
+			continue
+		}
+		resp, err := s.client.Do(req)
+		if err != nil {
+			s.router.MarkHealth(backend, err)
+			continue
+		}
+		var list struct {
+			Data []model `json:"data"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		if err != nil {
+			continue
+		}
+		for _, m := range list.Data {
+			if seen[m.ID] {
+				continue
+			}
+			seen[m.ID] = true
+			if m.OwnedBy == "" {
+				m.OwnedBy = backend.Name
+			}
+			m.Object = "model"
+			merged = append(merged, m)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"object": "list", "data": merged})
+}
+
+// StatusReport is the JSON document served at /dgx/status. Queue is nil without
+// admission control.
+type StatusReport struct {
+	Routes   []types.Route   `json:"routes"`
+	Backends []BackendStatus `json:"backends"`
+	Queue    *QueueStatus    `json:"queue,omitempty"`
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(StatusReport{
+		Routes:   s.router.Routes(),
+		Backends: s.router.Status(),
+		Queue:    s.admission.Status(),
+	})
+}
+
+func (s *Server) runHealthChecks(ctx context.Context) {
+	ticker := time.NewTicker(s.healthInterval)
+	defer ticker.Stop()
+	for {
+		s.checkBackends(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) checkBackends(ctx context.Context) {
+	for _, backend := range s.router.Backends() {
+		checkCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		req, err := http.NewRequestWithContext(checkCtx, http.MethodGet, BackendURL(backend, "/v1/models"), nil)
+		if err == nil {
+			var resp *http.Response
+			resp, err = s.client.Do(req)
+			if err == nil {
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+				if resp.StatusCode >= 500 {
+					err = fmt.Errorf("health check returned %s", resp.Status)
+				}
+			}
+		}
+		cancel()
+		s.router.MarkHealth(backend, err)
+	}
+}
+
+// requestModel extracts the "model" field from a JSON request body
+func requestModel(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	var req struct {
+		Model string `json:"model"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return ""
+	}
+	return req.Model
+}
+
+// hopHeaders are connection-specific and must not be forwarded
+var hopHeaders = map[string]bool{
+	"Connection":          true,
+	"Keep-Alive":          true,
+	"Proxy-Authenticate":  true,
+	"Proxy-Authorization": true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+}
+
+func copyHeaders(dst, src http.Header) {
+	for key, values := range src {
+		if hopHeaders[http.CanonicalHeaderKey(key)] {
+			continue
+		}
+		for _, v := range values {
+			dst.Add(key, v)
+		}
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]string{
+			"message": message,
+			"type":    strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_"),
+		},
+	})
+}
@@ -0,0 +1,70 @@
+package serve
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// newTestProxy returns a proxy routing model "m" to upstream
+func newTestProxy(t *testing.T, upstream *httptest.Server) *Server {
+	t.Helper()
+	addr := upstream.Listener.Addr().String()
+	dialers := map[string]DialFunc{"": func(network, _ string) (net.Conn, error) {
+		return net.Dial(network, addr)
+	}}
+	cfg := &types.ServeConfig{Routes: []types.Route{{Model: "m", Backends: []types.Backend{{Name: "vllm", Port: 8000}}}}}
+	return NewServer(cfg, dialers, nil, nil)
+}
+
+func TestStreamPassthrough(t *testing.T) {
+	stream := "data: {\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\n\n" +
+		"data: {\"choices\":[{\"delta\":{\"content\":\"lo\"}}]}\n\n" +
+		"data: [DONE]\n\n"
+	var received []byte
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, stream)
+	}))
+	defer upstream.Close()
+	proxy := newTestProxy(t, upstream)
+
+	body := `{"model":"m","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if string(received) != body {
+		t.Fatalf("request changed on the way upstream:\n got %s\nwant %s", received, body)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), []byte(stream)) {
+		t.Fatalf("stream changed on the way to the client:\n got %q\nwant %q", rec.Body, stream)
+	}
+}
+
+func TestRequestBodyTooLarge(t *testing.T) {
+	called := false
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer upstream.Close()
+	proxy := newTestProxy(t, upstream)
+
+	body := `{"model":"m","prompt":"` + strings.Repeat("a", maxRequestBody) + `"}`
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/completions", strings.NewReader(body)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d: %s", rec.Code, rec.Body)
+	}
+	if called {
+		t.Fatal("a truncated body was forwarded upstream")
+	}
+}
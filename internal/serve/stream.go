@@ -0,0 +1,46 @@
+package serve
+
+import (
+	"io"
+	"mime"
+	"net/http"
+)
+
+// isEventStream reports whether a response carries server-sent events
+func isEventStream(h http.Header) bool {
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	return mediaType == "text/event-stream"
+}
+
+// copyResponse writes an upstream body to the client. Streamed responses (SSE or unknown
+// length) are flushed after every read so tokens reach the client as they are generated.
+// Writes block while the client is slow to read, which stops reads from the SSH channel
+// and lets its flow-control window push back on the backend.
+func copyResponse(w http.ResponseWriter, resp *http.Response) (int64, error) {
+	if !isEventStream(resp.Header) && resp.ContentLength >= 0 {
+		return io.Copy(w, resp.Body)
+	}
+
+	rc := http.NewResponseController(w)
+	buf := make([]byte, 32*1024)
+	var written int64
+	for {
+		n, readErr := resp.Body.Read(buf)
+		if n > 0 {
+			m, err := w.Write(buf[:n])
+			written += int64(m)
+			if err != nil {
+				return written, err
+			}
+			if err := rc.Flush(); err != nil {
+				return written, err
+			}
+		}
+		if readErr == io.EOF {
+			return written, nil
+		}
+		if readErr != nil {
+			return written, readErr
+		}
+	}
+}
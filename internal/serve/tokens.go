@@ -0,0 +1,113 @@
+package serve
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// maxUsageBody bounds how much of a non-streamed response is kept to read its usage block
+const maxUsageBody = 8 << 20
+
+// usageBlock is the OpenAI "usage" object. Embedding and rerank responses may only report
+// total_tokens, which are all input.
+type usageBlock struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// tokenCounter observes a response body and extracts token usage. Streams are scanned
+// event by event; a stream carries a usage block only when the client asked for one with
+// stream_options.include_usage, and otherwise content chunks are counted as an
+// approximation of completion tokens.
+type tokenCounter struct {
+	stream   bool
+	buf      []byte
+	usage    *usageBlock
+	chunks   int
+	overflow bool
+}
+
+func newTokenCounter(stream bool) *tokenCounter {
+	return &tokenCounter{stream: stream}
+}
+
+func (t *tokenCounter) Write(p []byte) (int, error) {
+	if !t.stream {
+		if len(t.buf)+len(p) > maxUsageBody {
+			t.overflow = true
+		} else {
+			t.buf = append(t.buf, p...)
+		}
+		return len(p), nil
+	}
+
+	t.buf = append(t.buf, p...)
+	for {
+		i := bytes.IndexByte(t.buf, '\n')
+		if i < 0 {
+			break
+		}
+		t.scanLine(bytes.TrimRight(t.buf[:i], "\r"))
+		t.buf = t.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+func (t *tokenCounter) scanLine(line []byte) {
+	data, ok := bytes.CutPrefix(line, []byte("data:"))
+	if !ok {
+		return
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || string(data) == "[DONE]" {
+		return
+	}
+
+	var chunk struct {
+		Usage   *usageBlock `json:"usage"`
+		Choices []struct {
+			Delta struct {
+				Content string `json:"content"`
+			} `json:"delta"`
+			Text string `json:"text"`
+		} `json:"choices"`
+	}
+	if json.Unmarshal(data, &chunk) != nil {
+		return
+	}
+	if chunk.Usage != nil {
+		t.usage = chunk.Usage
+	}
+	for _, c := range chunk.Choices {
+		if c.Delta.Content != "" || c.Text != "" {
+			t.chunks++
+		}
+	}
+}
+
+// Totals returns prompt and completion token counts for everything written so far
+func (t *tokenCounter) Totals() (prompt, completion int) {
+	if !t.stream && !t.overflow {
+		var resp struct {
+			Usage *usageBlock `json:"usage"`
+		}
+		if json.Unmarshal(t.buf, &resp) == nil && resp.Usage != nil {
+			t.usage = resp.Usage
+		}
+	}
+	if t.usage != nil {
+		if t.usage.PromptTokens == 0 && t.usage.CompletionTokens == 0 {
+			return t.usage.TotalTokens, 0
+		}
+		return t.usage.PromptTokens, t.usage.CompletionTokens
+	}
+	return 0, t.chunks
+}
+
+// tappedBody tees a response body into a token counter while keeping the original closer
+type tappedBody struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,38 @@
+package serve
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTokenCounter(t *testing.T) {
+	t.Run("stream with usage block", func(t *testing.T) {
+		c := newTokenCounter(true)
+		stream := "data: {\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\n\n" +
+			"data: {\"choices\":[{\"delta\":{\"content\":\"lo\"}}]}\n\n" +
+			"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":12,\"completion_tokens\":2}}\n\n" +
+			"data: [DONE]\n\n"
+		// Split mid-line to exercise buffering across writes
+		c.Write([]byte(stream[:17]))
+		c.Write([]byte(stream[17:]))
+		if p, comp := c.Totals(); p != 12 || comp != 2 {
+			t.Fatalf("expected 12/2, got %d/%d", p, comp)
+		}
+	})
+
+	t.Run("stream without usage counts chunks", func(t *testing.T) {
+		c := newTokenCounter(true)
+		c.Write([]byte(strings.Repeat("data: {\"choices\":[{\"delta\":{\"content\":\"x\"}}]}\n\n", 5)))
+		if p, comp := c.Totals(); p != 0 || comp != 5 {
+			t.Fatalf("expected 0/5, got %d/%d", p, comp)
+		}
+	})
+
+	t.Run("json body", func(t *testing.T) {
+		c := newTokenCounter(false)
+		c.Write([]byte(`{"choices":[{"message":{"content":"hi"}}],"usage":{"prompt_tokens":7,"completion_tokens":3}}`))
+		if p, comp := c.Totals(); p != 7 || comp != 3 {
+			t.Fatalf("expected 7/3, got %d/%d", p, comp)
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package serve
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// primaryHost is the upstream host name used for backends on the configured DGX
+const primaryHost = "dgx"
+
+// DialFunc opens a connection to addr as seen from a backend's host (typically over SSH)
+type DialFunc func(network, addr string) (net.Conn, error)
+
+// NewTransport returns an HTTP transport that reaches backends through the given dialers.
+// dialers maps backend hosts to dial functions; the empty key is the configured DGX.
+func NewTransport(dialers map[string]DialFunc) *http.Transport {
+	byHost := make(map[string]DialFunc, len(dialers))
+	for host, dial := range dialers {
+		byHost[upstreamHostName(host)] = dial
+	}
+
+	dialContext := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		dial, ok := byHost[host]
+		if !ok {
+			return nil, fmt.Errorf("no SSH connection configured for backend host %s", host)
+		}
+
+		type result struct {
+			conn net.Conn
+			err  error
+		}
+		done := make(chan result, 1)
+		go func() {
+			conn, err := dial(network, net.JoinHostPort("127.0.0.1", port))
+			done <- result{conn, err}
+		}()
+		select {
+		case <-ctx.Done():
+			// The dial may still succeed after the caller gave up; close what it opens
+			go func() {
+				if res := <-done; res.conn != nil {
+					res.conn.Close()
+				}
+			}()
+			return nil, ctx.Err()
+		case res := <-done:
+			return res.conn, res.err
+		}
+	}
+
+	return &http.Transport{
+		DialContext:         dialContext,
+		MaxIdleConnsPerHost: 16,
+		IdleConnTimeout:     90 * time.Second,
+		// Pass bodies through untouched so streamed responses are not buffered for decompression
+		DisableCompression: true,
+	}
+}
+
+// BackendURL returns the upstream URL for a path on a backend
+func BackendURL(b types.Backend, path string) string {
+	return fmt.Sprintf("http://%s%s%s", net.JoinHostPort(upstreamHostName(b.Host), strconv.Itoa(b.Port)), b.BasePath, path)
+}
+
+// upstreamHostName maps a backend host ("", "host", or "user@host") to the host name used
+// in upstream URLs and the dialer table
+func upstreamHostName(host string) string {
+	if _, h, ok := strings.Cut(host, "@"); ok {
+		host = h
+	}
+	if host == "" {
+		return primaryHost
+	}
+	return host
+}
@@ -0,0 +1,48 @@
+package serve
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// closeConn records when it is closed
+type closeConn struct {
+	net.Conn
+	closed chan struct{}
+}
+
+func (c *closeConn) Close() error {
+	close(c.closed)
+	return c.Conn.Close()
+}
+
+func TestCancelledDialClosesConn(t *testing.T) {
+	release := make(chan struct{})
+	local, remote := net.Pipe()
+	defer remote.Close()
+	conn := &closeConn{Conn: local, closed: make(chan struct{})}
+	transport := NewTransport(map[string]DialFunc{"": func(string, string) (net.Conn, error) {
+		<-release
+		return conn, nil
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		_, err := transport.DialContext(ctx, "tcp", "dgx:8000")
+		errc <- err
+	}()
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	close(release)
+	select {
+	case <-conn.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection opened after the dial was cancelled was never closed")
+	}
+}
@@ -0,0 +1,253 @@
+package session
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/weatherman/dgx-manager/internal/redact"
+)
+
+// DefaultDir is the recordings directory inside the dgx config directory
+const DefaultDir = "sessions"
+
+// Header is the first line of an asciicast v2 recording
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Command   string            `json:"command,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Event is one recorded output chunk ("o") or terminal resize ("r")
+type Event struct {
+	Time float64
+	Kind string
+	Data string
+}
+
+// Recorder writes an asciicast v2 file that asciinema can play. Output is redacted a line
+// at a time, so a secret split across two writes is still masked.
+type Recorder struct {
+	out *redact.Writer
+
+	mu      sync.Mutex
+	file    *os.File
+	start   time.Time
+	pending []byte // trailing bytes of an incomplete UTF-8 sequence
+}
+
+// DefaultPath returns ~/.config/dgx/sessions
+func DefaultPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".config", "dgx", DefaultDir), nil
+}
+
+// Create starts a recording in dir named after the start time and kind (e.g. "connect")
+func Create(dir, kind string, header Header) (*Recorder, string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, "", fmt.Errorf("failed to create sessions directory: %w", err)
+	}
+	start := time.Now()
+	base := fmt.Sprintf("%s-%s", start.Format("20060102-150405"), kind)
+	id := base
+	var file *os.File
+	for n := 2; ; n++ {
+		var err error
+		file, err = os.OpenFile(filepath.Join(dir, id+".cast"), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return nil, "", fmt.Errorf("failed to create recording: %w", err)
+		}
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+
+	header.Version = 2
+	header.Timestamp = start.Unix()
+	if header.Width <= 0 || header.Height <= 0 {
+		header.Width, header.Height = 80, 24
+	}
+	line, err := json.Marshal(header)
+	if err == nil {
+		_, err = file.Write(append(line, '\n'))
+	}
+	if err != nil {
+		file.Close()
+		return nil, "", fmt.Errorf("failed to write recording header: %w", err)
+	}
+	r := &Recorder{file: file, start: start}
+	r.out = redact.NewWriter(recordWriter{r}, redact.Default())
+	return r, id, nil
+}
+
+// Write records p as terminal output
+func (r *Recorder) Write(p []byte) (int, error) {
+	return r.out.Write(p)
+}
+
+// recordWriter takes the redacted output of a Recorder
+type recordWriter struct{ r *Recorder }
+
+func (w recordWriter) Write(p []byte) (int, error) {
+	r := w.r
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data := append(r.pending, p...)
+	cut := completeUTF8(data)
+	r.pending = append([]byte(nil), data[cut:]...)
+	if cut > 0 {
+		if err := r.event("o", string(data[:cut])); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Resize records a terminal size change
+func (r *Recorder) Resize(width, height int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.event("r", fmt.Sprintf("%dx%d", width, height))
+}
+
+// Close flushes buffered output and closes the file
+func (r *Recorder) Close() error {
+	r.out.Flush()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.pending) > 0 {
+		r.event("o", string(r.pending))
+		r.pending = nil
+	}
+	return r.file.Close()
+}
+
+func (r *Recorder) event(kind, data string) error {
+	line, err := json.Marshal([]interface{}{time.Since(r.start).Seconds(), kind, data})
+	if err != nil {
+		return err
+	}
+	_, err = r.file.Write(append(line, '\n'))
+	return err
+}
+
+// completeUTF8 returns the length of the prefix of p that does not end inside a multi-byte
+// character, so a character split across writes is recorded whole
+func completeUTF8(p []byte) int {
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(p[i]) {
+			if !utf8.FullRune(p[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(p)
+}
+
+// Recording is a parsed recording file
+type Recording struct {
+	ID     string
+	Path   string
+	Header Header
+	Events []Event
+}
+
+// Duration is the time of the last event
+func (r *Recording) Duration() time.Duration {
+	if len(r.Events) == 0 {
+		return 0
+	}
+	return time.Duration(r.Events[len(r.Events)-1].Time * float64(time.Second))
+}
+
+// Load reads a recording by ID from dir
+func Load(dir, id string) (*Recording, error) {
+	path := filepath.Join(dir, strings.TrimSuffix(id, ".cast")+".cast")
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("session %s not found", id)
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	rec, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	rec.ID = strings.TrimSuffix(filepath.Base(path), ".cast")
+	rec.Path = path
+	return rec, nil
+}
+
+// Parse reads an asciicast v2 stream. A truncated final line, as left by an interrupted
+// recording, is ignored.
+func Parse(r io.Reader) (*Recording, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("empty recording")
+	}
+	rec := &Recording{}
+	if err := json.Unmarshal(scanner.Bytes(), &rec.Header); err != nil {
+		return nil, fmt.Errorf("invalid header: %w", err)
+	}
+	if rec.Header.Version != 2 {
+		return nil, fmt.Errorf("unsupported asciicast version %d", rec.Header.Version)
+	}
+
+	for scanner.Scan() {
+		var raw []interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &raw); err != nil || len(raw) != 3 {
+			continue
+		}
+		t, ok1 := raw[0].(float64)
+		kind, ok2 := raw[1].(string)
+		data, ok3 := raw[2].(string)
+		if ok1 && ok2 && ok3 {
+			rec.Events = append(rec.Events, Event{Time: t, Kind: kind, Data: data})
+		}
+	}
+	return rec, scanner.Err()
+}
+
+// List returns the recordings in dir, newest first
+func List(dir string) ([]*Recording, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.cast"))
+	if err != nil {
+		return nil, err
+	}
+	var recs []*Recording
+	for _, path := range paths {
+		rec, err := Load(dir, strings.TrimSuffix(filepath.Base(path), ".cast"))
+		if err != nil {
+			continue
+		}
+		recs = append(recs, rec)
+	}
+	// IDs start with the start time, so they sort chronologically
+	sort.Slice(recs, func(i, j int) bool { return recs[i].ID > recs[j].ID })
+	return recs, nil
+}
@@ -0,0 +1,68 @@
+package session
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/weatherman/dgx-manager/internal/redact"
+)
+
+func TestRecorderRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	rec, id, err := Create(dir, "connect", Header{Width: 100, Height: 30, Title: "test"})
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	// "é" split across two writes must be recorded as one character
+	rec.Write([]byte("caf\xc3"))
+	rec.Write([]byte("\xa9\r\n"))
+	// as must a secret split across two writes be masked
+	rec.Write([]byte("token=hf_abcdefghijklmno"))
+	rec.Write([]byte("pqrstuvwxyz0123456789\r\n"))
+	rec.Resize(120, 40)
+	if err := rec.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	loaded, err := Load(dir, id)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if loaded.Header.Version != 2 || loaded.Header.Width != 100 || loaded.Header.Title != "test" {
+		t.Fatalf("unexpected header %+v", loaded.Header)
+	}
+	if len(loaded.Events) != 3 {
+		t.Fatalf("expected 3 events, got %+v", loaded.Events)
+	}
+	if loaded.Events[0].Data != "café\r\n" {
+		t.Fatalf("unexpected output events %+v", loaded.Events)
+	}
+	if data := loaded.Events[1].Data; strings.Contains(data, "hf_") || !strings.Contains(data, redact.Mask) {
+		t.Fatalf("split secret reached the recording: %q", data)
+	}
+	if loaded.Events[2].Kind != "r" || loaded.Events[2].Data != "120x40" {
+		t.Fatalf("unexpected resize event %+v", loaded.Events[2])
+	}
+
+	second, secondID, err := Create(dir, "connect", Header{})
+	if err != nil || secondID == id {
+		t.Fatalf("expected a distinct id for a second recording, got %q err=%v", secondID, err)
+	}
+	second.Close()
+	if recs, err := List(dir); err != nil || len(recs) != 2 {
+		t.Fatalf("expected 2 recordings, got %d err=%v", len(recs), err)
+	}
+}
+
+func TestText(t *testing.T) {
+	rec := &Recording{Events: []Event{
+		{Kind: "o", Data: "\x1b[1;32muser@spark\x1b[0m:~$ ls\r\n"},
+		{Kind: "r", Data: "80x24"},
+		{Kind: "o", Data: "Downloading 10%\rDownloading 100%\r\n"},
+		{Kind: "o", Data: "\x1b]0;title\x07typo\b\bpo\r\n"},
+	}}
+	want := "user@spark:~$ ls\nDownloading 100%\ntypo\n"
+	if got := Text(rec); got != want {
+		t.Fatalf("Text() = %q, want %q", got, want)
+	}
+}
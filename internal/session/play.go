@@ -0,0 +1,76 @@
+package session
+
+import (
+	"context"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Play writes the recording's output to w with its original timing, sped up by speed.
+// Pauses longer than idleLimit are shortened to idleLimit when it is positive.
+func Play(ctx context.Context, w io.Writer, rec *Recording, speed float64, idleLimit time.Duration) error {
+	if speed <= 0 {
+		speed = 1
+	}
+	var last float64
+	for _, ev := range rec.Events {
+		delay := time.Duration((ev.Time - last) / speed * float64(time.Second))
+		last = ev.Time
+		if idleLimit > 0 && delay > idleLimit {
+			delay = idleLimit
+		}
+		if delay > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+		if ev.Kind == "o" {
+			if _, err := io.WriteString(w, ev.Data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ansiSequence matches CSI and OSC escape sequences and two-byte escapes
+var ansiSequence = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+// Text renders the recording's output as a plain transcript: escape sequences are removed,
+// and carriage returns and backspaces overwrite the current line as a terminal would
+func Text(rec *Recording) string {
+	var raw strings.Builder
+	for _, ev := range rec.Events {
+		if ev.Kind == "o" {
+			raw.WriteString(ev.Data)
+		}
+	}
+	clean := ansiSequence.ReplaceAllString(raw.String(), "")
+	clean = strings.ReplaceAll(clean, "\r\n", "\n")
+
+	var out strings.Builder
+	var line []rune
+	for _, r := range clean {
+		switch r {
+		case '\n':
+			out.WriteString(strings.TrimRight(string(line), " "))
+			out.WriteByte('\n')
+			line = line[:0]
+		case '\r':
+			line = line[:0]
+		case '\b':
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+			}
+		case '\a':
+		default:
+			line = append(line, r)
+		}
+	}
+	out.WriteString(string(line))
+	return out.String()
+}
@@ -0,0 +1,91 @@
+package sftp
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// HasMeta reports whether p contains glob metacharacters
+func HasMeta(p string) bool {
+	return strings.ContainsAny(p, `*?[\`)
+}
+
+// Glob returns the paths matching pattern, with path.Match syntax in each element, sorted.
+// Relative patterns are resolved against the login directory by the server, as in a shell.
+// As in a shell, * and ? do not match a leading dot unless the pattern element starts
+// with one. A pattern without metacharacters is returned as is when the file exists.
+func (c *Client) Glob(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	if !HasMeta(pattern) {
+		if _, err := c.Lstat(pattern); err != nil {
+			return nil, err
+		}
+		return []string{pattern}, nil
+	}
+
+	dir, rest := "", pattern
+	if strings.HasPrefix(pattern, "/") {
+		dir, rest = "/", strings.TrimLeft(pattern, "/")
+	}
+	matches := []string{dir}
+	listed := true // whether the matches came from directory listings
+	for _, elem := range strings.Split(rest, "/") {
+		if elem == "" {
+			continue
+		}
+		listed = HasMeta(elem)
+		var next []string
+		for _, m := range matches {
+			if !HasMeta(elem) {
+				next = append(next, join(m, elem))
+				continue
+			}
+			listDir := m
+			if listDir == "" {
+				listDir = "."
+			}
+			entries, err := c.ReadDir(listDir)
+			if err != nil {
+				// A match that is not a directory, or cannot be read, just yields nothing
+				continue
+			}
+			for _, e := range entries {
+				if strings.HasPrefix(e.Name(), ".") && !strings.HasPrefix(elem, ".") {
+					continue
+				}
+				if ok, _ := path.Match(elem, e.Name()); ok {
+					next = append(next, join(m, e.Name()))
+				}
+			}
+		}
+		matches = next
+	}
+
+	// Literal elements after the last wildcard were never checked
+	found := matches
+	if !listed {
+		found = nil
+		for _, m := range matches {
+			if _, err := c.Lstat(m); err == nil {
+				found = append(found, m)
+			}
+		}
+	}
+	if len(found) == 0 {
+		return nil, &fs.PathError{Op: "glob", Path: pattern, Err: fs.ErrNotExist}
+	}
+	sort.Strings(found)
+	return found, nil
+}
+
+func join(dir, name string) string {
+	if dir == "" {
+		return name
+	}
+	return path.Join(dir, name)
+}
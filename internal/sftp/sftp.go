@@ -0,0 +1,541 @@
+// Package sftp is a read-only SFTP (protocol version 3) client, enough to list, stat, and
+// read files on the DGX without starting a remote shell.
+package sftp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+// Packet types of SFTP version 3
+const (
+	fxpInit     = 1
+	fxpVersion  = 2
+	fxpOpen     = 3
+	fxpClose    = 4
+	fxpRead     = 5
+	fxpLstat    = 7
+	fxpOpendir  = 11
+	fxpReaddir  = 12
+	fxpRealpath = 16
+	fxpStat     = 17
+	fxpStatus   = 101
+	fxpHandle   = 102
+	fxpData     = 103
+	fxpName     = 104
+	fxpAttrs    = 105
+)
+
+// Status codes
+const (
+	fxOK               = 0
+	fxEOF              = 1
+	fxNoSuchFile       = 2
+	fxPermissionDenied = 3
+)
+
+// Attribute flags
+const (
+	attrSize        = 0x1
+	attrUIDGID      = 0x2
+	attrPermissions = 0x4
+	attrACModTime   = 0x8
+	attrExtended    = 0x80000000
+)
+
+// fxfRead opens a file for reading
+const fxfRead = 0x1
+
+// protocolVersion is the version this client speaks
+const protocolVersion = 3
+
+// maxPacket bounds a reply; servers keep theirs far below this
+const maxPacket = 1 << 20
+
+// readSize is how much one read request asks for; 32 KiB is what every server accepts
+const readSize = 32 << 10
+
+// StatusError is a failure reported by the server
+type StatusError struct {
+	Code    uint32
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return fmt.Sprintf("sftp status %d", e.Code)
+}
+
+// Is makes errors.Is match fs.ErrNotExist and fs.ErrPermission
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case fs.ErrNotExist:
+		return e.Code == fxNoSuchFile
+	case fs.ErrPermission:
+		return e.Code == fxPermissionDenied
+	}
+	return false
+}
+
+// Client is an SFTP session. Requests are sent one at a time.
+type Client struct {
+	conn   io.ReadWriteCloser
+	mu     sync.Mutex
+	nextID uint32
+}
+
+// NewClient negotiates the protocol version over conn, typically the DGX's sftp subsystem
+func NewClient(conn io.ReadWriteCloser) (*Client, error) {
+	c := &Client{conn: conn}
+	var init packet
+	init.byte(fxpInit)
+	init.uint32(protocolVersion)
+	if err := c.write(init); err != nil {
+		return nil, fmt.Errorf("failed to start sftp: %w", err)
+	}
+	typ, data, err := c.read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start sftp: %w", err)
+	}
+	if typ != fxpVersion || len(data) < 4 {
+		return nil, fmt.Errorf("failed to start sftp: unexpected reply %d", typ)
+	}
+	if v := binary.BigEndian.Uint32(data); v < protocolVersion {
+		return nil, fmt.Errorf("the server speaks sftp version %d; version %d is required", v, protocolVersion)
+	}
+	return c, nil
+}
+
+// Close ends the session
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// FileInfo describes a remote file; it implements fs.FileInfo
+type FileInfo struct {
+	name  string
+	attrs attributes
+}
+
+func (fi *FileInfo) Name() string       { return fi.name }
+func (fi *FileInfo) Size() int64        { return int64(fi.attrs.size) }
+func (fi *FileInfo) Mode() fs.FileMode  { return fileMode(fi.attrs.permissions) }
+func (fi *FileInfo) ModTime() time.Time { return time.Unix(int64(fi.attrs.mtime), 0) }
+func (fi *FileInfo) IsDir() bool        { return fi.Mode().IsDir() }
+func (fi *FileInfo) Sys() interface{}   { return nil }
+
+// WithName returns a copy of fi named name, e.g. the path it was listed by
+func (fi *FileInfo) WithName(name string) *FileInfo {
+	return &FileInfo{name: name, attrs: fi.attrs}
+}
+
+// Owner returns the numeric user and group IDs
+func (fi *FileInfo) Owner() (uid, gid uint32) {
+	return fi.attrs.uid, fi.attrs.gid
+}
+
+// AccessTime returns the last access time
+func (fi *FileInfo) AccessTime() time.Time {
+	return time.Unix(int64(fi.attrs.atime), 0)
+}
+
+// Stat describes the file at p, following symbolic links
+func (c *Client) Stat(p string) (*FileInfo, error) {
+	return c.stat(fxpStat, p)
+}
+
+// Lstat describes the file at p without following a final symbolic link
+func (c *Client) Lstat(p string) (*FileInfo, error) {
+	return c.stat(fxpLstat, p)
+}
+
+func (c *Client) stat(typ byte, p string) (*FileInfo, error) {
+	var req packet
+	req.string(p)
+	reply, data, err := c.request(typ, req)
+	if err != nil {
+		return nil, pathError("stat", p, err)
+	}
+	if reply != fxpAttrs {
+		return nil, pathError("stat", p, fmt.Errorf("unexpected reply %d", reply))
+	}
+	r := reader{data: data}
+	attrs := r.attributes()
+	if r.err != nil {
+		return nil, pathError("stat", p, r.err)
+	}
+	return &FileInfo{name: path.Base(p), attrs: attrs}, nil
+}
+
+// RealPath resolves p, relative to the login directory, to a canonical absolute path
+func (c *Client) RealPath(p string) (string, error) {
+	var req packet
+	req.string(p)
+	reply, data, err := c.request(fxpRealpath, req)
+	if err != nil {
+		return "", pathError("realpath", p, err)
+	}
+	r := reader{data: data}
+	if reply != fxpName || r.uint32() < 1 {
+		return "", pathError("realpath", p, fmt.Errorf("unexpected reply %d", reply))
+	}
+	resolved := r.string()
+	return resolved, pathError("realpath", p, r.err)
+}
+
+// ReadDir lists the directory at p, without . and ..
+func (c *Client) ReadDir(p string) ([]*FileInfo, error) {
+	handle, err := c.open(fxpOpendir, p, nil)
+	if err != nil {
+		return nil, pathError("open", p, err)
+	}
+	defer c.closeHandle(handle)
+
+	var entries []*FileInfo
+	for {
+		var req packet
+		req.string(handle)
+		reply, data, err := c.request(fxpReaddir, req)
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return entries, pathError("readdir", p, err)
+		}
+		if reply != fxpName {
+			return entries, pathError("readdir", p, fmt.Errorf("unexpected reply %d", reply))
+		}
+		r := reader{data: data}
+		for n := r.uint32(); n > 0 && r.err == nil; n-- {
+			name := r.string()
+			r.string() // the ls -l style long name
+			attrs := r.attributes()
+			if r.err == nil && name != "." && name != ".." {
+				entries = append(entries, &FileInfo{name: name, attrs: attrs})
+			}
+		}
+		if r.err != nil {
+			return entries, pathError("readdir", p, r.err)
+		}
+	}
+}
+
+// File is a remote file open for reading
+type File struct {
+	c      *Client
+	path   string
+	handle string
+	offset int64
+}
+
+// Open opens the file at p for reading
+func (c *Client) Open(p string) (*File, error) {
+	var flags packet
+	flags.uint32(fxfRead)
+	flags.uint32(0) // no attributes
+	handle, err := c.open(fxpOpen, p, flags)
+	if err != nil {
+		return nil, pathError("open", p, err)
+	}
+	return &File{c: c, path: p, handle: handle}, nil
+}
+
+// ReadAt reads len(b) bytes at off; it returns io.EOF when fewer are left
+func (f *File) ReadAt(b []byte, off int64) (int, error) {
+	n := 0
+	for n < len(b) {
+		m, err := f.readChunk(b[n:], off+int64(n))
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Read reads from the current offset
+func (f *File) Read(b []byte) (int, error) {
+	n, err := f.readChunk(b, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+// Seek sets the offset for the next Read; io.SeekEnd is not supported, use Stat
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	default:
+		return f.offset, fmt.Errorf("seek %s: whence %d not supported", f.path, whence)
+	}
+	if offset < 0 {
+		return f.offset, fmt.Errorf("seek %s: negative offset", f.path)
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *File) readChunk(b []byte, off int64) (int, error) {
+	if len(b) > readSize {
+		b = b[:readSize]
+	}
+	var req packet
+	req.string(f.handle)
+	req.uint64(uint64(off))
+	req.uint32(uint32(len(b)))
+	reply, data, err := f.c.request(fxpRead, req)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return 0, io.EOF
+		}
+		return 0, pathError("read", f.path, err)
+	}
+	r := reader{data: data}
+	if reply != fxpData {
+		return 0, pathError("read", f.path, fmt.Errorf("unexpected reply %d", reply))
+	}
+	chunk := r.bytes()
+	if r.err != nil {
+		return 0, pathError("read", f.path, r.err)
+	}
+	return copy(b, chunk), nil
+}
+
+// Close releases the file handle
+func (f *File) Close() error {
+	return f.c.closeHandle(f.handle)
+}
+
+// open sends an OPEN or OPENDIR request and returns the handle
+func (c *Client) open(typ byte, p string, extra packet) (string, error) {
+	var req packet
+	req.string(p)
+	req = append(req, extra...)
+	reply, data, err := c.request(typ, req)
+	if err != nil {
+		return "", err
+	}
+	r := reader{data: data}
+	if reply != fxpHandle {
+		return "", fmt.Errorf("unexpected reply %d", reply)
+	}
+	handle := r.string()
+	return handle, r.err
+}
+
+func (c *Client) closeHandle(handle string) error {
+	var req packet
+	req.string(handle)
+	_, _, err := c.request(fxpClose, req)
+	return err
+}
+
+// request sends a request and returns the reply's type and payload after the request ID.
+// A STATUS reply other than OK is returned as a *StatusError, or io.EOF for end of file.
+func (c *Client) request(typ byte, payload packet) (byte, []byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nextID++
+	id := c.nextID
+
+	var p packet
+	p.byte(typ)
+	p.uint32(id)
+	p = append(p, payload...)
+	if err := c.write(p); err != nil {
+		return 0, nil, err
+	}
+	reply, data, err := c.read()
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(data) < 4 || binary.BigEndian.Uint32(data) != id {
+		return 0, nil, errors.New("sftp reply out of order")
+	}
+	data = data[4:]
+	if reply != fxpStatus {
+		return reply, data, nil
+	}
+	r := reader{data: data}
+	code := r.uint32()
+	message := r.string()
+	switch {
+	case code == fxOK:
+		return reply, nil, nil
+	case code == fxEOF:
+		return reply, nil, io.EOF
+	default:
+		return reply, nil, &StatusError{Code: code, Message: message}
+	}
+}
+
+func (c *Client) write(p packet) error {
+	frame := make([]byte, 4, 4+len(p))
+	binary.BigEndian.PutUint32(frame, uint32(len(p)))
+	_, err := c.conn.Write(append(frame, p...))
+	return err
+}
+
+func (c *Client) read() (byte, []byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(c.conn, header[:]); err != nil {
+		return 0, nil, fmt.Errorf("sftp connection lost: %w", err)
+	}
+	n := binary.BigEndian.Uint32(header[:])
+	if n < 1 || n > maxPacket {
+		return 0, nil, fmt.Errorf("invalid sftp packet length %d", n)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(c.conn, data); err != nil {
+		return 0, nil, fmt.Errorf("sftp connection lost: %w", err)
+	}
+	return data[0], data[1:], nil
+}
+
+// pathError adds the operation and path to a failure, keeping it matchable with errors.Is
+func pathError(op, p string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &fs.PathError{Op: op, Path: p, Err: err}
+}
+
+// attributes are the file attributes of SFTP version 3
+type attributes struct {
+	size        uint64
+	uid, gid    uint32
+	permissions uint32
+	atime       uint32
+	mtime       uint32
+}
+
+// fileMode converts POSIX st_mode bits to an fs.FileMode
+func fileMode(mode uint32) fs.FileMode {
+	m := fs.FileMode(mode & 0777)
+	switch mode & 0170000 {
+	case 0040000:
+		m |= fs.ModeDir
+	case 0120000:
+		m |= fs.ModeSymlink
+	case 0010000:
+		m |= fs.ModeNamedPipe
+	case 0140000:
+		m |= fs.ModeSocket
+	case 0020000:
+		m |= fs.ModeDevice | fs.ModeCharDevice
+	case 0060000:
+		m |= fs.ModeDevice
+	}
+	if mode&04000 != 0 {
+		m |= os.ModeSetuid
+	}
+	if mode&02000 != 0 {
+		m |= os.ModeSetgid
+	}
+	if mode&01000 != 0 {
+		m |= os.ModeSticky
+	}
+	return m
+}
+
+// packet builds a request payload
+type packet []byte
+
+func (p *packet) byte(b byte) {
+	*p = append(*p, b)
+}
+
+func (p *packet) uint32(v uint32) {
+	*p = binary.BigEndian.AppendUint32(*p, v)
+}
+
+func (p *packet) uint64(v uint64) {
+	*p = binary.BigEndian.AppendUint64(*p, v)
+}
+
+func (p *packet) string(s string) {
+	p.uint32(uint32(len(s)))
+	*p = append(*p, s...)
+}
+
+// reader decodes a reply payload; the first out-of-range read sets err
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.data) < n {
+		r.err = errors.New("short sftp packet")
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *reader) uint32() uint32 {
+	if b := r.next(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *reader) uint64() uint64 {
+	if b := r.next(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (r *reader) bytes() []byte {
+	n := r.uint32()
+	if n > maxPacket {
+		r.err = errors.New("short sftp packet")
+		return nil
+	}
+	return r.next(int(n))
+}
+
+func (r *reader) string() string {
+	return string(r.bytes())
+}
+
+func (r *reader) attributes() attributes {
+	var a attributes
+	flags := r.uint32()
+	if flags&attrSize != 0 {
+		a.size = r.uint64()
+	}
+	if flags&attrUIDGID != 0 {
+		a.uid = r.uint32()
+		a.gid = r.uint32()
+	}
+	if flags&attrPermissions != 0 {
+		a.permissions = r.uint32()
+	}
+	if flags&attrACModTime != 0 {
+		a.atime = r.uint32()
+		a.mtime = r.uint32()
+	}
+	if flags&attrExtended != 0 {
+		for n := r.uint32(); n > 0 && r.err == nil; n-- {
+			r.string()
+			r.string()
+		}
+	}
+	return a
+}
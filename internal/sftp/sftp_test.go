@@ -0,0 +1,244 @@
+package sftp
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// serve answers the requests the client sends with the files under root, the way
+// sftp-server does for a chrooted user
+func serve(t *testing.T, conn net.Conn, root string) {
+	handles := map[string]interface{}{}
+	send := func(p packet) {
+		frame := binary.BigEndian.AppendUint32(nil, uint32(len(p)))
+		conn.Write(append(frame, p...))
+	}
+	status := func(id, code uint32) {
+		var p packet
+		p.byte(fxpStatus)
+		p.uint32(id)
+		p.uint32(code)
+		p.string("status " + strconv.Itoa(int(code)))
+		p.string("")
+		send(p)
+	}
+	attrs := func(p *packet, fi os.FileInfo) {
+		st := fi.Sys().(*syscall.Stat_t)
+		p.uint32(attrSize | attrUIDGID | attrPermissions | attrACModTime)
+		p.uint64(uint64(fi.Size()))
+		p.uint32(st.Uid)
+		p.uint32(st.Gid)
+		p.uint32(st.Mode)
+		p.uint32(uint32(fi.ModTime().Unix()))
+		p.uint32(uint32(fi.ModTime().Unix()))
+	}
+	local := func(p string) string { return filepath.Join(root, filepath.FromSlash(p)) }
+
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(conn, header[:]); err != nil {
+			return
+		}
+		data := make([]byte, binary.BigEndian.Uint32(header[:]))
+		io.ReadFull(conn, data)
+		r := reader{data: data[1:]}
+		if data[0] == fxpInit {
+			var p packet
+			p.byte(fxpVersion)
+			p.uint32(3)
+			send(p)
+			continue
+		}
+		id := r.uint32()
+		switch data[0] {
+		case fxpStat, fxpLstat:
+			fi, err := os.Lstat(local(r.string()))
+			if err != nil {
+				status(id, fxNoSuchFile)
+				continue
+			}
+			var p packet
+			p.byte(fxpAttrs)
+			p.uint32(id)
+			attrs(&p, fi)
+			send(p)
+		case fxpRealpath:
+			var p packet
+			p.byte(fxpName)
+			p.uint32(id)
+			p.uint32(1)
+			p.string("/" + strings.TrimPrefix(filepath.Clean("/"+r.string()), "/"))
+			p.string("")
+			p.uint32(0)
+			send(p)
+		case fxpOpendir:
+			entries, err := os.ReadDir(local(r.string()))
+			if err != nil {
+				status(id, fxNoSuchFile)
+				continue
+			}
+			h := strconv.Itoa(len(handles))
+			handles[h] = entries
+			var p packet
+			p.byte(fxpHandle)
+			p.uint32(id)
+			p.string(h)
+			send(p)
+		case fxpReaddir:
+			h := r.string()
+			entries, _ := handles[h].([]os.DirEntry)
+			if len(entries) == 0 {
+				status(id, fxEOF)
+				continue
+			}
+			// Two per reply, so the client has to keep asking
+			batch := entries
+			if len(batch) > 2 {
+				batch = batch[:2]
+			}
+			handles[h] = entries[len(batch):]
+			var p packet
+			p.byte(fxpName)
+			p.uint32(id)
+			p.uint32(uint32(len(batch)))
+			for _, e := range batch {
+				fi, _ := e.Info()
+				p.string(e.Name())
+				p.string("long " + e.Name())
+				attrs(&p, fi)
+			}
+			send(p)
+		case fxpOpen:
+			f, err := os.Open(local(r.string()))
+			if err != nil {
+				status(id, fxNoSuchFile)
+				continue
+			}
+			h := strconv.Itoa(len(handles))
+			handles[h] = f
+			var p packet
+			p.byte(fxpHandle)
+			p.uint32(id)
+			p.string(h)
+			send(p)
+		case fxpRead:
+			f := handles[r.string()].(*os.File)
+			offset, length := r.uint64(), r.uint32()
+			// Short reads, as servers may return
+			buf := make([]byte, min(length, 5))
+			n, err := f.ReadAt(buf, int64(offset))
+			if n == 0 && err == io.EOF {
+				status(id, fxEOF)
+				continue
+			}
+			var p packet
+			p.byte(fxpData)
+			p.uint32(id)
+			p.string(string(buf[:n]))
+			send(p)
+		case fxpClose:
+			if f, ok := handles[r.string()].(*os.File); ok {
+				f.Close()
+			}
+			status(id, fxOK)
+		default:
+			t.Errorf("unexpected request %d", data[0])
+			return
+		}
+	}
+}
+
+func newTestClient(t *testing.T) (*Client, string) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"logs/train.log":    "epoch 1\nepoch 2\nepoch 3\n",
+		"logs/eval.log":     "ok\n",
+		"logs/.hidden":      "x",
+		"logs/old/a.log":    "aaaa",
+		"models/readme.txt": "",
+	} {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		os.WriteFile(p, []byte(content), 0644)
+	}
+
+	server, conn := net.Pipe()
+	go serve(t, server, root)
+	c, err := NewClient(conn)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c, root
+}
+
+func TestReadDirAndStat(t *testing.T) {
+	c, _ := newTestClient(t)
+	entries, err := c.ReadDir("logs")
+	if err != nil || len(entries) != 4 {
+		t.Fatalf("ReadDir = %d entries, %v", len(entries), err)
+	}
+	fi, err := c.Stat("logs/train.log")
+	if err != nil || fi.Size() != 24 || fi.IsDir() || fi.Mode().Perm() != 0644 {
+		t.Fatalf("Stat = %+v, %v", fi, err)
+	}
+	if fi, err := c.Stat("logs/old"); err != nil || !fi.IsDir() {
+		t.Fatalf("Stat(dir) = %+v, %v", fi, err)
+	}
+	if _, err := c.Stat("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Stat(missing) = %v, want fs.ErrNotExist", err)
+	}
+	if p, err := c.RealPath("logs/../models"); err != nil || p != "/models" {
+		t.Fatalf("RealPath = %q, %v", p, err)
+	}
+}
+
+func TestRead(t *testing.T) {
+	c, _ := newTestClient(t)
+	f, err := c.Open("logs/train.log")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil || string(data) != "epoch 1\nepoch 2\nepoch 3\n" {
+		t.Fatalf("ReadAll = %q, %v", data, err)
+	}
+	buf := make([]byte, 7)
+	if n, err := f.ReadAt(buf, 8); err != nil || string(buf[:n]) != "epoch 2" {
+		t.Fatalf("ReadAt = %q, %v", buf[:n], err)
+	}
+	if _, err := f.ReadAt(make([]byte, 10), 20); err != io.EOF {
+		t.Fatalf("ReadAt past the end = %v, want io.EOF", err)
+	}
+}
+
+func TestGlob(t *testing.T) {
+	c, _ := newTestClient(t)
+	cases := map[string]string{
+		"logs/*.log":     "logs/eval.log logs/train.log",
+		"logs/.h*":       "logs/.hidden",
+		"*/readme.txt":   "models/readme.txt",
+		"logs/*/a.log":   "logs/old/a.log",
+		"logs/train.log": "logs/train.log",
+		"/logs/[et]*":    "/logs/eval.log /logs/train.log",
+	}
+	for pattern, want := range cases {
+		got, err := c.Glob(pattern)
+		if err != nil || strings.Join(got, " ") != want {
+			t.Fatalf("Glob(%s) = %v, %v; want %s", pattern, got, err, want)
+		}
+	}
+	if _, err := c.Glob("logs/*.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Glob without matches = %v, want fs.ErrNotExist", err)
+	}
+}
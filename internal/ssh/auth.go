@@ -0,0 +1,150 @@
+package ssh
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+// passwordAttempts is how often a wrong password may be entered before giving up
+const passwordAttempts = 3
+
+// passwordMethods returns password and keyboard-interactive auth that prompt on the terminal.
+// A password remembered from an earlier login is tried before prompting.
+func (c *Client) passwordMethods() []ssh.AuthMethod {
+	tries := 0
+	password := ssh.PasswordCallback(func() (string, error) {
+		tries++
+		if tries == 1 && c.password != nil {
+			return string(c.password), nil
+		}
+		secret, err := ReadSecret(fmt.Sprintf("%s@%s's password: ", c.config.User, c.config.Host))
+		c.pendingPassword = secret
+		return string(secret), err
+	})
+	interactive := ssh.KeyboardInteractive(func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+		if name != "" {
+			fmt.Fprintln(os.Stderr, name)
+		}
+		if instruction != "" {
+			fmt.Fprintln(os.Stderr, instruction)
+		}
+		answers := make([]string, len(questions))
+		for i, question := range questions {
+			if echos[i] {
+				fmt.Fprint(os.Stderr, question)
+				line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+				if err != nil {
+					return nil, err
+				}
+				answers[i] = strings.TrimRight(line, "\r\n")
+				continue
+			}
+			secret, err := ReadSecret(question)
+			if err != nil {
+				return nil, err
+			}
+			answers[i] = string(secret)
+		}
+		return answers, nil
+	})
+	return []ssh.AuthMethod{
+		ssh.RetryableAuthMethod(password, passwordAttempts),
+		ssh.RetryableAuthMethod(interactive, passwordAttempts),
+	}
+}
+
+// ReadSecret prompts on stderr and reads a line from the terminal without echo
+func ReadSecret(prompt string) ([]byte, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, fmt.Errorf("cannot prompt for input: stdin is not a terminal")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	secret, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input: %w", err)
+	}
+	return secret, nil
+}
+
+// InstallAuthorizedKey appends pubKey to ~/.ssh/authorized_keys on the DGX unless it is
+// already there. It logs in with the configured key when that works and otherwise prompts
+// for the account password, like ssh-copy-id.
+func (c *Client) InstallAuthorizedKey(pubKey string) error {
+	var auth []ssh.AuthMethod
+	if signer, err := c.loadSigner(); err == nil {
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	auth = append(auth, c.passwordMethods()...)
+	if err := c.connect(auth); err != nil {
+		return connectionError(err)
+	}
+
+	if output, err := c.Execute(authorizeCommand(pubKey)); err != nil {
+		return fmt.Errorf("failed to install public key: %w: %s", err, strings.TrimSpace(output))
+	}
+	return nil
+}
+
+// authorizeCommand appends pubKey to ~/.ssh/authorized_keys unless the exact line is there
+func authorizeCommand(pubKey string) string {
+	key := ShellQuote(strings.TrimSpace(pubKey))
+	return "umask 077 && mkdir -p ~/.ssh && touch ~/.ssh/authorized_keys && " +
+		"chmod 700 ~/.ssh && chmod 600 ~/.ssh/authorized_keys && " +
+		fmt.Sprintf("{ grep -qxF %s ~/.ssh/authorized_keys || printf '%%s\\n' %s >> ~/.ssh/authorized_keys; }", key, key)
+}
+
+// GenerateKey writes a new ed25519 key pair to path and path.pub and returns the public key
+// in authorized_keys format. An empty passphrase leaves the private key unencrypted.
+func GenerateKey(path, comment string, passphrase []byte) (string, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	var block *pem.Block
+	if len(passphrase) > 0 {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(priv, comment, passphrase)
+	} else {
+		block, err = ssh.MarshalPrivateKey(priv, comment)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to encode private key: %w", err)
+	}
+
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode public key: %w", err)
+	}
+	authorized := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPub))) + " " + comment
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", fmt.Errorf("failed to create key directory: %w", err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		return "", fmt.Errorf("failed to write private key: %w", err)
+	}
+	if err := os.WriteFile(path+".pub", []byte(authorized+"\n"), 0644); err != nil {
+		return "", fmt.Errorf("failed to write public key: %w", err)
+	}
+	return authorized, nil
+}
+
+// PublicKeyFile returns the authorized_keys line stored next to a private key
+func PublicKeyFile(path string) (string, error) {
+	data, err := os.ReadFile(path + ".pub")
+	if err != nil {
+		return "", fmt.Errorf("failed to read public key: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,78 @@
+package ssh
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestGenerateKey(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "keys", "id_ed25519_dgx")
+	authorized, err := GenerateKey(path, "dgx@laptop", nil)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	if !strings.HasPrefix(authorized, "ssh-ed25519 ") || !strings.HasSuffix(authorized, " dgx@laptop") {
+		t.Fatalf("unexpected authorized_keys line: %q", authorized)
+	}
+	if stored, err := PublicKeyFile(path); err != nil || stored != authorized {
+		t.Fatalf("PublicKeyFile = %q, %v; want %q", stored, err, authorized)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("private key mode: %v, %v", info, err)
+	}
+
+	data, _ := os.ReadFile(path)
+	signer, err := ssh.ParsePrivateKey(data)
+	if err != nil {
+		t.Fatalf("the unencrypted key does not parse: %v", err)
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(authorized))
+	if err != nil || string(pub.Marshal()) != string(signer.PublicKey().Marshal()) {
+		t.Fatalf("the public key does not match the private key: %v", err)
+	}
+
+	encrypted := filepath.Join(dir, "encrypted")
+	if _, err := GenerateKey(encrypted, "dgx", []byte("hunter2")); err != nil {
+		t.Fatalf("GenerateKey with a passphrase: %v", err)
+	}
+	data, _ = os.ReadFile(encrypted)
+	if _, err := ssh.ParsePrivateKey(data); err == nil {
+		t.Fatalf("a key with a passphrase should be encrypted")
+	}
+	if _, err := ssh.ParsePrivateKeyWithPassphrase(data, []byte("hunter2")); err != nil {
+		t.Fatalf("the passphrase does not decrypt the key: %v", err)
+	}
+
+	if _, err := PublicKeyFile(filepath.Join(dir, "missing")); err == nil {
+		t.Fatalf("expected an error for a missing public key")
+	}
+}
+
+func TestAuthorizeCommand(t *testing.T) {
+	home := t.TempDir()
+	key := "ssh-ed25519 AAAAC3Nza it's-me"
+	for range 2 {
+		cmd := exec.Command("sh", "-c", authorizeCommand(key+"\n"))
+		cmd.Env = append(os.Environ(), "HOME="+home)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("authorize command failed: %v: %s", err, output)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(home, ".ssh", "authorized_keys"))
+	if err != nil {
+		t.Fatalf("authorized_keys not written: %v", err)
+	}
+	if string(data) != key+"\n" {
+		t.Fatalf("authorized_keys = %q, want the key once", data)
+	}
+	if info, _ := os.Stat(filepath.Join(home, ".ssh")); info.Mode().Perm() != 0700 {
+		t.Fatalf("~/.ssh mode = %v", info.Mode().Perm())
+	}
+}
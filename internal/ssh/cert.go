@@ -0,0 +1,139 @@
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/weatherman/dgx-manager/pkg/types"
+	"golang.org/x/crypto/ssh"
+)
+
+// CertExpiryWarning is how close to expiry a certificate must be before dgx warns
+const CertExpiryWarning = 10 * time.Minute
+
+// CertificatePath returns the user certificate for cfg's key: certificate_file, or the
+// OpenSSH default <identity_file>-cert.pub when that exists. It is empty when there is none.
+func CertificatePath(cfg *types.Config) string {
+	if cfg.CertificateFile != "" {
+		return cfg.CertificateFile
+	}
+	if cfg.IdentityFile == "" {
+		return ""
+	}
+	path := cfg.IdentityFile + "-cert.pub"
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// LoadCertificate reads an OpenSSH user certificate, such as one written by ssh-keygen -s
+// or a CA like step-ca or Vault
+func LoadCertificate(path string) (*ssh.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SSH certificate: %w", err)
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SSH certificate %s: %w", path, err)
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%s is a public key, not a certificate", path)
+	}
+	if cert.CertType != ssh.UserCert {
+		return nil, fmt.Errorf("%s is a host certificate, not a user certificate", path)
+	}
+	return cert, nil
+}
+
+// CheckCertificate reports why cert cannot log in as user at now: outside its validity
+// window, or not issued for that user
+func CheckCertificate(cert *ssh.Certificate, user string, now time.Time) error {
+	if after := certTime(cert.ValidAfter); cert.ValidAfter != 0 && now.Before(after) {
+		return fmt.Errorf("SSH certificate %q is not valid until %s", cert.KeyId, after.Local().Format(time.RFC1123))
+	}
+	if cert.ValidBefore != ssh.CertTimeInfinity && !now.Before(certTime(cert.ValidBefore)) {
+		return fmt.Errorf("SSH certificate %q expired at %s; get a new one from your certificate authority", cert.KeyId, certTime(cert.ValidBefore).Local().Format(time.RFC1123))
+	}
+	if len(cert.ValidPrincipals) > 0 && user != "" && !slices.Contains(cert.ValidPrincipals, user) {
+		return fmt.Errorf("SSH certificate %q is for %v, not user %s", cert.KeyId, cert.ValidPrincipals, user)
+	}
+	return nil
+}
+
+// CertificateInfo summarizes a certificate for display
+type CertificateInfo struct {
+	KeyID      string
+	Serial     uint64
+	Principals []string  // empty means any user
+	CA         string    // SHA256 fingerprint of the signing CA key
+	ValidAfter time.Time // zero when valid from the start
+	Expires    time.Time // zero when it never expires
+}
+
+// DescribeCertificate returns the fields of cert worth showing
+func DescribeCertificate(cert *ssh.Certificate) CertificateInfo {
+	info := CertificateInfo{
+		KeyID:      cert.KeyId,
+		Serial:     cert.Serial,
+		Principals: cert.ValidPrincipals,
+		CA:         cert.SignatureKey.Type() + " " + ssh.FingerprintSHA256(cert.SignatureKey),
+	}
+	if cert.ValidAfter != 0 {
+		info.ValidAfter = certTime(cert.ValidAfter)
+	}
+	if cert.ValidBefore != ssh.CertTimeInfinity {
+		info.Expires = certTime(cert.ValidBefore)
+	}
+	return info
+}
+
+// CheckCertificateKey reports whether cert was issued for the key at identityFile, going
+// by the public key stored next to it
+func CheckCertificateKey(cert *ssh.Certificate, identityFile string) error {
+	authorized, err := PublicKeyFile(identityFile)
+	if err != nil {
+		return err
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(authorized))
+	if err != nil {
+		return fmt.Errorf("failed to parse public key %s.pub: %w", identityFile, err)
+	}
+	if !bytes.Equal(pub.Marshal(), cert.Key.Marshal()) {
+		return fmt.Errorf("SSH certificate %q was issued for a different key than %s", cert.KeyId, identityFile)
+	}
+	return nil
+}
+
+// certSigner pairs signer with the configured certificate, if any, so the server sees the
+// certificate instead of the bare key
+func (c *Client) certSigner(signer ssh.Signer) (ssh.Signer, error) {
+	path := CertificatePath(c.config)
+	if path == "" {
+		return signer, nil
+	}
+	cert, err := LoadCertificate(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckCertificate(cert, c.config.User, time.Now()); err != nil {
+		return nil, err
+	}
+	if expires := DescribeCertificate(cert).Expires; !expires.IsZero() && time.Until(expires) < CertExpiryWarning {
+		fmt.Fprintf(os.Stderr, "Warning: SSH certificate %q expires in %v\n", cert.KeyId, time.Until(expires).Round(time.Second))
+	}
+	certSigner, err := ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, fmt.Errorf("SSH certificate %s does not belong to %s: %w", path, c.config.IdentityFile, err)
+	}
+	return certSigner, nil
+}
+
+func certTime(t uint64) time.Time {
+	return time.Unix(int64(t), 0)
+}
@@ -0,0 +1,106 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weatherman/dgx-manager/pkg/types"
+	"golang.org/x/crypto/ssh"
+)
+
+// signedCert writes a new key pair to dir with a certificate for it signed by a fresh CA
+func signedCert(t *testing.T, dir string, principals []string, after, before uint64) (string, *ssh.Certificate) {
+	t.Helper()
+	keyFile := filepath.Join(dir, "id_ed25519")
+	authorized, err := GenerateKey(keyFile, "test", nil)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(authorized))
+	if err != nil {
+		t.Fatalf("ParseAuthorizedKey: %v", err)
+	}
+	_, caKey, _ := ed25519.GenerateKey(rand.Reader)
+	ca, err := ssh.NewSignerFromKey(caKey)
+	if err != nil {
+		t.Fatalf("NewSignerFromKey: %v", err)
+	}
+	cert := &ssh.Certificate{
+		Key:             pub,
+		Serial:          7,
+		CertType:        ssh.UserCert,
+		KeyId:           "alice@example.com",
+		ValidPrincipals: principals,
+		ValidAfter:      after,
+		ValidBefore:     before,
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatalf("SignCert: %v", err)
+	}
+	if err := os.WriteFile(keyFile+"-cert.pub", ssh.MarshalAuthorizedKey(cert), 0644); err != nil {
+		t.Fatalf("write certificate: %v", err)
+	}
+	return keyFile, cert
+}
+
+func TestCertificate(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	keyFile, _ := signedCert(t, dir, []string{"alice", "ubuntu"}, uint64(now.Add(-time.Hour).Unix()), uint64(now.Add(time.Hour).Unix()))
+
+	cfg := &types.Config{User: "alice", IdentityFile: keyFile}
+	path := CertificatePath(cfg)
+	if path != keyFile+"-cert.pub" {
+		t.Fatalf("CertificatePath = %q, want the -cert.pub next to the key", path)
+	}
+	cert, err := LoadCertificate(path)
+	if err != nil {
+		t.Fatalf("LoadCertificate: %v", err)
+	}
+	if err := CheckCertificate(cert, "alice", now); err != nil {
+		t.Fatalf("CheckCertificate: %v", err)
+	}
+	if err := CheckCertificate(cert, "bob", now); err == nil || !strings.Contains(err.Error(), "not user bob") {
+		t.Fatalf("expected a principal error, got %v", err)
+	}
+	if err := CheckCertificate(cert, "alice", now.Add(2*time.Hour)); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Fatalf("expected an expiry error, got %v", err)
+	}
+	if err := CheckCertificate(cert, "alice", now.Add(-2*time.Hour)); err == nil || !strings.Contains(err.Error(), "not valid until") {
+		t.Fatalf("expected a not-yet-valid error, got %v", err)
+	}
+	if err := CheckCertificateKey(cert, keyFile); err != nil {
+		t.Fatalf("CheckCertificateKey: %v", err)
+	}
+	if info := DescribeCertificate(cert); info.KeyID != "alice@example.com" || info.Serial != 7 || info.Expires.IsZero() || !strings.HasPrefix(info.CA, "ssh-ed25519 SHA256:") {
+		t.Fatalf("unexpected info %+v", info)
+	}
+
+	client := &Client{config: cfg}
+	signer, err := client.loadSigner()
+	if err != nil {
+		t.Fatalf("loadSigner: %v", err)
+	}
+	if _, ok := signer.PublicKey().(*ssh.Certificate); !ok {
+		t.Fatalf("loadSigner should present the certificate, got %s", signer.PublicKey().Type())
+	}
+
+	// A certificate for another key is rejected before connecting
+	otherDir := t.TempDir()
+	_, otherCert := signedCert(t, otherDir, nil, 0, ssh.CertTimeInfinity)
+	if err := CheckCertificateKey(otherCert, keyFile); err == nil {
+		t.Fatalf("expected a key mismatch error")
+	}
+	if info := DescribeCertificate(otherCert); !info.Expires.IsZero() || !info.ValidAfter.IsZero() {
+		t.Fatalf("unbounded certificate should have zero times, got %+v", info)
+	}
+	cfg.CertificateFile = filepath.Join(otherDir, "id_ed25519-cert.pub")
+	if _, err := (&Client{config: cfg}).loadSigner(); err == nil {
+		t.Fatalf("expected loadSigner to reject a certificate for another key")
+	}
+}
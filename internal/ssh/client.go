@@ -1,23 +1,39 @@
 package ssh
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/redact"
 	"github.com/weatherman/dgx-manager/pkg/types"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/term"
 )
 
+// promptIdle is how long RunInteractive holds back a line without a newline before
+// showing it, as a prompt waiting for input would be
+const promptIdle = 100 * time.Millisecond
+
 // Client manages SSH connections to the DGX
 type Client struct {
 	config *types.Config
 	client *ssh.Client
+	signer ssh.Signer // decrypted once per process
+	// password is remembered after a successful password login so reconnects do not prompt
+	password        []byte
+	pendingPassword []byte
+	mu              sync.Mutex // guards reconnects from concurrent Dial callers
 }
 
 // NewClient creates a new SSH client
@@ -27,19 +43,40 @@ func NewClient(config *types.Config) (*Client, error) {
 	}, nil
 }
 
-// Connect establishes an SSH connection
+// Host returns the DGX host name this client connects to
+func (c *Client) Host() string {
+	return c.config.Host
+}
+
+// Connect establishes an SSH connection. Without a key file it falls back to password and
+// keyboard-interactive authentication when running in a terminal.
 func (c *Client) Connect() error {
-	// Load SSH key
-	key, err := os.ReadFile(c.config.IdentityFile)
-	if err != nil {
-		return fmt.Errorf("failed to read SSH key: %w", err)
-	}
+	return connectionError(c.login())
+}
 
-	signer, err := ssh.ParsePrivateKey(key)
-	if err != nil {
-		return fmt.Errorf("failed to parse SSH key: %w", err)
+func (c *Client) login() error {
+	// Load SSH key
+	signer, err := c.loadSigner()
+	switch {
+	case err == nil:
+		return c.connect([]ssh.AuthMethod{ssh.PublicKeys(signer)})
+	case errors.Is(err, errNoKey) || errors.Is(err, os.ErrNotExist):
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return fmt.Errorf("%w; password authentication needs a terminal (run 'dgx key setup' to install a key)", err)
+		}
+		if err := c.connect(c.passwordMethods()); err != nil {
+			return err
+		}
+		c.password = c.pendingPassword
+		return nil
+	default:
+		return err
 	}
+}
 
+// connect dials the DGX with the given auth methods, verifying the host key against
+// known_hosts and offering to trust unknown hosts
+func (c *Client) connect(auth []ssh.AuthMethod) error {
 	// Load known_hosts
 	home, _ := os.UserHomeDir()
 	knownHostsPath := fmt.Sprintf("%s/.ssh/known_hosts", home)
@@ -50,7 +87,7 @@ func (c *Client) Connect() error {
 		var response string
 		fmt.Scanln(&response)
 		if response != "" && strings.ToLower(response) != "y" {
-			return fmt.Errorf("connection aborted: host key not trusted")
+			return fmt.Errorf("connection %w: host key not trusted", exitcode.ErrAborted)
 		}
 		if err := c.addHostKey(); err != nil {
 			return fmt.Errorf("failed to initialize known_hosts: %w", err)
@@ -64,17 +101,15 @@ func (c *Client) Connect() error {
 
 	// SSH client configuration
 	sshConfig := &ssh.ClientConfig{
-		User: c.config.User,
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeys(signer),
-		},
+		User:            c.config.User,
+		Auth:            auth,
 		HostKeyCallback: hostKeyCallback,
-		Timeout:         10 * time.Second,
 	}
+	clientConfig(sshConfig, c.config.SSH)
 
 	// Connect
 	addr := fmt.Sprintf("%s:%d", c.config.Host, c.config.Port)
-	client, err := ssh.Dial("tcp", addr, sshConfig)
+	client, err := dial(addr, sshConfig, c.config.SSH)
 	if err != nil {
 		// Check if it's a known_hosts error
 		if strings.Contains(err.Error(), "knownhosts:") || strings.Contains(err.Error(), "key is unknown") {
@@ -99,22 +134,62 @@ func (c *Client) Connect() error {
 				}
 				sshConfig.HostKeyCallback = hostKeyCallback
 
-				client, err = ssh.Dial("tcp", addr, sshConfig)
+				client, err = dial(addr, sshConfig, c.config.SSH)
 				if err != nil {
 					return fmt.Errorf("failed to connect after adding host key: %w", err)
 				}
 			} else {
-				return fmt.Errorf("connection aborted: host key not trusted")
+				return fmt.Errorf("connection %w: host key not trusted", exitcode.ErrAborted)
 			}
-		} else {
+		} else if client, err = c.relocate(sshConfig, hostKeyCallback, knownHostsPath, err); err != nil {
 			return fmt.Errorf("failed to connect to %s: %w", addr, err)
 		}
 	}
 
 	c.client = client
+	if tracker != nil {
+		tracker.Connected(c.config, c)
+	}
 	return nil
 }
 
+// connectionError tags a failure to connect so the CLI exits with the connectivity code.
+// Declined host key prompts keep the abort code.
+func connectionError(err error) error {
+	if err == nil || errors.Is(err, exitcode.ErrAborted) {
+		return err
+	}
+	return exitcode.Wrap(exitcode.Connection, err)
+}
+
+// nativeSSHError classifies an ssh(1) failure: ssh exits 255 when it cannot connect and
+// otherwise passes on the remote command's status
+func nativeSSHError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() != 255 {
+		return exitcode.Wrap(exitcode.Remote, err)
+	}
+	return connectionError(err)
+}
+
+// Disconnected reports whether err from RunInteractive means ssh(1) lost the connection
+// (it exits 255), as when the DGX tears the session down while rebooting, rather than that
+// the remote command failed
+func Disconnected(err error) bool {
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr) && exitErr.ExitCode() == 255
+}
+
+// RemoteExitStatus returns the remote command's exit status when err came from a command
+// that ran and failed, as opposed to one that could not be started
+func RemoteExitStatus(err error) (int, bool) {
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitStatus(), true
+	}
+	return 0, false
+}
+
 // Close closes the SSH connection
 func (c *Client) Close() error {
 	if c.client != nil {
@@ -155,6 +230,12 @@ func (c *Client) addHostKey() error {
 
 // Execute runs a command on the remote host
 func (c *Client) Execute(command string) (string, error) {
+	return c.ExecuteContext(context.Background(), command)
+}
+
+// ExecuteContext runs a command like Execute, closing the session if ctx ends first.
+// Its output is meant for parsing, so the command runs under ParseLocale.
+func (c *Client) ExecuteContext(ctx context.Context, command string) (string, error) {
 	// Ensure we're connected
 	if c.client == nil {
 		if err := c.Connect(); err != nil {
@@ -175,46 +256,145 @@ func (c *Client) Execute(command string) (string, error) {
 	}
 	defer session.Close()
 
-	output, err := session.CombinedOutput(command)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			session.Close()
+		case <-done:
+		}
+	}()
+
+	output, err := session.CombinedOutput(WithParseLocale(command))
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return string(output), ctxErr
+	}
 	if err != nil {
-		return string(output), fmt.Errorf("command failed: %w", err)
+		return string(output), exitcode.Wrap(exitcode.Remote, fmt.Errorf("command failed: %w", err))
 	}
 
 	return string(output), nil
 }
 
+// Stream runs a command on the remote host, wiring the given readers/writers to the
+// session instead of buffering output. Any of stdin, stdout, or stderr may be nil.
+func (c *Client) Stream(command string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if c.client == nil {
+		if err := c.Connect(); err != nil {
+			return err
+		}
+	}
+
+	session, err := c.client.NewSession()
+	if err != nil {
+		if err := c.Connect(); err != nil {
+			return fmt.Errorf("failed to reconnect: %w", err)
+		}
+		session, err = c.client.NewSession()
+		if err != nil {
+			return fmt.Errorf("failed to create session: %w", err)
+		}
+	}
+	defer session.Close()
+
+	session.Stdin = stdin
+	session.Stdout = stdout
+	session.Stderr = stderr
+
+	if err := session.Run(command); err != nil {
+		return exitcode.Wrap(exitcode.Remote, fmt.Errorf("command failed: %w", err))
+	}
+	return nil
+}
+
+// subsystemConn is a subsystem session's stdin and stdout; Close ends the session
+type subsystemConn struct {
+	io.Reader
+	io.WriteCloser
+	session *ssh.Session
+}
+
+func (s *subsystemConn) Close() error {
+	s.WriteCloser.Close()
+	return s.session.Close()
+}
+
+// Subsystem starts the named SSH subsystem, such as sftp, and returns a connection to it
+func (c *Client) Subsystem(name string) (io.ReadWriteCloser, error) {
+	if c.client == nil {
+		if err := c.Connect(); err != nil {
+			return nil, err
+		}
+	}
+
+	session, err := c.client.NewSession()
+	if err != nil {
+		if err := c.Connect(); err != nil {
+			return nil, fmt.Errorf("failed to reconnect: %w", err)
+		}
+		session, err = c.client.NewSession()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create session: %w", err)
+		}
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	if err := session.RequestSubsystem(name); err != nil {
+		session.Close()
+		return nil, fmt.Errorf("failed to start the %s subsystem: %w", name, err)
+	}
+	return &subsystemConn{Reader: stdout, WriteCloser: stdin, session: session}, nil
+}
+
 // InteractiveShell starts an interactive SSH shell
 func (c *Client) InteractiveShell() error {
+	c.locateNative()
 	// Use native SSH command for interactive shell (better terminal handling)
-	args := []string{
-		"-i", c.config.IdentityFile,
+	args := append(NativeArgs(c.config),
 		"-p", fmt.Sprintf("%d", c.config.Port),
 		fmt.Sprintf("%s@%s", c.config.User, c.config.Host),
-	}
+	)
 
 	cmd := exec.Command("ssh", args...)
+	cmd.Env = AgentEnv(c.config)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
-	return cmd.Run()
+	return nativeSSHError(cmd.Run())
 }
 
 // RunInteractive executes a command on the remote host with local stdin/stdout attached.
 func (c *Client) RunInteractive(command string) error {
-	args := []string{
-		"-i", c.config.IdentityFile,
+	c.locateNative()
+	args := append(NativeArgs(c.config),
 		"-p", fmt.Sprintf("%d", c.config.Port),
 		fmt.Sprintf("%s@%s", c.config.User, c.config.Host),
 		"bash", "-lc", command,
-	}
+	)
 
+	// Secrets are masked on the way; prompts without a newline still show after a pause
+	stdout := redact.NewWriter(os.Stdout, redact.Default()).FlushIdle(promptIdle)
+	stderr := redact.NewWriter(os.Stderr, redact.Default()).FlushIdle(promptIdle)
 	cmd := exec.Command("ssh", args...)
+	cmd.Env = AgentEnv(c.config)
 	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 
-	return cmd.Run()
+	err := cmd.Run()
+	stdout.Flush()
+	stderr.Flush()
+	return nativeSSHError(err)
 }
 
 // CheckConnection tests the connection without keeping it open
@@ -230,6 +410,45 @@ func (c *Client) CheckConnection() (time.Duration, error) {
 	return latency, nil
 }
 
+// IsReachable reports whether the SSH port accepts TCP connections within the timeout
+func (c *Client) IsReachable(timeout time.Duration) bool {
+	addr := net.JoinHostPort(c.config.Host, strconv.Itoa(c.config.Port))
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// Dial opens a connection to addr as seen from the remote host, over the SSH connection
+func (c *Client) Dial(network, addr string) (net.Conn, error) {
+	c.mu.Lock()
+	if c.client == nil {
+		if err := c.Connect(); err != nil {
+			c.mu.Unlock()
+			return nil, err
+		}
+	}
+	client := c.client
+	c.mu.Unlock()
+
+	conn, err := client.Dial(network, addr)
+	if err == nil {
+		return conn, nil
+	}
+
+	// The SSH connection may have dropped; reconnect once unless another caller already did
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client == client {
+		if err := c.Connect(); err != nil {
+			return nil, fmt.Errorf("failed to reconnect: %w", err)
+		}
+	}
+	return c.client.Dial(network, addr)
+}
+
 // ForwardPort creates an SSH tunnel
 func (c *Client) ForwardPort(localPort, remotePort int, remoteHost string) error {
 	if c.client == nil {
@@ -290,42 +509,85 @@ func (c *Client) handleForward(localConn net.Conn, remoteHost string, remotePort
 
 // CopyFile transfers a file using SCP
 func (c *Client) CopyFile(source, dest string) error {
-	args := []string{
-		"-i", c.config.IdentityFile,
+	args := append(NativeArgs(c.config),
 		"-P", fmt.Sprintf("%d", c.config.Port),
 		"-r",
 		source,
 		dest,
-	}
+	)
 
 	cmd := exec.Command("scp", args...)
+	cmd.Env = AgentEnv(c.config)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
 	return cmd.Run()
 }
 
-// Rsync syncs files using rsync over SSH
-func (c *Client) Rsync(source, dest string, deleteExtraneous bool) error {
+// Rsync syncs files using rsync over SSH. rsh replaces the remote shell command passed to
+// rsync -e; when empty, ssh(1) is used as given by SSHCommand. extra is passed to rsync
+// ahead of the paths.
+func (c *Client) Rsync(source, dest string, deleteExtraneous bool, rsh string, extra ...string) error {
+	if rsh == "" {
+		rsh = SSHCommand(c.config)
+	}
 	args := []string{
 		"-avz",
 		"--progress",
-		"-e", fmt.Sprintf("ssh -i %s -p %d", c.config.IdentityFile, c.config.Port),
+		"-e", rsh,
 	}
 
 	if deleteExtraneous {
 		args = append(args, "--delete")
 	}
 
+	args = append(args, extra...)
 	args = append(args, source, dest)
 
 	cmd := exec.Command("rsync", args...)
+	cmd.Env = AgentEnv(c.config)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
 	return cmd.Run()
 }
 
+// IdentityArgs returns the ssh(1) -i arguments for cfg, or none when no key file exists so
+// ssh falls back to its own password prompt. ssh finds <key>-cert.pub itself; a
+// certificate_file elsewhere is passed with -o CertificateFile.
+func IdentityArgs(cfg *types.Config) []string {
+	if cfg.IdentityFile == "" {
+		return nil
+	}
+	if _, err := os.Stat(cfg.IdentityFile); err != nil {
+		return nil
+	}
+	args := []string{"-i", cfg.IdentityFile}
+	if cfg.CertificateFile != "" {
+		args = append(args, "-o", "CertificateFile="+cfg.CertificateFile)
+	}
+	return args
+}
+
+// NativeArgs returns IdentityArgs followed by the -o arguments for cfg's ssh settings,
+// for every ssh(1) and scp(1) invocation
+func NativeArgs(cfg *types.Config) []string {
+	return append(IdentityArgs(cfg), OptionArgs(cfg.SSH)...)
+}
+
+// SSHCommand returns an ssh command line for tools that take one, such as rsync -e
+func SSHCommand(cfg *types.Config) string {
+	command := "ssh"
+	for _, arg := range NativeArgs(cfg) {
+		if strings.HasPrefix(arg, "-") {
+			command += " " + arg
+		} else {
+			command += fmt.Sprintf(" %q", arg)
+		}
+	}
+	return fmt.Sprintf("%s -p %d", command, cfg.Port)
+}
+
 // ShellQuote safely quotes a string for use in shell commands.
 // It wraps the value in single quotes and escapes any embedded single quotes.
 func ShellQuote(value string) string {
@@ -334,3 +596,16 @@ func ShellQuote(value string) string {
 	}
 	return "'" + strings.ReplaceAll(value, "'", "'\"'\"'") + "'"
 }
+
+// QuoteRemotePath quotes a DGX path for a remote shell while keeping ~ meaningful: "~/x"
+// and relative paths resolve against the remote home directory.
+func QuoteRemotePath(p string) string {
+	if p == "~" {
+		return `"$HOME"`
+	}
+	p = strings.TrimPrefix(p, "~/")
+	if strings.HasPrefix(p, "/") {
+		return ShellQuote(p)
+	}
+	return `"$HOME"/` + ShellQuote(p)
+}
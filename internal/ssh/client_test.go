@@ -0,0 +1,74 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/weatherman/dgx-manager/pkg/types"
+
+	"golang.org/x/term"
+)
+
+func TestDisconnected(t *testing.T) {
+	status := func(code string) error {
+		return nativeSSHError(exec.Command("sh", "-c", "exit "+code).Run())
+	}
+	if !Disconnected(status("255")) {
+		t.Fatalf("ssh exiting 255 should count as a dropped connection")
+	}
+	// sudo failing (a wrong password) or the command's own failure passes its status on
+	for _, code := range []string{"1", "127"} {
+		if Disconnected(status(code)) {
+			t.Fatalf("exit %s should count as a failed command", code)
+		}
+	}
+	if Disconnected(nil) || Disconnected(errors.New("connection refused")) {
+		t.Fatalf("only ssh's own exit status counts as a dropped connection")
+	}
+}
+
+func TestIdentityArgs(t *testing.T) {
+	key := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(key, []byte("key"), 0600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+
+	cfg := &types.Config{Port: 2222}
+	if args := IdentityArgs(cfg); args != nil {
+		t.Fatalf("expected no -i without a key, got %v", args)
+	}
+	if got := SSHCommand(cfg); got != "ssh -p 2222" {
+		t.Fatalf("SSHCommand without a key = %q", got)
+	}
+	cfg.IdentityFile = key + ".missing"
+	if args := IdentityArgs(cfg); args != nil {
+		t.Fatalf("expected no -i for a missing key file, got %v", args)
+	}
+
+	cfg.IdentityFile = key
+	if args := IdentityArgs(cfg); !reflect.DeepEqual(args, []string{"-i", key}) {
+		t.Fatalf("IdentityArgs = %v", args)
+	}
+	if got, want := SSHCommand(cfg), fmt.Sprintf("ssh -i %q -p 2222", key); got != want {
+		t.Fatalf("SSHCommand = %q, want %q", got, want)
+	}
+}
+
+func TestConnectWithoutKeyNeedsTerminal(t *testing.T) {
+	c, _ := NewClient(&types.Config{Host: "127.0.0.1", Port: 1, User: "alice"})
+	if _, err := c.loadSigner(); !errors.Is(err, errNoKey) {
+		t.Fatalf("loadSigner without a key = %v, want errNoKey", err)
+	}
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		t.Skip("stdin is a terminal, so Connect would prompt for a password")
+	}
+	if err := c.Connect(); err == nil || !errors.Is(err, errNoKey) || !strings.Contains(err.Error(), "needs a terminal") {
+		t.Fatalf("Connect without a key or terminal = %v", err)
+	}
+}
@@ -0,0 +1,204 @@
+package ssh
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/term"
+
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// KeyCacheAgent caches decrypted keys in an ssh-agent spawned by dgx
+const KeyCacheAgent = "agent"
+
+// DefaultKeyCacheTTL is how long a cached key stays usable when key_cache_ttl is unset
+const DefaultKeyCacheTTL = time.Hour
+
+// errNoKey means no identity file is configured
+var errNoKey = errors.New("no SSH key configured")
+
+// passphraseAttempts is how often a wrong passphrase may be entered before giving up
+const passphraseAttempts = 3
+
+// AgentSocketPath returns the socket of the ssh-agent dgx spawns to cache keys
+func AgentSocketPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "dgx", "agent.sock")
+}
+
+// AgentEnv returns the environment for ssh child processes. When the dgx agent is running,
+// SSH_AUTH_SOCK points at it so cached keys are reused instead of prompting again, but only
+// if no agent of the user's own is set or cfg has dgx cache its key (key_cache: agent).
+func AgentEnv(cfg *types.Config) []string {
+	env := os.Environ()
+	if os.Getenv("SSH_AUTH_SOCK") != "" && cfg.KeyCache != KeyCacheAgent {
+		return env
+	}
+	sock := AgentSocketPath()
+	if conn, err := net.Dial("unix", sock); err == nil {
+		conn.Close()
+		env = append(env, "SSH_AUTH_SOCK="+sock)
+	}
+	return env
+}
+
+// loadSigner loads the identity file, with its certificate when one is configured or sits
+// next to it. Encrypted keys are taken from a running agent when it already holds them,
+// otherwise the passphrase is prompted for once per process.
+func (c *Client) loadSigner() (ssh.Signer, error) {
+	if c.signer != nil {
+		return c.signer, nil
+	}
+
+	if c.config.IdentityFile == "" {
+		return nil, errNoKey
+	}
+	key, err := os.ReadFile(c.config.IdentityFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SSH key: %w", err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(key)
+	var missing *ssh.PassphraseMissingError
+	switch {
+	case err == nil:
+	case errors.As(err, &missing):
+		signer, err = c.encryptedKeySigner(key, missing.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("failed to parse SSH key: %w", err)
+	}
+	if signer, err = c.certSigner(signer); err != nil {
+		return nil, err
+	}
+
+	c.signer = signer
+	return signer, nil
+}
+
+func (c *Client) encryptedKeySigner(key []byte, pub ssh.PublicKey) (ssh.Signer, error) {
+	// Legacy PEM keys do not expose their public half until decrypted
+	if pub != nil {
+		for _, sock := range []string{AgentSocketPath(), os.Getenv("SSH_AUTH_SOCK")} {
+			if signer := agentSigner(sock, pub); signer != nil {
+				return signer, nil
+			}
+		}
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, fmt.Errorf("SSH key %s is passphrase-protected: load it into ssh-agent or run dgx from a terminal", c.config.IdentityFile)
+	}
+
+	var raw interface{}
+	for attempt := 1; ; attempt++ {
+		passphrase, err := ReadSecret(fmt.Sprintf("Enter passphrase for %s: ", c.config.IdentityFile))
+		if err != nil {
+			return nil, err
+		}
+		raw, err = ssh.ParseRawPrivateKeyWithPassphrase(key, passphrase)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, x509.IncorrectPasswordError) || attempt == passphraseAttempts {
+			return nil, fmt.Errorf("failed to decrypt SSH key: %w", err)
+		}
+		fmt.Fprintln(os.Stderr, "Incorrect passphrase, try again.")
+	}
+
+	signer, err := ssh.NewSignerFromKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SSH key: %w", err)
+	}
+
+	if c.config.KeyCache == KeyCacheAgent {
+		if err := c.cacheKey(raw); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not cache SSH key in ssh-agent: %v\n", err)
+		}
+	}
+	return signer, nil
+}
+
+// cacheKey adds the decrypted key to the dgx agent, starting the agent if needed
+func (c *Client) cacheKey(raw interface{}) error {
+	if runtime.GOOS == "windows" {
+		return fmt.Errorf("not supported on Windows; add the key to the OpenSSH agent service with ssh-add")
+	}
+
+	sock := AgentSocketPath()
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		if err := startAgent(sock); err != nil {
+			return err
+		}
+		if conn, err = net.Dial("unix", sock); err != nil {
+			return fmt.Errorf("failed to connect to ssh-agent: %w", err)
+		}
+	}
+	defer conn.Close()
+
+	ttl := c.config.KeyCacheTTL
+	if ttl <= 0 {
+		ttl = DefaultKeyCacheTTL
+	}
+	return agent.NewClient(conn).Add(agent.AddedKey{
+		PrivateKey:   raw,
+		Comment:      c.config.IdentityFile,
+		LifetimeSecs: uint32(ttl.Seconds()),
+	})
+}
+
+// startAgent launches ssh-agent on sock. The agent daemonizes and outlives this process.
+func startAgent(sock string) error {
+	if err := os.MkdirAll(filepath.Dir(sock), 0700); err != nil {
+		return fmt.Errorf("failed to create agent directory: %w", err)
+	}
+	// A socket left behind by an agent that has exited blocks the new one
+	os.Remove(sock)
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("ssh-agent", "-a", sock)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to start ssh-agent: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return nil
+}
+
+// agentSigner returns the agent's signer for pub, or nil when the agent is not running or
+// does not hold the key
+func agentSigner(sock string, pub ssh.PublicKey) ssh.Signer {
+	if sock == "" {
+		return nil
+	}
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return nil
+	}
+	signers, err := agent.NewClient(conn).Signers()
+	if err != nil {
+		conn.Close()
+		return nil
+	}
+	for _, s := range signers {
+		if bytes.Equal(s.PublicKey().Marshal(), pub.Marshal()) {
+			// The connection must stay open for signing; it lives as long as the process
+			return s
+		}
+	}
+	conn.Close()
+	return nil
+}
@@ -0,0 +1,47 @@
+package ssh
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+func TestAgentEnv(t *testing.T) {
+	// Unix socket paths are short, so the home directory is kept near the root
+	home, err := os.MkdirTemp("", "dgx")
+	if err != nil {
+		t.Fatalf("MkdirTemp: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(home) })
+	t.Setenv("HOME", home)
+
+	own := "SSH_AUTH_SOCK=" + AgentSocketPath()
+	cfg := &types.Config{}
+	t.Setenv("SSH_AUTH_SOCK", "")
+	if slices.Contains(AgentEnv(cfg), own) {
+		t.Fatalf("the dgx agent is not running, but SSH_AUTH_SOCK points at it")
+	}
+
+	os.MkdirAll(filepath.Dir(AgentSocketPath()), 0700)
+	l, err := net.Listen("unix", AgentSocketPath())
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+
+	if !slices.Contains(AgentEnv(cfg), own) {
+		t.Fatalf("without an agent of the user's, the dgx agent should be used")
+	}
+	t.Setenv("SSH_AUTH_SOCK", "/run/user/1000/keyring/ssh")
+	if env := AgentEnv(cfg); slices.Contains(env, own) || !slices.Contains(env, "SSH_AUTH_SOCK=/run/user/1000/keyring/ssh") {
+		t.Fatalf("the user's agent was replaced: %v", env)
+	}
+	cfg.KeyCache = KeyCacheAgent
+	if env := AgentEnv(cfg); env[len(env)-1] != own {
+		t.Fatalf("with key_cache: agent the dgx agent should win, got %v", env)
+	}
+}
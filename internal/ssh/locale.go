@@ -0,0 +1,17 @@
+package ssh
+
+// ParseLocale is the locale remote commands run under when dgx parses their output, so
+// docker, apt, and nvidia-smi print the English messages, decimal points, and dates the
+// parsers expect. C.UTF-8 is built into glibc, so every DGX OS install has it.
+const ParseLocale = "C.UTF-8"
+
+// ShellLocaleVar holds the locale the remote shell had set before WithParseLocale
+// replaced it, which dgx doctor reports
+const ShellLocaleVar = "DGX_SHELL_LOCALE"
+
+// WithParseLocale prefixes command so it runs under ParseLocale. The exports run after the
+// shell's startup files, so a locale set in ~/.bashrc does not reach the command. LANGUAGE
+// is cleared because gettext prefers it to every locale other than plain C.
+func WithParseLocale(command string) string {
+	return "export " + ShellLocaleVar + `="${LC_ALL:-$LANG}" LC_ALL=` + ParseLocale + " LANG=" + ParseLocale + " LANGUAGE=; " + command
+}
@@ -0,0 +1,19 @@
+package ssh
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestWithParseLocale(t *testing.T) {
+	cmd := exec.Command("sh", "-c", WithParseLocale(`echo "$LC_ALL $LANG [$LANGUAGE] $DGX_SHELL_LOCALE"`))
+	cmd.Env = []string{"LANG=de_DE.UTF-8", "LANGUAGE=de:en"}
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("running the command failed: %v", err)
+	}
+	if got, want := strings.TrimSpace(string(output)), "C.UTF-8 C.UTF-8 [] de_DE.UTF-8"; got != want {
+		t.Fatalf("output = %q, want %q", got, want)
+	}
+}
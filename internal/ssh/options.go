@@ -0,0 +1,204 @@
+package ssh
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weatherman/dgx-manager/pkg/types"
+	"golang.org/x/crypto/ssh"
+)
+
+// DefaultDialTimeout bounds connecting when ssh.dial_timeout is unset
+const DefaultDialTimeout = 10 * time.Second
+
+// defaultKeepAlive is the keepalive interval when ssh.keepalive is unset
+const defaultKeepAlive = 30 * time.Second
+
+// Algorithms the Go SSH client implements, and so the names ssh.ciphers, ssh.kex, and
+// ssh.macs may use
+var (
+	knownCiphers = []string{
+		"aes128-gcm@openssh.com", "aes256-gcm@openssh.com", "chacha20-poly1305@openssh.com",
+		"aes128-ctr", "aes192-ctr", "aes256-ctr",
+	}
+	knownKEX = []string{
+		"curve25519-sha256", "curve25519-sha256@libssh.org",
+		"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+		"diffie-hellman-group14-sha256", "diffie-hellman-group16-sha512", "diffie-hellman-group14-sha1",
+	}
+	knownMACs = []string{
+		"hmac-sha2-256-etm@openssh.com", "hmac-sha2-512-etm@openssh.com",
+		"hmac-sha2-256", "hmac-sha2-512", "hmac-sha1",
+	}
+)
+
+// ValidateOptions reports the first setting in o that the SSH client cannot use
+func ValidateOptions(o *types.SSHOptions) error {
+	if o == nil {
+		return nil
+	}
+	lists := []struct {
+		name   string
+		values []string
+		known  []string
+	}{
+		{"ssh.ciphers", o.Ciphers, knownCiphers},
+		{"ssh.kex", o.KEX, knownKEX},
+		{"ssh.macs", o.MACs, knownMACs},
+	}
+	for _, l := range lists {
+		for _, v := range l.values {
+			if !slices.Contains(l.known, v) {
+				return fmt.Errorf("%s: unsupported algorithm %q (supported: %s)", l.name, v, strings.Join(l.known, ", "))
+			}
+		}
+	}
+	if o.DialTimeout < 0 {
+		return fmt.Errorf("ssh.dial_timeout must not be negative")
+	}
+	return nil
+}
+
+// MergeOptions layers the fields set in override over base, for a profile's ssh settings
+// on top of the top-level ones
+func MergeOptions(base, override *types.SSHOptions) *types.SSHOptions {
+	if override == nil {
+		return base
+	}
+	if base == nil {
+		return override
+	}
+	merged := *base
+	if len(override.Ciphers) > 0 {
+		merged.Ciphers = override.Ciphers
+	}
+	if len(override.KEX) > 0 {
+		merged.KEX = override.KEX
+	}
+	if len(override.MACs) > 0 {
+		merged.MACs = override.MACs
+	}
+	if override.Compression != nil {
+		merged.Compression = override.Compression
+	}
+	if override.KeepAlive != 0 {
+		merged.KeepAlive = override.KeepAlive
+	}
+	if override.DialTimeout != 0 {
+		merged.DialTimeout = override.DialTimeout
+	}
+	return &merged
+}
+
+func dialTimeout(o *types.SSHOptions) time.Duration {
+	if o == nil || o.DialTimeout <= 0 {
+		return DefaultDialTimeout
+	}
+	return o.DialTimeout
+}
+
+// keepAlive returns the keepalive interval, or 0 when keepalives are off
+func keepAlive(o *types.SSHOptions) time.Duration {
+	switch {
+	case o == nil || o.KeepAlive == 0:
+		return defaultKeepAlive
+	case o.KeepAlive < 0:
+		return 0
+	default:
+		return o.KeepAlive
+	}
+}
+
+// clientConfig applies the algorithm preferences and timeout in o to the Go client
+func clientConfig(sshConfig *ssh.ClientConfig, o *types.SSHOptions) {
+	sshConfig.Timeout = dialTimeout(o)
+	if o == nil {
+		return
+	}
+	sshConfig.Ciphers = o.Ciphers
+	sshConfig.KeyExchanges = o.KEX
+	sshConfig.MACs = o.MACs
+}
+
+// dial connects to addr with the TCP keepalive from o and runs the SSH handshake
+func dial(addr string, sshConfig *ssh.ClientConfig, o *types.SSHOptions) (*ssh.Client, error) {
+	dialer := net.Dialer{Timeout: sshConfig.Timeout, KeepAlive: keepAlive(o)}
+	if dialer.KeepAlive == 0 {
+		dialer.KeepAlive = -1
+	}
+	conn, err := dialer.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	// The deadline covers the handshake; it is lifted once the connection is up
+	conn.SetDeadline(time.Now().Add(sshConfig.Timeout))
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, sshConfig)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	client := ssh.NewClient(c, chans, reqs)
+	if interval := keepAlive(o); interval > 0 {
+		go sendKeepAlives(client, interval)
+	}
+	return client, nil
+}
+
+// sendKeepAlives pings the server every interval so idle connections survive NAT and VPN
+// timeouts, and closes the client once the server stops answering
+func sendKeepAlives(client *ssh.Client, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, _, err := client.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+			client.Close()
+			return
+		}
+	}
+}
+
+// OptionArgs returns the ssh(1) -o arguments for o
+func OptionArgs(o *types.SSHOptions) []string {
+	var args []string
+	for _, opt := range ConfigOptions(o) {
+		args = append(args, "-o", opt[0]+"="+opt[1])
+	}
+	return args
+}
+
+// ConfigOptions returns o as ssh_config keywords and values
+func ConfigOptions(o *types.SSHOptions) [][2]string {
+	var opts [][2]string
+	if o != nil && len(o.Ciphers) > 0 {
+		opts = append(opts, [2]string{"Ciphers", strings.Join(o.Ciphers, ",")})
+	}
+	if o != nil && len(o.KEX) > 0 {
+		opts = append(opts, [2]string{"KexAlgorithms", strings.Join(o.KEX, ",")})
+	}
+	if o != nil && len(o.MACs) > 0 {
+		opts = append(opts, [2]string{"MACs", strings.Join(o.MACs, ",")})
+	}
+	if o != nil && o.Compression != nil {
+		value := "no"
+		if *o.Compression {
+			value = "yes"
+		}
+		opts = append(opts, [2]string{"Compression", value})
+	}
+	if o != nil && o.DialTimeout > 0 {
+		opts = append(opts, [2]string{"ConnectTimeout", strconv.Itoa(int(o.DialTimeout.Round(time.Second) / time.Second))})
+	}
+	if o != nil && o.KeepAlive != 0 {
+		if interval := keepAlive(o); interval > 0 {
+			opts = append(opts, [2]string{"ServerAliveInterval", strconv.Itoa(int(interval.Round(time.Second) / time.Second))})
+		} else {
+			opts = append(opts, [2]string{"TCPKeepAlive", "no"}, [2]string{"ServerAliveInterval", "0"})
+		}
+	}
+	return opts
+}
@@ -0,0 +1,42 @@
+package ssh
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+func TestValidateOptions(t *testing.T) {
+	if err := ValidateOptions(&types.SSHOptions{Ciphers: []string{"aes128-gcm@openssh.com"}, KEX: []string{"curve25519-sha256"}}); err != nil {
+		t.Fatalf("supported options rejected: %v", err)
+	}
+	if err := ValidateOptions(&types.SSHOptions{Ciphers: []string{"blowfish-cbc"}}); err == nil || !strings.Contains(err.Error(), "ssh.ciphers") {
+		t.Fatalf("unsupported cipher: got %v", err)
+	}
+	if err := ValidateOptions(&types.SSHOptions{DialTimeout: -time.Second}); err == nil {
+		t.Fatalf("negative dial_timeout accepted")
+	}
+}
+
+func TestOptionArgs(t *testing.T) {
+	on := true
+	o := &types.SSHOptions{
+		Ciphers:     []string{"aes128-gcm@openssh.com", "aes128-ctr"},
+		Compression: &on,
+		KeepAlive:   15 * time.Second,
+		DialTimeout: 20 * time.Second,
+	}
+	got := strings.Join(OptionArgs(o), " ")
+	want := "-o Ciphers=aes128-gcm@openssh.com,aes128-ctr -o Compression=yes -o ConnectTimeout=20 -o ServerAliveInterval=15"
+	if got != want {
+		t.Fatalf("OptionArgs = %q, want %q", got, want)
+	}
+	if args := OptionArgs(&types.SSHOptions{KeepAlive: -1}); strings.Join(args, " ") != "-o TCPKeepAlive=no -o ServerAliveInterval=0" {
+		t.Fatalf("disabled keepalive: %v", args)
+	}
+	if args := OptionArgs(nil); len(args) != 0 {
+		t.Fatalf("nil options: %v", args)
+	}
+}
@@ -0,0 +1,135 @@
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/exitcode"
+	"github.com/weatherman/dgx-manager/internal/ui"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+// resizePollInterval is how often the local terminal size is checked during a Shell session
+const resizePollInterval = 250 * time.Millisecond
+
+// Shell opens an interactive shell over the Go SSH connection, copying everything the remote
+// terminal prints to out as well as to stdout. onResize, if set, is called with the initial
+// terminal size and after every change. Unlike InteractiveShell it does not need ssh(1),
+// which lets the session be recorded.
+func (c *Client) Shell(out io.Writer, onResize func(width, height int)) error {
+	err := c.runPTY("", out, onResize)
+	if _, ok := err.(*ssh.ExitError); ok {
+		return nil
+	}
+	return err
+}
+
+// RunPTY runs command in a remote pseudo-terminal attached to the local one, for
+// full-screen or line-editing programs such as docker exec -it. A non-zero exit status is
+// returned as a remote error; see RemoteExitStatus.
+func (c *Client) RunPTY(command string) error {
+	err := c.runPTY(command, io.Discard, nil)
+	if _, ok := err.(*ssh.ExitError); ok {
+		return exitcode.Wrap(exitcode.Remote, fmt.Errorf("command failed: %w", err))
+	}
+	return err
+}
+
+// runPTY runs command, or the login shell when command is empty, in a remote PTY. The
+// remote exit status is returned as an *ssh.ExitError.
+func (c *Client) runPTY(command string, out io.Writer, onResize func(width, height int)) error {
+	if c.client == nil {
+		if err := c.Connect(); err != nil {
+			return err
+		}
+	}
+
+	session, err := c.client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	defer session.Close()
+
+	fd := int(os.Stdin.Fd())
+	width, height := 80, 24
+	if term.IsTerminal(fd) {
+		if w, h, err := term.GetSize(fd); err == nil {
+			width, height = w, h
+		}
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return fmt.Errorf("failed to set terminal raw mode: %w", err)
+		}
+		defer term.Restore(fd, state)
+	}
+	if onResize != nil {
+		onResize(width, height)
+	}
+
+	termType := os.Getenv("TERM")
+	if termType == "" {
+		termType = "xterm-256color"
+	}
+	// Remote commands draw no progress bars or colors for a dumb terminal; an interactive
+	// shell keeps the real one
+	if ui.Plain() && command != "" {
+		termType = "dumb"
+	}
+	modes := ssh.TerminalModes{
+		ssh.ECHO:          1,
+		ssh.TTY_OP_ISPEED: 14400,
+		ssh.TTY_OP_OSPEED: 14400,
+	}
+	if err := session.RequestPty(termType, height, width, modes); err != nil {
+		return fmt.Errorf("failed to request pty: %w", err)
+	}
+
+	session.Stdin = os.Stdin
+	session.Stdout = io.MultiWriter(os.Stdout, out)
+	session.Stderr = io.MultiWriter(os.Stderr, out)
+	if command == "" {
+		err = session.Shell()
+	} else {
+		err = session.Start(command)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to start session: %w", err)
+	}
+
+	// Polling keeps resize handling portable; SIGWINCH does not exist on Windows
+	done := make(chan struct{})
+	defer close(done)
+	if term.IsTerminal(fd) {
+		go func() {
+			ticker := time.NewTicker(resizePollInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					w, h, err := term.GetSize(fd)
+					if err != nil || (w == width && h == height) {
+						continue
+					}
+					width, height = w, h
+					session.WindowChange(h, w)
+					if onResize != nil {
+						onResize(w, h)
+					}
+				}
+			}
+		}()
+	}
+
+	if err := session.Wait(); err != nil {
+		if _, ok := err.(*ssh.ExitError); ok {
+			return err
+		}
+		return connectionError(err)
+	}
+	return nil
+}
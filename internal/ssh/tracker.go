@@ -0,0 +1,90 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/weatherman/dgx-manager/pkg/types"
+)
+
+// Tracker follows a DGX whose address changes, e.g. when DHCP hands it a new lease
+type Tracker interface {
+	// Connected is called after every successful login; client is ready for commands
+	Connected(cfg *types.Config, client *Client)
+	// Relocate is called when cfg.Host does not answer and returns a candidate address
+	// and how it was found
+	Relocate(cfg *types.Config) (host, method string, ok bool)
+	// Moved is called once the DGX was verified at cfg.Host, which used to be from
+	Moved(cfg *types.Config, from string)
+}
+
+var tracker Tracker
+
+// SetTracker installs the tracker consulted when the DGX cannot be reached
+func SetTracker(t Tracker) {
+	tracker = t
+}
+
+// relocate asks the tracker where the DGX went after dialErr and logs in there. The new
+// address must present the key known_hosts has for the configured host, so a different
+// machine that picked up the name or address is never trusted.
+func (c *Client) relocate(sshConfig *ssh.ClientConfig, hostKeyCallback ssh.HostKeyCallback, knownHostsPath string, dialErr error) (*ssh.Client, error) {
+	var opErr *net.OpError
+	if tracker == nil || !errors.As(dialErr, &opErr) {
+		return nil, dialErr
+	}
+	from := c.config.Host
+	host, method, ok := tracker.Relocate(c.config)
+	if !ok || host == from {
+		return nil, dialErr
+	}
+
+	port := strconv.Itoa(c.config.Port)
+	var presented ssh.PublicKey
+	relocated := *sshConfig
+	relocated.HostKeyCallback = func(_ string, remote net.Addr, key ssh.PublicKey) error {
+		presented = key
+		return hostKeyCallback(net.JoinHostPort(from, port), remote, key)
+	}
+	client, err := dial(net.JoinHostPort(host, port), &relocated, c.config.SSH)
+	if err != nil {
+		return nil, fmt.Errorf("%s does not answer and %s (found by %s) is not the same DGX: %w", from, host, method, err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Note: %s does not answer; the DGX is now at %s (found by %s)\n", from, host, method)
+	c.config.Host = host
+	// Record the new address so ssh(1) and later connections accept it without a prompt
+	if f, err := os.OpenFile(knownHostsPath, os.O_APPEND|os.O_WRONLY, 0600); err == nil {
+		fmt.Fprintln(f, knownhosts.Line([]string{knownhosts.Normalize(net.JoinHostPort(host, port))}, presented))
+		f.Close()
+	}
+	tracker.Moved(c.config, from)
+	return client, nil
+}
+
+// locateNative gives the tracker a chance to find a moved DGX before handing over to
+// ssh(1), which cannot be redirected once it runs
+func (c *Client) locateNative() {
+	if tracker != nil && c.client == nil && !c.IsReachable(3*time.Second) {
+		c.Connect()
+	}
+}
+
+// RemoteIP returns the IP address of the connected DGX, or "" when not connected
+func (c *Client) RemoteIP() string {
+	if c.client == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(c.client.RemoteAddr().String())
+	if err != nil {
+		return ""
+	}
+	return host
+}
@@ -0,0 +1,80 @@
+package state
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Cache keeps the results of slow read-only remote probes for a short time so repeated
+// commands do not pay for them again on high-latency links. A nil *Cache never caches.
+type Cache struct {
+	store  *Store
+	bypass bool
+	now    func() time.Time
+}
+
+// cacheEntry is the stored form of a cached result
+type cacheEntry struct {
+	StoredAt time.Time       `json:"stored_at"`
+	Value    json.RawMessage `json:"value"`
+}
+
+// NewCache creates a cache that keeps its entries in store
+func NewCache(store *Store) *Cache {
+	return &Cache{store: store, now: time.Now}
+}
+
+// SetBypass makes Fetch ignore cached entries. Fresh results are still stored, so
+// --no-cache also refreshes the cache for later commands.
+func (c *Cache) SetBypass(bypass bool) {
+	if c != nil {
+		c.bypass = bypass
+	}
+}
+
+// Fetch fills v from the entry at key if it is younger than ttl. Otherwise it calls fetch,
+// which must fill v, and stores the result when fetch succeeds. It returns the age of the
+// cached entry used, or zero when fetch ran.
+func (c *Cache) Fetch(key string, ttl time.Duration, v interface{}, fetch func() error) (time.Duration, error) {
+	if c == nil {
+		return 0, fetch()
+	}
+	if !c.bypass {
+		if age, ok := c.Get(key, ttl, v); ok {
+			return age, nil
+		}
+	}
+
+	if err := fetch(); err != nil {
+		return 0, err
+	}
+	// Caching is best effort; a read-only or full disk must not fail the command
+	if value, err := json.Marshal(v); err == nil {
+		c.store.Save(Key("cache", key), cacheEntry{StoredAt: c.now(), Value: value})
+	}
+	return 0, nil
+}
+
+// Get fills v from the entry at key if it is younger than ttl, regardless of SetBypass,
+// and returns its age
+func (c *Cache) Get(key string, ttl time.Duration, v interface{}) (time.Duration, bool) {
+	if c == nil {
+		return 0, false
+	}
+	var entry cacheEntry
+	if found, err := c.store.Load(Key("cache", key), &entry); err != nil || !found {
+		return 0, false
+	}
+	age := c.now().Sub(entry.StoredAt)
+	if age < 0 || age >= ttl || json.Unmarshal(entry.Value, v) != nil {
+		return 0, false
+	}
+	return age, true
+}
+
+// Invalidate drops the entry at key, for commands that change what a probe reports
+func (c *Cache) Invalidate(key string) {
+	if c != nil {
+		c.store.Delete(Key("cache", key))
+	}
+}
@@ -0,0 +1,55 @@
+package state
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCacheFetch(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	cache := NewCache(NewStore(t.TempDir()))
+	cache.now = func() time.Time { return now }
+
+	calls := 0
+	fetch := func(v *[]string) func() error {
+		return func() error {
+			calls++
+			*v = []string{"ai/smollm2", "ai/llama3.2"}
+			return nil
+		}
+	}
+
+	var models []string
+	if age, err := cache.Fetch("models_spark", time.Minute, &models, fetch(&models)); err != nil || age != 0 || calls != 1 {
+		t.Fatalf("expected a fresh fetch, got age=%v err=%v calls=%d", age, err, calls)
+	}
+
+	now = now.Add(30 * time.Second)
+	var cached []string
+	if age, err := cache.Fetch("models_spark", time.Minute, &cached, fetch(&cached)); err != nil || age != 30*time.Second || calls != 1 || len(cached) != 2 {
+		t.Fatalf("expected a cache hit, got age=%v err=%v calls=%d models=%v", age, err, calls, cached)
+	}
+
+	cache.SetBypass(true)
+	if age, _ := cache.Fetch("models_spark", time.Minute, &cached, fetch(&cached)); age != 0 || calls != 2 {
+		t.Fatalf("bypass should fetch, got age=%v calls=%d", age, calls)
+	}
+	cache.SetBypass(false)
+
+	now = now.Add(2 * time.Minute)
+	if _, err := cache.Fetch("models_spark", time.Minute, &cached, fetch(&cached)); err != nil || calls != 3 {
+		t.Fatalf("expired entry should fetch, got err=%v calls=%d", err, calls)
+	}
+
+	failed := errors.New("unreachable")
+	cache.Invalidate("models_spark")
+	if _, err := cache.Fetch("models_spark", time.Minute, &cached, func() error { return failed }); err != failed {
+		t.Fatalf("expected fetch error, got %v", err)
+	}
+
+	var nilCache *Cache
+	if _, err := nilCache.Fetch("models_spark", time.Minute, &cached, fetch(&cached)); err != nil || calls != 4 {
+		t.Fatalf("nil cache should always fetch, got err=%v calls=%d", err, calls)
+	}
+}
@@ -0,0 +1,102 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// unsafeChars are replaced when turning keys into file names
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Store persists small JSON documents under ~/.config/dgx/state
+type Store struct {
+	dir string
+}
+
+// NewStore creates a store rooted at dir
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// DefaultStore returns the store at ~/.config/dgx/state
+func DefaultStore() (*Store, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return NewStore(filepath.Join(home, ".config", "dgx", "state")), nil
+}
+
+// Key joins parts into a file-system safe document key
+func Key(parts ...string) string {
+	key := ""
+	for i, p := range parts {
+		if i > 0 {
+			key += "_"
+		}
+		key += unsafeChars.ReplaceAllString(p, "-")
+	}
+	return key
+}
+
+// Dir returns the directory holding the store's documents
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+func (s *Store) path(key string) string {
+	return filepath.Join(s.dir, key+".json")
+}
+
+// Load decodes the document at key into v. It reports false when no document exists.
+func (s *Store) Load(key string, v interface{}) (bool, error) {
+	data, err := os.ReadFile(s.path(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to read state %s: %w", key, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("failed to parse state %s: %w", key, err)
+	}
+	return true, nil
+}
+
+// Save writes v as the document at key, replacing it atomically
+func (s *Store) Save(key string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state %s: %w", key, err)
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write state %s: %w", key, err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write state %s: %w", key, err)
+	}
+	tmp.Close()
+	if err := os.Rename(tmp.Name(), s.path(key)); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write state %s: %w", key, err)
+	}
+	return nil
+}
+
+// Delete removes the document at key; a missing document is not an error
+func (s *Store) Delete(key string) error {
+	if err := os.Remove(s.path(key)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete state %s: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package state
+
+import "testing"
+
+func TestStoreRoundTrip(t *testing.T) {
+	store := NewStore(t.TempDir())
+	key := Key("checkpoint", "spark.local", "dmr setup")
+	if key != "checkpoint_spark.local_dmr-setup" {
+		t.Fatalf("unexpected key %q", key)
+	}
+
+	var doc struct{ Completed []string }
+	if found, err := store.Load(key, &doc); err != nil || found {
+		t.Fatalf("expected missing document, got found=%v err=%v", found, err)
+	}
+
+	doc.Completed = []string{"docker-engine"}
+	if err := store.Save(key, doc); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	var loaded struct{ Completed []string }
+	if found, err := store.Load(key, &loaded); err != nil || !found || len(loaded.Completed) != 1 {
+		t.Fatalf("unexpected load result: found=%v err=%v doc=%+v", found, err, loaded)
+	}
+
+	if err := store.Delete(key); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if err := store.Delete(key); err != nil {
+		t.Fatalf("deleting a missing document should succeed: %v", err)
+	}
+}
@@ -0,0 +1,210 @@
+// Package support collects DGX logs and diagnostics into a redacted archive for issue reports.
+package support
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/redact"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+// RemoteDir is where the collect script gathers files on the DGX
+const RemoteDir = `"$HOME/.cache/dgx/support"`
+
+// maxFileSize bounds each file taken into the bundle; larger logs keep their last part
+const maxFileSize = 64 << 20
+
+// Options select what the collect script gathers
+type Options struct {
+	Since     time.Duration // journal window
+	Sudo      bool          // read dmesg and the journal as root and run nvidia-bug-report.sh
+	BugReport bool
+}
+
+// CollectScript gathers system information, kernel and service logs, GPU state, Docker
+// Model Runner logs, and optionally nvidia-bug-report.sh output into RemoteDir. Every
+// command runs even when others fail; its exit status is noted in its file.
+func CollectScript(opts Options) string {
+	sudo := ""
+	if opts.Sudo {
+		sudo = "sudo "
+	}
+	since := fmt.Sprintf("-%dmin", int(opts.Since.Minutes()))
+
+	var script strings.Builder
+	fmt.Fprintf(&script, `dir=%s
+rm -rf "$dir"
+mkdir -p "$dir"
+cd "$dir" || exit 1
+run() {
+  f=$1
+  shift
+  { printf '$ %%s\n' "$*"; sh -c "$*"; } > "$f" 2>&1 || echo "(exit status $?)" >> "$f"
+}
+echo "Collecting system information..."
+`, RemoteDir)
+	for _, c := range []struct{ file, command string }{
+		{"system.txt", "uname -a; cat /etc/os-release; cat /etc/dgx-release; uptime; free -h; df -h; lsblk; ip -brief address"},
+		{"packages.txt", "dpkg-query -W -f='${Package} ${Version}\\n' 'nvidia*' 'cuda*' 'docker*' 'libnvidia*' 2>/dev/null"},
+		{"dmesg.txt", sudo + "dmesg -T"},
+		{"journal-docker.txt", fmt.Sprintf("%sjournalctl --no-pager --since '%s' -u docker -u containerd", sudo, since)},
+		{"journal-nvidia.txt", fmt.Sprintf("%sjournalctl --no-pager --since '%s' -u 'nvidia*'", sudo, since)},
+		{"nvidia-smi.txt", "nvidia-smi -q"},
+		{"docker.txt", "docker version; docker info; docker ps -a"},
+		{"dmr.txt", "docker model version; docker model status; docker model ps; docker model list"},
+		{"dmr-logs.txt", "docker model logs --tail 5000"},
+	} {
+		fmt.Fprintf(&script, "run %s %s\n", c.file, ssh.ShellQuote(c.command))
+	}
+	if opts.BugReport && opts.Sudo {
+		script.WriteString(`if command -v nvidia-bug-report.sh >/dev/null 2>&1; then
+  echo "Running nvidia-bug-report.sh (this takes a minute or two)..."
+  sudo timeout 600 nvidia-bug-report.sh --output-file "$dir/nvidia-bug-report.log" >/dev/null 2>&1 || true
+  sudo chown "$(id -u):$(id -g)" "$dir"/nvidia-bug-report.log* 2>/dev/null || true
+fi
+`)
+	}
+	return script.String()
+}
+
+// FetchCommand streams RemoteDir as a gzipped tar and removes it afterwards
+func FetchCommand() string {
+	return fmt.Sprintf(`tar -C %[1]s -czf - . && rm -rf %[1]s`, RemoteDir)
+}
+
+// Bundle is a gzipped tar whose every file passes through a redactor before it is written
+type Bundle struct {
+	file     *os.File
+	gz       *gzip.Writer
+	tw       *tar.Writer
+	redactor *redact.Redactor
+	root     string
+	created  time.Time
+	files    []string
+}
+
+// Create starts a bundle at path. Files are stored under a directory named like the
+// archive, so extracting it does not scatter them.
+func Create(path string, redactor *redact.Redactor) (*Bundle, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	gz := gzip.NewWriter(f)
+	root := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), ".gz"), ".tar")
+	return &Bundle{file: f, gz: gz, tw: tar.NewWriter(gz), redactor: redactor, root: root, created: time.Now()}, nil
+}
+
+// Add writes a redacted file into the bundle
+func (b *Bundle) Add(name string, data []byte) error {
+	data = b.redactor.Bytes(data)
+	hdr := &tar.Header{
+		Name:    path.Join(b.root, name),
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: b.created,
+	}
+	if err := b.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := b.tw.Write(data); err != nil {
+		return err
+	}
+	b.files = append(b.files, name)
+	return nil
+}
+
+// AddArchive copies the regular files of a gzipped tar stream into the bundle under dir,
+// redacting each one. Compressed files (nvidia-bug-report.log.gz) are decompressed first so
+// their contents can be redacted too.
+func (b *Bundle) AddArchive(r io.Reader, dir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("unexpected archive from the DGX: %w", err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("unexpected archive from the DGX: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		if strings.HasPrefix(name, "..") {
+			continue
+		}
+		var src io.Reader = tr
+		if strings.HasSuffix(name, ".gz") {
+			inner, err := gzip.NewReader(tr)
+			if err != nil {
+				return fmt.Errorf("failed to decompress %s: %w", name, err)
+			}
+			src, name = inner, strings.TrimSuffix(name, ".gz")
+		}
+		data, err := readTail(src, maxFileSize)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		if err := b.Add(path.Join(dir, name), data); err != nil {
+			return err
+		}
+	}
+}
+
+// Files returns the names added so far
+func (b *Bundle) Files() []string {
+	return b.files
+}
+
+// Close finishes the archive
+func (b *Bundle) Close() error {
+	err := b.tw.Close()
+	if gzErr := b.gz.Close(); err == nil {
+		err = gzErr
+	}
+	if closeErr := b.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// readTail reads r, keeping only its last limit bytes
+func readTail(r io.Reader, limit int) ([]byte, error) {
+	buf := make([]byte, 0, 64<<10)
+	chunk := make([]byte, 64<<10)
+	truncated := false
+	for {
+		n, err := r.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		if len(buf) > 2*limit {
+			buf = append(buf[:0], buf[len(buf)-limit:]...)
+			truncated = true
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(buf) > limit {
+		buf, truncated = buf[len(buf)-limit:], true
+	}
+	if truncated {
+		buf = append([]byte(fmt.Sprintf("(truncated to the last %d MiB)\n", limit>>20)), buf...)
+	}
+	return buf, nil
+}
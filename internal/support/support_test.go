@@ -0,0 +1,94 @@
+package support
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/redact"
+)
+
+func TestBundle(t *testing.T) {
+	// A remote archive as FetchCommand streams it, with a compressed bug report
+	var remote bytes.Buffer
+	gz := gzip.NewWriter(&remote)
+	tw := tar.NewWriter(gz)
+	var report bytes.Buffer
+	rgz := gzip.NewWriter(&report)
+	rgz.Write([]byte("NVRM: ok\nHF_TOKEN=hf_abcdefghijklmnopqrstuvwxyz0123456789\n"))
+	rgz.Close()
+	for name, data := range map[string][]byte{
+		"./dmesg.txt":                []byte("[    1.0] booted\n"),
+		"./nvidia-bug-report.log.gz": report.Bytes(),
+	} {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg})
+		tw.Write(data)
+	}
+	tw.Close()
+	gz.Close()
+
+	path := filepath.Join(t.TempDir(), "dgx-support-spark.tar.gz")
+	b, err := Create(path, redact.New())
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := b.AddArchive(&remote, "dgx"); err != nil {
+		t.Fatalf("AddArchive: %v", err)
+	}
+	if err := b.Add("local/config.yaml", []byte("host: spark\n")); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open bundle: %v", err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("bundle is not gzipped: %v", err)
+	}
+	tr := tar.NewReader(zr)
+	files := map[string]string{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read bundle: %v", err)
+		}
+		data, _ := io.ReadAll(tr)
+		files[hdr.Name] = string(data)
+	}
+	report2, ok := files["dgx-support-spark/dgx/nvidia-bug-report.log"]
+	if !ok || len(files) != 3 {
+		t.Fatalf("unexpected files: %v", files)
+	}
+	if strings.Contains(report2, "hf_abc") || !strings.Contains(report2, "NVRM: ok") {
+		t.Fatalf("bug report not decompressed and redacted: %q", report2)
+	}
+}
+
+func TestCollectScript(t *testing.T) {
+	script := CollectScript(Options{Since: 2 * time.Hour, Sudo: true, BugReport: true})
+	if !strings.Contains(script, "-120min") || !strings.Contains(script, "nvidia-bug-report.sh") {
+		t.Fatalf("unexpected script:\n%s", script)
+	}
+	if strings.Contains(CollectScript(Options{Since: time.Hour, BugReport: true}), "sudo") {
+		t.Fatalf("script uses sudo without Sudo")
+	}
+	if out, err := exec.Command("bash", "-n", "-c", script).CombinedOutput(); err != nil {
+		t.Fatalf("script does not parse: %v %s", err, out)
+	}
+}
@@ -0,0 +1,64 @@
+package textdiff
+
+import "testing"
+
+func TestUnified(t *testing.T) {
+	a := "{\n  \"runtimes\": {\n    \"nvidia\": {\n      \"path\": \"nvidia-container-runtime\"\n    }\n  }\n}\n"
+	b := "{\n  \"default-runtime\": \"nvidia\",\n  \"runtimes\": {\n    \"nvidia\": {\n      \"path\": \"nvidia-container-runtime\"\n    }\n  }\n}\n"
+	want := `--- daemon.json
++++ daemon.json (edited)
+@@ -1,4 +1,5 @@
+ {
++  "default-runtime": "nvidia",
+   "runtimes": {
+     "nvidia": {
+       "path": "nvidia-container-runtime"
+`
+	if got := Unified("daemon.json", "daemon.json (edited)", a, b); got != want {
+		t.Fatalf("Unified =\n%s\nwant\n%s", got, want)
+	}
+	if got := Unified("a", "b", a, a); got != "" {
+		t.Fatalf("equal inputs gave a diff:\n%s", got)
+	}
+}
+
+func TestUnifiedHunks(t *testing.T) {
+	var a, b string
+	for i := 1; i <= 20; i++ {
+		line := string(rune('a'+i-1)) + "\n"
+		a += line
+		switch i {
+		case 2:
+			b += "B\n"
+		case 18:
+		default:
+			b += line
+		}
+	}
+	want := `--- a
++++ b
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -15,6 +15,5 @@
+ o
+ p
+ q
+-r
+ s
+ t
+`
+	if got := Unified("a", "b", a, b); got != want {
+		t.Fatalf("Unified =\n%s\nwant\n%s", got, want)
+	}
+	if got := Unified("a", "b", "x", "x\n"); got != "--- a\n+++ b\n@@ -1 +1 @@\n-x\n\\ No newline at end of file\n+x\n" {
+		t.Fatalf("missing final newline:\n%s", got)
+	}
+	if got := Unified("a", "b", "", "new\n"); got != "--- a\n+++ b\n@@ -0,0 +1 @@\n+new\n" {
+		t.Fatalf("new file:\n%s", got)
+	}
+}
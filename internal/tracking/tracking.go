@@ -0,0 +1,111 @@
+package tracking
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// EnvFile is the secrets file 'dgx env' maintains on the DGX
+const EnvFile = "~/.config/dgx/env.sh"
+
+// Backend is an experiment tracker a remote job can report to
+type Backend struct {
+	Name string
+	// Required must be set in EnvFile; Optional are passed along when present
+	Required []string
+	Optional []string
+	// SetHint tells the user how to store the required variables
+	SetHint string
+}
+
+var backends = []Backend{
+	{
+		Name:     "wandb",
+		Required: []string{"WANDB_API_KEY"},
+		Optional: []string{"WANDB_ENTITY", "WANDB_PROJECT", "WANDB_BASE_URL"},
+		SetHint:  "dgx env wandb",
+	},
+	{
+		Name:     "mlflow",
+		Required: []string{"MLFLOW_TRACKING_URI"},
+		Optional: []string{"MLFLOW_TRACKING_TOKEN", "MLFLOW_TRACKING_USERNAME", "MLFLOW_TRACKING_PASSWORD", "MLFLOW_EXPERIMENT_NAME"},
+		SetHint:  "dgx env mlflow",
+	},
+}
+
+// Lookup returns the backend called name
+func Lookup(name string) (Backend, error) {
+	names := make([]string, len(backends))
+	for i, b := range backends {
+		if b.Name == name {
+			return b, nil
+		}
+		names[i] = b.Name
+	}
+	return Backend{}, fmt.Errorf("unknown tracker %q (supported: %s)", name, strings.Join(names, ", "))
+}
+
+// Prelude returns shell that loads EnvFile, fails when a required variable is missing,
+// and exports the backend's variables so the job that follows inherits them
+func (b Backend) Prelude() string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "[ -f %[1]s ] && . %[1]s;", EnvFile)
+	for _, v := range b.Required {
+		fmt.Fprintf(&s, ` [ -n "${%[1]s:-}" ] || { echo "%[1]s is not set on the DGX; store it with: %[2]s" >&2; exit 1; };`, v, b.SetHint)
+	}
+	fmt.Fprintf(&s, " export %s", strings.Join(append(append([]string{}, b.Required...), b.Optional...), " "))
+	return s.String()
+}
+
+var (
+	// Both wandb ("wandb: 🚀 View run NAME at: URL") and mlflow ("🏃 View run NAME at: URL")
+	// announce runs this way
+	runURLPattern = regexp.MustCompile(`View run\b.*?\bat:? (https?://\S+)`)
+	ansiPattern   = regexp.MustCompile("\x1b\\[[0-9;]*[A-Za-z]")
+)
+
+// Watcher is an io.Writer that collects run URLs from the job output written to it
+type Watcher struct {
+	mu      sync.Mutex
+	partial string
+	urls    []string
+}
+
+// Write scans complete lines of p for run URLs
+func (w *Watcher) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	data := w.partial + string(p)
+	lines := strings.Split(strings.ReplaceAll(data, "\r", "\n"), "\n")
+	w.partial = lines[len(lines)-1]
+	for _, line := range lines[:len(lines)-1] {
+		w.scan(line)
+	}
+	return len(p), nil
+}
+
+// URLs returns the distinct run URLs seen so far, in order
+func (w *Watcher) URLs() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.partial != "" {
+		w.scan(w.partial)
+		w.partial = ""
+	}
+	return append([]string(nil), w.urls...)
+}
+
+func (w *Watcher) scan(line string) {
+	match := runURLPattern.FindStringSubmatch(ansiPattern.ReplaceAllString(line, ""))
+	if match == nil {
+		return
+	}
+	for _, u := range w.urls {
+		if u == match[1] {
+			return
+		}
+	}
+	w.urls = append(w.urls, match[1])
+}
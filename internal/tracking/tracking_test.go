@@ -0,0 +1,38 @@
+package tracking
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWatcherCollectsRunURLs(t *testing.T) {
+	w := &Watcher{}
+	chunks := []string{
+		"wandb: Tracking run with wandb version 0.18.0\nwandb: \x1b[33m🚀 View run \x1b[0m\x1b[33mfluent-sun-3\x1b[0m at: \x1b[34mhttps://wandb.ai/acme/llm/runs/a1b2c3\x1b[0m\n",
+		"step 10 loss 1.2\r",
+		"step 20 loss 1.1\n🏃 View run bold-owl-7 at: http://mlflow.lab:5000/#/experiments/1/runs/9f",
+		"e8\nwandb: 🚀 View run fluent-sun-3 at: https://wandb.ai/acme/llm/runs/a1b2c3\n",
+	}
+	for _, c := range chunks {
+		w.Write([]byte(c))
+	}
+	got := w.URLs()
+	want := []string{"https://wandb.ai/acme/llm/runs/a1b2c3", "http://mlflow.lab:5000/#/experiments/1/runs/9fe8"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("URLs = %v, want %v", got, want)
+	}
+}
+
+func TestPrelude(t *testing.T) {
+	b, err := Lookup("mlflow")
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	prelude := b.Prelude()
+	if !strings.Contains(prelude, `"${MLFLOW_TRACKING_URI:-}"`) || !strings.Contains(prelude, "export MLFLOW_TRACKING_URI MLFLOW_TRACKING_TOKEN") {
+		t.Fatalf("Prelude = %s", prelude)
+	}
+	if _, err := Lookup("tensorboard"); err == nil {
+		t.Fatalf("Lookup(tensorboard) should fail")
+	}
+}
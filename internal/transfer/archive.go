@@ -0,0 +1,85 @@
+package transfer
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+// Compression is the codec applied to a tar stream
+type Compression string
+
+const (
+	CompressNone Compression = ""
+	CompressGzip Compression = "gzip"
+	CompressZstd Compression = "zstd"
+)
+
+// DetectCompression infers the codec from an archive file name
+func DetectCompression(name string) Compression {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar.zst"), strings.HasSuffix(lower, ".tzst"), strings.HasSuffix(lower, ".tar.zstd"):
+		return CompressZstd
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return CompressGzip
+	default:
+		return CompressNone
+	}
+}
+
+// Extension returns the conventional archive suffix for c
+func (c Compression) Extension() string {
+	switch c {
+	case CompressZstd:
+		return ".tar.zst"
+	case CompressGzip:
+		return ".tar.gz"
+	default:
+		return ".tar"
+	}
+}
+
+// TarCommand returns a remote command that writes an archive of dir's contents to stdout,
+// or to the DGX path output when it is not empty
+func TarCommand(dir string, c Compression, output string) string {
+	script := fmt.Sprintf("tar -C %s -cf - .", ssh.QuoteRemotePath(dir))
+	switch c {
+	case CompressZstd:
+		script += " | zstd -T0 -q -c"
+	case CompressGzip:
+		script += " | gzip -c"
+	}
+	if output != "" {
+		script = fmt.Sprintf("mkdir -p %s && %s > %s",
+			ssh.QuoteRemotePath(path.Dir(output)), script, ssh.QuoteRemotePath(output))
+	}
+	return wrap(c, script)
+}
+
+// UntarCommand returns a remote command that extracts an archive into dir, reading it from
+// stdin or from the DGX path input when it is not empty
+func UntarCommand(dir string, c Compression, input string) string {
+	source := "cat"
+	if input != "" {
+		source = "cat " + ssh.QuoteRemotePath(input)
+	}
+	switch c {
+	case CompressZstd:
+		source += " | zstd -d -q -c"
+	case CompressGzip:
+		source += " | gzip -dc"
+	}
+	return wrap(c, fmt.Sprintf("mkdir -p %[1]s && %[2]s | tar -C %[1]s -xf -", ssh.QuoteRemotePath(dir), source))
+}
+
+// wrap runs script under bash with pipefail, so a failing tar is not masked by the codec,
+// after checking the codec is installed
+func wrap(c Compression, script string) string {
+	if c != CompressNone {
+		script = fmt.Sprintf("command -v %[1]s >/dev/null || { echo '%[1]s is not installed on the DGX (sudo apt-get install %[1]s)' >&2; exit 1; }; %[2]s", c, script)
+	}
+	return "bash -o pipefail -c " + ssh.ShellQuote(script)
+}
@@ -0,0 +1,47 @@
+package transfer
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectCompression(t *testing.T) {
+	for name, want := range map[string]Compression{
+		"run1.tar.zst": CompressZstd,
+		"RUN1.TZST":    CompressZstd,
+		"run1.tgz":     CompressGzip,
+		"run1.tar":     CompressNone,
+	} {
+		if got := DetectCompression(name); got != want {
+			t.Fatalf("DetectCompression(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestTarRoundTrip(t *testing.T) {
+	for _, tool := range []string{"bash", "tar", "gzip"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not installed", tool)
+		}
+	}
+	src, dst := t.TempDir(), filepath.Join(t.TempDir(), "out")
+	if err := os.MkdirAll(filepath.Join(src, "logs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "logs", "train.log"), []byte("loss 0.1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := filepath.Join(t.TempDir(), "a", "run.tar.gz")
+	if out, err := exec.Command("bash", "-c", TarCommand(src, CompressGzip, archive)).CombinedOutput(); err != nil {
+		t.Fatalf("create: %v: %s", err, out)
+	}
+	if out, err := exec.Command("bash", "-c", UntarCommand(dst, DetectCompression(archive), archive)).CombinedOutput(); err != nil {
+		t.Fatalf("extract: %v: %s", err, out)
+	}
+	if data, err := os.ReadFile(filepath.Join(dst, "logs", "train.log")); err != nil || string(data) != "loss 0.1\n" {
+		t.Fatalf("extracted %q, %v", data, err)
+	}
+}
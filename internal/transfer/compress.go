@@ -0,0 +1,120 @@
+package transfer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// Executor runs a command on the DGX and returns its output, as ssh.Client.Execute does
+type Executor func(command string) (string, error)
+
+// ProbeZstd reports whether a stream can be zstd-compressed in transit: zstd must be
+// installed both here and on the DGX. When it cannot, the error says which side lacks it.
+func ProbeZstd(execute Executor) error {
+	if _, err := exec.LookPath("zstd"); err != nil {
+		return fmt.Errorf("zstd is not installed locally")
+	}
+	if _, err := execute("command -v zstd"); err != nil {
+		return fmt.Errorf("zstd is not installed on the DGX (sudo apt-get install zstd)")
+	}
+	return nil
+}
+
+// ProbeRsyncZstd reports whether both rsyncs can compress with zstd, which needs rsync
+// 3.2 or later built with libzstd on each end
+func ProbeRsyncZstd(execute Executor) error {
+	local, err := exec.Command("rsync", "--version").Output()
+	if err != nil {
+		return fmt.Errorf("failed to run rsync --version: %w", err)
+	}
+	if !slices.Contains(rsyncCompressors(string(local)), "zstd") {
+		return fmt.Errorf("the local rsync does not support zstd (it needs rsync 3.2 or later)")
+	}
+	remote, err := execute("rsync --version")
+	if err != nil || !slices.Contains(rsyncCompressors(remote), "zstd") {
+		return fmt.Errorf("rsync on the DGX does not support zstd (it needs rsync 3.2 or later)")
+	}
+	return nil
+}
+
+// RsyncZstdArgs are the rsync arguments that select zstd once ProbeRsyncZstd succeeds
+var RsyncZstdArgs = []string{"--compress-choice=zstd", "--compress-level=3"}
+
+// rsyncCompressors returns the "Compress list" of rsync --version output, which is empty
+// for versions before 3.2
+func rsyncCompressors(version string) []string {
+	lines := strings.Split(version, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "Compress list:" && i+1 < len(lines) {
+			return strings.Fields(lines[i+1])
+		}
+	}
+	return nil
+}
+
+// filter is a local zstd process that data is passed through
+type filter struct {
+	cmd  *exec.Cmd
+	pipe io.Closer
+}
+
+// Decompressor returns a writer that decompresses the zstd stream written to it into w.
+// Close flushes it and waits for zstd to finish.
+func Decompressor(w io.Writer) (io.WriteCloser, error) {
+	cmd := exec.Command("zstd", "-d", "-q", "-c")
+	cmd.Stdout = w
+	cmd.Stderr = os.Stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start zstd: %w", err)
+	}
+	return &decompressor{WriteCloser: in, f: filter{cmd: cmd, pipe: in}}, nil
+}
+
+type decompressor struct {
+	io.WriteCloser
+	f filter
+}
+
+func (d *decompressor) Close() error {
+	return d.f.close()
+}
+
+// Compressor returns a reader of r compressed with zstd. Close waits for zstd to finish.
+func Compressor(r io.Reader) (io.ReadCloser, error) {
+	cmd := exec.Command("zstd", "-T0", "-3", "-q", "-c")
+	cmd.Stdin = r
+	cmd.Stderr = os.Stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start zstd: %w", err)
+	}
+	return &compressor{ReadCloser: out, f: filter{cmd: cmd, pipe: out}}, nil
+}
+
+type compressor struct {
+	io.ReadCloser
+	f filter
+}
+
+func (c *compressor) Close() error {
+	return c.f.close()
+}
+
+func (f filter) close() error {
+	f.pipe.Close()
+	if err := f.cmd.Wait(); err != nil {
+		return fmt.Errorf("zstd failed: %w", err)
+	}
+	return nil
+}
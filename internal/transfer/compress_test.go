@@ -0,0 +1,56 @@
+package transfer
+
+import (
+	"bytes"
+	"io"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRsyncCompressors(t *testing.T) {
+	version := `rsync  version 3.2.7  protocol version 31
+Capabilities:
+    64-bit files, 64-bit inums, 64-bit timestamps, 64-bit long ints,
+Checksum list:
+    xxh128 xxh3 xxh64 (xxhash) md5 md4 sha1 none
+Compress list:
+    zstd lz4 zlibx zlib none
+`
+	if got := rsyncCompressors(version); !slices.Contains(got, "zstd") || len(got) != 5 {
+		t.Fatalf("rsyncCompressors = %v", got)
+	}
+	if got := rsyncCompressors("rsync  version 3.1.3  protocol version 31\n"); got != nil {
+		t.Fatalf("old rsync: %v", got)
+	}
+}
+
+func TestZstdRoundTrip(t *testing.T) {
+	if _, err := exec.LookPath("zstd"); err != nil {
+		t.Skip("zstd not installed")
+	}
+	data := strings.Repeat("layer.0.weight fp16 ", 4096)
+	compressed, err := Compressor(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Compressor: %v", err)
+	}
+	var out bytes.Buffer
+	decompressed, err := Decompressor(&out)
+	if err != nil {
+		t.Fatalf("Decompressor: %v", err)
+	}
+	n, err := io.Copy(decompressed, compressed)
+	if err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	if err := compressed.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := decompressed.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n >= int64(len(data)) || out.String() != data {
+		t.Fatalf("round trip sent %d bytes and got %d back, want fewer than %d and the same data", n, out.Len(), len(data))
+	}
+}
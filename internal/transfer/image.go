@@ -0,0 +1,175 @@
+package transfer
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/ssh"
+)
+
+// Image is a docker image as docker image inspect reports it
+type Image struct {
+	Ref  string
+	ID   string
+	Size int64
+}
+
+// InspectImagesCommand returns a remote command that prints "<id> <size>" for each of
+// refs, or "- 0" for those docker does not have
+func InspectImagesCommand(refs []string) string {
+	quoted := make([]string, len(refs))
+	for i, r := range refs {
+		quoted[i] = ssh.ShellQuote(r)
+	}
+	return fmt.Sprintf("for ref in %s; do docker image inspect --format '{{.Id}} {{.Size}}' \"$ref\" 2>/dev/null || echo '- 0'; done", strings.Join(quoted, " "))
+}
+
+// ParseImages reads the output of InspectImagesCommand for refs. Images docker does not
+// have are returned with no ID.
+func ParseImages(refs []string, output string) ([]Image, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != len(refs) {
+		return nil, fmt.Errorf("unexpected docker image inspect output: %q", output)
+	}
+	images := make([]Image, len(refs))
+	for i, line := range lines {
+		id, size, ok := strings.Cut(strings.TrimSpace(line), " ")
+		n, err := strconv.ParseInt(size, 10, 64)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("unexpected docker image inspect output: %q", line)
+		}
+		if id == "-" {
+			id = ""
+		}
+		images[i] = Image{Ref: refs[i], ID: id, Size: n}
+	}
+	return images, nil
+}
+
+// SaveCommand returns a remote command that writes refs to stdout as a docker save
+// archive, compressed with c
+func SaveCommand(refs []string, c Compression) string {
+	quoted := make([]string, len(refs))
+	for i, r := range refs {
+		quoted[i] = ssh.ShellQuote(r)
+	}
+	script := "docker save " + strings.Join(quoted, " ")
+	switch c {
+	case CompressZstd:
+		script += " | zstd -T0 -q -c"
+	case CompressGzip:
+		script += " | gzip -c"
+	}
+	return wrap(c, script)
+}
+
+// LoadCommand returns a remote command that loads a docker save archive compressed with c
+// from stdin
+func LoadCommand(c Compression) string {
+	script := "docker load"
+	switch c {
+	case CompressZstd:
+		script = "zstd -d -q -c | docker load"
+	case CompressGzip:
+		script = "gzip -dc | docker load"
+	}
+	return wrap(c, script)
+}
+
+// plainMeterInterval is how often a meter that may not redraw prints a progress line
+const plainMeterInterval = 10 * time.Second
+
+// Meter counts the bytes of a stream and reports its progress on out until stopped: on a
+// live terminal by redrawing one line twice a second, otherwise with a line every ten
+// seconds
+type Meter struct {
+	n     atomic.Int64
+	total int64
+	start time.Time
+	out   io.Writer
+	live  bool
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// NewMeter starts a meter towards total bytes (0 when unknown), reporting on out unless it
+// is nil
+func NewMeter(out io.Writer, total int64, live bool) *Meter {
+	m := &Meter{total: total, start: time.Now(), out: out, live: live, stop: make(chan struct{}), done: make(chan struct{})}
+	go m.run()
+	return m
+}
+
+// Reader counts what is read through r
+func (m *Meter) Reader(r io.Reader) io.Reader {
+	return &meterReader{r: r, m: m}
+}
+
+// Bytes returns the bytes counted so far
+func (m *Meter) Bytes() int64 {
+	return m.n.Load()
+}
+
+// Stop stops reporting and clears a redrawn progress line
+func (m *Meter) Stop() {
+	close(m.stop)
+	<-m.done
+}
+
+func (m *Meter) run() {
+	defer close(m.done)
+	if m.out == nil {
+		return
+	}
+	interval := plainMeterInterval
+	if m.live {
+		interval = 500 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			if m.live {
+				fmt.Fprint(m.out, "\r\x1b[2K")
+			}
+			return
+		case <-ticker.C:
+			if m.live {
+				fmt.Fprintf(m.out, "\r\x1b[2K%s", m.line())
+			} else {
+				fmt.Fprintln(m.out, m.line())
+			}
+		}
+	}
+}
+
+// line is the progress line: bytes so far, the share of total, and the rate
+func (m *Meter) line() string {
+	n := m.Bytes()
+	elapsed := time.Since(m.start)
+	mib := float64(n) / (1 << 20)
+	s := fmt.Sprintf("  %.1f MiB", mib)
+	if m.total > 0 {
+		s += fmt.Sprintf(" of %.1f MiB (%d%%)", float64(m.total)/(1<<20), min(100, n*100/m.total))
+	}
+	if elapsed >= time.Second {
+		s += fmt.Sprintf(", %.1f MiB/s, %s", mib/elapsed.Seconds(), elapsed.Round(time.Second))
+	}
+	return s
+}
+
+type meterReader struct {
+	r io.Reader
+	m *Meter
+}
+
+func (r *meterReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.m.n.Add(int64(n))
+	return n, err
+}
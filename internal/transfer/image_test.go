@@ -0,0 +1,47 @@
+package transfer
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseImages(t *testing.T) {
+	refs := []string{"trainer:dev", "missing:latest"}
+	images, err := ParseImages(refs, "sha256:3f9a 8123456789\n- 0\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if images[0].ID != "sha256:3f9a" || images[0].Size != 8123456789 || images[1].ID != "" {
+		t.Fatalf("ParseImages = %+v", images)
+	}
+	if _, err := ParseImages(refs, "sha256:3f9a 8123456789\n"); err == nil {
+		t.Fatalf("expected an error for a missing line")
+	}
+}
+
+func TestImageStream(t *testing.T) {
+	for _, tool := range []string{"bash", "zstd"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not installed", tool)
+		}
+	}
+	dir := t.TempDir()
+	loaded := filepath.Join(dir, "loaded")
+	docker := "#!/bin/sh\ncase $1 in\nsave) shift; echo \"archive of $*\" ;;\nload) cat > " + loaded + "; echo 'Loaded image: trainer:dev' ;;\nesac\n"
+	if err := os.WriteFile(filepath.Join(dir, "docker"), []byte(docker), 0755); err != nil {
+		t.Fatal(err)
+	}
+	pipeline := SaveCommand([]string{"trainer:dev", "base image"}, CompressZstd) + " | " + LoadCommand(CompressZstd)
+	c := exec.Command("bash", "-c", pipeline)
+	c.Env = append(os.Environ(), "PATH="+dir+":"+os.Getenv("PATH"))
+	out, err := c.CombinedOutput()
+	if err != nil || !strings.Contains(string(out), "Loaded image: trainer:dev") {
+		t.Fatalf("stream: %v: %s", err, out)
+	}
+	if data, _ := os.ReadFile(loaded); string(data) != "archive of trainer:dev base image\n" {
+		t.Fatalf("docker load read %q", data)
+	}
+}
@@ -0,0 +1,161 @@
+package transfer
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minBurst keeps very low limits from degrading into one tiny write per token
+const minBurst = 4096
+
+// Limiter is a token bucket that paces a byte stream to a fixed rate. Tokens accrue at the
+// rate up to an eighth of a second's worth, so short bursts are smoothed out instead of
+// sent at line speed.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+	sleep  func(time.Duration)
+}
+
+// NewLimiter creates a limiter for bytesPerSecond
+func NewLimiter(bytesPerSecond int64) *Limiter {
+	burst := float64(bytesPerSecond) / 8
+	if burst < minBurst {
+		burst = minBurst
+	}
+	return &Limiter{
+		rate:   float64(bytesPerSecond),
+		burst:  burst,
+		tokens: burst,
+		now:    time.Now,
+		sleep:  time.Sleep,
+	}
+}
+
+// chunk is the largest read or write the limiter lets through at once
+func (l *Limiter) chunk() int {
+	return int(l.burst)
+}
+
+// Wait blocks until n bytes may be sent
+func (l *Limiter) Wait(n int) {
+	l.mu.Lock()
+	now := l.now()
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	deficit := -l.tokens
+	l.mu.Unlock()
+
+	if deficit > 0 {
+		l.sleep(time.Duration(deficit / l.rate * float64(time.Second)))
+	}
+}
+
+type reader struct {
+	r io.Reader
+	l *Limiter
+}
+
+// NewReader paces reads from r to the limiter's rate
+func NewReader(r io.Reader, l *Limiter) io.Reader {
+	return &reader{r: r, l: l}
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if len(p) > r.l.chunk() {
+		p = p[:r.l.chunk()]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.l.Wait(n)
+	}
+	return n, err
+}
+
+type writer struct {
+	w io.Writer
+	l *Limiter
+}
+
+// NewWriter paces writes to w to the limiter's rate
+func NewWriter(w io.Writer, l *Limiter) io.Writer {
+	return &writer{w: w, l: l}
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := len(p)
+		if n > w.l.chunk() {
+			n = w.l.chunk()
+		}
+		w.l.Wait(n)
+		m, err := w.w.Write(p[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// ParseRate parses a bandwidth such as "500K", "10M", "1.5MB/s", or a plain number of
+// bytes per second. Suffixes are binary (K = 1024), as with rsync --bwlimit.
+func ParseRate(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	value = strings.TrimSuffix(value, "/S")
+	value = strings.TrimSuffix(strings.TrimSuffix(value, "B"), "I")
+	multiplier := 1.0
+	if value != "" {
+		switch value[len(value)-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		}
+		if multiplier > 1 {
+			value = value[:len(value)-1]
+		}
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid bandwidth %q (use e.g. 500K, 10M, 1G)", s)
+	}
+	rate := int64(n * multiplier)
+	if rate < 1 {
+		return 0, fmt.Errorf("invalid bandwidth %q (use e.g. 500K, 10M, 1G)", s)
+	}
+	return rate, nil
+}
+
+// FormatRate renders bytes per second the way ParseRate accepts it
+func FormatRate(rate int64) string {
+	switch {
+	case rate >= 1<<30 && rate%(1<<30) == 0:
+		return fmt.Sprintf("%dG", rate>>30)
+	case rate >= 1<<20 && rate%(1<<20) == 0:
+		return fmt.Sprintf("%dM", rate>>20)
+	case rate >= 1<<10 && rate%(1<<10) == 0:
+		return fmt.Sprintf("%dK", rate>>10)
+	default:
+		return strconv.FormatInt(rate, 10)
+	}
+}
@@ -0,0 +1,68 @@
+package transfer
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	cases := map[string]int64{
+		"1000":     1000,
+		"500K":     500 << 10,
+		"10M":      10 << 20,
+		"1.5MB/s":  3 << 19,
+		"2g":       2 << 30,
+		"64KiB":    64 << 10,
+		" 8mb/s  ": 8 << 20,
+	}
+	for input, want := range cases {
+		got, err := ParseRate(input)
+		if err != nil || got != want {
+			t.Fatalf("ParseRate(%q) = %d, %v; want %d", input, got, err, want)
+		}
+		if back, err := ParseRate(FormatRate(got)); err != nil || back != got {
+			t.Fatalf("FormatRate(%d) = %q does not round-trip", got, FormatRate(got))
+		}
+	}
+	for _, bad := range []string{"", "M", "-5M", "fast", "0"} {
+		if _, err := ParseRate(bad); err == nil {
+			t.Fatalf("ParseRate(%q) should fail", bad)
+		}
+	}
+}
+
+func TestLimiterPacesToRate(t *testing.T) {
+	clock := time.Unix(0, 0)
+	l := NewLimiter(64 << 10) // 64 KiB/s, 8 KiB burst
+	l.now = func() time.Time { return clock }
+	l.sleep = func(d time.Duration) { clock = clock.Add(d) }
+
+	data := bytes.Repeat([]byte("x"), 256<<10)
+	var out bytes.Buffer
+	if _, err := io.Copy(NewWriter(&out, l), NewReader(bytes.NewReader(data), NewLimiter(1<<30))); err != nil {
+		t.Fatalf("copy failed: %v", err)
+	}
+	if out.Len() != len(data) {
+		t.Fatalf("copied %d bytes, want %d", out.Len(), len(data))
+	}
+
+	// 256 KiB at 64 KiB/s, less the initial burst, takes just under 4s
+	elapsed := clock.Sub(time.Unix(0, 0))
+	if elapsed < 3800*time.Millisecond || elapsed > 4*time.Second {
+		t.Fatalf("transfer took %v of simulated time, want about 4s", elapsed)
+	}
+}
+
+func TestReaderChunksReads(t *testing.T) {
+	l := NewLimiter(1 << 20)
+	l.sleep = func(time.Duration) {}
+	r := NewReader(strings.NewReader(strings.Repeat("y", 1<<20)), l)
+	buf := make([]byte, 1<<20)
+	n, _ := r.Read(buf)
+	if n != l.chunk() {
+		t.Fatalf("read %d bytes, want one %d byte chunk", n, l.chunk())
+	}
+}
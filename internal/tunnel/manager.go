@@ -9,6 +9,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/weatherman/dgx-manager/internal/ssh"
 	"github.com/weatherman/dgx-manager/pkg/types"
 )
 
@@ -30,13 +31,16 @@ func (m *Manager) Create(tunnel types.Tunnel) error {
 	args := []string{
 		"-N", // Don't execute remote command
 		"-f", // Go to background
-		"-i", m.config.IdentityFile,
+	}
+	args = append(args, ssh.NativeArgs(m.config)...)
+	args = append(args,
 		"-p", fmt.Sprintf("%d", m.config.Port),
 		"-L", fmt.Sprintf("%d:%s:%d", tunnel.LocalPort, tunnel.RemoteHost, tunnel.RemotePort),
 		fmt.Sprintf("%s@%s", m.config.User, m.config.Host),
-	}
+	)
 
 	cmd := exec.Command("ssh", args...)
+	cmd.Env = ssh.AgentEnv(m.config)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
@@ -0,0 +1,40 @@
+package ui
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// OrDash returns s, or "-" when it is empty, for table cells that must not be blank
+func OrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// Truncate shortens s to width runes, marking the cut with an ellipsis. A width of zero
+// or less leaves s alone.
+func Truncate(s string, width int) string {
+	if width <= 0 || utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:width-1]) + "…"
+}
+
+// OneLine folds runs of whitespace, newlines included, into single spaces so s fits on
+// one line of a table or status row
+func OneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// ShortID returns the first 12 characters of a container or image ID, without its
+// "sha256:" prefix, as docker prints them
+func ShortID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,42 @@
+// Package ui holds the output style every command shares: whether terminal output may be
+// redrawn in place, with progress lines, live tables, footers, colors, and cursor
+// movement, or must be plain lines, as screen readers and log files need.
+package ui
+
+import (
+	"os"
+
+	"golang.org/x/term"
+)
+
+// EnvPlain turns plain output on for dgx and the dgx processes it starts, such as fan-out
+// children and plugins
+const EnvPlain = "DGX_PLAIN"
+
+var plain = os.Getenv(EnvPlain) != "" || os.Getenv("TERM") == "dumb"
+
+// SetPlain turns plain output on or off, exporting the choice to child processes
+func SetPlain(on bool) {
+	plain = on
+	if on {
+		os.Setenv(EnvPlain, "1")
+	} else {
+		os.Unsetenv(EnvPlain)
+	}
+}
+
+// Plain reports whether output must be plain lines
+func Plain() bool {
+	return plain
+}
+
+// Live reports whether f is a terminal that may be redrawn in place
+func Live(f *os.File) bool {
+	return !plain && term.IsTerminal(int(f.Fd()))
+}
+
+// Color reports whether output to f may use ANSI colors: a terminal, outside plain mode,
+// with NO_COLOR unset
+func Color(f *os.File) bool {
+	return Live(f) && os.Getenv("NO_COLOR") == ""
+}
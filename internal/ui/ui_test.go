@@ -0,0 +1,64 @@
+package ui
+
+import (
+	"os"
+	"testing"
+)
+
+func TestSetPlain(t *testing.T) {
+	defer SetPlain(Plain())
+	SetPlain(true)
+	if !Plain() || os.Getenv(EnvPlain) != "1" {
+		t.Fatalf("SetPlain(true): Plain() = %v, $%s = %q", Plain(), EnvPlain, os.Getenv(EnvPlain))
+	}
+	// A pipe is never live, and plain mode rules out redrawing anything
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe: %v", err)
+	}
+	defer r.Close()
+	defer w.Close()
+	if Live(w) || Color(w) {
+		t.Fatalf("a pipe in plain mode counts as live")
+	}
+	SetPlain(false)
+	if Plain() || os.Getenv(EnvPlain) != "" {
+		t.Fatalf("SetPlain(false): Plain() = %v, $%s = %q", Plain(), EnvPlain, os.Getenv(EnvPlain))
+	}
+	if Live(w) {
+		t.Fatalf("a pipe counts as a live terminal")
+	}
+}
+
+func TestText(t *testing.T) {
+	if got := OrDash(""); got != "-" {
+		t.Fatalf("OrDash(\"\") = %q", got)
+	}
+	if got := OrDash("x"); got != "x" {
+		t.Fatalf("OrDash(\"x\") = %q", got)
+	}
+	for _, tc := range []struct {
+		in    string
+		width int
+		want  string
+	}{
+		{"short", 10, "short"},
+		{"exactly10!", 10, "exactly10!"},
+		{"much too long", 8, "much to…"},
+		{"café au lait", 5, "café…"},
+		{"anything", 0, "anything"},
+	} {
+		if got := Truncate(tc.in, tc.width); got != tc.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", tc.in, tc.width, got, tc.want)
+		}
+	}
+	if got := OneLine("  Hello,\n\tworld  \n"); got != "Hello, world" {
+		t.Fatalf("OneLine = %q", got)
+	}
+	if got := ShortID("sha256:0123456789abcdef"); got != "0123456789ab" {
+		t.Fatalf("ShortID = %q", got)
+	}
+	if got := ShortID("abc"); got != "abc" {
+		t.Fatalf("ShortID(\"abc\") = %q", got)
+	}
+}
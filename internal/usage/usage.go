@@ -0,0 +1,188 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultFile is the usage log name inside the dgx config directory
+const DefaultFile = "usage.jsonl"
+
+// Record is one proxied request
+type Record struct {
+	Time             time.Time     `json:"time"`
+	Key              string        `json:"key,omitempty"`
+	Model            string        `json:"model"`
+	Backend          string        `json:"backend"`
+	Status           int           `json:"status"`
+	PromptTokens     int           `json:"prompt_tokens"`
+	CompletionTokens int           `json:"completion_tokens"`
+	Duration         time.Duration `json:"duration_ns"`
+}
+
+// Store is an append-only JSON-lines usage log
+type Store struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewStore creates a store backed by the file at path
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// DefaultPath returns ~/.config/dgx/usage.jsonl
+func DefaultPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".config", "dgx", DefaultFile), nil
+}
+
+// Append adds a record to the log
+func (s *Store) Append(rec Record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode usage record: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create usage directory: %w", err)
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open usage log: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write usage log: %w", err)
+	}
+	return nil
+}
+
+// Load returns records at or after since. A missing log yields no records.
+func (s *Store) Load(since time.Time) ([]Record, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open usage log: %w", err)
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec Record
+		// Skip lines truncated by a crash mid-write
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		if !rec.Time.Before(since) {
+			records = append(records, rec)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read usage log: %w", err)
+	}
+	return records, nil
+}
+
+// Summary aggregates records sharing a model and key
+type Summary struct {
+	Model            string
+	Key              string
+	Requests         int
+	Errors           int
+	PromptTokens     int
+	CompletionTokens int
+	WallTime         time.Duration
+}
+
+// Summarize groups records by model and key, busiest first
+func Summarize(records []Record) []Summary {
+	byGroup := make(map[[2]string]*Summary)
+	for _, rec := range records {
+		group := [2]string{rec.Model, rec.Key}
+		sum, ok := byGroup[group]
+		if !ok {
+			sum = &Summary{Model: rec.Model, Key: rec.Key}
+			byGroup[group] = sum
+		}
+		sum.Requests++
+		if rec.Status >= 400 {
+			sum.Errors++
+		}
+		sum.PromptTokens += rec.PromptTokens
+		sum.CompletionTokens += rec.CompletionTokens
+		sum.WallTime += rec.Duration
+	}
+
+	summaries := make([]Summary, 0, len(byGroup))
+	for _, sum := range byGroup {
+		summaries = append(summaries, *sum)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		a, b := summaries[i], summaries[j]
+		if ta, tb := a.PromptTokens+a.CompletionTokens, b.PromptTokens+b.CompletionTokens; ta != tb {
+			return ta > tb
+		}
+		if a.Requests != b.Requests {
+			return a.Requests > b.Requests
+		}
+		return a.Model+a.Key < b.Model+b.Key
+	})
+	return summaries
+}
+
+// WriteCSV writes summaries as CSV with a header row
+func WriteCSV(w io.Writer, summaries []Summary) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"model", "key", "requests", "errors", "prompt_tokens", "completion_tokens", "wall_time_seconds"})
+	for _, s := range summaries {
+		cw.Write([]string{
+			s.Model,
+			s.Key,
+			strconv.Itoa(s.Requests),
+			strconv.Itoa(s.Errors),
+			strconv.Itoa(s.PromptTokens),
+			strconv.Itoa(s.CompletionTokens),
+			strconv.FormatFloat(s.WallTime.Seconds(), 'f', 3, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ParseSince parses a lookback window such as "7d", "12h", or "2w"
+func ParseSince(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(value, suffix); ok {
+			count, err := strconv.Atoi(n)
+			if err != nil || count < 0 {
+				return 0, fmt.Errorf("invalid duration %q", value)
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q (use e.g. 7d, 12h, 2w)", value)
+	}
+	return d, nil
+}
@@ -0,0 +1,140 @@
+package usage
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultFile)
+	store := NewStore(path)
+	if records, err := store.Load(time.Time{}); err != nil || records != nil {
+		t.Fatalf("a missing log should yield no records, got %v, %v", records, err)
+	}
+
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	for _, rec := range []Record{
+		{Time: now.Add(-48 * time.Hour), Model: "old"},
+		{Time: now.Add(-time.Hour), Model: "recent"},
+		{Time: now, Model: "now"},
+	} {
+		if err := store.Append(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A corrupt line in the middle and one cut short by a crash at the end
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("not json\n")
+	line, _ := json.Marshal(Record{Time: now, Model: "torn"})
+	f.Write(line[:len(line)/2])
+	f.Close()
+
+	tests := []struct {
+		since time.Time
+		want  []string
+	}{
+		{time.Time{}, []string{"old", "recent", "now"}},
+		{now.Add(-24 * time.Hour), []string{"recent", "now"}},
+		{now, []string{"now"}},
+		{now.Add(time.Second), nil},
+	}
+	for _, tt := range tests {
+		records, err := store.Load(tt.since)
+		if err != nil {
+			t.Fatalf("Load(%v): %v", tt.since, err)
+		}
+		var got []string
+		for _, rec := range records {
+			got = append(got, rec.Model)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("Load(%v) = %v, want %v", tt.since, got, tt.want)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	records := []Record{
+		{Model: "small", Key: "alice", Status: 200, PromptTokens: 5, CompletionTokens: 5, Duration: time.Second},
+		{Model: "big", Key: "bob", Status: 200, PromptTokens: 100, CompletionTokens: 50, Duration: 2 * time.Second},
+		{Model: "big", Key: "bob", Status: 502, Duration: time.Second},
+		{Model: "big", Key: "alice", Status: 429},
+		{Model: "big", Key: "alice", Status: 200},
+		{Model: "a", Key: "alice", Status: 404},
+		{Model: "small", Key: "alice", Status: 500, PromptTokens: 1},
+	}
+	got := Summarize(records)
+	want := []Summary{
+		{Model: "big", Key: "bob", Requests: 2, Errors: 1, PromptTokens: 100, CompletionTokens: 50, WallTime: 3 * time.Second},
+		{Model: "small", Key: "alice", Requests: 2, Errors: 1, PromptTokens: 6, CompletionTokens: 5, WallTime: time.Second},
+		// Equal tokens: more requests first, then by name
+		{Model: "big", Key: "alice", Requests: 2, Errors: 1},
+		{Model: "a", Key: "alice", Requests: 1, Errors: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Summarize =\n%+v\nwant\n%+v", got, want)
+	}
+	if got := Summarize(nil); len(got) != 0 {
+		t.Fatalf("expected no summaries, got %+v", got)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteCSV(&buf, []Summary{
+		{Model: "ai/smollm2", Key: "alice", Requests: 3, Errors: 1, PromptTokens: 12, CompletionTokens: 34, WallTime: 1500 * time.Millisecond},
+		{Model: "m,with,commas", Requests: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"model,key,requests,errors,prompt_tokens,completion_tokens,wall_time_seconds",
+		"ai/smollm2,alice,3,1,12,34,1.500",
+		`"m,with,commas",,1,0,0,0,0.000`,
+		"",
+	}, "\n")
+	if buf.String() != want {
+		t.Fatalf("WriteCSV =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := WriteCSV(&buf, nil); err != nil || buf.String() != "model,key,requests,errors,prompt_tokens,completion_tokens,wall_time_seconds\n" {
+		t.Fatalf("expected only the header, got %q, %v", buf.String(), err)
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"7d", 7 * 24 * time.Hour, true},
+		{"2w", 14 * 24 * time.Hour, true},
+		{"12h", 12 * time.Hour, true},
+		{" 30m ", 30 * time.Minute, true},
+		{"0d", 0, true},
+		{"-1d", 0, false},
+		{"-2h", 0, false},
+		{"d", 0, false},
+		{"1.5d", 0, false},
+		{"", 0, false},
+		{"week", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseSince(tt.value)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Fatalf("ParseSince(%q) = %v, %v; want %v (ok %v)", tt.value, got, err, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,498 @@
+package workload
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/weatherman/dgx-manager/internal/artifacts"
+	"github.com/weatherman/dgx-manager/internal/deploy"
+	"github.com/weatherman/dgx-manager/internal/dmr"
+	"github.com/weatherman/dgx-manager/internal/gpu"
+	"github.com/weatherman/dgx-manager/internal/ssh"
+	"github.com/weatherman/dgx-manager/internal/ui"
+)
+
+// Kind is the sort of workload
+type Kind string
+
+const (
+	KindContainer Kind = "container"
+	KindModel     Kind = "model"
+	KindProcess   Kind = "process"
+)
+
+// Workload is something running on the DGX that holds (or may hold) the GPU
+type Workload struct {
+	Host   string        `json:"host"`
+	Kind   Kind          `json:"kind"`
+	ID     string        `json:"id"`
+	Name   string        `json:"name"`
+	Origin string        `json:"origin"`
+	User   string        `json:"user,omitempty"`
+	Uptime time.Duration `json:"uptime_ns,omitempty"`
+	Idle   time.Duration `json:"idle_ns,omitempty"`
+	GPU    bool          `json:"gpu"`
+	// Memory figures are in bytes; CPU is a percentage of one core
+	GPUMemory  int64   `json:"gpu_memory_bytes"`
+	CPUPercent float64 `json:"cpu_percent"`
+	Memory     int64   `json:"memory_bytes"`
+	Detail     string  `json:"detail,omitempty"`
+	PIDs       []int   `json:"pids,omitempty"`
+	Unit       string  `json:"unit,omitempty"` // systemd unit that owns the workload
+}
+
+// snapshotScript prints everything List needs in one round trip, as "@@section" headers
+// followed by pipe-separated lines
+const snapshotScript = `echo @@now; date +%s
+echo @@gpu; nvidia-smi --query-compute-apps=pid,used_memory --format=csv,noheader,nounits 2>/dev/null
+pids=$(nvidia-smi --query-compute-apps=pid --format=csv,noheader 2>/dev/null | tr -d ' ' | paste -sd, -)
+echo @@ps; [ -n "$pids" ] && ps -o pid=,user=,etimes=,pcpu=,rss=,args= -p "$pids"
+echo @@cgroup; for p in $(echo "$pids" | tr , ' '); do echo "$p|$(tr '\n' ';' < /proc/$p/cgroup 2>/dev/null)"; done
+ids=$(docker ps -q 2>/dev/null)
+echo @@containers; [ -n "$ids" ] && docker inspect --format '{{.Id}}|{{.Name}}|{{.Config.Image}}|{{.State.StartedAt}}|{{.HostConfig.Runtime}}|{{len .HostConfig.DeviceRequests}}' $ids
+echo @@stats; [ -n "$ids" ] && docker stats --no-stream --no-trunc --format '{{.ID}}|{{.CPUPerc}}|{{.MemUsage}}' $ids
+true`
+
+// dmrTimeout bounds the Model Runner query, which is skipped when the runner is not installed
+const dmrTimeout = 5 * time.Second
+
+// Collector lists the workloads on a DGX
+type Collector struct {
+	sshClient *ssh.Client
+	host      string
+}
+
+// NewCollector creates a collector for the DGX at host
+func NewCollector(sshClient *ssh.Client, host string) *Collector {
+	return &Collector{
+		sshClient: sshClient,
+		host:      host,
+	}
+}
+
+// List returns GPU containers, loaded Model Runner models, and bare GPU processes. With
+// all set, containers without GPU access are included too.
+func (c *Collector) List(ctx context.Context, all bool) ([]Workload, error) {
+	output, err := c.sshClient.ExecuteContext(ctx, snapshotScript)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workloads: %w", err)
+	}
+	workloads := parseSnapshot(c.host, output, all)
+
+	dmrCtx, cancel := context.WithTimeout(ctx, dmrTimeout)
+	defer cancel()
+	if runners, err := dmr.NewClient(c.sshClient.Dial, "tcp", dmr.DefaultAddr).Running(dmrCtx); err == nil {
+		now := time.Now()
+		for _, r := range runners {
+			w := Workload{
+				Host:   c.host,
+				Kind:   KindModel,
+				ID:     r.Model,
+				Name:   r.Model,
+				Origin: "model runner",
+				GPU:    true,
+				Detail: strings.TrimSpace(r.Backend + " " + r.Mode),
+			}
+			if !r.LastUsed.IsZero() {
+				w.Idle = now.Sub(r.LastUsed)
+			}
+			workloads = append(workloads, w)
+		}
+	}
+	return workloads, nil
+}
+
+type process struct {
+	pid     int
+	user    string
+	elapsed time.Duration
+	cpu     float64
+	rss     int64
+	args    string
+	gpuMem  int64
+	cgroup  string
+}
+
+type container struct {
+	Workload
+	gpuFlag bool
+}
+
+// parseSnapshot turns snapshotScript output into workloads, attributing GPU processes to
+// the containers they run in
+func parseSnapshot(host, output string, all bool) []Workload {
+	sections := map[string][]string{}
+	current := ""
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(line, "@@") {
+			current = strings.TrimPrefix(line, "@@")
+			continue
+		}
+		if strings.TrimSpace(line) != "" {
+			sections[current] = append(sections[current], line)
+		}
+	}
+
+	var now time.Time
+	if len(sections["now"]) > 0 {
+		if secs, err := strconv.ParseInt(strings.TrimSpace(sections["now"][0]), 10, 64); err == nil {
+			now = time.Unix(secs, 0)
+		}
+	}
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	processes := map[int]*process{}
+	var order []int
+	for _, line := range sections["gpu"] {
+		fields := strings.Split(line, ",")
+		if len(fields) < 2 {
+			continue
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+		if err != nil {
+			continue
+		}
+		p, ok := processes[pid]
+		if !ok {
+			p = &process{pid: pid}
+			processes[pid] = p
+			order = append(order, pid)
+		}
+		// used_memory is "[N/A]" on unified-memory systems
+		if mib, err := strconv.ParseInt(strings.TrimSpace(fields[1]), 10, 64); err == nil {
+			p.gpuMem += mib << 20
+		}
+	}
+	for _, line := range sections["ps"] {
+		fields := strings.Fields(line)
+		if len(fields) < 6 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil || processes[pid] == nil {
+			continue
+		}
+		p := processes[pid]
+		p.user = fields[1]
+		if secs, err := strconv.ParseInt(fields[2], 10, 64); err == nil {
+			p.elapsed = time.Duration(secs) * time.Second
+		}
+		p.cpu, _ = strconv.ParseFloat(fields[3], 64)
+		if kib, err := strconv.ParseInt(fields[4], 10, 64); err == nil {
+			p.rss = kib << 10
+		}
+		p.args = strings.Join(fields[5:], " ")
+	}
+	for _, line := range sections["cgroup"] {
+		pidField, cgroup, ok := strings.Cut(line, "|")
+		if !ok {
+			continue
+		}
+		if pid, err := strconv.Atoi(pidField); err == nil && processes[pid] != nil {
+			processes[pid].cgroup = cgroup
+		}
+	}
+
+	containers := map[string]*container{}
+	var containerOrder []string
+	for _, line := range sections["containers"] {
+		fields := strings.Split(line, "|")
+		if len(fields) < 6 {
+			continue
+		}
+		name := strings.TrimPrefix(fields[1], "/")
+		c := &container{Workload: Workload{
+			Host:   host,
+			Kind:   KindContainer,
+			ID:     ui.ShortID(fields[0]),
+			Name:   name,
+			Detail: fields[2],
+			User:   "root",
+		}}
+		c.Origin, c.Unit = containerOrigin(name)
+		if started, err := time.Parse(time.RFC3339Nano, fields[3]); err == nil && started.Before(now) {
+			c.Uptime = now.Sub(started)
+		}
+		c.gpuFlag = fields[4] == "nvidia" || (fields[5] != "" && fields[5] != "0")
+		containers[fields[0]] = c
+		containerOrder = append(containerOrder, fields[0])
+	}
+	for _, line := range sections["stats"] {
+		fields := strings.Split(line, "|")
+		if len(fields) < 3 {
+			continue
+		}
+		c := containers[fields[0]]
+		if c == nil {
+			continue
+		}
+		c.CPUPercent, _ = strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(fields[1]), "%"), 64)
+		used, _, _ := strings.Cut(fields[2], "/")
+		c.Memory, _ = artifacts.ParseBytes(used)
+	}
+
+	var workloads []Workload
+	var bare []Workload
+	for _, pid := range order {
+		p := processes[pid]
+		if id := gpu.ContainerIDFromCgroup(p.cgroup); id != "" && containers[id] != nil {
+			c := containers[id]
+			c.GPU = true
+			c.GPUMemory += p.gpuMem
+			c.PIDs = append(c.PIDs, pid)
+			continue
+		}
+		w := Workload{
+			Host:       host,
+			Kind:       KindProcess,
+			ID:         strconv.Itoa(pid),
+			Name:       processName(p.args, pid),
+			User:       p.user,
+			Uptime:     p.elapsed,
+			GPU:        true,
+			GPUMemory:  p.gpuMem,
+			CPUPercent: p.cpu,
+			Memory:     p.rss,
+			Detail:     p.args,
+			PIDs:       []int{pid},
+		}
+		w.Origin, w.Unit = processOrigin(p.cgroup)
+		bare = append(bare, w)
+	}
+	for _, id := range containerOrder {
+		c := containers[id]
+		if !c.GPU && !c.gpuFlag && !all {
+			continue
+		}
+		c.GPU = c.GPU || c.gpuFlag
+		workloads = append(workloads, c.Workload)
+	}
+	return append(workloads, bare...)
+}
+
+// containerOrigin names what started a container, from the names dgx gives its own, and
+// the systemd unit that manages it
+func containerOrigin(name string) (string, string) {
+	switch {
+	case name == "vllm-server":
+		return "dgx run vllm", ""
+	case strings.HasPrefix(name, "dgx-"):
+		// vLLM autostart units run their container as dgx-<entry name>
+		entry := strings.TrimPrefix(name, "dgx-")
+		return "dgx autostart " + entry, deploy.UnitName(entry)
+	case strings.Contains(name, "model-runner"):
+		return "model runner", ""
+	default:
+		return "docker", ""
+	}
+}
+
+// processOrigin classifies a process by the systemd unit in its cgroup path
+func processOrigin(cgroup string) (string, string) {
+	unit := ""
+	for _, part := range strings.FieldsFunc(cgroup, func(r rune) bool { return r == '/' || r == ';' }) {
+		if strings.HasSuffix(part, ".service") {
+			unit = part
+		}
+	}
+	switch {
+	case strings.HasPrefix(unit, "dgx-autostart-"):
+		return "dgx autostart " + strings.TrimSuffix(strings.TrimPrefix(unit, "dgx-autostart-"), ".service"), unit
+	case unit == "ollama.service":
+		return "ollama", unit
+	case unit != "" && !strings.HasPrefix(unit, "user@"):
+		return "systemd " + strings.TrimSuffix(unit, ".service"), unit
+	default:
+		return "manual", ""
+	}
+}
+
+func processName(args string, pid int) string {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return strconv.Itoa(pid)
+	}
+	name := fields[0]
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	// Interpreters: show the script or module instead
+	if strings.HasPrefix(name, "python") && len(fields) > 1 {
+		next := fields[1]
+		if next == "-m" && len(fields) > 2 {
+			next = fields[2]
+		}
+		if i := strings.LastIndex(next, "/"); i >= 0 {
+			next = next[i+1:]
+		}
+		name += " " + next
+	}
+	return name
+}
+
+// Find returns the workloads matching ref: a container name or ID prefix, a model name,
+// or a PID
+func Find(workloads []Workload, ref string) []Workload {
+	var matches []Workload
+	for _, w := range workloads {
+		switch {
+		case w.Name == ref || w.ID == ref:
+			return []Workload{w}
+		case w.Kind == KindContainer && len(ref) >= 4 && strings.HasPrefix(w.ID, ref):
+			matches = append(matches, w)
+		}
+	}
+	return matches
+}
+
+// LogsCommand returns the remote command that shows the workload's logs
+func (w Workload) LogsCommand(tail int, follow bool) (string, error) {
+	followFlag := ""
+	if follow {
+		followFlag = " -f"
+	}
+	switch {
+	case w.Kind == KindContainer:
+		return fmt.Sprintf("docker logs --tail %d%s %s", tail, followFlag, ssh.ShellQuote(w.ID)), nil
+	case w.Kind == KindModel && follow:
+		return "docker model logs -f", nil
+	case w.Kind == KindModel:
+		return fmt.Sprintf("docker model logs | tail -n %d", tail), nil
+	case w.Unit != "":
+		return fmt.Sprintf("journalctl -u %s -n %d%s --no-pager", ssh.ShellQuote(w.Unit), tail, followFlag), nil
+	default:
+		return "", fmt.Errorf("%s (PID %s) was started by hand and has no log to show", w.Name, w.ID)
+	}
+}
+
+// FilterLogs wraps a LogsCommand so its output, stderr included, is filtered on the DGX
+// by the extended regular expression pattern and only matching lines are sent. An empty
+// pattern just merges stderr into stdout.
+func FilterLogs(command, pattern string, ignoreCase bool) string {
+	command = fmt.Sprintf("{ %s; } 2>&1", command)
+	if pattern == "" {
+		return command
+	}
+	flags := "-E"
+	if ignoreCase {
+		flags = "-Ei"
+	}
+	// grep exits 1 when no line matched, which is not a failure here
+	return fmt.Sprintf("%s | grep --line-buffered %s -- %s || [ $? -eq 1 ]", command, flags, ssh.ShellQuote(pattern))
+}
+
+// StopCommand returns the remote command that stops the workload. Workloads owned by a
+// systemd unit are stopped through it, so the unit does not restart them.
+func (w Workload) StopCommand(force bool) string {
+	switch {
+	case w.Unit != "":
+		return fmt.Sprintf("sudo systemctl stop %s", ssh.ShellQuote(w.Unit))
+	case w.Kind == KindContainer:
+		return fmt.Sprintf("docker stop %s", ssh.ShellQuote(w.ID))
+	case w.Kind == KindModel:
+		return fmt.Sprintf("docker model unload %s", ssh.ShellQuote(w.Name))
+	default:
+		signal := "TERM"
+		if force {
+			signal = "KILL"
+		}
+		return fmt.Sprintf("kill -s %s %s 2>/dev/null || sudo kill -s %s %s", signal, w.ID, signal, w.ID)
+	}
+}
+
+// containerShell prefers bash and falls back to sh for minimal images
+const containerShell = `sh -c 'if command -v bash >/dev/null 2>&1; then exec bash; else exec sh; fi'`
+
+// ExecCommand returns the docker exec -it command that runs command in container, or a
+// shell when command is empty, as user and in workdir when they are set
+func ExecCommand(container, user, workdir string, command []string) string {
+	remote := []string{"docker", "exec", "-it"}
+	if user != "" {
+		remote = append(remote, "--user", ssh.ShellQuote(user))
+	}
+	if workdir != "" {
+		remote = append(remote, "--workdir", ssh.ShellQuote(workdir))
+	}
+	remote = append(remote, ssh.ShellQuote(container))
+	if len(command) == 0 {
+		return strings.Join(append(remote, containerShell), " ")
+	}
+	for _, arg := range command {
+		remote = append(remote, ssh.ShellQuote(arg))
+	}
+	return strings.Join(remote, " ")
+}
+
+// Sort orders workloads by host, then GPU memory (largest first), then name
+func Sort(workloads []Workload) {
+	sort.SliceStable(workloads, func(i, j int) bool {
+		a, b := workloads[i], workloads[j]
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.GPUMemory != b.GPUMemory {
+			return a.GPUMemory > b.GPUMemory
+		}
+		return a.Name < b.Name
+	})
+}
+
+// Format renders workloads as a table, with a HOST column when more than one host is shown
+func Format(workloads []Workload, showHost bool) string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	header := "KIND\tID\tNAME\tORIGIN\tUSER\tUPTIME\tGPU MEM\tCPU\tMEM\tDETAIL"
+	if showHost {
+		header = "HOST\t" + header
+	}
+	fmt.Fprintln(w, header)
+	for _, wl := range workloads {
+		uptime := formatDuration(wl.Uptime)
+		if wl.Kind == KindModel && wl.Idle > 0 {
+			uptime = "idle " + formatDuration(wl.Idle)
+		}
+		gpuMem := "-"
+		if wl.GPUMemory > 0 {
+			gpuMem = artifacts.FormatBytes(wl.GPUMemory)
+		} else if wl.GPU {
+			gpuMem = "yes"
+		}
+		cpu, mem := "-", "-"
+		if wl.Kind != KindModel {
+			cpu = fmt.Sprintf("%.0f%%", wl.CPUPercent)
+			mem = artifacts.FormatBytes(wl.Memory)
+		}
+		row := fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s",
+			wl.Kind, ui.Truncate(ui.OrDash(wl.ID), 24), ui.Truncate(ui.OrDash(wl.Name), 32), wl.Origin, ui.OrDash(wl.User),
+			uptime, gpuMem, cpu, mem, ui.Truncate(ui.OrDash(wl.Detail), 48))
+		if showHost {
+			row = wl.Host + "\t" + row
+		}
+		fmt.Fprintln(w, row)
+	}
+	w.Flush()
+	return b.String()
+}
+
+func formatDuration(d time.Duration) string {
+	switch {
+	case d <= 0:
+		return "-"
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
@@ -0,0 +1,117 @@
+package workload
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	vllmID  = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	redisID = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+)
+
+var snapshot = strings.Join([]string{
+	"@@now", "1700003600",
+	"@@gpu", "4242, 2048", "5151, [N/A]",
+	"@@ps",
+	" 4242 root     3500  310.5 1048576 python3 -m vllm.entrypoints.openai.api_server",
+	" 5151 alice     120   95.0  524288 /home/alice/.venv/bin/python train.py --epochs 3",
+	"@@cgroup",
+	"4242|0::/system.slice/docker-" + vllmID + ".scope;",
+	"5151|0::/user.slice/user-1000.slice/session-4.scope;",
+	"@@containers",
+	vllmID + "|/dgx-qwen|nvcr.io/nvidia/vllm:25.09-py3|2023-11-14T22:13:20.5Z|runc|1",
+	redisID + "|/redis|redis:7|2023-11-14T21:13:20Z|runc|0",
+	"@@stats",
+	vllmID + "|250.00%|3.5GiB / 119.7GiB",
+}, "\n")
+
+func TestParseSnapshot(t *testing.T) {
+	workloads := parseSnapshot("spark", snapshot, false)
+	if len(workloads) != 2 {
+		t.Fatalf("got %d workloads, want the vLLM container and the training process: %+v", len(workloads), workloads)
+	}
+
+	c := workloads[0]
+	if c.Kind != KindContainer || c.Name != "dgx-qwen" || c.ID != vllmID[:12] {
+		t.Fatalf("unexpected container %+v", c)
+	}
+	if c.Origin != "dgx autostart qwen" || c.Unit != "dgx-autostart-qwen.service" {
+		t.Fatalf("container origin = %q (unit %q)", c.Origin, c.Unit)
+	}
+	if c.GPUMemory != 2048<<20 || c.CPUPercent != 250 || c.Memory != 3584<<20 {
+		t.Fatalf("container usage = gpu %d cpu %v mem %d", c.GPUMemory, c.CPUPercent, c.Memory)
+	}
+	if c.Uptime < time.Hour-time.Second || c.Uptime > time.Hour {
+		t.Fatalf("container uptime = %v, want about 1h", c.Uptime)
+	}
+
+	p := workloads[1]
+	if p.Kind != KindProcess || p.ID != "5151" || p.User != "alice" || p.Origin != "manual" {
+		t.Fatalf("unexpected process %+v", p)
+	}
+	if p.Name != "python train.py" || p.Uptime != 2*time.Minute || p.Memory != 512<<20 {
+		t.Fatalf("process name %q uptime %v mem %d", p.Name, p.Uptime, p.Memory)
+	}
+
+	if all := parseSnapshot("spark", snapshot, true); len(all) != 3 {
+		t.Fatalf("got %d workloads with all, want the redis container too", len(all))
+	}
+}
+
+func TestStopCommandUsesOwningUnit(t *testing.T) {
+	w := Workload{Kind: KindContainer, ID: "abc", Unit: "dgx-autostart-qwen.service"}
+	if got := w.StopCommand(false); got != "sudo systemctl stop 'dgx-autostart-qwen.service'" {
+		t.Fatalf("StopCommand = %q", got)
+	}
+	if _, err := (Workload{Kind: KindProcess, ID: "1", Name: "python"}).LogsCommand(50, false); err == nil {
+		t.Fatal("a hand-started process should have no logs")
+	}
+}
+
+func TestExecCommand(t *testing.T) {
+	if got := ExecCommand("vllm", "", "", nil); got != "docker exec -it 'vllm' "+containerShell {
+		t.Fatalf("shell: %q", got)
+	}
+	got := ExecCommand("vllm", "root", "/work space", []string{"python", "-c", "print('hi')"})
+	want := `docker exec -it --user 'root' --workdir '/work space' 'vllm' 'python' '-c' 'print('"'"'hi'"'"')'`
+	if got != want {
+		t.Fatalf("ExecCommand = %q, want %q", got, want)
+	}
+
+	// The remote shell hands docker each argument unchanged
+	output, err := exec.Command("sh", "-c", `docker() { printf '%s\n' "$@"; }; `+got).Output()
+	if err != nil {
+		t.Fatalf("sh: %v", err)
+	}
+	args := strings.Split(strings.TrimSuffix(string(output), "\n"), "\n")
+	if wantArgs := []string{"exec", "-it", "--user", "root", "--workdir", "/work space", "vllm", "python", "-c", "print('hi')"}; !reflect.DeepEqual(args, wantArgs) {
+		t.Fatalf("docker got %q, want %q", args, wantArgs)
+	}
+}
+
+func TestFilterLogs(t *testing.T) {
+	if got := FilterLogs("docker logs -f abc", "", false); got != "{ docker logs -f abc; } 2>&1" {
+		t.Fatalf("unfiltered: %q", got)
+	}
+	want := `{ docker logs -f abc; } 2>&1 | grep --line-buffered -Ei -- 'error|it'"'"'s' || [ $? -eq 1 ]`
+	if got := FilterLogs("docker logs -f abc", "error|it's", true); got != want {
+		t.Fatalf("filtered:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFind(t *testing.T) {
+	workloads := parseSnapshot("spark", snapshot, true)
+	if got := Find(workloads, "redis"); len(got) != 1 || got[0].Kind != KindContainer {
+		t.Fatalf("Find(redis) = %+v", got)
+	}
+	if got := Find(workloads, "aaaa"); len(got) != 1 || got[0].Name != "dgx-qwen" {
+		t.Fatalf("Find by ID prefix = %+v", got)
+	}
+	if got := Find(workloads, "5151"); len(got) != 1 || got[0].Kind != KindProcess {
+		t.Fatalf("Find(PID) = %+v", got)
+	}
+}
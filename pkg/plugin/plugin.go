@@ -0,0 +1,146 @@
+// Package plugin is the SDK for dgx plugins. A plugin is any executable named dgx-<name>
+// on PATH; 'dgx <name> [args...]' runs it with the resolved DGX connection in the
+// environment, so plugins see the same host, profile, and key as built-in commands.
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Prefix is the executable name prefix that makes a program a dgx plugin
+const Prefix = "dgx-"
+
+// Environment variables dgx sets for a plugin. The connection variables are the same
+// ones dgx reads, so running dgx from a plugin targets the same DGX.
+const (
+	EnvPlugin          = "DGX_PLUGIN" // the plugin name, e.g. "hello" for dgx-hello
+	EnvBin             = "DGX_BIN"    // path of the dgx executable that started the plugin
+	EnvHost            = "DGX_HOST"
+	EnvPort            = "DGX_PORT"
+	EnvUser            = "DGX_USER"
+	EnvIdentityFile    = "DGX_IDENTITY_FILE"
+	EnvCertificateFile = "DGX_CERTIFICATE_FILE" // set only when the config names one
+	EnvProfile         = "DGX_PROFILE"
+	EnvConfig          = "DGX_CONFIG"
+	EnvReadOnly        = "DGX_READONLY" // "1" when the connection is read-only
+)
+
+// ErrNotPlugin is returned by FromEnv when the program was not started by dgx
+var ErrNotPlugin = errors.New("not started by dgx: run it as 'dgx <name>'")
+
+// Connection is the DGX a plugin was asked to act on
+type Connection struct {
+	Name         string
+	Host         string
+	Port         int
+	User         string
+	IdentityFile string
+	// CertificateFile is the SSH certificate for IdentityFile, when not at the default
+	// <identity_file>-cert.pub that ssh finds on its own
+	CertificateFile string
+	Profile         string
+	ReadOnly        bool
+}
+
+// FromEnv reads the connection dgx passed to the plugin
+func FromEnv() (*Connection, error) {
+	name := os.Getenv(EnvPlugin)
+	if name == "" {
+		return nil, ErrNotPlugin
+	}
+	c := &Connection{
+		Name:            name,
+		Host:            os.Getenv(EnvHost),
+		Port:            22,
+		User:            os.Getenv(EnvUser),
+		IdentityFile:    os.Getenv(EnvIdentityFile),
+		CertificateFile: os.Getenv(EnvCertificateFile),
+		Profile:         os.Getenv(EnvProfile),
+		ReadOnly:        os.Getenv(EnvReadOnly) == "1",
+	}
+	if v := os.Getenv(EnvPort); v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", EnvPort, v, err)
+		}
+		c.Port = port
+	}
+	if c.Host == "" {
+		return nil, fmt.Errorf("%s is not set", EnvHost)
+	}
+	return c, nil
+}
+
+// Env returns the variables that describe c, as dgx passes them to plugins
+func (c *Connection) Env() []string {
+	env := []string{
+		EnvPlugin + "=" + c.Name,
+		EnvHost + "=" + c.Host,
+		EnvPort + "=" + strconv.Itoa(c.Port),
+		EnvUser + "=" + c.User,
+		EnvIdentityFile + "=" + c.IdentityFile,
+		EnvProfile + "=" + c.Profile,
+	}
+	if c.CertificateFile != "" {
+		env = append(env, EnvCertificateFile+"="+c.CertificateFile)
+	}
+	if c.ReadOnly {
+		env = append(env, EnvReadOnly+"=1")
+	}
+	return env
+}
+
+// Target returns user@host
+func (c *Connection) Target() string {
+	if c.User == "" {
+		return c.Host
+	}
+	return c.User + "@" + c.Host
+}
+
+// SSHArgs returns the ssh arguments that reach the DGX, ending with user@host
+func (c *Connection) SSHArgs() []string {
+	var args []string
+	if c.IdentityFile != "" {
+		args = append(args, "-i", c.IdentityFile)
+	}
+	if c.CertificateFile != "" {
+		args = append(args, "-o", "CertificateFile="+c.CertificateFile)
+	}
+	return append(args, "-p", strconv.Itoa(c.Port), c.Target())
+}
+
+// Command returns an ssh command that runs script in a login shell on the DGX
+func (c *Connection) Command(script string) *exec.Cmd {
+	args := append(c.SSHArgs(), "bash", "-lc", shellQuote(script))
+	return exec.Command("ssh", args...)
+}
+
+// Output runs script on the DGX and returns its standard output
+func (c *Connection) Output(script string) (string, error) {
+	cmd := c.Command(script)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// Dgx returns a command that runs dgx itself with args against the same connection
+func Dgx(args ...string) *exec.Cmd {
+	bin := os.Getenv(EnvBin)
+	if bin == "" {
+		bin = "dgx"
+	}
+	cmd := exec.Command(bin, args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd
+}
+
+// shellQuote quotes s for the remote shell that ssh hands the command to
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
@@ -0,0 +1,30 @@
+package plugin
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFromEnvRoundTrip(t *testing.T) {
+	t.Setenv(EnvPlugin, "")
+	if _, err := FromEnv(); !errors.Is(err, ErrNotPlugin) {
+		t.Fatalf("FromEnv outside dgx: got %v, want ErrNotPlugin", err)
+	}
+
+	want := Connection{Name: "hello", Host: "spark.local", Port: 2222, User: "ubuntu", IdentityFile: "/keys/id", Profile: "lab", ReadOnly: true}
+	for _, kv := range want.Env() {
+		k, v, _ := strings.Cut(kv, "=")
+		t.Setenv(k, v)
+	}
+	got, err := FromEnv()
+	if err != nil {
+		t.Fatalf("FromEnv: %v", err)
+	}
+	if *got != want {
+		t.Fatalf("FromEnv = %+v, want %+v", *got, want)
+	}
+	if args := strings.Join(got.SSHArgs(), " "); args != "-i /keys/id -p 2222 ubuntu@spark.local" {
+		t.Fatalf("SSHArgs = %q", args)
+	}
+}
@@ -8,7 +8,218 @@ type Config struct {
 	Port         int    `yaml:"port"`
 	User         string `yaml:"user"`
 	IdentityFile string `yaml:"identity_file"`
-	Tunnels      []Tunnel `yaml:"tunnels,omitempty"`
+	// CertificateFile is an OpenSSH user certificate for IdentityFile, signed by a CA the
+	// DGX trusts. Unset, <identity_file>-cert.pub is used when it exists.
+	CertificateFile string       `yaml:"certificate_file,omitempty"`
+	Tunnels         []Tunnel     `yaml:"tunnels,omitempty"`
+	Serve           *ServeConfig `yaml:"serve,omitempty"`
+	// PlaybookRetries overrides how often idempotent playbook steps are retried after a
+	// transient failure (0 disables retries)
+	PlaybookRetries *int `yaml:"playbook_retries,omitempty"`
+	// KeyCache set to "agent" keeps passphrase-protected keys decrypted in an ssh-agent
+	// spawned by dgx for KeyCacheTTL (default 1h), so later commands do not prompt again
+	KeyCache    string        `yaml:"key_cache,omitempty"`
+	KeyCacheTTL time.Duration `yaml:"key_cache_ttl,omitempty"`
+	// SSH tunes the transport; a profile's settings are layered over these field by field
+	SSH *SSHOptions `yaml:"ssh,omitempty"`
+	// Pins hold managed components at fixed versions; a profile's pins are layered over
+	// these field by field
+	Pins *VersionPins `yaml:"pins,omitempty"`
+	// Profiles are alternative DGX connections selected with --profile or active_profile
+	Profiles      map[string]Profile `yaml:"profiles,omitempty"`
+	ActiveProfile string             `yaml:"active_profile,omitempty"`
+	NVSync        *NVSyncImport      `yaml:"nvsync,omitempty"`
+	DevSetup      *DevSetupConfig    `yaml:"devsetup,omitempty"`
+	// ReadOnly blocks playbook commands that change the DGX, e.g. for a shared lab Spark
+	// that students should only inspect. Profiles and --readonly can turn it on, not off.
+	ReadOnly bool `yaml:"readonly,omitempty"`
+	// Plain makes every command print plain lines, for screen readers: no progress bars,
+	// live tables, colors, or cursor movement (see --plain)
+	Plain bool `yaml:"plain,omitempty"`
+	// Confirm is how destructive commands are confirmed: "prompt" (default, [y/N]) or
+	// "typed", which asks for the hostname to be typed even with --yes
+	Confirm string `yaml:"confirm,omitempty"`
+	// ExecAllow, when set, limits `dgx exec` to commands matching one of these patterns
+	// (see policy.MatchExec) and disables interactive shells. A profile's list replaces it.
+	ExecAllow []string `yaml:"exec_allow,omitempty"`
+	// MAC and MDNS help find the DGX when its DHCP address changes; dgx also remembers the
+	// MAC and hostname it saw on earlier connections
+	MAC  string `yaml:"mac,omitempty"`
+	MDNS string `yaml:"mdns,omitempty"`
+	// Power prices the energy reported by `dgx power report`
+	Power *PowerConfig `yaml:"power,omitempty"`
+	// Alerts are the rules `dgx daemon` evaluates against the DGX and the hooks it notifies
+	// when one fires or resolves
+	Alerts *AlertsConfig `yaml:"alerts,omitempty"`
+	// Poll paces the loops that query the DGX again and again, such as status waits, the
+	// GPU status line, and event feeds
+	Poll *PollConfig `yaml:"poll,omitempty"`
+	// Defaults fill in the model, system prompt, and temperature of chat, test, and
+	// playbook commands when they are left out. Presets are named alternatives; the one
+	// picked with `dgx preset use` is layered over Defaults.
+	Defaults     *Preset           `yaml:"defaults,omitempty"`
+	Presets      map[string]Preset `yaml:"presets,omitempty"`
+	ActivePreset string            `yaml:"active_preset,omitempty"`
+}
+
+// Preset is a model with the system prompt and sampling temperature to use with it. A nil
+// Temperature leaves it to the server.
+type Preset struct {
+	Model       string   `yaml:"model,omitempty"`
+	System      string   `yaml:"system,omitempty"`
+	Temperature *float64 `yaml:"temperature,omitempty"`
+}
+
+// SSHOptions are advanced SSH transport settings, for links such as VPNs where the
+// defaults perform badly. Empty fields keep the defaults.
+type SSHOptions struct {
+	// Ciphers, KEX, and MACs are algorithm preference lists, most preferred first
+	Ciphers []string `yaml:"ciphers,omitempty"`
+	KEX     []string `yaml:"kex,omitempty"`
+	MACs    []string `yaml:"macs,omitempty"`
+	// Compression turns on zlib compression in ssh(1) sessions: connect, tunnels, and sync
+	Compression *bool `yaml:"compression,omitempty"`
+	// KeepAlive is the interval of TCP and SSH keepalives; a negative value disables them
+	KeepAlive time.Duration `yaml:"keepalive,omitempty"`
+	// DialTimeout bounds connecting and the SSH handshake (default 10s)
+	DialTimeout time.Duration `yaml:"dial_timeout,omitempty"`
+}
+
+// VersionPins are the versions playbooks install instead of the latest, so a demo or
+// production Spark is never upgraded by surprise. Empty fields are not pinned.
+type VersionPins struct {
+	// ModelPlugin is the docker-model-plugin package version, as apt-cache policy lists it
+	ModelPlugin string `yaml:"model_plugin,omitempty"`
+	// Runner is the Docker Model Runner image version, e.g. "v0.1.44" for the
+	// docker/model-runner:v0.1.44-cuda image
+	Runner string `yaml:"runner,omitempty"`
+	// DriverBranch is the NVIDIA driver branch, e.g. "580"; updates stay on it
+	DriverBranch string `yaml:"driver_branch,omitempty"`
+	// GPUBurn is the full wilicc/gpu-burn commit `dgx gpu stress --method gpu-burn` builds
+	GPUBurn string `yaml:"gpu_burn,omitempty"`
+}
+
+// PowerConfig holds the electricity rate, per kWh in Currency (default "USD")
+type PowerConfig struct {
+	Rate     float64 `yaml:"rate,omitempty"`
+	Currency string  `yaml:"currency,omitempty"`
+}
+
+// PollConfig sets how often one DGX may be polled for the same reading: MinInterval
+// (default 1s) for every host, or per host in Hosts. Loops running at once, in any dgx
+// process, share it, so two dashboards each poll half as often.
+type PollConfig struct {
+	MinInterval time.Duration            `yaml:"min_interval,omitempty"`
+	Hosts       map[string]time.Duration `yaml:"hosts,omitempty"`
+}
+
+// AlertsConfig holds alert rules, checked every Interval (default 30s), and the hooks
+// notified of their events. Without rules the defaults listed by `dgx alerts list` apply.
+type AlertsConfig struct {
+	Interval time.Duration `yaml:"interval,omitempty"`
+	Rules    []AlertRule   `yaml:"rules,omitempty"`
+	Hooks    []AlertHook   `yaml:"hooks,omitempty"`
+}
+
+// AlertRule fires once Metric has stayed above Above for For. Metrics are gpu_temp (°C),
+// gpu_util, memory, and disk (percent of Path used, default "/"), and deployment, which
+// fires while the autostart unit named by Deployment (any, when empty) is not active.
+type AlertRule struct {
+	Name       string        `yaml:"name"`
+	Metric     string        `yaml:"metric"`
+	Above      float64       `yaml:"above,omitempty"`
+	For        time.Duration `yaml:"for,omitempty"`
+	Path       string        `yaml:"path,omitempty"`
+	Deployment string        `yaml:"deployment,omitempty"`
+}
+
+// AlertHook is one notification target: URL receives each event as a JSON POST; Command
+// runs locally through sh with the event in DGX_ALERT_* variables and as JSON on stdin
+type AlertHook struct {
+	URL     string `yaml:"url,omitempty"`
+	Command string `yaml:"command,omitempty"`
+}
+
+// DevSetupConfig declares the developer tools and dotfiles `dgx run devsetup` installs.
+// Tools are apt package names plus "uv"; DotfilesInstall runs inside the checkout
+// (default: ./install.sh or ./bootstrap.sh when present).
+type DevSetupConfig struct {
+	Tools           []string `yaml:"tools,omitempty"`
+	DotfilesRepo    string   `yaml:"dotfiles_repo,omitempty"`
+	DotfilesBranch  string   `yaml:"dotfiles_branch,omitempty"`
+	DotfilesInstall string   `yaml:"dotfiles_install,omitempty"`
+}
+
+// Profile is a named DGX connection. Empty fields fall back to the top-level config.
+// Source is "nvsync" for profiles imported from NVIDIA Sync; Stale marks imported profiles
+// whose Host block has since disappeared.
+type Profile struct {
+	Host            string       `yaml:"host,omitempty"`
+	Port            int          `yaml:"port,omitempty"`
+	User            string       `yaml:"user,omitempty"`
+	IdentityFile    string       `yaml:"identity_file,omitempty"`
+	CertificateFile string       `yaml:"certificate_file,omitempty"`
+	Source          string       `yaml:"source,omitempty"`
+	Stale           bool         `yaml:"stale,omitempty"`
+	ReadOnly        bool         `yaml:"readonly,omitempty"`
+	Confirm         string       `yaml:"confirm,omitempty"`
+	ExecAllow       []string     `yaml:"exec_allow,omitempty"`
+	SSH             *SSHOptions  `yaml:"ssh,omitempty"`
+	Pins            *VersionPins `yaml:"pins,omitempty"`
+	MAC             string       `yaml:"mac,omitempty"`
+	MDNS            string       `yaml:"mdns,omitempty"`
+}
+
+// NVSyncImport tracks the NVIDIA Sync config last imported into profiles. With Watch set,
+// changes to the Sync config are picked up on the next dgx invocation.
+type NVSyncImport struct {
+	Watch       bool      `yaml:"watch"`
+	Fingerprint string    `yaml:"fingerprint,omitempty"`
+	ImportedAt  time.Time `yaml:"imported_at,omitempty"`
+}
+
+// ServeConfig configures the local OpenAI-compatible proxy started by `dgx serve`
+type ServeConfig struct {
+	Listen         string        `yaml:"listen,omitempty"`
+	HealthInterval time.Duration `yaml:"health_interval,omitempty"`
+	Routes         []Route       `yaml:"routes,omitempty"`
+	Keys           []APIKey      `yaml:"keys,omitempty"`
+	LogFile        string        `yaml:"log_file,omitempty"`
+	// MaxConcurrency bounds the requests forwarded at once (0 for no limit). Up to MaxQueue
+	// more (default 4 per slot) wait up to QueueTimeout (default 2m); beyond that clients
+	// get 429 with Retry-After.
+	MaxConcurrency int           `yaml:"max_concurrency,omitempty"`
+	MaxQueue       int           `yaml:"max_queue,omitempty"`
+	QueueTimeout   time.Duration `yaml:"queue_timeout,omitempty"`
+	// Wake sends Wake-on-LAN to a suspended backend host when a request finds it asleep,
+	// then retries the request once it answers (see `dgx power idle`)
+	Wake bool `yaml:"wake,omitempty"`
+}
+
+// APIKey grants bearer-token access to the serve proxy.
+// RateLimit is in requests per minute; 0 means unlimited.
+type APIKey struct {
+	Name      string `yaml:"name"`
+	Key       string `yaml:"key"`
+	RateLimit int    `yaml:"rate_limit,omitempty"`
+}
+
+// Route maps a model name pattern to an ordered list of backends.
+// Patterns are exact names, "*" for any model, or a prefix ending in "*" (e.g. "ai/*").
+// Type restricts the route to chat, embedding, or rerank requests; empty serves all.
+type Route struct {
+	Model    string    `yaml:"model" json:"model"`
+	Type     string    `yaml:"type,omitempty" json:"type,omitempty"`
+	Backends []Backend `yaml:"backends" json:"backends"`
+}
+
+// Backend is an inference server reachable over SSH from the workstation.
+// Host is empty for the configured DGX or names a second Spark node.
+type Backend struct {
+	Name     string `yaml:"name" json:"name"`
+	Host     string `yaml:"host,omitempty" json:"host,omitempty"`
+	Port     int    `yaml:"port" json:"port"`
+	BasePath string `yaml:"base_path,omitempty" json:"base_path,omitempty"` // e.g. "/engines" for Docker Model Runner
 }
 
 // Tunnel represents an SSH tunnel configuration